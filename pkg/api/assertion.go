@@ -0,0 +1,181 @@
+package api
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// Assertion is a success criterion of a run, evaluated by the daemon once the
+// run completes. A run whose assertions don't all pass has a failure outcome,
+// even if all its instances succeeded.
+//
+// An assertion either checks a metric recorded by the instances, or checks
+// that all instances signalled a state, e.g.:
+//
+//	[[runs.assertions]]
+//	metric = "message-latency"
+//	measure = "p95"
+//	op = "<"
+//	value = "500ms"
+//
+//	[[runs.assertions]]
+//	state = "done"
+type Assertion struct {
+	// Metric is the name of the metric the assertion checks.
+	Metric string `toml:"metric" json:"metric,omitempty"`
+
+	// Measure is the measure of the metric the assertion checks, e.g. "p95"
+	// for a histogram or a timer (default: "value").
+	Measure string `toml:"measure" json:"measure,omitempty"`
+
+	// Aggregate reduces the values of the measure recorded by all the
+	// instances to the value that is checked: "max", "min", "mean", "sum" or
+	// "count" (default: "max").
+	Aggregate string `toml:"aggregate" json:"aggregate,omitempty"`
+
+	// Op compares the aggregate to Value: "<", "<=", ">", ">=", "==" or "!=".
+	Op string `toml:"op" json:"op,omitempty"`
+
+	// Value is the threshold, either a number or a duration; durations are
+	// compared in nanoseconds, the unit of timers.
+	Value string `toml:"value" json:"value,omitempty"`
+
+	// State is the state all instances must have signalled.
+	State string `toml:"state" json:"state,omitempty"`
+}
+
+// AssertionResult is the result of the evaluation of an assertion.
+type AssertionResult struct {
+	Assertion string `json:"assertion"`
+	Passed    bool   `json:"passed"`
+	// Observed is the value the assertion was evaluated against.
+	Observed string `json:"observed,omitempty"`
+	// Error is set if the assertion could not be evaluated, in which case it
+	// doesn't pass.
+	Error string `json:"error,omitempty"`
+}
+
+var assertionAggregates = map[string]func(values []float64) float64{
+	"max": func(values []float64) float64 {
+		m := values[0]
+		for _, v := range values[1:] {
+			if v > m {
+				m = v
+			}
+		}
+		return m
+	},
+	"min": func(values []float64) float64 {
+		m := values[0]
+		for _, v := range values[1:] {
+			if v < m {
+				m = v
+			}
+		}
+		return m
+	},
+	"sum": sumOf,
+	"mean": func(values []float64) float64 {
+		return sumOf(values) / float64(len(values))
+	},
+	"count": func(values []float64) float64 {
+		return float64(len(values))
+	},
+}
+
+var assertionOps = map[string]func(a, b float64) bool{
+	"<":  func(a, b float64) bool { return a < b },
+	"<=": func(a, b float64) bool { return a <= b },
+	">":  func(a, b float64) bool { return a > b },
+	">=": func(a, b float64) bool { return a >= b },
+	"==": func(a, b float64) bool { return a == b },
+	"!=": func(a, b float64) bool { return a != b },
+}
+
+func sumOf(values []float64) float64 {
+	var s float64
+	for _, v := range values {
+		s += v
+	}
+	return s
+}
+
+// Validate checks the assertion is well formed.
+func (a *Assertion) Validate() error {
+	switch {
+	case a.Metric != "" && a.State != "":
+		return fmt.Errorf("assertion cannot check both metric %s and state %s", a.Metric, a.State)
+	case a.State != "":
+		return nil
+	case a.Metric == "":
+		return fmt.Errorf("assertion must check either a metric or a state")
+	}
+
+	if _, ok := assertionAggregates[a.aggregate()]; !ok {
+		return fmt.Errorf("unknown aggregate %q in assertion on metric %s", a.Aggregate, a.Metric)
+	}
+	if _, ok := assertionOps[a.Op]; !ok {
+		return fmt.Errorf("unknown operator %q in assertion on metric %s", a.Op, a.Metric)
+	}
+	if _, err := a.threshold(); err != nil {
+		return fmt.Errorf("invalid value in assertion on metric %s: %w", a.Metric, err)
+	}
+	return nil
+}
+
+// MeasureName returns the measure of the metric the assertion checks.
+func (a *Assertion) MeasureName() string {
+	if a.Measure == "" {
+		return "value"
+	}
+	return a.Measure
+}
+
+func (a *Assertion) aggregate() string {
+	if a.Aggregate == "" {
+		return "max"
+	}
+	return a.Aggregate
+}
+
+func (a *Assertion) threshold() (float64, error) {
+	if v, err := strconv.ParseFloat(a.Value, 64); err == nil {
+		return v, nil
+	}
+	d, err := time.ParseDuration(a.Value)
+	if err != nil {
+		return 0, fmt.Errorf("%q is neither a number nor a duration", a.Value)
+	}
+	return float64(d.Nanoseconds()), nil
+}
+
+// String describes the assertion, e.g. "max(message-latency.p95) < 500ms".
+func (a *Assertion) String() string {
+	if a.State != "" {
+		return fmt.Sprintf("all instances signalled state %s", a.State)
+	}
+	return fmt.Sprintf("%s(%s.%s) %s %s", a.aggregate(), a.Metric, a.MeasureName(), a.Op, a.Value)
+}
+
+// EvaluateMetric evaluates a metric assertion against the values of the
+// measure recorded by the instances.
+func (a *Assertion) EvaluateMetric(values []float64) *AssertionResult {
+	res := &AssertionResult{Assertion: a.String()}
+
+	if err := a.Validate(); err != nil {
+		res.Error = err.Error()
+		return res
+	}
+
+	if len(values) == 0 && a.aggregate() != "count" {
+		res.Error = fmt.Sprintf("no values recorded for metric %s", a.Metric)
+		return res
+	}
+
+	threshold, _ := a.threshold()
+	observed := assertionAggregates[a.aggregate()](values)
+	res.Observed = strconv.FormatFloat(observed, 'f', -1, 64)
+	res.Passed = assertionOps[a.Op](observed, threshold)
+	return res
+}
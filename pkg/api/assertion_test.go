@@ -0,0 +1,42 @@
+package api
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAssertionValidate(t *testing.T) {
+	require.NoError(t, (&Assertion{Metric: "latency", Op: "<", Value: "500ms"}).Validate())
+	require.NoError(t, (&Assertion{Metric: "errors", Aggregate: "count", Op: "==", Value: "0"}).Validate())
+	require.NoError(t, (&Assertion{State: "done"}).Validate())
+
+	require.Error(t, (&Assertion{}).Validate())
+	require.Error(t, (&Assertion{Metric: "latency", State: "done"}).Validate())
+	require.Error(t, (&Assertion{Metric: "latency", Op: "~", Value: "1"}).Validate())
+	require.Error(t, (&Assertion{Metric: "latency", Aggregate: "median", Op: "<", Value: "1"}).Validate())
+	require.Error(t, (&Assertion{Metric: "latency", Op: "<", Value: "fast"}).Validate())
+}
+
+func TestAssertionEvaluateMetric(t *testing.T) {
+	a := &Assertion{Metric: "latency", Measure: "p95", Op: "<", Value: "500ms"}
+	require.Equal(t, "max(latency.p95) < 500ms", a.String())
+
+	res := a.EvaluateMetric([]float64{100e6, 499e6})
+	require.True(t, res.Passed)
+	require.Equal(t, "499000000", res.Observed)
+
+	res = a.EvaluateMetric([]float64{100e6, 501e6})
+	require.False(t, res.Passed)
+	require.Empty(t, res.Error)
+
+	res = a.EvaluateMetric(nil)
+	require.False(t, res.Passed)
+	require.NotEmpty(t, res.Error)
+
+	mean := &Assertion{Metric: "throughput", Aggregate: "mean", Op: ">=", Value: "10"}
+	require.True(t, mean.EvaluateMetric([]float64{5, 15}).Passed)
+
+	none := &Assertion{Metric: "errors", Aggregate: "count", Op: "==", Value: "0"}
+	require.True(t, none.EvaluateMetric(nil).Passed)
+}
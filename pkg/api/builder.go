@@ -3,6 +3,7 @@ package api
 import (
 	"context"
 	"reflect"
+	"time"
 
 	"github.com/testground/testground/pkg/config"
 	"github.com/testground/testground/pkg/rpc"
@@ -25,6 +26,25 @@ type Builder interface {
 	ConfigType() reflect.Type
 }
 
+// Artifact is the output of a build that outlives it, e.g. a docker image or
+// a binary.
+type Artifact struct {
+	ID      string
+	Plan    string
+	Created time.Time
+	Size    int64
+}
+
+// ArtifactCollector is implemented by builders whose artifacts can be
+// garbage collected by the retention policies of the daemon.
+type ArtifactCollector interface {
+	// ListArtifacts returns the artifacts built by this builder.
+	ListArtifacts(ctx context.Context, env *config.EnvConfig) ([]*Artifact, error)
+
+	// RemoveArtifact removes an artifact returned by ListArtifacts.
+	RemoveArtifact(ctx context.Context, artifact *Artifact) error
+}
+
 // BuildInput encapsulates the input options for building a test plan.
 type BuildInput struct {
 	// BuildID is a unique ID for this build.
@@ -69,6 +89,33 @@ type BuildOutput struct {
 	// containing the collapsed transitive upstream dependency set of this
 	// build.
 	Dependencies map[string]string
+
+	// Config is the configuration the build ran with: the env config of the
+	// builder coalesced with the build config of the group. It's set by the
+	// engine.
+	Config map[string]interface{}
+
+	// Scan is the report of the vulnerability scan of the artifact, if the
+	// daemon scans artifacts. It's set by the engine.
+	Scan *ScanReport
+
+	// Groups are the ids of the groups the build was for. It's set by the
+	// engine.
+	Groups []string
+
+	// Files are the files of the build for consumers other than runners,
+	// e.g. a binary, or the configs generated by the build. The engine keeps
+	// them once the build is done, for `testground build collect`.
+	Files []BuildFile
+}
+
+// BuildFile is a file produced by a build.
+type BuildFile struct {
+	// Name is the path of the file in the archive of the files of the build.
+	Name string
+	// Path is the path of the file on the host of the daemon, which may be
+	// removed once the build is done.
+	Path string
 }
 
 // DependencyTarget encapsulates the target and version of a dependency.
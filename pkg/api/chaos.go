@@ -0,0 +1,148 @@
+package api
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+// ChaosAction is a disruption of an instance of a live run.
+type ChaosAction string
+
+const (
+	// ChaosKill kills the instance. It is started again after the restore
+	// delay of the rule, if any.
+	ChaosKill ChaosAction = "kill"
+	// ChaosPause freezes the instance. It is resumed after the restore delay
+	// of the rule, if any.
+	ChaosPause ChaosAction = "pause"
+	// ChaosRestart restarts the instance right away.
+	ChaosRestart ChaosAction = "restart"
+
+	// ChaosStart starts an instance killed earlier.
+	ChaosStart ChaosAction = "start"
+	// ChaosResume resumes an instance paused earlier.
+	ChaosResume ChaosAction = "resume"
+)
+
+// ChaosRule schedules disruptions of the instances of a group, e.g. "kill 10%
+// of the validators every 2m, and start them again after 30s".
+type ChaosRule struct {
+	// Group is the id of the run group whose instances are disrupted.
+	Group string `toml:"group" json:"group"`
+
+	// Action is the disruption: kill, pause or restart.
+	Action ChaosAction `toml:"action" json:"action"`
+
+	// Count is the number of running instances disrupted every time.
+	//
+	// Specifying a count is mutually exclusive with specifying a percentage.
+	Count uint `toml:"count" json:"count,omitempty"`
+
+	// Percentage is the proportion of the running instances of the group
+	// disrupted every time, rounded up.
+	//
+	// Specifying a percentage is mutually exclusive with specifying a count.
+	Percentage float64 `toml:"percentage" json:"percentage,omitempty"`
+
+	// Every is the interval between two disruptions, in time.Duration string
+	// representation (e.g. 2m).
+	Every string `toml:"every" json:"every"`
+
+	// StartAfter is the delay before the first disruption, from the start of
+	// the run (default: Every).
+	StartAfter string `toml:"start_after" json:"start_after,omitempty" mapstructure:"start_after"`
+
+	// RestoreAfter is the delay after which killed instances are started
+	// again, and paused instances resumed. Instances are not restored if
+	// empty.
+	RestoreAfter string `toml:"restore_after" json:"restore_after,omitempty" mapstructure:"restore_after"`
+}
+
+// Validate checks the action, the number of instances and the delays of the
+// rule.
+func (r *ChaosRule) Validate() error {
+	if r.Group == "" {
+		return fmt.Errorf("chaos rule has no group")
+	}
+
+	switch r.Action {
+	case ChaosKill, ChaosPause:
+	case ChaosRestart:
+		if r.RestoreAfter != "" {
+			return fmt.Errorf("chaos rule on group %s: restore_after does not apply to restarts", r.Group)
+		}
+	default:
+		return fmt.Errorf("chaos rule on group %s: unknown action %q; supported: %s, %s, %s", r.Group, r.Action, ChaosKill, ChaosPause, ChaosRestart)
+	}
+
+	if (r.Count == 0) == (r.Percentage == 0) {
+		return fmt.Errorf("chaos rule on group %s: either count or percentage is required, not both", r.Group)
+	}
+	if r.Percentage < 0 || r.Percentage > 1 {
+		return fmt.Errorf("chaos rule on group %s: percentage must be between 0 and 1", r.Group)
+	}
+
+	if every, err := time.ParseDuration(r.Every); err != nil || every <= 0 {
+		return fmt.Errorf("chaos rule on group %s: invalid interval %q", r.Group, r.Every)
+	}
+	for _, d := range []string{r.StartAfter, r.RestoreAfter} {
+		if d == "" {
+			continue
+		}
+		if v, err := time.ParseDuration(d); err != nil || v < 0 {
+			return fmt.Errorf("chaos rule on group %s: invalid delay %q", r.Group, d)
+		}
+	}
+	return nil
+}
+
+// Schedule returns the interval between two disruptions, the delay before
+// the first one, and the restore delay of a valid rule.
+func (r *ChaosRule) Schedule() (every, startAfter, restoreAfter time.Duration) {
+	every, _ = time.ParseDuration(r.Every)
+	startAfter = every
+	if r.StartAfter != "" {
+		startAfter, _ = time.ParseDuration(r.StartAfter)
+	}
+	if r.RestoreAfter != "" {
+		restoreAfter, _ = time.ParseDuration(r.RestoreAfter)
+	}
+	return every, startAfter, restoreAfter
+}
+
+// Targets returns the number of instances to disrupt among the running ones.
+func (r *ChaosRule) Targets(running int) int {
+	n := int(r.Count)
+	if r.Percentage > 0 {
+		n = int(math.Ceil(r.Percentage * float64(running)))
+	}
+	if n > running {
+		n = running
+	}
+	return n
+}
+
+// ChaosInput is the input of a chaos action on an instance of a live run.
+type ChaosInput struct {
+	RunID    string
+	Instance *Instance
+	Action   ChaosAction
+	// Restore is set on disruptions followed by a restore. Runners wait for
+	// instances killed this way to be started again before considering them
+	// done.
+	Restore bool
+}
+
+// ChaosEvent records a chaos action on an instance. Events make up the chaos
+// timeline of a run.
+type ChaosEvent struct {
+	Time time.Time `json:"time"`
+	// Rule is the index of the chaos rule of the run the action follows.
+	Rule     int         `json:"rule"`
+	Action   ChaosAction `json:"action"`
+	Group    string      `json:"group"`
+	Instance string      `json:"instance"`
+	// Error is set if the runner could not apply the action.
+	Error string `json:"error,omitempty"`
+}
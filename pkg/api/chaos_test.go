@@ -0,0 +1,62 @@
+package api
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestChaosRuleValidate(t *testing.T) {
+	valid := ChaosRule{Group: "validators", Action: ChaosKill, Percentage: 0.1, Every: "2m", RestoreAfter: "30s"}
+	require.NoError(t, valid.Validate())
+
+	every, startAfter, restoreAfter := valid.Schedule()
+	require.Equal(t, 2*time.Minute, every)
+	require.Equal(t, 2*time.Minute, startAfter)
+	require.Equal(t, 30*time.Second, restoreAfter)
+
+	for _, r := range []ChaosRule{
+		{Action: ChaosKill, Count: 1, Every: "2m"},
+		{Group: "validators", Action: "explode", Count: 1, Every: "2m"},
+		{Group: "validators", Action: ChaosRestart, Count: 1, Every: "2m", RestoreAfter: "30s"},
+		{Group: "validators", Action: ChaosKill, Every: "2m"},
+		{Group: "validators", Action: ChaosKill, Count: 1, Percentage: 0.5, Every: "2m"},
+		{Group: "validators", Action: ChaosKill, Percentage: 10, Every: "2m"},
+		{Group: "validators", Action: ChaosKill, Count: 1},
+		{Group: "validators", Action: ChaosKill, Count: 1, Every: "2m", StartAfter: "soon"},
+	} {
+		require.Error(t, r.Validate(), "%+v", r)
+	}
+}
+
+func TestChaosRuleTargets(t *testing.T) {
+	require.Equal(t, 1, (&ChaosRule{Percentage: 0.1}).Targets(5))
+	require.Equal(t, 2, (&ChaosRule{Percentage: 0.1}).Targets(11))
+	require.Equal(t, 0, (&ChaosRule{Percentage: 0.1}).Targets(0))
+	require.Equal(t, 3, (&ChaosRule{Count: 5}).Targets(3))
+}
+
+type plainRunner struct{ Runner }
+
+func (plainRunner) ID() string { return "cluster:plain" }
+
+type chaosRunner struct{ plainRunner }
+
+func (chaosRunner) InjectChaos(context.Context, *ChaosInput) error { return nil }
+func (chaosRunner) ListInstances(context.Context, string) ([]*Instance, error) {
+	return nil, nil
+}
+
+func TestValidateForRunnerChaos(t *testing.T) {
+	c := &Composition{Runs: Runs{
+		{ID: "calm"},
+		{ID: "chaotic", Chaos: []*ChaosRule{{Group: "validators", Action: ChaosKill, Count: 1, Every: "2m"}}},
+	}}
+	require.NoError(t, c.ValidateForRunner(chaosRunner{}))
+	require.EqualError(t, c.ValidateForRunner(plainRunner{}), "run chaotic: runner cluster:plain does not support chaos rules")
+
+	c.Runs = c.Runs[:1]
+	require.NoError(t, c.ValidateForRunner(plainRunner{}))
+}
@@ -1,6 +1,7 @@
 package api
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"os"
@@ -48,6 +49,11 @@ type Global struct {
 	// scheduled for this test.
 	ConcurrentBuilds int `toml:"concurrent_builds" json:"concurrent_builds"`
 
+	// ConcurrentRuns defines the maximum number of runs of this composition
+	// that are executed concurrently, once the first run has built the
+	// artifacts (default: 1).
+	ConcurrentRuns int `toml:"concurrent_runs" json:"concurrent_runs"`
+
 	// Builder is the default builder we're using.
 	Builder string `toml:"builder" json:"builder"`
 
@@ -72,6 +78,45 @@ type Global struct {
 
 	// DisableMetrics is used to disable metrics batching.
 	DisableMetrics bool `toml:"disable_metrics" json:"disable_metrics"`
+
+	// Network configures the data network of the instances.
+	Network *Network `toml:"network" json:"network"`
+
+	// Timeouts bound the builds and runs of this composition.
+	Timeouts *Timeouts `toml:"timeouts" json:"timeouts,omitempty"`
+
+	// Fixtures are the data sets mounted read-only into the instances of the
+	// runs of this composition.
+	Fixtures Fixtures `toml:"fixtures" json:"fixtures,omitempty"`
+
+	// Diagnostics configures what is captured from the instances of the runs
+	// of this composition that fail.
+	Diagnostics *Diagnostics `toml:"diagnostics" json:"diagnostics,omitempty"`
+}
+
+// IPFamily is the IP family of a data network.
+type IPFamily string
+
+const (
+	// IPv4 data networks are the default.
+	IPv4 IPFamily = "ipv4"
+	// IPv6 data networks have IPv6 addresses only.
+	IPv6 IPFamily = "ipv6"
+	// DualStack data networks have both IPv4 and IPv6 addresses.
+	DualStack IPFamily = "dual"
+)
+
+// HasIPv6 returns whether data networks of the family have IPv6 addresses.
+func (f IPFamily) HasIPv6() bool {
+	return f == IPv6 || f == DualStack
+}
+
+// Network configures the data network of a run.
+type Network struct {
+	// IPFamily is the IP family of the data network: ipv4, ipv6 or dual
+	// (default: ipv4). IPv6 and dual-stack networks are supported by the
+	// local:docker and cluster:k8s runners.
+	IPFamily IPFamily `toml:"ip_family" json:"ip_family" mapstructure:"ip_family" validate:"omitempty,oneof=ipv4 ipv6 dual"`
 }
 
 type Metadata struct {
@@ -109,6 +154,11 @@ type Group struct {
 	// Run specifies the run configuration for this group.
 	Run RunParams `toml:"run" json:"run"`
 
+	// Repeat makes this group a template: the group is expanded into Repeat
+	// copies of it when the composition is loaded, with ids suffixed by their
+	// index.
+	Repeat uint `toml:"repeat,omitempty" json:"repeat,omitempty"`
+
 	// calculatedInstanceCnt caches the actual number of instances in this
 	// group.
 	calculatedInstanceCnt uint
@@ -118,6 +168,10 @@ type Run struct {
 	// ID is the unique ID of this run group.
 	ID string `toml:"id" json:"id"`
 
+	// Priority orders the runs in CI mode: higher priority runs are scheduled
+	// first, and lower priority runs are shed first when the budget is at risk.
+	Priority int `toml:"priority" json:"priority"`
+
 	// TestParams specify the test parameters to pass down to instances of this
 	// group.
 	TestParams map[string]string `toml:"test_params" json:"test_params" mapstructure:"test_params"`
@@ -128,6 +182,36 @@ type Run struct {
 
 	// Instances defines the number of instances that belong to this group.
 	Groups CompositionRunGroups `toml:"groups" json:"groups" validate:"required,gt=0"`
+
+	// DependsOn lists the runs that must complete successfully before this
+	// run starts. Their outputs are made available to the instances of this
+	// run.
+	DependsOn []string `toml:"depends_on" json:"depends_on,omitempty" mapstructure:"depends_on"`
+
+	// Retry configures the attempts the engine makes at this run when it
+	// fails.
+	Retry *RetryPolicy `toml:"retry" json:"retry,omitempty"`
+
+	// Assertions are the success criteria of this run, evaluated once it
+	// completes.
+	Assertions []*Assertion `toml:"assertions" json:"assertions,omitempty"`
+
+	// Chaos schedules disruptions of the instances of this run.
+	Chaos []*ChaosRule `toml:"chaos" json:"chaos,omitempty"`
+
+	// Readiness aborts this run early if not enough of its instances become
+	// ready in time, as checked by the readiness probe of the test case.
+	Readiness *ReadinessGate `toml:"readiness" json:"readiness,omitempty"`
+
+	// Sweep declares ranges of parameters; the run is expanded into one run
+	// per combination of values when the composition is loaded.
+	Sweep *Sweep `toml:"sweep" json:"sweep,omitempty"`
+
+	// SweptFrom is the id of the run this run was expanded from, if any.
+	SweptFrom string `toml:"swept_from,omitempty" json:"swept_from,omitempty" mapstructure:"swept_from"`
+
+	// SweptParams are the values of the swept parameters of this run.
+	SweptParams map[string]string `toml:"swept_params,omitempty" json:"swept_params,omitempty" mapstructure:"swept_params"`
 }
 
 type CompositionRunGroups []*CompositionRunGroup
@@ -161,6 +245,18 @@ type CompositionRunGroup struct {
 	// CPU profile for the entire duration of the test.
 	Profiles map[string]string `toml:"profiles" json:"profiles"`
 
+	// Env are the variables set in the runtime environment of the instances
+	// of this group, e.g. feature flags. Variables can also be injected with
+	// the --env and --env-file flags of testground run, over these.
+	Env map[string]string `toml:"env" json:"env,omitempty"`
+
+	// Volume is the persistent volume mounted in the instances of this group.
+	Volume *Volume `toml:"volume" json:"volume,omitempty"`
+
+	// Publish are the ports of the instances of this group published on the
+	// host.
+	Publish []PublishedPort `toml:"publish" json:"publish,omitempty"`
+
 	// calculatedInstanceCnt caches the actual number of instances in this
 	// group.
 	calculatedInstanceCnt uint
@@ -297,6 +393,18 @@ type RunParams struct {
 	// profile kind "cpu" is supported; it takes no frequency and it starts a
 	// CPU profile for the entire duration of the test.
 	Profiles map[string]string `toml:"profiles" json:"profiles"`
+
+	// Env are the variables set in the runtime environment of the instances
+	// of this group, e.g. feature flags. Variables can also be injected with
+	// the --env and --env-file flags of testground run, over these.
+	Env map[string]string `toml:"env" json:"env,omitempty"`
+
+	// Volume is the persistent volume mounted in the instances of this group.
+	Volume *Volume `toml:"volume" json:"volume,omitempty"`
+
+	// Publish are the ports of the instances of this group published on the
+	// host.
+	Publish []PublishedPort `toml:"publish" json:"publish,omitempty"`
 }
 
 type Dependency struct {
@@ -356,7 +464,7 @@ func (c Composition) FrameForRuns(runIds ...string) (*Composition, error) {
 
 	// Gather every run used + the corresponding groups.
 	for _, runId := range runIds {
-		run, err := c.getRun(runId)
+		run, err := c.GetRun(runId)
 
 		if err != nil {
 			return nil, fmt.Errorf("invalid run id %s: %w", runId, err)
@@ -387,7 +495,7 @@ func (c Composition) FrameForRuns(runIds ...string) (*Composition, error) {
 	return &c, nil
 }
 
-func (c Composition) getRun(runId string) (*Run, error) {
+func (c Composition) GetRun(runId string) (*Run, error) {
 	for _, x := range c.Runs {
 		if x.ID == runId {
 			return x, nil
@@ -451,8 +559,25 @@ func WriteCompositionToFile(comp *Composition, file string) error {
 		return fmt.Errorf("failed to write composition to file: %w", err)
 	}
 
-	enc := toml.NewEncoder(f)
-	if err := enc.Encode(comp); err != nil {
+	format := CompositionFormatOf(file)
+	if format == FormatTOML {
+		enc := toml.NewEncoder(f)
+		if err := enc.Encode(comp); err != nil {
+			return fmt.Errorf("failed to encode composition into file: %w", err)
+		}
+		return nil
+	}
+
+	// Other formats have the keys of TOML.
+	var buf bytes.Buffer
+	if err := toml.NewEncoder(&buf).Encode(comp); err != nil {
+		return fmt.Errorf("failed to encode composition into file: %w", err)
+	}
+	tree, err := DecodeCompositionTree(FormatTOML, buf.Bytes())
+	if err != nil {
+		return fmt.Errorf("failed to encode composition into file: %w", err)
+	}
+	if err := EncodeCompositionTree(format, tree, f); err != nil {
 		return fmt.Errorf("failed to encode composition into file: %w", err)
 	}
 	return nil
@@ -466,6 +591,9 @@ func (g *Group) DefaultRunGroup() (*CompositionRunGroup) {
 		Instances:  g.Instances,
 		TestParams: g.Run.TestParams,
 		Profiles:   g.Run.Profiles,
+		Env:        g.Run.Env,
+		Volume:     g.Run.Volume,
+		Publish:    g.Run.Publish,
 	}
 }
 
@@ -475,7 +603,7 @@ func (r *CompositionRunGroup) merge(other *Group) (error) {
 		return err
 	}
 
-	err = mergo.Merge(&r.Instances, other.Instances)
+	err = r.mergeInstances(other)
 	if err != nil {
 		return err
 	}
@@ -488,6 +616,24 @@ func (r *CompositionRunGroup) merge(other *Group) (error) {
 	return nil
 }
 
+func (r *CompositionRunGroup) mergeInstances(other *Group) error {
+	return mergo.Merge(&r.Instances, other.Instances)
+}
+
+// EffectiveInstances returns the instances of this run group, inheriting what
+// it doesn't set from the group of the composition it belongs to, as when the
+// composition is prepared for a run.
+func (r CompositionRunGroup) EffectiveInstances(c *Composition) (Instances, error) {
+	group, err := c.GetGroup(r.EffectiveGroupId())
+	if err != nil {
+		return Instances{}, err
+	}
+	if err := r.mergeInstances(group); err != nil {
+		return Instances{}, err
+	}
+	return r.Instances, nil
+}
+
 func (r *CompositionRunGroup) mergeRun(other *RunParams) (error) {
 	err := mergo.Merge(&r.TestParams, other.TestParams)
 	if err != nil {
@@ -499,5 +645,18 @@ func (r *CompositionRunGroup) mergeRun(other *RunParams) (error) {
 		return err
 	}
 
+	err = mergo.Merge(&r.Env, other.Env)
+	if err != nil {
+		return err
+	}
+
+	if r.Volume == nil {
+		r.Volume = other.Volume
+	}
+
+	if r.Publish == nil {
+		r.Publish = other.Publish
+	}
+
 	return nil
 }
\ No newline at end of file
@@ -0,0 +1,217 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// CompositionFormat is the format of a composition file. All formats have
+// the same keys.
+type CompositionFormat string
+
+const (
+	FormatTOML = CompositionFormat("toml")
+	FormatYAML = CompositionFormat("yaml")
+	FormatJSON = CompositionFormat("json")
+)
+
+// CompositionFormatOf returns the format of a composition file, by its
+// extension. Files with an unknown extension are TOML.
+func CompositionFormatOf(path string) CompositionFormat {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		return FormatYAML
+	case ".json":
+		return FormatJSON
+	default:
+		return FormatTOML
+	}
+}
+
+// ParseCompositionFormat parses the name of a format.
+func ParseCompositionFormat(s string) (CompositionFormat, error) {
+	switch f := CompositionFormat(strings.ToLower(s)); f {
+	case FormatTOML, FormatYAML, FormatJSON:
+		return f, nil
+	case "yml":
+		return FormatYAML, nil
+	default:
+		return "", fmt.Errorf("unknown composition format %q; formats: toml, yaml, json", s)
+	}
+}
+
+// DecodeCompositionTree decodes a composition file into its tree. Whatever
+// the format, the tree has the types of a decoded TOML document: tables are
+// map[string]interface{}, arrays of tables []map[string]interface{}, and
+// numbers int64 or float64.
+func DecodeCompositionTree(format CompositionFormat, src []byte) (map[string]interface{}, error) {
+	var tree map[string]interface{}
+	switch format {
+	case FormatTOML:
+		if _, err := toml.Decode(string(src), &tree); err != nil {
+			return nil, err
+		}
+		return tree, nil
+	case FormatYAML:
+		if err := yaml.Unmarshal(src, &tree); err != nil {
+			return nil, err
+		}
+	case FormatJSON:
+		dec := json.NewDecoder(bytes.NewReader(src))
+		dec.UseNumber()
+		if err := dec.Decode(&tree); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unknown composition format %q", format)
+	}
+
+	v, err := normalizeTree(tree, "")
+	if err != nil {
+		return nil, err
+	}
+	if v == nil {
+		return map[string]interface{}{}, nil
+	}
+	return v.(map[string]interface{}), nil
+}
+
+// normalizeTree converts a decoded YAML or JSON value to the types of a
+// decoded TOML value. Null values are dropped, as TOML has none.
+func normalizeTree(v interface{}, path string) (interface{}, error) {
+	switch x := v.(type) {
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(x))
+		for k, e := range x {
+			n, err := normalizeTree(e, joinPath(path, k))
+			if err != nil {
+				return nil, err
+			}
+			if n != nil {
+				m[k] = n
+			}
+		}
+		return m, nil
+	case map[interface{}]interface{}:
+		return nil, fmt.Errorf("%s: keys must be strings", path)
+	case []interface{}:
+		elems := make([]interface{}, 0, len(x))
+		tables := len(x) > 0
+		for i, e := range x {
+			n, err := normalizeTree(e, joinPath(path, strconv.Itoa(i)))
+			if err != nil {
+				return nil, err
+			}
+			if _, ok := n.(map[string]interface{}); !ok {
+				tables = false
+			}
+			elems = append(elems, n)
+		}
+		if !tables {
+			return elems, nil
+		}
+		ts := make([]map[string]interface{}, 0, len(elems))
+		for _, e := range elems {
+			ts = append(ts, e.(map[string]interface{}))
+		}
+		return ts, nil
+	case int:
+		return int64(x), nil
+	case uint64:
+		return int64(x), nil
+	case json.Number:
+		if n, err := x.Int64(); err == nil {
+			return n, nil
+		}
+		return x.Float64()
+	default:
+		return v, nil
+	}
+}
+
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+// EncodeCompositionTree encodes the tree of a composition in a format.
+func EncodeCompositionTree(format CompositionFormat, tree map[string]interface{}, w io.Writer) error {
+	switch format {
+	case FormatTOML:
+		return toml.NewEncoder(w).Encode(tree)
+	case FormatYAML:
+		enc := yaml.NewEncoder(w)
+		enc.SetIndent(2)
+		if err := enc.Encode(tree); err != nil {
+			return err
+		}
+		return enc.Close()
+	case FormatJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(tree)
+	default:
+		return fmt.Errorf("unknown composition format %q", format)
+	}
+}
+
+// NewKeyLocatorFor returns the locator of the keys of a composition file of
+// any format.
+func NewKeyLocatorFor(format CompositionFormat, src []byte) *KeyLocator {
+	if format == FormatTOML {
+		return NewKeyLocator(src)
+	}
+
+	// JSON documents are YAML documents too.
+	l := &KeyLocator{positions: make(map[string]keyPosition)}
+	var doc yaml.Node
+	if err := yaml.Unmarshal(src, &doc); err != nil || len(doc.Content) == 0 {
+		return l
+	}
+	l.addYAMLNode(doc.Content[0], nil, nil)
+	return l
+}
+
+// addYAMLNode registers the keys of a YAML node, by index and by id.
+func (l *KeyLocator) addYAMLNode(n *yaml.Node, byIndex, byId []string) {
+	switch n.Kind {
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			k, v := n.Content[i], n.Content[i+1]
+			ki, kd := append(append([]string{}, byIndex...), k.Value), append(append([]string{}, byId...), k.Value)
+			pos := keyPosition{k.Line, k.Column}
+			l.positions[strings.Join(ki, ".")] = pos
+			l.positions[strings.Join(kd, ".")] = pos
+			l.addYAMLNode(v, ki, kd)
+		}
+	case yaml.SequenceNode:
+		for i, e := range n.Content {
+			seg := strconv.Itoa(i)
+			id := seg
+			if e.Kind == yaml.MappingNode {
+				for j := 0; j+1 < len(e.Content); j += 2 {
+					if e.Content[j].Value == "id" && e.Content[j+1].Kind == yaml.ScalarNode {
+						id = e.Content[j+1].Value
+					}
+				}
+			}
+			ki, kd := append(append([]string{}, byIndex...), seg), append(append([]string{}, byId...), id)
+			pos := keyPosition{e.Line, e.Column}
+			l.positions[strings.Join(ki, ".")] = pos
+			if _, ok := l.positions[strings.Join(kd, ".")]; !ok {
+				l.positions[strings.Join(kd, ".")] = pos
+			}
+			l.addYAMLNode(e, ki, kd)
+		}
+	}
+}
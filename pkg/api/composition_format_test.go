@@ -0,0 +1,106 @@
+package api
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/BurntSushi/toml"
+	"github.com/stretchr/testify/require"
+)
+
+const formatYAML = `global:
+  plan: network
+  case: ping-pong
+  builder: docker:go
+  runner: local:docker
+  total_instances: 4
+groups:
+  - id: pingers
+    instances:
+      percentage: 0.5
+    run:
+      test_params:
+        latency: 50ms
+  - id: pongers
+    instances: { percentage: 0.5 }
+`
+
+const formatJSON = `{
+  "global": {
+    "plan": "network",
+    "case": "ping-pong",
+    "builder": "docker:go",
+    "runner": "local:docker",
+    "total_instances": 4
+  },
+  "groups": [
+    {"id": "pingers", "instances": {"percentage": 0.5}, "run": {"test_params": {"latency": "50ms"}}},
+    {"id": "pongers", "instances": {"percentage": 0.5}}
+  ]
+}`
+
+func decodeComposition(t *testing.T, format CompositionFormat, src string) *Composition {
+	tree, err := DecodeCompositionTree(format, []byte(src))
+	require.NoError(t, err)
+	require.Empty(t, LintCompositionTree(tree))
+
+	var buf bytes.Buffer
+	require.NoError(t, EncodeCompositionTree(FormatTOML, tree, &buf))
+
+	var c Composition
+	_, err = toml.Decode(buf.String(), &c)
+	require.NoError(t, err)
+	return &c
+}
+
+func TestCompositionFormats(t *testing.T) {
+	fromYAML := decodeComposition(t, FormatYAML, formatYAML)
+	fromJSON := decodeComposition(t, FormatJSON, formatJSON)
+	require.Equal(t, fromYAML, fromJSON)
+
+	require.EqualValues(t, 4, fromYAML.Global.TotalInstances)
+	require.Equal(t, []string{"pingers", "pongers"}, fromYAML.ListGroupsIds())
+	require.Equal(t, 0.5, fromYAML.Groups[1].Instances.Percentage)
+	require.Equal(t, "50ms", fromYAML.Groups[0].Run.TestParams["latency"])
+
+	// Round trip through every format.
+	tree, err := DecodeCompositionTree(FormatYAML, []byte(formatYAML))
+	require.NoError(t, err)
+	for _, f := range []CompositionFormat{FormatTOML, FormatJSON, FormatYAML} {
+		var buf bytes.Buffer
+		require.NoError(t, EncodeCompositionTree(f, tree, &buf))
+		require.Equal(t, fromYAML, decodeComposition(t, f, buf.String()), f)
+	}
+}
+
+func TestCompositionFormatOf(t *testing.T) {
+	require.Equal(t, FormatYAML, CompositionFormatOf("a/b.yml"))
+	require.Equal(t, FormatYAML, CompositionFormatOf("b.YAML"))
+	require.Equal(t, FormatJSON, CompositionFormatOf("b.json"))
+	require.Equal(t, FormatTOML, CompositionFormatOf("b.toml"))
+	require.Equal(t, FormatTOML, CompositionFormatOf("b"))
+
+	_, err := ParseCompositionFormat("xml")
+	require.Error(t, err)
+}
+
+func TestYAMLKeyLocator(t *testing.T) {
+	l := NewKeyLocatorFor(FormatYAML, []byte(formatYAML))
+
+	line, col, ok := l.Locate("groups.pingers.run.test_params.latency")
+	require.True(t, ok)
+	require.Equal(t, []int{13, 9}, []int{line, col})
+
+	line, col, ok = l.Locate("groups.1.instances.percentage")
+	require.True(t, ok)
+	require.Equal(t, []int{15, 18}, []int{line, col})
+
+	line, col, ok = l.Locate("global.total_instances")
+	require.True(t, ok)
+	require.Equal(t, []int{6, 3}, []int{line, col})
+
+	l = NewKeyLocatorFor(FormatJSON, []byte(formatJSON))
+	line, _, ok = l.Locate("groups.pongers.instances")
+	require.True(t, ok)
+	require.Equal(t, 11, line)
+}
@@ -0,0 +1,476 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+)
+
+// LintIssue is a problem found in a composition.
+type LintIssue struct {
+	// File, Line and Column locate the issue, if known.
+	File   string
+	Line   int
+	Column int
+
+	// Key is the path of the offending key, if any, e.g.
+	// groups.peers.instances.count. See KeyLocator.
+	Key string
+
+	Message string
+
+	// Warning is true for issues that don't prevent the composition from
+	// running.
+	Warning bool
+}
+
+func (i *LintIssue) String() string {
+	var b strings.Builder
+	if i.File != "" {
+		b.WriteString(i.File)
+		if i.Line > 0 {
+			fmt.Fprintf(&b, ":%d:%d", i.Line, i.Column)
+		}
+		b.WriteString(": ")
+	}
+	if i.Warning {
+		b.WriteString("warning: ")
+	}
+	if i.Key != "" {
+		b.WriteString(i.Key)
+		b.WriteString(": ")
+	}
+	b.WriteString(i.Message)
+	return b.String()
+}
+
+// LintErrors returns the issues that aren't warnings.
+func LintErrors(issues []*LintIssue) []*LintIssue {
+	var errs []*LintIssue
+	for _, i := range issues {
+		if !i.Warning {
+			errs = append(errs, i)
+		}
+	}
+	return errs
+}
+
+// LintCompositionTree checks the keys of a decoded composition file, or of a
+// fragment of a composition, against the schema of compositions: it reports
+// unknown keys, and values of the wrong type.
+func LintCompositionTree(tree map[string]interface{}) []*LintIssue {
+	var issues []*LintIssue
+	lintValue(tree, reflect.TypeOf(Composition{}), nil, &issues)
+	return issues
+}
+
+func lintValue(v interface{}, t reflect.Type, path []string, issues *[]*LintIssue) {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	mismatch := func(expected string) {
+		*issues = append(*issues, &LintIssue{
+			Key:     strings.Join(path, "."),
+			Message: fmt.Sprintf("expected %s, got %s", expected, tomlTypeName(v)),
+		})
+	}
+
+	switch t.Kind() {
+	case reflect.Interface:
+	case reflect.Struct:
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			mismatch("a table")
+			return
+		}
+		keys := sortedKeys(m)
+		for _, k := range keys {
+			f, ok := tomlField(t, k)
+			if !ok {
+				msg := fmt.Sprintf("unknown key %s", k)
+				if s := suggestKey(t, k); s != "" {
+					msg += fmt.Sprintf("; did you mean %s?", s)
+				}
+				*issues = append(*issues, &LintIssue{Key: strings.Join(append(path, k), "."), Message: msg})
+				continue
+			}
+			lintValue(m[k], f.Type, append(path, k), issues)
+		}
+	case reflect.Map:
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			mismatch("a table")
+			return
+		}
+		for _, k := range sortedKeys(m) {
+			lintValue(m[k], t.Elem(), append(path, k), issues)
+		}
+	case reflect.Slice, reflect.Array:
+		var elems []interface{}
+		switch xs := v.(type) {
+		case []interface{}:
+			elems = xs
+		case []map[string]interface{}:
+			for _, x := range xs {
+				elems = append(elems, x)
+			}
+		default:
+			mismatch("an array")
+			return
+		}
+		for i, e := range elems {
+			seg := strconv.Itoa(i)
+			if m, ok := e.(map[string]interface{}); ok {
+				if id, ok := m["id"].(string); ok {
+					seg = id
+				}
+			}
+			lintValue(e, t.Elem(), append(path, seg), issues)
+		}
+	case reflect.String:
+		if _, ok := v.(string); !ok {
+			mismatch("a string")
+		}
+	case reflect.Bool:
+		if _, ok := v.(bool); !ok {
+			mismatch("a boolean")
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if _, ok := v.(int64); !ok {
+			mismatch("an integer")
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if n, ok := v.(int64); !ok || n < 0 {
+			mismatch("a positive integer")
+		}
+	case reflect.Float32, reflect.Float64:
+		switch v.(type) {
+		case float64, int64:
+		default:
+			mismatch("a number")
+		}
+	}
+}
+
+// tomlField returns the field of a struct a key decodes into. Like the TOML
+// decoder, it matches the names of the fields without a tag case-insensitively.
+func tomlField(t reflect.Type, key string) (reflect.StructField, bool) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" {
+			continue
+		}
+		name := strings.Split(f.Tag.Get("toml"), ",")[0]
+		switch {
+		case name == "-":
+			continue
+		case name == "":
+			if strings.EqualFold(f.Name, key) {
+				return f, true
+			}
+		case name == key:
+			return f, true
+		}
+	}
+	return reflect.StructField{}, false
+}
+
+// suggestKey returns the key of the struct closest to an unknown key, if it's
+// close enough to be a typo.
+func suggestKey(t reflect.Type, key string) string {
+	best, dist := "", 3
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name := strings.Split(f.Tag.Get("toml"), ",")[0]
+		if f.PkgPath != "" || name == "-" || name == "" {
+			continue
+		}
+		if d := editDistance(strings.ToLower(key), name); d < dist {
+			best, dist = name, d
+		}
+	}
+	return best
+}
+
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur := make([]int, len(b)+1)
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min3(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev = cur
+	}
+	return prev[len(b)]
+}
+
+func min3(a, b, c int) int {
+	if b < a {
+		a = b
+	}
+	if c < a {
+		a = c
+	}
+	return a
+}
+
+func tomlTypeName(v interface{}) string {
+	switch v.(type) {
+	case string:
+		return "a string"
+	case bool:
+		return "a boolean"
+	case int64:
+		return "an integer"
+	case float64:
+		return "a float"
+	case time.Time:
+		return "a datetime"
+	case map[string]interface{}:
+		return "a table"
+	case []interface{}, []map[string]interface{}:
+		return "an array"
+	default:
+		return fmt.Sprintf("%T", v)
+	}
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// lintValidator validates compositions like compositionValidator, but names
+// the fields by their TOML key.
+var lintValidator = func() *validator.Validate {
+	v := validator.New()
+	v.RegisterStructValidation(ValidateInstances, &Instances{})
+	v.RegisterTagNameFunc(func(f reflect.StructField) string {
+		return strings.Split(f.Tag.Get("toml"), ",")[0]
+	})
+	return v
+}()
+
+// Lint checks a loaded composition for a run of the plan of the manifest. On
+// top of the checks of ValidateForRun, it checks the test case, the builders
+// and the runner are supported by the plan, the builders are compatible with
+// the runner, and the test parameters against the parameters of the test
+// case.
+//
+// compatible maps the ids of the runners to the ids of the builders they are
+// compatible with; runners that aren't listed aren't checked.
+func (c *Composition) Lint(manifest *TestPlanManifest, compatible map[string][]string) []*LintIssue {
+	var issues []*LintIssue
+	issue := func(key string, warning bool, format string, args ...interface{}) {
+		issues = append(issues, &LintIssue{Key: key, Message: fmt.Sprintf(format, args...), Warning: warning})
+	}
+
+	if err := lintValidator.Struct(c); err != nil {
+		var verrs validator.ValidationErrors
+		if !errors.As(err, &verrs) {
+			issue("", false, "%s", err)
+			return issues
+		}
+		for _, e := range verrs {
+			issue(validationKey(e.Namespace()), false, "failed the %s validation", e.Tag())
+		}
+		return issues
+	}
+
+	if err := c.Groups.Validate(c); err != nil {
+		issue("", false, "%s", err)
+	}
+	if err := c.Runs.Validate(c); err != nil {
+		issue("", false, "%s", err)
+	}
+
+	_, tc, ok := manifest.TestCaseByName(c.Global.Case)
+	if !ok {
+		issue("global.case", false, "test case %s not found in plan %s", c.Global.Case, manifest.Name)
+	}
+
+	if !manifest.HasRunner(c.Global.Runner) {
+		issue("global.runner", false, "plan does not support runner %s; supported: %v", c.Global.Runner, sortedStrings(manifest.SupportedRunners()))
+	}
+
+	for _, g := range c.Groups {
+		key, builder := "groups."+g.ID+".builder", g.Builder
+		if builder == "" {
+			key, builder = "global.builder", c.Global.Builder
+		}
+		if !manifest.HasBuilder(builder) {
+			issue(key, false, "plan does not support builder %s; supported: %v", builder, sortedStrings(manifest.SupportedBuilders()))
+			continue
+		}
+		if builders, ok := compatible[c.Global.Runner]; ok && !stringInSlice(builder, builders) {
+			issue(key, false, "builder %s is not compatible with runner %s; compatible: %v", builder, c.Global.Runner, builders)
+		}
+	}
+
+	if version, err := manifest.RuntimeAPI(); err != nil {
+		issue("", false, "%s", err)
+	} else if err := checkRuntimeFeatures(c, manifest, version); err != nil {
+		issue("", false, "%s", err)
+	}
+
+	if tc == nil {
+		return issues
+	}
+
+	// Check the test parameters where they are set.
+	checkParams := func(prefix string, params map[string]string, inherited map[string]string) {
+		for _, name := range sortedStringKeys(params) {
+			if v, ok := inherited[name]; ok && v == params[name] {
+				// Already checked where it's inherited from, e.g. the
+				// groups of the default run.
+				continue
+			}
+			key := prefix + "." + name
+			p, ok := tc.Parameters[name]
+			if !ok {
+				issue(key, true, "unknown test parameter %s of test case %s", name, tc.Name)
+				continue
+			}
+			if err := checkParamType(p.Type, params[name]); err != nil {
+				issue(key, false, "invalid value %q of %s parameter: %s", params[name], p.Type, err)
+			}
+		}
+	}
+	if c.Global.Run != nil {
+		checkParams("global.run.test_params", c.Global.Run.TestParams, nil)
+	}
+	for _, g := range c.Groups {
+		checkParams("groups."+g.ID+".run.test_params", g.Run.TestParams, nil)
+	}
+	for _, r := range c.Runs {
+		checkParams("runs."+r.ID+".test_params", r.TestParams, nil)
+
+		for _, g := range r.Groups {
+			key := "runs." + r.ID + ".groups." + g.ID
+			var inherited map[string]string
+			if grp, err := c.GetGroup(g.EffectiveGroupId()); err == nil {
+				inherited = grp.Run.TestParams
+			}
+			checkParams(key+".test_params", g.TestParams, inherited)
+
+			// Report the parameters without a default that aren't set.
+			set := func(name string) bool {
+				if _, ok := g.TestParams[name]; ok {
+					return true
+				}
+				if _, ok := r.TestParams[name]; ok {
+					return true
+				}
+				if grp, err := c.GetGroup(g.EffectiveGroupId()); err == nil {
+					if _, ok := grp.Run.TestParams[name]; ok {
+						return true
+					}
+				}
+				if c.Global.Run != nil {
+					_, ok := c.Global.Run.TestParams[name]
+					return ok
+				}
+				return false
+			}
+			for _, name := range sortedParamNames(tc.Parameters) {
+				if tc.Parameters[name].Default == nil && !set(name) {
+					issue(key, false, "test parameter %s is required by test case %s", name, tc.Name)
+				}
+			}
+		}
+
+		// Validate recalculated the instance counts.
+		if t := int(r.TotalInstances); t > 0 && (t < tc.Instances.Minimum || t > tc.Instances.Maximum) {
+			issue("runs."+r.ID, false, "total instance count (%d) outside of allowable range [%d, %d] for test case %s", t, tc.Instances.Minimum, tc.Instances.Maximum, tc.Name)
+		}
+	}
+
+	return issues
+}
+
+// checkParamType checks a test parameter value can be parsed as the type the
+// manifest declares. Types the SDK parses as strings aren't checked.
+func checkParamType(typ, v string) error {
+	var err error
+	switch strings.ToLower(typ) {
+	case "int", "integer":
+		_, err = strconv.ParseInt(v, 10, 64)
+	case "float", "number":
+		_, err = strconv.ParseFloat(v, 64)
+	case "bool", "boolean":
+		_, err = strconv.ParseBool(v)
+	case "duration":
+		_, err = time.ParseDuration(v)
+	case "json":
+		if !json.Valid([]byte(v)) {
+			err = errors.New("not valid JSON")
+		}
+	}
+	var nerr *strconv.NumError
+	if errors.As(err, &nerr) {
+		err = nerr.Err
+	}
+	return err
+}
+
+// validationKey converts a validator namespace, e.g.
+// Composition.groups[0].instances, into a key.
+func validationKey(ns string) string {
+	if i := strings.Index(ns, "."); i >= 0 {
+		ns = ns[i+1:]
+	}
+	ns = strings.ReplaceAll(ns, "[", ".")
+	return strings.ReplaceAll(ns, "]", "")
+}
+
+func stringInSlice(s string, xs []string) bool {
+	for _, x := range xs {
+		if x == s {
+			return true
+		}
+	}
+	return false
+}
+
+func sortedStrings(xs []string) []string {
+	sort.Strings(xs)
+	return xs
+}
+
+func sortedStringKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func sortedParamNames(m map[string]Parameter) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
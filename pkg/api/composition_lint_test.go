@@ -0,0 +1,143 @@
+package api
+
+import (
+	"testing"
+
+	"github.com/BurntSushi/toml"
+	"github.com/stretchr/testify/require"
+
+	"github.com/testground/testground/pkg/config"
+)
+
+const lintComposition = `[global]
+plan = "network"
+case = "ping-pong"
+builder = "docker:go"
+runner = "local:exec"
+total_instances = "2"
+
+[[groups]]
+id = "pingers"
+instances = { count = 1, percentag = 0.5 }
+
+  [groups.run]
+  test_params = { latency = "fast", jitter = "5" }
+
+[[groups]]
+id = "pongers"
+instances = { count = 1 }
+
+[[runs]]
+id = "baseline"
+test_params = { timeout = "10" }
+
+  [[runs.groups]]
+  id = "pingers"
+  instances = { count = 1 }
+  test_params = { latency = "50" }
+
+  [[runs.groups]]
+  id = "pongers"
+  instances = { count = 1 }
+`
+
+var lintManifest = &TestPlanManifest{
+	Name:     "network",
+	Builders: map[string]config.ConfigMap{"docker:go": nil, "exec:go": nil},
+	Runners:  map[string]config.ConfigMap{"local:docker": nil, "local:exec": nil},
+	TestCases: []*TestCase{{
+		Name:      "ping-pong",
+		Instances: InstanceConstraints{Minimum: 1, Maximum: 10},
+		Parameters: map[string]Parameter{
+			"latency": {Type: "int", Default: 10},
+			"jitter":  {Type: "int", Default: 0},
+			"peers":   {Type: "int"},
+		},
+	}},
+}
+
+func TestLintCompositionTree(t *testing.T) {
+	var tree map[string]interface{}
+	_, err := toml.Decode(lintComposition, &tree)
+	require.NoError(t, err)
+
+	issues := LintCompositionTree(tree)
+	require.Len(t, issues, 2)
+
+	require.Equal(t, "global.total_instances", issues[0].Key)
+	require.Equal(t, "expected a positive integer, got a string", issues[0].Message)
+	require.Equal(t, "groups.pingers.instances.percentag", issues[1].Key)
+	require.Equal(t, "unknown key percentag; did you mean percentage?", issues[1].Message)
+
+	l := NewKeyLocator([]byte(lintComposition))
+	line, col, ok := l.Locate(issues[0].Key)
+	require.True(t, ok)
+	require.Equal(t, []int{6, 1}, []int{line, col})
+	line, col, ok = l.Locate(issues[1].Key)
+	require.True(t, ok)
+	require.Equal(t, []int{10, 26}, []int{line, col})
+}
+
+func TestKeyLocator(t *testing.T) {
+	l := NewKeyLocator([]byte(lintComposition))
+
+	for key, pos := range map[string][]int{
+		"groups.pongers":                             {15, 3},
+		"groups.1.instances.count":                   {17, 15},
+		"groups.pingers.run.test_params.jitter":      {13, 37},
+		"runs.baseline.groups.pingers.test_params":   {26, 3},
+		"runs.0.groups.1.instances":                  {30, 3},
+		"runs.baseline.groups.pongers.test_params.x": {28, 5},
+	} {
+		line, col, ok := l.Locate(key)
+		require.True(t, ok, key)
+		require.Equal(t, pos, []int{line, col}, key)
+	}
+
+	_, _, ok := l.Locate("metadata.name")
+	require.False(t, ok)
+}
+
+func TestCompositionLint(t *testing.T) {
+	var c Composition
+	_, err := toml.Decode(lintComposition, &c)
+	require.Error(t, err)
+
+	c = Composition{
+		Global: Global{Plan: "network", Case: "ping-pong", Builder: "docker:go", Runner: "local:exec"},
+		Groups: Groups{
+			{ID: "pingers", Run: RunParams{TestParams: map[string]string{"latency": "fast", "jitter": "5"}}},
+			{ID: "pongers", Builder: "docker:rust"},
+		},
+		Runs: Runs{{
+			ID:         "baseline",
+			TestParams: map[string]string{"timeout": "10"},
+			Groups: CompositionRunGroups{
+				{ID: "pingers", Instances: Instances{Count: 1}, TestParams: map[string]string{"peers": "2", "latency": "fast"}},
+				{ID: "pongers", Instances: Instances{Count: 20}},
+			},
+		}},
+	}
+
+	issues := c.Lint(lintManifest, map[string][]string{"local:exec": {"exec:go"}})
+
+	var got []string
+	for _, i := range issues {
+		got = append(got, i.String())
+	}
+	require.Equal(t, []string{
+		"global.builder: builder docker:go is not compatible with runner local:exec; compatible: [exec:go]",
+		"groups.pongers.builder: plan does not support builder docker:rust; supported: [docker:go exec:go]",
+		"groups.pingers.run.test_params.latency: invalid value \"fast\" of int parameter: invalid syntax",
+		"warning: runs.baseline.test_params.timeout: unknown test parameter timeout of test case ping-pong",
+		"runs.baseline.groups.pongers: test parameter peers is required by test case ping-pong",
+		"runs.baseline: total instance count (21) outside of allowable range [1, 10] for test case ping-pong",
+	}, got)
+	require.Len(t, LintErrors(issues), 5)
+
+	c.Global.Case = ""
+	issues = c.Lint(lintManifest, nil)
+	require.Len(t, issues, 1)
+	require.Equal(t, "global.case", issues[0].Key)
+	require.Equal(t, "failed the required validation", issues[0].Message)
+}
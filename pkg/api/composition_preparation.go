@@ -165,6 +165,16 @@ func (c Composition) PrepareForRun(manifest *TestPlanManifest) (*Composition, er
 	}
 	c.Runs = newRuns
 
+	// Is the runtime API the plan targets supported, and does it have the
+	// features the composition uses?
+	version, err := manifest.RuntimeAPI()
+	if err != nil {
+		return nil, err
+	}
+	if err := checkRuntimeFeatures(&c, manifest, version); err != nil {
+		return nil, err
+	}
+
 	return &c, nil
 }
 
@@ -0,0 +1,78 @@
+package api
+
+import "fmt"
+
+// repeatedGroupId is the id of the i-th copy (1-based) of a repeated group.
+func repeatedGroupId(id string, i uint) string {
+	return fmt.Sprintf("%s-%d", id, i)
+}
+
+// ExpandGroups replaces every group that declares a repeat count with that
+// many copies of it, named after the group suffixed with their index, e.g.
+// peers-1 to peers-40. The run groups that refer to a repeated group are
+// expanded likewise, each copy referring to a copy of the group.
+//
+// This method doesn't modify the composition, it returns a new one.
+func (c Composition) ExpandGroups() (*Composition, error) {
+	repeats := make(map[string]uint)
+	groups := make(Groups, 0, len(c.Groups))
+	for _, g := range c.Groups {
+		if g.Repeat == 0 {
+			groups = append(groups, g)
+			continue
+		}
+
+		repeats[g.ID] = g.Repeat
+		for i := uint(1); i <= g.Repeat; i++ {
+			cp := *g
+			cp.ID = repeatedGroupId(g.ID, i)
+			cp.Repeat = 0
+			cp.Run.TestParams = cloneParams(g.Run.TestParams)
+			cp.Run.Env = cloneParams(g.Run.Env)
+			groups = append(groups, &cp)
+		}
+	}
+
+	if len(repeats) == 0 {
+		return &c, nil
+	}
+
+	seen := make(map[string]struct{}, len(groups))
+	for _, g := range groups {
+		if _, ok := seen[g.ID]; ok {
+			return nil, fmt.Errorf("duplicate group id %s after expanding repeated groups", g.ID)
+		}
+		seen[g.ID] = struct{}{}
+	}
+	c.Groups = groups
+
+	runs := make(Runs, 0, len(c.Runs))
+	for _, r := range c.Runs {
+		run := r.clone()
+		cloned := run.Groups
+		run.Groups = make(CompositionRunGroups, 0, len(cloned))
+		for _, g := range cloned {
+			n, ok := repeats[g.EffectiveGroupId()]
+			if !ok {
+				run.Groups = append(run.Groups, g)
+				continue
+			}
+
+			for i := uint(1); i <= n; i++ {
+				cp := *g
+				cp.ID = repeatedGroupId(g.ID, i)
+				if g.GroupID != "" {
+					cp.GroupID = repeatedGroupId(g.GroupID, i)
+				}
+				cp.TestParams = cloneParams(g.TestParams)
+				cp.Profiles = cloneParams(g.Profiles)
+				cp.Env = cloneParams(g.Env)
+				run.Groups = append(run.Groups, &cp)
+			}
+		}
+		runs = append(runs, run)
+	}
+	c.Runs = runs
+
+	return &c, nil
+}
@@ -0,0 +1,74 @@
+package api
+
+import (
+	"testing"
+
+	"github.com/BurntSushi/toml"
+	"github.com/stretchr/testify/require"
+)
+
+const repeatComposition = `
+[global]
+plan = "network"
+case = "ping-pong"
+builder = "docker:go"
+runner = "local:docker"
+
+[[groups]]
+id = "seed"
+
+[[groups]]
+id = "peers"
+repeat = 3
+
+  [groups.run]
+  test_params = { role = "peer" }
+
+[[runs]]
+id = "baseline"
+
+  [[runs.groups]]
+  id = "seed"
+  instances = { count = 1 }
+
+  [[runs.groups]]
+  id = "peers"
+  instances = { count = 2 }
+
+  [[runs.groups]]
+  id = "lurkers"
+  group_id = "peers"
+  instances = { count = 1 }
+`
+
+func TestExpandGroups(t *testing.T) {
+	var c Composition
+	_, err := toml.Decode(repeatComposition, &c)
+	require.NoError(t, err)
+
+	expanded, err := c.ExpandGroups()
+	require.NoError(t, err)
+	require.NoError(t, expanded.ValidateForRun())
+
+	// the original composition is left untouched.
+	require.Len(t, c.Groups, 2)
+	require.Len(t, c.Runs[0].Groups, 3)
+
+	require.Equal(t, []string{"peers-1", "peers-2", "peers-3", "seed"}, expanded.ListGroupsIds())
+	for _, g := range expanded.Groups[1:] {
+		require.Zero(t, g.Repeat)
+		require.Equal(t, "peer", g.Run.TestParams["role"])
+	}
+
+	groups := expanded.Runs[0].Groups
+	require.Len(t, groups, 7)
+	require.Equal(t, "seed", groups[0].ID)
+	require.Equal(t, "peers-2", groups[2].EffectiveGroupId())
+	require.EqualValues(t, 2, groups[2].Instances.Count)
+	require.Equal(t, "lurkers-3", groups[6].ID)
+	require.Equal(t, "peers-3", groups[6].EffectiveGroupId())
+
+	c.Groups = append(c.Groups, &Group{ID: "peers-2"})
+	_, err = c.ExpandGroups()
+	require.Error(t, err)
+}
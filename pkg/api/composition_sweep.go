@@ -0,0 +1,236 @@
+package api
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+)
+
+// Sweep declares ranges of values for the parameters of a run. A run with a
+// sweep is expanded into one run per combination of values, i.e. the
+// cartesian product of all the ranges.
+type Sweep struct {
+	// TotalInstances sweeps the total number of instances of the run. The run
+	// groups must be sized by percentages.
+	TotalInstances []uint `toml:"total_instances" json:"total_instances"`
+
+	// GroupInstances sweeps the number of instances of run groups, by run group
+	// id.
+	GroupInstances map[string][]uint `toml:"group_instances" json:"group_instances" mapstructure:"group_instances"`
+
+	// TestParams sweeps test parameters, which apply to all run groups.
+	TestParams map[string][]string `toml:"test_params" json:"test_params" mapstructure:"test_params"`
+}
+
+// sweepAxis is a swept parameter and its values, formatted as strings.
+type sweepAxis struct {
+	name   string
+	values []string
+	apply  func(r *Run, i int)
+}
+
+func (s *Sweep) axes(r *Run) ([]sweepAxis, error) {
+	var axes []sweepAxis
+
+	if len(s.TotalInstances) > 0 {
+		values := s.TotalInstances
+		axes = append(axes, sweepAxis{
+			name:   "total_instances",
+			values: formatUints(values),
+			apply:  func(r *Run, i int) { r.TotalInstances = values[i] },
+		})
+	}
+
+	groups := make([]string, 0, len(s.GroupInstances))
+	for id := range s.GroupInstances {
+		groups = append(groups, id)
+	}
+	sort.Strings(groups)
+
+	for _, id := range groups {
+		id, values := id, s.GroupInstances[id]
+		if !r.hasGroup(id) {
+			return nil, fmt.Errorf("sweep references non-existent run group %s", id)
+		}
+		axes = append(axes, sweepAxis{
+			name:   "instances:" + id,
+			values: formatUints(values),
+			apply: func(r *Run, i int) {
+				for _, g := range r.Groups {
+					if g.ID == id {
+						g.Instances = Instances{Count: values[i]}
+					}
+				}
+			},
+		})
+	}
+
+	params := make([]string, 0, len(s.TestParams))
+	for name := range s.TestParams {
+		params = append(params, name)
+	}
+	sort.Strings(params)
+
+	for _, name := range params {
+		name, values := name, s.TestParams[name]
+		axes = append(axes, sweepAxis{
+			name:   name,
+			values: values,
+			apply: func(r *Run, i int) {
+				// Set on the groups, as group parameters take precedence
+				// over run parameters.
+				for _, g := range r.Groups {
+					if g.TestParams == nil {
+						g.TestParams = make(map[string]string)
+					}
+					g.TestParams[name] = values[i]
+				}
+			},
+		})
+	}
+
+	for _, a := range axes {
+		if len(a.values) == 0 {
+			return nil, fmt.Errorf("sweep of %s has no values", a.name)
+		}
+	}
+
+	return axes, nil
+}
+
+// expand returns one run per combination of the swept values. The runs are
+// named after the swept run, suffixed with their index.
+func (r *Run) expand() (Runs, error) {
+	axes, err := r.Sweep.axes(r)
+	if err != nil {
+		return nil, fmt.Errorf("invalid sweep in run %s: %w", r.ID, err)
+	}
+
+	// Iterate over the cartesian product like an odometer, the last axis
+	// changing fastest.
+	var (
+		runs Runs
+		idx  = make([]int, len(axes))
+	)
+	for {
+		run := r.clone()
+		run.ID = fmt.Sprintf("%s-%d", r.ID, len(runs)+1)
+		run.Sweep = nil
+		run.SweptFrom = r.ID
+		run.SweptParams = make(map[string]string, len(axes))
+		for a, i := range idx {
+			axes[a].apply(run, i)
+			run.SweptParams[axes[a].name] = axes[a].values[i]
+		}
+		runs = append(runs, run)
+
+		a := len(axes) - 1
+		for ; a >= 0; a-- {
+			if idx[a]++; idx[a] < len(axes[a].values) {
+				break
+			}
+			idx[a] = 0
+		}
+		if a < 0 {
+			return runs, nil
+		}
+	}
+}
+
+// ExpandSweeps replaces every run that declares a sweep with the runs of its
+// expansion.
+//
+// This method doesn't modify the composition, it returns a new one.
+func (c Composition) ExpandSweeps() (*Composition, error) {
+	runs := make(Runs, 0, len(c.Runs))
+	for _, r := range c.Runs {
+		if r.Sweep == nil {
+			runs = append(runs, r)
+			continue
+		}
+
+		expanded, err := r.expand()
+		if err != nil {
+			return nil, err
+		}
+		runs = append(runs, expanded...)
+	}
+
+	c.Runs = runs
+
+	// Runs that depend on a swept run depend on all the runs of the sweep.
+	for i, r := range c.Runs {
+		if len(r.DependsOn) > 0 {
+			run := *r
+			run.DependsOn = c.ResolveRunIds(r.DependsOn)
+			c.Runs[i] = &run
+		}
+	}
+
+	return &c, nil
+}
+
+// ResolveRunIds resolves run ids that refer to swept runs into the ids of the
+// runs of their expansion. Other ids are returned unchanged.
+func (c Composition) ResolveRunIds(runIds []string) []string {
+	resolved := make([]string, 0, len(runIds))
+	for _, id := range runIds {
+		var expanded bool
+		for _, r := range c.Runs {
+			if r.SweptFrom == id {
+				resolved = append(resolved, r.ID)
+				expanded = true
+			}
+		}
+		if !expanded {
+			resolved = append(resolved, id)
+		}
+	}
+	return resolved
+}
+
+func (r *Run) hasGroup(id string) bool {
+	for _, g := range r.Groups {
+		if g.ID == id {
+			return true
+		}
+	}
+	return false
+}
+
+// clone returns a copy of the run that shares no groups or parameters with
+// the original.
+func (r Run) clone() *Run {
+	r.TestParams = cloneParams(r.TestParams)
+
+	groups := make(CompositionRunGroups, 0, len(r.Groups))
+	for _, g := range r.Groups {
+		g := *g
+		g.TestParams = cloneParams(g.TestParams)
+		g.Profiles = cloneParams(g.Profiles)
+		g.Env = cloneParams(g.Env)
+		groups = append(groups, &g)
+	}
+	r.Groups = groups
+
+	return &r
+}
+
+func cloneParams(m map[string]string) map[string]string {
+	if m == nil {
+		return nil
+	}
+	c := make(map[string]string, len(m))
+	for k, v := range m {
+		c[k] = v
+	}
+	return c
+}
+
+func formatUints(values []uint) []string {
+	s := make([]string, 0, len(values))
+	for _, v := range values {
+		s = append(s, strconv.FormatUint(uint64(v), 10))
+	}
+	return s
+}
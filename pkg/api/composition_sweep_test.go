@@ -0,0 +1,92 @@
+package api
+
+import (
+	"testing"
+
+	"github.com/BurntSushi/toml"
+	"github.com/stretchr/testify/require"
+)
+
+const sweepComposition = `
+[global]
+plan = "network"
+case = "ping-pong"
+builder = "docker:go"
+runner = "local:docker"
+
+[[groups]]
+id = "nodes"
+
+[[runs]]
+id = "baseline"
+
+  [[runs.groups]]
+  id = "nodes"
+  instances = { count = 2 }
+
+[[runs]]
+id = "latency"
+
+  [runs.sweep]
+  group_instances = { nodes = [10, 50] }
+
+  [runs.sweep.test_params]
+  latency = ["50ms", "100ms", "200ms"]
+
+  [[runs.groups]]
+  id = "nodes"
+  instances = { count = 2 }
+  test_params = { latency = "0ms", jitter = "5" }
+`
+
+func TestExpandSweeps(t *testing.T) {
+	var c Composition
+	_, err := toml.Decode(sweepComposition, &c)
+	require.NoError(t, err)
+
+	expanded, err := c.ExpandSweeps()
+	require.NoError(t, err)
+	require.Len(t, expanded.Runs, 7)
+	require.NoError(t, expanded.ValidateForRun())
+
+	// the original composition is left untouched.
+	require.Len(t, c.Runs, 2)
+	require.NotNil(t, c.Runs[1].Sweep)
+	require.EqualValues(t, 2, c.Runs[1].Groups[0].Instances.Count)
+
+	require.Equal(t, "baseline", expanded.Runs[0].ID)
+	require.Empty(t, expanded.Runs[0].SweptFrom)
+
+	first, last := expanded.Runs[1], expanded.Runs[6]
+	require.Equal(t, "latency-1", first.ID)
+	require.Equal(t, "latency", first.SweptFrom)
+	require.Nil(t, first.Sweep)
+	require.Equal(t, map[string]string{"instances:nodes": "10", "latency": "50ms"}, first.SweptParams)
+	require.EqualValues(t, 10, first.Groups[0].Instances.Count)
+	require.Equal(t, map[string]string{"latency": "50ms", "jitter": "5"}, first.Groups[0].TestParams)
+
+	require.Equal(t, "latency-6", last.ID)
+	require.Equal(t, map[string]string{"instances:nodes": "50", "latency": "200ms"}, last.SweptParams)
+	require.EqualValues(t, 50, last.Groups[0].Instances.Count)
+	require.Equal(t, "200ms", last.Groups[0].TestParams["latency"])
+
+	require.Equal(t,
+		[]string{"baseline", "latency-1", "latency-2", "latency-3", "latency-4", "latency-5", "latency-6"},
+		expanded.ResolveRunIds([]string{"baseline", "latency"}))
+}
+
+func TestExpandSweepsInvalid(t *testing.T) {
+	c := Composition{
+		Runs: Runs{{
+			ID:     "sweep",
+			Groups: CompositionRunGroups{{ID: "a"}},
+			Sweep:  &Sweep{GroupInstances: map[string][]uint{"b": {1, 2}}},
+		}},
+	}
+	_, err := c.ExpandSweeps()
+	require.Error(t, err)
+
+	c.Runs[0].Sweep = &Sweep{TestParams: map[string][]string{"x": {}}}
+	_, err = c.ExpandSweeps()
+	require.Error(t, err)
+}
@@ -28,6 +28,25 @@ func TestValidateGroupsUnique(t *testing.T) {
 	require.Error(t, c.ValidateForRun())
 }
 
+func TestValidateIPFamily(t *testing.T) {
+	c := &Composition{
+		Global: Global{
+			Plan:    "foo_plan",
+			Case:    "foo_case",
+			Builder: "docker:go",
+			Runner:  "local:docker",
+			Network: &Network{IPFamily: DualStack},
+		},
+		Groups: []*Group{
+			{ID: "a"},
+		},
+	}
+	require.NoError(t, c.ValidateForBuild())
+
+	c.Global.Network.IPFamily = "ipv5"
+	require.Error(t, c.ValidateForBuild())
+}
+
 func TestValidateGroupBuildKey(t *testing.T) {
 	c := &Composition{
 		Metadata: Metadata{},
@@ -503,12 +522,12 @@ func TestGetRun(t *testing.T) {
 		},
 	}
 
-	run, err := c.getRun("a")
+	run, err := c.GetRun("a")
 
 	require.NoError(t, err)
 	require.EqualValues(t, "a", run.ID)
 
-	run, err = c.getRun("d")
+	run, err = c.GetRun("d")
 
 	require.Error(t, err)
 	require.Nil(t, run)
@@ -29,6 +29,31 @@ func (gs Groups) Validate(c *Composition) error {
 		}
 	}
 
+	// Validate the volumes
+	for _, g := range gs {
+		if g.Run.Volume != nil {
+			if err := g.Run.Volume.Validate(); err != nil {
+				return fmt.Errorf("group %s: %w", g.ID, err)
+			}
+		}
+	}
+
+	// Validate the environments
+	for _, g := range gs {
+		if err := validateEnv(g.Run.Env); err != nil {
+			return fmt.Errorf("group %s: %w", g.ID, err)
+		}
+	}
+
+	// Validate the published ports
+	for _, g := range gs {
+		for _, p := range g.Run.Publish {
+			if err := p.Validate(); err != nil {
+				return fmt.Errorf("group %s: %w", g.ID, err)
+			}
+		}
+	}
+
 	return nil
 }
 
@@ -60,6 +85,74 @@ func (rs Runs) Validate(c *Composition) error {
 			}
 			m[x.ID] = true
 		}
+
+		// Validate the volumes of the run groups
+		for _, x := range r.Groups {
+			if x.Volume != nil {
+				if err := x.Volume.Validate(); err != nil {
+					return fmt.Errorf("run %s:%s: %w", r.ID, x.ID, err)
+				}
+			}
+		}
+
+		// Validate the environments of the run groups
+		for _, x := range r.Groups {
+			if err := validateEnv(x.Env); err != nil {
+				return fmt.Errorf("run %s:%s: %w", r.ID, x.ID, err)
+			}
+		}
+
+		// Validate the published ports of the run groups
+		for _, x := range r.Groups {
+			for _, p := range x.Publish {
+				if err := p.Validate(); err != nil {
+					return fmt.Errorf("run %s:%s: %w", r.ID, x.ID, err)
+				}
+			}
+		}
+
+		// Validate the dependencies exist
+		for _, dep := range r.DependsOn {
+			if _, err := c.GetRun(dep); err != nil {
+				return fmt.Errorf("run %s depends on non-existent run %s", r.ID, dep)
+			}
+		}
+
+		// Validate the retry policy
+		if r.Retry != nil {
+			if err := r.Retry.Validate(); err != nil {
+				return fmt.Errorf("run %s: %w", r.ID, err)
+			}
+		}
+
+		// Validate the readiness gate
+		if r.Readiness != nil {
+			if err := r.Readiness.Validate(); err != nil {
+				return fmt.Errorf("run %s: %w", r.ID, err)
+			}
+		}
+
+		// Validate the assertions
+		for _, a := range r.Assertions {
+			if err := a.Validate(); err != nil {
+				return fmt.Errorf("run %s: %w", r.ID, err)
+			}
+		}
+
+		// Validate the chaos rules target groups of the run
+		for _, rule := range r.Chaos {
+			if err := rule.Validate(); err != nil {
+				return fmt.Errorf("run %s: %w", r.ID, err)
+			}
+			if _, ok := m[rule.Group]; !ok {
+				return fmt.Errorf("run %s: chaos rule targets non-existent group %s", r.ID, rule.Group)
+			}
+		}
+	}
+
+	// Validate the dependencies are acyclic
+	if _, err := c.PipelineOrder(c.ListRunIds()); err != nil {
+		return err
 	}
 
 	// Recalculate instance counts
@@ -86,6 +179,12 @@ func (c *Composition) ValidateForBuild() error {
 		return err
 	}
 
+	if c.Global.Timeouts != nil {
+		if err := c.Global.Timeouts.Validate(); err != nil {
+			return err
+		}
+	}
+
 	return c.Groups.Validate(c)
 }
 
@@ -96,6 +195,25 @@ func (c *Composition) ValidateForRun() error {
 		return err
 	}
 
+	// Validate timeouts.
+	if c.Global.Timeouts != nil {
+		if err := c.Global.Timeouts.Validate(); err != nil {
+			return err
+		}
+	}
+
+	// Validate fixtures.
+	if err := c.Global.Fixtures.Validate(); err != nil {
+		return err
+	}
+
+	// Validate diagnostics.
+	if c.Global.Diagnostics != nil {
+		if err := c.Global.Diagnostics.Validate(); err != nil {
+			return err
+		}
+	}
+
 	// Validate groups.
 	if err := c.Groups.Validate(c); err != nil {
 		return err
@@ -109,6 +227,19 @@ func (c *Composition) ValidateForRun() error {
 	return nil
 }
 
+// ValidateForRunner validates that the runner of this Composition supports
+// the features its runs use.
+func (c *Composition) ValidateForRunner(run Runner) error {
+	_, injects := run.(ChaosInjector)
+	_, lists := run.(InstanceRegistry)
+	for _, r := range c.Runs {
+		if len(r.Chaos) > 0 && !(injects && lists) {
+			return fmt.Errorf("run %s: runner %s does not support chaos rules", r.ID, run.ID())
+		}
+	}
+	return nil
+}
+
 // ValidateInstances validates that either count or percentage is provided, but
 // not both.
 func ValidateInstances(sl validator.StructLevel) {
@@ -0,0 +1,109 @@
+package api
+
+import (
+	"fmt"
+	"path"
+)
+
+const (
+	// DefaultDiagnosticsLogLines is the number of last lines of logs of the
+	// instances captured by default.
+	DefaultDiagnosticsLogLines = 1000
+
+	// DefaultPprofPort is the port the instances serve net/http/pprof on, as
+	// the sdk-go does.
+	DefaultPprofPort = 6060
+)
+
+// DefaultDiagnosticsProfiles are the profiles captured by default.
+var DefaultDiagnosticsProfiles = []string{"goroutine", "heap"}
+
+// diagnosticsProfiles are the profiles net/http/pprof serves that can be
+// captured.
+var diagnosticsProfiles = map[string]bool{
+	"allocs":       true,
+	"block":        true,
+	"goroutine":    true,
+	"heap":         true,
+	"mutex":        true,
+	"threadcreate": true,
+}
+
+// Diagnostics configures what the runners capture from the instances of a
+// run when one of them crashes or the run fails: the pprof profiles of the
+// instances still running, and the last lines of the logs of all of them.
+// They're written to the diagnostics directory of the outputs of every
+// instance. Diagnostics are captured only if the composition has a
+// diagnostics section.
+type Diagnostics struct {
+	// Profiles are the pprof profiles captured, e.g. goroutine, heap or
+	// allocs (default: goroutine and heap). Goroutine profiles are captured
+	// as text dumps of the stacks of all goroutines.
+	Profiles []string `toml:"profiles" json:"profiles,omitempty"`
+
+	// LogLines is the number of last lines of logs captured (default: 1000).
+	LogLines int `toml:"log_lines" json:"log_lines,omitempty" mapstructure:"log_lines"`
+
+	// PprofPort is the port the instances serve net/http/pprof on (default:
+	// 6060).
+	PprofPort int `toml:"pprof_port" json:"pprof_port,omitempty" mapstructure:"pprof_port"`
+}
+
+// Validate checks the profiles are known, and the numbers in range.
+func (d *Diagnostics) Validate() error {
+	for _, p := range d.Profiles {
+		if !diagnosticsProfiles[p] {
+			return fmt.Errorf("diagnostics: unknown profile %q", p)
+		}
+	}
+	if d.LogLines < 0 {
+		return fmt.Errorf("diagnostics: invalid number of log lines %d", d.LogLines)
+	}
+	if d.PprofPort < 0 || d.PprofPort > 65535 {
+		return fmt.Errorf("diagnostics: invalid pprof port %d", d.PprofPort)
+	}
+	return nil
+}
+
+// ProfileNames returns the profiles to capture.
+func (d *Diagnostics) ProfileNames() []string {
+	if len(d.Profiles) == 0 {
+		return DefaultDiagnosticsProfiles
+	}
+	return d.Profiles
+}
+
+// LogTail returns the number of last lines of logs to capture.
+func (d *Diagnostics) LogTail() int {
+	if d.LogLines == 0 {
+		return DefaultDiagnosticsLogLines
+	}
+	return d.LogLines
+}
+
+// Port returns the port the instances serve net/http/pprof on.
+func (d *Diagnostics) Port() int {
+	if d.PprofPort == 0 {
+		return DefaultPprofPort
+	}
+	return d.PprofPort
+}
+
+// ProfileURLPath returns the path and query net/http/pprof serves a profile
+// at.
+func ProfileURLPath(profile string) string {
+	p := path.Join("/debug/pprof", profile)
+	if profile == "goroutine" {
+		p += "?debug=2"
+	}
+	return p
+}
+
+// ProfileFile returns the name of the file a profile is captured to, in the
+// diagnostics directory of an instance.
+func ProfileFile(profile string) string {
+	if profile == "goroutine" {
+		return "goroutine.txt"
+	}
+	return profile + ".pprof"
+}
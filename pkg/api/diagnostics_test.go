@@ -0,0 +1,36 @@
+package api
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiagnostics(t *testing.T) {
+	d := &Diagnostics{}
+	require.NoError(t, d.Validate())
+	require.Equal(t, DefaultDiagnosticsProfiles, d.ProfileNames())
+	require.Equal(t, DefaultDiagnosticsLogLines, d.LogTail())
+	require.Equal(t, DefaultPprofPort, d.Port())
+
+	d = &Diagnostics{Profiles: []string{"allocs", "mutex"}, LogLines: 50, PprofPort: 7070}
+	require.NoError(t, d.Validate())
+	require.Equal(t, []string{"allocs", "mutex"}, d.ProfileNames())
+	require.Equal(t, 50, d.LogTail())
+	require.Equal(t, 7070, d.Port())
+
+	for _, d := range []Diagnostics{
+		{Profiles: []string{"cpu"}},
+		{LogLines: -1},
+		{PprofPort: 70000},
+	} {
+		require.Error(t, d.Validate(), "%+v", d)
+	}
+}
+
+func TestProfileFiles(t *testing.T) {
+	require.Equal(t, "/debug/pprof/goroutine?debug=2", ProfileURLPath("goroutine"))
+	require.Equal(t, "goroutine.txt", ProfileFile("goroutine"))
+	require.Equal(t, "/debug/pprof/heap", ProfileURLPath("heap"))
+	require.Equal(t, "heap.pprof", ProfileFile("heap"))
+}
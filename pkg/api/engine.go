@@ -60,8 +60,20 @@ type Engine interface {
 
 	DoBuildPurge(ctx context.Context, builder, plan string, ow *rpc.OutputWriter) error
 	DoCollectOutputs(ctx context.Context, runID string, ow *rpc.OutputWriter) error
+	DoCollectBuild(ctx context.Context, id string, ow *rpc.OutputWriter) error
 	DoTerminate(ctx context.Context, ctype ComponentType, ref string, ow *rpc.OutputWriter) error
 	DoHealthcheck(ctx context.Context, runner string, fix bool, ow *rpc.OutputWriter) (*HealthcheckReport, error)
+	DoListInstances(ctx context.Context, runID string) ([]*Instance, error)
+	DoPushParam(ctx context.Context, req *ParamPushRequest, ow *rpc.OutputWriter) (*ParamPushOutput, error)
+	DoInjectNetworkFault(ctx context.Context, req *NetworkFaultRequest, ow *rpc.OutputWriter) (*NetworkFaultOutput, error)
+	DoScale(ctx context.Context, req *ScaleRequest, ow *rpc.OutputWriter) (*ScaleOutput, error)
+	DoExec(ctx context.Context, req *ExecRequest, stdin io.Reader, stdout, stderr io.Writer) (*ExecOutput, error)
+	DoGC(ctx context.Context, dryRun bool, ow *rpc.OutputWriter) (*GCReport, error)
+	DoExplain(ctx context.Context, id string) (*Explanation, error)
+	DoDrain(ctx context.Context, ow *rpc.OutputWriter) error
+	Resume()
+	Reload(cfg *config.EnvConfig) (*ReloadOutput, error)
+	SubscribeEvents(filter EventFilter) (<-chan *Event, func())
 
 	EnvConfig() config.EnvConfig
 	Context() context.Context
@@ -71,6 +83,7 @@ type TasksManager interface {
 	Tasks(filters TasksFilters) ([]task.Task, error)
 	GetTask(id string) (*task.Task, error)
 	Kill(taskId string) error
+	Cancel(taskId string, by string) error
 	DeleteTask(taskId string) error
 	Logs(ctx context.Context, taskId string, follow bool, cancel bool, w io.Writer) (*task.Task, error)
 }
@@ -0,0 +1,164 @@
+package api
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ReservedEnvPrefix is the prefix of the variables of the runtime API, which
+// can't be set in the environment of groups.
+const ReservedEnvPrefix = "TEST_"
+
+var envKeyRe = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// EnvVar is a variable injected into the runtime environment of the groups of
+// a run without editing its composition, e.g. with the --env flag of
+// testground run.
+type EnvVar struct {
+	// Groups are the ids of the groups the variable is injected into; it's
+	// injected into all groups if empty.
+	Groups []string `json:"groups,omitempty"`
+	Key    string   `json:"key"`
+	Value  string   `json:"value"`
+}
+
+// ParseEnvVar parses a variable as [<group id>,...:]KEY=VALUE, e.g.
+// "FEATURE_X=on", or "miners,relays:FEATURE_X=on" to inject it into the
+// miners and relays groups only.
+func ParseEnvVar(s string) (EnvVar, error) {
+	var v EnvVar
+	eq := strings.IndexByte(s, '=')
+	if eq < 0 {
+		return v, fmt.Errorf("invalid env var %q; expected [<group id>,...:]KEY=VALUE", s)
+	}
+	key, value := s[:eq], s[eq+1:]
+	if i := strings.LastIndexByte(key, ':'); i >= 0 {
+		for _, g := range strings.Split(key[:i], ",") {
+			if g = strings.TrimSpace(g); g != "" {
+				v.Groups = append(v.Groups, g)
+			}
+		}
+		if len(v.Groups) == 0 {
+			return v, fmt.Errorf("invalid env var %q; no group ids before ':'", s)
+		}
+		key = key[i+1:]
+	}
+	v.Key, v.Value = key, value
+	return v, validateEnvKey(key)
+}
+
+// ParseEnvFile parses the variables of an env file: a KEY=VALUE per line,
+// optionally prefixed by export, and with the value optionally quoted. Empty
+// lines and lines starting with # are ignored. The variables are injected
+// into all groups.
+func ParseEnvFile(r io.Reader) ([]EnvVar, error) {
+	var (
+		vars []EnvVar
+		n    int
+	)
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		n++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimSpace(strings.TrimPrefix(line, "export "))
+
+		eq := strings.IndexByte(line, '=')
+		if eq < 0 {
+			return nil, fmt.Errorf("line %d: expected KEY=VALUE", n)
+		}
+		key, value := strings.TrimSpace(line[:eq]), strings.TrimSpace(line[eq+1:])
+		if err := validateEnvKey(key); err != nil {
+			return nil, fmt.Errorf("line %d: %w", n, err)
+		}
+		if len(value) >= 2 && value[0] == '"' && value[len(value)-1] == '"' {
+			unquoted, err := strconv.Unquote(value)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: invalid quoted value: %w", n, err)
+			}
+			value = unquoted
+		} else if len(value) >= 2 && value[0] == '\'' && value[len(value)-1] == '\'' {
+			value = value[1 : len(value)-1]
+		}
+		vars = append(vars, EnvVar{Key: key, Value: value})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return vars, nil
+}
+
+func validateEnvKey(key string) error {
+	if !envKeyRe.MatchString(key) {
+		return fmt.Errorf("invalid env var name %q", key)
+	}
+	if strings.HasPrefix(key, ReservedEnvPrefix) {
+		return fmt.Errorf("env var %s: the %s prefix is reserved for the runtime API", key, ReservedEnvPrefix)
+	}
+	return nil
+}
+
+// validateEnv checks the names of the variables of the environment of a
+// group.
+func validateEnv(env map[string]string) error {
+	keys := make([]string, 0, len(env))
+	for k := range env {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		if err := validateEnvKey(k); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// InjectEnv sets the variables in the environment of the groups of the
+// composition they're injected into, and of the groups of its runs, over
+// the values the composition sets. Later variables override earlier ones.
+func (c *Composition) InjectEnv(vars []EnvVar) error {
+	for _, v := range vars {
+		if err := validateEnvKey(v.Key); err != nil {
+			return err
+		}
+		for _, id := range v.Groups {
+			if _, err := c.GetGroup(id); err != nil {
+				return fmt.Errorf("env var %s: %w", v.Key, err)
+			}
+		}
+	}
+
+	into := func(groups []string, id string) bool {
+		return len(groups) == 0 || stringInSlice(id, groups)
+	}
+	set := func(env *map[string]string, v EnvVar) {
+		if *env == nil {
+			*env = make(map[string]string)
+		}
+		(*env)[v.Key] = v.Value
+	}
+
+	for _, v := range vars {
+		for _, g := range c.Groups {
+			if into(v.Groups, g.ID) {
+				set(&g.Run.Env, v)
+			}
+		}
+		for _, r := range c.Runs {
+			for _, g := range r.Groups {
+				if into(v.Groups, g.EffectiveGroupId()) {
+					set(&g.Env, v)
+				}
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,65 @@
+package api
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseEnvVar(t *testing.T) {
+	v, err := ParseEnvVar("FEATURE_X=a=b")
+	require.NoError(t, err)
+	require.Equal(t, EnvVar{Key: "FEATURE_X", Value: "a=b"}, v)
+
+	v, err = ParseEnvVar("miners,relays:FEATURE_X=")
+	require.NoError(t, err)
+	require.Equal(t, EnvVar{Groups: []string{"miners", "relays"}, Key: "FEATURE_X"}, v)
+
+	for _, s := range []string{"FEATURE_X", "=on", ":FEATURE_X=on", "FEATURE-X=on", "TEST_PLAN=foo"} {
+		_, err := ParseEnvVar(s)
+		require.Error(t, err, s)
+	}
+}
+
+func TestParseEnvFile(t *testing.T) {
+	vars, err := ParseEnvFile(strings.NewReader(`
+# flags
+FEATURE_X=on
+export LOG_FORMAT = "json\tpretty"
+PEERS='a b'
+`))
+	require.NoError(t, err)
+	require.Equal(t, []EnvVar{
+		{Key: "FEATURE_X", Value: "on"},
+		{Key: "LOG_FORMAT", Value: "json\tpretty"},
+		{Key: "PEERS", Value: "a b"},
+	}, vars)
+
+	_, err = ParseEnvFile(strings.NewReader("FEATURE_X=on\nTEST_RUN=foo\n"))
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "line 2")
+}
+
+func TestInjectEnv(t *testing.T) {
+	c := &Composition{
+		Groups: []*Group{
+			{ID: "miners", Run: RunParams{Env: map[string]string{"FEATURE_X": "off", "PEERS": "8"}}},
+			{ID: "relays"},
+		},
+		Runs: []*Run{{ID: "r", Groups: CompositionRunGroups{
+			{ID: "m", GroupID: "miners", Env: map[string]string{"FEATURE_X": "off"}},
+		}}},
+	}
+
+	require.NoError(t, c.InjectEnv([]EnvVar{
+		{Key: "FEATURE_X", Value: "on"},
+		{Groups: []string{"relays"}, Key: "PEERS", Value: "2"},
+	}))
+	require.Equal(t, map[string]string{"FEATURE_X": "on", "PEERS": "8"}, c.Groups[0].Run.Env)
+	require.Equal(t, map[string]string{"FEATURE_X": "on", "PEERS": "2"}, c.Groups[1].Run.Env)
+	require.Equal(t, map[string]string{"FEATURE_X": "on"}, c.Runs[0].Groups[0].Env)
+
+	require.Error(t, c.InjectEnv([]EnvVar{{Groups: []string{"validators"}, Key: "FEATURE_X"}}))
+	require.Error(t, c.InjectEnv([]EnvVar{{Key: "TEST_SIDECAR", Value: "false"}}))
+}
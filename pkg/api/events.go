@@ -0,0 +1,130 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/testground/testground/pkg/task"
+)
+
+// EventType is the type of a lifecycle event of a task.
+type EventType string
+
+const (
+	// EventTaskQueued is published when a task is queued, including the runs
+	// of pipelines, and the retries of runs.
+	EventTaskQueued EventType = "task_queued"
+	// EventBuildStarted and EventBuildFinished are published for every build
+	// of the groups of a task; finished builds carry their artifact, or the
+	// error of the build.
+	EventBuildStarted  EventType = "build_started"
+	EventBuildFinished EventType = "build_finished"
+	// EventInstanceStarted, EventInstanceHealthy and EventInstanceCrashed are
+	// published as the instances of a run start, pass their readiness probe,
+	// and exit with a non-zero code. local:docker publishes them all;
+	// local:exec only the starts of instances.
+	EventInstanceStarted EventType = "instance_started"
+	EventInstanceHealthy EventType = "instance_healthy"
+	EventInstanceCrashed EventType = "instance_crashed"
+	// EventTaskCompleted is published when a task completes, with its
+	// outcome: for runs, the outcome of the run.
+	EventTaskCompleted EventType = "task_completed"
+)
+
+var eventTypes = map[EventType]bool{
+	EventTaskQueued:      true,
+	EventBuildStarted:    true,
+	EventBuildFinished:   true,
+	EventInstanceStarted: true,
+	EventInstanceHealthy: true,
+	EventInstanceCrashed: true,
+	EventTaskCompleted:   true,
+}
+
+// Event is a lifecycle event of a task, as streamed by GET /events.
+type Event struct {
+	Type     EventType `json:"type"`
+	Time     time.Time `json:"time"`
+	TaskID   string    `json:"task_id"`
+	TaskType task.Type `json:"task_type"`
+	Plan     string    `json:"plan,omitempty"`
+	Case     string    `json:"case,omitempty"`
+	// Groups are the groups a build is for.
+	Groups []string `json:"groups,omitempty"`
+	// Builder and Artifact are the builder and the artifact of a build.
+	Builder  string `json:"builder,omitempty"`
+	Artifact string `json:"artifact,omitempty"`
+	// Group and Instance are the group and the name of an instance.
+	Group    string `json:"group,omitempty"`
+	Instance string `json:"instance,omitempty"`
+	// ExitCode is the exit code of a crashed instance.
+	ExitCode int `json:"exit_code,omitempty"`
+	// Outcome is the outcome of a completed task.
+	Outcome task.Outcome `json:"outcome,omitempty"`
+	// Error is the error of a failed build, or of a completed task.
+	Error string `json:"error,omitempty"`
+}
+
+// EventFilter selects the events streamed to a subscriber. Empty fields
+// select all events.
+type EventFilter struct {
+	TaskID string
+	Plan   string
+	Types  []EventType
+}
+
+// ParseEventTypes parses a comma-separated list of event types.
+func ParseEventTypes(s string) ([]EventType, error) {
+	var types []EventType
+	for _, t := range strings.Split(s, ",") {
+		if t = strings.TrimSpace(t); t == "" {
+			continue
+		}
+		if !eventTypes[EventType(t)] {
+			return nil, fmt.Errorf("unknown event type %q", t)
+		}
+		types = append(types, EventType(t))
+	}
+	return types, nil
+}
+
+// Match returns whether the filter selects the event.
+func (f EventFilter) Match(ev *Event) bool {
+	if f.TaskID != "" && f.TaskID != ev.TaskID {
+		return false
+	}
+	if f.Plan != "" && f.Plan != ev.Plan {
+		return false
+	}
+	if len(f.Types) == 0 {
+		return true
+	}
+	for _, t := range f.Types {
+		if t == ev.Type {
+			return true
+		}
+	}
+	return false
+}
+
+// EventSink publishes the events of a task, stamped with the task.
+type EventSink func(ev Event)
+
+type eventSinkKey struct{}
+
+// WithEventSink returns a context that publishes the events of builders and
+// runners to the sink.
+func WithEventSink(ctx context.Context, sink EventSink) context.Context {
+	return context.WithValue(ctx, eventSinkKey{}, sink)
+}
+
+// EmitEvent publishes an event to the sink of the context, if any.
+func EmitEvent(ctx context.Context, ev Event) {
+	sink, ok := ctx.Value(eventSinkKey{}).(EventSink)
+	if !ok {
+		return
+	}
+	sink(ev)
+}
@@ -0,0 +1,34 @@
+package api
+
+import (
+	"io"
+	"time"
+)
+
+// DefaultPauseOnFailure is how long failed runs keep their instances alive for
+// inspection by default, when asked to pause on failure.
+const DefaultPauseOnFailure = 30 * time.Minute
+
+type ExecInput struct {
+	RunID string
+	// Group is the id of the run group of the instance.
+	Group string
+	// Instance is the index of the instance in its group.
+	Instance int
+	// Cmd is the command to run in the instance.
+	Cmd []string
+	// Tty allocates a terminal to the command; its output is then all written
+	// to Stdout.
+	Tty bool
+
+	Stdin  io.Reader
+	Stdout io.Writer
+	Stderr io.Writer
+}
+
+type ExecOutput struct {
+	// Instance is the name of the instance the command ran in.
+	Instance string `json:"instance"`
+	// ExitCode is the exit code of the command.
+	ExitCode int `json:"exit_code"`
+}
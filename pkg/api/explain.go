@@ -0,0 +1,87 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/testground/testground/pkg/task"
+)
+
+// Stages of the decisions recorded while processing a task.
+const (
+	DecisionStageQueue     = "queue"
+	DecisionStageBuild     = "build"
+	DecisionStageLimits    = "limits"
+	DecisionStagePlacement = "placement"
+)
+
+// DecisionLog collects the decisions taken while processing a task. It is
+// safe for concurrent use, e.g. by parallel build jobs.
+type DecisionLog struct {
+	lk        sync.Mutex
+	decisions []task.Decision
+}
+
+// Decisions returns a copy of the decisions recorded so far.
+func (l *DecisionLog) Decisions() []task.Decision {
+	l.lk.Lock()
+	defer l.lk.Unlock()
+	return append([]task.Decision(nil), l.decisions...)
+}
+
+// Record records a decision into the log.
+func (l *DecisionLog) Record(stage string, format string, args ...interface{}) {
+	l.lk.Lock()
+	defer l.lk.Unlock()
+	l.decisions = append(l.decisions, task.Decision{
+		Time:    time.Now().UTC(),
+		Stage:   stage,
+		Message: fmt.Sprintf(format, args...),
+	})
+}
+
+type decisionLogKey struct{}
+
+// WithDecisionLog returns a context that records the decisions of builders
+// and runners into the log.
+func WithDecisionLog(ctx context.Context, l *DecisionLog) context.Context {
+	return context.WithValue(ctx, decisionLogKey{}, l)
+}
+
+// RecordDecision records a decision into the log of the context, if any, so
+// that `testground task explain` can report it.
+func RecordDecision(ctx context.Context, stage string, format string, args ...interface{}) {
+	l, ok := ctx.Value(decisionLogKey{}).(*DecisionLog)
+	if !ok {
+		return
+	}
+	l.Record(stage, format, args...)
+}
+
+// QueueExplanation explains why a task is still queued.
+type QueueExplanation struct {
+	// Position is the 1-based position of the task in the queue.
+	Position int `json:"position"`
+	Length   int `json:"length"`
+	// Ahead are the tasks that will be picked before this one, in order.
+	Ahead   []string `json:"ahead"`
+	Workers int      `json:"workers"`
+	// Busy are the tasks the workers are processing.
+	Busy []string `json:"busy"`
+	// Runner and RunnerLimit are set for runs whose runner has a concurrency
+	// limit, along with the number of runs it's processing.
+	Runner        string `json:"runner,omitempty"`
+	RunnerRunning int    `json:"runner_running,omitempty"`
+	RunnerLimit   int    `json:"runner_limit,omitempty"`
+}
+
+// Explanation explains the scheduling and placement of a task.
+type Explanation struct {
+	TaskID string     `json:"task_id"`
+	State  task.State `json:"state"`
+	// Queue is set while the task is queued.
+	Queue     *QueueExplanation `json:"queue,omitempty"`
+	Decisions []task.Decision   `json:"decisions"`
+}
@@ -0,0 +1,133 @@
+package api
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// DefaultFixtureSourcePath is the directory of an OCI image holding a
+// fixture, when the fixture doesn't specify one.
+const DefaultFixtureSourcePath = "/fixture"
+
+var (
+	fixtureNameRe   = regexp.MustCompile(`^[a-z][a-z0-9_]*$`)
+	fixtureSHA256Re = regexp.MustCompile(`^[0-9a-f]{64}$`)
+)
+
+// Fixture is a data set that the runner fetches once, caches, and mounts
+// read-only into every instance of the runs of a composition.
+type Fixture struct {
+	// Name identifies the fixture; instances find it at the path in the
+	// TEST_FIXTURE_<NAME> environment variable.
+	Name string `toml:"name" json:"name"`
+
+	// Source is where the fixture is fetched from: a local directory (an
+	// absolute path or a file:// URL), a tarball URL (http:// or https://,
+	// optionally gzipped), or an OCI image (oci://<image reference>).
+	Source string `toml:"source" json:"source"`
+
+	// SourcePath is the directory of the OCI image holding the fixture, for
+	// oci:// sources (default: /fixture).
+	SourcePath string `toml:"source_path" json:"source_path,omitempty" mapstructure:"source_path"`
+
+	// SHA256 is the hex-encoded digest of the tarball, for tarball sources.
+	// Tarballs not matching it are rejected, and never cached.
+	SHA256 string `toml:"sha256" json:"sha256,omitempty"`
+
+	// Path is the path the fixture is mounted at in the instances (default:
+	// /fixtures/<name>).
+	Path string `toml:"path" json:"path,omitempty"`
+}
+
+// Validate checks the name, the source, and the path of the fixture.
+func (f *Fixture) Validate() error {
+	if !fixtureNameRe.MatchString(f.Name) {
+		return fmt.Errorf("fixture: invalid name %q; lowercase letters, digits and underscores are allowed", f.Name)
+	}
+
+	switch {
+	case path.IsAbs(f.Source), strings.HasPrefix(f.Source, "file:///"):
+	case f.IsTarball():
+	case f.IsImage() && len(f.Source) > len("oci://"):
+	default:
+		return fmt.Errorf("fixture %s: unsupported source %q; expected a local directory, a tarball URL, or oci://<image>", f.Name, f.Source)
+	}
+
+	if f.SHA256 != "" {
+		if !f.IsTarball() {
+			return fmt.Errorf("fixture %s: a sha256 digest is only supported for tarball sources", f.Name)
+		}
+		if !fixtureSHA256Re.MatchString(f.SHA256) {
+			return fmt.Errorf("fixture %s: invalid sha256 digest %q", f.Name, f.SHA256)
+		}
+	}
+	if f.SourcePath != "" && !f.IsImage() {
+		return fmt.Errorf("fixture %s: a source path is only supported for oci:// sources", f.Name)
+	}
+	if f.SourcePath != "" && (!path.IsAbs(f.SourcePath) || path.Clean(f.SourcePath) == "/") {
+		return fmt.Errorf("fixture %s: invalid source path %q; an absolute path other than / is required", f.Name, f.SourcePath)
+	}
+	if f.Path != "" && (!path.IsAbs(f.Path) || path.Clean(f.Path) == "/") {
+		return fmt.Errorf("fixture %s: invalid path %q; an absolute path other than / is required", f.Name, f.Path)
+	}
+	return nil
+}
+
+// IsTarball returns whether the fixture is fetched from a tarball URL.
+func (f *Fixture) IsTarball() bool {
+	return strings.HasPrefix(f.Source, "http://") || strings.HasPrefix(f.Source, "https://")
+}
+
+// IsImage returns whether the fixture is fetched from an OCI image.
+func (f *Fixture) IsImage() bool {
+	return strings.HasPrefix(f.Source, "oci://")
+}
+
+// LocalDir returns the directory a fixture with a local source points to.
+func (f *Fixture) LocalDir() (string, bool) {
+	if strings.HasPrefix(f.Source, "file://") {
+		return strings.TrimPrefix(f.Source, "file://"), true
+	}
+	return f.Source, path.IsAbs(f.Source)
+}
+
+// MountPath returns the path the fixture is mounted at in the instances.
+func (f *Fixture) MountPath() string {
+	if f.Path == "" {
+		return path.Join("/fixtures", f.Name)
+	}
+	return path.Clean(f.Path)
+}
+
+// ImageSourcePath returns the directory of the OCI image holding the fixture.
+func (f *Fixture) ImageSourcePath() string {
+	if f.SourcePath == "" {
+		return DefaultFixtureSourcePath
+	}
+	return path.Clean(f.SourcePath)
+}
+
+// Fixtures are the fixtures of a composition.
+type Fixtures []*Fixture
+
+// Validate checks every fixture, and that their names and paths are unique.
+func (fs Fixtures) Validate() error {
+	names := make(map[string]bool, len(fs))
+	paths := make(map[string]bool, len(fs))
+	for _, f := range fs {
+		if err := f.Validate(); err != nil {
+			return err
+		}
+		if names[f.Name] {
+			return fmt.Errorf("fixture names not unique; found duplicate: %s", f.Name)
+		}
+		if paths[f.MountPath()] {
+			return fmt.Errorf("fixture paths not unique; found duplicate: %s", f.MountPath())
+		}
+		names[f.Name] = true
+		paths[f.MountPath()] = true
+	}
+	return nil
+}
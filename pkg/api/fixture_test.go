@@ -0,0 +1,69 @@
+package api
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFixtureValidate(t *testing.T) {
+	digest := strings.Repeat("ab", 32)
+	for _, f := range []Fixture{
+		{Name: "chain", Source: "/data/chain"},
+		{Name: "chain", Source: "file:///data/chain", Path: "/var/chain"},
+		{Name: "chain_v2", Source: "https://example.com/chain.tar.gz", SHA256: digest},
+		{Name: "chain", Source: "oci://ghcr.io/org/chain:v1", SourcePath: "/data"},
+	} {
+		require.NoError(t, f.Validate(), "%+v", f)
+	}
+
+	for _, f := range []Fixture{
+		{Name: "Chain", Source: "/data/chain"},
+		{Name: "chain-v2", Source: "/data/chain"},
+		{Name: "chain", Source: "data/chain"},
+		{Name: "chain", Source: "s3://bucket/chain.tar"},
+		{Name: "chain", Source: "oci://"},
+		{Name: "chain", Source: "/data/chain", Path: "/"},
+		{Name: "chain", Source: "/data/chain", Path: "chain"},
+		{Name: "chain", Source: "/data/chain", SHA256: digest},
+		{Name: "chain", Source: "https://example.com/chain.tar", SHA256: "abc"},
+		{Name: "chain", Source: "https://example.com/chain.tar", SourcePath: "/data"},
+		{Name: "chain", Source: "oci://ghcr.io/org/chain:v1", SourcePath: "/"},
+	} {
+		require.Error(t, f.Validate(), "%+v", f)
+	}
+}
+
+func TestFixturesValidate(t *testing.T) {
+	require.NoError(t, Fixtures{
+		{Name: "a", Source: "/data/a"},
+		{Name: "b", Source: "/data/b"},
+	}.Validate())
+
+	require.Error(t, Fixtures{
+		{Name: "a", Source: "/data/a"},
+		{Name: "a", Source: "/data/b"},
+	}.Validate())
+
+	// Both end up mounted at /fixtures/a.
+	require.Error(t, Fixtures{
+		{Name: "a", Source: "/data/a"},
+		{Name: "b", Source: "/data/b", Path: "/fixtures/a/"},
+	}.Validate())
+}
+
+func TestFixturePaths(t *testing.T) {
+	f := &Fixture{Name: "chain", Source: "oci://chain"}
+	require.Equal(t, "/fixtures/chain", f.MountPath())
+	require.Equal(t, DefaultFixtureSourcePath, f.ImageSourcePath())
+
+	f = &Fixture{Name: "chain", Source: "file:///data/chain", Path: "/var/chain/"}
+	require.Equal(t, "/var/chain", f.MountPath())
+	dir, ok := f.LocalDir()
+	require.True(t, ok)
+	require.Equal(t, "/data/chain", dir)
+
+	_, ok = (&Fixture{Source: "https://example.com/chain.tar"}).LocalDir()
+	require.False(t, ok)
+}
@@ -0,0 +1,37 @@
+package api
+
+import "time"
+
+const (
+	// GCKindOutputs are the outputs of a run kept by a local runner.
+	GCKindOutputs = "outputs"
+	// GCKindSources are the plan and sdk sources unpacked from a request.
+	GCKindSources = "sources"
+	// GCKindArtifact are the artifacts of a build, e.g. docker images.
+	GCKindArtifact = "artifact"
+)
+
+// GCItem is something the garbage collector removed, or would remove in a
+// dry run.
+type GCItem struct {
+	Kind string `json:"kind"`
+	// Builder is set for artifacts.
+	Builder string `json:"builder,omitempty"`
+	Plan    string `json:"plan,omitempty"`
+	// ID is the run ID for outputs, the request ID for sources and the
+	// artifact ID for artifacts.
+	ID      string    `json:"id"`
+	Path    string    `json:"path,omitempty"`
+	Created time.Time `json:"created"`
+	Size    int64     `json:"size"`
+	// Reason is the retention policy that selected the item.
+	Reason string `json:"reason"`
+}
+
+// GCReport is the outcome of a garbage collection.
+type GCReport struct {
+	DryRun bool      `json:"dry_run"`
+	Items  []*GCItem `json:"items"`
+	// Freed is the total size of the removed items, in bytes.
+	Freed int64 `json:"freed"`
+}
@@ -24,6 +24,10 @@ type TestPlanManifest struct {
 	//
 	// It's a mapping of builder => directories.
 	ExtraSources map[string][]string `toml:"extra_sources"`
+
+	// Runtime is the runtime API, and the SDK, the plan targets; plans that
+	// don't declare it run with the latest runtime API.
+	Runtime *RuntimeTarget `toml:"runtime"`
 }
 
 // TestCase represents a configuration for a test case known by the system.
@@ -32,6 +36,8 @@ type TestCase struct {
 	Instances InstanceConstraints
 	// Parameters that can be passed to this test case.
 	Parameters map[string]Parameter `toml:"params"`
+	// Readiness checks whether the instances of this test case are ready.
+	Readiness *ReadinessProbe `toml:"readiness"`
 }
 
 // Parameter is metadata about a test case parameter.
@@ -0,0 +1,72 @@
+package api
+
+import (
+	"time"
+
+	"github.com/testground/sdk-go/network"
+)
+
+// NetworkFault is a change of the data network of the instances of a live
+// run, which the sidecar applies on top of the network configuration of the
+// test plan. Faults are published on the network-faults topic of the run:
+// by the runner on behalf of operators, or by the test plan itself.
+type NetworkFault struct {
+	// ID identifies the fault in the network events.
+	ID string `json:"id"`
+	// Group is the group the fault is addressed to; instances of other
+	// groups ignore it. The fault is addressed to all instances if empty.
+	Group string `json:"group,omitempty"`
+	// Shape replaces the shape of the data link set by the test plan.
+	Shape *network.LinkShape `json:"shape,omitempty"`
+	// Partition splits the instances of these groups from the rest of the
+	// run: the traffic between both sides is dropped.
+	Partition []string `json:"partition,omitempty"`
+	// Blackhole drops the traffic to these addresses or subnets.
+	Blackhole []string `json:"blackhole,omitempty"`
+	// Heal lifts the faults applied earlier, before applying this one: the
+	// shape of the test plan is restored, and partitions and blackholes are
+	// lifted.
+	Heal bool      `json:"heal,omitempty"`
+	Time time.Time `json:"time"`
+}
+
+// NetworkEvent is a change of the data network of an instance, applied by
+// the sidecar. Events make up the network timeline of a run.
+type NetworkEvent struct {
+	Time time.Time `json:"time"`
+	// Instance identifies the instance, as <group id>/<hostname>.
+	Instance string `json:"instance"`
+	// Source is NetworkEventPlan for changes requested by the test plan
+	// through the network client of the sdk, and NetworkEventFault for
+	// faults.
+	Source  string `json:"source"`
+	FaultID string `json:"fault_id,omitempty"`
+	// Enabled, Shape and Blocked are the resulting configuration of the
+	// data link: its shape, and the subnets it drops the traffic to.
+	Enabled bool              `json:"enabled"`
+	Shape   network.LinkShape `json:"shape"`
+	Blocked []string          `json:"blocked,omitempty"`
+	// Error is set if the sidecar could not apply the change.
+	Error string `json:"error,omitempty"`
+}
+
+const (
+	NetworkEventPlan  = "plan"
+	NetworkEventFault = "fault"
+)
+
+type NetworkFaultInput struct {
+	RunID    string
+	TestPlan string
+	TestCase string
+	Fault    *NetworkFault
+	// Timeout is how long to wait for the instances to apply the fault.
+	Timeout time.Duration
+}
+
+type NetworkFaultOutput struct {
+	Fault *NetworkFault `json:"fault"`
+	// Expected is the number of instances the fault was addressed to.
+	Expected int             `json:"expected"`
+	Events   []*NetworkEvent `json:"events"`
+}
@@ -0,0 +1,29 @@
+package api
+
+// OutputsSchemaFile is the name of the file, at the root of the outputs of a
+// run, that stamps the outputs with the version of their schema.
+const OutputsSchemaFile = "schema.json"
+
+// OutputsSchemaVersion is the version of the schema of the outputs of the
+// runs produced by this version of testground. It is bumped whenever the
+// layout or the format of the outputs changes, and every bump comes with a
+// migration from the previous version.
+//
+// Outputs that aren't stamped predate schema versioning and have version 0;
+// their layout is that of version 1.
+const OutputsSchemaVersion = 1
+
+// OutputsSchema describes the outputs of a run. As of version 1, the outputs
+// hold a directory per group, holding a directory per instance, named after
+// its sequence number in the group, that holds the outputs of the instance:
+// run.out (logs and events), results.out (metrics), diagnostics.out
+// (diagnostic metrics) and any file written by the test plan.
+type OutputsSchema struct {
+	Version int    `json:"version"`
+	RunID   string `json:"run_id"`
+	Plan    string `json:"plan,omitempty"`
+	Case    string `json:"case,omitempty"`
+	Runner  string `json:"runner,omitempty"`
+	// Testground is the version of testground that produced the outputs.
+	Testground string `json:"testground,omitempty"`
+}
@@ -0,0 +1,45 @@
+package api
+
+import "time"
+
+// ParamUpdate is an update of a runtime parameter, pushed to the instances of
+// a live run. Instances that support steering subscribe to the updates and
+// acknowledge every update they apply.
+type ParamUpdate struct {
+	// ID identifies the update in the acknowledgments.
+	ID string `json:"id"`
+	// Group is the group the update is addressed to; instances of other
+	// groups must ignore it. The update is addressed to all instances if
+	// empty.
+	Group string    `json:"group,omitempty"`
+	Key   string    `json:"key"`
+	Value string    `json:"value"`
+	Time  time.Time `json:"time"`
+}
+
+// ParamAck is the acknowledgment of a parameter update by an instance.
+type ParamAck struct {
+	UpdateID string `json:"update_id"`
+	// Instance identifies the instance, as <group id>/<hostname>.
+	Instance string `json:"instance"`
+	// Error is set if the instance received the update but could not apply
+	// it.
+	Error string    `json:"error,omitempty"`
+	Time  time.Time `json:"time"`
+}
+
+type ParamPushInput struct {
+	RunID    string
+	TestPlan string
+	TestCase string
+	Update   *ParamUpdate
+	// Timeout is how long to wait for the acknowledgments of the instances.
+	Timeout time.Duration
+}
+
+type ParamPushOutput struct {
+	Update *ParamUpdate `json:"update"`
+	// Expected is the number of instances the update was addressed to.
+	Expected int         `json:"expected"`
+	Acks     []*ParamAck `json:"acks"`
+}
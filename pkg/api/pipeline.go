@@ -0,0 +1,85 @@
+package api
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/testground/testground/pkg/task"
+)
+
+// PipelineOrder returns the given runs along with all the runs they depend on,
+// ordered so that every run comes after its dependencies. Runs otherwise keep
+// their relative order.
+func (c Composition) PipelineOrder(runIds []string) ([]string, error) {
+	var (
+		order   = make([]string, 0, len(runIds))
+		visited = make(map[string]bool, len(runIds))
+		visit   func(id string, path []string) error
+	)
+
+	// visited is false while a run is being visited, and true once it's
+	// ordered.
+	visit = func(id string, path []string) error {
+		done, seen := visited[id]
+		if done {
+			return nil
+		}
+		if seen {
+			return fmt.Errorf("dependency cycle between runs: %v", append(path, id))
+		}
+
+		run, err := c.GetRun(id)
+		if err != nil {
+			return err
+		}
+
+		visited[id] = false
+		for _, dep := range run.DependsOn {
+			if err := visit(dep, append(path, id)); err != nil {
+				return err
+			}
+		}
+		visited[id] = true
+
+		order = append(order, id)
+		return nil
+	}
+
+	for _, id := range runIds {
+		if err := visit(id, nil); err != nil {
+			return nil, err
+		}
+	}
+
+	return order, nil
+}
+
+// PipelineResult is the result of a pipeline task, which runs several runs of
+// a composition, e.g. the runs of a sweep, or runs that depend on other runs.
+type PipelineResult struct {
+	// Outcome is successful if all the runs that weren't shed succeeded.
+	Outcome task.Outcome `json:"outcome"`
+	// Runs are the results of the runs, in the order they were scheduled.
+	Runs []*PipelineRunResult `json:"runs"`
+}
+
+// PipelineRunResult is the result of a run of a pipeline.
+type PipelineRunResult struct {
+	RunID string `json:"run_id"`
+	// TaskID is the id of the task that ran the run, its last attempt if it
+	// was retried; it's empty if the run didn't run.
+	TaskID  string       `json:"task_id,omitempty"`
+	Outcome task.Outcome `json:"outcome"`
+	Error   string       `json:"error,omitempty"`
+	// Shed is true if the run didn't run, to be done by the deadline of the
+	// pipeline.
+	Shed bool `json:"shed,omitempty"`
+	// Took is how long the run took, from being queued to completing.
+	Took time.Duration `json:"took,omitempty"`
+	// SweptFrom and SweptParams tell the sweep the run was expanded from,
+	// if any.
+	SweptFrom   string            `json:"swept_from,omitempty"`
+	SweptParams map[string]string `json:"swept_params,omitempty"`
+	// Result is the result of the task that ran the run.
+	Result interface{} `json:"result,omitempty"`
+}
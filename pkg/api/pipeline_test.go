@@ -0,0 +1,54 @@
+package api
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPipelineOrder(t *testing.T) {
+	c := Composition{
+		Runs: Runs{
+			{ID: "analyse", DependsOn: []string{"replay", "snapshot"}},
+			{ID: "replay", DependsOn: []string{"snapshot"}},
+			{ID: "snapshot"},
+			{ID: "unrelated"},
+		},
+	}
+
+	order, err := c.PipelineOrder([]string{"analyse", "unrelated"})
+	require.NoError(t, err)
+	require.Equal(t, []string{"snapshot", "replay", "analyse", "unrelated"}, order)
+
+	order, err = c.PipelineOrder([]string{"unrelated", "snapshot"})
+	require.NoError(t, err)
+	require.Equal(t, []string{"unrelated", "snapshot"}, order)
+
+	c.Runs[2].DependsOn = []string{"analyse"}
+	_, err = c.PipelineOrder([]string{"analyse"})
+	require.Error(t, err)
+}
+
+func TestValidatePipeline(t *testing.T) {
+	c := &Composition{
+		Global: Global{
+			Plan:    "foo_plan",
+			Case:    "foo_case",
+			Builder: "docker:go",
+			Runner:  "local:docker",
+		},
+		Groups: Groups{{ID: "a"}},
+		Runs: Runs{
+			{ID: "first", Groups: CompositionRunGroups{{ID: "a", Instances: Instances{Count: 1}}}},
+			{ID: "second", DependsOn: []string{"first"}, Groups: CompositionRunGroups{{ID: "a", Instances: Instances{Count: 1}}}},
+		},
+	}
+	require.NoError(t, c.ValidateForRun())
+
+	c.Runs[1].DependsOn = []string{"missing"}
+	require.Error(t, c.ValidateForRun())
+
+	c.Runs[1].DependsOn = []string{"first"}
+	c.Runs[0].DependsOn = []string{"second"}
+	require.Error(t, c.ValidateForRun())
+}
@@ -0,0 +1,109 @@
+package api
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// PublishedPort publishes a port of the instances of a group on the host, so
+// that tools outside of testground (wallets, load generators, browsers) can
+// connect to the instances during a run.
+//
+// Published ports are supported by the local:docker runner.
+type PublishedPort struct {
+	// Port is the port of the instances, e.g. "8545", or "30303/udp"; tcp is
+	// the default protocol.
+	Port string `toml:"port" json:"port"`
+
+	// HostPort is the port of the host the port of the first instance is
+	// published on, or a range of ports, e.g. "30000-30099", the i-th instance
+	// getting the i-th port of the range. Docker picks a free port for every
+	// instance if it's not set.
+	HostPort string `toml:"host_port" json:"host_port,omitempty" mapstructure:"host_port"`
+
+	// HostIP is the address of the host the port is published on (default:
+	// 127.0.0.1, i.e. only reachable from the host).
+	HostIP string `toml:"host_ip" json:"host_ip,omitempty" mapstructure:"host_ip"`
+}
+
+// DefaultPublishHostIP is the address ports are published on by default.
+const DefaultPublishHostIP = "127.0.0.1"
+
+// PortProto returns the port number and the protocol of the published port.
+func (p *PublishedPort) PortProto() (int, string, error) {
+	port, proto := p.Port, "tcp"
+	if i := strings.Index(port, "/"); i >= 0 {
+		port, proto = port[:i], port[i+1:]
+	}
+	if proto != "tcp" && proto != "udp" {
+		return 0, "", fmt.Errorf("port %q: unsupported protocol %q; expected tcp or udp", p.Port, proto)
+	}
+	n, err := parsePort(port)
+	if err != nil {
+		return 0, "", fmt.Errorf("port %q: %w", p.Port, err)
+	}
+	return n, proto, nil
+}
+
+// hostPorts returns the range of host ports of the published port, or zeros
+// if docker picks them.
+func (p *PublishedPort) hostPorts() (int, int, error) {
+	if p.HostPort == "" {
+		return 0, 0, nil
+	}
+	first, last := p.HostPort, p.HostPort
+	if i := strings.Index(p.HostPort, "-"); i >= 0 {
+		first, last = p.HostPort[:i], p.HostPort[i+1:]
+	}
+	lo, err := parsePort(first)
+	if err != nil {
+		return 0, 0, fmt.Errorf("host port %q: %w", p.HostPort, err)
+	}
+	hi, err := parsePort(last)
+	if err != nil {
+		return 0, 0, fmt.Errorf("host port %q: %w", p.HostPort, err)
+	}
+	if hi < lo {
+		return 0, 0, fmt.Errorf("host port %q: empty range", p.HostPort)
+	}
+	return lo, hi, nil
+}
+
+// HostPortOf returns the host port the port of the i-th instance of the group
+// is published on, or an empty string if docker picks it.
+func (p *PublishedPort) HostPortOf(i int) (string, error) {
+	lo, hi, err := p.hostPorts()
+	if err != nil || lo == 0 {
+		return "", err
+	}
+	if lo+i > hi {
+		return "", fmt.Errorf("port %s: no host port left in %s for instance %d", p.Port, p.HostPort, i)
+	}
+	return strconv.Itoa(lo + i), nil
+}
+
+// Validate checks the ports and the host address. A range of host ports too
+// small for the instances of the group is only detected when the instances are
+// created.
+func (p *PublishedPort) Validate() error {
+	if _, _, err := p.PortProto(); err != nil {
+		return fmt.Errorf("publish: %w", err)
+	}
+	if _, _, err := p.hostPorts(); err != nil {
+		return fmt.Errorf("publish: %w", err)
+	}
+	if p.HostIP != "" && net.ParseIP(p.HostIP) == nil {
+		return fmt.Errorf("publish: invalid host ip %q", p.HostIP)
+	}
+	return nil
+}
+
+func parsePort(s string) (int, error) {
+	n, err := strconv.Atoi(s)
+	if err != nil || n < 1 || n > 65535 {
+		return 0, fmt.Errorf("invalid port %q", s)
+	}
+	return n, nil
+}
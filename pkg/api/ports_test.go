@@ -0,0 +1,45 @@
+package api
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPublishedPortValidate(t *testing.T) {
+	for _, p := range []PublishedPort{
+		{Port: "8545"},
+		{Port: "30303/udp", HostPort: "30303"},
+		{Port: "8545", HostPort: "30000-30099", HostIP: "0.0.0.0"},
+	} {
+		require.NoError(t, p.Validate(), "%+v", p)
+	}
+
+	for _, p := range []PublishedPort{
+		{},
+		{Port: "8545/sctp"},
+		{Port: "70000"},
+		{Port: "8545", HostPort: "30099-30000"},
+		{Port: "8545", HostPort: "30000-"},
+		{Port: "8545", HostIP: "localhost"},
+	} {
+		require.Error(t, p.Validate(), "%+v", p)
+	}
+}
+
+func TestPublishedPortHostPortOf(t *testing.T) {
+	p := PublishedPort{Port: "8545", HostPort: "30000-30001"}
+	for i, want := range []string{"30000", "30001"} {
+		got, err := p.HostPortOf(i)
+		require.NoError(t, err)
+		require.Equal(t, want, got)
+	}
+	_, err := p.HostPortOf(2)
+	require.Error(t, err)
+
+	// Docker picks the host ports.
+	p = PublishedPort{Port: "8545"}
+	got, err := p.HostPortOf(5)
+	require.NoError(t, err)
+	require.Empty(t, got)
+}
@@ -0,0 +1,64 @@
+package api
+
+// Provenance records what a run was executed from: the exact plan, builds,
+// configuration and version of testground, so that the run can be replayed
+// with `testground run replay`.
+type Provenance struct {
+	// Version is the git commit of the daemon that executed the run.
+	Version string `json:"version"`
+	// RunID is the id of the run in the composition.
+	RunID string `json:"run_id"`
+	// PlanSource is the remote source of the plan, if it was imported from
+	// one.
+	PlanSource *PlanSource `json:"plan_source,omitempty"`
+	// PlanHash is the hash of the plan sources the run built from, if it
+	// built any.
+	PlanHash string `json:"plan_hash,omitempty"`
+	// Manifest is the manifest of the plan.
+	Manifest TestPlanManifest `json:"manifest"`
+	// Composition is the composition as prepared for the run, with the
+	// artifacts of every group.
+	Composition Composition `json:"composition"`
+	// Runner is the id of the runner.
+	Runner string `json:"runner"`
+	// RunnerConfig is the configuration the runner ran with: its env config
+	// coalesced with the run config of the composition.
+	RunnerConfig map[string]interface{} `json:"runner_config"`
+	// Upstream maps the ids of the runs the run depends on to the ids of the
+	// tasks whose outputs it consumed.
+	Upstream map[string]string `json:"upstream,omitempty"`
+	// Env are the variables injected into the runtime environment of the
+	// groups of the run, e.g. with testground run --env; the composition
+	// has them in the environment of its groups.
+	Env []EnvVar `json:"env,omitempty"`
+	// Groups are the builds of the groups.
+	Groups []*GroupProvenance `json:"groups"`
+}
+
+// GroupProvenance records the build of the artifact of a group.
+type GroupProvenance struct {
+	ID string `json:"id"`
+	// Builder is the id of the builder.
+	Builder string `json:"builder"`
+	// Artifact is the artifact the group ran, e.g. a docker image ID.
+	Artifact string `json:"artifact"`
+	// Built is whether the run built the artifact; if false, the artifact
+	// was provided, and its build isn't known.
+	Built bool `json:"built"`
+	// BuildConfig is the configuration the artifact was built with, if the
+	// run built it.
+	BuildConfig map[string]interface{} `json:"build_config,omitempty"`
+	// Dependencies are the versions of the modules the artifact was built
+	// with, if the builder reports them.
+	Dependencies map[string]string `json:"dependencies,omitempty"`
+}
+
+// Group returns the provenance of a group, or nil if there's none.
+func (p *Provenance) Group(id string) *GroupProvenance {
+	for _, g := range p.Groups {
+		if g.ID == id {
+			return g
+		}
+	}
+	return nil
+}
@@ -0,0 +1,159 @@
+package api
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+// ReadinessProbeType is the way a readiness probe checks instances.
+type ReadinessProbeType string
+
+const (
+	// ProbeHTTP expects a 2xx or 3xx response to a GET request on a port of
+	// the instance.
+	ProbeHTTP ReadinessProbeType = "http"
+	// ProbeTCP expects a port of the instance to accept connections.
+	ProbeTCP ReadinessProbeType = "tcp"
+	// ProbeExec expects a command run in the instance to exit with 0.
+	ProbeExec ReadinessProbeType = "exec"
+	// ProbeSync expects the instances to signal entry to a sync state.
+	ProbeSync ReadinessProbeType = "sync"
+)
+
+// DefaultProbeInterval is the interval between two probes of the instances,
+// when the probe doesn't specify one.
+const DefaultProbeInterval = 2 * time.Second
+
+// ReadinessProbe checks whether the instances of a test case are ready. It is
+// declared by the test case in the plan manifest.
+type ReadinessProbe struct {
+	// Type is the way the probe checks instances: http, tcp, exec or sync.
+	Type ReadinessProbeType `toml:"type" json:"type"`
+
+	// Port is the port http and tcp probes connect to.
+	Port int `toml:"port" json:"port,omitempty"`
+
+	// Path is the path http probes request (default: /).
+	Path string `toml:"path" json:"path,omitempty"`
+
+	// Command is the command exec probes run in the instance.
+	Command []string `toml:"command" json:"command,omitempty"`
+
+	// State is the sync state ready instances signal entry to, with sync
+	// probes.
+	State string `toml:"state" json:"state,omitempty"`
+
+	// Interval is the interval between two probes, in time.Duration string
+	// representation (default: 2s).
+	Interval string `toml:"interval" json:"interval,omitempty"`
+}
+
+// Validate checks the probe has what its type requires.
+func (p *ReadinessProbe) Validate() error {
+	switch p.Type {
+	case ProbeHTTP, ProbeTCP:
+		if p.Port <= 0 || p.Port > 65535 {
+			return fmt.Errorf("%s readiness probe: invalid port %d", p.Type, p.Port)
+		}
+	case ProbeExec:
+		if len(p.Command) == 0 {
+			return fmt.Errorf("exec readiness probe: no command")
+		}
+	case ProbeSync:
+		if p.State == "" {
+			return fmt.Errorf("sync readiness probe: no state")
+		}
+	default:
+		return fmt.Errorf("unknown readiness probe type %q; supported: %s, %s, %s, %s", p.Type, ProbeHTTP, ProbeTCP, ProbeExec, ProbeSync)
+	}
+
+	if p.Interval != "" {
+		if d, err := time.ParseDuration(p.Interval); err != nil || d <= 0 {
+			return fmt.Errorf("%s readiness probe: invalid interval %q", p.Type, p.Interval)
+		}
+	}
+	return nil
+}
+
+// ProbeInterval returns the interval between two probes of a valid probe.
+func (p *ReadinessProbe) ProbeInterval() time.Duration {
+	if p.Interval == "" {
+		return DefaultProbeInterval
+	}
+	d, _ := time.ParseDuration(p.Interval)
+	return d
+}
+
+// ReadinessGate aborts a run if not enough of its instances become ready in
+// time, as checked by the readiness probe of its test case.
+type ReadinessGate struct {
+	// MinReady is the number of instances that must become ready.
+	//
+	// Specifying a number is mutually exclusive with specifying a percentage.
+	MinReady uint `toml:"min_ready" json:"min_ready,omitempty" mapstructure:"min_ready"`
+
+	// MinReadyPercentage is the proportion of the instances of the run that
+	// must become ready, rounded up.
+	//
+	// Specifying a percentage is mutually exclusive with specifying a number.
+	MinReadyPercentage float64 `toml:"min_ready_percentage" json:"min_ready_percentage,omitempty" mapstructure:"min_ready_percentage"`
+
+	// Deadline is the delay, from the start of the run, within which the
+	// instances must become ready, in time.Duration string representation
+	// (e.g. 2m).
+	Deadline string `toml:"deadline" json:"deadline"`
+}
+
+// Validate checks the number of instances and the deadline of the gate.
+func (g *ReadinessGate) Validate() error {
+	if (g.MinReady == 0) == (g.MinReadyPercentage == 0) {
+		return fmt.Errorf("readiness gate: either min_ready or min_ready_percentage is required, not both")
+	}
+	if g.MinReadyPercentage < 0 || g.MinReadyPercentage > 1 {
+		return fmt.Errorf("readiness gate: min_ready_percentage must be between 0 and 1")
+	}
+	if d, err := time.ParseDuration(g.Deadline); err != nil || d <= 0 {
+		return fmt.Errorf("readiness gate: invalid deadline %q", g.Deadline)
+	}
+	return nil
+}
+
+// Target returns the number of instances that must become ready, out of the
+// instances of the run.
+func (g *ReadinessGate) Target(total int) int {
+	n := int(g.MinReady)
+	if g.MinReadyPercentage > 0 {
+		n = int(math.Ceil(g.MinReadyPercentage * float64(total)))
+	}
+	return n
+}
+
+// ReadinessInput is the input of a probe of the instances of a run.
+type ReadinessInput struct {
+	RunID    string
+	TestPlan string
+	TestCase string
+	Probe    *ReadinessProbe
+	// Target is the number of instances the run waits for. Sync probes only
+	// tell whether the target is reached.
+	Target int
+}
+
+// InstanceReadiness is the result of the probe of an instance.
+type InstanceReadiness struct {
+	Name  string `json:"name"`
+	Group string `json:"group"`
+	Ready bool   `json:"ready"`
+	// Error is the reason the instance is not ready, if known.
+	Error string `json:"error,omitempty"`
+}
+
+// ReadinessReport is the result of a probe of the instances of a run.
+type ReadinessReport struct {
+	// Ready is the number of ready instances.
+	Ready int `json:"ready"`
+	// Instances are the results of the probes of the instances, if the probe
+	// checks instances one by one.
+	Instances []*InstanceReadiness `json:"instances,omitempty"`
+}
@@ -0,0 +1,49 @@
+package api
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadinessProbeValidate(t *testing.T) {
+	for _, p := range []ReadinessProbe{
+		{Type: ProbeHTTP, Port: 8080, Path: "/healthz"},
+		{Type: ProbeTCP, Port: 4001, Interval: "500ms"},
+		{Type: ProbeExec, Command: []string{"test", "-f", "/tmp/ready"}},
+		{Type: ProbeSync, State: "ready"},
+	} {
+		require.NoError(t, p.Validate(), "%+v", p)
+	}
+
+	for _, p := range []ReadinessProbe{
+		{Type: "grpc", Port: 8080},
+		{Type: ProbeHTTP},
+		{Type: ProbeTCP, Port: 70000},
+		{Type: ProbeExec},
+		{Type: ProbeSync},
+		{Type: ProbeSync, State: "ready", Interval: "often"},
+	} {
+		require.Error(t, p.Validate(), "%+v", p)
+	}
+
+	require.Equal(t, DefaultProbeInterval, (&ReadinessProbe{}).ProbeInterval())
+	require.Equal(t, 500*time.Millisecond, (&ReadinessProbe{Interval: "500ms"}).ProbeInterval())
+}
+
+func TestReadinessGate(t *testing.T) {
+	require.NoError(t, (&ReadinessGate{MinReady: 10, Deadline: "2m"}).Validate())
+
+	for _, g := range []ReadinessGate{
+		{Deadline: "2m"},
+		{MinReady: 10, MinReadyPercentage: 0.5, Deadline: "2m"},
+		{MinReadyPercentage: 2, Deadline: "2m"},
+		{MinReady: 10},
+	} {
+		require.Error(t, g.Validate(), "%+v", g)
+	}
+
+	require.Equal(t, 10, (&ReadinessGate{MinReady: 10}).Target(100))
+	require.Equal(t, 91, (&ReadinessGate{MinReadyPercentage: 0.901}).Target(100))
+}
@@ -0,0 +1,17 @@
+package api
+
+// ReloadRequest asks the daemon to reload its env config.
+type ReloadRequest struct {
+	// Path is the config file to load, on the host of the daemon, instead of
+	// the .env.toml file of its home directory.
+	Path string `json:"path,omitempty"`
+}
+
+// ReloadOutput is the outcome of a reload of the env config of the daemon.
+type ReloadOutput struct {
+	// Changed are the keys of the settings that changed, and were applied.
+	Changed []string `json:"changed"`
+	// Restart are the keys of the settings that changed, but are only
+	// applied when the daemon restarts; they keep their current values.
+	Restart []string `json:"restart"`
+}
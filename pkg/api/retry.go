@@ -0,0 +1,74 @@
+package api
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/testground/testground/pkg/task"
+)
+
+const (
+	// RetryOnFailure retries runs that completed with a failure outcome.
+	RetryOnFailure = "failure"
+	// RetryOnError retries runs that could not complete due to an
+	// infrastructure error, e.g. a failure to start containers.
+	RetryOnError = "error"
+)
+
+// RetryPolicy configures the attempts the engine makes at a run. Every attempt
+// is a task on its own, with its own outputs; the outcome of the run is the
+// outcome of the last attempt.
+type RetryPolicy struct {
+	// MaxAttempts is the maximum number of attempts at the run, including
+	// the first one (default: 1, i.e. no retries).
+	MaxAttempts int `toml:"max_attempts" json:"max_attempts" mapstructure:"max_attempts"`
+
+	// BackoffSecs is the delay before the first retry, in seconds. It doubles
+	// with every retry.
+	BackoffSecs int `toml:"backoff_secs" json:"backoff_secs" mapstructure:"backoff_secs"`
+
+	// On lists the conditions that trigger a retry: "failure" and/or "error"
+	// (default: ["error"]).
+	On []string `toml:"on" json:"on"`
+}
+
+// Validate checks the retry conditions are known.
+func (p *RetryPolicy) Validate() error {
+	for _, on := range p.On {
+		if on != RetryOnFailure && on != RetryOnError {
+			return fmt.Errorf("unknown retry condition %q; supported: %s, %s", on, RetryOnFailure, RetryOnError)
+		}
+	}
+	return nil
+}
+
+// ShouldRetry returns true if the given attempt, which either errored or
+// completed with the given outcome, should be followed by another attempt.
+func (p *RetryPolicy) ShouldRetry(attempt int, outcome task.Outcome, errored bool) bool {
+	if attempt >= p.MaxAttempts {
+		return false
+	}
+
+	on := p.On
+	if len(on) == 0 {
+		on = []string{RetryOnError}
+	}
+
+	for _, cond := range on {
+		switch {
+		case cond == RetryOnError && errored:
+			return true
+		case cond == RetryOnFailure && !errored && outcome == task.OutcomeFailure:
+			return true
+		}
+	}
+	return false
+}
+
+// Backoff returns the delay before the attempt following the given one.
+func (p *RetryPolicy) Backoff(attempt int) time.Duration {
+	if p.BackoffSecs <= 0 || attempt < 1 {
+		return 0
+	}
+	return time.Duration(p.BackoffSecs) * time.Second << (attempt - 1)
+}
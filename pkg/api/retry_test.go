@@ -0,0 +1,38 @@
+package api
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/testground/testground/pkg/task"
+)
+
+func TestRetryPolicyShouldRetry(t *testing.T) {
+	p := &RetryPolicy{MaxAttempts: 3}
+	require.True(t, p.ShouldRetry(1, task.OutcomeUnknown, true))
+	require.True(t, p.ShouldRetry(2, task.OutcomeUnknown, true))
+	require.False(t, p.ShouldRetry(3, task.OutcomeUnknown, true))
+	require.False(t, p.ShouldRetry(1, task.OutcomeFailure, false))
+
+	p.On = []string{RetryOnFailure}
+	require.True(t, p.ShouldRetry(1, task.OutcomeFailure, false))
+	require.False(t, p.ShouldRetry(1, task.OutcomeSuccess, false))
+	require.False(t, p.ShouldRetry(1, task.OutcomeUnknown, true))
+
+	require.False(t, (&RetryPolicy{}).ShouldRetry(1, task.OutcomeUnknown, true))
+}
+
+func TestRetryPolicyBackoff(t *testing.T) {
+	p := &RetryPolicy{BackoffSecs: 5}
+	require.Equal(t, 5*time.Second, p.Backoff(1))
+	require.Equal(t, 10*time.Second, p.Backoff(2))
+	require.Equal(t, 20*time.Second, p.Backoff(3))
+	require.Zero(t, (&RetryPolicy{}).Backoff(1))
+}
+
+func TestRetryPolicyValidate(t *testing.T) {
+	require.NoError(t, (&RetryPolicy{On: []string{"failure", "error"}}).Validate())
+	require.Error(t, (&RetryPolicy{On: []string{"timeout"}}).Validate())
+}
@@ -2,7 +2,9 @@ package api
 
 import (
 	"bytes"
+	"time"
 
+	"github.com/testground/testground/pkg/auth"
 	"github.com/testground/testground/pkg/task"
 )
 
@@ -21,20 +23,49 @@ type BuildRequest struct {
 	Composition Composition      `json:"composition"`
 	Manifest    TestPlanManifest `json:"manifest"`
 	CreatedBy   CreatedBy        `json:"created_by"`
+	// PlanSource is the remote source the test plan was imported from, if
+	// any. The daemon fetches the plan from it if the request carries no plan
+	// sources.
+	PlanSource *PlanSource `json:"plan_source,omitempty"`
+	// TraceContext carries the trace of the request through the queue.
+	TraceContext map[string]string `json:"trace_context,omitempty"`
 }
 
 // RunRequest is the request struct for the `run` function.
 type RunRequest struct {
 	Priority    int              `json:"priority"`
 	BuildGroups []int            `json:"build_groups"`
-	RunIds	    []string         `json:"run_ids"`
+	RunIds      []string         `json:"run_ids"`
 	Composition Composition      `json:"composition"`
 	Manifest    TestPlanManifest `json:"manifest"`
 	CreatedBy   CreatedBy        `json:"created_by"`
+	// PlanSource is the remote source the test plan was imported from, if
+	// any. The daemon fetches the plan from it if the request carries no plan
+	// sources.
+	PlanSource *PlanSource `json:"plan_source,omitempty"`
+	// TraceContext carries the trace of the request through the queue.
+	TraceContext map[string]string `json:"trace_context,omitempty"`
+	// Upstream maps the ids of the runs this run depends on to the ids of
+	// the tasks that ran them.
+	Upstream map[string]string `json:"upstream,omitempty"`
+	// Pipeline is the id of the pipeline task a run is part of, if any.
+	Pipeline string `json:"pipeline,omitempty"`
+	// Deadline is the time by which the runs of a pipeline must be done; the
+	// runs that would likely complete after it are shed.
+	Deadline *time.Time `json:"deadline,omitempty"`
+	// PauseOnFailureSecs is how long failed runs keep their instances alive
+	// for inspection, in seconds; failed runs are torn down right away if
+	// zero.
+	PauseOnFailureSecs int `json:"pause_on_failure_secs,omitempty"`
+	// Env are the variables injected into the runtime environment of the
+	// groups, over those the composition sets.
+	Env []EnvVar `json:"env,omitempty"`
 }
 
 type CreatedBy task.CreatedBy
 
+type PlanSource task.PlanSource
+
 type OutputsRequest struct {
 	Runner string `json:"runner"`
 	RunID  string `json:"run_id"`
@@ -50,11 +81,73 @@ type HealthcheckRequest struct {
 	Fix    bool   `json:"fix"`
 }
 
+type ParamPushRequest struct {
+	TaskID string `json:"task_id"`
+	// Group restricts the update to the instances of a group; all instances
+	// receive it if empty.
+	Group string `json:"group"`
+	Key   string `json:"key"`
+	Value string `json:"value"`
+	// TimeoutSecs is how long to wait for the instances to acknowledge the
+	// update, in seconds.
+	TimeoutSecs int `json:"timeout_secs"`
+}
+
+type NetworkFaultRequest struct {
+	TaskID string        `json:"task_id"`
+	Fault  *NetworkFault `json:"fault"`
+	// TimeoutSecs is how long to wait for the instances to apply the
+	// fault, in seconds.
+	TimeoutSecs int `json:"timeout_secs"`
+}
+
+type ScaleRequest struct {
+	TaskID string `json:"task_id"`
+	Group  string `json:"group"`
+	// Delta is the number of instances to add to the group, or to remove
+	// from it if negative.
+	Delta int `json:"delta"`
+}
+
+type ExecRequest struct {
+	TaskID string `json:"task_id"`
+	Group  string `json:"group"`
+	// Instance is the index of the instance in its group.
+	Instance int      `json:"instance"`
+	Cmd      []string `json:"cmd"`
+	Tty      bool     `json:"tty"`
+}
+
+type TokenCreateRequest struct {
+	Name  string     `json:"name"`
+	Scope auth.Scope `json:"scope"`
+	// ExpiresSecs is how long the token is valid for, in seconds; the token
+	// never expires if zero.
+	ExpiresSecs int `json:"expires_secs"`
+}
+
+type TokenRevokeRequest struct {
+	ID string `json:"id"`
+}
+
+type ExplainRequest struct {
+	TaskID string `json:"task_id"`
+}
+
+type GCRequest struct {
+	// DryRun reports what would be removed, without removing it.
+	DryRun bool `json:"dry_run"`
+}
+
 type BuildPurgeRequest struct {
 	Builder  string `json:"builder"`
 	Testplan string `json:"testplan"`
 }
 
+type BuildCollectRequest struct {
+	TaskID string `json:"task_id"`
+}
+
 type TasksRequest = TasksFilters
 
 type StatusRequest struct {
@@ -65,11 +158,17 @@ type CancelRequest struct {
 	TaskID string `json:"task_id"`
 }
 
+type DrainRequest struct {
+	// Resume accepts and picks tasks again, instead of draining.
+	Resume bool `json:"resume"`
+}
+
 type LogsRequest struct {
 	TaskID string `json:"task_id"`
 	Follow bool   `json:"follow"`
 	// CancelWithContext indicates if the task should be cancelled
-	// on context cancellation.
+	// on context cancellation. Only the owner of the task, or an admin, may
+	// set it.
 	CancelWithContext bool `json:"cancel_with_context"`
 }
 
@@ -92,3 +191,16 @@ type HealthcheckResponse = HealthcheckReport
 type StatusResponse = task.Task
 
 type LogsResponse = task.Task
+
+// TokenCreateResponse holds the secret of a new token, which is only ever
+// returned here.
+type TokenCreateResponse struct {
+	Token  *auth.Token `json:"token"`
+	Secret string      `json:"secret"`
+}
+
+type TokenListResponse = []*auth.Token
+
+type GCResponse = GCReport
+
+type ExplainResponse = Explanation
@@ -3,6 +3,7 @@ package api
 import (
 	"context"
 	"reflect"
+	"time"
 
 	"github.com/testground/testground/pkg/config"
 	"github.com/testground/testground/pkg/rpc"
@@ -58,8 +59,31 @@ type RunInput struct {
 	// DisableMetrics disables metrics batching.
 	DisableMetrics bool
 
+	// RuntimeAPI is the version of the runtime API of the environment of the
+	// instances, as targeted by the plan.
+	RuntimeAPI int
+
+	// IPFamily is the IP family of the data network.
+	IPFamily IPFamily
+
 	// Groups enumerates the groups participating in this run.
 	Groups []*RunGroup
+
+	// Inputs maps the ids of the runs this run depends on, in a pipeline, to
+	// the directories holding their outputs.
+	Inputs map[string]string
+
+	// Fixtures are the data sets to mount read-only into the instances.
+	Fixtures []*Fixture
+
+	// PauseOnFailure is how long a failed run keeps its instances alive for
+	// inspection before tearing them down, unless canceled earlier; failed
+	// runs are torn down right away if zero.
+	PauseOnFailure time.Duration
+
+	// Diagnostics configures what is captured from the instances when one of
+	// them crashes or the run fails; nothing is if nil.
+	Diagnostics *Diagnostics
 }
 
 type RunGroup struct {
@@ -82,6 +106,18 @@ type RunGroup struct {
 	// Profiles specifies the profiles to capture. Refer to the docs
 	// on Run#Profiles for more info.
 	Profiles map[string]string
+
+	// Env are the variables set in the runtime environment of the instances
+	// of this group.
+	Env map[string]string
+
+	// Volume is the persistent volume mounted in the instances of this
+	// group, if any.
+	Volume *Volume
+
+	// Publish are the ports of the instances of this group published on the
+	// host.
+	Publish []PublishedPort
 }
 
 type RunOutput struct {
@@ -99,6 +135,9 @@ type RunOutput struct {
 	// -- Kubernetes pod Status
 	// -- etc.
 	Result interface{}
+
+	// Provenance is what the run was executed from.
+	Provenance *Provenance
 }
 
 type CollectionInput struct {
@@ -113,6 +152,87 @@ type CollectionInput struct {
 	RunnerConfig interface{}
 }
 
+// Instance describes a live test instance of a run, as exposed to observers
+// outside of testground.
+type Instance struct {
+	// Name is the name of the instance, unique within the run.
+	Name string `json:"name"`
+	// GroupID is the group the instance belongs to, i.e. its role.
+	GroupID string `json:"group_id"`
+	// DataIP is the address of the instance on the data network.
+	DataIP string `json:"data_ip,omitempty"`
+	// Ports maps the ports exposed by the instance (e.g. "8080/tcp") to the
+	// host address they are published on, if any.
+	Ports map[string]string `json:"ports,omitempty"`
+	// State is the runner-specific state of the instance.
+	State string `json:"state"`
+}
+
+// InstanceRegistry is the interface to be implemented by runners that can
+// list the instances of a live run.
+type InstanceRegistry interface {
+	ListInstances(ctx context.Context, runID string) ([]*Instance, error)
+}
+
+// OutputsLocator is the interface to be implemented by runners that keep the
+// outputs of runs in a directory of the daemon host, so that they can be
+// consumed by the downstream runs of a pipeline.
+type OutputsLocator interface {
+	LocateOutputs(plan string, runID string) (string, error)
+}
+
+// ParamPusher is the interface to be implemented by runners that can push
+// parameter updates to the instances of a live run.
+type ParamPusher interface {
+	PushParam(ctx context.Context, input *ParamPushInput, ow *rpc.OutputWriter) (*ParamPushOutput, error)
+}
+
+// NetworkFaulter is the interface to be implemented by runners that can
+// inject network faults in a live run.
+type NetworkFaulter interface {
+	InjectNetworkFault(ctx context.Context, input *NetworkFaultInput, ow *rpc.OutputWriter) (*NetworkFaultOutput, error)
+}
+
+// Scaler is the interface to be implemented by runners that can add
+// instances to, or remove instances from, the groups of a live run.
+type Scaler interface {
+	Scale(ctx context.Context, input *ScaleInput, ow *rpc.OutputWriter) (*ScaleOutput, error)
+}
+
+// Executor is the interface to be implemented by runners that can run
+// commands in the instances of a live run.
+type Executor interface {
+	Exec(ctx context.Context, input *ExecInput) (*ExecOutput, error)
+}
+
+// ChaosInjector is the interface to be implemented by runners that can
+// disrupt the instances of a live run.
+type ChaosInjector interface {
+	InjectChaos(ctx context.Context, input *ChaosInput) error
+}
+
+// ReadinessProber is the interface to be implemented by runners that can
+// probe whether the instances of a live run are ready.
+type ReadinessProber interface {
+	ProbeReadiness(ctx context.Context, input *ReadinessInput) (*ReadinessReport, error)
+}
+
+// StateInspector is the interface to be implemented by runners that can tell
+// whether all the instances of a run signalled a state, once it completed.
+type StateInspector interface {
+	StateReached(ctx context.Context, input *RunInput, state string) (bool, error)
+}
+
+// RunReaper is the interface to be implemented by runners that label the
+// resources of runs with their run ID, so that the resources left behind by
+// the runs a restart of the daemon interrupted can be found and removed.
+type RunReaper interface {
+	// ListRuns returns the IDs of the runs that have resources.
+	ListRuns(ctx context.Context) ([]string, error)
+	// RemoveRun removes the resources of a run.
+	RemoveRun(ctx context.Context, runID string, ow *rpc.OutputWriter) error
+}
+
 // Terminatable is the interface to be implemented by a runner that can be
 // terminated.
 type Terminatable interface {
@@ -0,0 +1,122 @@
+package api
+
+import (
+	"fmt"
+)
+
+// Versions of the runtime API: the environment testground passes to the
+// instances of plans, which SDKs parse into their runtime environment.
+const (
+	// RuntimeAPIV1 is the environment of the run parameters, e.g. TEST_PLAN,
+	// TEST_RUN, TEST_INSTANCE_COUNT, and the test parameters packed in
+	// TEST_INSTANCE_PARAMS.
+	RuntimeAPIV1 = 1
+	// RuntimeAPIV2 adds TEST_CAPTURE_PROFILES and TEST_DISABLE_METRICS, and
+	// TEST_RUNTIME_API itself. SDKs that only know v1 ignore them, so that
+	// profiles aren't captured and metrics aren't disabled.
+	RuntimeAPIV2 = 2
+
+	// MinRuntimeAPI and MaxRuntimeAPI are the versions of the runtime API
+	// this daemon supports. Plans that don't declare the version they target
+	// get the latest.
+	MinRuntimeAPI = RuntimeAPIV1
+	MaxRuntimeAPI = RuntimeAPIV2
+)
+
+// EnvTestRuntimeAPI tells instances the version of the runtime API of their
+// environment.
+const EnvTestRuntimeAPI = "TEST_RUNTIME_API"
+
+// RuntimeTarget is the runtime API, and the SDK, a plan targets, as declared
+// in the [runtime] table of its manifest.
+type RuntimeTarget struct {
+	// API is the version of the runtime API the plan targets.
+	API int `toml:"api" json:"api"`
+	// SDK is the SDK the plan is built with, and its version, e.g.
+	// "sdk-go@v0.3.0". It's informational, and quoted in errors.
+	SDK string `toml:"sdk" json:"sdk,omitempty"`
+}
+
+// VersionResponse is what the daemon advertises of its versions.
+type VersionResponse struct {
+	// Version is the git commit the daemon was built from.
+	Version string `json:"version"`
+	// RuntimeAPI are the versions of the runtime API the daemon supports.
+	RuntimeAPI RuntimeAPIRange `json:"runtime_api"`
+}
+
+// RuntimeAPIRange is a range of versions of the runtime API.
+type RuntimeAPIRange struct {
+	Min int `json:"min"`
+	Max int `json:"max"`
+}
+
+// SupportedRuntimeAPI returns the versions of the runtime API this daemon
+// supports.
+func SupportedRuntimeAPI() RuntimeAPIRange {
+	return RuntimeAPIRange{Min: MinRuntimeAPI, Max: MaxRuntimeAPI}
+}
+
+// Supports returns whether the range includes the version.
+func (r RuntimeAPIRange) Supports(v int) bool {
+	return v >= r.Min && v <= r.Max
+}
+
+func (r RuntimeAPIRange) String() string {
+	if r.Min == r.Max {
+		return fmt.Sprintf("v%d", r.Min)
+	}
+	return fmt.Sprintf("v%d to v%d", r.Min, r.Max)
+}
+
+// RuntimeAPI returns the version of the runtime API to run the plan with: the
+// version it targets, or the latest if it doesn't declare one. It errors if
+// this daemon doesn't support the version the plan targets.
+func (tp *TestPlanManifest) RuntimeAPI() (int, error) {
+	if tp.Runtime == nil || tp.Runtime.API == 0 {
+		return MaxRuntimeAPI, nil
+	}
+
+	v, supported := tp.Runtime.API, SupportedRuntimeAPI()
+	if supported.Supports(v) {
+		return v, nil
+	}
+
+	sdk := ""
+	if tp.Runtime.SDK != "" {
+		sdk = fmt.Sprintf(" with %s", tp.Runtime.SDK)
+	}
+	if v > supported.Max {
+		return 0, fmt.Errorf("plan %s targets runtime API v%d%s, but this daemon supports %s; upgrade testground", tp.Name, v, sdk, supported)
+	}
+	return 0, fmt.Errorf("plan %s targets runtime API v%d%s, but this daemon supports %s; upgrade the SDK of the plan", tp.Name, v, sdk, supported)
+}
+
+// checkRuntimeFeatures returns an error if the composition uses features of
+// the runtime API above the version the plan targets, which its SDK would
+// silently ignore.
+func checkRuntimeFeatures(c *Composition, manifest *TestPlanManifest, version int) error {
+	if version >= RuntimeAPIV2 {
+		return nil
+	}
+
+	need := func(feature string) error {
+		return fmt.Errorf("%s needs runtime API v%d, but plan %s targets v%d", feature, RuntimeAPIV2, manifest.Name, version)
+	}
+	if c.Global.DisableMetrics {
+		return need("global.disable_metrics")
+	}
+	for _, g := range c.Groups {
+		if len(g.Run.Profiles) > 0 {
+			return need(fmt.Sprintf("the profiles of group %s", g.ID))
+		}
+	}
+	for _, r := range c.Runs {
+		for _, g := range r.Groups {
+			if len(g.Profiles) > 0 {
+				return need(fmt.Sprintf("the profiles of group %s of run %s", g.ID, r.ID))
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,66 @@
+package api
+
+import (
+	"testing"
+
+	"github.com/testground/testground/pkg/config"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestManifestRuntimeAPI(t *testing.T) {
+	m := &TestPlanManifest{Name: "foo_plan"}
+
+	// Plans that don't declare the runtime API run with the latest.
+	v, err := m.RuntimeAPI()
+	require.NoError(t, err)
+	require.Equal(t, MaxRuntimeAPI, v)
+
+	m.Runtime = &RuntimeTarget{API: RuntimeAPIV1}
+	v, err = m.RuntimeAPI()
+	require.NoError(t, err)
+	require.Equal(t, RuntimeAPIV1, v)
+
+	m.Runtime = &RuntimeTarget{API: MaxRuntimeAPI + 1, SDK: "sdk-go@v9.0.0"}
+	_, err = m.RuntimeAPI()
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "sdk-go@v9.0.0")
+	require.Contains(t, err.Error(), "upgrade testground")
+}
+
+func TestPrepareForRunChecksRuntimeFeatures(t *testing.T) {
+	manifest := &TestPlanManifest{
+		Name:      "foo_plan",
+		TestCases: []*TestCase{{Name: "foo_case", Instances: InstanceConstraints{Minimum: 1, Maximum: 10}}},
+		Builders:  map[string]config.ConfigMap{"docker:go": {}},
+		Runners:   map[string]config.ConfigMap{"local:docker": {}},
+		Runtime:   &RuntimeTarget{API: RuntimeAPIV1},
+	}
+
+	comp := func() *Composition {
+		return &Composition{
+			Global: Global{
+				Plan:           "foo_plan",
+				Case:           "foo_case",
+				Builder:        "docker:go",
+				Runner:         "local:docker",
+				TotalInstances: 1,
+			},
+			Groups: []*Group{{ID: "a", Instances: Instances{Count: 1}}},
+		}
+	}
+
+	_, err := comp().PrepareForRun(manifest)
+	require.NoError(t, err)
+
+	// Profiles aren't part of the runtime API v1.
+	c := comp()
+	c.Groups[0].Run.Profiles = map[string]string{"cpu": "true"}
+	_, err = c.PrepareForRun(manifest)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "needs runtime API v2")
+
+	manifest.Runtime.API = RuntimeAPIV2
+	_, err = c.PrepareForRun(manifest)
+	require.NoError(t, err)
+}
@@ -0,0 +1,20 @@
+package api
+
+type ScaleInput struct {
+	RunID string
+	// Group is the id of the run group to scale.
+	Group string
+	// Delta is the number of instances to add to the group, or to remove
+	// from it if negative.
+	Delta int
+}
+
+type ScaleOutput struct {
+	Group string `json:"group"`
+	// Added and Removed are the names of the instances added to and removed
+	// from the group.
+	Added   []string `json:"added,omitempty"`
+	Removed []string `json:"removed,omitempty"`
+	// Instances is the number of live instances of the group once scaled.
+	Instances int `json:"instances"`
+}
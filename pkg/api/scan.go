@@ -0,0 +1,102 @@
+package api
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Severities of vulnerabilities, from the lowest to the highest.
+const (
+	SeverityUnknown    = "UNKNOWN"
+	SeverityNegligible = "NEGLIGIBLE"
+	SeverityLow        = "LOW"
+	SeverityMedium     = "MEDIUM"
+	SeverityHigh       = "HIGH"
+	SeverityCritical   = "CRITICAL"
+)
+
+var severityRanks = map[string]int{
+	SeverityUnknown:    0,
+	SeverityNegligible: 1,
+	SeverityLow:        2,
+	SeverityMedium:     3,
+	SeverityHigh:       4,
+	SeverityCritical:   5,
+}
+
+// NormalizeSeverity returns the severity as one of the constants above, e.g.
+// "Critical" as CRITICAL. Unrecognized severities are UNKNOWN.
+func NormalizeSeverity(s string) string {
+	s = strings.ToUpper(s)
+	if _, ok := severityRanks[s]; ok {
+		return s
+	}
+	return SeverityUnknown
+}
+
+// ParseSeverityThreshold parses the severity from which vulnerabilities fail
+// a task.
+func ParseSeverityThreshold(s string) (string, error) {
+	switch sev := strings.ToUpper(s); sev {
+	case SeverityLow, SeverityMedium, SeverityHigh, SeverityCritical:
+		return sev, nil
+	default:
+		return "", fmt.Errorf("invalid severity %q; expected low, medium, high or critical", s)
+	}
+}
+
+// Vulnerability is a vulnerability found in a package of an artifact.
+type Vulnerability struct {
+	ID           string `json:"id"`
+	Package      string `json:"package"`
+	Version      string `json:"version"`
+	FixedVersion string `json:"fixed_version,omitempty"`
+	Severity     string `json:"severity"`
+	Title        string `json:"title,omitempty"`
+}
+
+// ScanReport is the report of the vulnerability scan of a build artifact.
+type ScanReport struct {
+	Scanner  string   `json:"scanner"`
+	Artifact string   `json:"artifact"`
+	Groups   []string `json:"groups"`
+	// Counts are the numbers of vulnerabilities, by severity.
+	Counts          map[string]int   `json:"counts"`
+	Vulnerabilities []*Vulnerability `json:"vulnerabilities"`
+	// FailOn is the severity from which vulnerabilities fail the task, and
+	// Blocking the number of those found, if set.
+	FailOn   string `json:"fail_on,omitempty"`
+	Blocking int    `json:"blocking,omitempty"`
+}
+
+// Evaluate counts the vulnerabilities of the report, by severity, and those
+// at or above the failOn severity, unless they have no fix and ignoreUnfixed
+// is set.
+func (r *ScanReport) Evaluate(failOn string, ignoreUnfixed bool) {
+	r.Counts = make(map[string]int)
+	r.FailOn = failOn
+	r.Blocking = 0
+	for _, v := range r.Vulnerabilities {
+		r.Counts[v.Severity]++
+		if failOn == "" || (ignoreUnfixed && v.FixedVersion == "") {
+			continue
+		}
+		if severityRanks[v.Severity] >= severityRanks[failOn] {
+			r.Blocking++
+		}
+	}
+}
+
+// Summary returns the counts of the report, from the highest severity.
+func (r *ScanReport) Summary() string {
+	var parts []string
+	for _, sev := range []string{SeverityCritical, SeverityHigh, SeverityMedium, SeverityLow, SeverityNegligible, SeverityUnknown} {
+		if n := r.Counts[sev]; n > 0 {
+			parts = append(parts, fmt.Sprintf("%d %s", n, strings.ToLower(sev)))
+		}
+	}
+	if len(parts) == 0 {
+		return "no vulnerabilities"
+	}
+	return strings.Join(parts, ", ")
+}
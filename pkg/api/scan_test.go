@@ -0,0 +1,45 @@
+package api
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestScanReportEvaluate(t *testing.T) {
+	r := &ScanReport{Vulnerabilities: []*Vulnerability{
+		{ID: "CVE-1", Severity: SeverityCritical},
+		{ID: "CVE-2", Severity: SeverityHigh, FixedVersion: "1.2"},
+		{ID: "CVE-3", Severity: SeverityLow, FixedVersion: "1.0"},
+		{ID: "CVE-4", Severity: SeverityUnknown},
+	}}
+
+	r.Evaluate("", false)
+	require.Zero(t, r.Blocking)
+	require.Equal(t, map[string]int{SeverityCritical: 1, SeverityHigh: 1, SeverityLow: 1, SeverityUnknown: 1}, r.Counts)
+	require.Equal(t, "1 critical, 1 high, 1 low, 1 unknown", r.Summary())
+
+	r.Evaluate(SeverityHigh, false)
+	require.Equal(t, 2, r.Blocking)
+
+	// Vulnerabilities without a fix can be ignored.
+	r.Evaluate(SeverityHigh, true)
+	require.Equal(t, 1, r.Blocking)
+
+	r.Evaluate(SeverityLow, false)
+	require.Equal(t, 3, r.Blocking)
+
+	require.Equal(t, "no vulnerabilities", (&ScanReport{}).Summary())
+}
+
+func TestParseSeverityThreshold(t *testing.T) {
+	sev, err := ParseSeverityThreshold("High")
+	require.NoError(t, err)
+	require.Equal(t, SeverityHigh, sev)
+
+	_, err = ParseSeverityThreshold("severe")
+	require.Error(t, err)
+
+	require.Equal(t, SeverityMedium, NormalizeSeverity("Medium"))
+	require.Equal(t, SeverityUnknown, NormalizeSeverity("whatever"))
+}
@@ -0,0 +1,67 @@
+package api
+
+import (
+	"fmt"
+	"time"
+)
+
+// Timeouts bound the runs of a composition. The daemon enforces them: a run
+// running out of time is torn down, and completes as timed out, whether or
+// not its client is still connected.
+//
+// All timeouts are in time.Duration string representation (e.g. 30m). Empty
+// timeouts don't apply.
+type Timeouts struct {
+	// Run bounds a run as a whole, from the start of its task, builds
+	// included, to the end of its instances.
+	Run string `toml:"run" json:"run,omitempty"`
+
+	// Build bounds the builds of a run, or of a build task.
+	Build string `toml:"build" json:"build,omitempty"`
+
+	// Start bounds the time all the instances of a run take to start, from
+	// the start of the run.
+	Start string `toml:"start" json:"start,omitempty"`
+}
+
+// Validate checks the timeouts are positive durations.
+func (t *Timeouts) Validate() error {
+	for _, f := range []struct{ name, value string }{
+		{"run", t.Run},
+		{"build", t.Build},
+		{"start", t.Start},
+	} {
+		if f.value == "" {
+			continue
+		}
+		if d, err := time.ParseDuration(f.value); err != nil || d <= 0 {
+			return fmt.Errorf("timeouts: invalid %s timeout %q", f.name, f.value)
+		}
+	}
+	return nil
+}
+
+// RunTimeout returns the run timeout of valid timeouts, or 0 if there is none.
+func (t *Timeouts) RunTimeout() time.Duration {
+	return parseTimeout(t, func(t *Timeouts) string { return t.Run })
+}
+
+// BuildTimeout returns the build timeout of valid timeouts, or 0 if there is
+// none.
+func (t *Timeouts) BuildTimeout() time.Duration {
+	return parseTimeout(t, func(t *Timeouts) string { return t.Build })
+}
+
+// StartTimeout returns the start timeout of valid timeouts, or 0 if there is
+// none.
+func (t *Timeouts) StartTimeout() time.Duration {
+	return parseTimeout(t, func(t *Timeouts) string { return t.Start })
+}
+
+func parseTimeout(t *Timeouts, field func(*Timeouts) string) time.Duration {
+	if t == nil || field(t) == "" {
+		return 0
+	}
+	d, _ := time.ParseDuration(field(t))
+	return d
+}
@@ -0,0 +1,28 @@
+package api
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTimeouts(t *testing.T) {
+	to := &Timeouts{Run: "1h", Build: "10m"}
+	require.NoError(t, to.Validate())
+	require.Equal(t, time.Hour, to.RunTimeout())
+	require.Equal(t, 10*time.Minute, to.BuildTimeout())
+	require.Zero(t, to.StartTimeout())
+
+	// No timeouts apply without a timeouts section.
+	var none *Timeouts
+	require.Zero(t, none.RunTimeout())
+
+	for _, to := range []Timeouts{
+		{Run: "forever"},
+		{Build: "-1m"},
+		{Start: "0s"},
+	} {
+		require.Error(t, to.Validate(), "%+v", to)
+	}
+}
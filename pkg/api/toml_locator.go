@@ -0,0 +1,350 @@
+package api
+
+import (
+	"strconv"
+	"strings"
+)
+
+// KeyLocator finds the line and column of the keys of a TOML document, to
+// point at the offending keys of a composition.
+//
+// Keys are dotted paths. The entries of arrays of tables are addressed by
+// their id, if they have one, or by their index otherwise, e.g.
+// groups.peers.instances.count or runs.0.test_params.latency.
+type KeyLocator struct {
+	positions map[string]keyPosition
+}
+
+type keyPosition struct {
+	line, col int
+}
+
+// NewKeyLocator scans a TOML document. The document is expected to be valid
+// TOML; the keys of invalid documents are located on a best effort basis.
+func NewKeyLocator(src []byte) *KeyLocator {
+	s := &tomlScanner{src: []rune(string(src)), line: 1, col: 1, counts: make(map[string]int), aliases: make(map[string]string)}
+	s.scan()
+
+	l := &KeyLocator{positions: make(map[string]keyPosition, 2*len(s.keys))}
+	for _, k := range s.keys {
+		pos := keyPosition{k.line, k.col}
+		l.positions[strings.Join(k.path, ".")] = pos
+
+		// Register the key by id too.
+		byId := make([]string, 0, len(k.path))
+		for i, seg := range k.path {
+			if id, ok := s.aliases[strings.Join(k.path[:i+1], ".")]; ok {
+				seg = id
+			}
+			byId = append(byId, seg)
+		}
+		if _, ok := l.positions[strings.Join(byId, ".")]; !ok {
+			l.positions[strings.Join(byId, ".")] = pos
+		}
+	}
+	return l
+}
+
+// Locate returns the position of a key. Keys that aren't in the document,
+// e.g. missing keys, are located at the closest table, or entry of an array of
+// tables, that contains them.
+func (l *KeyLocator) Locate(key string) (line, col int, ok bool) {
+	segs := strings.Split(key, ".")
+	for n := len(segs); n > 0; n-- {
+		if n < len(segs) && n < 2 {
+			break
+		}
+		if pos, ok := l.positions[strings.Join(segs[:n], ".")]; ok {
+			return pos.line, pos.col, true
+		}
+	}
+	return 0, 0, false
+}
+
+type scannedKey struct {
+	path      []string
+	line, col int
+}
+
+// tomlScanner is a minimal TOML scanner, recording the keys of the document
+// with their position.
+type tomlScanner struct {
+	src       []rune
+	pos       int
+	line, col int
+
+	keys []scannedKey
+	// counts are the number of entries of the arrays of tables seen so far.
+	counts map[string]int
+	// aliases are the ids of the entries of arrays of tables.
+	aliases map[string]string
+	// table is the path of the current table.
+	table []string
+}
+
+func (s *tomlScanner) peek(n int) rune {
+	if s.pos+n < len(s.src) {
+		return s.src[s.pos+n]
+	}
+	return 0
+}
+
+func (s *tomlScanner) next() rune {
+	r := s.peek(0)
+	if r == 0 {
+		return 0
+	}
+	s.pos++
+	if r == '\n' {
+		s.line, s.col = s.line+1, 1
+	} else {
+		s.col++
+	}
+	return r
+}
+
+func (s *tomlScanner) hasPrefix(p string) bool {
+	for i, r := range p {
+		if s.peek(i) != r {
+			return false
+		}
+	}
+	return true
+}
+
+// skip skips whitespace and comments, and newlines too if newlines is true.
+func (s *tomlScanner) skip(newlines bool) {
+	for {
+		switch r := s.peek(0); {
+		case r == ' ' || r == '\t' || r == '\r':
+			s.next()
+		case r == '\n' && newlines:
+			s.next()
+		case r == '#':
+			for s.peek(0) != '\n' && s.peek(0) != 0 {
+				s.next()
+			}
+		default:
+			return
+		}
+	}
+}
+
+func (s *tomlScanner) scan() {
+	for {
+		s.skip(true)
+		switch s.peek(0) {
+		case 0:
+			return
+		case '[':
+			s.header()
+		default:
+			line, col := s.line, s.col
+			key := s.key()
+			if len(key) == 0 {
+				// Not a key; skip the line.
+				for s.peek(0) != '\n' && s.peek(0) != 0 {
+					s.next()
+				}
+				continue
+			}
+			path := append(append([]string{}, s.table...), key...)
+			s.record(path, line, col)
+			s.skip(false)
+			if s.peek(0) == '=' {
+				s.next()
+				s.value(path)
+			}
+		}
+	}
+}
+
+func (s *tomlScanner) header() {
+	s.next()
+	array := s.peek(0) == '['
+	if array {
+		s.next()
+	}
+	s.skip(false)
+	line, col := s.line, s.col
+	key := s.key()
+
+	// Resolve the entries of the arrays of tables the table is nested in.
+	var path []string
+	for i, seg := range key {
+		path = append(path, seg)
+		prefix := strings.Join(path, ".")
+		if array && i == len(key)-1 {
+			s.counts[prefix]++
+			path = append(path, strconv.Itoa(s.counts[prefix]-1))
+			break
+		}
+		if n, ok := s.counts[prefix]; ok {
+			path = append(path, strconv.Itoa(n-1))
+		}
+	}
+	s.table = path
+	s.record(path, line, col)
+
+	for s.peek(0) != '\n' && s.peek(0) != 0 {
+		s.next()
+	}
+}
+
+func (s *tomlScanner) record(path []string, line, col int) {
+	s.keys = append(s.keys, scannedKey{path: append([]string{}, path...), line: line, col: col})
+}
+
+// key scans a possibly dotted key, and returns its segments.
+func (s *tomlScanner) key() []string {
+	var segs []string
+	for {
+		s.skip(false)
+		switch r := s.peek(0); {
+		case r == '"' || r == '\'':
+			v, ok := s.str()
+			if !ok {
+				return segs
+			}
+			segs = append(segs, v)
+		case isBareKeyRune(r):
+			var b strings.Builder
+			for isBareKeyRune(s.peek(0)) {
+				b.WriteRune(s.next())
+			}
+			segs = append(segs, b.String())
+		default:
+			return segs
+		}
+		s.skip(false)
+		if s.peek(0) != '.' {
+			return segs
+		}
+		s.next()
+	}
+}
+
+// str scans a string, and returns its value if it is a single line string.
+func (s *tomlScanner) str() (string, bool) {
+	quote := s.peek(0)
+	if delim := strings.Repeat(string(quote), 3); s.hasPrefix(delim) {
+		for i := 0; i < 3; i++ {
+			s.next()
+		}
+		for !s.hasPrefix(delim) && s.peek(0) != 0 {
+			if quote == '"' && s.peek(0) == '\\' {
+				s.next()
+			}
+			s.next()
+		}
+		for i := 0; i < 3; i++ {
+			s.next()
+		}
+		return "", false
+	}
+
+	s.next()
+	var b strings.Builder
+	for {
+		r := s.peek(0)
+		switch {
+		case r == 0 || r == '\n':
+			return b.String(), false
+		case r == quote:
+			s.next()
+			return b.String(), true
+		case r == '\\' && quote == '"':
+			s.next()
+			b.WriteRune(s.next())
+		default:
+			b.WriteRune(s.next())
+		}
+	}
+}
+
+// value scans the value of the key at path.
+func (s *tomlScanner) value(path []string) {
+	s.skip(false)
+	switch r := s.peek(0); r {
+	case '"', '\'':
+		v, ok := s.str()
+		if ok && len(path) >= 2 && path[len(path)-1] == "id" && isIndex(path[len(path)-2]) {
+			s.aliases[strings.Join(path[:len(path)-1], ".")] = v
+		}
+	case '{':
+		s.inlineTable(path)
+	case '[':
+		s.array(path)
+	default:
+		for {
+			r := s.peek(0)
+			if r == 0 || r == '\n' || r == ',' || r == '}' || r == ']' || r == '#' {
+				return
+			}
+			s.next()
+		}
+	}
+}
+
+func (s *tomlScanner) inlineTable(path []string) {
+	s.next()
+	for {
+		s.skip(true)
+		switch s.peek(0) {
+		case 0:
+			return
+		case '}':
+			s.next()
+			return
+		case ',':
+			s.next()
+			continue
+		}
+		line, col := s.line, s.col
+		key := s.key()
+		if len(key) == 0 {
+			s.next()
+			continue
+		}
+		p := append(append([]string{}, path...), key...)
+		s.record(p, line, col)
+		s.skip(false)
+		if s.peek(0) == '=' {
+			s.next()
+			s.value(p)
+		}
+	}
+}
+
+func (s *tomlScanner) array(path []string) {
+	s.next()
+	for i := 0; ; {
+		s.skip(true)
+		switch s.peek(0) {
+		case 0:
+			return
+		case ']':
+			s.next()
+			return
+		case ',':
+			s.next()
+			i++
+			continue
+		}
+		pos := s.pos
+		s.value(append(append([]string{}, path...), strconv.Itoa(i)))
+		if s.pos == pos {
+			// Not a value; skip it.
+			s.next()
+		}
+	}
+}
+
+func isBareKeyRune(r rune) bool {
+	return r == '_' || r == '-' || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9')
+}
+
+func isIndex(seg string) bool {
+	_, err := strconv.Atoi(seg)
+	return err == nil
+}
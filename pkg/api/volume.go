@@ -0,0 +1,70 @@
+package api
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// Volume is a persistent volume mounted in every instance of a group. The
+// volume of an instance can be snapshotted at the end of a run, and restored
+// at the start of another, so that instances carry their state (e.g. a
+// synced blockchain) over from run to run.
+//
+// Volumes are supported by the local:docker and cluster:k8s runners.
+type Volume struct {
+	// Path is the absolute path the volume is mounted at in the instances.
+	Path string `toml:"path" json:"path"`
+
+	// Snapshot snapshots the volume of every instance at the end of the run.
+	Snapshot bool `toml:"snapshot" json:"snapshot,omitempty"`
+
+	// FromSnapshot restores the volumes of the instances from the snapshot of
+	// a group of a previous run, as task:<task id>/group:<group id>. The i-th
+	// instance of the group gets the volume of the i-th instance of the
+	// snapshotted group; instances beyond those start with an empty volume.
+	FromSnapshot string `toml:"from_snapshot" json:"from_snapshot,omitempty" mapstructure:"from_snapshot"`
+}
+
+// Validate checks the path of the volume, and the snapshot it is restored
+// from, if any.
+func (v *Volume) Validate() error {
+	if !path.IsAbs(v.Path) || path.Clean(v.Path) == "/" {
+		return fmt.Errorf("volume: invalid path %q; an absolute path other than / is required", v.Path)
+	}
+	if v.FromSnapshot != "" {
+		if _, err := ParseSnapshotRef(v.FromSnapshot); err != nil {
+			return fmt.Errorf("volume: %w", err)
+		}
+	}
+	return nil
+}
+
+// SnapshotRef references the volume snapshots of a group of a run.
+type SnapshotRef struct {
+	// Task is the ID of the task of the run.
+	Task string
+	// Group is the ID of the group in the run.
+	Group string
+}
+
+// ParseSnapshotRef parses a snapshot reference, as task:<task id>/group:<group id>.
+func ParseSnapshotRef(s string) (*SnapshotRef, error) {
+	parts := strings.Split(s, "/")
+	if len(parts) != 2 || !strings.HasPrefix(parts[0], "task:") || !strings.HasPrefix(parts[1], "group:") {
+		return nil, fmt.Errorf("invalid snapshot %q; expected task:<task id>/group:<group id>", s)
+	}
+
+	ref := &SnapshotRef{
+		Task:  strings.TrimPrefix(parts[0], "task:"),
+		Group: strings.TrimPrefix(parts[1], "group:"),
+	}
+	if ref.Task == "" || ref.Group == "" || strings.Contains(ref.Task, "..") || strings.Contains(ref.Group, "..") {
+		return nil, fmt.Errorf("invalid snapshot %q; expected task:<task id>/group:<group id>", s)
+	}
+	return ref, nil
+}
+
+func (r *SnapshotRef) String() string {
+	return "task:" + r.Task + "/group:" + r.Group
+}
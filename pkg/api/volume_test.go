@@ -0,0 +1,37 @@
+package api
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestVolumeValidate(t *testing.T) {
+	for _, v := range []Volume{
+		{Path: "/data"},
+		{Path: "/data/chain", Snapshot: true, FromSnapshot: "task:abc/group:validators"},
+	} {
+		require.NoError(t, v.Validate(), "%+v", v)
+	}
+
+	for _, v := range []Volume{
+		{},
+		{Path: "data"},
+		{Path: "/"},
+		{Path: "/data", FromSnapshot: "abc/validators"},
+	} {
+		require.Error(t, v.Validate(), "%+v", v)
+	}
+}
+
+func TestParseSnapshotRef(t *testing.T) {
+	ref, err := ParseSnapshotRef("task:abc/group:validators")
+	require.NoError(t, err)
+	require.Equal(t, &SnapshotRef{Task: "abc", Group: "validators"}, ref)
+	require.Equal(t, "task:abc/group:validators", ref.String())
+
+	for _, s := range []string{"", "task:abc", "group:validators/task:abc", "task:/group:validators", "task:../group:validators"} {
+		_, err := ParseSnapshotRef(s)
+		require.Error(t, err, s)
+	}
+}
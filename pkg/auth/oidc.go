@@ -0,0 +1,416 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/testground/testground/pkg/config"
+)
+
+const (
+	// defaultGroupsClaim is the claim most providers list groups in.
+	defaultGroupsClaim = "groups"
+
+	// jwksRefreshInterval rate-limits fetching the keys of the provider when
+	// a token is signed with an unknown key, e.g. after a key rotation.
+	jwksRefreshInterval = time.Minute
+
+	// clockSkew is the leeway given to the expiry and not-before claims.
+	clockSkew = 30 * time.Second
+
+	// minRSAKeyBits is the size below which RSA keys of the provider are
+	// rejected.
+	minRSAKeyBits = 2048
+)
+
+// OIDCVerifier checks the ID tokens issued by an OpenID Connect provider, and
+// grants their users the scope of their groups.
+type OIDCVerifier struct {
+	cfg    config.OIDCConfig
+	client *http.Client
+
+	lk      sync.Mutex
+	keys    map[string]crypto.PublicKey
+	fetched time.Time
+}
+
+// NewOIDCVerifier validates the OIDC configuration. The keys of the provider
+// are only fetched when the first token is checked, so that the daemon
+// starts even if the provider is unreachable.
+func NewOIDCVerifier(cfg config.OIDCConfig) (*OIDCVerifier, error) {
+	if cfg.Issuer == "" {
+		return nil, errors.New("oidc: issuer is required")
+	}
+	if cfg.Audience == "" {
+		return nil, errors.New("oidc: audience is required")
+	}
+	if cfg.DefaultScope != "" {
+		if _, err := ParseScope(cfg.DefaultScope); err != nil {
+			return nil, fmt.Errorf("oidc: default scope: %w", err)
+		}
+	}
+	for group, scope := range cfg.Groups {
+		if _, err := ParseScope(scope); err != nil {
+			return nil, fmt.Errorf("oidc: scope of group %s: %w", group, err)
+		}
+	}
+	if cfg.GroupsClaim == "" {
+		cfg.GroupsClaim = defaultGroupsClaim
+	}
+	cfg.Issuer = strings.TrimSuffix(cfg.Issuer, "/")
+
+	return &OIDCVerifier{
+		cfg:    cfg,
+		client: &http.Client{Timeout: 10 * time.Second},
+		keys:   make(map[string]crypto.PublicKey),
+	}, nil
+}
+
+// IsJWT returns true if a bearer token is shaped like a JWT, rather than
+// like the secret of a token issued by the daemon.
+func IsJWT(token string) bool {
+	return strings.Count(token, ".") == 2 && !strings.HasPrefix(token, secretPrefix)
+}
+
+type jwtHeader struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+}
+
+// OIDCNamePrefix prefixes the names of the users of the OIDC provider, so
+// that they never collide with the names of the tokens of the daemon, which
+// own the tasks submitted with them.
+const OIDCNamePrefix = "oidc:"
+
+type jwtClaims struct {
+	Issuer    string          `json:"iss"`
+	Subject   string          `json:"sub"`
+	Email     string          `json:"email"`
+	Audience  json.RawMessage `json:"aud"`
+	ExpiresAt int64           `json:"exp"`
+	NotBefore int64           `json:"nbf"`
+}
+
+// Verify checks the signature and the claims of an ID token, and returns the
+// token of its user.
+func (v *OIDCVerifier) Verify(ctx context.Context, raw string) (*Token, error) {
+	parts := strings.Split(raw, ".")
+	if len(parts) != 3 {
+		return nil, ErrInvalidToken
+	}
+
+	var header jwtHeader
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, ErrInvalidToken
+	}
+
+	key, err := v.key(ctx, header.Kid)
+	if err != nil {
+		return nil, err
+	}
+
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+	if err := verifySignature(header.Alg, key, parts[0]+"."+parts[1], sig); err != nil {
+		return nil, err
+	}
+
+	var (
+		claims jwtClaims
+		all    map[string]interface{}
+	)
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, ErrInvalidToken
+	}
+	if err := decodeSegment(parts[1], &all); err != nil {
+		return nil, ErrInvalidToken
+	}
+
+	now := time.Now()
+	if strings.TrimSuffix(claims.Issuer, "/") != v.cfg.Issuer {
+		return nil, fmt.Errorf("oidc: unexpected issuer %q", claims.Issuer)
+	}
+	if !audienceContains(claims.Audience, v.cfg.Audience) {
+		return nil, fmt.Errorf("oidc: token not issued to %s", v.cfg.Audience)
+	}
+	expires := time.Unix(claims.ExpiresAt, 0)
+	if claims.ExpiresAt == 0 || now.After(expires.Add(clockSkew)) {
+		return nil, ErrExpiredToken
+	}
+	if claims.NotBefore != 0 && now.Add(clockSkew).Before(time.Unix(claims.NotBefore, 0)) {
+		return nil, ErrInvalidToken
+	}
+
+	// Only verified emails name users; anyone can claim an unverified one.
+	name := claims.Subject
+	if claims.Email != "" && emailVerified(all["email_verified"]) {
+		name = claims.Email
+	}
+	name = OIDCNamePrefix + name
+
+	scope, err := v.scope(all[v.cfg.GroupsClaim])
+	if err != nil {
+		return nil, fmt.Errorf("oidc: %s: %w", name, err)
+	}
+
+	return &Token{
+		ID:      "oidc:" + claims.Subject,
+		Name:    name,
+		Scope:   scope,
+		Expires: expires,
+	}, nil
+}
+
+// emailVerified returns whether the email_verified claim is true. Some
+// providers send it as a string.
+func emailVerified(claim interface{}) bool {
+	switch v := claim.(type) {
+	case bool:
+		return v
+	case string:
+		return v == "true"
+	}
+	return false
+}
+
+// scope returns the broadest scope granted by the groups of a user, or the
+// default scope if none of them grants one.
+func (v *OIDCVerifier) scope(groups interface{}) (Scope, error) {
+	var names []string
+	switch g := groups.(type) {
+	case string:
+		names = []string{g}
+	case []interface{}:
+		for _, n := range g {
+			if s, ok := n.(string); ok {
+				names = append(names, s)
+			}
+		}
+	}
+
+	var granted Scope
+	for _, n := range names {
+		s, ok := v.cfg.Groups[n]
+		if !ok {
+			continue
+		}
+		if sc := Scope(s); granted == "" || sc.rank() > granted.rank() {
+			granted = sc
+		}
+	}
+	if granted == "" {
+		granted = Scope(v.cfg.DefaultScope)
+	}
+	if granted == "" {
+		return "", errors.New("none of the groups of the user grants a scope")
+	}
+	return granted, nil
+}
+
+// key returns the public key of the provider with the given ID, fetching the
+// keys of the provider if it's unknown.
+func (v *OIDCVerifier) key(ctx context.Context, kid string) (crypto.PublicKey, error) {
+	v.lk.Lock()
+	defer v.lk.Unlock()
+
+	if k, ok := v.keys[kid]; ok {
+		return k, nil
+	}
+	if time.Since(v.fetched) < jwksRefreshInterval {
+		return nil, ErrInvalidToken
+	}
+
+	keys, err := v.fetchKeys(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("oidc: failed to fetch the keys of %s: %w", v.cfg.Issuer, err)
+	}
+	v.keys, v.fetched = keys, time.Now()
+
+	if k, ok := v.keys[kid]; ok {
+		return k, nil
+	}
+	return nil, ErrInvalidToken
+}
+
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// fetchKeys discovers the JWKS endpoint of the provider, and fetches its
+// signing keys.
+func (v *OIDCVerifier) fetchKeys(ctx context.Context) (map[string]crypto.PublicKey, error) {
+	var discovery struct {
+		Issuer  string `json:"issuer"`
+		JWKSURI string `json:"jwks_uri"`
+	}
+	if err := v.getJSON(ctx, v.cfg.Issuer+"/.well-known/openid-configuration", &discovery); err != nil {
+		return nil, err
+	}
+	if strings.TrimSuffix(discovery.Issuer, "/") != v.cfg.Issuer {
+		return nil, fmt.Errorf("provider claims to be issuer %q", discovery.Issuer)
+	}
+
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := v.getJSON(ctx, discovery.JWKSURI, &set); err != nil {
+		return nil, err
+	}
+
+	keys := make(map[string]crypto.PublicKey, len(set.Keys))
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		pub, err := k.publicKey()
+		if err != nil {
+			continue
+		}
+		keys[k.Kid] = pub
+	}
+	return keys, nil
+}
+
+func (v *OIDCVerifier) getJSON(ctx context.Context, url string, obj interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := v.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s: %s", url, resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(obj)
+}
+
+func (k *jwk) publicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeBigInt(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeBigInt(k.E)
+		if err != nil {
+			return nil, err
+		}
+		if n.BitLen() < minRSAKeyBits {
+			return nil, fmt.Errorf("rsa key too short: %d bits", n.BitLen())
+		}
+		if !e.IsInt64() || e.Int64() < 3 || e.Int64() > 1<<31-1 || e.Bit(0) == 0 {
+			return nil, errors.New("invalid rsa public exponent")
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		if k.Crv != "P-256" {
+			return nil, fmt.Errorf("unsupported curve: %s", k.Crv)
+		}
+		x, err := decodeBigInt(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeBigInt(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		// Coordinates are the full size of the curve; tokens signed for
+		// points off the curve could be forged.
+		size := base64.RawURLEncoding.EncodedLen(32)
+		if len(k.X) != size || len(k.Y) != size || !elliptic.P256().IsOnCurve(x, y) {
+			return nil, errors.New("ec point is not on the curve")
+		}
+		return &ecdsa.PublicKey{Curve: elliptic.P256(), X: x, Y: y}, nil
+	default:
+		return nil, fmt.Errorf("unsupported key type: %s", k.Kty)
+	}
+}
+
+// verifySignature checks a JWT signature. Only the asymmetric algorithms
+// OIDC providers sign ID tokens with are supported; "none" and HMAC never
+// are.
+func verifySignature(alg string, key crypto.PublicKey, signed string, sig []byte) error {
+	digest := sha256.Sum256([]byte(signed))
+
+	switch alg {
+	case "RS256":
+		pub, ok := key.(*rsa.PublicKey)
+		if !ok {
+			return ErrInvalidToken
+		}
+		if err := rsa.VerifyPKCS1v15(pub, crypto.SHA256, digest[:], sig); err != nil {
+			return ErrInvalidToken
+		}
+	case "ES256":
+		pub, ok := key.(*ecdsa.PublicKey)
+		if !ok || len(sig) != 64 {
+			return ErrInvalidToken
+		}
+		r, s := new(big.Int).SetBytes(sig[:32]), new(big.Int).SetBytes(sig[32:])
+		if !ecdsa.Verify(pub, digest[:], r, s) {
+			return ErrInvalidToken
+		}
+	default:
+		return fmt.Errorf("oidc: unsupported signing algorithm %q", alg)
+	}
+	return nil
+}
+
+func audienceContains(raw json.RawMessage, audience string) bool {
+	var one string
+	if err := json.Unmarshal(raw, &one); err == nil {
+		return one == audience
+	}
+	var many []string
+	if err := json.Unmarshal(raw, &many); err != nil {
+		return false
+	}
+	for _, a := range many {
+		if a == audience {
+			return true
+		}
+	}
+	return false
+}
+
+func decodeSegment(seg string, obj interface{}) error {
+	b, err := base64.RawURLEncoding.DecodeString(seg)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, obj)
+}
+
+func decodeBigInt(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	return new(big.Int).SetBytes(b), nil
+}
@@ -0,0 +1,327 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/testground/testground/pkg/config"
+)
+
+// fakeProvider serves the discovery document and the keys of an OIDC
+// provider, and signs ID tokens.
+type fakeProvider struct {
+	*httptest.Server
+	key *rsa.PrivateKey
+	ec  *ecdsa.PrivateKey
+
+	lk sync.Mutex
+	// issuer, if set, is the issuer the discovery document claims.
+	issuer string
+	// keys are the keys served, in addition to the signing keys.
+	keys []map[string]string
+	// fetches counts the fetches of the keys.
+	fetches int
+}
+
+func newFakeProvider(t *testing.T) *fakeProvider {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	ec, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	p := &fakeProvider{key: key, ec: ec}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		p.lk.Lock()
+		issuer := p.issuer
+		p.lk.Unlock()
+		if issuer == "" {
+			issuer = p.URL
+		}
+		_ = json.NewEncoder(w).Encode(map[string]string{"issuer": issuer, "jwks_uri": p.URL + "/keys"})
+	})
+	mux.HandleFunc("/keys", func(w http.ResponseWriter, r *http.Request) {
+		p.lk.Lock()
+		defer p.lk.Unlock()
+		p.fetches++
+
+		keys := append([]map[string]string{{
+			"kty": "RSA",
+			"kid": "k1",
+			"use": "sig",
+			"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}, {
+			"kty": "EC",
+			"kid": "e1",
+			"crv": "P-256",
+			"x":   base64.RawURLEncoding.EncodeToString(ec.X.FillBytes(make([]byte, 32))),
+			"y":   base64.RawURLEncoding.EncodeToString(ec.Y.FillBytes(make([]byte, 32))),
+		}}, p.keys...)
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"keys": keys})
+	})
+	p.Server = httptest.NewServer(mux)
+	t.Cleanup(p.Close)
+	return p
+}
+
+func (p *fakeProvider) sign(t *testing.T, claims map[string]interface{}) string {
+	return p.signWith(t, "RS256", "k1", claims)
+}
+
+// signWith signs a token with the key of the provider for the given
+// algorithm, whatever the key the header names.
+func (p *fakeProvider) signWith(t *testing.T, alg string, kid string, claims map[string]interface{}) string {
+	header, err := json.Marshal(map[string]string{"alg": alg, "kid": kid, "typ": "JWT"})
+	require.NoError(t, err)
+	payload, err := json.Marshal(claims)
+	require.NoError(t, err)
+
+	signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	digest := sha256.Sum256([]byte(signed))
+
+	var sig []byte
+	switch alg {
+	case "RS256":
+		sig, err = rsa.SignPKCS1v15(rand.Reader, p.key, crypto.SHA256, digest[:])
+		require.NoError(t, err)
+	case "ES256":
+		r, s, err := ecdsa.Sign(rand.Reader, p.ec, digest[:])
+		require.NoError(t, err)
+		sig = append(r.FillBytes(make([]byte, 32)), s.FillBytes(make([]byte, 32))...)
+	case "HS256":
+		// Signed with the public key of the provider as the secret, as
+		// in algorithm confusion attacks.
+		mac := hmac.New(sha256.New, p.key.N.Bytes())
+		mac.Write([]byte(signed))
+		sig = mac.Sum(nil)
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(sig)
+}
+
+func (p *fakeProvider) claims(groups ...string) map[string]interface{} {
+	return map[string]interface{}{
+		"iss":    p.URL,
+		"sub":    "1234",
+		"aud":    "testground",
+		"exp":    time.Now().Add(time.Hour).Unix(),
+		"groups": groups,
+	}
+}
+
+func TestOIDCVerifier(t *testing.T) {
+	p := newFakeProvider(t)
+
+	v, err := NewOIDCVerifier(config.OIDCConfig{
+		Issuer:   p.URL,
+		Audience: "testground",
+		Groups:   map[string]string{"ci": "submit-only", "infra": "admin"},
+	})
+	require.NoError(t, err)
+
+	claims := func(groups ...string) map[string]interface{} {
+		return map[string]interface{}{
+			"iss":            p.URL,
+			"sub":            "1234",
+			"email":          "alice@example.com",
+			"email_verified": true,
+			"aud":            []string{"testground", "other"},
+			"exp":            time.Now().Add(time.Hour).Unix(),
+			"groups":         groups,
+		}
+	}
+
+	raw := p.sign(t, claims("ci", "infra"))
+	require.True(t, IsJWT(raw))
+
+	tok, err := v.Verify(context.Background(), raw)
+	require.NoError(t, err)
+	require.Equal(t, "oidc:alice@example.com", tok.Name)
+	require.Equal(t, ScopeAdmin, tok.Scope)
+
+	// Unverified emails don't name users, as anyone can claim them.
+	unverified := claims("ci")
+	unverified["email_verified"] = false
+	tok, err = v.Verify(context.Background(), p.sign(t, unverified))
+	require.NoError(t, err)
+	require.Equal(t, "oidc:1234", tok.Name)
+	unverified["email_verified"] = "true"
+	tok, err = v.Verify(context.Background(), p.sign(t, unverified))
+	require.NoError(t, err)
+	require.Equal(t, "oidc:alice@example.com", tok.Name)
+
+	tok, err = v.Verify(context.Background(), p.sign(t, claims("ci")))
+	require.NoError(t, err)
+	require.Equal(t, ScopeSubmitOnly, tok.Scope)
+
+	// Users in none of the groups are denied, as there's no default scope.
+	_, err = v.Verify(context.Background(), p.sign(t, claims("sales")))
+	require.Error(t, err)
+
+	expired := claims("ci")
+	expired["exp"] = time.Now().Add(-time.Hour).Unix()
+	_, err = v.Verify(context.Background(), p.sign(t, expired))
+	require.Equal(t, ErrExpiredToken, err)
+
+	wrongAud := claims("ci")
+	wrongAud["aud"] = "other"
+	_, err = v.Verify(context.Background(), p.sign(t, wrongAud))
+	require.Error(t, err)
+
+	wrongIss := claims("ci")
+	wrongIss["iss"] = "https://evil.example.com"
+	_, err = v.Verify(context.Background(), p.sign(t, wrongIss))
+	require.Error(t, err)
+
+	// Tampering with the claims invalidates the signature.
+	tampered := []byte(raw)
+	tampered[len(tampered)-5] ^= 1
+	_, err = v.Verify(context.Background(), string(tampered))
+	require.Equal(t, ErrInvalidToken, err)
+
+	require.False(t, IsJWT("tg_0123456789abcdef"))
+}
+
+func TestOIDCVerifierDefaultScope(t *testing.T) {
+	p := newFakeProvider(t)
+
+	v, err := NewOIDCVerifier(config.OIDCConfig{Issuer: p.URL, Audience: "testground", DefaultScope: "read-only"})
+	require.NoError(t, err)
+
+	tok, err := v.Verify(context.Background(), p.sign(t, map[string]interface{}{
+		"iss": p.URL,
+		"sub": "5678",
+		"aud": "testground",
+		"exp": time.Now().Add(time.Hour).Unix(),
+	}))
+	require.NoError(t, err)
+	require.Equal(t, "oidc:5678", tok.Name)
+	require.Equal(t, ScopeReadOnly, tok.Scope)
+
+	_, err = NewOIDCVerifier(config.OIDCConfig{Issuer: p.URL, Audience: "testground", DefaultScope: "root"})
+	require.Error(t, err)
+}
+
+func TestOIDCVerifierAlgorithms(t *testing.T) {
+	p := newFakeProvider(t)
+
+	v, err := NewOIDCVerifier(config.OIDCConfig{Issuer: p.URL, Audience: "testground", DefaultScope: "read-only"})
+	require.NoError(t, err)
+
+	_, err = v.Verify(context.Background(), p.signWith(t, "ES256", "e1", p.claims()))
+	require.NoError(t, err)
+
+	// The algorithm must match the type of the key.
+	_, err = v.Verify(context.Background(), p.signWith(t, "ES256", "k1", p.claims()))
+	require.Equal(t, ErrInvalidToken, err)
+	_, err = v.Verify(context.Background(), p.signWith(t, "RS256", "e1", p.claims()))
+	require.Equal(t, ErrInvalidToken, err)
+
+	// Symmetric and unsigned tokens are never accepted.
+	_, err = v.Verify(context.Background(), p.signWith(t, "HS256", "k1", p.claims()))
+	require.Error(t, err)
+	_, err = v.Verify(context.Background(), p.signWith(t, "none", "k1", p.claims()))
+	require.Error(t, err)
+}
+
+func TestOIDCVerifierUnknownKey(t *testing.T) {
+	p := newFakeProvider(t)
+
+	v, err := NewOIDCVerifier(config.OIDCConfig{Issuer: p.URL, Audience: "testground", DefaultScope: "read-only"})
+	require.NoError(t, err)
+
+	_, err = v.Verify(context.Background(), p.sign(t, p.claims()))
+	require.NoError(t, err)
+
+	// Tokens signed with unknown keys don't trigger fetching the keys again
+	// within the refresh interval, so they can't be used to flood the
+	// provider.
+	for i := 0; i < 3; i++ {
+		_, err = v.Verify(context.Background(), p.signWith(t, "RS256", "k2", p.claims()))
+		require.Equal(t, ErrInvalidToken, err)
+	}
+	p.lk.Lock()
+	require.Equal(t, 1, p.fetches)
+	p.lk.Unlock()
+
+	// Past the refresh interval, the keys are fetched again.
+	v.lk.Lock()
+	v.fetched = time.Now().Add(-jwksRefreshInterval)
+	v.lk.Unlock()
+	_, err = v.Verify(context.Background(), p.signWith(t, "RS256", "k2", p.claims()))
+	require.Equal(t, ErrInvalidToken, err)
+	p.lk.Lock()
+	require.Equal(t, 2, p.fetches)
+	p.lk.Unlock()
+}
+
+func TestOIDCVerifierWrongDiscoveryIssuer(t *testing.T) {
+	p := newFakeProvider(t)
+	p.issuer = "https://evil.example.com"
+
+	v, err := NewOIDCVerifier(config.OIDCConfig{Issuer: p.URL, Audience: "testground", DefaultScope: "read-only"})
+	require.NoError(t, err)
+
+	_, err = v.Verify(context.Background(), p.sign(t, p.claims()))
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "claims to be issuer")
+}
+
+func TestJWKPublicKey(t *testing.T) {
+	b64 := func(b []byte) string { return base64.RawURLEncoding.EncodeToString(b) }
+
+	ec, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	x, y := ec.X.FillBytes(make([]byte, 32)), ec.Y.FillBytes(make([]byte, 32))
+
+	_, err = (&jwk{Kty: "EC", Crv: "P-256", X: b64(x), Y: b64(y)}).publicKey()
+	require.NoError(t, err)
+
+	// Points off the curve, as used in invalid curve attacks.
+	offCurve := new(big.Int).Add(ec.Y, big.NewInt(1)).FillBytes(make([]byte, 32))
+	_, err = (&jwk{Kty: "EC", Crv: "P-256", X: b64(x), Y: b64(offCurve)}).publicKey()
+	require.Error(t, err)
+	_, err = (&jwk{Kty: "EC", Crv: "P-256", X: b64(x), Y: b64(y[:31])}).publicKey()
+	require.Error(t, err)
+	_, err = (&jwk{Kty: "EC", Crv: "P-256", X: b64(make([]byte, 32)), Y: b64(make([]byte, 32))}).publicKey()
+	require.Error(t, err)
+	_, err = (&jwk{Kty: "EC", Crv: "P-384", X: b64(x), Y: b64(y)}).publicKey()
+	require.Error(t, err)
+	_, err = (&jwk{Kty: "EC", Crv: "P-256", X: "not base64!", Y: b64(y)}).publicKey()
+	require.Error(t, err)
+
+	short, err := rsa.GenerateKey(rand.Reader, 1024)
+	require.NoError(t, err)
+	_, err = (&jwk{Kty: "RSA", N: b64(short.N.Bytes()), E: b64(big.NewInt(65537).Bytes())}).publicKey()
+	require.Error(t, err)
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	_, err = (&jwk{Kty: "RSA", N: b64(key.N.Bytes()), E: b64(big.NewInt(65537).Bytes())}).publicKey()
+	require.NoError(t, err)
+	for _, e := range []int64{1, 65536} {
+		_, err = (&jwk{Kty: "RSA", N: b64(key.N.Bytes()), E: b64(big.NewInt(e).Bytes())}).publicKey()
+		require.Error(t, err, e)
+	}
+	_, err = (&jwk{Kty: "RSA", N: b64(key.N.Bytes()), E: b64(make([]byte, 9))}).publicKey()
+	require.Error(t, err)
+
+	_, err = (&jwk{Kty: "oct"}).publicKey()
+	require.Error(t, err)
+}
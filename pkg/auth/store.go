@@ -0,0 +1,142 @@
+package auth
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/rs/xid"
+	"github.com/syndtr/goleveldb/leveldb"
+	"github.com/syndtr/goleveldb/leveldb/opt"
+	"github.com/syndtr/goleveldb/leveldb/storage"
+	"github.com/syndtr/goleveldb/leveldb/util"
+)
+
+// tokens are stored under token:<hash of the secret>, so that checking a
+// request is a single lookup.
+const prefixToken = "token:"
+
+// Store keeps the tokens issued by the daemon in leveldb.
+type Store struct {
+	db *leveldb.DB
+}
+
+func NewMemoryStore() (*Store, error) {
+	db, err := leveldb.Open(storage.NewMemStorage(), nil)
+	if err != nil {
+		return nil, err
+	}
+	return &Store{db}, nil
+}
+
+func NewStore(path string) (*Store, error) {
+	db, err := leveldb.OpenFile(path, nil)
+	if err != nil {
+		return nil, fmt.Errorf("error while opening token storage: %v", err)
+	}
+	return &Store{db}, nil
+}
+
+// Create issues a new token, valid for ttl, or forever if ttl is zero. It
+// returns the token along with its secret, which can't be recovered later.
+func (s *Store) Create(name string, scope Scope, ttl time.Duration) (*Token, string, error) {
+	if _, err := ParseScope(string(scope)); err != nil {
+		return nil, "", err
+	}
+	if ttl < 0 {
+		return nil, "", fmt.Errorf("invalid token ttl %s: must not be negative", ttl)
+	}
+	if strings.HasPrefix(name, OIDCNamePrefix) {
+		return nil, "", fmt.Errorf("token names can't start with %q, which names the users of the OIDC provider", OIDCNamePrefix)
+	}
+
+	secret, err := newSecret()
+	if err != nil {
+		return nil, "", err
+	}
+
+	now := time.Now().UTC()
+	tok := &Token{
+		ID:      xid.New().String(),
+		Name:    name,
+		Scope:   scope,
+		Created: now,
+		Hash:    hashSecret(secret),
+	}
+	if ttl > 0 {
+		tok.Expires = now.Add(ttl)
+	}
+
+	val, err := json.Marshal(tok)
+	if err != nil {
+		return nil, "", err
+	}
+	if err := s.db.Put([]byte(prefixToken+tok.Hash), val, &opt.WriteOptions{Sync: true}); err != nil {
+		return nil, "", err
+	}
+	return tok, secret, nil
+}
+
+// Check returns the token of a secret, if it exists and hasn't expired.
+func (s *Store) Check(secret string) (*Token, error) {
+	val, err := s.db.Get([]byte(prefixToken+hashSecret(secret)), nil)
+	if err == leveldb.ErrNotFound {
+		return nil, ErrInvalidToken
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var tok Token
+	if err := json.Unmarshal(val, &tok); err != nil {
+		return nil, err
+	}
+	if tok.Expired(time.Now()) {
+		return nil, ErrExpiredToken
+	}
+	return &tok, nil
+}
+
+// List returns all the tokens, including the expired ones, from the oldest
+// to the newest.
+func (s *Store) List() ([]*Token, error) {
+	iter := s.db.NewIterator(util.BytesPrefix([]byte(prefixToken)), nil)
+	defer iter.Release()
+
+	var tokens []*Token
+	for iter.Next() {
+		var tok Token
+		if err := json.Unmarshal(iter.Value(), &tok); err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, &tok)
+	}
+	if err := iter.Error(); err != nil {
+		return nil, err
+	}
+
+	sort.Slice(tokens, func(i, j int) bool {
+		return tokens[i].ID < tokens[j].ID
+	})
+	return tokens, nil
+}
+
+// Revoke deletes the token with the given ID.
+func (s *Store) Revoke(id string) error {
+	tokens, err := s.List()
+	if err != nil {
+		return err
+	}
+	for _, tok := range tokens {
+		if tok.ID == id {
+			return s.db.Delete([]byte(prefixToken+tok.Hash), &opt.WriteOptions{Sync: true})
+		}
+	}
+	return fmt.Errorf("token %s not found", id)
+}
+
+func (s *Store) Close() error {
+	return s.db.Close()
+}
@@ -0,0 +1,62 @@
+package auth
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestStore(t *testing.T) {
+	s, err := NewMemoryStore()
+	require.NoError(t, err)
+	defer s.Close()
+
+	tok, secret, err := s.Create("ci", ScopeSubmitOnly, 0)
+	require.NoError(t, err)
+	require.True(t, tok.Expires.IsZero())
+
+	checked, err := s.Check(secret)
+	require.NoError(t, err)
+	require.Equal(t, tok.ID, checked.ID)
+	require.Equal(t, ScopeSubmitOnly, checked.Scope)
+
+	_, err = s.Check("tg_unknown")
+	require.Equal(t, ErrInvalidToken, err)
+
+	// Tokens can't be named after the users of the OIDC provider.
+	_, _, err = s.Create("oidc:alice@example.com", ScopeSubmitOnly, 0)
+	require.Error(t, err)
+
+	// Expired tokens are listed, but rejected.
+	expired, expiredSecret, err := s.Create("alice", ScopeAdmin, time.Nanosecond)
+	require.NoError(t, err)
+	time.Sleep(time.Millisecond)
+	_, err = s.Check(expiredSecret)
+	require.Equal(t, ErrExpiredToken, err)
+
+	tokens, err := s.List()
+	require.NoError(t, err)
+	require.Len(t, tokens, 2)
+
+	require.NoError(t, s.Revoke(expired.ID))
+	require.Error(t, s.Revoke(expired.ID))
+	tokens, err = s.List()
+	require.NoError(t, err)
+	require.Len(t, tokens, 1)
+
+	_, _, err = s.Create("bob", Scope("root"), 0)
+	require.Error(t, err)
+
+	_, _, err = s.Create("bob", ScopeReadOnly, -time.Hour)
+	require.Error(t, err)
+}
+
+func TestScopeAllows(t *testing.T) {
+	require.True(t, ScopeAdmin.Allows(ScopeSubmitOnly))
+	require.True(t, ScopeSubmitOnly.Allows(ScopeReadOnly))
+	require.True(t, ScopeReadOnly.Allows(ScopeReadOnly))
+	require.False(t, ScopeReadOnly.Allows(ScopeSubmitOnly))
+	require.False(t, ScopeSubmitOnly.Allows(ScopeAdmin))
+	require.False(t, Scope("").Allows(ScopeReadOnly))
+}
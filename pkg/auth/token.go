@@ -0,0 +1,111 @@
+// Package auth issues and checks the scoped API tokens of the daemon, and
+// checks the ID tokens of OpenID Connect providers.
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Scope is what a token is allowed to do against the daemon.
+type Scope string
+
+const (
+	// ScopeReadOnly tokens can list and inspect tasks, and fetch their logs
+	// and outputs.
+	ScopeReadOnly Scope = "read-only"
+	// ScopeSubmitOnly tokens can also submit builds and runs, and steer
+	// them, but cannot administer the daemon; this is the scope meant for CI.
+	ScopeSubmitOnly Scope = "submit-only"
+	// ScopeAdmin tokens can do everything, including killing and deleting
+	// any task, exec'ing into instances, purging builds, and managing tokens.
+	ScopeAdmin Scope = "admin"
+)
+
+// Scopes are all the scopes, from the narrowest to the broadest.
+var Scopes = []Scope{ScopeReadOnly, ScopeSubmitOnly, ScopeAdmin}
+
+// ParseScope validates a scope.
+func ParseScope(s string) (Scope, error) {
+	for _, sc := range Scopes {
+		if Scope(s) == sc {
+			return sc, nil
+		}
+	}
+	return "", fmt.Errorf("unknown scope %q; supported: %s, %s, %s", s, ScopeReadOnly, ScopeSubmitOnly, ScopeAdmin)
+}
+
+// Allows returns true if the scope grants the required scope. Scopes are
+// nested: every scope grants the narrower ones.
+func (s Scope) Allows(required Scope) bool {
+	return s.rank() >= required.rank() && required.rank() >= 0
+}
+
+func (s Scope) rank() int {
+	for i, sc := range Scopes {
+		if s == sc {
+			return i
+		}
+	}
+	return -1
+}
+
+// Token is an API token issued by the daemon. Only the hash of its secret is
+// kept; the secret itself is handed out once, when the token is created.
+type Token struct {
+	ID      string    `json:"id"`
+	Name    string    `json:"name"`
+	Scope   Scope     `json:"scope"`
+	Created time.Time `json:"created"`
+	// Expires is zero for tokens that never expire.
+	Expires time.Time `json:"expires,omitempty"`
+	Hash    string    `json:"hash,omitempty"`
+}
+
+// Expired returns true if the token has expired at the given time.
+func (t *Token) Expired(now time.Time) bool {
+	return !t.Expires.IsZero() && !now.Before(t.Expires)
+}
+
+type tokenKey struct{}
+
+// WithToken returns a context carrying the token a request was authenticated
+// with.
+func WithToken(ctx context.Context, tok *Token) context.Context {
+	return context.WithValue(ctx, tokenKey{}, tok)
+}
+
+// TokenFromContext returns the token a request was authenticated with, or nil
+// if the daemon doesn't require authentication.
+func TokenFromContext(ctx context.Context) *Token {
+	tok, _ := ctx.Value(tokenKey{}).(*Token)
+	return tok
+}
+
+// secretPrefix makes the secrets of testground tokens easy to recognize,
+// e.g. by secret scanners.
+const secretPrefix = "tg_"
+
+var (
+	ErrInvalidToken = errors.New("invalid token")
+	ErrExpiredToken = errors.New("expired token")
+)
+
+// newSecret generates a random secret for a token.
+func newSecret() (string, error) {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return secretPrefix + hex.EncodeToString(b), nil
+}
+
+func hashSecret(secret string) string {
+	h := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(h[:])
+}
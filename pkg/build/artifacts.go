@@ -0,0 +1,67 @@
+package build
+
+import (
+	"context"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/client"
+
+	"github.com/testground/testground/pkg/api"
+)
+
+const (
+	// labelBuilder and labelPlan are set on the images built by the docker
+	// builders, so that their artifacts can be garbage collected.
+	labelBuilder = "testground.builder"
+	labelPlan    = "testground.plan"
+)
+
+func imageLabels(builder, plan string) map[string]string {
+	return map[string]string{
+		labelBuilder: builder,
+		labelPlan:    plan,
+	}
+}
+
+// listImageArtifacts lists the images built by a docker builder. Images
+// built before they were labelled aren't listed.
+func listImageArtifacts(ctx context.Context, builder string) ([]*api.Artifact, error) {
+	cli, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
+	if err != nil {
+		return nil, err
+	}
+	defer cli.Close()
+
+	images, err := cli.ImageList(ctx, types.ImageListOptions{
+		Filters: filters.NewArgs(filters.Arg("label", labelBuilder+"="+builder)),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	artifacts := make([]*api.Artifact, 0, len(images))
+	for _, img := range images {
+		artifacts = append(artifacts, &api.Artifact{
+			ID:      img.ID,
+			Plan:    img.Labels[labelPlan],
+			Created: time.Unix(img.Created, 0),
+			Size:    img.Size,
+		})
+	}
+	return artifacts, nil
+}
+
+// removeImageArtifact removes an image built by a docker builder, along with
+// all its tags. Docker refuses to remove the images of running containers.
+func removeImageArtifact(ctx context.Context, artifact *api.Artifact) error {
+	cli, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
+	if err != nil {
+		return err
+	}
+	defer cli.Close()
+
+	_, err = cli.ImageRemove(ctx, artifact.ID, types.ImageRemoveOptions{Force: true, PruneChildren: true})
+	return err
+}
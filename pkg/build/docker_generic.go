@@ -3,12 +3,14 @@ package build
 import (
 	"context"
 	"fmt"
+	"io/ioutil"
 	"path"
 	"path/filepath"
 	"reflect"
 	"time"
 
 	"github.com/testground/testground/pkg/api"
+	"github.com/testground/testground/pkg/config"
 	"github.com/testground/testground/pkg/docker"
 	"github.com/testground/testground/pkg/rpc"
 
@@ -17,7 +19,8 @@ import (
 )
 
 var (
-	_ api.Builder = &DockerGenericBuilder{}
+	_ api.Builder           = &DockerGenericBuilder{}
+	_ api.ArtifactCollector = &DockerGenericBuilder{}
 )
 
 type DockerGenericBuilder struct {
@@ -28,6 +31,10 @@ type DockerGenericBuilderConfig struct {
 	// Custom base path where we find the test source
 	Path      string             `toml:"path" default:"./"`
 	BuildArgs map[string]*string `toml:"build_args"` // ok if nil
+
+	// Platform is the platform to build the image for, e.g. "linux/arm64"
+	// (default: the platform of the docker daemon).
+	Platform string `toml:"platform"`
 }
 
 // Build builds a testplan written in Go and outputs a Docker container.
@@ -46,6 +53,9 @@ func (b *DockerGenericBuilder) Build(ctx context.Context, in *api.BuildInput, ow
 	if err != nil {
 		return nil, err
 	}
+	if err := requireLinuxContainers(ctx, cli, b.ID()); err != nil {
+		return nil, err
+	}
 
 	planPath := cfg.Path
 	basePathForPlan := path.Join("/plan", planPath)
@@ -58,11 +68,27 @@ func (b *DockerGenericBuilder) Build(ctx context.Context, in *api.BuildInput, ow
 		cfg.BuildArgs["PLAN_PATH"] = &cfg.Path
 	}
 
+	// Offline builds start from local or mirrored images only, and reach
+	// the network of the host for the Nix binary caches on the LAN.
+	if offline := in.EnvConfig.Daemon.Offline; offline.Enabled {
+		setNixConfig(offline, cfg.BuildArgs)
+
+		dockerfile, err := ioutil.ReadFile(filepath.Join(basesrc, basePathForPlan, "Dockerfile"))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read the Dockerfile of plan %s: %w", in.TestPlan, err)
+		}
+		if err := ensureOfflineImages(ctx, ow, cli, dockerfileBaseImages(string(dockerfile), cfg.BuildArgs)...); err != nil {
+			return nil, err
+		}
+	}
+
 	opts := types.ImageBuildOptions{
 		Tags:        []string{in.BuildID},
+		Labels:      imageLabels(b.ID(), in.TestPlan),
 		BuildArgs:   cfg.BuildArgs,
 		NetworkMode: "host",
 		Dockerfile:  filepath.Join(basePathForPlan, "Dockerfile"),
+		Platform:    cfg.Platform,
 	}
 
 	imageOpts := docker.BuildImageOpts{
@@ -112,3 +138,11 @@ func (*DockerGenericBuilder) ConfigType() reflect.Type {
 func (*DockerGenericBuilder) Purge(ctx context.Context, testplan string, ow *rpc.OutputWriter) error {
 	return fmt.Errorf("purge not implemented for docker:generic")
 }
+
+func (b *DockerGenericBuilder) ListArtifacts(ctx context.Context, _ *config.EnvConfig) ([]*api.Artifact, error) {
+	return listImageArtifacts(ctx, b.ID())
+}
+
+func (*DockerGenericBuilder) RemoveArtifact(ctx context.Context, artifact *api.Artifact) error {
+	return removeImageArtifact(ctx, artifact)
+}
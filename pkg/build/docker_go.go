@@ -14,7 +14,9 @@ import (
 	"time"
 
 	"github.com/testground/testground/pkg/api"
+	"github.com/testground/testground/pkg/config"
 	"github.com/testground/testground/pkg/docker"
+	"github.com/testground/testground/pkg/metrics"
 	"github.com/testground/testground/pkg/rpc"
 
 	"github.com/docker/docker/api/types"
@@ -28,12 +30,16 @@ import (
 const (
 	DefaultGoBuildBaseImage = "golang:1.16-buster"
 
+	// DefaultGoRuntimeImage is the default runtime image of the Dockerfile.
+	DefaultGoRuntimeImage = "busybox:1.35.0-glibc"
+
 	buildNetworkName = "testground-build"
 )
 
 var (
-	_ api.Builder      = &DockerGoBuilder{}
-	_ api.Terminatable = &DockerGoBuilder{}
+	_ api.Builder           = &DockerGoBuilder{}
+	_ api.Terminatable      = &DockerGoBuilder{}
+	_ api.ArtifactCollector = &DockerGoBuilder{}
 
 	goDockerfileTmpl = template.Must(template.New("Dockerfile").Parse(GoDockerfileTemplate))
 )
@@ -113,6 +119,10 @@ type DockerGoBuilderConfig struct {
 
 	// DockefileExtensions enables plans to inject custom Dockerfile directives.
 	DockerfileExtensions DockerfileExtensions `toml:"dockerfile_extensions"`
+
+	// Platform is the platform to build the image for, e.g. "linux/arm64"
+	// (default: the platform of the docker daemon).
+	Platform string `toml:"platform"`
 }
 
 type DockerfileTemplateVars struct {
@@ -121,6 +131,8 @@ type DockerfileTemplateVars struct {
 	DockerfileExtensions DockerfileExtensions
 	SkipRuntimeImage     bool
 	CgoEnabled           int
+	// Offline builds from the vendored modules of the plan, without network.
+	Offline bool
 }
 
 // Build builds a testplan written in Go and outputs a Docker container.
@@ -143,12 +155,30 @@ func (b *DockerGoBuilder) Build(ctx context.Context, in *api.BuildInput, ow *rpc
 	if err != nil {
 		return nil, err
 	}
+	if err := requireLinuxContainers(ctx, cli, b.ID()); err != nil {
+		return nil, err
+	}
 
 	planSrc := filepath.Join(planDir, cfg.Path)
 
+	offline := in.EnvConfig.Daemon.Offline.Enabled
+	if offline {
+		if err := checkOfflineGoBuild(in, planSrc, cfg.FreshGomod); err != nil {
+			return nil, err
+		}
+	}
+
 	// Set up the go proxy wiring. This will start a goproxy container if
-	// necessary, attaching it to the testground-build network.
-	proxyURL, buildNetworkID, warn := b.setupGoProxy(ctx, ow, cli, cfg)
+	// necessary, attaching it to the testground-build network. Offline
+	// builds use no proxy.
+	var (
+		proxyURL       = "off"
+		buildNetworkID string
+		warn           error
+	)
+	if !offline {
+		proxyURL, buildNetworkID, warn = b.setupGoProxy(ctx, ow, cli, cfg)
+	}
 	if warn != nil {
 		ow.Warnf("warning while setting up the go proxy: %s", warn)
 	}
@@ -171,6 +201,7 @@ func (b *DockerGoBuilder) Build(ctx context.Context, in *api.BuildInput, ow *rpc
 		DockerfileExtensions: cfg.DockerfileExtensions,
 		SkipRuntimeImage:     cfg.SkipRuntimeImage,
 		CgoEnabled:           cgoEnabled,
+		Offline:              offline,
 	}
 
 	if err = goDockerfileTmpl.Execute(f, &vars); err != nil {
@@ -262,7 +293,7 @@ func (b *DockerGoBuilder) Build(ctx context.Context, in *api.BuildInput, ow *rpc
 		args["RUNTIME_IMAGE"] = &cfg.RuntimeImage
 	}
 
-	cacheImage := fmt.Sprintf("tg-gobuildcache-%s", in.TestPlan)
+	cacheImage := buildCacheImageName(in.TestPlan, cfg.Platform)
 	baseImage := cfg.BuildBaseImage
 	alreadyCached := false
 
@@ -277,7 +308,12 @@ func (b *DockerGoBuilder) Build(ctx context.Context, in *api.BuildInput, ow *rpc
 		}
 		if alreadyCached {
 			baseImage = cacheImage
+			api.RecordDecision(ctx, api.DecisionStageBuild, "plan %s built on top of the go build cache image %s", in.TestPlan, cacheImage)
+		} else {
+			api.RecordDecision(ctx, api.DecisionStageBuild, "plan %s built without the go build cache: image %s not found; this build seeds it", in.TestPlan, cacheImage)
 		}
+	} else {
+		api.RecordDecision(ctx, api.DecisionStageBuild, "plan %s built without the go build cache: enable_go_build_cache is off", in.TestPlan)
 	}
 
 	args["BUILD_BASE_IMAGE"] = &baseImage
@@ -292,8 +328,10 @@ func (b *DockerGoBuilder) Build(ctx context.Context, in *api.BuildInput, ow *rpc
 	// so the builder can make use of the goproxy container.
 	opts := types.ImageBuildOptions{
 		Tags:        []string{in.BuildID},
+		Labels:      imageLabels(b.ID(), in.TestPlan),
 		BuildArgs:   args,
 		NetworkMode: "host",
+		Platform:    cfg.Platform,
 	}
 
 	// If a docker network was created for the proxy, link it to the build container
@@ -301,6 +339,21 @@ func (b *DockerGoBuilder) Build(ctx context.Context, in *api.BuildInput, ow *rpc
 		opts.NetworkMode = buildNetworkName
 	}
 
+	if offline {
+		opts.NetworkMode = "none"
+
+		runtimeImage := cfg.RuntimeImage
+		if runtimeImage == "" {
+			runtimeImage = DefaultGoRuntimeImage
+		}
+		if cfg.SkipRuntimeImage {
+			runtimeImage = ""
+		}
+		if err := ensureOfflineImages(ctx, ow, cli, baseImage, runtimeImage); err != nil {
+			return nil, err
+		}
+	}
+
 	imageOpts := docker.BuildImageOpts{
 		BuildCtx:  baseSrc,
 		BuildOpts: &opts,
@@ -387,6 +440,14 @@ func (b *DockerGoBuilder) TerminateAll(ctx context.Context, ow *rpc.OutputWriter
 	return merr.ErrorOrNil()
 }
 
+func (b *DockerGoBuilder) ListArtifacts(ctx context.Context, _ *config.EnvConfig) ([]*api.Artifact, error) {
+	return listImageArtifacts(ctx, b.ID())
+}
+
+func (*DockerGoBuilder) RemoveArtifact(ctx context.Context, artifact *api.Artifact) error {
+	return removeImageArtifact(ctx, artifact)
+}
+
 func (*DockerGoBuilder) ID() string {
 	return "docker:go"
 }
@@ -490,10 +551,12 @@ func (b *DockerGoBuilder) hasBuildCacheImage(ctx context.Context, cli *client.Cl
 		return false, err
 	case ok:
 		ow.Infow("build cache image found", "cache_image", cacheImage, "image_id", info.ID)
+		metrics.ImageCache.WithLabelValues(b.ID(), "hit").Inc()
 		return true, nil
 	}
 
 	ow.Infow("build cache image not found", "cache_image", cacheImage)
+	metrics.ImageCache.WithLabelValues(b.ID(), "miss").Inc()
 	return false, nil
 }
 
@@ -558,15 +621,31 @@ func (b *DockerGoBuilder) Purge(ctx context.Context, testplan string, ow *rpc.Ou
 		return err
 	}
 
-	cacheimage := fmt.Sprintf("tg-gobuildcache-%s", testplan)
-	err = b.removeBuildCacheImage(ctx, cli, cacheimage)
-	if err != nil {
-		return err
+	for _, platform := range buildCachePlatforms {
+		cacheimage := buildCacheImageName(testplan, platform)
+		err = b.removeBuildCacheImage(ctx, cli, cacheimage)
+		if err != nil {
+			return err
+		}
+		ow.Infow("removed cached imaged", "image", cacheimage)
 	}
-	ow.Infow("removed cached imaged", "image", cacheimage)
 	return nil
 }
 
+// buildCachePlatforms are the platforms whose build cache images are purged;
+// the empty platform is the default platform of the docker daemon.
+var buildCachePlatforms = []string{"", "linux/amd64", "linux/arm64"}
+
+// buildCacheImageName returns the name of the go build cache image of a plan.
+// Images built for an explicit platform have their own cache, so that they
+// never reuse the dependencies built for another architecture.
+func buildCacheImageName(testplan string, platform string) string {
+	if platform == "" {
+		return fmt.Sprintf("tg-gobuildcache-%s", testplan)
+	}
+	return fmt.Sprintf("tg-gobuildcache-%s-%s", testplan, strings.ReplaceAll(platform, "/", "-"))
+}
+
 const GoDockerfileTemplate = `
 # BUILD_BASE_IMAGE is the base image to use for the build. It contains a rolling
 # accumulation of Go build/package caches.
@@ -580,7 +659,7 @@ ARG BUILD_BASE_IMAGE
 #
 # The user can override the runtime image by passing in the appropriate builder
 # configuration option.
-ARG RUNTIME_IMAGE=busybox:1.35.0-glibc
+ARG RUNTIME_IMAGE=` + DefaultGoRuntimeImage + `
 
 #:::
 #::: BUILD CONTAINER
@@ -630,11 +709,16 @@ COPY /plan/${PLAN_PATH}/${MODFILE_SUM} ${PLAN_DIR}/go.sum
 COPY /sdk/go.mod /sdk/go.mod
 {{end}}
 
+{{if .Offline}}
+# Offline builds use the vendored modules of the plan.
+ENV GOFLAGS -mod=vendor
+{{else}}
 # Download deps.
 RUN echo "Using go proxy: ${GO_PROXY}" \
     && cd ${PLAN_DIR} \
     && go env -w GOPROXY="${GO_PROXY}" \
     && go mod download
+{{end}}
 
 {{.DockerfileExtensions.PostModDownload}}
 
@@ -657,8 +741,13 @@ RUN cd ${PLAN_DIR} \
 {{.DockerfileExtensions.PostBuild}}
 
 # Store module dependencies
+{{if .Offline}}
+RUN cd ${PLAN_DIR} \
+  && sed -n 's/^# \([^ ]*\) \(v[^ ]*\).*/\1 \2/p' vendor/modules.txt > /testground_dep_list
+{{else}}
 RUN cd ${PLAN_DIR} \
   && go list -m all > /testground_dep_list
+{{end}}
 
 #:::
 #::: (OPTIONAL) RUNTIME CONTAINER
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"io/ioutil"
+	"os"
 	"path/filepath"
 	"reflect"
 	"time"
@@ -11,6 +12,7 @@ import (
 	"github.com/docker/docker/api/types"
 	"github.com/docker/docker/client"
 	"github.com/testground/testground/pkg/api"
+	"github.com/testground/testground/pkg/config"
 	"github.com/testground/testground/pkg/docker"
 	"github.com/testground/testground/pkg/rpc"
 )
@@ -20,7 +22,8 @@ const (
 )
 
 var (
-	_ api.Builder = &DockerNodeBuilder{}
+	_ api.Builder           = &DockerNodeBuilder{}
+	_ api.ArtifactCollector = &DockerNodeBuilder{}
 )
 
 type DockerNodeBuilder struct{}
@@ -42,6 +45,9 @@ func (d DockerNodeBuilder) Build(ctx context.Context, in *api.BuildInput, ow *rp
 	if err != nil {
 		return nil, err
 	}
+	if err := requireLinuxContainers(ctx, cli, d.ID()); err != nil {
+		return nil, err
+	}
 
 	// Write the Dockerfile.
 	dockerfileDst := filepath.Join(basesrc, "Dockerfile")
@@ -60,10 +66,25 @@ func (d DockerNodeBuilder) Build(ctx context.Context, in *api.BuildInput, ow *rp
 		"BASE_IMAGE": &cfg.BaseImage,
 	}
 
+	// Offline builds use the node_modules checked in with the plan, rather
+	// than installing them from the registry.
+	if in.EnvConfig.Daemon.Offline.Enabled {
+		if _, err := os.Stat(filepath.Join(in.UnpackedSources.PlanDir, "node_modules")); err != nil {
+			return nil, fmt.Errorf("plan %s has no node_modules, which offline mode builds from; run `npm ci` in the plan, or bundle it with `testground bundle create`", in.TestPlan)
+		}
+		if err := ensureOfflineImages(ctx, ow, cli, cfg.BaseImage); err != nil {
+			return nil, err
+		}
+		offline := "true"
+		args["OFFLINE"] = &offline
+	}
+
 	opts := types.ImageBuildOptions{
 		Tags:        []string{in.BuildID},
+		Labels:      imageLabels(d.ID(), in.TestPlan),
 		BuildArgs:   args,
 		NetworkMode: "host",
+		Platform:    cfg.Platform,
 	}
 
 	imageOpts := docker.BuildImageOpts{
@@ -113,15 +134,28 @@ func (d DockerNodeBuilder) ConfigType() reflect.Type {
 type DockerNodeBuilderConfig struct {
 	Enabled   bool
 	BaseImage string `toml:"base_image"`
+
+	// Platform is the platform to build the image for, e.g. "linux/arm64"
+	// (default: the platform of the docker daemon).
+	Platform string `toml:"platform"`
 }
 
 const NodeDockerfileTemplate = `
 ARG BASE_IMAGE
 FROM ${BASE_IMAGE} AS builder
+ARG OFFLINE
 ENV PLAN_DIR /plan
 WORKDIR /plan
 COPY . /
-RUN npm ci
+RUN if [ -n "${OFFLINE}" ]; then npm rebuild --offline; else npm ci; fi
 EXPOSE 6060
 ENTRYPOINT [ "npm", "start"]
 `
+
+func (d DockerNodeBuilder) ListArtifacts(ctx context.Context, _ *config.EnvConfig) ([]*api.Artifact, error) {
+	return listImageArtifacts(ctx, d.ID())
+}
+
+func (DockerNodeBuilder) RemoveArtifact(ctx context.Context, artifact *api.Artifact) error {
+	return removeImageArtifact(ctx, artifact)
+}
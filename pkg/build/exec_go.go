@@ -7,14 +7,32 @@ import (
 	"os/exec"
 	"path/filepath"
 	"reflect"
+	"runtime"
 	"strings"
 
 	"github.com/testground/testground/pkg/api"
+	"github.com/testground/testground/pkg/config"
 	"github.com/testground/testground/pkg/rpc"
 )
 
+// execGoBinPrefix prefixes the binaries built in the work directory, which
+// are named exec-go--<plan>-<build id>, with the .exe extension on Windows.
+const execGoBinPrefix = "exec-go--"
+
+// execGoBinName returns the name of the binary of a build of a plan. Plans
+// in subdirectories are flattened, as the binaries are in the work directory.
+func execGoBinName(plan, id, goos string) string {
+	plan = strings.NewReplacer("/", "-", `\`, "-").Replace(plan)
+	bin := fmt.Sprintf("%s%s-%s", execGoBinPrefix, plan, id)
+	if goos == "windows" {
+		bin += ".exe"
+	}
+	return bin
+}
+
 var (
-	_ api.Builder = &ExecGoBuilder{}
+	_ api.Builder           = &ExecGoBuilder{}
+	_ api.ArtifactCollector = &ExecGoBuilder{}
 )
 
 // ExecGoBuilder (id: "exec:go") is a builder that compiles the test plan into
@@ -40,10 +58,21 @@ func (b *ExecGoBuilder) Build(ctx context.Context, in *api.BuildInput, ow *rpc.O
 		plansrc = in.UnpackedSources.PlanDir
 		sdksrc  = in.UnpackedSources.SDKDir
 
-		bin  = fmt.Sprintf("exec-go--%s-%s", in.TestPlan, id)
+		bin  = execGoBinName(in.TestPlan, id, runtime.GOOS)
 		path = filepath.Join(in.EnvConfig.Dirs().Work(), bin)
 	)
 
+	if _, err := exec.LookPath("go"); err != nil {
+		return nil, fmt.Errorf("the exec:go builder requires the Go toolchain on the PATH of the daemon: %w", err)
+	}
+
+	offline := in.EnvConfig.Daemon.Offline.Enabled
+	if offline {
+		if err := checkOfflineGoBuild(in, plansrc, cfg.FreshGomod); err != nil {
+			return nil, err
+		}
+	}
+
 	if cfg.FreshGomod {
 		for _, f := range []string{"go.mod", "go.sum"} {
 			file := filepath.Join(plansrc, f)
@@ -88,6 +117,12 @@ func (b *ExecGoBuilder) Build(ctx context.Context, in *api.BuildInput, ow *rpc.O
 		}
 	}
 
+	// Offline builds use the vendored modules of the plan, and never reach
+	// a module proxy.
+	if offline {
+		return b.buildOffline(ctx, in, cfg, path, ow)
+	}
+
 	// go mod tidy
 	cmd := exec.CommandContext(ctx, "go", "mod", "tidy")
 	cmd.Dir = plansrc
@@ -96,17 +131,8 @@ func (b *ExecGoBuilder) Build(ctx context.Context, in *api.BuildInput, ow *rpc.O
 		return nil, fmt.Errorf("unable to go mod tidy in build; %w; output: %s", err, string(out))
 	}
 
-	// Calculate the arguments to go build.
-	// go build -o <output_path> [-tags <comma-separated tags>] <exec_pkg>
-	var args = []string{"build", "-gcflags=all=-N -l", "-o", path}
-	if len(in.Selectors) > 0 {
-		args = append(args, "-tags")
-		args = append(args, strings.Join(in.Selectors, ","))
-	}
-	args = append(args, cfg.ExecPkg)
-
 	// Execute the build.
-	cmd = exec.CommandContext(ctx, "go", args...)
+	cmd = exec.CommandContext(ctx, "go", goBuildArgs(in, cfg, path)...)
 	cmd.Dir = plansrc
 	out, err := cmd.CombinedOutput()
 	if err != nil {
@@ -124,9 +150,58 @@ func (b *ExecGoBuilder) Build(ctx context.Context, in *api.BuildInput, ow *rpc.O
 	return &api.BuildOutput{
 		ArtifactPath: path,
 		Dependencies: parseDependencies(string(out)),
+		Files:        execGoFiles(path, plansrc),
 	}, nil
 }
 
+// buildOffline builds a plan from its vendored modules.
+func (*ExecGoBuilder) buildOffline(ctx context.Context, in *api.BuildInput, cfg *ExecGoBuilderConfig, path string, ow *rpc.OutputWriter) (*api.BuildOutput, error) {
+	plansrc := in.UnpackedSources.PlanDir
+
+	cmd := exec.CommandContext(ctx, "go", goBuildArgs(in, cfg, path)...)
+	cmd.Dir = plansrc
+	cmd.Env = append(os.Environ(), "GOFLAGS=-mod=vendor", "GOPROXY=off")
+	if out, err := cmd.CombinedOutput(); err != nil {
+		ow.Errorf("go build failed: %s", string(out))
+		return nil, fmt.Errorf("failed to run the build; %w", err)
+	}
+
+	deps, err := vendoredDependencies(plansrc)
+	if err != nil {
+		return nil, fmt.Errorf("unable to list module dependencies; %w", err)
+	}
+	return &api.BuildOutput{
+		ArtifactPath: path,
+		Dependencies: deps,
+		Files:        execGoFiles(path, plansrc),
+	}, nil
+}
+
+// execGoFiles returns the files of a build: the binary, and the go.mod and
+// go.sum the plan was built with, as the build may have generated or edited
+// them.
+func execGoFiles(bin, plansrc string) []api.BuildFile {
+	files := []api.BuildFile{{Name: filepath.Base(bin), Path: bin}}
+	for _, f := range []string{"go.mod", "go.sum"} {
+		path := filepath.Join(plansrc, f)
+		if _, err := os.Stat(path); err == nil {
+			files = append(files, api.BuildFile{Name: f, Path: path})
+		}
+	}
+	return files
+}
+
+// goBuildArgs returns the arguments to go build:
+// go build -o <output_path> [-tags <comma-separated tags>] <exec_pkg>
+func goBuildArgs(in *api.BuildInput, cfg *ExecGoBuilderConfig, path string) []string {
+	var args = []string{"build", "-gcflags=all=-N -l", "-o", path}
+	if len(in.Selectors) > 0 {
+		args = append(args, "-tags")
+		args = append(args, strings.Join(in.Selectors, ","))
+	}
+	return append(args, cfg.ExecPkg)
+}
+
 func (*ExecGoBuilder) ID() string {
 	return "exec:go"
 }
@@ -138,3 +213,34 @@ func (*ExecGoBuilder) ConfigType() reflect.Type {
 func (*ExecGoBuilder) Purge(ctx context.Context, testplan string, ow *rpc.OutputWriter) error {
 	return fmt.Errorf("purge not implemented for exec:go")
 }
+
+func (*ExecGoBuilder) ListArtifacts(_ context.Context, env *config.EnvConfig) ([]*api.Artifact, error) {
+	paths, err := filepath.Glob(filepath.Join(env.Dirs().Work(), execGoBinPrefix+"*"))
+	if err != nil {
+		return nil, err
+	}
+
+	artifacts := make([]*api.Artifact, 0, len(paths))
+	for _, p := range paths {
+		fi, err := os.Stat(p)
+		if err != nil || fi.IsDir() {
+			continue
+		}
+		// Strip the prefix and the -<build id> suffix to get the plan.
+		plan := strings.TrimSuffix(strings.TrimPrefix(fi.Name(), execGoBinPrefix), ".exe")
+		if i := strings.LastIndex(plan, "-"); i > 0 {
+			plan = plan[:i]
+		}
+		artifacts = append(artifacts, &api.Artifact{
+			ID:      p,
+			Plan:    plan,
+			Created: fi.ModTime(),
+			Size:    fi.Size(),
+		})
+	}
+	return artifacts, nil
+}
+
+func (*ExecGoBuilder) RemoveArtifact(_ context.Context, artifact *api.Artifact) error {
+	return os.Remove(artifact.ID)
+}
@@ -0,0 +1,15 @@
+package build
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestExecGoBinName(t *testing.T) {
+	require.Equal(t, "exec-go--network-c3ft", execGoBinName("network", "c3ft", "linux"))
+	require.Equal(t, "exec-go--network-c3ft", execGoBinName("network", "c3ft", "darwin"))
+	require.Equal(t, "exec-go--network-c3ft.exe", execGoBinName("network", "c3ft", "windows"))
+	require.Equal(t, "exec-go--benchmarks-dht-c3ft.exe", execGoBinName(`benchmarks\dht`, "c3ft", "windows"))
+	require.Equal(t, "exec-go--benchmarks-dht-c3ft", execGoBinName("benchmarks/dht", "c3ft", "linux"))
+}
@@ -0,0 +1,137 @@
+package build
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/docker/docker/client"
+
+	"github.com/testground/testground/pkg/api"
+	"github.com/testground/testground/pkg/config"
+	"github.com/testground/testground/pkg/docker"
+	"github.com/testground/testground/pkg/rpc"
+)
+
+// checkOfflineGoBuild checks that a Go plan can be built in offline mode:
+// from its vendored modules, with none of the replace directives that would
+// make them inconsistent.
+func checkOfflineGoBuild(in *api.BuildInput, planDir string, freshGomod bool) error {
+	switch {
+	case freshGomod:
+		return fmt.Errorf("fresh_gomod is not supported in offline mode")
+	case len(in.Dependencies) > 0:
+		return fmt.Errorf("dependency overrides are not supported in offline mode; vendor them into plan %s instead", in.TestPlan)
+	case in.UnpackedSources.SDKDir != "":
+		return fmt.Errorf("linked sdks are not supported in offline mode; vendor the sdk into plan %s instead", in.TestPlan)
+	}
+	if _, err := os.Stat(filepath.Join(planDir, "vendor", "modules.txt")); err != nil {
+		return fmt.Errorf("plan %s has no vendored modules, which offline mode builds from; run `go mod vendor` in the plan, or bundle it with `testground bundle create`", in.TestPlan)
+	}
+	return nil
+}
+
+// vendoredDependencies returns the modules vendored in a plan, as listed in
+// its vendor/modules.txt, and the versions they are vendored at.
+func vendoredDependencies(planDir string) (map[string]string, error) {
+	b, err := ioutil.ReadFile(filepath.Join(planDir, "vendor", "modules.txt"))
+	if err != nil {
+		return nil, err
+	}
+
+	deps := make(map[string]string)
+	scanner := bufio.NewScanner(strings.NewReader(string(b)))
+	for scanner.Scan() {
+		// # github.com/testground/sdk-go v0.3.0 [=> replacement]; modules
+		// replaced by directories have no version.
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 3 || fields[0] != "#" || !strings.HasPrefix(fields[2], "v") {
+			continue
+		}
+		deps[fields[1]] = fields[2]
+	}
+	return deps, scanner.Err()
+}
+
+// ensureOfflineImages makes sure the images a docker build starts from are
+// present locally in offline mode, as the build would otherwise try to pull
+// them from their origin.
+func ensureOfflineImages(ctx context.Context, ow *rpc.OutputWriter, cli *client.Client, images ...string) error {
+	for _, image := range images {
+		if image == "" || image == "scratch" {
+			continue
+		}
+		if err := docker.PullImage(ctx, ow, cli, image); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// setNixConfig passes the Nix binary caches of the offline config on to a
+// docker build, as the NIX_CONFIG build arg, unless the build sets it.
+func setNixConfig(cfg config.OfflineConfig, args map[string]*string) {
+	conf := cfg.NixConfig()
+	if _, ok := args["NIX_CONFIG"]; ok || conf == "" {
+		return
+	}
+	args["NIX_CONFIG"] = &conf
+}
+
+// dockerfileBaseImages returns the images the stages of a Dockerfile start
+// from, expanding the build args they reference. Stages that start from
+// earlier stages are skipped.
+func dockerfileBaseImages(dockerfile string, args map[string]*string) []string {
+	var (
+		vars     = make(map[string]string)
+		stages   = make(map[string]bool)
+		images   []string
+		seenFrom bool
+	)
+	for k, v := range args {
+		if v != nil {
+			vars[k] = *v
+		}
+	}
+
+	scanner := bufio.NewScanner(strings.NewReader(dockerfile))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 {
+			continue
+		}
+		switch strings.ToUpper(fields[0]) {
+		case "ARG":
+			// Only the args declared before the first stage are in scope of
+			// the FROM instructions.
+			if seenFrom {
+				continue
+			}
+			kv := strings.SplitN(fields[1], "=", 2)
+			if _, ok := vars[kv[0]]; !ok && len(kv) == 2 {
+				vars[kv[0]] = strings.Trim(kv[1], `"`)
+			}
+		case "FROM":
+			rest := fields[1:]
+			for len(rest) > 0 && strings.HasPrefix(rest[0], "--") {
+				rest = rest[1:]
+			}
+			if len(rest) == 0 {
+				continue
+			}
+			seenFrom = true
+			image := os.Expand(rest[0], func(k string) string { return vars[k] })
+			if !stages[image] {
+				images = append(images, image)
+			}
+			if len(rest) == 3 && strings.EqualFold(rest[1], "AS") {
+				stages[rest[2]] = true
+			}
+		}
+	}
+	return images
+}
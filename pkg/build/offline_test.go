@@ -0,0 +1,48 @@
+package build
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/testground/testground/pkg/api"
+)
+
+func TestDockerfileBaseImages(t *testing.T) {
+	dockerfile := `
+ARG BASE=golang:1.16
+ARG RUNTIME
+FROM ${BASE} AS builder
+ARG BASE=ignored
+RUN go build
+FROM --platform=linux/amd64 $RUNTIME AS runtime
+FROM builder
+FROM scratch
+`
+	runtime := "busybox"
+	images := dockerfileBaseImages(dockerfile, map[string]*string{"RUNTIME": &runtime})
+	require.Equal(t, []string{"golang:1.16", "busybox", "scratch"}, images)
+}
+
+func TestCheckOfflineGoBuild(t *testing.T) {
+	dir := t.TempDir()
+	in := &api.BuildInput{TestPlan: "plan", UnpackedSources: &api.UnpackedSources{PlanDir: dir}}
+
+	require.Error(t, checkOfflineGoBuild(in, dir, false), "no vendored modules")
+
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "vendor"), 0755))
+	modules := "# github.com/testground/sdk-go v0.3.0\n## explicit\ngithub.com/testground/sdk-go/runtime\n# github.com/local/mod => ../mod\n"
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "vendor", "modules.txt"), []byte(modules), 0644))
+	require.NoError(t, checkOfflineGoBuild(in, dir, false))
+	require.Error(t, checkOfflineGoBuild(in, dir, true), "fresh go.mod")
+
+	in.Dependencies = map[string]api.DependencyTarget{"github.com/ipfs/go-ipfs": {Version: "v0.4.22"}}
+	require.Error(t, checkOfflineGoBuild(in, dir, false), "dependency overrides")
+
+	deps, err := vendoredDependencies(dir)
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{"github.com/testground/sdk-go": "v0.3.0"}, deps)
+}
@@ -0,0 +1,28 @@
+package build
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+
+	"github.com/docker/docker/client"
+)
+
+// requireLinuxContainers checks that Docker is reachable, and runs Linux
+// containers, which the images of the docker builders are. Hosts such as
+// macOS or Windows without Docker build test plans with exec:go instead, to
+// run them with local:exec.
+func requireLinuxContainers(ctx context.Context, cli *client.Client, builder string) error {
+	info, err := cli.Info(ctx)
+	return linuxContainersError(builder, info.OSType, err)
+}
+
+func linuxContainersError(builder, osType string, err error) error {
+	switch {
+	case err != nil:
+		return fmt.Errorf("the %s builder requires Docker, which is not reachable: %w; build with exec:go to run with local:exec on %s without Docker", builder, err, runtime.GOOS)
+	case osType != "" && osType != "linux":
+		return fmt.Errorf("the %s builder builds Linux images, but Docker runs %s containers; switch Docker to Linux containers, or build with exec:go to run with local:exec", builder, osType)
+	}
+	return nil
+}
@@ -0,0 +1,21 @@
+package build
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLinuxContainersError(t *testing.T) {
+	require.NoError(t, linuxContainersError("docker:go", "linux", nil))
+
+	err := linuxContainersError("docker:go", "windows", nil)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "runs windows containers")
+
+	err = linuxContainersError("docker:node", "", errors.New("cannot connect to the Docker daemon"))
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "the docker:node builder requires Docker")
+	require.Contains(t, err.Error(), "exec:go")
+}
@@ -0,0 +1,330 @@
+// Package bundle packages what runs need, i.e. plans with their vendored
+// dependencies, compositions and images, into a single archive, to transfer
+// them into an air-gapped environment where the daemon runs in offline mode;
+// and loads such archives there.
+package bundle
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/client"
+	"github.com/docker/docker/pkg/archive"
+	"github.com/otiai10/copy"
+
+	"github.com/testground/testground/pkg/build"
+	"github.com/testground/testground/pkg/docker"
+	"github.com/testground/testground/pkg/rpc"
+	"github.com/testground/testground/pkg/runner"
+)
+
+// Version is the version of the layout of bundles.
+const Version = 1
+
+const (
+	manifestFile    = "bundle.json"
+	plansDir        = "plans"
+	compositionsDir = "compositions"
+	imagesFile      = "images.tar"
+)
+
+// DefaultImages are the images of the infrastructure of the local runners,
+// and the base images of the builders.
+var DefaultImages = []string{
+	"library/redis",
+	"iptestground/sync-service:edge",
+	"iptestground/sidecar:edge",
+	"library/influxdb:1.8",
+	runner.DefaultGrafanaImage,
+	build.DefaultGoBuildBaseImage,
+	build.DefaultGoRuntimeImage,
+	build.DefaultNodeBuildBaseImage,
+}
+
+// Manifest describes the contents of a bundle.
+type Manifest struct {
+	Version      int       `json:"version"`
+	Created      time.Time `json:"created"`
+	Plans        []string  `json:"plans"`
+	Compositions []string  `json:"compositions,omitempty"`
+	Images       []string  `json:"images,omitempty"`
+}
+
+// CreateOptions are the contents of a bundle.
+type CreateOptions struct {
+	// Plans are the directories of the plans to bundle, by name.
+	Plans map[string]string
+	// Compositions are the compositions to bundle, by file name.
+	Compositions map[string][]byte
+	// Images are the images to bundle; they are pulled if missing.
+	Images []string
+}
+
+// Create writes a bundle to w. The Go modules and node_modules of the plans
+// that don't have them are vendored into the bundle, which requires the Go
+// toolchain, or npm, and network access.
+func Create(ctx context.Context, ow *rpc.OutputWriter, cli *client.Client, opts *CreateOptions, w io.Writer) (*Manifest, error) {
+	staging, err := ioutil.TempDir("", "testground-bundle")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(staging)
+
+	m := &Manifest{Version: Version, Created: time.Now().UTC()}
+
+	for name, dir := range opts.Plans {
+		dst := filepath.Join(staging, plansDir, name)
+		if err := copyPlan(ctx, ow, dir, dst); err != nil {
+			return nil, fmt.Errorf("failed to bundle plan %s: %w", name, err)
+		}
+		m.Plans = append(m.Plans, name)
+	}
+	sort.Strings(m.Plans)
+
+	if len(opts.Compositions) > 0 {
+		if err := os.MkdirAll(filepath.Join(staging, compositionsDir), 0755); err != nil {
+			return nil, err
+		}
+	}
+	// Compositions are bundled by file name, which must be unique.
+	bundled := make(map[string]string, len(opts.Compositions))
+	for name, src := range opts.Compositions {
+		base := filepath.Base(name)
+		if other, ok := bundled[base]; ok {
+			return nil, fmt.Errorf("compositions %s and %s have the same file name", other, name)
+		}
+		bundled[base] = name
+		if err := ioutil.WriteFile(filepath.Join(staging, compositionsDir, base), src, 0644); err != nil {
+			return nil, fmt.Errorf("failed to bundle composition %s: %w", name, err)
+		}
+		m.Compositions = append(m.Compositions, base)
+	}
+	sort.Strings(m.Compositions)
+
+	if len(opts.Images) > 0 {
+		if err := saveImages(ctx, ow, cli, opts.Images, filepath.Join(staging, imagesFile)); err != nil {
+			return nil, err
+		}
+		m.Images = opts.Images
+	}
+
+	b, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err := ioutil.WriteFile(filepath.Join(staging, manifestFile), b, 0644); err != nil {
+		return nil, err
+	}
+
+	rc, err := archive.TarWithOptions(staging, &archive.TarOptions{Compression: archive.Gzip})
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+
+	if _, err := io.Copy(w, rc); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// copyPlan copies a plan into a bundle, vendoring its dependencies if they
+// aren't. Modules are vendored in place, as the replace directives of the plan
+// may point to directories relative to it, and removed once copied.
+func copyPlan(ctx context.Context, ow *rpc.OutputWriter, src, dst string) error {
+	for _, v := range []struct {
+		marker, dir string
+		cmd         []string
+	}{
+		{"go.mod", "vendor", []string{"go", "mod", "vendor"}},
+		{"package.json", "node_modules", []string{"npm", "ci"}},
+	} {
+		if !exists(filepath.Join(src, v.marker)) || exists(filepath.Join(src, v.dir)) {
+			continue
+		}
+
+		ow.Infow("vendoring the dependencies of the plan", "plan", src, "cmd", v.cmd)
+		cmd := exec.CommandContext(ctx, v.cmd[0], v.cmd[1:]...)
+		cmd.Dir = src
+		if out, err := cmd.CombinedOutput(); err != nil {
+			return fmt.Errorf("%v failed: %w; output: %s", v.cmd, err, out)
+		}
+		defer os.RemoveAll(filepath.Join(src, v.dir))
+	}
+	return copy.Copy(src, dst)
+}
+
+// saveImages saves images, pulling those missing locally, into a tarball.
+func saveImages(ctx context.Context, ow *rpc.OutputWriter, cli *client.Client, images []string, path string) error {
+	for _, image := range images {
+		if _, _, err := cli.ImageInspectWithRaw(ctx, image); err == nil {
+			continue
+		}
+		ow.Infow("pulling image", "image", image)
+		if err := docker.PullImage(ctx, ow, cli, image); err != nil {
+			return fmt.Errorf("failed to pull image %s: %w", image, err)
+		}
+	}
+
+	rc, err := cli.ImageSave(ctx, images)
+	if err != nil {
+		return fmt.Errorf("failed to save the images: %w", err)
+	}
+	defer rc.Close()
+
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if _, err := io.Copy(f, rc); err != nil {
+		return fmt.Errorf("failed to save the images: %w", err)
+	}
+	return f.Close()
+}
+
+// LoadOptions are where the contents of a bundle are loaded.
+type LoadOptions struct {
+	// PlansDir is the directory plans are copied into.
+	PlansDir string
+	// CompositionsDir is the directory compositions are copied into.
+	CompositionsDir string
+	// Replace replaces the plans and compositions that exist already.
+	Replace bool
+	// Registry, if set, is a mirror registry the images are pushed to, as
+	// the daemon pulls them in offline mode.
+	Registry string
+}
+
+// Load loads a bundle read from r: it copies its plans and compositions,
+// and loads its images into the Docker daemon.
+func Load(ctx context.Context, ow *rpc.OutputWriter, cli *client.Client, opts *LoadOptions, r io.Reader) (*Manifest, error) {
+	staging, err := ioutil.TempDir("", "testground-bundle")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(staging)
+
+	if err := archive.Untar(r, staging, &archive.TarOptions{NoLchown: true}); err != nil {
+		return nil, fmt.Errorf("failed to extract the bundle: %w", err)
+	}
+
+	b, err := ioutil.ReadFile(filepath.Join(staging, manifestFile))
+	if err != nil {
+		return nil, fmt.Errorf("not a bundle: %w", err)
+	}
+	var m Manifest
+	if err := json.Unmarshal(b, &m); err != nil {
+		return nil, fmt.Errorf("invalid bundle manifest: %w", err)
+	}
+	if m.Version != Version {
+		return nil, fmt.Errorf("unsupported bundle version %d; expected %d", m.Version, Version)
+	}
+	// The names are those of directories and files of the bundle, which are
+	// copied, or replaced, under the plans and the compositions directories.
+	for _, name := range append(append([]string(nil), m.Plans...), m.Compositions...) {
+		if !isName(name) {
+			return nil, fmt.Errorf("invalid bundle manifest: %q is not a plan or composition name", name)
+		}
+	}
+
+	for _, name := range m.Plans {
+		if err := place(filepath.Join(staging, plansDir, name), filepath.Join(opts.PlansDir, name), opts.Replace); err != nil {
+			return nil, fmt.Errorf("failed to load plan %s: %w", name, err)
+		}
+		ow.Infow("loaded plan", "plan", name)
+	}
+	for _, name := range m.Compositions {
+		if err := place(filepath.Join(staging, compositionsDir, name), filepath.Join(opts.CompositionsDir, name), opts.Replace); err != nil {
+			return nil, fmt.Errorf("failed to load composition %s: %w", name, err)
+		}
+		ow.Infow("loaded composition", "composition", name)
+	}
+
+	if len(m.Images) == 0 {
+		return &m, nil
+	}
+	if err := loadImages(ctx, ow, cli, filepath.Join(staging, imagesFile)); err != nil {
+		return nil, err
+	}
+	if opts.Registry != "" {
+		for _, image := range m.Images {
+			if err := pushImage(ctx, ow, cli, opts.Registry, image); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return &m, nil
+}
+
+// isName returns whether name is a single path element, that can't escape
+// the directory it's joined to.
+func isName(name string) bool {
+	return name != "" && name != "." && name != ".." && name == filepath.Base(name)
+}
+
+// place copies a file or directory of a bundle to its destination.
+func place(src, dst string, replace bool) error {
+	if exists(dst) {
+		if !replace {
+			return fmt.Errorf("%s exists already", dst)
+		}
+		if err := os.RemoveAll(dst); err != nil {
+			return err
+		}
+	}
+	return copy.Copy(src, dst)
+}
+
+func loadImages(ctx context.Context, ow *rpc.OutputWriter, cli *client.Client, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	resp, err := cli.ImageLoad(ctx, f, true)
+	if err != nil {
+		return fmt.Errorf("failed to load the images: %w", err)
+	}
+	defer resp.Body.Close()
+
+	_, err = docker.PipeOutput(resp.Body, ow.StdoutWriter())
+	return err
+}
+
+// pushImage pushes an image to a mirror registry, at the reference the
+// daemon pulls it from in offline mode.
+func pushImage(ctx context.Context, ow *rpc.OutputWriter, cli *client.Client, registry, image string) error {
+	mirror := docker.MirrorRef(registry, image)
+	if err := cli.ImageTag(ctx, image, mirror); err != nil {
+		return err
+	}
+
+	ow.Infow("pushing image", "image", image, "mirror", mirror)
+	// Registries on the LAN usually need no credentials; the auth header is
+	// required all the same.
+	rc, err := cli.ImagePush(ctx, mirror, types.ImagePushOptions{RegistryAuth: "e30="})
+	if err != nil {
+		return fmt.Errorf("failed to push image %s: %w", mirror, err)
+	}
+	defer rc.Close()
+
+	_, err = docker.PipeOutput(rc, ow.StdoutWriter())
+	return err
+}
+
+func exists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
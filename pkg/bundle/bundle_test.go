@@ -0,0 +1,89 @@
+package bundle
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/testground/testground/pkg/rpc"
+)
+
+func TestCreateLoad(t *testing.T) {
+	src := t.TempDir()
+	plan := filepath.Join(src, "plan")
+	require.NoError(t, os.MkdirAll(filepath.Join(plan, "vendor"), 0755))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(plan, "go.mod"), []byte("module plan\n"), 0644))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(plan, "vendor", "modules.txt"), []byte("# github.com/testground/sdk-go v0.3.0\n"), 0644))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(plan, "manifest.toml"), []byte("name = \"plan\"\n"), 0644))
+
+	ctx := context.Background()
+	var buf bytes.Buffer
+	m, err := Create(ctx, rpc.Discard(), nil, &CreateOptions{
+		Plans:        map[string]string{"plan": plan},
+		Compositions: map[string][]byte{"run.toml": []byte("[global]\nplan = \"plan\"\n")},
+	}, &buf)
+	require.NoError(t, err)
+	require.Equal(t, []string{"plan"}, m.Plans)
+	require.Equal(t, []string{"run.toml"}, m.Compositions)
+
+	dst := t.TempDir()
+	opts := &LoadOptions{PlansDir: filepath.Join(dst, "plans"), CompositionsDir: dst}
+	bundle := buf.Bytes()
+
+	loaded, err := Load(ctx, rpc.Discard(), nil, opts, bytes.NewReader(bundle))
+	require.NoError(t, err)
+	require.Equal(t, m.Plans, loaded.Plans)
+	require.FileExists(t, filepath.Join(dst, "plans", "plan", "vendor", "modules.txt"))
+	require.FileExists(t, filepath.Join(dst, "run.toml"))
+
+	// Plans aren't replaced, unless asked to.
+	_, err = Load(ctx, rpc.Discard(), nil, opts, bytes.NewReader(bundle))
+	require.Error(t, err)
+
+	opts.Replace = true
+	_, err = Load(ctx, rpc.Discard(), nil, opts, bytes.NewReader(bundle))
+	require.NoError(t, err)
+}
+
+func TestCreateSameCompositionName(t *testing.T) {
+	_, err := Create(context.Background(), rpc.Discard(), nil, &CreateOptions{
+		Compositions: map[string][]byte{
+			"ping/run.toml": []byte("[global]\nplan = \"ping\"\n"),
+			"pong/run.toml": []byte("[global]\nplan = \"pong\"\n"),
+		},
+	}, ioutil.Discard)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "have the same file name")
+}
+
+func TestLoadNotABundle(t *testing.T) {
+	_, err := Load(context.Background(), rpc.Discard(), nil, &LoadOptions{}, bytes.NewReader([]byte("nope")))
+	require.Error(t, err)
+}
+
+func TestLoadRejectsEscapes(t *testing.T) {
+	for _, manifest := range []string{
+		`{"version": 1, "plans": ["../../victim"]}`,
+		`{"version": 1, "plans": [".."]}`,
+		`{"version": 1, "compositions": ["a/../../run.toml"]}`,
+	} {
+		var buf bytes.Buffer
+		tw := tar.NewWriter(&buf)
+		require.NoError(t, tw.WriteHeader(&tar.Header{Name: manifestFile, Mode: 0644, Size: int64(len(manifest))}))
+		_, err := tw.Write([]byte(manifest))
+		require.NoError(t, err)
+		require.NoError(t, tw.Close())
+
+		dst := t.TempDir()
+		opts := &LoadOptions{PlansDir: filepath.Join(dst, "plans"), CompositionsDir: dst, Replace: true}
+		_, err = Load(context.Background(), rpc.Discard(), nil, opts, &buf)
+		require.Error(t, err, manifest)
+		require.Contains(t, err.Error(), "is not a plan or composition name", manifest)
+	}
+}
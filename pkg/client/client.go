@@ -14,6 +14,7 @@ import (
 	"net/http"
 	"net/textproto"
 	"os"
+	"os/exec"
 	"path/filepath"
 	"strings"
 	"sync"
@@ -28,6 +29,7 @@ import (
 	"github.com/testground/testground/pkg/config"
 	"github.com/testground/testground/pkg/logging"
 	"github.com/testground/testground/pkg/rpc"
+	"github.com/testground/testground/pkg/tracing"
 
 	"github.com/mholt/archiver"
 	"github.com/mitchellh/mapstructure"
@@ -40,6 +42,13 @@ type Client struct {
 	client   *http.Client
 	cfg      *config.EnvConfig
 	endpoint string
+
+	tokenOnce sync.Once
+	tokenVal  string
+	tokenErr  error
+
+	// err is the error the client was configured with, if any.
+	err error
 }
 
 // New initializes a new API client
@@ -48,11 +57,45 @@ func New(cfg *config.EnvConfig) *Client {
 
 	logging.S().Infow("testground client initialized", "addr", endpoint)
 
-	return &Client{
+	c := &Client{
 		client:   &http.Client{},
 		cfg:      cfg,
 		endpoint: endpoint,
 	}
+
+	// Errors in the TLS configuration are reported by the first request.
+	tlscfg, err := clientTLSConfig(cfg.Client.TLS)
+	if err != nil {
+		c.err = err
+	} else if tlscfg != nil {
+		t := http.DefaultTransport.(*http.Transport).Clone()
+		t.TLSClientConfig = tlscfg
+		c.client.Transport = t
+	}
+
+	return c
+}
+
+// token returns the token to authenticate with. The token command of the
+// client config, if set, is only run on the first request.
+func (c *Client) token(ctx context.Context) (string, error) {
+	cmd := strings.TrimSpace(c.cfg.Client.TokenCommand)
+	if cmd == "" {
+		return strings.TrimSpace(c.cfg.Client.Token), nil
+	}
+
+	c.tokenOnce.Do(func() {
+		var stderr bytes.Buffer
+		command := exec.CommandContext(ctx, "sh", "-c", cmd)
+		command.Stderr = &stderr
+		out, err := command.Output()
+		if err != nil {
+			c.tokenErr = fmt.Errorf("token command failed: %w: %s", err, strings.TrimSpace(stderr.String()))
+			return
+		}
+		c.tokenVal = strings.TrimSpace(string(out))
+	})
+	return c.tokenVal, c.tokenErr
 }
 
 // Close the transport used by the client
@@ -152,7 +195,10 @@ func (c *Client) runBuild(ctx context.Context, r interface{}, path, plandir, sdk
 		mp     = multipart.NewWriter(wr)
 	)
 
-	go func() error {
+	go func() (err error) {
+		_, span := tracing.Start(ctx, "pack sources")
+		defer func() { tracing.End(span, err) }()
+
 		var (
 			hcomp  = make(textproto.MIMEHeader) // composition
 			hplan  = make(textproto.MIMEHeader) // plan source
@@ -295,6 +341,20 @@ func (c *Client) CollectOutputs(ctx context.Context, r *api.OutputsRequest) (io.
 	return c.request(ctx, "POST", "/outputs", bytes.NewReader(body.Bytes()))
 }
 
+// CollectBuild sends a `build/collect` request to the daemon.
+//
+// The Body in the response implement an io.ReadCloser and it's up to the caller
+// to close it.
+func (c *Client) CollectBuild(ctx context.Context, r *api.BuildCollectRequest) (io.ReadCloser, error) {
+	var body bytes.Buffer
+	err := json.NewEncoder(&body).Encode(r)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.request(ctx, "POST", "/build/collect", bytes.NewReader(body.Bytes()))
+}
+
 // Terminate sends a `terminate` request to the daemon.
 func (c *Client) Terminate(ctx context.Context, r *api.TerminateRequest) (io.ReadCloser, error) {
 	var body bytes.Buffer
@@ -317,6 +377,88 @@ func (c *Client) Healthcheck(ctx context.Context, r *api.HealthcheckRequest) (io
 	return c.request(ctx, "POST", "/healthcheck", bytes.NewReader(body.Bytes()))
 }
 
+// PushParam sends a `param` request to the daemon.
+func (c *Client) PushParam(ctx context.Context, r *api.ParamPushRequest) (io.ReadCloser, error) {
+	var body bytes.Buffer
+	err := json.NewEncoder(&body).Encode(r)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.request(ctx, "POST", "/param", bytes.NewReader(body.Bytes()))
+}
+
+// InjectNetworkFault sends a `network` request to the daemon.
+func (c *Client) InjectNetworkFault(ctx context.Context, r *api.NetworkFaultRequest) (io.ReadCloser, error) {
+	var body bytes.Buffer
+	err := json.NewEncoder(&body).Encode(r)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.request(ctx, "POST", "/network", bytes.NewReader(body.Bytes()))
+}
+
+// Scale sends a `scale` request to the daemon.
+func (c *Client) Scale(ctx context.Context, r *api.ScaleRequest) (io.ReadCloser, error) {
+	var body bytes.Buffer
+	err := json.NewEncoder(&body).Encode(r)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.request(ctx, "POST", "/scale", bytes.NewReader(body.Bytes()))
+}
+
+// Explain sends an `explain` request to the daemon.
+func (c *Client) Explain(ctx context.Context, r *api.ExplainRequest) (io.ReadCloser, error) {
+	var body bytes.Buffer
+	err := json.NewEncoder(&body).Encode(r)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.request(ctx, "POST", "/explain", bytes.NewReader(body.Bytes()))
+}
+
+// GC sends a `gc` request to the daemon.
+func (c *Client) GC(ctx context.Context, r *api.GCRequest) (io.ReadCloser, error) {
+	var body bytes.Buffer
+	err := json.NewEncoder(&body).Encode(r)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.request(ctx, "POST", "/gc", bytes.NewReader(body.Bytes()))
+}
+
+// CreateToken sends a `token/create` request to the daemon.
+func (c *Client) CreateToken(ctx context.Context, r *api.TokenCreateRequest) (io.ReadCloser, error) {
+	var body bytes.Buffer
+	err := json.NewEncoder(&body).Encode(r)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.request(ctx, "POST", "/token/create", bytes.NewReader(body.Bytes()))
+}
+
+// ListTokens sends a `token/list` request to the daemon.
+func (c *Client) ListTokens(ctx context.Context) (io.ReadCloser, error) {
+	return c.request(ctx, "POST", "/token/list", nil)
+}
+
+// RevokeToken sends a `token/revoke` request to the daemon.
+func (c *Client) RevokeToken(ctx context.Context, r *api.TokenRevokeRequest) (io.ReadCloser, error) {
+	var body bytes.Buffer
+	err := json.NewEncoder(&body).Encode(r)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.request(ctx, "POST", "/token/revoke", bytes.NewReader(body.Bytes()))
+}
+
 // BuildPurge sends a `build/purge` request to the daemon.
 func (c *Client) BuildPurge(ctx context.Context, r *api.BuildPurgeRequest) (io.ReadCloser, error) {
 	var body bytes.Buffer
@@ -348,6 +490,7 @@ func (c *Client) Status(ctx context.Context, r *api.StatusRequest) (io.ReadClose
 	return c.request(ctx, "POST", "/status", bytes.NewReader(body.Bytes()))
 }
 
+// Cancel sends a `cancel` request to the daemon.
 func (c *Client) Cancel(ctx context.Context, r *api.CancelRequest) (io.ReadCloser, error) {
 	var body bytes.Buffer
 	err := json.NewEncoder(&body).Encode(r)
@@ -358,6 +501,29 @@ func (c *Client) Cancel(ctx context.Context, r *api.CancelRequest) (io.ReadClose
 	return c.request(ctx, "POST", "/cancel", bytes.NewReader(body.Bytes()))
 }
 
+// Drain sends a `drain` request to the daemon. The response completes once the
+// tasks being processed have completed.
+func (c *Client) Drain(ctx context.Context, r *api.DrainRequest) (io.ReadCloser, error) {
+	var body bytes.Buffer
+	err := json.NewEncoder(&body).Encode(r)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.request(ctx, "POST", "/drain", bytes.NewReader(body.Bytes()))
+}
+
+// Reload sends a `reload` request to the daemon.
+func (c *Client) Reload(ctx context.Context, r *api.ReloadRequest) (io.ReadCloser, error) {
+	var body bytes.Buffer
+	err := json.NewEncoder(&body).Encode(r)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.request(ctx, "POST", "/reload", bytes.NewReader(body.Bytes()))
+}
+
 func (c *Client) Logs(ctx context.Context, r *api.LogsRequest) (io.ReadCloser, error) {
 	var body bytes.Buffer
 	err := json.NewEncoder(&body).Encode(r)
@@ -369,6 +535,12 @@ func (c *Client) Logs(ctx context.Context, r *api.LogsRequest) (io.ReadCloser, e
 }
 
 func parseGeneric(r io.ReadCloser, progress io.Writer, fnBinary, fnResult func(interface{}) error) error {
+	return parseChunks(r, progress, fnBinary, fnResult, true)
+}
+
+// parseChunks decodes the chunks of a response, and prints banners between
+// the server output and the result if banners is true.
+func parseChunks(r io.ReadCloser, progress io.Writer, fnBinary, fnResult func(interface{}) error, banners bool) error {
 	var chunk rpc.Chunk
 	var once sync.Once
 
@@ -380,9 +552,11 @@ func parseGeneric(r io.ReadCloser, progress io.Writer, fnBinary, fnResult func(i
 
 		switch chunk.Type {
 		case rpc.ChunkTypeProgress:
-			once.Do(func() {
-				fmt.Println(aurora.Bold(aurora.Cyan("\n>>> Server output:\n")))
-			})
+			if banners {
+				once.Do(func() {
+					fmt.Println(aurora.Bold(aurora.Cyan("\n>>> Server output:\n")))
+				})
+			}
 
 			line, err := decodeProgress(chunk.Payload)
 			if err != nil {
@@ -395,11 +569,15 @@ func parseGeneric(r io.ReadCloser, progress io.Writer, fnBinary, fnResult func(i
 			}
 
 		case rpc.ChunkTypeError:
-			fmt.Println(aurora.Bold(aurora.BrightRed("\n>>> Error:\n")))
+			if banners {
+				fmt.Println(aurora.Bold(aurora.BrightRed("\n>>> Error:\n")))
+			}
 			return errors.New(chunk.Error.Msg)
 
 		case rpc.ChunkTypeResult:
-			fmt.Println(aurora.Bold(aurora.BrightGreen("\n>>> Result:\n")))
+			if banners {
+				fmt.Println(aurora.Bold(aurora.BrightGreen("\n>>> Result:\n")))
+			}
 			return fnResult(chunk.Payload)
 
 		case rpc.ChunkTypeBinary:
@@ -520,6 +698,40 @@ func ParseTerminateRequest(r io.ReadCloser, progress io.Writer) error {
 	)
 }
 
+func ParseCancelResponse(r io.ReadCloser, progress io.Writer) error {
+	return parseGeneric(
+		r,
+		progress,
+		nil,
+		func(result interface{}) error {
+			return nil
+		},
+	)
+}
+
+func ParseDrainResponse(r io.ReadCloser, progress io.Writer) error {
+	return parseGeneric(
+		r,
+		progress,
+		nil,
+		func(result interface{}) error {
+			return nil
+		},
+	)
+}
+
+// ParseReloadResponse parses a response from a 'reload' call
+func ParseReloadResponse(r io.ReadCloser, progress io.Writer) (*api.ReloadOutput, error) {
+	var resp *api.ReloadOutput
+	err := parseGeneric(
+		r,
+		progress,
+		nil,
+		parseMarshalAndUnmarshal(&resp),
+	)
+	return resp, err
+}
+
 // ParseHealthcheckResponse parses a response from a 'healthcheck' call
 func ParseHealthcheckResponse(r io.ReadCloser, progress io.Writer) (api.HealthcheckResponse, error) {
 	var resp api.HealthcheckResponse
@@ -534,6 +746,102 @@ func ParseHealthcheckResponse(r io.ReadCloser, progress io.Writer) (api.Healthch
 	return resp, err
 }
 
+// ParseParamPushResponse parses a response from a 'param' call
+func ParseParamPushResponse(r io.ReadCloser, progress io.Writer) (*api.ParamPushOutput, error) {
+	var resp *api.ParamPushOutput
+	err := parseGeneric(
+		r,
+		progress,
+		nil,
+		parseMarshalAndUnmarshal(&resp),
+	)
+	return resp, err
+}
+
+// ParseNetworkFaultResponse parses a response from a 'network' call
+func ParseNetworkFaultResponse(r io.ReadCloser, progress io.Writer) (*api.NetworkFaultOutput, error) {
+	var resp *api.NetworkFaultOutput
+	err := parseGeneric(
+		r,
+		progress,
+		nil,
+		parseMarshalAndUnmarshal(&resp),
+	)
+	return resp, err
+}
+
+// ParseScaleResponse parses a response from a 'scale' call
+func ParseScaleResponse(r io.ReadCloser, progress io.Writer) (*api.ScaleOutput, error) {
+	var resp *api.ScaleOutput
+	err := parseGeneric(
+		r,
+		progress,
+		nil,
+		parseMarshalAndUnmarshal(&resp),
+	)
+	return resp, err
+}
+
+// ParseExplainResponse parses a response from an 'explain' call
+func ParseExplainResponse(r io.ReadCloser, progress io.Writer) (*api.ExplainResponse, error) {
+	var resp *api.ExplainResponse
+	err := parseGeneric(
+		r,
+		progress,
+		nil,
+		parseMarshalAndUnmarshal(&resp),
+	)
+	return resp, err
+}
+
+// ParseGCResponse parses a response from a 'gc' call
+func ParseGCResponse(r io.ReadCloser, progress io.Writer) (*api.GCResponse, error) {
+	var resp *api.GCResponse
+	err := parseGeneric(
+		r,
+		progress,
+		nil,
+		parseMarshalAndUnmarshal(&resp),
+	)
+	return resp, err
+}
+
+// ParseTokenCreateResponse parses a response from a 'token/create' call
+func ParseTokenCreateResponse(r io.ReadCloser, progress io.Writer) (*api.TokenCreateResponse, error) {
+	var resp *api.TokenCreateResponse
+	err := parseGeneric(
+		r,
+		progress,
+		nil,
+		parseMarshalAndUnmarshal(&resp),
+	)
+	return resp, err
+}
+
+// ParseTokenListResponse parses a response from a 'token/list' call
+func ParseTokenListResponse(r io.ReadCloser, progress io.Writer) (api.TokenListResponse, error) {
+	var resp api.TokenListResponse
+	err := parseGeneric(
+		r,
+		progress,
+		nil,
+		parseMarshalAndUnmarshal(&resp),
+	)
+	return resp, err
+}
+
+// ParseTokenRevokeResponse parses a response from a 'token/revoke' call
+func ParseTokenRevokeResponse(r io.ReadCloser, progress io.Writer) error {
+	return parseGeneric(
+		r,
+		progress,
+		nil,
+		func(result interface{}) error {
+			return nil
+		},
+	)
+}
+
 // ParseTasksRequest parses a response from a 'task' call
 func ParseTasksRequest(r io.ReadCloser, progress io.Writer) ([]*task.Task, error) {
 	var resp []*task.Task
@@ -570,14 +878,33 @@ func ParseLogsRequest(w io.Writer, r io.ReadCloser) (api.LogsResponse, error) {
 	return resp, err
 }
 
-func (c *Client) request(ctx context.Context, method string, path string, body io.Reader, headers ...string) (io.ReadCloser, error) {
+// CopyLogs writes the output of a 'logs' response to w, without the banners
+// ParseLogsRequest prints, e.g. for the daemon to serve it as plain text.
+func CopyLogs(w io.Writer, r io.ReadCloser) (api.LogsResponse, error) {
+	var resp api.LogsResponse
+	err := parseChunks(r, w, nil, parseMarshalAndUnmarshal(&resp), false)
+	return resp, err
+}
+
+func (c *Client) newRequest(ctx context.Context, method string, path string, body io.Reader, headers ...string) (*http.Request, error) {
+	if c.err != nil {
+		return nil, c.err
+	}
 	if len(headers)%2 != 0 {
 		return nil, fmt.Errorf("headers must be tuples: key1, value1, key2, value2")
 	}
 	req, err := http.NewRequest(method, c.endpoint+path, body)
+	if err != nil {
+		return nil, err
+	}
 	req = req.WithContext(ctx)
 
-	token := strings.TrimSpace(c.cfg.Client.Token)
+	tracing.InjectHTTP(ctx, req.Header)
+
+	token, err := c.token(ctx)
+	if err != nil {
+		return nil, err
+	}
 	if token != "" {
 		req.Header.Add("Authorization", "Bearer "+token)
 	}
@@ -585,7 +912,11 @@ func (c *Client) request(ctx context.Context, method string, path string, body i
 	for i := 0; i < len(headers); i = i + 2 {
 		req.Header.Add(headers[i], headers[i+1])
 	}
+	return req, nil
+}
 
+func (c *Client) request(ctx context.Context, method string, path string, body io.Reader, headers ...string) (io.ReadCloser, error) {
+	req, err := c.newRequest(ctx, method, path, body, headers...)
 	if err != nil {
 		return nil, err
 	}
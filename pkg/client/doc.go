@@ -8,4 +8,15 @@
 //
 // Currently all commands to Testground, but the `daemon` command, are
 // client-side commands.
+//
+// Programs can drive the daemon like the CLI does, with the typed methods of
+// the client:
+//
+//	cl := client.New(cfg)
+//	id, err := cl.CreateRun(ctx, &api.RunRequest{Composition: *comp}, client.Sources{}, nil)
+//	...
+//	res, err := cl.WaitForTask(ctx, id, os.Stdout)
+//	...
+//	if res.Outcome != task.OutcomeSuccess { ... }
+//	err = cl.CollectOutputsTo(ctx, id, file, nil)
 package client
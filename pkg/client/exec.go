@@ -0,0 +1,100 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/testground/testground/pkg/api"
+	"github.com/testground/testground/pkg/rpc"
+)
+
+// Exec runs a command in an instance of a running task, streaming stdin to
+// the command, and its output to stdout and stderr, until it exits.
+func (c *Client) Exec(ctx context.Context, r *api.ExecRequest, stdin io.Reader, stdout, stderr io.Writer) (*api.ExecOutput, error) {
+	var body bytes.Buffer
+	if err := json.NewEncoder(&body).Encode(r); err != nil {
+		return nil, err
+	}
+
+	conn, err := c.upgrade(ctx, "/exec", &body)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	// Stream the input as binary chunks, and end it with a result chunk.
+	go func() {
+		enc := json.NewEncoder(conn)
+		if stdin != nil {
+			buf := make([]byte, 32*1024)
+			for {
+				n, err := stdin.Read(buf)
+				if n > 0 {
+					if enc.Encode(&rpc.Chunk{Type: rpc.ChunkTypeBinary, Payload: buf[:n]}) != nil {
+						return
+					}
+				}
+				if err != nil {
+					break
+				}
+			}
+		}
+		_ = enc.Encode(&rpc.Chunk{Type: rpc.ChunkTypeResult})
+	}()
+
+	var out *api.ExecOutput
+	err = parseChunks(
+		conn,
+		stderr,
+		func(payload interface{}) error {
+			b, err := base64.StdEncoding.DecodeString(payload.(string))
+			if err != nil {
+				return err
+			}
+			_, err = stdout.Write(b)
+			return err
+		},
+		parseMarshalAndUnmarshal(&out),
+		false,
+	)
+	return out, err
+}
+
+// upgrade sends a request to the daemon, upgrading its connection to
+// rpc.StreamProtocol, and returns the connection.
+func (c *Client) upgrade(ctx context.Context, path string, body io.Reader) (io.ReadWriteCloser, error) {
+	req, err := c.newRequest(ctx, "POST", path, body, "Connection", "Upgrade", "Upgrade", rpc.StreamProtocol)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode == http.StatusSwitchingProtocols {
+		if conn, ok := resp.Body.(io.ReadWriteCloser); ok {
+			return conn, nil
+		}
+		resp.Body.Close()
+		return nil, fmt.Errorf("the connection to the daemon can't be upgraded")
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		return nil, fmt.Errorf("unexpected status code received: %s", resp.Status)
+	}
+
+	// The daemon responds as usual when it refuses the upgrade.
+	err = parseChunks(resp.Body, ioutil.Discard, nil, func(interface{}) error { return nil }, false)
+	if err == nil || err == io.EOF {
+		err = fmt.Errorf("the daemon did not upgrade the connection")
+	}
+	return nil, err
+}
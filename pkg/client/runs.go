@@ -0,0 +1,383 @@
+package client
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"strings"
+
+	"github.com/testground/testground/pkg/api"
+	"github.com/testground/testground/pkg/logging"
+	"github.com/testground/testground/pkg/task"
+)
+
+// The methods in this file are the typed API of the client, for programs
+// driving the daemon. Unlike the methods returning the raw response stream,
+// they parse the response, write the server output to the given progress
+// writer, if any, and print nothing else.
+
+var (
+	// ErrTaskNotFound is returned when the daemon doesn't know the task.
+	ErrTaskNotFound = errors.New("task not found")
+	// ErrNoOutputs is returned when collecting the outputs of a run that
+	// doesn't exist, or has no outputs.
+	ErrNoOutputs = errors.New("no outputs for this run")
+	// ErrNoBuildFiles is returned when collecting the files of a build that
+	// doesn't exist, or whose builders produced none.
+	ErrNoBuildFiles = errors.New("no files for this build")
+)
+
+// Sources are the local directories a build is made of, packed and sent to
+// the daemon along with the request.
+type Sources struct {
+	// PlanDir is the directory of the test plan.
+	PlanDir string
+	// SDKDir is the directory of an SDK to link the plan with, if any.
+	SDKDir string
+	// ExtraSources are additional directories the builder needs, as listed
+	// in the manifest of the plan.
+	ExtraSources []string
+}
+
+// GroupOutcome is the number of instances of a group that succeeded.
+type GroupOutcome struct {
+	Ok    int `json:"ok"`
+	Total int `json:"total"`
+}
+
+// RunResult is the result of a completed run.
+type RunResult struct {
+	Outcome    task.Outcome             `json:"outcome"`
+	Outcomes   map[string]*GroupOutcome `json:"outcomes"`
+	Assertions []*api.AssertionResult   `json:"assertions,omitempty"`
+}
+
+// TaskResult is a completed task, and its typed result.
+type TaskResult struct {
+	Task    *task.Task
+	Outcome task.Outcome
+	// Run is the result of a run; it's nil for builds.
+	Run *RunResult
+	// Pipeline is the result of a pipeline, with the results of its runs.
+	Pipeline *api.PipelineResult
+	// Artifacts are the artifacts a build produced, one per group.
+	Artifacts []string
+}
+
+// CreateBuild queues the build of a test plan, and returns the ID of its
+// task.
+func (c *Client) CreateBuild(ctx context.Context, r *api.BuildRequest, src Sources, progress io.Writer) (string, error) {
+	resp, err := c.Build(ctx, r, src.PlanDir, src.SDKDir, src.ExtraSources)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Close()
+
+	return parseID(resp, progress)
+}
+
+// CreateRun queues a run, and returns the ID of its task. The plan directory
+// of the sources is only needed if the request has build groups.
+func (c *Client) CreateRun(ctx context.Context, r *api.RunRequest, src Sources, progress io.Writer) (string, error) {
+	resp, err := c.Run(ctx, r, src.PlanDir, src.SDKDir, src.ExtraSources)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Close()
+
+	return parseID(resp, progress)
+}
+
+// GetTask returns the current state of a task.
+func (c *Client) GetTask(ctx context.Context, id string) (*task.Task, error) {
+	resp, err := c.Status(ctx, &api.StatusRequest{TaskID: id})
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Close()
+
+	var tsk *task.Task
+	err = parseChunks(resp, ioutil.Discard, nil, parseMarshalAndUnmarshal(&tsk), false)
+	if errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("%w: %s", ErrTaskNotFound, id)
+	}
+	return tsk, err
+}
+
+// StreamLogs writes the output of a task to w, following it until the task
+// completes if follow is true, and returns the task.
+func (c *Client) StreamLogs(ctx context.Context, id string, follow bool, w io.Writer) (*task.Task, error) {
+	return c.streamLogs(ctx, &api.LogsRequest{TaskID: id, Follow: follow}, w)
+}
+
+func (c *Client) streamLogs(ctx context.Context, r *api.LogsRequest, w io.Writer) (*task.Task, error) {
+	resp, err := c.Logs(ctx, r)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Close()
+
+	var tsk *task.Task
+	err = parseChunks(resp, writerOrDiscard(w), nil, parseMarshalAndUnmarshal(&tsk), false)
+	if errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("%w: %s", ErrTaskNotFound, r.TaskID)
+	}
+	return tsk, err
+}
+
+// WaitForTask follows the output of a task until it completes, writing it to
+// w, and returns its result. If a run is retried, it follows the retries,
+// and returns the result of the last attempt. Canceling the context cancels
+// the task too, like interrupting `testground run` does.
+func (c *Client) WaitForTask(ctx context.Context, id string, w io.Writer) (*TaskResult, error) {
+	for {
+		tsk, err := c.streamLogs(ctx, &api.LogsRequest{TaskID: id, Follow: true, CancelWithContext: true}, w)
+		if err != nil {
+			return nil, err
+		}
+		if tsk.RetriedBy == "" {
+			return NewTaskResult(tsk)
+		}
+		logging.S().Infof("run with ID %s is retried with ID: %s", id, tsk.RetriedBy)
+		id = tsk.RetriedBy
+	}
+}
+
+// CollectOutputsTo writes the tgz archive of the outputs of a run to w. It
+// returns ErrNoOutputs if the run has none.
+func (c *Client) CollectOutputsTo(ctx context.Context, runID string, w io.Writer, progress io.Writer) error {
+	resp, err := c.CollectOutputs(ctx, &api.OutputsRequest{RunID: runID})
+	if err != nil {
+		return err
+	}
+	defer resp.Close()
+
+	var exists bool
+	err = parseChunks(
+		resp,
+		writerOrDiscard(progress),
+		func(payload interface{}) error {
+			m, err := base64.StdEncoding.DecodeString(payload.(string))
+			if err != nil {
+				return err
+			}
+			_, err = w.Write(m)
+			return err
+		},
+		func(result interface{}) error {
+			exists, _ = result.(bool)
+			return nil
+		},
+		false,
+	)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return fmt.Errorf("%w: %s", ErrNoOutputs, runID)
+	}
+	return nil
+}
+
+// CollectBuildTo writes the tgz archive of the files of the builds of a build
+// task to w. It returns ErrNoBuildFiles if the builds produced none.
+func (c *Client) CollectBuildTo(ctx context.Context, id string, w io.Writer, progress io.Writer) error {
+	resp, err := c.CollectBuild(ctx, &api.BuildCollectRequest{TaskID: id})
+	if err != nil {
+		return err
+	}
+	defer resp.Close()
+
+	var exists bool
+	err = parseChunks(
+		resp,
+		writerOrDiscard(progress),
+		func(payload interface{}) error {
+			m, err := base64.StdEncoding.DecodeString(payload.(string))
+			if err != nil {
+				return err
+			}
+			_, err = w.Write(m)
+			return err
+		},
+		func(result interface{}) error {
+			exists, _ = result.(bool)
+			return nil
+		},
+		false,
+	)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return fmt.Errorf("%w: %s", ErrNoBuildFiles, id)
+	}
+	return nil
+}
+
+// NewTaskResult decodes the result of a task. The outcome of a task that
+// hasn't completed is unknown.
+func NewTaskResult(tsk *task.Task) (*TaskResult, error) {
+	res := &TaskResult{Task: tsk, Outcome: task.OutcomeUnknown}
+
+	switch tsk.State().State {
+	case task.StateCanceled:
+		res.Outcome = task.OutcomeCanceled
+	case task.StateComplete:
+		res.Outcome = task.OutcomeSuccess
+	}
+
+	if tsk.Result == nil {
+		return res, nil
+	}
+
+	b, err := json.Marshal(tsk.Result)
+	if err != nil {
+		return nil, err
+	}
+
+	switch tsk.Type {
+	case task.TypeRun:
+		if err := json.Unmarshal(b, &res.Run); err != nil {
+			return nil, fmt.Errorf("failed to decode the result of run %s: %w", tsk.ID, err)
+		}
+		if res.Outcome == task.OutcomeSuccess && res.Run.Outcome != "" {
+			res.Outcome = res.Run.Outcome
+		}
+		if res.Run.Outcome == task.OutcomeTimedOut {
+			res.Outcome = task.OutcomeTimedOut
+		}
+	case task.TypePipeline:
+		if err := json.Unmarshal(b, &res.Pipeline); err != nil {
+			return nil, fmt.Errorf("failed to decode the result of pipeline %s: %w", tsk.ID, err)
+		}
+		if res.Outcome == task.OutcomeSuccess && res.Pipeline.Outcome != "" {
+			res.Outcome = res.Pipeline.Outcome
+		}
+	case task.TypeBuild:
+		if err := json.Unmarshal(b, &res.Artifacts); err != nil {
+			return nil, fmt.Errorf("failed to decode the result of build %s: %w", tsk.ID, err)
+		}
+	}
+	return res, nil
+}
+
+// TaskProvenance returns the provenance of a run, or nil if the task has
+// none: it's not a run, or it didn't get to run.
+func TaskProvenance(tsk *task.Task) (*api.Provenance, error) {
+	if tsk.Provenance == nil {
+		return nil, nil
+	}
+
+	b, err := json.Marshal(tsk.Provenance)
+	if err != nil {
+		return nil, err
+	}
+
+	var prov *api.Provenance
+	if err := json.Unmarshal(b, &prov); err != nil {
+		return nil, fmt.Errorf("failed to decode the provenance of run %s: %w", tsk.ID, err)
+	}
+	return prov, nil
+}
+
+// Version returns the versions the daemon advertises, including the versions
+// of the runtime API it supports.
+func (c *Client) Version(ctx context.Context) (*api.VersionResponse, error) {
+	resp, err := c.request(ctx, "GET", "/version", nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Close()
+
+	var v api.VersionResponse
+	if err := json.NewDecoder(resp).Decode(&v); err != nil {
+		return nil, fmt.Errorf("failed to decode the version of the daemon: %w", err)
+	}
+	return &v, nil
+}
+
+// Events streams the lifecycle events of tasks selected by the filter to fn,
+// until the context is done, fn returns an error, or the daemon ends the
+// stream, e.g. if the client falls behind; it returns nil then, and the
+// client may subscribe again.
+func (c *Client) Events(ctx context.Context, filter api.EventFilter, fn func(*api.Event) error) error {
+	q := url.Values{}
+	if filter.TaskID != "" {
+		q.Set("task_id", filter.TaskID)
+	}
+	if filter.Plan != "" {
+		q.Set("plan", filter.Plan)
+	}
+	if len(filter.Types) > 0 {
+		types := make([]string, 0, len(filter.Types))
+		for _, t := range filter.Types {
+			types = append(types, string(t))
+		}
+		q.Set("type", strings.Join(types, ","))
+	}
+
+	req, err := c.newRequest(ctx, "GET", "/events?"+q.Encode(), nil, "Accept", "text/event-stream")
+	if err != nil {
+		return err
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		return fmt.Errorf("unexpected status code received: %s", resp.Status)
+	}
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		return fmt.Errorf("unexpected content-type received: %s", ct)
+	}
+
+	// Events are sent as an event line and a data line, and end with a
+	// blank line; comments keep the stream alive.
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, "data: ") {
+			continue
+		}
+		var ev api.Event
+		if err := json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &ev); err != nil {
+			return fmt.Errorf("failed to decode event: %w", err)
+		}
+		if err := fn(&ev); err != nil {
+			return err
+		}
+	}
+	if err := scanner.Err(); err != nil && ctx.Err() == nil {
+		return err
+	}
+	return nil
+}
+
+func parseID(r io.ReadCloser, progress io.Writer) (string, error) {
+	var id string
+	err := parseChunks(r, writerOrDiscard(progress), nil, func(result interface{}) error {
+		var ok bool
+		if id, ok = result.(string); !ok {
+			return errors.New("result should be string")
+		}
+		return nil
+	}, false)
+	return id, err
+}
+
+func writerOrDiscard(w io.Writer) io.Writer {
+	if w == nil {
+		return ioutil.Discard
+	}
+	return w
+}
@@ -0,0 +1,107 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/testground/testground/pkg/api"
+	"github.com/testground/testground/pkg/config"
+	"github.com/testground/testground/pkg/rpc"
+	"github.com/testground/testground/pkg/task"
+)
+
+func TestWaitForTask(t *testing.T) {
+	now := time.Now().UTC()
+	tasks := map[string]*task.Task{
+		"c3ftkqjpc98qra498sg0": {
+			ID:        "c3ftkqjpc98qra498sg0",
+			Type:      task.TypeRun,
+			States:    []task.DatedState{{State: task.StateComplete, Created: now}},
+			Error:     "instance 3 crashed",
+			RetriedBy: "c3ftkqjpc98qra498sh0",
+		},
+		"c3ftkqjpc98qra498sh0": {
+			ID:     "c3ftkqjpc98qra498sh0",
+			Type:   task.TypeRun,
+			States: []task.DatedState{{State: task.StateComplete, Created: now}},
+			Result: map[string]interface{}{
+				"outcome":  "failure",
+				"outcomes": map[string]interface{}{"peers": map[string]int{"ok": 3, "total": 4}},
+			},
+		},
+	}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tgw := rpc.NewOutputWriter(w, r)
+		switch r.URL.Path {
+		case "/logs":
+			var req api.LogsRequest
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+			require.True(t, req.Follow)
+			tgw.Infow("running", "task_id", req.TaskID)
+			tgw.WriteResult(tasks[req.TaskID])
+		case "/status":
+			tgw.Warnw("could not fetch status")
+		case "/outputs":
+			var req api.OutputsRequest
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+			if req.RunID == "c3ftkqjpc98qra498sh0" {
+				_, _ = tgw.WriteBinary([]byte("tgz"))
+			}
+			tgw.WriteResult(req.RunID == "c3ftkqjpc98qra498sh0")
+		}
+	}))
+	defer srv.Close()
+
+	cl := New(&config.EnvConfig{Client: config.ClientConfig{Endpoint: srv.URL}})
+	ctx := context.Background()
+
+	var progress bytes.Buffer
+	res, err := cl.WaitForTask(ctx, "c3ftkqjpc98qra498sg0", &progress)
+	require.NoError(t, err)
+	require.Equal(t, "c3ftkqjpc98qra498sh0", res.Task.ID)
+	require.Equal(t, task.OutcomeFailure, res.Outcome)
+	require.Equal(t, &GroupOutcome{Ok: 3, Total: 4}, res.Run.Outcomes["peers"])
+	require.Contains(t, progress.String(), "c3ftkqjpc98qra498sg0")
+	require.Contains(t, progress.String(), "c3ftkqjpc98qra498sh0")
+
+	_, err = cl.GetTask(ctx, "c3ftkqjpc98qra498si0")
+	require.True(t, errors.Is(err, ErrTaskNotFound), err)
+
+	var out bytes.Buffer
+	require.NoError(t, cl.CollectOutputsTo(ctx, "c3ftkqjpc98qra498sh0", &out, nil))
+	require.Equal(t, "tgz", out.String())
+
+	err = cl.CollectOutputsTo(ctx, "c3ftkqjpc98qra498sg0", &out, nil)
+	require.True(t, errors.Is(err, ErrNoOutputs), err)
+}
+
+func TestNewTaskResult(t *testing.T) {
+	build := &task.Task{
+		Type:   task.TypeBuild,
+		States: []task.DatedState{{State: task.StateComplete}},
+		Result: []interface{}{"artifact-a", "artifact-b"},
+	}
+	res, err := NewTaskResult(build)
+	require.NoError(t, err)
+	require.Equal(t, task.OutcomeSuccess, res.Outcome)
+	require.Equal(t, []string{"artifact-a", "artifact-b"}, res.Artifacts)
+	require.Nil(t, res.Run)
+
+	canceled := &task.Task{
+		Type:   task.TypeRun,
+		States: []task.DatedState{{State: task.StateCanceled}},
+		Result: map[string]interface{}{"outcome": "success"},
+	}
+	res, err = NewTaskResult(canceled)
+	require.NoError(t, err)
+	require.Equal(t, task.OutcomeCanceled, res.Outcome)
+}
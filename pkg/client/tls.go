@@ -0,0 +1,46 @@
+package client
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+
+	"github.com/testground/testground/pkg/config"
+)
+
+// clientTLSConfig returns the TLS configuration of the connections to the
+// daemon, or nil if the defaults apply.
+func clientTLSConfig(cfg config.ClientTLSConfig) (*tls.Config, error) {
+	if cfg == (config.ClientTLSConfig{}) {
+		return nil, nil
+	}
+
+	tlscfg := &tls.Config{
+		ServerName:         cfg.ServerName,
+		InsecureSkipVerify: cfg.InsecureSkipVerify, // nolint:gosec
+		MinVersion:         tls.VersionTLS12,
+	}
+
+	if cfg.CAFile != "" {
+		pem, err := ioutil.ReadFile(cfg.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("tls: failed to read the CAs of the daemon: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("tls: no certificates found in %s", cfg.CAFile)
+		}
+		tlscfg.RootCAs = pool
+	}
+
+	if cfg.CertFile != "" || cfg.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("tls: failed to load the client certificate: %w", err)
+		}
+		tlscfg.Certificates = []tls.Certificate{cert}
+	}
+
+	return tlscfg, nil
+}
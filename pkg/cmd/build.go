@@ -4,10 +4,10 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"os"
 	"path/filepath"
 	"strings"
 
-	"github.com/mitchellh/mapstructure"
 
 	"github.com/testground/testground/pkg/api"
 	"github.com/testground/testground/pkg/client"
@@ -36,6 +36,10 @@ var BuildCommand = cli.Command{
 					Usage:    "path to a `COMPOSITION`",
 					Required: true,
 				},
+				&cli.StringSliceFlag{
+					Name:  "set",
+					Usage: "set a composition template variable, available as {{ .Vars.KEY }}, as `KEY=VALUE`",
+				},
 				&cli.BoolFlag{
 					Name:    "write-artifacts",
 					Aliases: []string{"w"},
@@ -108,6 +112,19 @@ var BuildCommand = cli.Command{
 				},
 			},
 		},
+		&cli.Command{
+			Name:      "collect",
+			Usage:     "collect the files the builds of the supplied build task produced, e.g. the exec:go binary, into a .tgz archive",
+			Action:    runBuildCollectCmd,
+			ArgsUsage: "[build_id]",
+			Flags: []cli.Flag{
+				&cli.StringFlag{
+					Name:    "output",
+					Aliases: []string{"o"},
+					Usage:   "write the archive to `FILENAME`",
+				},
+			},
+		},
 	},
 }
 
@@ -117,12 +134,16 @@ func buildCompositionCmd(c *cli.Context) (err error) {
 		return fmt.Errorf("no composition file supplied")
 	}
 
-	comp, err := loadComposition(file)
+	comp, err := loadComposition(file, c.StringSlice("set"))
 
 	if err != nil {
 		return fmt.Errorf("failed to load composition file: %w", err)
 	}
 
+	if err = lintBeforeSubmit(c, file, false); err != nil {
+		return err
+	}
+
 	if err = comp.ValidateForBuild(); err != nil {
 		return fmt.Errorf("invalid composition file: %w", err)
 	}
@@ -161,6 +182,9 @@ func build(c *cli.Context, comp *api.Composition) error {
 	ctx, cancel := context.WithCancel(ProcessContext())
 	defer cancel()
 
+	ctx, endTracing := startTracing(ctx, cfg, "build", comp)
+	defer endTracing()
+
 	// Resolve the test plan and its manifest.
 	var manifest *api.TestPlanManifest
 	planDir, manifest, err := resolveTestPlan(cfg, comp.Global.Plan)
@@ -192,6 +216,15 @@ func build(c *cli.Context, comp *api.Composition) error {
 		req.Priority = 1
 	}
 
+	// Plans imported from a remote source are fetched by the daemon, at the
+	// commit they were imported at.
+	sourceDir := planDir
+	if req.PlanSource, err = resolvePlanSource(planDir); err != nil {
+		return err
+	} else if req.PlanSource != nil {
+		sourceDir = ""
+	}
+
 	// Resolve the linked SDK directory, if one has been supplied.
 	if sdk := c.String("link-sdk"); sdk != "" {
 		var err error
@@ -217,13 +250,7 @@ func build(c *cli.Context, comp *api.Composition) error {
 		}
 	}
 
-	resp, err := cl.Build(ctx, req, planDir, sdkDir, extra)
-	if err != nil {
-		return err
-	}
-	defer resp.Close()
-
-	id, err := client.ParseBuildResponse(resp, c.App.Writer)
+	id, err := cl.CreateBuild(ctx, req, client.Sources{PlanDir: sourceDir, SDKDir: sdkDir, ExtraSources: extra}, c.App.Writer)
 	switch err {
 	case nil:
 	case context.Canceled:
@@ -238,38 +265,23 @@ func build(c *cli.Context, comp *api.Composition) error {
 		return nil
 	}
 
-	r, err := cl.Logs(ctx, &api.LogsRequest{
-		TaskID:            id,
-		Follow:            true,
-		CancelWithContext: true,
-	})
-	if err != nil {
-		return err
-	}
-	defer r.Close()
-
-	tsk, err := client.ParseLogsRequest(c.App.Writer, r)
+	res, err := cl.WaitForTask(ctx, id, c.App.Writer)
 	if err != nil {
 		return err
 	}
+	tsk := res.Task
 
 	if tsk.Error != "" {
 		return errors.New(tsk.Error)
 	}
 
-	var artifactPaths []string
-	err = mapstructure.Decode(tsk.Result, &artifactPaths)
-	if err != nil {
-		return err
-	}
-
-	for i, ap := range artifactPaths {
+	for i, ap := range res.Artifacts {
 		g := comp.Groups[i]
 		logging.S().Infow("generated build artifact", "group", g.ID, "artifact", ap)
 		g.Run.Artifact = ap
 	}
 
-	return data.IsTaskOutcomeInError(&tsk)
+	return data.IsTaskOutcomeInError(tsk)
 }
 
 func runBuildPurgeCmd(c *cli.Context) (err error) {
@@ -304,3 +316,48 @@ func runBuildPurgeCmd(c *cli.Context) (err error) {
 	return nil
 }
 
+
+func runBuildCollectCmd(c *cli.Context) (err error) {
+	ctx, cancel := context.WithCancel(ProcessContext())
+	defer cancel()
+
+	if c.NArg() != 1 {
+		return errors.New("missing build id")
+	}
+
+	var (
+		id     = c.Args().First()
+		output = id + ".tgz"
+	)
+
+	if o := c.String("output"); o != "" {
+		output = o
+	}
+
+	cl, _, err := setupClient(c)
+	if err != nil {
+		return err
+	}
+
+	file, err := os.Create(output)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	err = cl.CollectBuildTo(ctx, id, file, c.App.Writer)
+	switch {
+	case err == nil:
+	case errors.Is(err, client.ErrNoBuildFiles):
+		logging.S().Errorw("no files for this build", "build_id", id)
+
+		return os.Remove(output)
+	case errors.Is(err, context.Canceled):
+		return fmt.Errorf("interrupted")
+	default:
+		return err
+	}
+
+	logging.S().Infof("created file: %s", output)
+	return nil
+}
@@ -0,0 +1,187 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"github.com/docker/docker/client"
+	"github.com/urfave/cli/v2"
+
+	"github.com/testground/testground/pkg/bundle"
+	"github.com/testground/testground/pkg/config"
+	"github.com/testground/testground/pkg/rpc"
+)
+
+var BundleCommand = cli.Command{
+	Name:  "bundle",
+	Usage: "package what runs need for transfer into an air-gapped environment, and load it there",
+	Description: `A bundle holds plans, with their Go modules and node_modules vendored,
+   compositions, and images: those of the infrastructure of the local runners
+   and the base images of the builders, by default. Bundles are created where
+   the network is reachable, and loaded where the daemon runs in offline mode.`,
+	Subcommands: cli.Commands{
+		&cli.Command{
+			Name:   "create",
+			Usage:  "create a bundle of plans, compositions and images",
+			Action: bundleCreateCommand,
+			Flags: []cli.Flag{
+				&cli.StringSliceFlag{
+					Name:    "plan",
+					Aliases: []string{"p"},
+					Usage:   "bundle the plan `NAME` of $TESTGROUND_HOME/plans",
+				},
+				&cli.StringSliceFlag{
+					Name:    "composition",
+					Aliases: []string{"f"},
+					Usage:   "bundle the `COMPOSITION`, rendered, and its plan",
+				},
+				&cli.StringSliceFlag{
+					Name:  "set",
+					Usage: "set a composition template variable, available as {{ .Vars.KEY }}, as `KEY=VALUE`",
+				},
+				&cli.StringSliceFlag{
+					Name:  "image",
+					Usage: "bundle the `IMAGE` too, e.g. the runtime image of a plan",
+				},
+				&cli.BoolFlag{
+					Name:  "no-default-images",
+					Usage: "don't bundle the images of the infrastructure and of the builders",
+				},
+				&cli.StringFlag{
+					Name:    "output",
+					Aliases: []string{"o"},
+					Usage:   "write the bundle to `FILE`",
+					Value:   "testground-bundle.tgz",
+				},
+			},
+		},
+		&cli.Command{
+			Name:      "load",
+			Usage:     "load the plans, compositions and images of a bundle",
+			ArgsUsage: "BUNDLE",
+			Action:    bundleLoadCommand,
+			Flags: []cli.Flag{
+				&cli.StringFlag{
+					Name:  "compositions-dir",
+					Usage: "copy the compositions into `DIR`",
+					Value: ".",
+				},
+				&cli.BoolFlag{
+					Name:  "replace",
+					Usage: "replace the plans and compositions that exist already",
+				},
+				&cli.StringFlag{
+					Name:  "push",
+					Usage: "push the images to the mirror `REGISTRY` the daemon pulls from in offline mode",
+				},
+			},
+		},
+	},
+}
+
+func bundleCreateCommand(c *cli.Context) error {
+	ctx, cancel := context.WithCancel(ProcessContext())
+	defer cancel()
+
+	cfg := &config.EnvConfig{}
+	if err := cfg.Load(); err != nil {
+		return err
+	}
+
+	opts := &bundle.CreateOptions{
+		Plans:        make(map[string]string),
+		Compositions: make(map[string][]byte),
+		Images:       c.StringSlice("image"),
+	}
+	for _, plan := range c.StringSlice("plan") {
+		opts.Plans[plan] = filepath.Join(cfg.Dirs().Plans(), plan)
+	}
+	for _, path := range c.StringSlice("composition") {
+		comp, err := loadComposition(path, c.StringSlice("set"))
+		if err != nil {
+			return fmt.Errorf("failed to load composition %s: %w", path, err)
+		}
+		var buf bytes.Buffer
+		if err := toml.NewEncoder(&buf).Encode(comp); err != nil {
+			return fmt.Errorf("failed to render composition %s: %w", path, err)
+		}
+		name := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path)) + ".toml"
+		opts.Compositions[name] = buf.Bytes()
+		opts.Plans[comp.Global.Plan] = filepath.Join(cfg.Dirs().Plans(), comp.Global.Plan)
+	}
+	if len(opts.Plans) == 0 {
+		return fmt.Errorf("nothing to bundle; pass a --plan or a --composition")
+	}
+	if !c.Bool("no-default-images") {
+		opts.Images = append(append([]string{}, bundle.DefaultImages...), opts.Images...)
+	}
+
+	docker, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
+	if err != nil {
+		return err
+	}
+	defer docker.Close()
+
+	out := c.String("output")
+	f, err := os.Create(out)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	m, err := bundle.Create(ctx, rpc.NewStdoutWriter(), docker, opts, f)
+	if err != nil {
+		_ = os.Remove(out)
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(c.App.Writer, "bundled %d plans, %d compositions and %d images into %s\n", len(m.Plans), len(m.Compositions), len(m.Images), out)
+	return nil
+}
+
+func bundleLoadCommand(c *cli.Context) error {
+	ctx, cancel := context.WithCancel(ProcessContext())
+	defer cancel()
+
+	if c.NArg() != 1 {
+		return fmt.Errorf("expected the path of a bundle")
+	}
+
+	cfg := &config.EnvConfig{}
+	if err := cfg.Load(); err != nil {
+		return err
+	}
+
+	docker, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
+	if err != nil {
+		return err
+	}
+	defer docker.Close()
+
+	f, err := os.Open(c.Args().First())
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	m, err := bundle.Load(ctx, rpc.NewStdoutWriter(), docker, &bundle.LoadOptions{
+		PlansDir:        cfg.Dirs().Plans(),
+		CompositionsDir: c.String("compositions-dir"),
+		Replace:         c.Bool("replace"),
+		Registry:        c.String("push"),
+	}, f)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(c.App.Writer, "loaded %d plans, %d compositions and %d images\n", len(m.Plans), len(m.Compositions), len(m.Images))
+	return nil
+}
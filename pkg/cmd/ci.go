@@ -0,0 +1,134 @@
+package cmd
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/urfave/cli/v2"
+
+	"github.com/testground/testground/pkg/api"
+	"github.com/testground/testground/pkg/logging"
+	"github.com/testground/testground/pkg/runner"
+	"github.com/testground/testground/pkg/task"
+)
+
+// ciBudget enforces the wall-clock and resource budgets of a CI invocation
+// across the runs it schedules. Runs are scheduled by decreasing priority, so
+// that the runs the daemon sheds when the deadline of the budget is at risk
+// are the least important ones.
+type ciBudget struct {
+	// budget is the total wall-clock budget; zero means unlimited.
+	budget time.Duration
+	// maxInstances is the maximum number of instances of a run; zero means
+	// unlimited.
+	maxInstances int
+
+	started time.Time
+}
+
+// newCIBudget returns the budget configured by the CLI flags, or nil if CI
+// mode is not enabled.
+func newCIBudget(c *cli.Context) *ciBudget {
+	if !c.Bool("ci") {
+		return nil
+	}
+	return &ciBudget{
+		budget:       c.Duration("ci-budget"),
+		maxInstances: c.Int("ci-max-instances"),
+		started:      time.Now(),
+	}
+}
+
+// Deadline returns the time at which the budget is exhausted, if any.
+func (b *ciBudget) Deadline() (time.Time, bool) {
+	if b.budget == 0 {
+		return time.Time{}, false
+	}
+	return b.started.Add(b.budget), true
+}
+
+// Plan orders the runs by decreasing priority, and fits them into the
+// resource budget by shrinking the runs sized by percentages and shedding the
+// others. It returns the ids of the runs to schedule, and the results of the
+// shed runs.
+func (b *ciBudget) Plan(comp *api.Composition, runIds []string) ([]string, []MultiRunResult, error) {
+	runs := make([]*api.Run, 0, len(runIds))
+	for _, id := range runIds {
+		run, err := comp.GetRun(id)
+		if err != nil {
+			return nil, nil, err
+		}
+		runs = append(runs, run)
+	}
+
+	sort.SliceStable(runs, func(i, j int) bool {
+		return runs[i].Priority > runs[j].Priority
+	})
+
+	var (
+		keep []string
+		shed []MultiRunResult
+	)
+	for _, run := range runs {
+		instances, scalable, err := runInstances(comp, run)
+		if err != nil {
+			return nil, nil, fmt.Errorf("run %s: %w", run.ID, err)
+		}
+		if b.maxInstances > 0 && instances > b.maxInstances {
+			if !scalable {
+				shed = append(shed, shedRun(run.ID, fmt.Sprintf("run needs %d instances, over the budget of %d", instances, b.maxInstances)))
+				continue
+			}
+			logging.S().Infow("shrinking run to fit the ci budget", "run_id", run.ID, "instances", instances, "max_instances", b.maxInstances)
+			run.TotalInstances = uint(b.maxInstances)
+		}
+		keep = append(keep, run.ID)
+	}
+
+	return keep, shed, nil
+}
+
+// runInstances returns the number of instances of a run, and whether the run
+// can be resized by changing its total instance count, i.e. all its groups
+// are sized by percentages. Run groups inherit the instances of the groups of
+// the composition they don't set.
+func runInstances(comp *api.Composition, run *api.Run) (int, bool, error) {
+	total := run.TotalInstances
+	if total == 0 {
+		total = comp.Global.TotalInstances
+	}
+
+	var (
+		count    uint
+		scalable = true
+	)
+	for _, g := range run.Groups {
+		instances, err := g.EffectiveInstances(comp)
+		if err != nil {
+			return 0, false, err
+		}
+		if instances.Count > 0 {
+			count += instances.Count
+			scalable = false
+		}
+	}
+
+	if scalable {
+		return int(total), total > 0, nil
+	}
+	return int(count), false, nil
+}
+
+func shedRun(runId string, reason string) MultiRunResult {
+	logging.S().Warnw("shedding run to stay within the ci budget", "run_id", runId, "reason", reason)
+	return MultiRunResult{
+		RunId:  runId,
+		TaskId: "N/A",
+		Error:  "shed: " + reason,
+		Shed:   true,
+		Result: runner.Result{
+			Outcome: task.OutcomeCanceled,
+		},
+	}
+}
@@ -0,0 +1,114 @@
+package cmd
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/testground/testground/pkg/api"
+)
+
+func TestCIBudgetPlan(t *testing.T) {
+	comp := &api.Composition{
+		Groups: api.Groups{{ID: "a"}},
+		Runs: api.Runs{
+			{
+				ID:     "small",
+				Groups: api.CompositionRunGroups{{ID: "a", Instances: api.Instances{Count: 2}}},
+			},
+			{
+				ID:       "large",
+				Priority: 1,
+				Groups:   api.CompositionRunGroups{{ID: "a", Instances: api.Instances{Count: 20}}},
+			},
+			{
+				ID:             "scalable",
+				Priority:       2,
+				TotalInstances: 50,
+				Groups:         api.CompositionRunGroups{{ID: "a", Instances: api.Instances{Percentage: 1}}},
+			},
+		},
+	}
+
+	b := &ciBudget{maxInstances: 10}
+	keep, shed, err := b.Plan(comp, []string{"small", "large", "scalable"})
+	require.NoError(t, err)
+
+	require.Equal(t, []string{"scalable", "small"}, keep)
+	require.Len(t, shed, 1)
+	require.Equal(t, "large", shed[0].RunId)
+	require.True(t, shed[0].Shed)
+
+	run, err := comp.GetRun("scalable")
+	require.NoError(t, err)
+	require.EqualValues(t, 10, run.TotalInstances)
+
+	_, _, err = b.Plan(comp, []string{"missing"})
+	require.Error(t, err)
+}
+
+func TestCIBudgetPlanInheritedInstances(t *testing.T) {
+	comp := &api.Composition{
+		Groups: api.Groups{
+			{ID: "miners", Instances: api.Instances{Count: 8}},
+			{ID: "clients", Instances: api.Instances{Count: 4}},
+		},
+		Runs: api.Runs{
+			// Both groups inherit their counts: 12 instances.
+			{
+				ID:     "inherited",
+				Groups: api.CompositionRunGroups{{ID: "miners"}, {ID: "clients"}},
+			},
+			// The run group overrides the count of its group: 2 + 4 instances.
+			{
+				ID:     "overridden",
+				Groups: api.CompositionRunGroups{{ID: "m", GroupID: "miners", Instances: api.Instances{Count: 2}}, {ID: "clients"}},
+			},
+		},
+	}
+
+	b := &ciBudget{maxInstances: 10}
+	keep, shed, err := b.Plan(comp, []string{"inherited", "overridden"})
+	require.NoError(t, err)
+	require.Equal(t, []string{"overridden"}, keep)
+	require.Len(t, shed, 1)
+	require.Equal(t, "inherited", shed[0].RunId)
+	require.Contains(t, shed[0].Error, "needs 12 instances")
+
+	// The composition is left as it was.
+	require.Zero(t, comp.Runs[0].Groups[0].Instances.Count)
+
+	// Run groups of unknown groups can't be sized.
+	comp.Runs[0].Groups[0].GroupID = "unknown"
+	_, _, err = b.Plan(comp, []string{"inherited"})
+	require.Error(t, err)
+}
+
+func TestCIBudgetDeadline(t *testing.T) {
+	now := time.Now()
+
+	_, ok := (&ciBudget{started: now}).Deadline()
+	require.False(t, ok)
+
+	deadline, ok := (&ciBudget{budget: 30 * time.Minute, started: now}).Deadline()
+	require.True(t, ok)
+	require.Equal(t, now.Add(30*time.Minute), deadline)
+}
+
+func TestPipelineOrderDropsShedUpstream(t *testing.T) {
+	comp := &api.Composition{
+		Runs: api.Runs{
+			{ID: "snapshot"},
+			{ID: "replay", DependsOn: []string{"snapshot"}},
+			{ID: "analyse", DependsOn: []string{"replay"}},
+			{ID: "unrelated"},
+		},
+	}
+
+	// snapshot was shed by the budget.
+	order, dropped, err := pipelineOrder(comp, []string{"analyse", "unrelated", "replay"})
+	require.NoError(t, err)
+	require.Equal(t, []string{"unrelated"}, order)
+	require.Equal(t, []string{"replay", "analyse"}, dropped)
+}
@@ -7,7 +7,6 @@ import (
 	"io"
 	"os"
 
-	"github.com/testground/testground/pkg/api"
 	"github.com/testground/testground/pkg/client"
 	"github.com/testground/testground/pkg/logging"
 
@@ -62,38 +61,23 @@ func collectCommand(c *cli.Context) error {
 }
 
 func collect(ctx context.Context, cl *client.Client, stdout io.Writer, runner string, runid string, outputFile string) error {
-	req := &api.OutputsRequest{
-		Runner: runner,
-		RunID:  runid,
-	}
-
-	resp, err := cl.CollectOutputs(ctx, req)
-	if err != nil {
-		if err == context.Canceled {
-			return fmt.Errorf("interrupted")
-		}
-		return err
-	}
-	defer resp.Close()
-
 	file, err := os.Create(outputFile)
 	if err != nil {
-		if err == context.Canceled {
-			return fmt.Errorf("interrupted")
-		}
 		return err
 	}
 	defer file.Close()
 
-	cr, err := client.ParseCollectResponse(resp, file, stdout)
-	if err != nil {
-		return err
-	}
-
-	if !cr.Exists {
+	err = cl.CollectOutputsTo(ctx, runid, file, stdout)
+	switch {
+	case err == nil:
+	case errors.Is(err, client.ErrNoOutputs):
 		logging.S().Errorw("no such testplan run", "run_id", runid, "runner", runner)
 
 		return os.Remove(outputFile)
+	case errors.Is(err, context.Canceled):
+		return fmt.Errorf("interrupted")
+	default:
+		return err
 	}
 
 	logging.S().Infof("created file: %s", outputFile)
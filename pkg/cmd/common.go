@@ -1,19 +1,25 @@
 package cmd
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/BurntSushi/toml"
 	"github.com/urfave/cli/v2"
+	"go.opentelemetry.io/otel/attribute"
 
 	"github.com/testground/testground/pkg/api"
 	"github.com/testground/testground/pkg/client"
 	"github.com/testground/testground/pkg/config"
 	"github.com/testground/testground/pkg/conv"
+	"github.com/testground/testground/pkg/logging"
+	"github.com/testground/testground/pkg/plansource"
+	"github.com/testground/testground/pkg/tracing"
 )
 
 func setupClient(c *cli.Context) (*client.Client, *config.EnvConfig, error) {
@@ -31,6 +37,29 @@ func setupClient(c *cli.Context) (*client.Client, *config.EnvConfig, error) {
 	return cl, cfg, nil
 }
 
+// startTracing initializes tracing for the CLI, and starts the root span of
+// the command. The returned function ends the span and flushes the trace.
+func startTracing(ctx context.Context, cfg *config.EnvConfig, name string, comp *api.Composition) (context.Context, func()) {
+	shutdown, err := tracing.Init(ctx, cfg.Tracing, "testground-cli")
+	if err != nil {
+		logging.S().Warnw("failed to initialize tracing", "err", err)
+		return ctx, func() {}
+	}
+
+	ctx, span := tracing.Start(ctx, name,
+		attribute.String("plan", comp.Global.Plan),
+		attribute.String("case", comp.Global.Case),
+		attribute.String("runner", comp.Global.Runner),
+	)
+	return ctx, func() {
+		span.End()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = shutdown(ctx)
+	}
+}
+
 // createSingletonComposition parses a single-style command line build/run, and
 // produces a synthetic composition to submit to the server.
 func createSingletonComposition(c *cli.Context) (*api.Composition, error) {
@@ -157,6 +186,23 @@ func resolveTestPlan(cfg *config.EnvConfig, name string) (string, *api.TestPlanM
 	return path, plan, nil
 }
 
+// resolvePlanSource returns the remote source a plan was imported from, if
+// it was, pinned to the commit it was imported at. Plans imported from local
+// repositories, or over ssh, are sent along with the request instead, as the
+// daemon doesn't fetch them by default.
+func resolvePlanSource(planDir string) (*api.PlanSource, error) {
+	src, err := plansource.ReadRecord(planDir)
+	if err != nil || src == nil {
+		return nil, err
+	}
+	if err := src.CheckRemote(nil); err != nil {
+		logging.S().Infof("sending test plan imported from %s: %s", src, err)
+		return nil, nil
+	}
+	logging.S().Infof("test plan pinned to %s", src)
+	return (*api.PlanSource)(src), nil
+}
+
 // resolveSDK resolves the root directory of an SDK.
 func resolveSDK(cfg *config.EnvConfig, path string) (string, error) {
 	baseDir := cfg.Dirs().SDKs()
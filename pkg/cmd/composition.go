@@ -0,0 +1,97 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/testground/testground/pkg/api"
+
+	"github.com/urfave/cli/v2"
+)
+
+var CompositionCommand = cli.Command{
+	Name:  "composition",
+	Usage: "work with composition files",
+	Subcommands: cli.Commands{
+		&cli.Command{
+			Name:   "convert",
+			Usage:  "convert a composition file between the TOML, YAML and JSON formats",
+			Action: convertCompositionCmd,
+			Flags: []cli.Flag{
+				&cli.StringFlag{
+					Name:     "file",
+					Aliases:  []string{"f"},
+					Usage:    "path to a `COMPOSITION`; its format is detected by its extension",
+					Required: true,
+				},
+				&cli.StringFlag{
+					Name:    "output",
+					Aliases: []string{"o"},
+					Usage:   "write the converted composition to `FILENAME`, in the format of its extension; without it, it writes to stdout",
+				},
+				&cli.StringFlag{
+					Name:  "format",
+					Usage: "`FORMAT` of the converted composition, if it can't be detected from --output; values: toml, yaml, json",
+				},
+				&cli.StringSliceFlag{
+					Name:  "set",
+					Usage: "set a composition template variable, available as {{ .Vars.KEY }}, as `KEY=VALUE`",
+				},
+			},
+		},
+	},
+}
+
+// convertCompositionCmd converts a single composition file. Templates are
+// processed, as they can't be translated, but the files it extends are
+// left as they are.
+func convertCompositionCmd(c *cli.Context) error {
+	file, output := c.String("file"), c.String("output")
+
+	var (
+		format api.CompositionFormat
+		err    error
+	)
+	switch {
+	case c.String("format") != "":
+		format, err = api.ParseCompositionFormat(c.String("format"))
+	case output != "":
+		format = api.CompositionFormatOf(output)
+	default:
+		err = fmt.Errorf("either --output or --format is required")
+	}
+	if err != nil {
+		return err
+	}
+
+	data, err := newCompositionData(c.StringSlice("set"))
+	if err != nil {
+		return err
+	}
+
+	buff, err := compileCompositionTemplate(file, data)
+	if err != nil {
+		return fmt.Errorf("failed to process composition template: %w", err)
+	}
+
+	tree, err := api.DecodeCompositionTree(api.CompositionFormatOf(file), buff.Bytes())
+	if err != nil {
+		return fmt.Errorf("failed to process composition file: %w", err)
+	}
+
+	var w io.Writer = c.App.Writer
+	if output != "" {
+		f, err := os.Create(output)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+
+	if err := api.EncodeCompositionTree(format, tree, w); err != nil {
+		return fmt.Errorf("failed to encode composition: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,22 @@
+package cmd
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/urfave/cli/v2"
+)
+
+func TestConvertComposition(t *testing.T) {
+	var out bytes.Buffer
+	app := &cli.App{Commands: []*cli.Command{&CompositionCommand}, Writer: &out}
+
+	err := app.Run([]string{"testground", "composition", "convert", "-f", "fixtures/templates/base.toml", "--format", "yaml"})
+	require.NoError(t, err)
+	require.Contains(t, out.String(), "global:\n")
+	require.Contains(t, out.String(), "  - id: pingers\n")
+
+	err = app.Run([]string{"testground", "composition", "convert", "-f", "fixtures/templates/base.toml"})
+	require.Error(t, err)
+}
@@ -2,12 +2,20 @@ package cmd
 
 import (
 	"context"
+	"fmt"
 	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
 	"time"
 
+	"github.com/testground/testground/pkg/api"
+	"github.com/testground/testground/pkg/client"
 	"github.com/testground/testground/pkg/config"
 	"github.com/testground/testground/pkg/daemon"
 	"github.com/testground/testground/pkg/logging"
+	"github.com/testground/testground/pkg/tracing"
 
 	"github.com/urfave/cli/v2"
 )
@@ -17,6 +25,25 @@ var DaemonCommand = cli.Command{
 	Name:   "daemon",
 	Usage:  "start a long-running testground daemon process",
 	Action: daemonCommand,
+	Flags: []cli.Flag{
+		&cli.BoolFlag{
+			Name:  "ui",
+			Usage: "serve the web UI under /ui (overrides .env.toml)",
+		},
+	},
+	Subcommands: cli.Commands{
+		&cli.Command{
+			Name:   "reload",
+			Usage:  "reload the env config of the daemon without restarting it; the daemon also reloads it on SIGHUP",
+			Action: daemonReloadCommand,
+			Flags: []cli.Flag{
+				&cli.StringFlag{
+					Name:  "config",
+					Usage: "load the config `FILE`, on the host of the daemon, instead of its .env.toml",
+				},
+			},
+		},
+	},
 }
 
 func daemonCommand(c *cli.Context) error {
@@ -28,6 +55,16 @@ func daemonCommand(c *cli.Context) error {
 		return err
 	}
 
+	if c.Bool("ui") {
+		cfg.Daemon.UI = true
+	}
+
+	shutdownTracing, err := tracing.Init(ctx, cfg.Tracing, "testground-daemon")
+	if err != nil {
+		return fmt.Errorf("failed to initialize tracing: %w", err)
+	}
+	defer shutdownTracing(context.Background()) //nolint:errcheck
+
 	srv, err := daemon.New(cfg)
 	if err != nil {
 		return err
@@ -36,6 +73,24 @@ func daemonCommand(c *cli.Context) error {
 	exiting := make(chan struct{})
 	defer close(exiting)
 
+	// Reload the env config on SIGHUP.
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	go func() {
+		for {
+			select {
+			case <-hup:
+				if _, err := srv.Reload(""); err != nil {
+					logging.S().Errorw("failed to reload the env config", "err", err)
+				}
+			case <-exiting:
+				return
+			}
+		}
+	}()
+
 	go func() {
 		select {
 		case <-ctx.Done():
@@ -62,3 +117,34 @@ func daemonCommand(c *cli.Context) error {
 	}
 	return err
 }
+
+func daemonReloadCommand(c *cli.Context) error {
+	ctx, cancel := context.WithCancel(ProcessContext())
+	defer cancel()
+
+	cl, _, err := setupClient(c)
+	if err != nil {
+		return err
+	}
+
+	r, err := cl.Reload(ctx, &api.ReloadRequest{Path: c.String("config")})
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	out, err := client.ParseReloadResponse(r, c.App.Writer)
+	if err != nil {
+		return err
+	}
+
+	if len(out.Changed) == 0 {
+		fmt.Fprintln(c.App.Writer, "env config reloaded; nothing changed")
+	} else {
+		fmt.Fprintf(c.App.Writer, "env config reloaded; applied: %s\n", strings.Join(out.Changed, ", "))
+	}
+	if len(out.Restart) > 0 {
+		fmt.Fprintf(c.App.Writer, "only applied on restart: %s\n", strings.Join(out.Restart, ", "))
+	}
+	return nil
+}
@@ -0,0 +1,440 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	goruntime "runtime"
+	"sort"
+	"strings"
+	"time"
+
+	dockerapi "github.com/docker/docker/api"
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/versions"
+	"github.com/docker/docker/client"
+	"github.com/urfave/cli/v2"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+var DoctorCommand = cli.Command{
+	Name:  "doctor",
+	Usage: "check the local environment has what the builders and runners need, and print how to fix what it lacks",
+	Description: "Checks the Docker daemon and the control network, the Go toolchain, Nix with flakes, the access to " +
+		"the Kubernetes cluster, cgroup v2, and the binfmt emulators of cross-architecture builds. By default, it " +
+		"checks what all builders and runners need; --builder and --runner limit it to theirs.",
+	Action: doctorCommand,
+	Flags: []cli.Flag{
+		&cli.StringSliceFlag{
+			Name:  "builder",
+			Usage: "only check what the `BUILDER` needs; can be repeated",
+		},
+		&cli.StringSliceFlag{
+			Name:  "runner",
+			Usage: "only check what the `RUNNER` needs; can be repeated",
+		},
+	},
+}
+
+const doctorTimeout = 10 * time.Second
+
+// doctorStatus is the outcome of a check of the doctor command.
+type doctorStatus string
+
+const (
+	doctorOK      = doctorStatus("ok")
+	doctorWarning = doctorStatus("warning")
+	doctorFailed  = doctorStatus("failed")
+	doctorSkipped = doctorStatus("skipped")
+)
+
+// doctorResult is the result of a check: what was found, and how to fix it.
+type doctorResult struct {
+	status  doctorStatus
+	message string
+	fix     string
+}
+
+func doctorOk(format string, args ...interface{}) doctorResult {
+	return doctorResult{status: doctorOK, message: fmt.Sprintf(format, args...)}
+}
+
+// doctorCheck checks something builders or runners need. Failed optional
+// checks are only warnings, as they are only needed by some plans.
+type doctorCheck struct {
+	name     string
+	neededBy []string
+	optional bool
+	run      func(ctx context.Context, env *doctorEnv) doctorResult
+}
+
+// doctorEnv is shared by the checks, so that they skip what depends on what
+// failed.
+type doctorEnv struct {
+	docker *client.Client
+}
+
+var doctorChecks = []doctorCheck{
+	{
+		name:     "docker daemon",
+		neededBy: []string{"docker:go", "docker:node", "docker:generic", "local:docker", "local:exec", "cluster:k8s"},
+		run:      doctorDocker,
+	},
+	{
+		name:     "control network",
+		neededBy: []string{"local:docker", "local:exec"},
+		optional: true,
+		run:      doctorControlNetwork,
+	},
+	{
+		name:     "go toolchain",
+		neededBy: []string{"exec:go"},
+		run:      doctorGo,
+	},
+	{
+		name:     "nix",
+		neededBy: []string{"docker:generic"},
+		optional: true,
+		run:      doctorNix,
+	},
+	{
+		name:     "kubernetes cluster",
+		neededBy: []string{"cluster:k8s"},
+		run:      doctorKubernetes,
+	},
+	{
+		name:     "cgroup v2",
+		neededBy: []string{"local:docker"},
+		optional: true,
+		run:      doctorCgroup,
+	},
+	{
+		name:     "binfmt emulators",
+		neededBy: []string{"docker:go", "docker:generic"},
+		optional: true,
+		run:      doctorBinfmt,
+	},
+}
+
+func doctorCommand(c *cli.Context) error {
+	ctx, cancel := context.WithCancel(ProcessContext())
+	defer cancel()
+
+	selected := append(c.StringSlice("builder"), c.StringSlice("runner")...)
+	for _, s := range selected {
+		if !doctorKnown(s) {
+			return fmt.Errorf("nothing to check for %q; checks are for %s", s, strings.Join(doctorTargets(), ", "))
+		}
+	}
+
+	env := new(doctorEnv)
+	defer func() {
+		if env.docker != nil {
+			_ = env.docker.Close()
+		}
+	}()
+
+	var failed []string
+	for _, check := range doctorChecks {
+		needed := doctorNeeded(check, selected)
+		if len(needed) == 0 {
+			continue
+		}
+
+		cctx, cancel := context.WithTimeout(ctx, doctorTimeout)
+		res := check.run(cctx, env)
+		cancel()
+
+		if res.status == doctorFailed && check.optional {
+			res.status = doctorWarning
+		}
+		if res.status == doctorFailed {
+			failed = append(failed, check.name)
+		}
+		printDoctorResult(c.App.Writer, check.name, needed, res)
+	}
+
+	if len(failed) > 0 {
+		return fmt.Errorf("%d checks failed: %s", len(failed), strings.Join(failed, ", "))
+	}
+	return nil
+}
+
+// doctorTargets returns the builders and runners the checks are for.
+func doctorTargets() []string {
+	seen := make(map[string]bool)
+	var targets []string
+	for _, check := range doctorChecks {
+		for _, n := range check.neededBy {
+			if !seen[n] {
+				seen[n] = true
+				targets = append(targets, n)
+			}
+		}
+	}
+	sort.Strings(targets)
+	return targets
+}
+
+func doctorKnown(s string) bool {
+	for _, check := range doctorChecks {
+		if doctorNeeds(check, s) {
+			return true
+		}
+	}
+	return false
+}
+
+func doctorNeeds(check doctorCheck, s string) bool {
+	for _, n := range check.neededBy {
+		if n == s {
+			return true
+		}
+	}
+	return false
+}
+
+// doctorNeeded returns the selected builders and runners that need the check;
+// all of those that do if none are selected.
+func doctorNeeded(check doctorCheck, selected []string) []string {
+	if len(selected) == 0 {
+		return check.neededBy
+	}
+	var needed []string
+	for _, s := range selected {
+		if doctorNeeds(check, s) {
+			needed = append(needed, s)
+		}
+	}
+	return needed
+}
+
+func printDoctorResult(w io.Writer, name string, needed []string, res doctorResult) {
+	_, _ = fmt.Fprintf(w, "- %s: %s; %s\n", name, res.status, res.message)
+	if res.status == doctorFailed || res.status == doctorWarning {
+		_, _ = fmt.Fprintf(w, "    needed by: %s\n", strings.Join(needed, ", "))
+		if res.fix != "" {
+			_, _ = fmt.Fprintf(w, "    fix: %s\n", res.fix)
+		}
+	}
+}
+
+func doctorDocker(ctx context.Context, env *doctorEnv) doctorResult {
+	cli, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
+	if err != nil {
+		return doctorResult{status: doctorFailed, message: err.Error(), fix: "check DOCKER_HOST, DOCKER_CERT_PATH and DOCKER_TLS_VERIFY"}
+	}
+
+	v, err := cli.ServerVersion(ctx)
+	if err != nil {
+		_ = cli.Close()
+		return doctorResult{
+			status:  doctorFailed,
+			message: fmt.Sprintf("the Docker daemon at %s is unreachable: %s", cli.DaemonHost(), err),
+			fix:     "start the Docker daemon, or set DOCKER_HOST to where it listens; check your user can access its socket, e.g. is in the docker group",
+		}
+	}
+	env.docker = cli
+
+	if versions.LessThan(v.APIVersion, dockerapi.DefaultVersion) {
+		return doctorResult{
+			status:  doctorWarning,
+			message: fmt.Sprintf("Docker %s, API %s, older than the API %s of testground", v.Version, v.APIVersion, dockerapi.DefaultVersion),
+			fix:     "upgrade Docker",
+		}
+	}
+	return doctorOk("Docker %s, API %s, %s/%s", v.Version, v.APIVersion, v.Os, v.Arch)
+}
+
+func doctorControlNetwork(ctx context.Context, env *doctorEnv) doctorResult {
+	if env.docker == nil {
+		return doctorResult{status: doctorSkipped, message: "the Docker daemon is unreachable"}
+	}
+
+	nw, err := env.docker.NetworkInspect(ctx, "testground-control", types.NetworkInspectOptions{})
+	if client.IsErrNotFound(err) {
+		return doctorResult{
+			status:  doctorFailed,
+			message: "the testground-control network doesn't exist",
+			fix:     "the daemon creates it on the first run; or run `testground healthcheck --runner local:docker --fix`",
+		}
+	}
+	if err != nil {
+		return doctorResult{status: doctorFailed, message: err.Error()}
+	}
+
+	var subnets []string
+	for _, c := range nw.IPAM.Config {
+		subnets = append(subnets, c.Subnet)
+	}
+	return doctorOk("testground-control exists, %s", strings.Join(subnets, ", "))
+}
+
+func doctorGo(ctx context.Context, _ *doctorEnv) doctorResult {
+	if _, err := exec.LookPath("go"); err != nil {
+		return doctorResult{status: doctorFailed, message: "go is not in the PATH", fix: "install Go from https://go.dev/dl/ and add it to the PATH"}
+	}
+	out, err := exec.CommandContext(ctx, "go", "version").Output()
+	if err != nil {
+		return doctorResult{status: doctorFailed, message: fmt.Sprintf("go version failed: %s", err)}
+	}
+	return doctorOk("%s", strings.TrimSpace(string(out)))
+}
+
+func doctorNix(ctx context.Context, _ *doctorEnv) doctorResult {
+	if _, err := exec.LookPath("nix"); err != nil {
+		return doctorResult{
+			status:  doctorFailed,
+			message: "nix is not in the PATH; only needed to build plans with Nix",
+			fix:     "install Nix from https://nixos.org/download",
+		}
+	}
+
+	out, err := exec.CommandContext(ctx, "nix", "--version").Output()
+	if err != nil {
+		return doctorResult{status: doctorFailed, message: fmt.Sprintf("nix --version failed: %s", err)}
+	}
+	version := strings.TrimSpace(string(out))
+
+	// nix config show superseded nix show-config.
+	conf, err := exec.CommandContext(ctx, "nix", "config", "show").Output()
+	if err != nil {
+		conf, err = exec.CommandContext(ctx, "nix", "show-config").Output()
+	}
+	if err != nil {
+		return doctorResult{status: doctorFailed, message: fmt.Sprintf("%s; failed to show its config: %s", version, err)}
+	}
+	if !nixFlakesEnabled(string(conf)) {
+		return doctorResult{
+			status:  doctorFailed,
+			message: fmt.Sprintf("%s, without flakes", version),
+			fix:     "add `experimental-features = nix-command flakes` to ~/.config/nix/nix.conf, or /etc/nix/nix.conf",
+		}
+	}
+	return doctorOk("%s, with flakes", version)
+}
+
+// nixFlakesEnabled returns whether the output of nix config show enables the
+// flakes.
+func nixFlakesEnabled(conf string) bool {
+	for _, line := range strings.Split(conf, "\n") {
+		kv := strings.SplitN(line, "=", 2)
+		if len(kv) != 2 || strings.TrimSpace(kv[0]) != "experimental-features" {
+			continue
+		}
+		for _, f := range strings.Fields(kv[1]) {
+			if f == "flakes" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func doctorKubernetes(ctx context.Context, _ *doctorEnv) doctorResult {
+	// The same kubeconfig as the cluster:k8s runner.
+	home, _ := os.UserHomeDir()
+	kubeconfig := filepath.Join(home, ".kube", "config")
+	if _, err := os.Stat(kubeconfig); err != nil {
+		return doctorResult{
+			status:  doctorFailed,
+			message: fmt.Sprintf("no kubeconfig at %s", kubeconfig),
+			fix:     "write the kubeconfig of the cluster to ~/.kube/config, e.g. with `aws eks update-kubeconfig`",
+		}
+	}
+
+	cfg, err := clientcmd.BuildConfigFromFlags("", kubeconfig)
+	if err != nil {
+		return doctorResult{status: doctorFailed, message: fmt.Sprintf("invalid kubeconfig %s: %s", kubeconfig, err)}
+	}
+	cfg.Timeout = doctorTimeout
+
+	k8s, err := kubernetes.NewForConfig(cfg)
+	if err != nil {
+		return doctorResult{status: doctorFailed, message: err.Error()}
+	}
+	v, err := k8s.Discovery().ServerVersion()
+	if err != nil {
+		return doctorResult{
+			status:  doctorFailed,
+			message: fmt.Sprintf("the cluster at %s is unreachable: %s", cfg.Host, err),
+			fix:     "check the current context of ~/.kube/config, and that its credentials haven't expired",
+		}
+	}
+
+	msg := fmt.Sprintf("Kubernetes %s at %s", v.GitVersion, cfg.Host)
+	if _, err := exec.LookPath("kubectl"); err != nil {
+		return doctorResult{
+			status:  doctorWarning,
+			message: msg + "; kubectl is not in the PATH",
+			fix:     "install kubectl, to set up the cluster and inspect the pods of runs",
+		}
+	}
+	return doctorOk("%s", msg)
+}
+
+func doctorCgroup(_ context.Context, _ *doctorEnv) doctorResult {
+	if goruntime.GOOS != "linux" {
+		return doctorResult{status: doctorSkipped, message: "Docker runs in a VM on " + goruntime.GOOS}
+	}
+	if cgroupV2("/sys/fs/cgroup") {
+		return doctorOk("the unified hierarchy is mounted")
+	}
+	return doctorResult{
+		status:  doctorFailed,
+		message: "cgroup v1; rootless Docker can't limit the resources of the instances",
+		fix:     "boot with systemd.unified_cgroup_hierarchy=1 on the kernel command line",
+	}
+}
+
+// cgroupV2 returns whether the cgroup filesystem mounted at root is the
+// unified hierarchy of cgroup v2.
+func cgroupV2(root string) bool {
+	_, err := os.Stat(filepath.Join(root, "cgroup.controllers"))
+	return err == nil
+}
+
+func doctorBinfmt(_ context.Context, _ *doctorEnv) doctorResult {
+	if goruntime.GOOS != "linux" {
+		return doctorResult{status: doctorSkipped, message: "Docker emulates other architectures in its VM on " + goruntime.GOOS}
+	}
+	emulators, err := binfmtEmulators("/proc/sys/fs/binfmt_misc")
+	if err != nil || len(emulators) == 0 {
+		return doctorResult{
+			status:  doctorFailed,
+			message: "no emulators registered; only needed to build for other platforms",
+			fix:     "run `docker run --privileged --rm tonistiigi/binfmt --install all`",
+		}
+	}
+	return doctorOk("emulators for %s", strings.Join(emulators, ", "))
+}
+
+// binfmtEmulators returns the architectures of the enabled qemu emulators
+// registered with binfmt_misc, mounted at dir.
+func binfmtEmulators(dir string) ([]string, error) {
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var archs []string
+	for _, e := range entries {
+		if !strings.HasPrefix(e.Name(), "qemu-") {
+			continue
+		}
+		b, err := ioutil.ReadFile(filepath.Join(dir, e.Name()))
+		if err != nil {
+			return nil, err
+		}
+		if strings.HasPrefix(string(b), "enabled") {
+			archs = append(archs, strings.TrimPrefix(e.Name(), "qemu-"))
+		}
+	}
+	sort.Strings(archs)
+	return archs, nil
+}
@@ -0,0 +1,52 @@
+package cmd
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNixFlakesEnabled(t *testing.T) {
+	require.True(t, nixFlakesEnabled("cores = 0\nexperimental-features = flakes nix-command\nsandbox = true\n"))
+	require.False(t, nixFlakesEnabled("experimental-features = nix-command\n"))
+	require.False(t, nixFlakesEnabled("extra-experimental-features-flakes = true\n"))
+	require.False(t, nixFlakesEnabled(""))
+}
+
+func TestBinfmtEmulators(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) {
+		require.NoError(t, ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644))
+	}
+	write("register", "")
+	write("status", "enabled\n")
+	write("qemu-aarch64", "enabled\ninterpreter /usr/bin/qemu-aarch64\n")
+	write("qemu-arm", "disabled\ninterpreter /usr/bin/qemu-arm\n")
+	write("qemu-riscv64", "enabled\ninterpreter /usr/bin/qemu-riscv64\n")
+
+	archs, err := binfmtEmulators(dir)
+	require.NoError(t, err)
+	require.Equal(t, []string{"aarch64", "riscv64"}, archs)
+
+	_, err = binfmtEmulators(filepath.Join(dir, "missing"))
+	require.Error(t, err)
+}
+
+func TestCgroupV2(t *testing.T) {
+	dir := t.TempDir()
+	require.False(t, cgroupV2(dir))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "cgroup.controllers"), []byte("cpu memory\n"), 0644))
+	require.True(t, cgroupV2(dir))
+}
+
+func TestDoctorNeeded(t *testing.T) {
+	check := doctorCheck{neededBy: []string{"docker:go", "local:docker"}}
+	require.Equal(t, []string{"docker:go", "local:docker"}, doctorNeeded(check, nil))
+	require.Equal(t, []string{"local:docker"}, doctorNeeded(check, []string{"exec:go", "local:docker"}))
+	require.Empty(t, doctorNeeded(check, []string{"exec:go"}))
+
+	require.True(t, doctorKnown("cluster:k8s"))
+	require.False(t, doctorKnown("local:nope"))
+}
@@ -0,0 +1,40 @@
+package cmd
+
+import (
+	"context"
+
+	"github.com/urfave/cli/v2"
+
+	"github.com/testground/testground/pkg/api"
+	"github.com/testground/testground/pkg/client"
+)
+
+var DrainCommand = cli.Command{
+	Name:   "drain",
+	Usage:  "stop the daemon from accepting new tasks, and wait for the running ones to complete before shutting it down",
+	Action: drainCommand,
+	Flags: []cli.Flag{
+		&cli.BoolFlag{
+			Name:  "resume",
+			Usage: "accept and pick tasks again after draining",
+		},
+	},
+}
+
+func drainCommand(c *cli.Context) error {
+	ctx, cancel := context.WithCancel(ProcessContext())
+	defer cancel()
+
+	cl, _, err := setupClient(c)
+	if err != nil {
+		return err
+	}
+
+	r, err := cl.Drain(ctx, &api.DrainRequest{Resume: c.Bool("resume")})
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	return client.ParseDrainResponse(r, c.App.Writer)
+}
@@ -0,0 +1,91 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/testground/testground/pkg/api"
+
+	"github.com/urfave/cli/v2"
+)
+
+var EventsCommand = cli.Command{
+	Name:   "events",
+	Usage:  "follow the lifecycle events of tasks: queued, builds, instances, and outcomes",
+	Action: eventsCommand,
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:    "task",
+			Aliases: []string{"t"},
+			Usage:   "only the events of the task with `ID`",
+		},
+		&cli.StringFlag{
+			Name:  "plan",
+			Usage: "only the events of the tasks of the `PLAN`",
+		},
+		&cli.StringFlag{
+			Name:  "type",
+			Usage: "only the events of the comma-separated `TYPES`, e.g. task_queued,task_completed",
+		},
+		&cli.BoolFlag{
+			Name:  "json",
+			Usage: "print the events as JSON, one per line",
+		},
+	},
+}
+
+func eventsCommand(c *cli.Context) error {
+	ctx, cancel := context.WithCancel(ProcessContext())
+	defer cancel()
+
+	types, err := api.ParseEventTypes(c.String("type"))
+	if err != nil {
+		return err
+	}
+	filter := api.EventFilter{TaskID: c.String("task"), Plan: c.String("plan"), Types: types}
+
+	cl, _, err := setupClient(c)
+	if err != nil {
+		return err
+	}
+
+	enc := json.NewEncoder(c.App.Writer)
+	err = cl.Events(ctx, filter, func(ev *api.Event) error {
+		if c.Bool("json") {
+			return enc.Encode(ev)
+		}
+		printEvent(c.App.Writer, ev)
+		return nil
+	})
+	if ctx.Err() != nil {
+		return nil
+	}
+	return err
+}
+
+// printEvent prints an event on a line: its time, type and task, and what
+// it's about.
+func printEvent(w io.Writer, ev *api.Event) {
+	fields := []string{ev.Time.Local().Format(time.RFC3339), string(ev.Type), ev.TaskID}
+	add := func(k, v string) {
+		if v != "" {
+			fields = append(fields, k+"="+v)
+		}
+	}
+	add("plan", strings.TrimSuffix(ev.Plan+":"+ev.Case, ":"))
+	add("groups", strings.Join(ev.Groups, ","))
+	add("builder", ev.Builder)
+	add("artifact", ev.Artifact)
+	add("group", ev.Group)
+	add("instance", ev.Instance)
+	if ev.ExitCode != 0 {
+		add("exit_code", fmt.Sprint(ev.ExitCode))
+	}
+	add("outcome", string(ev.Outcome))
+	add("error", ev.Error)
+	fmt.Fprintln(w, strings.Join(fields, " "))
+}
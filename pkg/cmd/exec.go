@@ -0,0 +1,108 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/urfave/cli/v2"
+	"golang.org/x/term"
+
+	"github.com/testground/testground/pkg/api"
+)
+
+// ExecCommand is the specification of the `exec` command.
+var ExecCommand = cli.Command{
+	Name:      "exec",
+	Usage:     "run a command in an instance of a running task, e.g. a shell, and exit with its exit code",
+	ArgsUsage: "-- COMMAND [ARG...]",
+	Action:    execCmd,
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:     "task",
+			Usage:    "`ID` of the running task",
+			Required: true,
+		},
+		&cli.StringFlag{
+			Name:     "group",
+			Usage:    "`ID` of the group of the instance",
+			Required: true,
+		},
+		&cli.IntFlag{
+			Name:  "instance",
+			Usage: "`INDEX` of the instance in its group",
+		},
+		&cli.BoolFlag{
+			Name:        "tty",
+			Aliases:     []string{"t"},
+			Usage:       "allocate a terminal to the command",
+			DefaultText: "true if stdin is a terminal",
+		},
+	},
+}
+
+// pauseOnFailureFlag and pauseTimeoutFlag are the flags of the run commands
+// that keep the instances of failed runs alive for inspection.
+var pauseOnFailureFlag = &cli.BoolFlag{
+	Name:  "pause-on-failure",
+	Usage: "keep the instances of failed runs alive for inspection with testground exec, until the task is canceled or --pause-timeout elapses",
+}
+
+var pauseTimeoutFlag = &cli.DurationFlag{
+	Name:  "pause-timeout",
+	Usage: "how long failed runs pause with --pause-on-failure",
+	Value: api.DefaultPauseOnFailure,
+}
+
+// pauseOnFailureSecs returns how long failed runs pause, in seconds.
+func pauseOnFailureSecs(c *cli.Context) int {
+	if !c.Bool("pause-on-failure") {
+		return 0
+	}
+	return int(c.Duration("pause-timeout").Seconds())
+}
+
+func execCmd(c *cli.Context) error {
+	ctx, cancel := context.WithCancel(ProcessContext())
+	defer cancel()
+
+	if c.NArg() == 0 {
+		return errors.New("missing command to run, e.g. testground exec --task <id> --group <group> -- /bin/sh")
+	}
+
+	cl, _, err := setupClient(c)
+	if err != nil {
+		return err
+	}
+
+	tty := term.IsTerminal(int(os.Stdin.Fd()))
+	if c.IsSet("tty") {
+		tty = c.Bool("tty")
+	}
+
+	// Pass the keys typed to the command as is.
+	if tty && term.IsTerminal(int(os.Stdin.Fd())) {
+		state, err := term.MakeRaw(int(os.Stdin.Fd()))
+		if err != nil {
+			return fmt.Errorf("failed to set the terminal to raw mode: %w", err)
+		}
+		defer term.Restore(int(os.Stdin.Fd()), state)
+	}
+
+	out, err := cl.Exec(ctx, &api.ExecRequest{
+		TaskID:   c.String("task"),
+		Group:    c.String("group"),
+		Instance: c.Int("instance"),
+		Cmd:      c.Args().Slice(),
+		Tty:      tty,
+	}, os.Stdin, c.App.Writer, c.App.ErrWriter)
+	if err != nil {
+		return err
+	}
+
+	if out.ExitCode != 0 {
+		return cli.Exit("", out.ExitCode)
+	}
+	return nil
+}
@@ -0,0 +1,75 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"text/tabwriter"
+	"time"
+
+	"github.com/dustin/go-humanize"
+	"github.com/urfave/cli/v2"
+
+	"github.com/testground/testground/pkg/api"
+	"github.com/testground/testground/pkg/client"
+)
+
+var GCCommand = cli.Command{
+	Name:  "gc",
+	Usage: "remove the run outputs, cached sources and build artifacts selected by the retention policies of the daemon",
+	Flags: []cli.Flag{
+		&cli.BoolFlag{
+			Name:  "dry-run",
+			Usage: "only report what would be removed",
+		},
+	},
+	Action: gcCommand,
+}
+
+func gcCommand(c *cli.Context) error {
+	ctx, cancel := context.WithCancel(ProcessContext())
+	defer cancel()
+
+	cl, _, err := setupClient(c)
+	if err != nil {
+		return err
+	}
+
+	r, err := cl.GC(ctx, &api.GCRequest{DryRun: c.Bool("dry-run")})
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	report, err := client.ParseGCResponse(r, c.App.Writer)
+	if err != nil {
+		return err
+	}
+
+	if len(report.Items) == 0 {
+		fmt.Fprintln(c.App.Writer, "nothing to remove")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(c.App.Writer, 0, 0, 3, ' ', 0)
+
+	fmt.Fprintln(w, "KIND\tPLAN\tID\tCREATED\tSIZE\tREASON")
+
+	for _, item := range report.Items {
+		kind := item.Kind
+		if item.Builder != "" {
+			kind += " (" + item.Builder + ")"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", kind, item.Plan, item.ID, item.Created.Format(time.RFC3339), humanize.Bytes(uint64(item.Size)), item.Reason)
+	}
+
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	verb := "freed"
+	if report.DryRun {
+		verb = "would free"
+	}
+	fmt.Fprintf(c.App.Writer, "%s %s\n", verb, humanize.Bytes(uint64(report.Freed)))
+	return nil
+}
@@ -0,0 +1,128 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/testground/testground/pkg/api"
+	"github.com/testground/testground/pkg/config"
+	"github.com/testground/testground/pkg/engine"
+	"github.com/testground/testground/pkg/logging"
+
+	"github.com/urfave/cli/v2"
+)
+
+func lintCommand(c *cli.Context) error {
+	cfg := &config.EnvConfig{}
+	if err := cfg.Load(); err != nil {
+		return err
+	}
+
+	file := c.String("file")
+	issues, err := lintComposition(cfg, file, c.StringSlice("set"), true)
+	if err != nil {
+		return err
+	}
+
+	for _, i := range issues {
+		_, _ = fmt.Fprintln(c.App.Writer, i)
+	}
+
+	if errs := api.LintErrors(issues); len(errs) > 0 {
+		return fmt.Errorf("found %d problems in composition %s", len(errs), file)
+	}
+	logging.S().Infof("composition %s is valid", file)
+	return nil
+}
+
+// lintBeforeSubmit lints a composition file before building or running it,
+// and fails if it has problems.
+func lintBeforeSubmit(c *cli.Context, file string, forRun bool) error {
+	_, cfg, err := setupClient(c)
+	if err != nil {
+		return err
+	}
+
+	issues, err := lintComposition(cfg, file, c.StringSlice("set"), forRun)
+	if err != nil {
+		return err
+	}
+
+	for _, i := range issues {
+		if i.Warning {
+			logging.S().Warn(i.String())
+		}
+	}
+
+	errs := api.LintErrors(issues)
+	if len(errs) == 0 {
+		return nil
+	}
+	for _, i := range errs {
+		logging.S().Error(i.String())
+	}
+	return fmt.Errorf("found %d problems in composition %s; see `testground plan lint`", len(errs), file)
+}
+
+// lintComposition checks a composition file, and the files it extends. It
+// checks the keys of every file against the schema of compositions and, if
+// forRun is true, the loaded composition against the manifest of its plan.
+//
+// The issues are located in the file that sets the offending key, the last
+// one if several do.
+func lintComposition(cfg *config.EnvConfig, path string, set []string, forRun bool) ([]*api.LintIssue, error) {
+	type lintedFile struct {
+		path    string
+		locator *api.KeyLocator
+	}
+
+	var (
+		files  []lintedFile
+		issues []*api.LintIssue
+	)
+	locate := func(i *api.LintIssue) {
+		for _, f := range files {
+			if line, col, ok := f.locator.Locate(i.Key); ok {
+				i.File, i.Line, i.Column = f.path, line, col
+				return
+			}
+		}
+		i.File = path
+	}
+
+	comp, err := loadCompositionVisiting(path, set, func(path string, src []byte, tree map[string]interface{}) {
+		f := lintedFile{path: path, locator: api.NewKeyLocatorFor(api.CompositionFormatOf(path), src)}
+		files = append(files, f)
+		for _, i := range api.LintCompositionTree(tree) {
+			i.File = path
+			i.Line, i.Column, _ = f.locator.Locate(i.Key)
+			issues = append(issues, i)
+		}
+	})
+	if err != nil {
+		// Syntax errors, and unprocessable compositions, e.g. with invalid
+		// sweeps; the error tells where.
+		return append(issues, &api.LintIssue{Message: err.Error()}), nil
+	}
+
+	if len(api.LintErrors(issues)) > 0 || !forRun {
+		return issues, nil
+	}
+
+	_, manifest, err := resolveTestPlan(cfg, comp.Global.Plan)
+	if err != nil {
+		i := &api.LintIssue{Key: "global.plan", Message: err.Error()}
+		locate(i)
+		return append(issues, i), nil
+	}
+
+	compatible := make(map[string][]string, len(engine.AllRunners))
+	for _, r := range engine.AllRunners {
+		compatible[r.ID()] = r.CompatibleBuilders()
+	}
+
+	for _, i := range comp.Lint(manifest, compatible) {
+		locate(i)
+		issues = append(issues, i)
+	}
+	return issues, nil
+}
@@ -0,0 +1,44 @@
+package cmd
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/testground/testground/pkg/config"
+)
+
+func TestLintComposition(t *testing.T) {
+	prev, had := os.LookupEnv(config.EnvTestgroundHomeDir)
+	require.NoError(t, os.Setenv(config.EnvTestgroundHomeDir, "fixtures/lint"))
+	defer func() {
+		if had {
+			os.Setenv(config.EnvTestgroundHomeDir, prev)
+		} else {
+			os.Unsetenv(config.EnvTestgroundHomeDir)
+		}
+	}()
+
+	cfg := &config.EnvConfig{}
+	require.NoError(t, cfg.Load())
+
+	const file = "fixtures/lint/composition.toml"
+
+	// The schema is checked first.
+	issues, err := lintComposition(cfg, file, []string{"latency=fast"}, true)
+	require.NoError(t, err)
+	require.Len(t, issues, 1)
+	require.Equal(t, file+":4:3: global.disable_metric: unknown key disable_metric; did you mean disable_metrics?", issues[0].String())
+
+	// Then the composition, against the manifest.
+	const inline = "fixtures/lint/inline.toml"
+	issues, err = lintComposition(cfg, inline, []string{"latency=fast"}, true)
+	require.NoError(t, err)
+	require.Len(t, issues, 1)
+	require.Equal(t, inline+":5:27: groups.peers.run.test_params.latency: invalid value \"fast\" of int parameter: invalid syntax", issues[0].String())
+
+	issues, err = lintComposition(cfg, inline, []string{"latency=20"}, true)
+	require.NoError(t, err)
+	require.Empty(t, issues)
+}
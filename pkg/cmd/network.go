@@ -0,0 +1,134 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/testground/sdk-go/network"
+	"github.com/urfave/cli/v2"
+
+	"github.com/testground/testground/pkg/api"
+	"github.com/testground/testground/pkg/client"
+)
+
+var NetworkCommand = cli.Command{
+	Name:  "network",
+	Usage: "change the data network of the instances of a running task",
+	Subcommands: cli.Commands{
+		&cli.Command{
+			Name:  "set",
+			Usage: "inject a network fault in a running task, and wait for the instances to apply it",
+			Flags: []cli.Flag{
+				&cli.StringFlag{
+					Name:     "task",
+					Usage:    "`ID` of the running task",
+					Required: true,
+				},
+				&cli.StringFlag{
+					Name:  "group",
+					Usage: "`ID` of the group to inject the fault in; all the instances apply it if unset",
+				},
+				&cli.DurationFlag{
+					Name:  "latency",
+					Usage: "egress latency of the data link",
+				},
+				&cli.DurationFlag{
+					Name:  "jitter",
+					Usage: "egress jitter of the data link",
+				},
+				&cli.Uint64Flag{
+					Name:  "bandwidth",
+					Usage: "egress bandwidth of the data link, in bytes per second",
+				},
+				&cli.Float64Flag{
+					Name:  "loss",
+					Usage: "egress packet loss of the data link, in percent",
+				},
+				&cli.StringSliceFlag{
+					Name:  "partition",
+					Usage: "`GROUP` to split from the rest of the run; can be repeated",
+				},
+				&cli.StringSliceFlag{
+					Name:  "blackhole",
+					Usage: "instance name, address or subnet (`PEER`) to drop the traffic to; can be repeated",
+				},
+				&cli.BoolFlag{
+					Name:  "heal",
+					Usage: "lift the faults injected earlier, before injecting this one",
+				},
+				&cli.DurationFlag{
+					Name:  "timeout",
+					Usage: "how long to wait for the instances to apply the fault",
+					Value: defaultNetworkFaultTimeout,
+				},
+			},
+			Action: networkSetCommand,
+		},
+	},
+}
+
+const defaultNetworkFaultTimeout = 30 * time.Second
+
+func networkSetCommand(c *cli.Context) error {
+	ctx, cancel := context.WithCancel(ProcessContext())
+	defer cancel()
+
+	fault := &api.NetworkFault{
+		Group:     c.String("group"),
+		Partition: c.StringSlice("partition"),
+		Blackhole: c.StringSlice("blackhole"),
+		Heal:      c.Bool("heal"),
+	}
+	if c.IsSet("latency") || c.IsSet("jitter") || c.IsSet("bandwidth") || c.IsSet("loss") {
+		fault.Shape = &network.LinkShape{
+			Latency:   c.Duration("latency"),
+			Jitter:    c.Duration("jitter"),
+			Bandwidth: c.Uint64("bandwidth"),
+			Loss:      float32(c.Float64("loss")),
+		}
+	}
+	if fault.Shape == nil && len(fault.Partition) == 0 && len(fault.Blackhole) == 0 && !fault.Heal {
+		return fmt.Errorf("nothing to inject: set a shape, --partition, --blackhole or --heal")
+	}
+
+	cl, _, err := setupClient(c)
+	if err != nil {
+		return err
+	}
+
+	r, err := cl.InjectNetworkFault(ctx, &api.NetworkFaultRequest{
+		TaskID:      c.String("task"),
+		Fault:       fault,
+		TimeoutSecs: int(c.Duration("timeout").Seconds()),
+	})
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	out, err := client.ParseNetworkFaultResponse(r, c.App.Writer)
+	if err != nil {
+		return err
+	}
+
+	var failed int
+	for _, ev := range out.Events {
+		if ev.Error != "" {
+			fmt.Fprintf(c.App.Writer, "%s: failed: %s\n", ev.Instance, ev.Error)
+			failed++
+			continue
+		}
+		fmt.Fprintf(c.App.Writer, "%s: applied after %s; blocked: [%s]\n", ev.Instance, ev.Time.Sub(out.Fault.Time).Round(time.Millisecond), strings.Join(ev.Blocked, " "))
+	}
+
+	fmt.Fprintf(c.App.Writer, "fault %s applied by %d/%d instances\n", out.Fault.ID, len(out.Events)-failed, out.Expected)
+	switch {
+	case failed > 0:
+		return fmt.Errorf("fault %s failed on %d instances", out.Fault.ID, failed)
+	case len(out.Events) < out.Expected:
+		return fmt.Errorf("fault %s was not applied by all instances", out.Fault.ID)
+	}
+	return nil
+}
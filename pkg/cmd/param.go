@@ -0,0 +1,96 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/urfave/cli/v2"
+
+	"github.com/testground/testground/pkg/api"
+	"github.com/testground/testground/pkg/client"
+)
+
+var ParamCommand = cli.Command{
+	Name:  "param",
+	Usage: "steer the instances of a running task",
+	Subcommands: cli.Commands{
+		&cli.Command{
+			Name:  "set",
+			Usage: "push a runtime parameter update to the instances of a running task, and wait for their acknowledgments",
+			Flags: []cli.Flag{
+				&cli.StringFlag{
+					Name:     "task",
+					Usage:    "`ID` of the running task",
+					Required: true,
+				},
+				&cli.StringFlag{
+					Name:  "group",
+					Usage: "`ID` of the group to push the update to; all the instances receive it if unset",
+				},
+				&cli.StringFlag{
+					Name:     "key",
+					Usage:    "`NAME` of the parameter",
+					Required: true,
+				},
+				&cli.StringFlag{
+					Name:     "value",
+					Usage:    "new `VALUE` of the parameter",
+					Required: true,
+				},
+				&cli.DurationFlag{
+					Name:  "timeout",
+					Usage: "how long to wait for the instances to acknowledge the update",
+					Value: defaultParamTimeout,
+				},
+			},
+			Action: paramSetCommand,
+		},
+	},
+}
+
+const defaultParamTimeout = 30 * time.Second
+
+func paramSetCommand(c *cli.Context) error {
+	ctx, cancel := context.WithCancel(ProcessContext())
+	defer cancel()
+
+	cl, _, err := setupClient(c)
+	if err != nil {
+		return err
+	}
+
+	r, err := cl.PushParam(ctx, &api.ParamPushRequest{
+		TaskID:      c.String("task"),
+		Group:       c.String("group"),
+		Key:         c.String("key"),
+		Value:       c.String("value"),
+		TimeoutSecs: int(c.Duration("timeout").Seconds()),
+	})
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	out, err := client.ParseParamPushResponse(r, c.App.Writer)
+	if err != nil {
+		return err
+	}
+
+	var rejected int
+	for _, ack := range out.Acks {
+		if ack.Error != "" {
+			fmt.Fprintf(c.App.Writer, "%s: rejected: %s\n", ack.Instance, ack.Error)
+			rejected++
+		}
+	}
+
+	fmt.Fprintf(c.App.Writer, "update %s (%s=%s) acknowledged by %d/%d instances\n", out.Update.ID, out.Update.Key, out.Update.Value, len(out.Acks), out.Expected)
+	switch {
+	case rejected > 0:
+		return fmt.Errorf("update %s was rejected by %d instances", out.Update.ID, rejected)
+	case len(out.Acks) < out.Expected:
+		return fmt.Errorf("update %s was not acknowledged by all instances", out.Update.ID)
+	}
+	return nil
+}
@@ -11,6 +11,7 @@ import (
 	"github.com/testground/testground/pkg/api"
 	"github.com/testground/testground/pkg/config"
 	"github.com/testground/testground/pkg/logging"
+	"github.com/testground/testground/pkg/plansource"
 
 	ttmpl "github.com/testground/plan-templates/templates"
 
@@ -58,10 +59,19 @@ var PlanCommand = cli.Command{
 		&cli.Command{
 			Name:  "import",
 			Usage: "import a plan from the local filesystem or a git repository into $TESTGROUND_HOME",
+			Description: `With --git, the source is either the URL of a repository, which is cloned
+   into $TESTGROUND_HOME/plans, or a remote source pinned to a commit:
+
+      https://github.com/org/plans//path/to/plan?ref=v1.2
+      github:org/plans/v1.2?dir=path/to/plan
+
+   Remote sources are fetched into a cache, at the commit their ref resolves
+   to. Builds and runs of the plan ask the daemon to fetch the plan at that
+   commit, and record it in their task.`,
 			Flags: []cli.Flag{
 				&cli.StringFlag{
 					Name:     "from",
-					Usage:    "the source `URL` of the plan to be imported; either a path, or a Git remote",
+					Usage:    "the source `URL` of the plan to be imported; either a path, a Git remote, or a remote source: a Git remote with a //DIR and a ?ref=REF, or a nix flake reference",
 					Required: true,
 				},
 				&cli.BoolFlag{
@@ -109,6 +119,23 @@ var PlanCommand = cli.Command{
 				},
 			},
 		},
+		&cli.Command{
+			Name:   "lint",
+			Usage:  "check a composition against the schema of compositions and the manifest of its plan",
+			Action: lintCommand,
+			Flags: []cli.Flag{
+				&cli.StringFlag{
+					Name:     "file",
+					Aliases:  []string{"f"},
+					Usage:    "path to a `COMPOSITION`",
+					Required: true,
+				},
+				&cli.StringSliceFlag{
+					Name:  "set",
+					Usage: "set a composition template variable, available as {{ .Vars.KEY }}, as `KEY=VALUE`",
+				},
+			},
+		},
 	},
 }
 
@@ -187,6 +214,10 @@ func importCommand(c *cli.Context) error {
 
 	from := c.String("from")
 
+	if c.Bool("git") && isRemoteSource(from) {
+		return importRemoteSource(c, cfg, from)
+	}
+
 	parsed, err := giturls.Parse(from)
 	if err != nil {
 		return err
@@ -234,6 +265,62 @@ func importCommand(c *cli.Context) error {
 	return err
 }
 
+// isRemoteSource returns whether a git source is a remote source, pinned to a
+// commit on import, rather than a repository to clone.
+func isRemoteSource(from string) bool {
+	if strings.HasPrefix(from, "github:") || strings.HasPrefix(from, "gitlab:") || strings.HasPrefix(from, "git+") {
+		return true
+	}
+	if strings.Contains(from, "?") {
+		return true
+	}
+	if i := strings.Index(from, "://"); i >= 0 {
+		from = from[i+len("://"):]
+	}
+	return strings.Contains(from, "//")
+}
+
+// importRemoteSource fetches a plan from a remote source, records the commit
+// it was fetched at, and symlinks it into the plans directory.
+func importRemoteSource(c *cli.Context, cfg *config.EnvConfig, from string) error {
+	src, err := plansource.Parse(from)
+	if err != nil {
+		return err
+	}
+
+	name := c.String("name")
+	if name == "" {
+		name = src.Name()
+	}
+	dstPath := filepath.Join(cfg.Dirs().Plans(), name)
+	if _, err := os.Lstat(dstPath); !os.IsNotExist(err) {
+		logging.S().Warnw("destination dir already exists", "path", dstPath)
+		return nil
+	}
+
+	pinned, dir, err := plansource.Fetch(c.Context, cfg.Dirs().PlanSources(), src, os.Stderr)
+	if err != nil {
+		return fmt.Errorf("could not fetch plan from %s: %w", src, err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "manifest.toml")); err != nil {
+		return fmt.Errorf("no plan manifest in %s at %s: %w", src, pinned.Commit, err)
+	}
+	if err := plansource.WriteRecord(dir, pinned); err != nil {
+		return fmt.Errorf("failed to record the source of the plan: %w", err)
+	}
+
+	if err := os.MkdirAll(cfg.Dirs().Plans(), 0755); err != nil {
+		return err
+	}
+	if err := symlinkPlan(dstPath, dir); err != nil {
+		return err
+	}
+	fmt.Printf("pinned plan %s to commit %s\n", name, pinned.Commit)
+	fmt.Println("imported plans:")
+	_ = printPlans(cfg, dstPath, true)
+	return nil
+}
+
 func symlinkPlan(dst, src string) error {
 	abs, err := filepath.Abs(src)
 	if err != nil {
@@ -0,0 +1,235 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+
+	"github.com/testground/testground/pkg/api"
+	"github.com/testground/testground/pkg/client"
+	"github.com/testground/testground/pkg/data"
+	"github.com/testground/testground/pkg/logging"
+	"github.com/testground/testground/pkg/task"
+	"github.com/testground/testground/pkg/version"
+
+	"github.com/urfave/cli/v2"
+)
+
+var replayCommand = &cli.Command{
+	Name:      "replay",
+	Usage:     "run a run again, from the composition, artifacts and configuration recorded in its provenance",
+	ArgsUsage: "[task id]",
+	Action:    replayCmd,
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:  "manifest",
+			Usage: "replay from the provenance in `FILENAME`, as written by `testground task provenance`, instead of a task",
+		},
+		&cli.BoolFlag{
+			Name:  "rebuild",
+			Usage: "rebuild the artifacts with their recorded build configuration, instead of running the recorded artifacts",
+		},
+		&cli.BoolFlag{
+			Name:  "wait",
+			Usage: "wait for the replay to complete",
+		},
+	},
+}
+
+func taskProvenanceCommand(c *cli.Context) error {
+	ctx, cancel := context.WithCancel(ProcessContext())
+	defer cancel()
+
+	if c.NArg() != 1 {
+		return errors.New("missing task id")
+	}
+
+	cl, _, err := setupClient(c)
+	if err != nil {
+		return err
+	}
+
+	prov, err := fetchProvenance(ctx, cl, c.Args().First())
+	if err != nil {
+		return err
+	}
+
+	var w io.Writer = c.App.Writer
+	if output := c.String("output"); output != "" {
+		f, err := os.Create(output)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(prov)
+}
+
+func replayCmd(c *cli.Context) error {
+	ctx, cancel := context.WithCancel(ProcessContext())
+	defer cancel()
+
+	cl, cfg, err := setupClient(c)
+	if err != nil {
+		return err
+	}
+
+	var (
+		prov *api.Provenance
+		from string
+	)
+	switch file := c.String("manifest"); {
+	case file != "" && c.NArg() > 0:
+		return errors.New("either a task id or --manifest is required, not both")
+	case file != "":
+		if prov, err = readProvenance(file); err != nil {
+			return err
+		}
+		from = file
+	case c.NArg() == 1:
+		from = c.Args().First()
+		if prov, err = fetchProvenance(ctx, cl, from); err != nil {
+			return err
+		}
+	default:
+		return errors.New("missing task id")
+	}
+
+	if prov.Version != version.GitCommit {
+		logging.S().Warnw("the run was executed by a different version of testground", "recorded", prov.Version, "current", version.GitCommit)
+	}
+
+	req, err := replayRequest(prov, c.Bool("rebuild"))
+	if err != nil {
+		return err
+	}
+	req.CreatedBy = api.CreatedBy{User: cfg.Client.User}
+
+	// Plans with a remote source are fetched by the daemon at the recorded
+	// commit; others are rebuilt from the local plan directory.
+	var src client.Sources
+	if len(req.BuildGroups) > 0 && req.PlanSource == nil {
+		planDir, _, err := resolveTestPlan(cfg, req.Composition.Global.Plan)
+		if err != nil {
+			return fmt.Errorf("failed to resolve test plan: %w", err)
+		}
+		logging.S().Warnw("the plan has no remote source; rebuilding from the local plan directory", "dir", planDir)
+		src.PlanDir = planDir
+	}
+
+	id, err := cl.CreateRun(ctx, req, src, c.App.Writer)
+	switch err {
+	case nil:
+	case context.Canceled:
+		return fmt.Errorf("interrupted")
+	default:
+		return err
+	}
+
+	logging.S().Infof("replay of %s queued with ID: %s", from, id)
+
+	if !c.Bool("wait") {
+		return nil
+	}
+
+	res, err := cl.WaitForTask(ctx, id, c.App.Writer)
+	if err != nil {
+		return err
+	}
+	if res.Task.Error != "" {
+		return errors.New(res.Task.Error)
+	}
+
+	if prov.PlanHash != "" {
+		if replayed, err := client.TaskProvenance(res.Task); err == nil && replayed != nil && replayed.PlanHash != "" && replayed.PlanHash != prov.PlanHash {
+			logging.S().Warnw("the replay was built from different plan sources than the run", "recorded", prov.PlanHash, "replayed", replayed.PlanHash)
+		}
+	}
+
+	return data.IsTaskOutcomeInError(res.Task)
+}
+
+// replayRequest returns the request replaying a run. The runner config is
+// pinned to the recorded one, and so is the build config of the groups if
+// they are rebuilt; otherwise the groups run the recorded artifacts.
+func replayRequest(prov *api.Provenance, rebuild bool) (*api.RunRequest, error) {
+	// Copy the composition through its JSON form, which the provenance was
+	// decoded from anyway, so the provenance is left as it is.
+	b, err := json.Marshal(prov.Composition)
+	if err != nil {
+		return nil, err
+	}
+	var comp api.Composition
+	if err := json.Unmarshal(b, &comp); err != nil {
+		return nil, err
+	}
+
+	comp.Global.RunConfig = prov.RunnerConfig
+
+	req := &api.RunRequest{
+		Composition: comp,
+		Manifest:    prov.Manifest,
+		RunIds:      []string{prov.RunID},
+		PlanSource:  prov.PlanSource,
+		Upstream:    prov.Upstream,
+	}
+
+	for i, g := range comp.Groups {
+		gp := prov.Group(g.ID)
+		if !rebuild {
+			if gp == nil || gp.Artifact == "" {
+				return nil, fmt.Errorf("no artifact recorded for group %s; replay with --rebuild", g.ID)
+			}
+			g.Run.Artifact = gp.Artifact
+			continue
+		}
+		if gp != nil && gp.Built {
+			g.BuildConfig = gp.BuildConfig
+		} else {
+			logging.S().Warnw("the build of the group is not recorded; rebuilding it with the current build config", "group", g.ID)
+		}
+		g.Run.Artifact = ""
+		req.BuildGroups = append(req.BuildGroups, i)
+	}
+	return req, nil
+}
+
+// fetchProvenance returns the provenance of a run task.
+func fetchProvenance(ctx context.Context, cl *client.Client, id string) (*api.Provenance, error) {
+	tsk, err := cl.GetTask(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if tsk.Type != task.TypeRun {
+		return nil, fmt.Errorf("task %s is a %s, not a run", id, tsk.Type)
+	}
+	prov, err := client.TaskProvenance(tsk)
+	if err != nil {
+		return nil, err
+	}
+	if prov == nil {
+		return nil, fmt.Errorf("task %s has no provenance; it didn't run, or it ran before testground recorded provenance", id)
+	}
+	return prov, nil
+}
+
+// readProvenance reads a provenance written by `testground task provenance`.
+func readProvenance(path string) (*api.Provenance, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var prov *api.Provenance
+	if err := json.Unmarshal(b, &prov); err != nil || prov == nil {
+		return nil, fmt.Errorf("failed to decode provenance %s: %v", path, err)
+	}
+	return prov, nil
+}
@@ -0,0 +1,80 @@
+package cmd
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/testground/testground/pkg/api"
+)
+
+func testProvenance() *api.Provenance {
+	return &api.Provenance{
+		RunID:      "run",
+		PlanSource: &api.PlanSource{URL: "https://github.com/org/plans", Commit: "0123"},
+		Composition: api.Composition{
+			Global: api.Global{Plan: "network", Case: "ping-pong", Runner: "local:docker", RunConfig: map[string]interface{}{"keep_service": false}},
+			Groups: api.Groups{
+				{ID: "pingers", Builder: "docker:go", Run: api.RunParams{Artifact: "sha256:a"}},
+				{ID: "pongers", Builder: "docker:go", Run: api.RunParams{Artifact: "sha256:b"}},
+			},
+			Runs: api.Runs{{ID: "run"}},
+		},
+		RunnerConfig: map[string]interface{}{"keep_service": true, "background": false},
+		Upstream:     map[string]string{"setup": "task1"},
+		Groups: []*api.GroupProvenance{
+			{ID: "pingers", Builder: "docker:go", Artifact: "sha256:a", Built: true, BuildConfig: map[string]interface{}{"go_version": "1.16"}},
+			{ID: "pongers", Builder: "docker:go", Artifact: "sha256:b"},
+		},
+	}
+}
+
+func TestReplayRequest(t *testing.T) {
+	prov := testProvenance()
+
+	req, err := replayRequest(prov, false)
+	require.NoError(t, err)
+	require.Equal(t, []string{"run"}, req.RunIds)
+	require.Empty(t, req.BuildGroups)
+	require.Equal(t, prov.PlanSource, req.PlanSource)
+	require.Equal(t, prov.Upstream, req.Upstream)
+	require.Equal(t, prov.RunnerConfig, req.Composition.Global.RunConfig)
+	require.Equal(t, "sha256:a", req.Composition.Groups[0].Run.Artifact)
+	require.Equal(t, "sha256:b", req.Composition.Groups[1].Run.Artifact)
+
+	// The provenance is left as it is.
+	require.Equal(t, map[string]interface{}{"keep_service": false}, prov.Composition.Global.RunConfig)
+
+	req, err = replayRequest(prov, true)
+	require.NoError(t, err)
+	require.Equal(t, []int{0, 1}, req.BuildGroups)
+	require.Empty(t, req.Composition.Groups[0].Run.Artifact)
+	require.Equal(t, map[string]interface{}{"go_version": "1.16"}, req.Composition.Groups[0].BuildConfig)
+	require.Equal(t, "sha256:a", prov.Composition.Groups[0].Run.Artifact)
+
+	// Without artifacts, runs can only be replayed by rebuilding them.
+	prov.Groups[1].Artifact = ""
+	_, err = replayRequest(prov, false)
+	require.Error(t, err)
+}
+
+func TestReadProvenance(t *testing.T) {
+	prov := testProvenance()
+	b, err := json.Marshal(prov)
+	require.NoError(t, err)
+
+	path := filepath.Join(t.TempDir(), "provenance.json")
+	require.NoError(t, ioutil.WriteFile(path, b, 0644))
+
+	read, err := readProvenance(path)
+	require.NoError(t, err)
+	require.Equal(t, prov.Groups, read.Groups)
+	require.Equal(t, prov.RunID, read.RunID)
+
+	require.NoError(t, ioutil.WriteFile(path, []byte("null"), 0644))
+	_, err = readProvenance(path)
+	require.Error(t, err)
+}
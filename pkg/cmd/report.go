@@ -0,0 +1,318 @@
+package cmd
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/testground/testground/pkg/api"
+	"github.com/testground/testground/pkg/logging"
+	"github.com/testground/testground/pkg/task"
+
+	"github.com/urfave/cli/v2"
+)
+
+const ReportOpt = "report"
+
+// githubSummaryEnv is where GitHub Actions expects the markdown summary of a
+// step to be appended.
+const githubSummaryEnv = "GITHUB_STEP_SUMMARY"
+
+var reportFlag = &cli.StringSliceFlag{
+	Name:  ReportOpt,
+	Usage: "write a report of the results as `FORMAT=PATH`; formats: junit, github (a step summary, appended to $GITHUB_STEP_SUMMARY if no path is given)",
+}
+
+// reportTarget is a report to write once the runs completed.
+type reportTarget struct {
+	format string
+	path   string
+}
+
+// parseReports parses the values of the --report flag.
+func parseReports(specs []string) ([]reportTarget, error) {
+	var targets []reportTarget
+	for _, spec := range specs {
+		format, path := spec, ""
+		if i := strings.Index(spec, "="); i >= 0 {
+			format, path = spec[:i], spec[i+1:]
+		}
+
+		switch format {
+		case "junit":
+			if path == "" {
+				return nil, fmt.Errorf("report %s: missing path", spec)
+			}
+		case "github":
+			if path == "" {
+				path = os.Getenv(githubSummaryEnv)
+			}
+			if path == "" {
+				return nil, fmt.Errorf("report %s: missing path, and $%s is not set", spec, githubSummaryEnv)
+			}
+		default:
+			return nil, fmt.Errorf("report %s: unknown format %q; formats: junit, github", spec, format)
+		}
+		targets = append(targets, reportTarget{format: format, path: path})
+	}
+	return targets, nil
+}
+
+// writeReports writes the reports of the results.
+func (m *MultiRunStrategy) writeReports() error {
+	for _, r := range m.reports {
+		var (
+			f   *os.File
+			err error
+		)
+		switch r.format {
+		case "github":
+			// Other steps append to the summary too.
+			f, err = os.OpenFile(r.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+		default:
+			f, err = os.Create(r.path)
+		}
+		if err != nil {
+			return err
+		}
+
+		switch r.format {
+		case "junit":
+			err = writeJUnit(f, m.Composition, m.Results)
+		case "github":
+			err = writeGitHubSummary(f, m.Composition, m.Results)
+		}
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			return fmt.Errorf("failed to write %s report: %w", r.format, err)
+		}
+		logging.S().Infof("wrote %s report: %s", r.format, r.path)
+	}
+	return nil
+}
+
+type junitTestSuites struct {
+	XMLName  xml.Name         `xml:"testsuites"`
+	Name     string           `xml:"name,attr"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Skipped  int              `xml:"skipped,attr"`
+	Time     string           `xml:"time,attr"`
+	Suites   []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name       string          `xml:"name,attr"`
+	Tests      int             `xml:"tests,attr"`
+	Failures   int             `xml:"failures,attr"`
+	Skipped    int             `xml:"skipped,attr"`
+	Time       string          `xml:"time,attr"`
+	Properties []junitProperty `xml:"properties>property,omitempty"`
+	Cases      []junitTestCase `xml:"testcase"`
+	SystemErr  string          `xml:"system-err,omitempty"`
+}
+
+type junitProperty struct {
+	Name  string `xml:"name,attr"`
+	Value string `xml:"value,attr"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	Classname string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitMessage `xml:"failure,omitempty"`
+	Skipped   *junitMessage `xml:"skipped,omitempty"`
+}
+
+type junitMessage struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr,omitempty"`
+}
+
+// writeJUnit writes the results as JUnit XML: each run is a test suite, with
+// a test case per group, failed if any of its instances failed, and one per
+// assertion of the run. Runs that didn't run are a single skipped test case,
+// and runs that failed without running any instance a single failed one.
+func writeJUnit(w io.Writer, comp *api.Composition, results []MultiRunResult) error {
+	name := comp.Global.Plan + ":" + comp.Global.Case
+	suites := junitTestSuites{Name: name}
+
+	var total time.Duration
+	for _, res := range results {
+		total += res.duration
+		classname := name + "." + res.RunId
+		secs := junitSeconds(res.duration)
+
+		suite := junitTestSuite{Name: res.RunId, Time: secs, SystemErr: res.Error}
+		if res.TaskId != "" {
+			suite.Properties = []junitProperty{{Name: "task_id", Value: res.TaskId}}
+		}
+
+		switch {
+		case res.TaskId == "N/A" || (res.Result.Outcome == task.OutcomeCanceled && len(res.Result.Outcomes) == 0):
+			suite.Cases = append(suite.Cases, junitTestCase{
+				Name:      res.RunId,
+				Classname: classname,
+				Time:      secs,
+				Skipped:   &junitMessage{Message: res.Error},
+			})
+		case len(res.Result.Outcomes) == 0:
+			tc := junitTestCase{Name: res.RunId, Classname: classname, Time: secs}
+			if res.Result.Outcome != task.OutcomeSuccess {
+				tc.Failure = &junitMessage{Message: failureMessage(res.Error, res.Result.Outcome), Type: string(res.Result.Outcome)}
+			}
+			suite.Cases = append(suite.Cases, tc)
+		}
+
+		groups := make([]string, 0, len(res.Result.Outcomes))
+		for id := range res.Result.Outcomes {
+			groups = append(groups, id)
+		}
+		sort.Strings(groups)
+		for _, id := range groups {
+			g := res.Result.Outcomes[id]
+			tc := junitTestCase{Name: id, Classname: classname, Time: secs}
+			if g.Ok < g.Total {
+				tc.Failure = &junitMessage{
+					Message: fmt.Sprintf("%d of %d instances failed", g.Total-g.Ok, g.Total),
+					Type:    string(task.OutcomeFailure),
+				}
+			}
+			suite.Cases = append(suite.Cases, tc)
+		}
+
+		for _, a := range res.Result.Assertions {
+			tc := junitTestCase{Name: "assert " + a.Assertion, Classname: classname, Time: junitSeconds(0)}
+			if !a.Passed {
+				tc.Failure = &junitMessage{Message: assertionMessage(a), Type: "assertion"}
+			}
+			suite.Cases = append(suite.Cases, tc)
+		}
+
+		for _, tc := range suite.Cases {
+			suite.Tests++
+			switch {
+			case tc.Failure != nil:
+				suite.Failures++
+			case tc.Skipped != nil:
+				suite.Skipped++
+			}
+		}
+		suites.Tests += suite.Tests
+		suites.Failures += suite.Failures
+		suites.Skipped += suite.Skipped
+		suites.Suites = append(suites.Suites, suite)
+	}
+	suites.Time = junitSeconds(total)
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(suites); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// writeGitHubSummary writes the results as a markdown table, for the summary
+// of a GitHub Actions step.
+func writeGitHubSummary(w io.Writer, comp *api.Composition, results []MultiRunResult) error {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "### Testground: %s:%s\n\n", comp.Global.Plan, comp.Global.Case)
+	b.WriteString("| | Run | Task | Outcome | Instances | Duration | Details |\n")
+	b.WriteString("|---|---|---|---|---|---|---|\n")
+
+	for _, res := range results {
+		var ok, total int
+		for _, g := range res.Result.Outcomes {
+			ok += g.Ok
+			total += g.Total
+		}
+		instances := "-"
+		if total > 0 {
+			instances = fmt.Sprintf("%d/%d", ok, total)
+		}
+		duration := "-"
+		if res.duration > 0 {
+			duration = res.duration.Round(time.Second).String()
+		}
+
+		var details []string
+		if res.Error != "" {
+			details = append(details, res.Error)
+		}
+		for _, a := range res.Result.Assertions {
+			if !a.Passed {
+				details = append(details, "assertion failed: "+assertionMessage(a))
+			}
+		}
+
+		fmt.Fprintf(&b, "| %s | %s | `%s` | %s | %s | %s | %s |\n",
+			outcomeIcon(res.Result.Outcome),
+			res.RunId,
+			res.TaskId,
+			res.Result.Outcome,
+			instances,
+			duration,
+			markdownCell(strings.Join(details, "; ")),
+		)
+	}
+	b.WriteString("\n")
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func junitSeconds(d time.Duration) string {
+	return fmt.Sprintf("%.3f", d.Seconds())
+}
+
+func failureMessage(err string, outcome task.Outcome) string {
+	if err != "" {
+		return err
+	}
+	return fmt.Sprintf("run outcome: %s", outcome)
+}
+
+func assertionMessage(a *api.AssertionResult) string {
+	switch {
+	case a.Error != "":
+		return fmt.Sprintf("%s: %s", a.Assertion, a.Error)
+	case a.Observed != "":
+		return fmt.Sprintf("%s (observed %s)", a.Assertion, a.Observed)
+	default:
+		return a.Assertion
+	}
+}
+
+func outcomeIcon(outcome task.Outcome) string {
+	switch outcome {
+	case task.OutcomeSuccess:
+		return "✅"
+	case task.OutcomeFailure:
+		return "❌"
+	case task.OutcomeCanceled:
+		return "⚪"
+	case task.OutcomeTimedOut:
+		return "⏱"
+	default:
+		return "❔"
+	}
+}
+
+// markdownCell escapes the characters that would break a table cell.
+func markdownCell(s string) string {
+	s = strings.ReplaceAll(s, "|", "\\|")
+	return strings.ReplaceAll(s, "\n", " ")
+}
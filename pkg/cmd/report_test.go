@@ -0,0 +1,133 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/testground/testground/pkg/api"
+	"github.com/testground/testground/pkg/runner"
+	"github.com/testground/testground/pkg/task"
+)
+
+func reportResults() (*api.Composition, []MultiRunResult) {
+	comp := &api.Composition{Global: api.Global{Plan: "network", Case: "ping-pong"}}
+	results := []MultiRunResult{
+		{
+			RunId:  "small",
+			TaskId: "c3ftkqjpc98qra498sg0",
+			Result: runner.Result{
+				Outcome: task.OutcomeFailure,
+				Outcomes: map[string]*runner.GroupOutcome{
+					"pingers": {Ok: 2, Total: 2},
+					"pongers": {Ok: 1, Total: 3},
+				},
+				Assertions: []*api.AssertionResult{
+					{Assertion: "metric:rtt.p99 < 50", Observed: "72"},
+				},
+			},
+			duration: 90 * time.Second,
+		},
+		{
+			RunId:  "large",
+			TaskId: "N/A",
+			Error:  "skipped: dependency small failed",
+			Result: runner.Result{Outcome: task.OutcomeCanceled},
+		},
+		{
+			RunId:  "broken",
+			TaskId: "c3ftkqjpc98qra498sh0",
+			Error:  "image not found",
+			Result: runner.Result{Outcome: task.OutcomeFailure},
+		},
+	}
+	return comp, results
+}
+
+func TestWriteJUnit(t *testing.T) {
+	comp, results := reportResults()
+
+	var buf bytes.Buffer
+	require.NoError(t, writeJUnit(&buf, comp, results))
+
+	var suites junitTestSuites
+	require.NoError(t, xml.Unmarshal(buf.Bytes(), &suites))
+	require.Equal(t, "network:ping-pong", suites.Name)
+	require.Equal(t, 5, suites.Tests)
+	require.Equal(t, 3, suites.Failures)
+	require.Equal(t, 1, suites.Skipped)
+	require.Len(t, suites.Suites, 3)
+
+	small := suites.Suites[0]
+	require.Equal(t, "90.000", small.Time)
+	require.Len(t, small.Cases, 3)
+	require.Equal(t, "pingers", small.Cases[0].Name)
+	require.Nil(t, small.Cases[0].Failure)
+	require.Equal(t, "pongers", small.Cases[1].Name)
+	require.Equal(t, "2 of 3 instances failed", small.Cases[1].Failure.Message)
+	require.Equal(t, "metric:rtt.p99 < 50 (observed 72)", small.Cases[2].Failure.Message)
+
+	require.Equal(t, "skipped: dependency small failed", suites.Suites[1].Cases[0].Skipped.Message)
+	require.Equal(t, "image not found", suites.Suites[2].Cases[0].Failure.Message)
+}
+
+func TestWriteGitHubSummary(t *testing.T) {
+	comp, results := reportResults()
+
+	var buf bytes.Buffer
+	require.NoError(t, writeGitHubSummary(&buf, comp, results))
+
+	out := buf.String()
+	require.Contains(t, out, "### Testground: network:ping-pong")
+	require.Contains(t, out, "| ❌ | small | `c3ftkqjpc98qra498sg0` | failure | 3/5 | 1m30s | assertion failed: metric:rtt.p99 < 50 (observed 72) |")
+	require.Contains(t, out, "| ⚪ | large | `N/A` | canceled | - | - | skipped: dependency small failed |")
+}
+
+func TestParseReports(t *testing.T) {
+	dir, err := ioutil.TempDir("", "report")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	summary := filepath.Join(dir, "summary.md")
+	prev, had := os.LookupEnv(githubSummaryEnv)
+	require.NoError(t, os.Setenv(githubSummaryEnv, summary))
+	defer func() {
+		if had {
+			os.Setenv(githubSummaryEnv, prev)
+		} else {
+			os.Unsetenv(githubSummaryEnv)
+		}
+	}()
+
+	targets, err := parseReports([]string{"junit=" + filepath.Join(dir, "junit.xml"), "github"})
+	require.NoError(t, err)
+	require.Equal(t, []reportTarget{
+		{format: "junit", path: filepath.Join(dir, "junit.xml")},
+		{format: "github", path: summary},
+	}, targets)
+
+	_, err = parseReports([]string{"junit"})
+	require.Error(t, err)
+	_, err = parseReports([]string{"tap=out.tap"})
+	require.Error(t, err)
+
+	// The step summary is appended to.
+	require.NoError(t, ioutil.WriteFile(summary, []byte("# Build\n"), 0644))
+	comp, results := reportResults()
+	m := &MultiRunStrategy{Composition: comp, Results: results, reports: targets}
+	require.NoError(t, m.writeReports())
+
+	b, err := ioutil.ReadFile(summary)
+	require.NoError(t, err)
+	require.Regexp(t, "^# Build\n### Testground", string(b))
+
+	b, err = ioutil.ReadFile(filepath.Join(dir, "junit.xml"))
+	require.NoError(t, err)
+	require.Contains(t, string(b), `<testsuite name="small"`)
+}
@@ -0,0 +1,463 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/dustin/go-humanize"
+	"github.com/urfave/cli/v2"
+
+	"github.com/testground/testground/pkg/api"
+	"github.com/testground/testground/pkg/client"
+	"github.com/testground/testground/pkg/data"
+	"github.com/testground/testground/pkg/logging"
+	"github.com/testground/testground/pkg/results"
+	"github.com/testground/testground/pkg/runner"
+	"github.com/testground/testground/pkg/task"
+)
+
+var ResultsCommand = cli.Command{
+	Name:  "results",
+	Usage: "analyse the outputs of runs",
+	Subcommands: cli.Commands{
+		&cli.Command{
+			Name:  "export",
+			Usage: "collect the outputs of a run and export the metrics and events of all instances as a tidy dataset",
+			Flags: []cli.Flag{
+				&cli.StringFlag{
+					Name:     "task",
+					Aliases:  []string{"t"},
+					Usage:    "`ID` of the run task",
+					Required: true,
+				},
+				&cli.StringFlag{
+					Name:  "format",
+					Usage: "`FORMAT` of the dataset; values: csv, json (one object per line), parquet",
+					Value: results.FormatCSV,
+				},
+				&cli.StringFlag{
+					Name:    "output",
+					Aliases: []string{"o"},
+					Usage:   "write the dataset to `FILENAME` instead of stdout",
+				},
+				&cli.StringFlag{
+					Name:  "influxdb",
+					Usage: "also push the metrics to the InfluxDB at `URL`",
+				},
+				&cli.StringFlag{
+					Name:  "influxdb-database",
+					Usage: "`NAME` of the InfluxDB database to push the metrics to",
+					Value: "testground",
+				},
+				&cli.StringFlag{
+					Name:  "prometheus-remote-write",
+					Usage: "also push the metrics to the Prometheus remote-write endpoint at `URL`, e.g. http://localhost:9090/api/v1/write",
+				},
+			},
+			Action: resultsExportCommand,
+		},
+		&cli.Command{
+			Name:      "compare",
+			Usage:     "compare the metrics of a candidate run against a baseline run, and fail on regressions",
+			ArgsUsage: "[baseline task] [candidate task]",
+			Flags: []cli.Flag{
+				&cli.Float64Flag{
+					Name:  "threshold",
+					Usage: "relative change beyond which a metric is a regression, e.g. 0.1 for 10%",
+					Value: 0.1,
+				},
+				&cli.StringSliceFlag{
+					Name:  "metric-threshold",
+					Usage: "override the threshold of a metric, as `NAME=THRESHOLD`",
+				},
+				&cli.StringSliceFlag{
+					Name:  "higher-is-better",
+					Usage: "`NAME` of a metric for which a decrease, rather than an increase, is a regression, e.g. throughput",
+				},
+				&cli.BoolFlag{
+					Name:  "regressions-only",
+					Usage: "only print the metrics that regressed",
+				},
+			},
+			Action: resultsCompareCommand,
+		},
+		&cli.Command{
+			Name:  "usage",
+			Usage: "print the resources the instances of a run used, by group and in total",
+			Flags: []cli.Flag{
+				&cli.StringFlag{
+					Name:     "task",
+					Aliases:  []string{"t"},
+					Usage:    "`ID` of the run task",
+					Required: true,
+				},
+				&cli.BoolFlag{
+					Name:  "instances",
+					Usage: "also print the usage of every instance",
+				},
+				&cli.BoolFlag{
+					Name:  "json",
+					Usage: "print the usage as JSON",
+				},
+			},
+			Action: resultsUsageCommand,
+		},
+		&cli.Command{
+			Name:      "migrate",
+			Usage:     "migrate collected outputs (.tgz) or a task record (.json) to the current schema version",
+			ArgsUsage: "[file]",
+			Flags: []cli.Flag{
+				&cli.StringFlag{
+					Name:    "output",
+					Aliases: []string{"o"},
+					Usage:   "write the migrated file to `FILENAME`; defaults to stdout",
+				},
+				&cli.BoolFlag{
+					Name:  "check",
+					Usage: "only print the schema version of the file",
+				},
+			},
+			Action: resultsMigrateCommand,
+		},
+	},
+}
+
+func resultsExportCommand(c *cli.Context) error {
+	ctx, cancel := context.WithCancel(ProcessContext())
+	defer cancel()
+
+	var (
+		id     = c.String("task")
+		format = c.String("format")
+	)
+
+	supported := false
+	for _, f := range results.Formats {
+		supported = supported || f == format
+	}
+	if !supported {
+		return fmt.Errorf("unsupported format %q; supported: %s", format, strings.Join(results.Formats, ", "))
+	}
+
+	cl, _, err := setupClient(c)
+	if err != nil {
+		return err
+	}
+
+	rows, err := collectRows(ctx, c, cl, id)
+	if err != nil {
+		return err
+	}
+
+	var w io.Writer = c.App.Writer
+	if o := c.String("output"); o != "" {
+		out, err := os.Create(o)
+		if err != nil {
+			return err
+		}
+		defer out.Close()
+		w = out
+	}
+
+	if err := results.Write(w, format, rows); err != nil {
+		return err
+	}
+
+	if addr := c.String("influxdb"); addr != "" {
+		n, err := results.PushInfluxDB(addr, c.String("influxdb-database"), rows)
+		if err != nil {
+			return err
+		}
+		logging.S().Infow("pushed metrics to influxdb", "points", n, "addr", addr)
+	}
+
+	if url := c.String("prometheus-remote-write"); url != "" {
+		n, err := results.PushRemoteWrite(url, rows)
+		if err != nil {
+			return err
+		}
+		logging.S().Infow("pushed metrics to prometheus", "samples", n, "url", url)
+	}
+
+	return nil
+}
+
+func resultsCompareCommand(c *cli.Context) error {
+	ctx, cancel := context.WithCancel(ProcessContext())
+	defer cancel()
+
+	if c.NArg() != 2 {
+		return errors.New("expected the baseline and the candidate tasks")
+	}
+
+	opts := results.CompareOptions{
+		Threshold:      c.Float64("threshold"),
+		Thresholds:     make(map[string]float64),
+		HigherIsBetter: make(map[string]bool),
+	}
+	for _, mt := range c.StringSlice("metric-threshold") {
+		parts := strings.SplitN(mt, "=", 2)
+		if len(parts) != 2 {
+			return fmt.Errorf("invalid metric threshold %q; expected NAME=THRESHOLD", mt)
+		}
+		t, err := strconv.ParseFloat(parts[1], 64)
+		if err != nil {
+			return fmt.Errorf("invalid metric threshold %q: %w", mt, err)
+		}
+		opts.Thresholds[parts[0]] = t
+	}
+	for _, name := range c.StringSlice("higher-is-better") {
+		opts.HigherIsBetter[name] = true
+	}
+
+	cl, _, err := setupClient(c)
+	if err != nil {
+		return err
+	}
+
+	base, err := collectRows(ctx, c, cl, c.Args().Get(0))
+	if err != nil {
+		return err
+	}
+	head, err := collectRows(ctx, c, cl, c.Args().Get(1))
+	if err != nil {
+		return err
+	}
+
+	deltas := results.Compare(base, head, opts)
+	regressions := results.Regressions(deltas)
+	if c.Bool("regressions-only") {
+		deltas = regressions
+	}
+
+	tw := tabwriter.NewWriter(c.App.Writer, 0, 0, 3, ' ', 0)
+	fmt.Fprintln(tw, "GROUP\tSOURCE\tMETRIC\tMEASURE\tBASELINE\tCANDIDATE\tCHANGE\t")
+	for _, d := range deltas {
+		change := "n/a"
+		if !math.IsNaN(d.Change) {
+			change = fmt.Sprintf("%+.2f%%", d.Change*100)
+		}
+		if d.Regression {
+			change += " REGRESSION"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t\n", d.Group, d.Source, d.Name, d.Measure, formatMeasure(d.Base), formatMeasure(d.Head), change)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	if len(regressions) > 0 {
+		return cli.Exit(fmt.Sprintf("%d metrics regressed beyond the threshold", len(regressions)), 1)
+	}
+	return nil
+}
+
+func formatMeasure(v float64) string {
+	if math.IsNaN(v) {
+		return "-"
+	}
+	return strconv.FormatFloat(v, 'g', 6, 64)
+}
+
+// collectRows collects the outputs of a run task and parses them into rows.
+func collectRows(ctx context.Context, c *cli.Context, cl *client.Client, id string) ([]*results.Row, error) {
+	r, err := cl.Status(ctx, &api.StatusRequest{TaskID: id})
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	tsk, err := client.ParseStatusResponse(r, ioutil.Discard)
+	if err != nil {
+		return nil, err
+	}
+
+	archive, err := ioutil.TempFile("", "testground-results-*.tgz")
+	if err != nil {
+		return nil, err
+	}
+	archive.Close()
+	defer os.Remove(archive.Name())
+
+	if err := collect(ctx, cl, c.App.ErrWriter, tsk.Runner, id, archive.Name()); err != nil {
+		return nil, err
+	}
+
+	f, err := os.Open(archive.Name())
+	if err != nil {
+		return nil, fmt.Errorf("no outputs for task %s", id)
+	}
+	defer f.Close()
+
+	return results.ReadArchive(f)
+}
+
+func resultsUsageCommand(c *cli.Context) error {
+	ctx, cancel := context.WithCancel(ProcessContext())
+	defer cancel()
+
+	id := c.String("task")
+
+	cl, _, err := setupClient(c)
+	if err != nil {
+		return err
+	}
+
+	r, err := cl.Status(ctx, &api.StatusRequest{TaskID: id})
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	tsk, err := client.ParseStatusResponse(r, ioutil.Discard)
+	if err != nil {
+		return err
+	}
+	if tsk.Type != task.TypeRun {
+		return fmt.Errorf("task %s is not a run", id)
+	}
+
+	usage := data.DecodeRunnerResult(tsk.Result).Usage
+	if usage == nil {
+		return fmt.Errorf("no usage recorded for task %s; it may still be running, or its runner doesn't account for usage", id)
+	}
+
+	if c.Bool("json") {
+		enc := json.NewEncoder(c.App.Writer)
+		enc.SetIndent("", "  ")
+		return enc.Encode(usage)
+	}
+	return writeUsage(c.App.Writer, usage, c.Bool("instances"))
+}
+
+// writeUsage prints the usage of a run as tables: by group and in total, by
+// instance type, and optionally by instance.
+func writeUsage(w io.Writer, usage *runner.Usage, instances bool) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 3, ' ', 0)
+
+	row := func(name string, t *runner.UsageTotals) {
+		fmt.Fprintf(tw, "%s\t%d\t%.1f\t%.1f\t%s\t%s\t%s\t\n", name, t.Instances, t.Seconds, t.CPUSeconds,
+			humanize.IBytes(t.PeakMemoryBytes), humanize.IBytes(t.NetworkRxBytes), humanize.IBytes(t.NetworkTxBytes))
+	}
+
+	fmt.Fprintln(tw, "GROUP\tINSTANCES\tSECONDS\tCPU SECONDS\tPEAK MEMORY\tNETWORK RX\tNETWORK TX\t")
+	for _, g := range usageKeys(usage.Groups) {
+		row(g, usage.Groups[g])
+	}
+	if usage.Total != nil {
+		row("(total)", usage.Total)
+	}
+
+	if len(usage.InstanceTypes) > 0 {
+		fmt.Fprintln(tw, "\t\t\t\t\t\t\t")
+		fmt.Fprintln(tw, "INSTANCE TYPE\tINSTANCES\tSECONDS\tCPU SECONDS\tPEAK MEMORY\tNETWORK RX\tNETWORK TX\t")
+		for _, typ := range usageKeys(usage.InstanceTypes) {
+			row(fmt.Sprintf("%s (%d nodes)", typ, len(usage.Nodes[typ])), usage.InstanceTypes[typ])
+		}
+	}
+
+	if instances {
+		names := make([]string, 0, len(usage.Instances))
+		for name := range usage.Instances {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		fmt.Fprintln(tw, "\t\t\t\t\t\t\t")
+		fmt.Fprintln(tw, "INSTANCE\tGROUP\tSECONDS\tCPU SECONDS\tPEAK MEMORY\tNETWORK RX\tNETWORK TX\tNODE")
+		for _, name := range names {
+			i := usage.Instances[name]
+			fmt.Fprintf(tw, "%s\t%s\t%.1f\t%.1f\t%s\t%s\t%s\t%s\n", name, i.Group, i.Seconds, i.CPUSeconds,
+				humanize.IBytes(i.PeakMemoryBytes), humanize.IBytes(i.NetworkRxBytes), humanize.IBytes(i.NetworkTxBytes), i.Node)
+		}
+	}
+	return tw.Flush()
+}
+
+func usageKeys(m map[string]*runner.UsageTotals) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func resultsMigrateCommand(c *cli.Context) error {
+	if c.NArg() != 1 {
+		return errors.New("missing file to migrate")
+	}
+
+	in, err := os.Open(c.Args().First())
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	var w io.Writer = c.App.Writer
+	if o := c.String("output"); o != "" && !c.Bool("check") {
+		out, err := os.Create(o)
+		if err != nil {
+			return err
+		}
+		defer out.Close()
+		w = out
+	}
+
+	if filepath.Ext(in.Name()) == ".json" {
+		return migrateTaskRecord(c, in, w)
+	}
+
+	if c.Bool("check") {
+		schema, err := results.ReadSchema(in)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(c.App.Writer, "outputs of run %s: schema version %d (current: %d)\n", schema.RunID, schema.Version, api.OutputsSchemaVersion)
+		return nil
+	}
+
+	from, err := results.MigrateArchive(in, w)
+	if err != nil {
+		return err
+	}
+	logging.S().Infow("migrated outputs", "run_id", from.RunID, "from", from.Version, "to", api.OutputsSchemaVersion)
+	return nil
+}
+
+func migrateTaskRecord(c *cli.Context, in io.Reader, w io.Writer) error {
+	data, err := ioutil.ReadAll(in)
+	if err != nil {
+		return err
+	}
+
+	if c.Bool("check") {
+		var record struct {
+			ID      string `json:"id"`
+			Version int    `json:"version"`
+		}
+		if err := json.Unmarshal(data, &record); err != nil {
+			return err
+		}
+		fmt.Fprintf(c.App.Writer, "task %s: schema version %d (current: %d)\n", record.ID, record.Version, task.CurrentVersion)
+		return nil
+	}
+
+	migrated, err := task.MigrateRecord(data)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(w, string(migrated))
+	return err
+}
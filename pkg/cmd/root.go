@@ -10,16 +10,29 @@ import (
 var RootCommands = cli.CommandsByName{
 	&RunCommand,
 	&PlanCommand,
+	&CompositionCommand,
 	&BuildCommand,
+	&BundleCommand,
 	&DescribeCommand,
+	&DoctorCommand,
 	&SidecarCommand,
+	&SyncProxyCommand,
 	&DaemonCommand,
 	&CollectCommand,
 	&TerminateCommand,
+	&DrainCommand,
 	&HealthcheckCommand,
 	&TasksCommand,
+	&TaskCommand,
 	&StatusCommand,
+	&EventsCommand,
 	&LogsCommand,
+	&ParamCommand,
+	&NetworkCommand,
+	&ExecCommand,
+	&ResultsCommand,
+	&TokenCommand,
+	&GCCommand,
 	&VersionCommand,
 }
 
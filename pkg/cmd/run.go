@@ -3,12 +3,15 @@ package cmd
 import (
 	"context"
 	"encoding/csv"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
+	"time"
 
 	"github.com/mitchellh/mapstructure"
 	"github.com/testground/testground/pkg/api"
@@ -17,6 +20,7 @@ import (
 	"github.com/testground/testground/pkg/logging"
 	"github.com/testground/testground/pkg/runner"
 	"github.com/testground/testground/pkg/task"
+	"github.com/testground/testground/pkg/tracing"
 
 	"github.com/urfave/cli/v2"
 )
@@ -58,6 +62,7 @@ var RunCommand = cli.Command{
 					Aliases: []string{"O"},
 					Usage:   "write the results csv `FILENAME`",
 				},
+				reportFlag,
 				&cli.StringFlag{
 					Name:  "metadata-repo",
 					Usage: "repo that triggered this run",
@@ -70,6 +75,27 @@ var RunCommand = cli.Command{
 					Name:  "metadata-commit",
 					Usage: "commit that triggered this run",
 				},
+				&cli.StringFlag{
+					Name:  "priority",
+					Usage: "scheduling `PRIORITY` of the run; values: high, normal, low",
+					Value: "normal",
+				},
+				&cli.BoolFlag{
+					Name:  "ci",
+					Usage: "enforce the --ci-budget and --ci-max-instances budgets across all the runs of the composition",
+				},
+				&cli.DurationFlag{
+					Name:  "ci-budget",
+					Usage: "total wall-clock budget of all the runs in CI mode, e.g. 30m; runs that no longer fit are shed, lowest priority first",
+				},
+				&cli.IntFlag{
+					Name:  "ci-max-instances",
+					Usage: "maximum number of instances of a run in CI mode; larger runs are shrunk when sized by percentages, and shed otherwise",
+				},
+				envFlag,
+				envFileFlag,
+				pauseOnFailureFlag,
+				pauseTimeoutFlag,
 			),
 		},
 		&cli.Command{
@@ -88,6 +114,12 @@ var RunCommand = cli.Command{
 					Aliases: []string{"o"},
 					Usage:   "destination for the assets if --collect is set",
 				},
+				reportFlag,
+				&cli.StringFlag{
+					Name:  "priority",
+					Usage: "scheduling `PRIORITY` of the run; values: high, normal, low",
+					Value: "normal",
+				},
 				&cli.UintFlag{
 					Name:        "instances",
 					Aliases:     []string{"i"},
@@ -137,8 +169,14 @@ var RunCommand = cli.Command{
 					Name:  "disable-metrics",
 					Usage: "disable metrics batching",
 				},
+				envFlag,
+				envFileFlag,
+				pauseOnFailureFlag,
+				pauseTimeoutFlag,
 			),
 		},
+		replayCommand,
+		scaleCommand,
 	},
 }
 
@@ -148,12 +186,16 @@ func runCompositionCmd(c *cli.Context) (err error) {
 		return fmt.Errorf("no composition file supplied")
 	}
 
-	comp, err := loadComposition(file)
+	comp, err := loadComposition(file, c.StringSlice("set"))
 
 	if err != nil {
 		return fmt.Errorf("failed to load composition file: %w", err)
 	}
 
+	if err = lintBeforeSubmit(c, file, true); err != nil {
+		return err
+	}
+
 	if err = comp.ValidateForRun(); err != nil {
 		return fmt.Errorf("invalid composition file: %w", err)
 	}
@@ -184,25 +226,63 @@ func run(c *cli.Context, comp *api.Composition) (err error) {
 	ctx, cancel := context.WithCancel(ProcessContext())
 	defer cancel()
 
+	ctx, endTracing := startTracing(ctx, cfg, "run", comp)
+	defer endTracing()
+
 	// Resolve the test plan and its manifest.
 	planDir, manifest, err := resolveTestPlan(cfg, comp.Global.Plan)
 	if err != nil {
 		return fmt.Errorf("failed to resolve test plan: %w", err)
 	}
 
-	// Retrieve the run ids to use.
-	rawRunIds := c.String("run-ids")
-	var runIds []string
+	planSource, err := resolvePlanSource(planDir)
+	if err != nil {
+		return err
+	}
 
-	// default to all the runs in the composition
-	if rawRunIds == "" {
-		runIds = comp.ListRunIds()
-	} else {
+	// Retrieve the run ids to use. The daemon runs all the runs of the
+	// composition by default; it expands their sweeps, and schedules them
+	// after the runs they depend on.
+	var runIds []string
+	if rawRunIds := c.String("run-ids"); rawRunIds != "" {
 		runIds = strings.Split(rawRunIds, ",")
 	}
 
-	// TODO: validate run ids
-	// TODO: verify run ids exists in the composition.
+	// In CI mode, order the runs by priority and fit them into the budget.
+	var (
+		shed     []MultiRunResult
+		deadline *time.Time
+	)
+	budget := newCIBudget(c)
+	if budget != nil {
+		// The budget applies to the runs of the sweeps.
+		if comp, err = comp.ExpandSweeps(); err != nil {
+			return fmt.Errorf("failed to expand sweeps: %w", err)
+		}
+		if len(runIds) == 0 {
+			runIds = comp.ListRunIds()
+		} else {
+			runIds = comp.ResolveRunIds(runIds)
+		}
+		if runIds, err = comp.PipelineOrder(runIds); err != nil {
+			return err
+		}
+		if runIds, shed, err = budget.Plan(comp, runIds); err != nil {
+			return err
+		}
+		var dropped []string
+		if runIds, dropped, err = pipelineOrder(comp, runIds); err != nil {
+			return err
+		}
+		for _, id := range dropped {
+			shed = append(shed, shedRun(id, "upstream run shed"))
+		}
+		if d, ok := budget.Deadline(); ok {
+			deadline = &d
+			ctx, cancel = context.WithDeadline(ctx, d)
+			defer cancel()
+		}
+	}
 
 	// Skip artifacts if the user explicit requests it.
 	// TODO: Simplify this code: empty the artifact field if required and post
@@ -246,7 +326,10 @@ func run(c *cli.Context, comp *api.Composition) (err error) {
 				extraSrcs[i] = filepath.Clean(filepath.Join(evalPlanDir, dir))
 			}
 		}
-	} else {
+	}
+
+	// The daemon fetches plans imported from a remote source itself.
+	if len(buildIdx) == 0 || planSource != nil {
 		planDir = ""
 	}
 
@@ -254,12 +337,14 @@ func run(c *cli.Context, comp *api.Composition) (err error) {
 
 	// Compute priority
 	isCollecting := c.Bool("collect")
-	isMultiple := len(runIds) > 1
-	isWaiting := c.Bool("wait") || isCollecting || isMultiple
+	isWaiting := c.Bool("wait") || isCollecting || budget != nil
 
-	priority := 0
+	priority, err := task.ParsePriority(c.String("priority"))
+	if err != nil {
+		return err
+	}
 	if isWaiting {
-		priority = 1
+		priority++
 	}
 
 	// Compute compositionTarget
@@ -274,137 +359,167 @@ func run(c *cli.Context, comp *api.Composition) (err error) {
 	// Compute result target
 	resultTarget := c.String(ResultFileOpt)
 
+	reports, err := parseReports(c.StringSlice(ReportOpt))
+	if err != nil {
+		return err
+	}
+
+	env, err := runEnv(c)
+	if err != nil {
+		return err
+	}
+
 	// Prepare the strategy
 	strategy := MultiRunStrategy{
-		CurrentRunIndex:      0,
-		RunIds:               runIds,
-		Composition:          comp,
-		EffectiveComposition: comp,
-		BaseRequest: api.RunRequest{
-			BuildGroups: buildIdx,
-			Priority:    priority,
-			RunIds:      []string{},
-			Composition: *comp,
-			Manifest:    *manifest,
-			CreatedBy: api.CreatedBy{
-				User:   cfg.Client.User,
-				Repo:   c.String("metadata-repo"),
-				Branch: c.String("metadata-branch"),
-				Commit: c.String("metadata-commit"),
-			},
-		},
-		planDir:           planDir,
-		sdkDir:            sdkDir,
-		extraSrcs:         extraSrcs,
+		Composition:       comp,
 		isCollecting:      isCollecting,
-		isWaiting:         isWaiting,
-		isMultiple:        isMultiple,
 		compositionTarget: compositionTarget,
 		collectionTarget:  collectionTarget,
 		resultTarget:      resultTarget,
-		Results:           make([]MultiRunResult, 0, len(runIds)),
+		reports:           reports,
+		Results:           shed,
 		Stdout:            c.App.Writer,
 	}
 
-	for {
-		shouldContinue, err := strategy.Next(ctx, cl, c)
-		if err != nil {
-			strategy.CancelEveryOtherRun()
-			showResultErr := strategy.ShowResult()
-
-			if showResultErr != nil {
-				fmt.Printf("failed to show result: %v", showResultErr)
-			}
-
+	// All the runs were shed.
+	if budget != nil && len(runIds) == 0 {
+		if err := strategy.ShowResult(); err != nil {
 			return err
 		}
+		return strategy.ExitStatus()
+	}
+
+	request := api.RunRequest{
+		BuildGroups: buildIdx,
+		Priority:    priority,
+		RunIds:      runIds,
+		Composition: *comp,
+		Manifest:    *manifest,
+		CreatedBy: api.CreatedBy{
+			User:   cfg.Client.User,
+			Repo:   c.String("metadata-repo"),
+			Branch: c.String("metadata-branch"),
+			Commit: c.String("metadata-commit"),
+		},
+		PlanSource:         planSource,
+		Deadline:           deadline,
+		PauseOnFailureSecs: pauseOnFailureSecs(c),
+		Env:                env,
+	}
+	sources := client.Sources{PlanDir: planDir, SDKDir: sdkDir, ExtraSources: extraSrcs}
 
-		if !shouldContinue {
-			break
-		}
+	id, err := cl.CreateRun(ctx, &request, sources, c.App.Writer)
+	switch err {
+	case nil:
+	case context.Canceled:
+		return fmt.Errorf("interrupted")
+	default:
+		return err
 	}
+	logging.S().Infof("run is queued with ID: %s", id)
 
-	err = strategy.ShowResult()
+	// We're not waiting, let's leave
+	if !isWaiting {
+		return nil
+	}
 
-	if err != nil {
+	if err := strategy.Follow(ctx, cl, id); err != nil {
+		if showResultErr := strategy.ShowResult(); showResultErr != nil {
+			fmt.Printf("failed to show result: %v", showResultErr)
+		}
+		return err
+	}
+
+	if err := strategy.ShowResult(); err != nil {
 		return err
 	}
 
 	return strategy.ExitStatus()
 }
 
-func (m *MultiRunStrategy) Next(ctx context.Context, cl *client.Client, c *cli.Context) (bool, error) {
-	// Done
-	if m.CurrentRunIndex >= len(m.RunIds) {
-		return false, nil
-	}
+// Follow waits for the task queued for the runs to finish, following the
+// retries of a single run, and records the results of the runs: the result of
+// the run, or the results of the runs of a pipeline. It collects the outputs
+// of the runs if requested.
+func (m *MultiRunStrategy) Follow(ctx context.Context, cl *client.Client, taskId string) error {
+	ctx, span := tracing.Start(ctx, "follow "+taskId)
+	defer span.End()
 
-	// Run the current run
-	taskId, err := m.CallDaemonRun(ctx, cl)
+	res, err := cl.WaitForTask(ctx, taskId, m.Stdout)
 	if err != nil {
-		return false, err
+		return err
 	}
-
-	// We're not waiting, let's leave
-	if !m.isWaiting {
-		return false, nil
+	tsk := res.Task
+	logging.S().Infof("finished run with ID: %s", tsk.ID)
+
+	first := len(m.Results)
+	switch {
+	case res.Pipeline != nil:
+		m.isMultiple = true
+		for _, r := range res.Pipeline.Runs {
+			result := MultiRunResult{
+				RunId:       r.RunID,
+				TaskId:      r.TaskID,
+				Error:       r.Error,
+				Result:      *data.DecodeRunnerResult(r.Result),
+				Shed:        r.Shed,
+				SweptFrom:   r.SweptFrom,
+				SweptParams: r.SweptParams,
+				duration:    r.Took,
+			}
+			if result.TaskId == "" {
+				result.TaskId = "N/A"
+			}
+			m.Results = append(m.Results, result)
+		}
+	case tsk.Type == task.TypeRun:
+		m.Results = append(m.Results, MultiRunResult{
+			RunId:    taskRunId(tsk),
+			TaskId:   tsk.ID,
+			Error:    tsk.Error,
+			Result:   *data.DecodeRunnerResult(tsk.Result),
+			duration: tsk.Took(),
+		})
 	}
 
-	// Wait for the task to finish.
-	tsk, err := m.WaitForTaskCompletion(ctx, cl, taskId)
-
-	if err != nil {
-		return false, err
+	if err := m.ProcessComposition(tsk); err != nil {
+		return err
 	}
 
-	// Add result
-	result := data.DecodeRunnerResult(tsk.Result)
-	m.Results = append(m.Results, MultiRunResult{
-		RunId:  m.CurrentRunId(),
-		TaskId: taskId,
-		Error:  tsk.Error,
-		Result: *result,
-	})
-
-	// Process the composition
-	err = m.ProcessComposition(tsk)
-
-	if err != nil {
-		return false, err
+	for _, result := range m.Results[first:] {
+		if result.TaskId == "N/A" {
+			continue
+		}
+		if err := m.collect(ctx, cl, result.RunId, result.TaskId); err != nil {
+			return err
+		}
 	}
 
-	// Process the collection
-	err = m.Collect(ctx, cl, tsk.ID)
-
-	if err != nil {
-		return false, err
+	if tsk.Error != "" {
+		return errors.New(tsk.Error)
 	}
-
-	m.CurrentRunIndex += 1
-	return true, nil
+	return nil
 }
 
-func (m *MultiRunStrategy) CurrentRequest() api.RunRequest {
-	request := m.BaseRequest
-	request.RunIds = []string{m.CurrentRunId()}
-
-	// No build groups, we are using the effective composition
-	if m.CurrentRunIndex != 0 {
-		request.BuildGroups = []int{}
+// taskRunId returns the id of the run a run task ran.
+func taskRunId(tsk *task.Task) string {
+	var input struct {
+		RunIds []string `json:"run_ids"`
 	}
-
-	request.Composition = *m.EffectiveComposition
-
-	return request
-}
-
-func (m *MultiRunStrategy) CurrentRunId() string {
-	return m.RunIds[m.CurrentRunIndex]
+	if b, err := json.Marshal(tsk.Input); err == nil {
+		_ = json.Unmarshal(b, &input)
+	}
+	if len(input.RunIds) == 0 {
+		return "N/A"
+	}
+	return input.RunIds[0]
 }
 
-
 func (m *MultiRunStrategy) ExitStatus() error {
 	for _, result := range m.Results {
+		if result.Shed {
+			continue
+		}
 		if (result.Error != "" || !data.IsOutcomeSuccess(result.Result.Outcome)) {
 			return cli.Exit(fmt.Errorf("run \"%s\" failed", result.RunId), 1)
 		}
@@ -413,87 +528,33 @@ func (m *MultiRunStrategy) ExitStatus() error {
 	return nil
 }
 
-func (m *MultiRunStrategy) CallDaemonRun(ctx context.Context, cl *client.Client) (string, error) {
-	req := m.CurrentRequest()
-	resp, err := cl.Run(ctx, &req, m.planDir, m.sdkDir, m.extraSrcs)
-
-	switch err {
-	case nil:
-		break // noop
-	case context.Canceled:
-		return "", fmt.Errorf("interrupted")
-	default:
-		return "", err
-	}
-
-	defer resp.Close()
-
-	id, err := client.ParseRunResponse(resp, m.Stdout)
-	if err != nil {
-		return "", err
-	}
-
-	logging.S().Infof("run is queued with ID: %s", id)
-	return id, nil
-}
-
-func (m *MultiRunStrategy) WaitForTaskCompletion(ctx context.Context, cl *client.Client, taskId string) (*task.Task, error) {
-	r, err := cl.Logs(ctx, &api.LogsRequest{
-		TaskID:            taskId,
-		Follow:            true,
-		CancelWithContext: true,
-	})
-	if err != nil {
-		return nil, err
-	}
-	defer r.Close()
-
-	tsk, err := client.ParseLogsRequest(m.Stdout, r)
-	if err != nil {
-		return nil, err
-	}
-
-	if tsk.Error != "" {
-		return nil, errors.New(tsk.Error)
-	}
-
-	logging.S().Infof("finished run with ID: %s", taskId)
-
-	return &tsk, nil
-}
-
+// ProcessComposition writes the composition the daemon ran, with the
+// artifacts it built, if requested.
 func (m *MultiRunStrategy) ProcessComposition(tsk *task.Task) error {
-	// for the first run, keep the composition produced by the daemon.
-	if m.CurrentRunIndex == 0 {
-		var composition api.Composition
-		err := mapstructure.Decode(tsk.Composition, &composition)
-
-		if err != nil {
-			return err
-		}
+	if m.compositionTarget == "" {
+		return nil
+	}
 
-		m.EffectiveComposition = &composition
+	var composition api.Composition
+	if err := mapstructure.Decode(tsk.Composition, &composition); err != nil {
+		return err
 	}
 
-	// If that's the first run and we are asking for a write artifact, store it
-	if m.CurrentRunIndex == 0 && m.compositionTarget != "" {
-		err := api.WriteCompositionToFile(m.EffectiveComposition, m.compositionTarget)
-		if err != nil {
-			return fmt.Errorf("failed to write composition file: %w", err)
-		}
+	if err := api.WriteCompositionToFile(&composition, m.compositionTarget); err != nil {
+		return fmt.Errorf("failed to write composition file: %w", err)
 	}
 
 	return nil
 }
 
-func (m *MultiRunStrategy) CurrentCollectedPath(taskId string) string {
+func (m *MultiRunStrategy) collectedPath(runId string, taskId string) string {
 	collectionTarget := m.collectionTarget
 
 	if m.isMultiple {
 		if collectionTarget == "" {
 			collectionTarget = "multiple"
 		}
-		collectionTarget = fmt.Sprintf("%s-%s-%s", collectionTarget, m.CurrentRunId(), taskId)
+		collectionTarget = fmt.Sprintf("%s-%s-%s", collectionTarget, runId, taskId)
 	} else {
 		if collectionTarget == "" {
 			collectionTarget = taskId
@@ -503,9 +564,9 @@ func (m *MultiRunStrategy) CurrentCollectedPath(taskId string) string {
 	return fmt.Sprintf("%s.tgz", collectionTarget)
 }
 
-func (m *MultiRunStrategy) Collect(ctx context.Context, cl *client.Client, taskId string) error {
+func (m *MultiRunStrategy) collect(ctx context.Context, cl *client.Client, runId string, taskId string) error {
 	if m.isCollecting {
-		err := collect(ctx, cl, m.Stdout, m.Composition.Global.Runner, taskId, m.CurrentCollectedPath(taskId))
+		err := collect(ctx, cl, m.Stdout, m.Composition.Global.Runner, taskId, m.collectedPath(runId, taskId))
 
 		if err != nil {
 			return cli.Exit(err.Error(), 3)
@@ -515,22 +576,14 @@ func (m *MultiRunStrategy) Collect(ctx context.Context, cl *client.Client, taskI
 	return nil
 }
 
-func (m *MultiRunStrategy) CancelEveryOtherRun() {
-	for m.CurrentRunIndex < len(m.RunIds) {
-		m.Results = append(m.Results, MultiRunResult{
-			RunId:  m.CurrentRunId(),
-			TaskId: "N/A",
-			Error:  "canceled",
-			Result: runner.Result{
-				Outcome: task.OutcomeCanceled,
-			},
-		})
-		m.CurrentRunIndex++
-	}
-}
-
 func (m *MultiRunStrategy) ShowResult() error {
+	var swept bool
 	for _, result := range m.Results {
+		if sweep, params := result.sweep(); sweep != "" {
+			swept = true
+			logging.S().Infof("result %s[%s] (%s %s): %s", result.RunId, result.TaskId, sweep, params, result.Result.Outcome)
+			continue
+		}
 		logging.S().Infof("result %s[%s]: %s", result.RunId, result.TaskId, result.Result.Outcome)
 	}
 
@@ -545,13 +598,22 @@ func (m *MultiRunStrategy) ShowResult() error {
 		w := csv.NewWriter(f)
 		defer w.Flush()
 
-		err = w.Write([]string{"run_id", "task_id", "outcome", "error"})
+		header := []string{"run_id", "task_id", "outcome", "error"}
+		if swept {
+			header = append(header, "sweep", "sweep_params")
+		}
+		err = w.Write(header)
 		if err != nil {
 			return err
 		}
 
 		for _, result := range m.Results {
-			err := w.Write([]string{result.RunId, result.TaskId, string(result.Result.Outcome), result.Error})
+			record := []string{result.RunId, result.TaskId, string(result.Result.Outcome), result.Error}
+			if swept {
+				sweep, params := result.sweep()
+				record = append(record, sweep, params)
+			}
+			err := w.Write(record)
 
 			if err != nil {
 				return err
@@ -559,45 +621,75 @@ func (m *MultiRunStrategy) ShowResult() error {
 		}
 	}
 
-	return nil
+	return m.writeReports()
 }
 
-type MultiRunStrategy struct {
-	// Current RunID Index
-	CurrentRunIndex int
+// pipelineOrder orders the given runs so that every run comes after its
+// dependencies. Runs that depend on runs that are not part of the given ones
+// are dropped.
+func pipelineOrder(comp *api.Composition, runIds []string) ([]string, []string, error) {
+	order, err := comp.PipelineOrder(runIds)
+	if err != nil {
+		return nil, nil, err
+	}
 
-	// Run IDs
-	RunIds []string
+	kept := make(map[string]bool, len(runIds))
+	for _, id := range runIds {
+		kept[id] = true
+	}
 
-	// Initial Composition
-	Composition *api.Composition
+	var res, dropped []string
+	for _, id := range order {
+		if !kept[id] {
+			continue
+		}
 
-	// Effective Composition used by the daemon, which contains artifacts
-	EffectiveComposition *api.Composition
+		// dependencies come first, so they were dropped already if needed.
+		run, _ := comp.GetRun(id)
+		for _, dep := range run.DependsOn {
+			if !kept[dep] {
+				kept[id] = false
+			}
+		}
+
+		if kept[id] {
+			res = append(res, id)
+		} else {
+			dropped = append(dropped, id)
+		}
+	}
+	return res, dropped, nil
+}
 
-	// Base Request
-	BaseRequest api.RunRequest
+// sweep returns the id of the sweep a run was expanded from, and the values
+// of its swept parameters formatted as `name=value` pairs.
+func (r *MultiRunResult) sweep() (string, string) {
+	if r.SweptFrom == "" {
+		return "", ""
+	}
 
-	// Collect Destination
-	CollectDestination string
+	params := make([]string, 0, len(r.SweptParams))
+	for k, v := range r.SweptParams {
+		params = append(params, k+"="+v)
+	}
+	sort.Strings(params)
 
-	// Composition Destination
-	CompositionDestination string
+	return r.SweptFrom, strings.Join(params, " ")
+}
 
-	// Plan configuration
-	planDir   string
-	sdkDir    string
-	extraSrcs []string
+type MultiRunStrategy struct {
+	// Initial Composition
+	Composition *api.Composition
 
 	// Flags
 	isCollecting bool
-	isWaiting    bool
 	isMultiple   bool
 
 	// Outputs
 	compositionTarget string
 	collectionTarget  string
 	resultTarget      string
+	reports           []reportTarget
 
 	// Results
 	Results []MultiRunResult
@@ -618,4 +710,14 @@ type MultiRunResult struct {
 
 	// Result
 	Result runner.Result
+
+	// Shed is true if the run was not scheduled to stay within the CI budget
+	Shed bool
+
+	// Sweep the run was expanded from, if any, and its swept parameters
+	SweptFrom   string
+	SweptParams map[string]string
+
+	// duration of the run, zero if it didn't run
+	duration time.Duration
 }
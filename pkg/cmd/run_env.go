@@ -0,0 +1,49 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/testground/testground/pkg/api"
+
+	"github.com/urfave/cli/v2"
+)
+
+// envFlag and envFileFlag are the flags of the run commands that inject
+// variables into the runtime environment of the groups, without editing the
+// composition.
+var envFlag = &cli.StringSliceFlag{
+	Name:  "env",
+	Usage: "inject a variable into the environment of the instances, as `[GROUPS:]KEY=VALUE`; GROUPS are comma-separated group ids, all groups if omitted",
+}
+
+var envFileFlag = &cli.StringSliceFlag{
+	Name:  "env-file",
+	Usage: "inject the variables of an env `FILE`, a KEY=VALUE per line, into the environment of the instances of all groups",
+}
+
+// runEnv returns the variables injected into the groups of a run: those of
+// the env files, then those of --env, which override them.
+func runEnv(c *cli.Context) ([]api.EnvVar, error) {
+	var vars []api.EnvVar
+	for _, path := range c.StringSlice("env-file") {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open env file: %w", err)
+		}
+		fvars, err := api.ParseEnvFile(f)
+		_ = f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("invalid env file %s: %w", path, err)
+		}
+		vars = append(vars, fvars...)
+	}
+	for _, s := range c.StringSlice("env") {
+		v, err := api.ParseEnvVar(s)
+		if err != nil {
+			return nil, err
+		}
+		vars = append(vars, v)
+	}
+	return vars, nil
+}
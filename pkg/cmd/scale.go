@@ -0,0 +1,85 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/urfave/cli/v2"
+
+	"github.com/testground/testground/pkg/api"
+	"github.com/testground/testground/pkg/client"
+)
+
+var scaleCommand = &cli.Command{
+	Name:      "scale",
+	Usage:     "add instances to, or remove instances from, a group of a running task; use -- before negative counts, e.g. -- -3",
+	ArgsUsage: "[+N | -N]",
+	Action:    scaleCmd,
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:     "task",
+			Usage:    "`ID` of the running task",
+			Required: true,
+		},
+		&cli.StringFlag{
+			Name:     "group",
+			Usage:    "`ID` of the group to scale",
+			Required: true,
+		},
+	},
+}
+
+func scaleCmd(c *cli.Context) error {
+	ctx, cancel := context.WithCancel(ProcessContext())
+	defer cancel()
+
+	if c.NArg() != 1 {
+		return errors.New("missing number of instances to add or remove, e.g. +5 or -3")
+	}
+	delta, err := parseScaleDelta(c.Args().First())
+	if err != nil {
+		return err
+	}
+
+	cl, _, err := setupClient(c)
+	if err != nil {
+		return err
+	}
+
+	r, err := cl.Scale(ctx, &api.ScaleRequest{
+		TaskID: c.String("task"),
+		Group:  c.String("group"),
+		Delta:  delta,
+	})
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	out, err := client.ParseScaleResponse(r, c.App.Writer)
+	if err != nil {
+		return err
+	}
+
+	for _, name := range out.Added {
+		fmt.Fprintf(c.App.Writer, "added %s\n", name)
+	}
+	for _, name := range out.Removed {
+		fmt.Fprintf(c.App.Writer, "removed %s\n", name)
+	}
+	fmt.Fprintf(c.App.Writer, "group %s has %d instances\n", out.Group, out.Instances)
+	return nil
+}
+
+// parseScaleDelta parses a number of instances to add, e.g. +5 or 5, or to
+// remove, e.g. -3.
+func parseScaleDelta(s string) (int, error) {
+	delta, err := strconv.Atoi(strings.TrimPrefix(s, "+"))
+	if err != nil || delta == 0 || strings.HasPrefix(s, "+-") {
+		return 0, fmt.Errorf("invalid number of instances %q: expected +N to add instances, or -N to remove them", s)
+	}
+	return delta, nil
+}
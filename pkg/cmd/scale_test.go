@@ -0,0 +1,20 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseScaleDelta(t *testing.T) {
+	for in, want := range map[string]int{"+5": 5, "5": 5, "-3": -3} {
+		delta, err := parseScaleDelta(in)
+		require.NoError(t, err, in)
+		require.Equal(t, want, delta, in)
+	}
+
+	for _, in := range []string{"", "0", "+0", "+-3", "five", "+"} {
+		_, err := parseScaleDelta(in)
+		require.Error(t, err, in)
+	}
+}
@@ -82,10 +82,13 @@ func printTask(tsk task.Task) {
 	}
 
 	fmt.Printf("ID:\t\t%s\n", tsk.ID)
-	fmt.Printf("Priority:\t%d\n", tsk.Priority)
+	fmt.Printf("Priority:\t%d (%s)\n", tsk.Priority, task.PriorityClass(tsk.Priority))
 	fmt.Printf("Created:\t%s\n", tsk.Created())
 	fmt.Printf("Type:\t\t%s\n", tsk.Type)
 	fmt.Printf("Status:\t\t%s\n", tsk.State().State)
 	fmt.Printf("Outcome:\t%s\n", outcomeStr)
 	fmt.Printf("Last update:\t%s\n", tsk.State().Created)
+	if tsk.Dashboard != "" {
+		fmt.Printf("Dashboard:\t%s\n", tsk.Dashboard)
+	}
 }
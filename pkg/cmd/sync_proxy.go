@@ -0,0 +1,61 @@
+package cmd
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/urfave/cli/v2"
+
+	"github.com/testground/testground/pkg/logging"
+	"github.com/testground/testground/pkg/syncproxy"
+)
+
+// SyncProxyCommand is the specification of the `sync-proxy` command.
+var SyncProxyCommand = cli.Command{
+	Name:   "sync-proxy",
+	Usage:  "serve the sync service of large runs from several sync service replicas, sharding their topics and barriers",
+	Action: syncProxyCommand,
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:  "listen",
+			Usage: "`ADDRESS` to serve instances at",
+			Value: ":5050",
+		},
+		&cli.StringSliceFlag{
+			Name:     "replica",
+			Usage:    "`ADDRESS` of a sync service replica, as host:port; repeat for every replica, in the same order on every proxy",
+			Required: true,
+		},
+	},
+}
+
+func syncProxyCommand(c *cli.Context) error {
+	ctx, cancel := context.WithCancel(ProcessContext())
+	defer cancel()
+
+	proxy, err := syncproxy.New(c.StringSlice("replica"), logging.S())
+	if err != nil {
+		return err
+	}
+
+	l, err := net.Listen("tcp", c.String("listen"))
+	if err != nil {
+		return err
+	}
+
+	srv := &http.Server{Handler: proxy}
+	go func() {
+		<-ctx.Done()
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = srv.Shutdown(ctx)
+	}()
+
+	logging.S().Infow("serving sync proxy", "addr", l.Addr().String(), "replicas", len(c.StringSlice("replica")))
+	if err := srv.Serve(l); err != http.ErrServerClosed {
+		return err
+	}
+	return nil
+}
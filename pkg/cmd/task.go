@@ -0,0 +1,123 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/urfave/cli/v2"
+
+	"github.com/testground/testground/pkg/api"
+	"github.com/testground/testground/pkg/client"
+)
+
+var TaskCommand = cli.Command{
+	Name:  "task",
+	Usage: "inspect a task",
+	Subcommands: cli.Commands{
+		&cli.Command{
+			Name:      "explain",
+			Usage:     "explain why a task is queued, or how it was built and where its instances were placed",
+			ArgsUsage: "[task id]",
+			Action:    taskExplainCommand,
+		},
+		&cli.Command{
+			Name:      "cancel",
+			Usage:     "cancel a queued or running task, tearing down the resources of its run",
+			ArgsUsage: "[task id]",
+			Action:    taskCancelCommand,
+		},
+		&cli.Command{
+			Name:      "provenance",
+			Usage:     "print the provenance of a run: the plan, builds, configuration and version it was executed from",
+			ArgsUsage: "[task id]",
+			Action:    taskProvenanceCommand,
+			Flags: []cli.Flag{
+				&cli.StringFlag{
+					Name:    "output",
+					Aliases: []string{"o"},
+					Usage:   "write the provenance to `FILENAME`, to replay it with `testground run replay --manifest`",
+				},
+			},
+		},
+	},
+}
+
+func taskCancelCommand(c *cli.Context) error {
+	ctx, cancel := context.WithCancel(ProcessContext())
+	defer cancel()
+
+	if c.NArg() != 1 {
+		return errors.New("missing task id")
+	}
+
+	cl, _, err := setupClient(c)
+	if err != nil {
+		return err
+	}
+
+	r, err := cl.Cancel(ctx, &api.CancelRequest{TaskID: c.Args().First()})
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	return client.ParseCancelResponse(r, c.App.Writer)
+}
+
+func taskExplainCommand(c *cli.Context) error {
+	ctx, cancel := context.WithCancel(ProcessContext())
+	defer cancel()
+
+	if c.NArg() != 1 {
+		return errors.New("missing task id")
+	}
+
+	cl, _, err := setupClient(c)
+	if err != nil {
+		return err
+	}
+
+	r, err := cl.Explain(ctx, &api.ExplainRequest{TaskID: c.Args().First()})
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	ex, err := client.ParseExplainResponse(r, c.App.Writer)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(c.App.Writer, "task %s is %s\n", ex.TaskID, ex.State)
+
+	if q := ex.Queue; q != nil {
+		fmt.Fprintf(c.App.Writer, "queued at position %d of %d", q.Position, q.Length)
+		if len(q.Ahead) > 0 {
+			fmt.Fprintf(c.App.Writer, ", behind %s", strings.Join(q.Ahead, ", "))
+		}
+		fmt.Fprintln(c.App.Writer)
+		if len(q.Busy) >= q.Workers {
+			fmt.Fprintf(c.App.Writer, "all %d workers are busy with %s\n", q.Workers, strings.Join(q.Busy, ", "))
+		} else if q.RunnerLimit > 0 && q.RunnerRunning >= q.RunnerLimit {
+			fmt.Fprintf(c.App.Writer, "runner %s is running %d of at most %d concurrent runs\n", q.Runner, q.RunnerRunning, q.RunnerLimit)
+		} else {
+			fmt.Fprintf(c.App.Writer, "%d of %d workers are busy; the task will be picked shortly\n", len(q.Busy), q.Workers)
+		}
+	}
+
+	if len(ex.Decisions) == 0 {
+		return nil
+	}
+
+	fmt.Fprintln(c.App.Writer)
+	w := tabwriter.NewWriter(c.App.Writer, 0, 0, 3, ' ', 0)
+	fmt.Fprintln(w, "TIME\tSTAGE\tDECISION")
+	for _, d := range ex.Decisions {
+		fmt.Fprintf(w, "%s\t%s\t%s\n", d.Time.Format(time.RFC3339), d.Stage, d.Message)
+	}
+	return w.Flush()
+}
@@ -31,7 +31,7 @@ func tasksCommand(c *cli.Context) error {
 	}
 
 	req := &api.TasksRequest{
-		Types:  []task.Type{task.TypeBuild, task.TypeRun},
+		Types:  []task.Type{task.TypeBuild, task.TypeRun, task.TypePipeline},
 		States: []task.State{task.StateScheduled, task.StateProcessing, task.StateComplete},
 	}
 
@@ -10,12 +10,16 @@ import (
 	"text/template"
 
 	"github.com/testground/testground/pkg/api"
+	"github.com/testground/testground/pkg/conv"
 
 	"github.com/BurntSushi/toml"
 )
 
 type compositionData struct {
 	Env map[string]string
+
+	// Vars are the variables set on the command line with --set.
+	Vars map[string]string
 }
 
 func compileCompositionTemplate(path string, input *compositionData) (*bytes.Buffer, error) {
@@ -47,6 +51,20 @@ func compileCompositionTemplate(path string, input *compositionData) (*bytes.Buf
 		"atoi": func(s string) (int, error) {
 			return strconv.Atoi(s)
 		},
+		"default": func(def string, v interface{}) string {
+			// missing keys, e.g. unset variables, are nil.
+			if v == nil || v == "" {
+				return def
+			}
+			return fmt.Sprint(v)
+		},
+		"seq": func(n int) []int {
+			xs := make([]int, n)
+			for i := range xs {
+				xs[i] = i + 1
+			}
+			return xs
+		},
 		"load_resource": func(p string) (map[string]interface{}, error) {
 			// NOTE: we do not worry about path that are leaving the template folders, or going through symlinks
 			//		 because this is run on the client.
@@ -57,8 +75,8 @@ func compileCompositionTemplate(path string, input *compositionData) (*bytes.Buf
 				return nil, err
 			}
 
-			var result map[string]interface{}
-			if _, err := toml.Decode(string(data), &result); err != nil {
+			result, err := api.DecodeCompositionTree(api.CompositionFormatOf(fullPath), data)
+			if err != nil {
 				return nil, fmt.Errorf("load_resource %s failed: %w", p, err)
 			}
 
@@ -85,18 +103,48 @@ func compileCompositionTemplate(path string, input *compositionData) (*bytes.Buf
 	return buff, nil
 }
 
-func loadComposition(path string) (*api.Composition, error) {
-	data := &compositionData{Env: map[string]string{}}
+// newCompositionData returns the input of composition templates: the
+// environment variables, and the variables set with --set.
+func newCompositionData(set []string) (*compositionData, error) {
+	vars, err := conv.ParseKeyValues(set)
+	if err != nil {
+		return nil, fmt.Errorf("invalid --set variable: %w", err)
+	}
+
+	data := &compositionData{Env: map[string]string{}, Vars: vars}
 
 	// Build a map of environment variables
 	for _, v := range os.Environ() {
 		s := strings.SplitN(v, "=", 2)
 		data.Env[s[0]] = s[1]
 	}
+	return data, nil
+}
 
-	buff, err := compileCompositionTemplate(path, data)
+// loadComposition loads a composition file, in TOML, YAML or JSON depending on
+// its extension: it runs the file as a template, with the environment and the
+// --set variables, merges it on top of the files it extends, and expands its
+// repeated groups. Sweeps are only validated; the daemon expands them.
+func loadComposition(path string, set []string) (*api.Composition, error) {
+	return loadCompositionVisiting(path, set, nil)
+}
+
+// loadCompositionVisiting loads a composition file like loadComposition,
+// calling visit with every file it loads, after processing the template.
+func loadCompositionVisiting(path string, set []string, visit visitComposition) (*api.Composition, error) {
+	data, err := newCompositionData(set)
 	if err != nil {
-		return nil, fmt.Errorf("failed to process composition template: %w", err)
+		return nil, err
+	}
+
+	tree, err := loadCompositionTree(path, data, nil, visit)
+	if err != nil {
+		return nil, err
+	}
+
+	var buff bytes.Buffer
+	if err := toml.NewEncoder(&buff).Encode(tree); err != nil {
+		return nil, fmt.Errorf("failed to process composition file: %w", err)
 	}
 
 	comp := new(api.Composition)
@@ -106,9 +154,129 @@ func loadComposition(path string) (*api.Composition, error) {
 
 	comp = comp.GenerateDefaultRun()
 
+	if _, err = comp.ExpandSweeps(); err != nil {
+		return nil, fmt.Errorf("failed to expand sweeps: %w", err)
+	}
+
+	comp, err = comp.ExpandGroups()
 	if err != nil {
-		return nil, fmt.Errorf("failed to prepare composition: %w", err)
+		return nil, fmt.Errorf("failed to expand repeated groups: %w", err)
 	}
 
 	return comp, nil
 }
+
+// visitComposition is called with the source of a composition file, and its
+// decoded tree, without the extends directive.
+type visitComposition func(path string, src []byte, tree map[string]interface{})
+
+// loadCompositionTree processes a composition template, and merges the result
+// on top of the compositions it extends, in order. The extended files are
+// relative to the file extending them, and don't need to be complete
+// compositions, e.g. a file with the groups shared by several compositions.
+func loadCompositionTree(path string, data *compositionData, parents []string, visit visitComposition) (map[string]interface{}, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range parents {
+		if p == abs {
+			return nil, fmt.Errorf("composition %s extends itself", path)
+		}
+	}
+
+	buff, err := compileCompositionTemplate(path, data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to process composition template %s: %w", path, err)
+	}
+
+	tree, err := api.DecodeCompositionTree(api.CompositionFormatOf(path), buff.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("failed to process composition file %s: %w", path, err)
+	}
+
+	var extends []string
+	switch v := tree["extends"].(type) {
+	case nil:
+	case string:
+		extends = []string{v}
+	case []interface{}:
+		for _, x := range v {
+			s, ok := x.(string)
+			if !ok {
+				return nil, fmt.Errorf("composition %s: extends must be a list of paths", path)
+			}
+			extends = append(extends, s)
+		}
+	default:
+		return nil, fmt.Errorf("composition %s: extends must be a path or a list of paths", path)
+	}
+	delete(tree, "extends")
+
+	if visit != nil {
+		visit(path, buff.Bytes(), tree)
+	}
+
+	if len(extends) == 0 {
+		return tree, nil
+	}
+
+	merged := map[string]interface{}{}
+	for _, e := range extends {
+		base, err := loadCompositionTree(filepath.Join(filepath.Dir(path), e), data, append(parents, abs), visit)
+		if err != nil {
+			return nil, err
+		}
+		mergeCompositionTrees(merged, base)
+	}
+	mergeCompositionTrees(merged, tree)
+	return merged, nil
+}
+
+// mergeCompositionTrees merges src into dst. Tables are merged recursively,
+// and arrays of tables with ids, like groups and runs, are merged by id:
+// entries with the id of an existing entry are merged into it, and the others
+// are appended. Anything else in src replaces what's in dst.
+func mergeCompositionTrees(dst, src map[string]interface{}) {
+	for k, v := range src {
+		switch sv := v.(type) {
+		case map[string]interface{}:
+			if dv, ok := dst[k].(map[string]interface{}); ok {
+				mergeCompositionTrees(dv, sv)
+				continue
+			}
+		case []map[string]interface{}:
+			if dv, ok := dst[k].([]map[string]interface{}); ok && hasIds(dv) && hasIds(sv) {
+				dst[k] = mergeById(dv, sv)
+				continue
+			}
+		}
+		dst[k] = v
+	}
+}
+
+func mergeById(dst, src []map[string]interface{}) []map[string]interface{} {
+	for _, s := range src {
+		var merged bool
+		for _, d := range dst {
+			if d["id"] == s["id"] {
+				mergeCompositionTrees(d, s)
+				merged = true
+				break
+			}
+		}
+		if !merged {
+			dst = append(dst, s)
+		}
+	}
+	return dst
+}
+
+func hasIds(tables []map[string]interface{}) bool {
+	for _, t := range tables {
+		if _, ok := t["id"].(string); !ok {
+			return false
+		}
+	}
+	return true
+}
@@ -75,3 +75,48 @@ func TestLoadCompositionWithTomlAndPickOperators(t *testing.T) {
 	require.Nil(t, err)
 	require.Equal(t, expected, str)
 }
+
+func TestLoadCompositionWithExtends(t *testing.T) {
+	comp, err := loadComposition("fixtures/templates/with-extends.toml", []string{"latency=100ms", "relays=3"})
+	require.NoError(t, err)
+
+	require.Equal(t, "extended", comp.Metadata.Name)
+	require.Equal(t, "network", comp.Global.Plan)
+	require.Equal(t, "local:docker", comp.Global.Runner)
+	require.EqualValues(t, 10, comp.Global.TotalInstances)
+
+	require.Equal(t, []string{"pingers", "pongers", "relays-1", "relays-2", "relays-3"}, comp.ListGroupsIds())
+
+	// groups with the same id are merged.
+	pingers, err := comp.GetGroup("pingers")
+	require.NoError(t, err)
+	require.Equal(t, 0.5, pingers.Instances.Percentage)
+	require.Equal(t, map[string]string{"latency": "100ms", "jitter": "5"}, pingers.Run.TestParams)
+
+	require.Len(t, comp.Runs, 1)
+	require.Len(t, comp.Runs[0].Groups, 5)
+	require.Equal(t, "relays-3", comp.Runs[0].Groups[4].ID)
+}
+
+func TestLoadCompositionExtendsItself(t *testing.T) {
+	_, err := loadComposition("fixtures/templates/extends-self.toml", nil)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "extends itself")
+
+	_, err = loadComposition("fixtures/templates/with-extends.toml", []string{"latency"})
+	require.Error(t, err)
+}
+
+func TestLoadCompositionYAML(t *testing.T) {
+	comp, err := loadComposition("fixtures/templates/extends-yaml.yaml", []string{"instances=4"})
+	require.NoError(t, err)
+
+	require.Equal(t, "network", comp.Global.Plan)
+	require.EqualValues(t, 4, comp.Global.TotalInstances)
+	require.Equal(t, []string{"pingers", "pongers"}, comp.ListGroupsIds())
+
+	pongers, err := comp.GetGroup("pongers")
+	require.NoError(t, err)
+	require.Equal(t, 0.5, pongers.Instances.Percentage)
+	require.Equal(t, "pong", pongers.Run.TestParams["role"])
+}
@@ -0,0 +1,162 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"text/tabwriter"
+	"time"
+
+	"github.com/urfave/cli/v2"
+
+	"github.com/testground/testground/pkg/api"
+	"github.com/testground/testground/pkg/auth"
+	"github.com/testground/testground/pkg/client"
+)
+
+var TokenCommand = cli.Command{
+	Name:  "token",
+	Usage: "manage the scoped API tokens of the daemon (requires an admin token)",
+	Subcommands: cli.Commands{
+		&cli.Command{
+			Name:  "create",
+			Usage: "issue a new token; its secret is only printed once",
+			Flags: []cli.Flag{
+				&cli.StringFlag{
+					Name:     "name",
+					Usage:    "`NAME` describing who or what the token is for, e.g. ci",
+					Required: true,
+				},
+				&cli.StringFlag{
+					Name:     "scope",
+					Usage:    "`SCOPE` of the token; values: read-only, submit-only, admin",
+					Required: true,
+				},
+				&cli.DurationFlag{
+					Name:  "expires",
+					Usage: "how long the token is valid for; 0 for a token that never expires",
+					Value: defaultTokenExpiry,
+				},
+			},
+			Action: tokenCreateCommand,
+		},
+		&cli.Command{
+			Name:   "list",
+			Usage:  "list the issued tokens",
+			Action: tokenListCommand,
+		},
+		&cli.Command{
+			Name:      "revoke",
+			Usage:     "revoke a token",
+			ArgsUsage: "[token id]",
+			Action:    tokenRevokeCommand,
+		},
+	},
+}
+
+// defaultTokenExpiry keeps the tokens short-lived unless asked otherwise;
+// long-lived tokens, e.g. for CI, need an explicit --expires 0.
+const defaultTokenExpiry = 24 * time.Hour
+
+func tokenCreateCommand(c *cli.Context) error {
+	ctx, cancel := context.WithCancel(ProcessContext())
+	defer cancel()
+
+	scope, err := auth.ParseScope(c.String("scope"))
+	if err != nil {
+		return err
+	}
+
+	if c.Duration("expires") < 0 {
+		return fmt.Errorf("--expires must not be negative")
+	}
+
+	cl, _, err := setupClient(c)
+	if err != nil {
+		return err
+	}
+
+	r, err := cl.CreateToken(ctx, &api.TokenCreateRequest{
+		Name:        c.String("name"),
+		Scope:       scope,
+		ExpiresSecs: int(c.Duration("expires").Seconds()),
+	})
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	resp, err := client.ParseTokenCreateResponse(r, c.App.Writer)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(c.App.Writer, "created token %s (%s, expires: %s)\n", resp.Token.ID, resp.Token.Scope, formatExpiry(resp.Token))
+	fmt.Fprintf(c.App.Writer, "secret: %s\n", resp.Secret)
+	fmt.Fprintln(c.App.Writer, "store it now, e.g. as [client] token in .env.toml; it can't be retrieved again")
+	return nil
+}
+
+func tokenListCommand(c *cli.Context) error {
+	ctx, cancel := context.WithCancel(ProcessContext())
+	defer cancel()
+
+	cl, _, err := setupClient(c)
+	if err != nil {
+		return err
+	}
+
+	r, err := cl.ListTokens(ctx)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	tokens, err := client.ParseTokenListResponse(r, c.App.Writer)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(c.App.Writer, 0, 0, 3, ' ', 0)
+
+	fmt.Fprintln(w, "ID\tNAME\tSCOPE\tCREATED\tEXPIRES")
+
+	for _, tok := range tokens {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", tok.ID, tok.Name, tok.Scope, tok.Created.Format(time.RFC3339), formatExpiry(tok))
+	}
+
+	return w.Flush()
+}
+
+func tokenRevokeCommand(c *cli.Context) error {
+	ctx, cancel := context.WithCancel(ProcessContext())
+	defer cancel()
+
+	if c.NArg() != 1 {
+		return errors.New("missing token id")
+	}
+
+	cl, _, err := setupClient(c)
+	if err != nil {
+		return err
+	}
+
+	r, err := cl.RevokeToken(ctx, &api.TokenRevokeRequest{ID: c.Args().First()})
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	return client.ParseTokenRevokeResponse(r, c.App.Writer)
+}
+
+func formatExpiry(tok *auth.Token) string {
+	switch {
+	case tok.Expires.IsZero():
+		return "never"
+	case tok.Expired(time.Now()):
+		return tok.Expires.Format(time.RFC3339) + " (expired)"
+	default:
+		return tok.Expires.Format(time.RFC3339)
+	}
+}
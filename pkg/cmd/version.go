@@ -1,8 +1,11 @@
 package cmd
 
 import (
+	"context"
 	"fmt"
+	"time"
 
+	"github.com/testground/testground/pkg/api"
 	"github.com/testground/testground/pkg/version"
 	"github.com/urfave/cli/v2"
 )
@@ -11,14 +14,49 @@ var VersionCommand = cli.Command{
 	Name:   "version",
 	Usage:  "print version numbers",
 	Action: versionCommand,
+	Flags: []cli.Flag{
+		&cli.BoolFlag{
+			Name:  "daemon",
+			Usage: "also print the versions the daemon advertises, including the runtime API versions it supports",
+		},
+	},
 }
 
 func versionCommand(c *cli.Context) error {
 	fmt.Println("Testground")
 	if version.GitCommit == "" {
 		fmt.Println("Git commit: dirty")
+	} else {
+		fmt.Println("Git commit:", version.GitCommit[:8])
+	}
+	fmt.Println("Runtime API:", api.SupportedRuntimeAPI())
+
+	if !c.Bool("daemon") {
 		return nil
 	}
-	fmt.Println("Git commit:", version.GitCommit[:8])
+
+	cl, _, err := setupClient(c)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ProcessContext(), 10*time.Second)
+	defer cancel()
+
+	v, err := cl.Version(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get the version of the daemon: %w", err)
+	}
+
+	fmt.Println()
+	fmt.Println("Daemon")
+	if v.Version == "" {
+		fmt.Println("Git commit: dirty")
+	} else if len(v.Version) > 8 {
+		fmt.Println("Git commit:", v.Version[:8])
+	} else {
+		fmt.Println("Git commit:", v.Version)
+	}
+	fmt.Println("Runtime API:", v.RuntimeAPI)
 	return nil
 }
@@ -14,7 +14,9 @@ func (c CoalescedConfig) Append(in map[string]interface{}) CoalescedConfig {
 	return append(c, in)
 }
 
-func (c CoalescedConfig) CoalesceIntoType(typ reflect.Type) (interface{}, error) {
+// Coalesce merges the configurations into a map, later ones taking
+// precedence.
+func (c CoalescedConfig) Coalesce() map[string]interface{} {
 	all := make(map[string]interface{})
 
 	// Copy all values into coalesced map.
@@ -26,6 +28,11 @@ func (c CoalescedConfig) CoalesceIntoType(typ reflect.Type) (interface{}, error)
 			all[k] = v
 		}
 	}
+	return all
+}
+
+func (c CoalescedConfig) CoalesceIntoType(typ reflect.Type) (interface{}, error) {
+	all := c.Coalesce()
 
 	// Serialize map into TOML, and then deserialize into the appropriate type.
 	buf := new(bytes.Buffer)
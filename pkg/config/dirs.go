@@ -26,6 +26,22 @@ func (d Directories) Outputs() string {
 	return filepath.Join(d.home, "data", "outputs")
 }
 
+func (d Directories) Snapshots() string {
+	return filepath.Join(d.home, "data", "snapshots")
+}
+
+func (d Directories) Fixtures() string {
+	return filepath.Join(d.home, "data", "fixtures")
+}
+
+func (d Directories) Builds() string {
+	return filepath.Join(d.home, "data", "builds")
+}
+
 func (d Directories) Daemon() string {
 	return filepath.Join(d.home, "data", "daemon")
 }
+
+func (d Directories) PlanSources() string {
+	return filepath.Join(d.home, "data", "plan-sources")
+}
@@ -1,5 +1,10 @@
 package config
 
+import (
+	"errors"
+	"strings"
+)
+
 type ConfigMap map[string]interface{}
 
 // EnvConfig contains the environment configuration. It is populated by
@@ -17,6 +22,7 @@ type EnvConfig struct {
 	Runners   map[string]ConfigMap `toml:"runners"`
 	Daemon    DaemonConfig         `toml:"daemon"`
 	Client    ClientConfig         `toml:"client"`
+	Tracing   TracingConfig        `toml:"tracing"`
 }
 
 func (e EnvConfig) Dirs() Directories {
@@ -43,6 +49,228 @@ type DaemonConfig struct {
 	GithubRepoStatusToken string          `toml:"github_repo_status_token"`
 	RootURL               string          `toml:"root_url"`
 	InfluxDBEndpoint      string          `toml:"influxdb_endpoint"`
+	// UI enables the web UI served under /ui.
+	UI bool `toml:"ui"`
+	// Registry exposes the instances of live runs under /registry.
+	Registry bool `toml:"registry"`
+	// Retention configures the garbage collection of run outputs, cached
+	// sources and build artifacts.
+	Retention RetentionConfig `toml:"retention"`
+	// Outputs configures the storage the outputs of runs are uploaded to.
+	Outputs OutputsConfig `toml:"outputs"`
+	// OIDC accepts the ID tokens of an OpenID Connect provider, along with
+	// the tokens above and those issued by the daemon.
+	OIDC OIDCConfig `toml:"oidc"`
+	// TLS serves the API over TLS, when a certificate is set.
+	TLS DaemonTLSConfig `toml:"tls"`
+	// Notifications posts the outcome of completed tasks to webhooks. It
+	// supersedes SlackWebhookURL, which is used as its Slack webhook if that
+	// isn't set.
+	Notifications NotificationsConfig `toml:"notifications"`
+	// GRPCListen is the host:port the gRPC API is served on, with the TLS
+	// config of the HTTP one. It isn't served if empty.
+	GRPCListen string `toml:"grpc_listen"`
+	// Observability provisions the stack the metrics of the instances are
+	// sent to, and a Grafana dashboard for every run.
+	Observability ObservabilityConfig `toml:"observability"`
+	// Offline runs the daemon in an air-gapped environment.
+	Offline OfflineConfig `toml:"offline"`
+	// Scan scans the artifacts of builds for vulnerabilities.
+	Scan ScanConfig `toml:"scan"`
+	// PlanSources restricts the remote sources the daemon fetches plans from.
+	PlanSources PlanSourcesConfig `toml:"plan_sources"`
+}
+
+// PlanSourcesConfig configures the remote sources of plans the daemon clones
+// for the requests that carry one. Only https repositories are cloned, unless
+// ssh is allowed for some hosts; ssh clones authenticate with the keys and the
+// agent of the daemon.
+type PlanSourcesConfig struct {
+	// SSHHosts are the hosts repositories may be cloned from over ssh.
+	SSHHosts []string `toml:"ssh_hosts"`
+}
+
+// ScanConfig configures the scan of the artifacts of builds for
+// vulnerabilities, with Trivy or Grype, once they're built. The reports are
+// attached to the outputs of the build, and to the outputs of the run.
+type ScanConfig struct {
+	// Scanner is "trivy" or "grype". Artifacts aren't scanned if it's empty.
+	Scanner string `toml:"scanner"`
+	// Path is the path of the binary of the scanner (default: the scanner,
+	// looked up in the PATH of the daemon).
+	Path string `toml:"path"`
+	// FailOn is the severity from which vulnerabilities fail the task: low,
+	// medium, high or critical. Reports are only attached if it's empty.
+	FailOn string `toml:"fail_on"`
+	// IgnoreUnfixed doesn't fail the task on vulnerabilities without a fix.
+	IgnoreUnfixed bool `toml:"ignore_unfixed"`
+	// TimeoutMin bounds the scan of an artifact (default: 10 minutes).
+	TimeoutMin int `toml:"timeout_min"`
+}
+
+// OfflineConfig configures the offline mode of the daemon, for air-gapped
+// environments. In offline mode, the daemon never reaches external networks:
+// images come from the local Docker daemon or a mirror registry on the LAN,
+// Go plans are built from their vendored modules, and no notification is
+// posted to Slack, Discord or GitHub. Plan sources and fixtures are only
+// served from their caches, and OIDC, which fetches the keys of the provider,
+// can't be enabled. `testground bundle` packages what runs need into the
+// air-gapped environment.
+type OfflineConfig struct {
+	Enabled bool `toml:"enabled"`
+	// Registry is a registry on the LAN mirroring the images of the daemon,
+	// e.g. registry.lan:5000. Images missing locally are pulled from it, as
+	// <registry>/<repository>:<tag>. When empty, images must be loaded into
+	// the local Docker daemon, e.g. with `testground bundle load`.
+	Registry string `toml:"registry"`
+	// NixSubstituters are the URLs of Nix binary caches on the LAN, and
+	// NixTrustedPublicKeys the keys they sign with. Docker builds get them
+	// as the NIX_CONFIG build arg.
+	NixSubstituters      []string `toml:"nix_substituters"`
+	NixTrustedPublicKeys []string `toml:"nix_trusted_public_keys"`
+}
+
+// ErrOffline is returned for what would reach external networks in offline
+// mode.
+var ErrOffline = errors.New("offline mode")
+
+// NixConfig returns the nix.conf settings of the Nix binary caches, or an
+// empty string if there are none.
+func (c OfflineConfig) NixConfig() string {
+	if len(c.NixSubstituters) == 0 {
+		return ""
+	}
+	conf := "substituters = " + strings.Join(c.NixSubstituters, " ") + "\n"
+	if len(c.NixTrustedPublicKeys) > 0 {
+		conf += "trusted-public-keys = " + strings.Join(c.NixTrustedPublicKeys, " ") + "\n"
+	}
+	return conf
+}
+
+const (
+	// ObservabilityStackLocal runs InfluxDB and Grafana as containers on the
+	// control network of the local runners.
+	ObservabilityStackLocal = "local"
+	// ObservabilityStackExternal uses the InfluxDB and Grafana of the
+	// endpoints configured, e.g. those of the cluster of cluster:k8s.
+	ObservabilityStackExternal = "external"
+)
+
+// ObservabilityConfig configures the observability stack of the daemon. The
+// daemon provisions the InfluxDB datasource of Grafana, and a dashboard of
+// the metrics of every run, which is linked from its task.
+type ObservabilityConfig struct {
+	// Stack is "local" or "external"; nothing is provisioned when empty.
+	Stack string `toml:"stack"`
+	// GrafanaURL is the URL the daemon reaches the API of Grafana at;
+	// http://localhost:3000 for the local stack by default.
+	GrafanaURL string `toml:"grafana_url"`
+	// GrafanaPublicURL is the URL dashboards are linked at, as users reach
+	// Grafana; the same as GrafanaURL by default.
+	GrafanaPublicURL string `toml:"grafana_public_url"`
+	// GrafanaToken is an API key of Grafana with the editor role. The daemon
+	// authenticates with GrafanaUser and GrafanaPassword when empty; those are
+	// admin and admin for the local stack by default.
+	GrafanaToken    string `toml:"grafana_token"`
+	GrafanaUser     string `toml:"grafana_user"`
+	GrafanaPassword string `toml:"grafana_password"`
+	// InfluxDBURL is the URL Grafana reaches InfluxDB at, for its datasource;
+	// http://testground-influxdb:8086 for the local stack by default. The
+	// datasource isn't provisioned when empty.
+	InfluxDBURL string `toml:"influxdb_url"`
+}
+
+// NotificationsConfig configures the notifications posted when tasks
+// complete.
+type NotificationsConfig struct {
+	// Webhooks are URLs a JSON payload describing the task is POSTed to.
+	Webhooks []string `toml:"webhooks"`
+	// Slack and Discord are the URLs of incoming webhooks, which are posted a
+	// message.
+	Slack   string `toml:"slack"`
+	Discord string `toml:"discord"`
+	// Outcomes restricts the notifications to the tasks of these outcomes,
+	// e.g. ["failure", "canceled"]. All tasks are notified when empty.
+	Outcomes []string `toml:"outcomes"`
+}
+
+// DaemonTLSConfig configures the TLS listener of the daemon.
+type DaemonTLSConfig struct {
+	// CertFile and KeyFile are the PEM encoded certificate and key of the
+	// daemon.
+	CertFile string `toml:"cert_file"`
+	KeyFile  string `toml:"key_file"`
+	// ClientCAFile, if set, requires clients to present a certificate signed
+	// by one of the PEM encoded CAs it holds (mutual TLS).
+	ClientCAFile string `toml:"client_ca_file"`
+}
+
+// OutputsConfig configures where the daemon keeps the outputs of runs, once
+// they are done. They stay on the local disk of the daemon when no backend is
+// set.
+type OutputsConfig struct {
+	// Backend is "s3", for AWS S3 and S3-compatible stores such as MinIO, or
+	// GCS through its interoperability API; or "dir", for a directory, e.g.
+	// a network mount.
+	Backend string `toml:"backend"`
+	// Bucket is the bucket of the s3 backend.
+	Bucket string `toml:"bucket"`
+	// Path is the directory of the dir backend.
+	Path string `toml:"path"`
+	// Prefix is prepended to the key of the outputs of every task, which
+	// are stored as <prefix>/<task id>/outputs.tgz.
+	Prefix string `toml:"prefix"`
+	// Endpoint overrides the endpoint of the s3 backend, e.g.
+	// http://minio:9000 or https://storage.googleapis.com.
+	Endpoint string `toml:"endpoint"`
+	// Region of the bucket; defaults to the aws region.
+	Region string `toml:"region"`
+	// PathStyle addresses the bucket in the path rather than the host name,
+	// as MinIO requires unless configured otherwise.
+	PathStyle bool `toml:"path_style"`
+	// AccessKeyID and SecretAccessKey default to the aws credentials.
+	AccessKeyID     string `toml:"access_key_id"`
+	SecretAccessKey string `toml:"secret_access_key"`
+	// KeepLocal keeps the outputs on the local disk as well, once uploaded.
+	// Downstream runs of a pipeline read the outputs of upstream runs from
+	// the local disk, so it must be set for pipelines.
+	KeepLocal bool `toml:"keep_local"`
+}
+
+// RetentionConfig holds the retention policies of the daemon. Each policy is
+// disabled when zero, and an item is removed as soon as one policy selects it.
+type RetentionConfig struct {
+	// MaxAgeDays removes the items older than this many days.
+	MaxAgeDays int `toml:"max_age_days"`
+	// MaxTotalSizeMB removes the oldest run outputs and sources until the
+	// ones left occupy at most this many megabytes.
+	MaxTotalSizeMB int `toml:"max_total_size_mb"`
+	// KeepLastPerPlan keeps only the outputs of the last N runs, and the
+	// last N build artifacts, of every plan.
+	KeepLastPerPlan int `toml:"keep_last_per_plan"`
+	// IntervalMin is how often the daemon garbage collects; it only does
+	// so on demand, through `testground gc`, when zero.
+	IntervalMin int `toml:"interval_min"`
+}
+
+// OIDCConfig lets users authenticate against the daemon with the ID tokens
+// of an OpenID Connect provider, instead of tokens issued by the daemon. The
+// groups of a user grant their scope.
+type OIDCConfig struct {
+	// Issuer is the URL of the provider, e.g. https://accounts.google.com;
+	// OIDC is disabled when empty.
+	Issuer string `toml:"issuer"`
+	// Audience is the client ID ID tokens must have been issued to.
+	Audience string `toml:"audience"`
+	// GroupsClaim is the claim listing the groups of a user; "groups" by
+	// default.
+	GroupsClaim string `toml:"groups_claim"`
+	// Groups maps groups to the scope they grant. Users in several groups
+	// get the broadest scope.
+	Groups map[string]string `toml:"groups"`
+	// DefaultScope is the scope of the users in none of the groups; they
+	// are denied when empty.
+	DefaultScope string `toml:"default_scope"`
 }
 
 type SchedulerConfig struct {
@@ -50,15 +278,58 @@ type SchedulerConfig struct {
 	QueueSize      int    `toml:"queue_size"`
 	TaskRepoType   string `toml:"task_repo_type"`
 	TaskTimeoutMin int    `toml:"task_timeout_min"`
+	// Preemption lets high priority runs preempt low priority ones when all
+	// workers are busy, or their runner is at its limit. Preempted runs are
+	// queued again, and start over.
+	Preemption bool `toml:"preemption"`
+	// RunnerConcurrency caps the runs of each runner processed at once, e.g.
+	// 1 for local:docker and 4 for cluster:k8s. Runners that aren't listed
+	// are only capped by the number of workers.
+	RunnerConcurrency map[string]int `toml:"runner_concurrency"`
 }
 
 type ClientConfig struct {
 	Endpoint string `toml:"endpoint"`
 	Token    string `toml:"token"`
-	User     string `toml:"user"`
+	// TokenCommand prints the token to authenticate with, e.g. the ID
+	// token of an OIDC provider; it takes precedence over Token.
+	TokenCommand string `toml:"token_command"`
+	User         string `toml:"user"`
+	// TLS configures how the client connects to an https endpoint.
+	TLS ClientTLSConfig `toml:"tls"`
+}
+
+// ClientTLSConfig configures the TLS connections of the client.
+type ClientTLSConfig struct {
+	// CAFile holds the PEM encoded CAs the certificate of the daemon is
+	// verified against, instead of those of the system.
+	CAFile string `toml:"ca_file"`
+	// CertFile and KeyFile are the PEM encoded certificate and key the
+	// client presents to daemons that require one.
+	CertFile string `toml:"cert_file"`
+	KeyFile  string `toml:"key_file"`
+	// ServerName overrides the name the certificate of the daemon is
+	// verified for, e.g. when connecting through a tunnel.
+	ServerName string `toml:"server_name"`
+	// InsecureSkipVerify disables the verification of the certificate of
+	// the daemon. Only meant for testing.
+	InsecureSkipVerify bool `toml:"insecure_skip_verify"`
+}
+
+// TracingConfig configures the export of OpenTelemetry traces, shared by the
+// client and the daemon.
+type TracingConfig struct {
+	// Endpoint is the host:port of the OTLP/HTTP collector. Tracing is
+	// disabled when empty.
+	Endpoint string `toml:"endpoint"`
+	// Insecure disables TLS when talking to the collector.
+	Insecure bool `toml:"insecure"`
 }
 
 // Common config flags kept here to avoid magic strings
 
 // Indicates whether a runner is disabled
 const RunnerDisabledFlag = "disabled"
+
+// Indicates whether a builder is disabled
+const BuilderDisabledFlag = "disabled"
@@ -13,8 +13,8 @@ import (
 const (
 	EnvTestgroundHomeDir = "TESTGROUND_HOME"
 
-	// DefaultListenAddr is a host:port value, where we set up an HTTP endpoint.
-	// In the future we will support an HTTPS mode.
+	// DefaultListenAddr is a host:port value, where we set up an HTTP endpoint,
+	// or an HTTPS one when the daemon has a TLS certificate.
 	DefaultListenAddr = "localhost:8042"
 
 	// DefaultClientURL is the HTTP(S) endpoint of the server.
@@ -27,6 +27,14 @@ const (
 	DefaultWorkers = 2
 
 	DefaultQueueSize = 100
+
+	// DefaultGrafanaURL, DefaultGrafanaUser, DefaultGrafanaPassword and
+	// DefaultStackInfluxDBURL are the defaults of the local observability
+	// stack.
+	DefaultGrafanaURL       = "http://localhost:3000"
+	DefaultGrafanaUser      = "admin"
+	DefaultGrafanaPassword  = "admin"
+	DefaultStackInfluxDBURL = "http://testground-influxdb:8086"
 )
 
 func (e *EnvConfig) Load() error {
@@ -50,6 +58,19 @@ func (e *EnvConfig) Load() error {
 	return nil
 }
 
+// LoadFrom loads the env config from the file at path, instead of the
+// .env.toml file of the home directory. Unlike the latter, it must exist.
+func (e *EnvConfig) LoadFrom(path string) error {
+	if err := e.EnsureMinimalConfig(); err != nil {
+		return err
+	}
+	if _, err := toml.DecodeFile(path, e); err != nil {
+		return fmt.Errorf("failed to parse env config %s: %w", path, err)
+	}
+	logging.S().Infof("env config loaded from: %s", path)
+	return nil
+}
+
 // returns $HOME/testground if it exists and is a directory (legacy)
 // otherwise, returns $XDG_CONFIG_HOME/testground
 func getDefaultHome() string {
@@ -79,6 +100,15 @@ func (e *EnvConfig) EnsureMinimalConfig() error {
 	e.Daemon.Scheduler.QueueSize = defaultInt(e.Daemon.Scheduler.QueueSize, DefaultQueueSize)
 	e.Daemon.Scheduler.TaskRepoType = defaultString(e.Daemon.Scheduler.TaskRepoType, DefaultTaskRepoType)
 
+	if obs := &e.Daemon.Observability; obs.Stack == ObservabilityStackLocal {
+		obs.GrafanaURL = defaultString(obs.GrafanaURL, DefaultGrafanaURL)
+		obs.InfluxDBURL = defaultString(obs.InfluxDBURL, DefaultStackInfluxDBURL)
+		if obs.GrafanaToken == "" {
+			obs.GrafanaUser = defaultString(obs.GrafanaUser, DefaultGrafanaUser)
+			obs.GrafanaPassword = defaultString(obs.GrafanaPassword, DefaultGrafanaPassword)
+		}
+	}
+
 	// 1. Use $TESTGROUND_HOME if set
         // 2. Otherwise use $HOME/testground if directory exists (legacy, to be deprecated)
         // 3. Otherwise use $XDG_CONFIG_HOME/testground
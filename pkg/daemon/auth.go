@@ -0,0 +1,164 @@
+package daemon
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+
+	"github.com/testground/testground/pkg/api"
+	"github.com/testground/testground/pkg/auth"
+	"github.com/testground/testground/pkg/logging"
+	"github.com/testground/testground/pkg/task"
+)
+
+// endpointScopes are the scopes required by the endpoints of the daemon, by
+// method and path template. Endpoints that aren't listed require the admin
+// scope, e.g. /exec, which opens a shell in the instances of any user.
+var endpointScopes = map[string]auth.Scope{
+	"GET /":               auth.ScopeReadOnly,
+	"GET /static/":        auth.ScopeReadOnly,
+	"GET /data":           auth.ScopeReadOnly,
+	"GET /dashboard":      auth.ScopeReadOnly,
+	"GET /tasks":          auth.ScopeReadOnly,
+	"GET /logs":           auth.ScopeReadOnly,
+	"GET /outputs":        auth.ScopeReadOnly,
+	"GET /journal":        auth.ScopeReadOnly,
+	"GET /metrics":        auth.ScopeReadOnly,
+	"GET /ui":             auth.ScopeReadOnly,
+	"GET /ui/task":        auth.ScopeReadOnly,
+	"GET /ui/logs":        auth.ScopeReadOnly,
+	"GET /registry":       auth.ScopeReadOnly,
+	"GET /version":        auth.ScopeReadOnly,
+	"GET /events":         auth.ScopeReadOnly,
+	"POST /outputs":       auth.ScopeReadOnly,
+	"POST /build/collect": auth.ScopeReadOnly,
+	"POST /tasks":         auth.ScopeReadOnly,
+	"POST /status":        auth.ScopeReadOnly,
+	"POST /logs":          auth.ScopeReadOnly,
+	"POST /explain":       auth.ScopeReadOnly,
+	"POST /build":         auth.ScopeSubmitOnly,
+	"POST /run":           auth.ScopeSubmitOnly,
+	"POST /param":         auth.ScopeSubmitOnly,
+	"POST /network":       auth.ScopeSubmitOnly,
+	"POST /scale":         auth.ScopeSubmitOnly,
+	"POST /cancel":        auth.ScopeSubmitOnly,
+}
+
+// requiredScope returns the scope required by the matched route of a request.
+func requiredScope(r *http.Request) auth.Scope {
+	route := mux.CurrentRoute(r)
+	if route == nil {
+		return auth.ScopeAdmin
+	}
+	tpl, err := route.GetPathTemplate()
+	if err != nil {
+		return auth.ScopeAdmin
+	}
+	if scope, ok := endpointScopes[r.Method+" "+tpl]; ok {
+		return scope
+	}
+	return auth.ScopeAdmin
+}
+
+// authenticator checks the tokens requests carry. The static tokens of the
+// daemon config have the admin scope; the others are those issued through the
+// /token endpoints, and the ID tokens of the OIDC provider, if one is
+// configured.
+type authenticator struct {
+	static map[string]struct{}
+	store  *auth.Store
+	oidc   *auth.OIDCVerifier
+}
+
+func newAuthenticator(static []string, store *auth.Store, oidc *auth.OIDCVerifier) *authenticator {
+	tokens := map[string]struct{}{}
+	for _, t := range static {
+		tokens[strings.TrimSpace(t)] = struct{}{}
+	}
+	return &authenticator{static: tokens, store: store, oidc: oidc}
+}
+
+// authenticate returns the token of an `Authorization: Bearer` header value.
+func (a *authenticator) authenticate(ctx context.Context, header string) (*auth.Token, error) {
+	splitToken := strings.Split(header, "Bearer ")
+	if len(splitToken) != 2 {
+		return nil, errors.New("no bearer token")
+	}
+	requestToken := strings.TrimSpace(splitToken[1])
+
+	switch _, isStatic := a.static[requestToken]; {
+	case isStatic:
+		return &auth.Token{ID: "static", Scope: auth.ScopeAdmin}, nil
+	case a.oidc != nil && auth.IsJWT(requestToken):
+		return a.oidc.Verify(ctx, requestToken)
+	default:
+		return a.store.Check(requestToken)
+	}
+}
+
+// authMiddleware rejects the requests that don't carry a token allowed to
+// call the endpoint. The token is passed on to the handlers in the request
+// context.
+func authMiddleware(a *authenticator) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			tok, err := a.authenticate(r.Context(), r.Header.Get("Authorization"))
+			if err != nil {
+				logging.S().Debugw("request not authenticated", "path", r.URL.Path, "err", err)
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+
+			if required := requiredScope(r); !tok.Scope.Allows(required) {
+				logging.S().Infow("request denied", "token", tok.ID, "name", tok.Name, "scope", tok.Scope, "required", required, "path", r.URL.Path)
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+
+			next.ServeHTTP(w, r.WithContext(auth.WithToken(r.Context(), tok)))
+		})
+	}
+}
+
+// createdBy records the user a request was authenticated as, rather than the
+// one the client claims to be, when the daemon requires authentication.
+func createdBy(ctx context.Context, cby *api.CreatedBy) {
+	cby.Authenticated = false
+	if tok := auth.TokenFromContext(ctx); tok != nil && tok.Name != "" {
+		cby.User = tok.Name
+		cby.Authenticated = true
+	}
+}
+
+// ownsTask returns whether a task was submitted with a token of the same name
+// as tok. Tasks submitted with unnamed tokens, e.g. the static ones, or
+// without authentication, are owned by no one.
+func ownsTask(tok *auth.Token, tsk *task.Task) bool {
+	return tok.Name != "" && tsk.CreatedBy.Authenticated && tsk.CreatedBy.User == tok.Name
+}
+
+// errNotOwner is returned when a token acts on a task it doesn't own.
+var errNotOwner = errors.New("not the owner of the task")
+
+// checkOwner returns an error unless the token of a request may act on a
+// task, e.g. cancel, re-parameterize or scale it. Admin tokens act on any
+// task, the others only on those submitted with them. Requests carry no
+// token if the daemon doesn't require authentication.
+func checkOwner(ctx context.Context, engine api.Engine, id string) error {
+	tok := auth.TokenFromContext(ctx)
+	if tok == nil || tok.Scope.Allows(auth.ScopeAdmin) {
+		return nil
+	}
+	tsk, err := engine.GetTask(id)
+	if err != nil {
+		return err
+	}
+	if !ownsTask(tok, tsk) {
+		return fmt.Errorf("%w: task %s was not submitted by %s", errNotOwner, tsk.ID, tok.Name)
+	}
+	return nil
+}
@@ -0,0 +1,211 @@
+package daemon
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/testground/testground/pkg/api"
+	"github.com/testground/testground/pkg/auth"
+	"github.com/testground/testground/pkg/client"
+	"github.com/testground/testground/pkg/config"
+	"github.com/testground/testground/pkg/rpc"
+	"github.com/testground/testground/pkg/task"
+)
+
+func TestRouterScopes(t *testing.T) {
+	store, err := auth.NewMemoryStore()
+	require.NoError(t, err)
+	defer store.Close()
+
+	_, readOnly, err := store.Create("bob", auth.ScopeReadOnly, 0)
+	require.NoError(t, err)
+	_, submitOnly, err := store.Create("carol", auth.ScopeSubmitOnly, 0)
+	require.NoError(t, err)
+
+	d := &Daemon{tokens: store}
+	srv := httptest.NewServer(d.newRouter(&config.EnvConfig{}, newTestEngine(t), newAuthenticator([]string{"secret"}, store, nil)))
+	defer srv.Close()
+
+	do := func(method, path, tok string) int {
+		req, err := http.NewRequest(method, srv.URL+path, strings.NewReader("{}"))
+		require.NoError(t, err)
+		if tok != "" {
+			req.Header.Set("Authorization", "Bearer "+tok)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	require.Equal(t, http.StatusForbidden, do("POST", "/tasks", ""))
+	require.Equal(t, http.StatusForbidden, do("POST", "/tasks", "unknown"))
+	require.NotEqual(t, http.StatusForbidden, do("POST", "/tasks", readOnly))
+
+	// Read-only tokens can't submit runs.
+	require.Equal(t, http.StatusForbidden, do("POST", "/run", readOnly))
+	require.NotEqual(t, http.StatusForbidden, do("POST", "/run", submitOnly))
+
+	// Submit-only tokens can't call the admin endpoints, e.g. to issue
+	// themselves broader tokens.
+	for _, path := range []string{"/drain", "/exec", "/token/create", "/token/list"} {
+		require.Equal(t, http.StatusForbidden, do("POST", path, submitOnly), path)
+	}
+	require.Equal(t, http.StatusForbidden, do("GET", "/kill?task_id=c3ftkqjpc98qra498sg0", submitOnly))
+	require.Equal(t, http.StatusOK, do("POST", "/token/list", "secret"))
+}
+
+func TestTokensRequireAuth(t *testing.T) {
+	store, err := auth.NewMemoryStore()
+	require.NoError(t, err)
+	defer store.Close()
+
+	d := &Daemon{tokens: store}
+	srv := httptest.NewServer(d.newRouter(&config.EnvConfig{}, newTestEngine(t), nil))
+	defer srv.Close()
+
+	resp, err := http.Post(srv.URL+"/token/create", "application/json", strings.NewReader(`{"name":"mallory","scope":"admin"}`))
+	require.NoError(t, err)
+	_, err = client.ParseTokenCreateResponse(resp.Body, ioutil.Discard)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "requires authentication")
+
+	// No token was issued.
+	tokens, err := store.List()
+	require.NoError(t, err)
+	require.Empty(t, tokens)
+}
+
+func TestTokenCreateNegativeExpiry(t *testing.T) {
+	store, err := auth.NewMemoryStore()
+	require.NoError(t, err)
+	defer store.Close()
+
+	d := &Daemon{tokens: store}
+	srv := httptest.NewServer(d.newRouter(&config.EnvConfig{}, newTestEngine(t), newAuthenticator([]string{"secret"}, store, nil)))
+	defer srv.Close()
+
+	req, err := http.NewRequest("POST", srv.URL+"/token/create", strings.NewReader(`{"name":"ci","scope":"read-only","expires_secs":-60}`))
+	require.NoError(t, err)
+	req.Header.Set("Authorization", "Bearer secret")
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	// No token was issued.
+	tokens, err := store.List()
+	require.NoError(t, err)
+	require.Empty(t, tokens)
+}
+
+func TestOwnsTask(t *testing.T) {
+	carol := &auth.Token{Name: "carol", Scope: auth.ScopeSubmitOnly}
+
+	// The client can't claim the tasks it submits were authenticated.
+	cby := api.CreatedBy{User: "root", Authenticated: true}
+	createdBy(context.Background(), &cby)
+	require.False(t, cby.Authenticated)
+
+	ctx := auth.WithToken(context.Background(), carol)
+	cby = api.CreatedBy{User: "root"}
+	createdBy(ctx, &cby)
+	require.Equal(t, api.CreatedBy{User: "carol", Authenticated: true}, cby)
+
+	require.True(t, ownsTask(carol, &task.Task{CreatedBy: task.CreatedBy(cby)}))
+
+	// Tasks of other users, and of the static tokens, which the client
+	// names, are owned by no one.
+	require.False(t, ownsTask(carol, &task.Task{CreatedBy: task.CreatedBy{User: "bob", Authenticated: true}}))
+	require.False(t, ownsTask(carol, &task.Task{CreatedBy: task.CreatedBy{User: "carol"}}))
+	require.False(t, ownsTask(&auth.Token{ID: "static"}, &task.Task{}))
+}
+
+// ownedEngine serves the tasks of alice, and records what's done to them.
+type ownedEngine struct {
+	api.Engine
+
+	killed bool
+	acted  []string
+}
+
+func (*ownedEngine) GetTask(id string) (*task.Task, error) {
+	return &task.Task{ID: id, CreatedBy: task.CreatedBy{User: "alice", Authenticated: true}}, nil
+}
+
+func (e *ownedEngine) Logs(_ context.Context, id string, _ bool, cancel bool, _ io.Writer) (*task.Task, error) {
+	// The client is gone as soon as the logs are written.
+	e.killed = e.killed || cancel
+	return e.GetTask(id)
+}
+
+func (e *ownedEngine) DoPushParam(context.Context, *api.ParamPushRequest, *rpc.OutputWriter) (*api.ParamPushOutput, error) {
+	e.acted = append(e.acted, "param")
+	return &api.ParamPushOutput{}, nil
+}
+
+func (e *ownedEngine) DoInjectNetworkFault(context.Context, *api.NetworkFaultRequest, *rpc.OutputWriter) (*api.NetworkFaultOutput, error) {
+	e.acted = append(e.acted, "network")
+	return &api.NetworkFaultOutput{}, nil
+}
+
+func (e *ownedEngine) DoScale(context.Context, *api.ScaleRequest, *rpc.OutputWriter) (*api.ScaleOutput, error) {
+	e.acted = append(e.acted, "scale")
+	return &api.ScaleOutput{}, nil
+}
+
+func TestTaskOwnerChecks(t *testing.T) {
+	store, err := auth.NewMemoryStore()
+	require.NoError(t, err)
+	defer store.Close()
+
+	_, bob, err := store.Create("bob", auth.ScopeReadOnly, 0)
+	require.NoError(t, err)
+	_, carol, err := store.Create("carol", auth.ScopeSubmitOnly, 0)
+	require.NoError(t, err)
+	_, alice, err := store.Create("alice", auth.ScopeSubmitOnly, 0)
+	require.NoError(t, err)
+
+	e := &ownedEngine{}
+	d := &Daemon{tokens: store}
+	srv := httptest.NewServer(d.newRouter(&config.EnvConfig{}, e, newAuthenticator([]string{"secret"}, store, nil)))
+	defer srv.Close()
+
+	do := func(path, tok, body string) string {
+		req, err := http.NewRequest("POST", srv.URL+path, strings.NewReader(body))
+		require.NoError(t, err)
+		req.Header.Set("Authorization", "Bearer "+tok)
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		b, err := ioutil.ReadAll(resp.Body)
+		require.NoError(t, err)
+		return string(b)
+	}
+
+	// Read-only tokens follow the logs of any task, but the task survives
+	// them disconnecting.
+	logs := `{"task_id": "c3ftkqjpc98qra498sg0", "follow": true, "cancel_with_context": true}`
+	require.Contains(t, do("/logs", bob, logs), "was not submitted by bob")
+	require.False(t, e.killed)
+	require.NotContains(t, do("/logs", bob, `{"task_id": "c3ftkqjpc98qra498sg0", "follow": true}`), "was not submitted")
+	require.False(t, e.killed)
+
+	// Submit-only tokens only act on their own tasks; admins on any.
+	for _, path := range []string{"/param", "/network", "/scale"} {
+		require.Contains(t, do(path, carol, `{"task_id": "c3ftkqjpc98qra498sg0"}`), "was not submitted by carol", path)
+		require.NotContains(t, do(path, alice, `{"task_id": "c3ftkqjpc98qra498sg0"}`), "was not submitted", path)
+		require.NotContains(t, do(path, "secret", `{"task_id": "c3ftkqjpc98qra498sg0"}`), "was not submitted", path)
+	}
+	require.Equal(t, []string{"param", "param", "network", "network", "scale", "scale"}, e.acted)
+
+	require.NotContains(t, do("/logs", alice, logs), "was not submitted")
+	require.True(t, e.killed)
+}
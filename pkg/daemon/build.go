@@ -16,6 +16,7 @@ import (
 	"github.com/testground/testground/pkg/api"
 	"github.com/testground/testground/pkg/logging"
 	"github.com/testground/testground/pkg/rpc"
+	"github.com/testground/testground/pkg/tracing"
 )
 
 func (d *Daemon) buildHandler(engine api.Engine) func(w http.ResponseWriter, r *http.Request) {
@@ -42,11 +43,20 @@ func (d *Daemon) buildHandler(engine api.Engine) func(w http.ResponseWriter, r *
 			return
 		}
 
+		sources, err = fetchPlanSource(r.Context(), engine.EnvConfig(), request.PlanSource, sources, dir)
+		if err != nil {
+			tgw.WriteError("failed to fetch plan source", "err", err)
+			return
+		}
+
 		if sources == nil || sources.PlanDir == "" {
 			tgw.WriteError("bad request", "err", errors.New("plan directory not present"))
 			return
 		}
 
+		request.TraceContext = tracing.Inject(r.Context())
+		createdBy(r.Context(), &request.CreatedBy)
+
 		id, err := engine.QueueBuild(request, sources)
 		if err != nil {
 			tgw.WriteError(fmt.Sprintf("engine build error: %s", err))
@@ -84,6 +94,38 @@ func (d *Daemon) buildPurgeHandler(engine api.Engine) func(w http.ResponseWriter
 	}
 }
 
+func (d *Daemon) buildCollectHandler(engine api.Engine) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		log := logging.S().With("req_id", r.Header.Get("X-Request-ID"))
+
+		log.Debugw("handle request", "command", "build/collect")
+		defer log.Debugw("request handled", "command", "build/collect")
+
+		var req api.BuildCollectRequest
+		err := json.NewDecoder(r.Body).Decode(&req)
+		if err != nil {
+			log.Errorw("build collect json decode", "err", err.Error())
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		tgw := rpc.NewOutputWriter(w, r)
+
+		result := false
+		defer func() {
+			tgw.WriteResult(result)
+		}()
+
+		err = engine.DoCollectBuild(r.Context(), req.TaskID, tgw)
+		if err != nil {
+			log.Warnw("build collect error", "err", err.Error())
+			return
+		}
+
+		result = true
+	}
+}
+
 func consumeRunBuildRequest(r *http.Request, body interface{}, dir string) (*api.UnpackedSources, error) {
 	var (
 		p   *multipart.Part
@@ -131,38 +173,10 @@ Outer:
 				unpacked.BaseDir = dir
 			}
 
-			var (
-				filename = p.FileName() // can be sdk.zip or extra.zip
-				kind     = strings.TrimSuffix(filename, ".zip")
-			)
-
-			// Read the archive.
-			targetzip, err := os.Create(filepath.Join(dir, filename))
-			if err != nil {
-				return nil, fmt.Errorf("failed to create file for %s: %w", kind, err)
-			}
-			if _, err = io.Copy(targetzip, p); err != nil {
-				return nil, fmt.Errorf("unexpected error when copying %s: %w", kind, err)
-			}
-
-			// Inflate the archive.
-			destdir := filepath.Join(dir, kind)
-			if err := os.Mkdir(destdir, 0755); err != nil {
-				return nil, fmt.Errorf("failed to create directory for sdk: %w", err)
-			}
-			logging.S().Infof("extracting %s to %s", filename, destdir)
-			if err := archiver.NewZip().Unarchive(targetzip.Name(), destdir); err != nil {
-				return nil, fmt.Errorf("failed to decompress sdk: %w", err)
-			}
-
-			// Set the right directory.
-			switch kind {
-			case "sdk":
-				unpacked.SDKDir = destdir
-			case "extra":
-				unpacked.ExtraDir = destdir
-			case "plan":
-				unpacked.PlanDir = destdir
+			// can be plan.zip, sdk.zip or extra.zip
+			kind := strings.TrimSuffix(p.FileName(), ".zip")
+			if err := unpackSource(unpacked, kind, p); err != nil {
+				return nil, err
 			}
 		default:
 			// an error occurred.
@@ -172,3 +186,40 @@ Outer:
 
 	return unpacked, nil
 }
+
+// unpackSource inflates the zip archive of the plan, sdk or extra sources
+// under the base directory of unpacked, and sets the matching directory.
+func unpackSource(unpacked *api.UnpackedSources, kind string, r io.Reader) error {
+	filename := kind + ".zip"
+
+	// Read the archive.
+	targetzip, err := os.Create(filepath.Join(unpacked.BaseDir, filename))
+	if err != nil {
+		return fmt.Errorf("failed to create file for %s: %w", kind, err)
+	}
+	defer targetzip.Close()
+	if _, err = io.Copy(targetzip, r); err != nil {
+		return fmt.Errorf("unexpected error when copying %s: %w", kind, err)
+	}
+
+	// Inflate the archive.
+	destdir := filepath.Join(unpacked.BaseDir, kind)
+	if err := os.Mkdir(destdir, 0755); err != nil {
+		return fmt.Errorf("failed to create directory for %s: %w", kind, err)
+	}
+	logging.S().Infof("extracting %s to %s", filename, destdir)
+	if err := archiver.NewZip().Unarchive(targetzip.Name(), destdir); err != nil {
+		return fmt.Errorf("failed to decompress %s: %w", kind, err)
+	}
+
+	// Set the right directory.
+	switch kind {
+	case "sdk":
+		unpacked.SDKDir = destdir
+	case "extra":
+		unpacked.ExtraDir = destdir
+	case "plan":
+		unpacked.PlanDir = destdir
+	}
+	return nil
+}
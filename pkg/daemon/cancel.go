@@ -0,0 +1,86 @@
+package daemon
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/testground/testground/pkg/api"
+	"github.com/testground/testground/pkg/auth"
+	"github.com/testground/testground/pkg/logging"
+	"github.com/testground/testground/pkg/rpc"
+)
+
+func (d *Daemon) cancelHandler(engine api.Engine) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		log := logging.S().With("req_id", r.Header.Get("X-Request-ID"))
+
+		log.Debugw("handle request", "command", "cancel")
+		defer log.Debugw("request handled", "command", "cancel")
+
+		tgw := rpc.NewOutputWriter(w, r)
+
+		var req api.CancelRequest
+		err := json.NewDecoder(r.Body).Decode(&req)
+		if err != nil {
+			tgw.WriteError("cancel json decode", "err", err.Error())
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		tsk, err := engine.GetTask(req.TaskID)
+		if err != nil {
+			tgw.WriteError("cancel error", "err", err.Error())
+			return
+		}
+
+		// Tokens that can't do everything can only cancel their own tasks.
+		var by string
+		if tok := auth.TokenFromContext(r.Context()); tok != nil {
+			by = tok.Name
+			if !tok.Scope.Allows(auth.ScopeAdmin) && !ownsTask(tok, tsk) {
+				tgw.WriteError("cancel error", "err", fmt.Sprintf("task %s was not submitted by %s", tsk.ID, tok.Name))
+				return
+			}
+		}
+
+		if err := engine.Cancel(tsk.ID, by); err != nil {
+			tgw.WriteError("cancel error", "err", err.Error())
+			return
+		}
+
+		tgw.WriteResult("Done")
+	}
+}
+
+func (d *Daemon) drainHandler(engine api.Engine) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		log := logging.S().With("req_id", r.Header.Get("X-Request-ID"))
+
+		log.Debugw("handle request", "command", "drain")
+		defer log.Debugw("request handled", "command", "drain")
+
+		tgw := rpc.NewOutputWriter(w, r)
+
+		var req api.DrainRequest
+		err := json.NewDecoder(r.Body).Decode(&req)
+		if err != nil {
+			tgw.WriteError("drain json decode", "err", err.Error())
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		if req.Resume {
+			engine.Resume()
+			tgw.WriteResult("Done")
+			return
+		}
+
+		if err := engine.DoDrain(r.Context(), tgw); err != nil {
+			tgw.WriteError("drain error", "err", err.Error())
+			return
+		}
+
+		tgw.WriteResult("Done")
+	}
+}
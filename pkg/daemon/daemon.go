@@ -2,26 +2,38 @@ package daemon
 
 import (
 	"context"
+	"crypto/tls"
 	"fmt"
 	"net"
 	"net/http"
-	"strings"
+	"path/filepath"
 	"time"
 
+	"github.com/testground/testground/pkg/api"
+	"github.com/testground/testground/pkg/auth"
 	"github.com/testground/testground/pkg/config"
 	"github.com/testground/testground/pkg/engine"
 	"github.com/testground/testground/pkg/logging"
 	"github.com/testground/testground/pkg/metrics"
+	"github.com/testground/testground/pkg/tracing"
 
 	"github.com/gorilla/mux"
 	"github.com/pborman/uuid"
+	"google.golang.org/grpc"
 )
 
 type Daemon struct {
+	engine api.Engine
 	server *http.Server
 	l      net.Listener
 	mv     *metrics.Viewer
+	tokens *auth.Store
+	tls    bool
 	doneCh chan struct{}
+
+	// grpc serves the gRPC API on grpcl, when enabled.
+	grpc  *grpc.Server
+	grpcl net.Listener
 }
 
 // New creates a new Daemon and attaches the following handlers:
@@ -29,8 +41,25 @@ type Daemon struct {
 // * GET /list: sends a `list` request to the daemon. list all test plans and test cases.
 // * GET /describe: sends a `describe` request to the daemon. describes a test plan or test case.
 // * POST /build: sends a `build` request to the daemon. builds a test plan.
+// * POST /build/collect: streams a tgz archive of the files the builds of a build task produced.
 // * POST /run: sends a `run` request to the daemon. (builds and) runs test case with name `<testplan>/<testcase>`.
+// * POST /param: pushes a runtime parameter update to the instances of a running task.
+// * POST /network: injects a network fault in a running task.
+// * POST /scale: adds instances to, or removes instances from, a group of a running task.
+// * POST /explain: explains why a task is queued, and the scheduling and placement decisions taken for it.
+// * POST /cancel: cancels a queued or running task, tearing down the resources of its run.
+// * POST /drain: stops accepting new tasks and waits for the running ones to complete, or resumes.
+// * POST /reload: reloads the env config, e.g. to enable or disable builders and runners, without restarting.
+// * POST /gc: applies the retention policies to run outputs, cached sources and build artifacts.
+// * POST /token/{create,list,revoke}: manages the scoped API tokens of the daemon, only when it authenticates requests.
+// * GET /events: streams the lifecycle events of tasks, as server-sent events or over a WebSocket.
+// * GET /metrics: Prometheus metrics of the daemon (task queue, build and run durations, etc.).
+// * GET /ui: the web UI, only served when enabled in the daemon config.
+// * GET /registry: the instances of a live run, only served when enabled in the daemon config.
 // A type-safe client for this server can be found in the `pkg/client` package.
+//
+// The build, run, tasks, status, logs and outputs operations are also served
+// over gRPC when `grpc_listen` is set; see `pkg/daemon/daemonpb`.
 func New(cfg *config.EnvConfig) (srv *Daemon, err error) {
 	srv = new(Daemon)
 
@@ -39,36 +68,87 @@ func New(cfg *config.EnvConfig) (srv *Daemon, err error) {
 		return nil, err
 	}
 
+	srv.engine = engine
+
 	mv, err := metrics.NewViewer(cfg)
 	if err != nil {
 		return nil, err
 	}
 
-	r := mux.NewRouter().StrictSlash(true)
+	// Tokens issued by the daemon are kept alongside the tasks.
+	if cfg.Daemon.Scheduler.TaskRepoType == "disk" {
+		srv.tokens, err = auth.NewStore(filepath.Join(cfg.Dirs().Home(), "tokens.db"))
+	} else {
+		srv.tokens, err = auth.NewMemoryStore()
+	}
+	if err != nil {
+		return nil, err
+	}
 
-	if len(cfg.Daemon.Tokens) > 0 {
-		tokens := map[string]struct{}{}
-		for _, t := range cfg.Daemon.Tokens {
-			tokens[strings.TrimSpace(t)] = struct{}{}
+	var oidc *auth.OIDCVerifier
+	if cfg.Daemon.OIDC.Issuer != "" {
+		if cfg.Daemon.Offline.Enabled {
+			return nil, fmt.Errorf("%w: the keys of the OIDC provider %s can't be fetched", config.ErrOffline, cfg.Daemon.OIDC.Issuer)
+		}
+		if oidc, err = auth.NewOIDCVerifier(cfg.Daemon.OIDC); err != nil {
+			return nil, err
 		}
+	}
 
-		r.Use(func(next http.Handler) http.Handler {
-			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-				splitToken := strings.Split(r.Header.Get("Authorization"), "Bearer ")
-				if len(splitToken) == 2 {
-					requestToken := strings.TrimSpace(splitToken[1])
+	var authn *authenticator
+	if len(cfg.Daemon.Tokens) > 0 || oidc != nil {
+		authn = newAuthenticator(cfg.Daemon.Tokens, srv.tokens, oidc)
+	}
 
-					if _, ok := tokens[requestToken]; ok {
-						next.ServeHTTP(w, r)
-						return
-					}
-				}
+	r := srv.newRouter(cfg, engine, authn)
 
-				w.WriteHeader(403)
-			})
-		})
+	srv.doneCh = make(chan struct{})
+	srv.server = &http.Server{
+		Handler:      r,
+		WriteTimeout: 7200 * time.Second,
+		ReadTimeout:  7200 * time.Second,
+	}
+
+	srv.l, err = net.Listen("tcp", cfg.Daemon.Listen)
+	if err != nil {
+		return nil, err
 	}
 
+	tlscfg, err := serverTLSConfig(cfg.Daemon.TLS)
+	if err != nil {
+		_ = srv.l.Close()
+		return nil, err
+	}
+	if tlscfg != nil {
+		srv.l = tls.NewListener(srv.l, tlscfg)
+		srv.tls = true
+	}
+
+	if cfg.Daemon.GRPCListen != "" {
+		srv.grpcl, err = net.Listen("tcp", cfg.Daemon.GRPCListen)
+		if err != nil {
+			_ = srv.l.Close()
+			return nil, err
+		}
+		srv.grpc = newGRPCServer(engine, authn, tlscfg)
+	}
+
+	srv.mv = mv
+
+	return srv, nil
+}
+
+// newRouter returns the router of the HTTP API of the daemon. Requests must be
+// authenticated if authn is not nil.
+func (d *Daemon) newRouter(cfg *config.EnvConfig, engine api.Engine, authn *authenticator) *mux.Router {
+	r := mux.NewRouter().StrictSlash(true)
+
+	if authn != nil {
+		r.Use(authMiddleware(authn))
+	}
+
+	r.Use(tracing.Middleware)
+
 	// Set a unique request ID.
 	r.Use(func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -80,41 +160,60 @@ func New(cfg *config.EnvConfig) (srv *Daemon, err error) {
 	staticDir := "/static/"
 	r.PathPrefix(staticDir).Handler(http.StripPrefix(staticDir, http.FileServer(http.Dir("."+staticDir))))
 
-	r.HandleFunc("/data", srv.dataHandler(engine)).Methods("GET")
-	r.HandleFunc("/dashboard", srv.dashboardHandler(engine)).Methods("GET")
-	r.HandleFunc("/kill", srv.killTaskHandler(engine)).Methods("GET")
-	r.HandleFunc("/delete", srv.deleteHandler(engine)).Methods("GET") // temporary endpoint until we build a proper ACL/admin endpoints within the daemon
-	r.HandleFunc("/tasks", srv.listTasksHandler(engine)).Methods("GET")
-	r.HandleFunc("/logs", srv.getLogsHandler(engine)).Methods("GET")
-	r.HandleFunc("/outputs", srv.getOutputsHandler(engine)).Methods("GET")
-	r.HandleFunc("/journal", srv.getJournalHandler(engine)).Methods("GET")
-	r.HandleFunc("/", srv.redirect()).Methods("GET")
-
-	r.HandleFunc("/build", srv.buildHandler(engine)).Methods("POST")
-	r.HandleFunc("/build/purge", srv.buildPurgeHandler(engine)).Methods("POST")
-	r.HandleFunc("/run", srv.runHandler(engine)).Methods("POST")
-	r.HandleFunc("/outputs", srv.outputsHandler(engine)).Methods("POST")
-	r.HandleFunc("/terminate", srv.terminateHandler(engine)).Methods("POST")
-	r.HandleFunc("/healthcheck", srv.healthcheckHandler(engine)).Methods("POST")
-	r.HandleFunc("/tasks", srv.tasksHandler(engine)).Methods("POST")
-	r.HandleFunc("/status", srv.statusHandler(engine)).Methods("POST")
-	r.HandleFunc("/logs", srv.logsHandler(engine)).Methods("POST")
+	r.HandleFunc("/data", d.dataHandler(engine)).Methods("GET")
+	r.HandleFunc("/dashboard", d.dashboardHandler(engine)).Methods("GET")
+	r.HandleFunc("/kill", d.killTaskHandler(engine)).Methods("GET")
+	r.HandleFunc("/delete", d.deleteHandler(engine)).Methods("GET") // temporary endpoint until we build a proper ACL/admin endpoints within the daemon
+	r.HandleFunc("/tasks", d.listTasksHandler(engine)).Methods("GET")
+	r.HandleFunc("/logs", d.getLogsHandler(engine)).Methods("GET")
+	r.HandleFunc("/outputs", d.getOutputsHandler(engine)).Methods("GET")
+	r.HandleFunc("/journal", d.getJournalHandler(engine)).Methods("GET")
+	r.HandleFunc("/version", d.versionHandler()).Methods("GET")
+	r.HandleFunc("/events", d.eventsHandler(engine)).Methods("GET")
+	r.HandleFunc("/", d.redirect()).Methods("GET")
+	r.Handle("/metrics", metrics.Handler()).Methods("GET")
 
-	srv.doneCh = make(chan struct{})
-	srv.server = &http.Server{
-		Handler:      r,
-		WriteTimeout: 7200 * time.Second,
-		ReadTimeout:  7200 * time.Second,
+	if cfg.Daemon.UI {
+		r.HandleFunc("/ui", d.uiTasksHandler(engine)).Methods("GET")
+		r.HandleFunc("/ui/task", d.uiTaskHandler(engine)).Methods("GET")
+		r.HandleFunc("/ui/logs", d.uiLogsHandler(engine)).Methods("GET")
 	}
 
-	srv.l, err = net.Listen("tcp", cfg.Daemon.Listen)
-	if err != nil {
-		return nil, err
+	if cfg.Daemon.Registry {
+		r.HandleFunc("/registry", d.registryHandler(engine)).Methods("GET")
 	}
 
-	srv.mv = mv
+	r.HandleFunc("/build", d.buildHandler(engine)).Methods("POST")
+	r.HandleFunc("/build/purge", d.buildPurgeHandler(engine)).Methods("POST")
+	r.HandleFunc("/build/collect", d.buildCollectHandler(engine)).Methods("POST")
+	r.HandleFunc("/run", d.runHandler(engine)).Methods("POST")
+	r.HandleFunc("/outputs", d.outputsHandler(engine)).Methods("POST")
+	r.HandleFunc("/terminate", d.terminateHandler(engine)).Methods("POST")
+	r.HandleFunc("/healthcheck", d.healthcheckHandler(engine)).Methods("POST")
+	r.HandleFunc("/param", d.paramHandler(engine)).Methods("POST")
+	r.HandleFunc("/network", d.networkHandler(engine)).Methods("POST")
+	r.HandleFunc("/scale", d.scaleHandler(engine)).Methods("POST")
+	r.HandleFunc("/exec", d.execHandler(engine)).Methods("POST")
+	r.HandleFunc("/gc", d.gcHandler(engine)).Methods("POST")
+	r.HandleFunc("/explain", d.explainHandler(engine)).Methods("POST")
+	r.HandleFunc("/cancel", d.cancelHandler(engine)).Methods("POST")
+	r.HandleFunc("/drain", d.drainHandler(engine)).Methods("POST")
+	r.HandleFunc("/reload", d.reloadHandler(engine)).Methods("POST")
+	r.HandleFunc("/tasks", d.tasksHandler(engine)).Methods("POST")
+	r.HandleFunc("/status", d.statusHandler(engine)).Methods("POST")
+	r.HandleFunc("/logs", d.logsHandler(engine)).Methods("POST")
 
-	return srv, nil
+	// Tokens are only managed when the daemon checks them; otherwise anyone
+	// could issue tokens that grant nothing.
+	if authn != nil {
+		r.HandleFunc("/token/create", d.tokenCreateHandler()).Methods("POST")
+		r.HandleFunc("/token/list", d.tokenListHandler()).Methods("POST")
+		r.HandleFunc("/token/revoke", d.tokenRevokeHandler()).Methods("POST")
+	} else {
+		r.PathPrefix("/token/").HandlerFunc(d.tokenDisabledHandler()).Methods("POST")
+	}
+
+	return r
 }
 
 // Serve starts the server and blocks until the server is closed, either
@@ -127,7 +226,16 @@ func (d *Daemon) Serve() error {
 	default:
 	}
 
-	logging.S().Infow("daemon listening", "addr", d.Addr())
+	if d.grpc != nil {
+		go func() {
+			logging.S().Infow("daemon serving gRPC", "addr", d.grpcl.Addr().String(), "tls", d.tls)
+			if err := d.grpc.Serve(d.grpcl); err != nil {
+				logging.S().Errorw("gRPC server stopped", "err", err)
+			}
+		}()
+	}
+
+	logging.S().Infow("daemon listening", "addr", d.Addr(), "tls", d.tls)
 	return d.server.Serve(d.l)
 }
 
@@ -141,5 +249,21 @@ func (d *Daemon) Port() int {
 
 func (d *Daemon) Shutdown(ctx context.Context) error {
 	defer close(d.doneCh)
+	defer d.tokens.Close()
+	if d.grpc != nil {
+		// Calls following logs can last as long as their task does.
+		stopped := make(chan struct{})
+		go func() {
+			d.grpc.GracefulStop()
+			close(stopped)
+		}()
+		defer func() {
+			select {
+			case <-stopped:
+			case <-ctx.Done():
+				d.grpc.Stop()
+			}
+		}()
+	}
 	return d.server.Shutdown(ctx)
 }
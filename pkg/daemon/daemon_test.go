@@ -0,0 +1,63 @@
+package daemon
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/testground/testground/pkg/api"
+	"github.com/testground/testground/pkg/config"
+	"github.com/testground/testground/pkg/engine"
+	"github.com/testground/testground/pkg/task"
+)
+
+// newTestEngine returns an engine without builders nor runners, with its
+// home in a temporary directory.
+func newTestEngine(t *testing.T) *engine.Engine {
+	home, err := ioutil.TempDir("", "testground")
+	require.NoError(t, err)
+	t.Cleanup(func() { os.RemoveAll(home) })
+
+	prev, had := os.LookupEnv(config.EnvTestgroundHomeDir)
+	require.NoError(t, os.Setenv(config.EnvTestgroundHomeDir, home))
+	t.Cleanup(func() {
+		if had {
+			os.Setenv(config.EnvTestgroundHomeDir, prev)
+		} else {
+			os.Unsetenv(config.EnvTestgroundHomeDir)
+		}
+	})
+
+	envcfg := &config.EnvConfig{}
+	require.NoError(t, envcfg.Load())
+	envcfg.Daemon.Scheduler = config.SchedulerConfig{TaskRepoType: "memory", QueueSize: 10, Workers: 1}
+
+	e, err := engine.NewEngine(&engine.EngineConfig{EnvConfig: envcfg})
+	require.NoError(t, err)
+	return e
+}
+
+// failedBuild queues a build that fails, as the plan supports no builders,
+// and waits for it to be done.
+func failedBuild(t *testing.T, e *engine.Engine) *task.Task {
+	dir := t.TempDir()
+	require.NoError(t, ioutil.WriteFile(dir+"/manifest.toml", []byte(`name = "placebo"`), 0644))
+
+	id, err := e.QueueBuild(&api.BuildRequest{
+		Composition: api.Composition{Global: api.Global{Plan: "placebo", Builder: "docker:fake"}},
+		CreatedBy:   api.CreatedBy{User: "alice"},
+	}, &api.UnpackedSources{BaseDir: dir, PlanDir: dir})
+	require.NoError(t, err)
+
+	var tsk *task.Task
+	require.Eventually(t, func() bool {
+		tsk, err = e.GetTask(id)
+		require.NoError(t, err)
+		st := tsk.State().State
+		return st != task.StateScheduled && st != task.StateProcessing
+	}, 10*time.Second, 50*time.Millisecond)
+	return tsk
+}
@@ -0,0 +1,5 @@
+// Package daemonpb contains the protobuf definition of the gRPC API of the
+// daemon, and the code generated from it.
+package daemonpb
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative daemon.proto
@@ -0,0 +1,121 @@
+package daemon
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/testground/testground/pkg/api"
+	"github.com/testground/testground/pkg/logging"
+
+	"nhooyr.io/websocket"
+	"nhooyr.io/websocket/wsjson"
+)
+
+// eventsKeepAlive is how often an idle event stream sends a comment, for
+// proxies not to close it.
+const eventsKeepAlive = 15 * time.Second
+
+// eventsHandler streams the lifecycle events of tasks, as server-sent events,
+// or as JSON messages over a WebSocket if the request upgrades to one. The
+// task_id, plan and type (comma-separated) query parameters filter the
+// events.
+func (d *Daemon) eventsHandler(engine api.Engine) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		log := logging.S().With("req_id", r.Header.Get("X-Request-ID"))
+
+		log.Debugw("handle request", "command", "events")
+		defer log.Debugw("request handled", "command", "events")
+
+		q := r.URL.Query()
+		types, err := api.ParseEventTypes(q.Get("type"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		filter := api.EventFilter{TaskID: q.Get("task_id"), Plan: q.Get("plan"), Types: types}
+
+		if strings.EqualFold(r.Header.Get("Upgrade"), "websocket") {
+			streamEventsWebSocket(w, r, engine, filter)
+			return
+		}
+		streamEventsSSE(w, r, engine, filter)
+	}
+}
+
+func streamEventsSSE(w http.ResponseWriter, r *http.Request, engine api.Engine, filter api.EventFilter) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	events, unsubscribe := engine.SubscribeEvents(filter)
+	defer unsubscribe()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	keepalive := time.NewTicker(eventsKeepAlive)
+	defer keepalive.Stop()
+
+	for {
+		select {
+		case ev, ok := <-events:
+			if !ok {
+				// The subscriber fell behind; clients reconnect.
+				return
+			}
+			b, err := json.Marshal(ev)
+			if err != nil {
+				return
+			}
+			if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", ev.Type, b); err != nil {
+				return
+			}
+		case <-keepalive.C:
+			if _, err := fmt.Fprint(w, ": keepalive\n\n"); err != nil {
+				return
+			}
+		case <-r.Context().Done():
+			return
+		}
+		flusher.Flush()
+	}
+}
+
+func streamEventsWebSocket(w http.ResponseWriter, r *http.Request, engine api.Engine, filter api.EventFilter) {
+	// Subscribe before accepting, not to miss the events that follow.
+	events, unsubscribe := engine.SubscribeEvents(filter)
+	defer unsubscribe()
+
+	c, err := websocket.Accept(w, r, nil)
+	if err != nil {
+		return
+	}
+
+	// Messages from the client are discarded; the context is done once it
+	// closes the connection.
+	ctx := c.CloseRead(r.Context())
+
+	for {
+		select {
+		case ev, ok := <-events:
+			if !ok {
+				_ = c.Close(websocket.StatusTryAgainLater, "fell behind the events")
+				return
+			}
+			if err := wsjson.Write(ctx, c, ev); err != nil {
+				_ = c.Close(websocket.StatusInternalError, "")
+				return
+			}
+		case <-ctx.Done():
+			_ = c.Close(websocket.StatusNormalClosure, "")
+			return
+		}
+	}
+}
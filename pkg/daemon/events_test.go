@@ -0,0 +1,72 @@
+package daemon
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"nhooyr.io/websocket"
+	"nhooyr.io/websocket/wsjson"
+
+	"github.com/testground/testground/pkg/api"
+	"github.com/testground/testground/pkg/config"
+	"github.com/testground/testground/pkg/task"
+)
+
+func TestEvents(t *testing.T) {
+	e := newTestEngine(t)
+	srv := httptest.NewServer((&Daemon{}).newRouter(&config.EnvConfig{}, e, nil))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, "GET", srv.URL+"/events?plan=placebo", nil)
+	require.NoError(t, err)
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+
+	ws, _, err := websocket.Dial(ctx, strings.Replace(srv.URL, "http", "ws", 1)+"/events?type=task_completed", nil)
+	require.NoError(t, err)
+	defer ws.Close(websocket.StatusNormalClosure, "")
+
+	tsk := failedBuild(t, e)
+
+	// The stream carries the events of the build, from queued to completed.
+	var types []api.EventType
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, "data: ") {
+			continue
+		}
+		var ev api.Event
+		require.NoError(t, json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &ev))
+		require.Equal(t, tsk.ID, ev.TaskID)
+		require.Equal(t, task.TypeBuild, ev.TaskType)
+		types = append(types, ev.Type)
+		if ev.Type == api.EventTaskCompleted {
+			require.Equal(t, task.OutcomeCanceled, ev.Outcome)
+			break
+		}
+	}
+	require.Equal(t, []api.EventType{api.EventTaskQueued, api.EventTaskCompleted}, types)
+
+	var ev api.Event
+	require.NoError(t, wsjson.Read(ctx, ws, &ev))
+	require.Equal(t, api.EventTaskCompleted, ev.Type)
+	require.Equal(t, tsk.ID, ev.TaskID)
+
+	resp, err = http.Get(srv.URL + "/events?type=unknown")
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
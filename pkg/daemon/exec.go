@@ -0,0 +1,118 @@
+package daemon
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/testground/testground/pkg/api"
+	"github.com/testground/testground/pkg/logging"
+	"github.com/testground/testground/pkg/rpc"
+)
+
+// execHandler runs a command in an instance of a running task. The client
+// upgrades its connection to rpc.StreamProtocol; the daemon then writes the
+// output of the command as binary chunks, its error output as progress
+// chunks, and the exit code as the result chunk, while the client writes the
+// input of the command as binary chunks, ending it with a result chunk.
+func (d *Daemon) execHandler(engine api.Engine) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		log := logging.S().With("req_id", r.Header.Get("X-Request-ID"))
+
+		log.Debugw("handle request", "command", "exec")
+		defer log.Debugw("request handled", "command", "exec")
+
+		var req api.ExecRequest
+		err := json.NewDecoder(r.Body).Decode(&req)
+		if err != nil {
+			tgw := rpc.NewOutputWriter(w, r)
+			tgw.WriteError("exec json decode", "err", err.Error())
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		if r.Header.Get("Upgrade") != rpc.StreamProtocol {
+			tgw := rpc.NewOutputWriter(w, r)
+			tgw.WriteError("exec error", "err", fmt.Sprintf("exec requires upgrading the connection to %s", rpc.StreamProtocol))
+			return
+		}
+
+		hj, ok := w.(http.Hijacker)
+		if !ok {
+			tgw := rpc.NewOutputWriter(w, r)
+			tgw.WriteError("exec error", "err", "the connection can't be upgraded")
+			return
+		}
+		conn, buf, err := hj.Hijack()
+		if err != nil {
+			log.Errorw("failed to upgrade the connection", "err", err)
+			return
+		}
+		defer conn.Close()
+
+		// The timeouts of the server don't apply to upgraded connections.
+		_ = conn.SetDeadline(time.Time{})
+		_, _ = fmt.Fprintf(conn, "HTTP/1.1 101 Switching Protocols\r\nConnection: Upgrade\r\nUpgrade: %s\r\n\r\n", rpc.StreamProtocol)
+
+		ctx, cancel := context.WithCancel(r.Context())
+		defer cancel()
+
+		// Stop the command if the client goes away.
+		stdin, inw := io.Pipe()
+		go func() {
+			defer cancel()
+			_, err := io.Copy(execInputWriter(inw), buf)
+			if err == nil {
+				err = io.ErrUnexpectedEOF
+			}
+			_ = inw.CloseWithError(err)
+		}()
+
+		tgw := rpc.NewFileOutputWriter(conn)
+		stdout, stderr := chunkedWriter(tgw.WriteBinary), chunkedWriter(tgw.WriteProgress)
+		out, err := engine.DoExec(ctx, &req, stdin, stdout, stderr)
+		if err != nil {
+			tgw.WriteError("exec error", "err", err.Error())
+			return
+		}
+
+		tgw.WriteResult(out)
+	}
+}
+
+// execInputWriter decodes the chunks of the input of a command into w, and
+// closes w once the input ends.
+func execInputWriter(w *io.PipeWriter) io.Writer {
+	return rpc.NewChunkWriter(func(c *rpc.Chunk) error {
+		switch c.Type {
+		case rpc.ChunkTypeBinary:
+			s, _ := c.Payload.(string)
+			b, err := base64.StdEncoding.DecodeString(s)
+			if err != nil {
+				return err
+			}
+			_, err = w.Write(b)
+			return err
+		case rpc.ChunkTypeResult:
+			return w.Close()
+		default:
+			return fmt.Errorf("unexpected chunk of type %c in the input", c.Type)
+		}
+	})
+}
+
+// chunkedWriter adapts a function writing chunks to io.Writer. The functions
+// return the size of the chunks they write, which io.Copy and the like
+// mistake for short writes.
+type chunkedWriter func([]byte) (int, error)
+
+func (f chunkedWriter) Write(p []byte) (int, error) {
+	if _, err := f(p); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
@@ -0,0 +1,64 @@
+package daemon
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/testground/testground/pkg/api"
+	"github.com/testground/testground/pkg/client"
+	"github.com/testground/testground/pkg/config"
+)
+
+// execEngine runs commands by echoing their input to their output, and the
+// command to their error output.
+type execEngine struct{ api.Engine }
+
+func (execEngine) DoExec(_ context.Context, req *api.ExecRequest, stdin io.Reader, stdout, stderr io.Writer) (*api.ExecOutput, error) {
+	in, err := ioutil.ReadAll(stdin)
+	if err != nil {
+		return nil, err
+	}
+	fmt.Fprintf(stderr, "running %s\n", strings.Join(req.Cmd, " "))
+	_, _ = stdout.Write(bytes.ToUpper(in))
+	return &api.ExecOutput{Instance: req.Group, ExitCode: 2}, nil
+}
+
+func TestExec(t *testing.T) {
+	e := newTestEngine(t)
+	d := &Daemon{}
+
+	cfg := &config.EnvConfig{}
+	srv := httptest.NewServer(d.newRouter(cfg, execEngine{e}, nil))
+	defer srv.Close()
+
+	cfg.Client.Endpoint = srv.URL
+	cl := client.New(cfg)
+	defer cl.Close()
+
+	var stdout, stderr bytes.Buffer
+	req := &api.ExecRequest{TaskID: "c3ftkqjpc98qra498sg0", Group: "nodes", Cmd: []string{"tr", "a-z", "A-Z"}}
+	out, err := cl.Exec(context.Background(), req, strings.NewReader(strings.Repeat("hello ", 10000)), &stdout, &stderr)
+	require.NoError(t, err)
+	require.Equal(t, &api.ExecOutput{Instance: "nodes", ExitCode: 2}, out)
+	require.Equal(t, strings.Repeat("HELLO ", 10000), stdout.String())
+	require.Equal(t, "running tr a-z A-Z\n", stderr.String())
+
+	// The errors of the engine are reported.
+	srv = httptest.NewServer(d.newRouter(cfg, e, nil))
+	defer srv.Close()
+	cfg.Client.Endpoint = srv.URL
+	cl = client.New(cfg)
+	defer cl.Close()
+
+	_, err = cl.Exec(context.Background(), req, nil, &stdout, &stderr)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "could not get task")
+}
@@ -0,0 +1,395 @@
+package daemon
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+
+	"github.com/pborman/uuid"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/testground/testground/pkg/api"
+	"github.com/testground/testground/pkg/auth"
+	"github.com/testground/testground/pkg/daemon/daemonpb"
+	"github.com/testground/testground/pkg/data"
+	"github.com/testground/testground/pkg/engine"
+	"github.com/testground/testground/pkg/logging"
+	"github.com/testground/testground/pkg/rpc"
+	"github.com/testground/testground/pkg/task"
+	"github.com/testground/testground/pkg/tracing"
+)
+
+// methodScopes are the scopes required by the methods of the gRPC API, like
+// endpointScopes for the HTTP one.
+var methodScopes = map[string]auth.Scope{
+	"Build":          auth.ScopeSubmitOnly,
+	"Run":            auth.ScopeSubmitOnly,
+	"Tasks":          auth.ScopeReadOnly,
+	"Status":         auth.ScopeReadOnly,
+	"Logs":           auth.ScopeReadOnly,
+	"CollectOutputs": auth.ScopeReadOnly,
+}
+
+// grpcServer serves the gRPC API of the daemon, on top of the same engine as
+// the HTTP endpoints.
+type grpcServer struct {
+	daemonpb.UnimplementedDaemonServer
+
+	engine api.Engine
+}
+
+var _ daemonpb.DaemonServer = (*grpcServer)(nil)
+
+// newGRPCServer returns a gRPC server for the engine. Calls must carry a
+// token if authn isn't nil, and are served over TLS if tlscfg isn't nil.
+func newGRPCServer(engine api.Engine, authn *authenticator, tlscfg *tls.Config) *grpc.Server {
+	var opts []grpc.ServerOption
+	if tlscfg != nil {
+		opts = append(opts, grpc.Creds(credentials.NewTLS(tlscfg)))
+	}
+	if authn != nil {
+		opts = append(opts,
+			grpc.UnaryInterceptor(authn.unaryInterceptor),
+			grpc.StreamInterceptor(authn.streamInterceptor),
+		)
+	}
+
+	s := grpc.NewServer(opts...)
+	daemonpb.RegisterDaemonServer(s, &grpcServer{engine: engine})
+	return s
+}
+
+// authorize returns the context of a call, with the token of its
+// `authorization` metadata, if that token is allowed to call the method.
+func (a *authenticator) authorize(ctx context.Context, method string) (context.Context, error) {
+	var header string
+	if md, ok := metadata.FromIncomingContext(ctx); ok && len(md.Get("authorization")) > 0 {
+		header = md.Get("authorization")[0]
+	}
+
+	tok, err := a.authenticate(ctx, header)
+	if err != nil {
+		logging.S().Debugw("call not authenticated", "method", method, "err", err)
+		return nil, status.Error(codes.Unauthenticated, "not authenticated")
+	}
+
+	required, ok := methodScopes[path.Base(method)]
+	if !ok {
+		required = auth.ScopeAdmin
+	}
+	if !tok.Scope.Allows(required) {
+		logging.S().Infow("call denied", "token", tok.ID, "name", tok.Name, "scope", tok.Scope, "required", required, "method", method)
+		return nil, status.Errorf(codes.PermissionDenied, "token scope %s does not allow %s", tok.Scope, method)
+	}
+
+	return auth.WithToken(ctx, tok), nil
+}
+
+func (a *authenticator) unaryInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	ctx, err := a.authorize(ctx, info.FullMethod)
+	if err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+func (a *authenticator) streamInterceptor(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	ctx, err := a.authorize(ss.Context(), info.FullMethod)
+	if err != nil {
+		return err
+	}
+	return handler(srv, &authorizedStream{ss, ctx})
+}
+
+// authorizedStream is a server stream carrying the context with the token of
+// the call.
+type authorizedStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *authorizedStream) Context() context.Context {
+	return s.ctx
+}
+
+func (s *grpcServer) Build(ctx context.Context, req *daemonpb.BuildRequest) (*daemonpb.QueueResponse, error) {
+	ruid := uuid.New()[:8]
+	log := logging.S().With("req_id", ruid)
+
+	log.Infow("handle call", "command", "build")
+	defer log.Infow("call handled", "command", "build")
+
+	var request *api.BuildRequest
+	if err := json.Unmarshal(req.RequestJson, &request); err != nil || request == nil {
+		return nil, status.Errorf(codes.InvalidArgument, "failed to json decode request: %v", err)
+	}
+
+	sources, err := s.unpackSources(ruid, req.Sources)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to unpack sources: %s", err)
+	}
+	sources, err = fetchPlanSource(ctx, s.engine.EnvConfig(), request.PlanSource, sources, s.requestDir(ruid))
+	if err != nil {
+		return nil, status.Errorf(codes.FailedPrecondition, "failed to fetch plan source: %s", err)
+	}
+	if sources == nil || sources.PlanDir == "" {
+		return nil, status.Error(codes.InvalidArgument, "plan directory not present")
+	}
+
+	request.TraceContext = tracing.Inject(ctx)
+	createdBy(ctx, &request.CreatedBy)
+
+	id, err := s.engine.QueueBuild(request, sources)
+	if err != nil {
+		return nil, queueError(err)
+	}
+	return &daemonpb.QueueResponse{TaskId: id}, nil
+}
+
+func (s *grpcServer) Run(ctx context.Context, req *daemonpb.RunRequest) (*daemonpb.QueueResponse, error) {
+	ruid := uuid.New()[:8]
+	log := logging.S().With("req_id", ruid)
+
+	log.Infow("handle call", "command", "run")
+	defer log.Infow("call handled", "command", "run")
+
+	var request *api.RunRequest
+	if err := json.Unmarshal(req.RequestJson, &request); err != nil || request == nil {
+		return nil, status.Errorf(codes.InvalidArgument, "failed to json decode request: %v", err)
+	}
+
+	sources, err := s.unpackSources(ruid, req.Sources)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to unpack sources: %s", err)
+	}
+	if len(request.BuildGroups) > 0 {
+		sources, err = fetchPlanSource(ctx, s.engine.EnvConfig(), request.PlanSource, sources, s.requestDir(ruid))
+		if err != nil {
+			return nil, status.Errorf(codes.FailedPrecondition, "failed to fetch plan source: %s", err)
+		}
+	}
+	if len(request.BuildGroups) > 0 && sources == nil {
+		return nil, status.Error(codes.InvalidArgument, "plan dir required for build")
+	}
+
+	request.TraceContext = tracing.Inject(ctx)
+	createdBy(ctx, &request.CreatedBy)
+
+	id, err := s.engine.QueueRun(request, sources)
+	if err != nil {
+		return nil, queueError(err)
+	}
+	return &daemonpb.QueueResponse{TaskId: id}, nil
+}
+
+// requestDir returns the packing directory of a call under the workdir.
+func (s *grpcServer) requestDir(ruid string) string {
+	return filepath.Join(s.engine.EnvConfig().Dirs().Work(), "requests", ruid)
+}
+
+// unpackSources inflates the archives of a call in a packing directory under
+// the workdir, like consumeRunBuildRequest. It returns nil if there are none.
+func (s *grpcServer) unpackSources(ruid string, src *daemonpb.Sources) (*api.UnpackedSources, error) {
+	if src == nil || (len(src.Plan) == 0 && len(src.Sdk) == 0 && len(src.Extra) == 0) {
+		return nil, nil
+	}
+
+	dir := s.requestDir(ruid)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create temp directory to unpack request: %w", err)
+	}
+
+	unpacked := &api.UnpackedSources{BaseDir: dir}
+	for kind, archive := range map[string][]byte{"plan": src.Plan, "sdk": src.Sdk, "extra": src.Extra} {
+		if len(archive) == 0 {
+			continue
+		}
+		if err := unpackSource(unpacked, kind, bytes.NewReader(archive)); err != nil {
+			return nil, err
+		}
+	}
+	return unpacked, nil
+}
+
+func (s *grpcServer) Tasks(_ context.Context, req *daemonpb.TasksRequest) (*daemonpb.TasksResponse, error) {
+	filters := api.TasksFilters{
+		TestPlan: req.TestPlan,
+		TestCase: req.TestCase,
+	}
+	for _, t := range req.Types {
+		filters.Types = append(filters.Types, task.Type(t))
+	}
+	for _, st := range req.States {
+		filters.States = append(filters.States, task.State(st))
+	}
+	if len(filters.Types) == 0 {
+		filters.Types = []task.Type{task.TypeBuild, task.TypeRun, task.TypePipeline}
+	}
+	if len(filters.States) == 0 {
+		filters.States = []task.State{task.StateScheduled, task.StateProcessing, task.StateComplete}
+	}
+	if req.After != nil {
+		after := req.After.AsTime()
+		filters.After = &after
+	}
+	if req.Before != nil {
+		before := req.Before.AsTime()
+		filters.Before = &before
+	}
+
+	tasks, err := s.engine.Tasks(filters)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	res := &daemonpb.TasksResponse{Tasks: make([]*daemonpb.Task, 0, len(tasks))}
+	for i := range tasks {
+		tsk, err := taskToProto(&tasks[i])
+		if err != nil {
+			return nil, status.Error(codes.Internal, err.Error())
+		}
+		res.Tasks = append(res.Tasks, tsk)
+	}
+	return res, nil
+}
+
+func (s *grpcServer) Status(_ context.Context, req *daemonpb.StatusRequest) (*daemonpb.Task, error) {
+	tsk, err := s.engine.GetTask(req.TaskId)
+	if err != nil {
+		return nil, taskError(err)
+	}
+
+	res, err := taskToProto(tsk)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	return res, nil
+}
+
+func (s *grpcServer) Logs(req *daemonpb.LogsRequest, stream daemonpb.Daemon_LogsServer) error {
+	w := rpc.NewChunkWriter(func(chunk *rpc.Chunk) error {
+		if chunk.Type != rpc.ChunkTypeProgress {
+			return nil
+		}
+		progress, err := decodePayload(chunk)
+		if err != nil {
+			return err
+		}
+		return stream.Send(&daemonpb.LogsResponse{Response: &daemonpb.LogsResponse_Progress{Progress: progress}})
+	})
+
+	if req.CancelOnClose {
+		if err := checkOwner(stream.Context(), s.engine, req.TaskId); err != nil {
+			return taskError(err)
+		}
+	}
+
+	tsk, err := s.engine.Logs(stream.Context(), req.TaskId, req.Follow, req.CancelOnClose, w)
+	if err != nil {
+		return taskError(err)
+	}
+
+	res, err := taskToProto(tsk)
+	if err != nil {
+		return status.Error(codes.Internal, err.Error())
+	}
+	return stream.Send(&daemonpb.LogsResponse{Response: &daemonpb.LogsResponse_Task{Task: res}})
+}
+
+func (s *grpcServer) CollectOutputs(req *daemonpb.CollectOutputsRequest, stream daemonpb.Daemon_CollectOutputsServer) error {
+	log := logging.S().With("run_id", req.RunId)
+
+	log.Debugw("handle call", "command", "collect outputs")
+	defer log.Debugw("call handled", "command", "collect outputs")
+
+	ow := rpc.NewFileOutputWriter(rpc.NewChunkWriter(func(chunk *rpc.Chunk) error {
+		payload, err := decodePayload(chunk)
+		if err != nil {
+			return err
+		}
+		switch chunk.Type {
+		case rpc.ChunkTypeProgress:
+			return stream.Send(&daemonpb.CollectOutputsResponse{Response: &daemonpb.CollectOutputsResponse_Progress{Progress: payload}})
+		case rpc.ChunkTypeBinary:
+			return stream.Send(&daemonpb.CollectOutputsResponse{Response: &daemonpb.CollectOutputsResponse_Data{Data: payload}})
+		}
+		return nil
+	}))
+
+	if err := s.engine.DoCollectOutputs(stream.Context(), req.RunId, ow); err != nil {
+		log.Warnw("collect outputs error", "err", err.Error())
+		return taskError(err)
+	}
+	return nil
+}
+
+// decodePayload returns the bytes of a progress or binary chunk, which are
+// base64 encoded.
+func decodePayload(chunk *rpc.Chunk) ([]byte, error) {
+	s, ok := chunk.Payload.(string)
+	if !ok {
+		return nil, nil
+	}
+	return base64.StdEncoding.DecodeString(s)
+}
+
+// taskToProto converts a task to its message, which carries the whole task
+// as JSON along with its main fields.
+func taskToProto(tsk *task.Task) (*daemonpb.Task, error) {
+	b, err := json.Marshal(tsk)
+	if err != nil {
+		return nil, err
+	}
+
+	outcome, err := data.DecodeTaskOutcome(tsk)
+	if err != nil {
+		return nil, err
+	}
+
+	return &daemonpb.Task{
+		Id:       tsk.ID,
+		Type:     string(tsk.Type),
+		Plan:     tsk.Plan,
+		Case:     tsk.Case,
+		Runner:   tsk.Runner,
+		State:    string(tsk.State().State),
+		Outcome:  string(outcome),
+		Error:    tsk.Error,
+		User:     tsk.CreatedBy.User,
+		Priority: int32(tsk.Priority),
+		Created:  timestamppb.New(tsk.Created()),
+		Updated:  timestamppb.New(tsk.State().Created),
+		TaskJson: b,
+	}, nil
+}
+
+// taskError maps the errors of fetching a task to a status.
+func taskError(err error) error {
+	switch {
+	case errors.Is(err, task.ErrNotFound):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, errNotOwner):
+		return status.Error(codes.PermissionDenied, err.Error())
+	}
+	return status.Error(codes.Internal, err.Error())
+}
+
+// queueError maps the errors of queueing a task to a status.
+func queueError(err error) error {
+	if errors.Is(err, task.ErrQueueFull) || errors.Is(err, engine.ErrDraining) {
+		return status.Error(codes.Unavailable, err.Error())
+	}
+	return status.Error(codes.Internal, err.Error())
+}
@@ -0,0 +1,165 @@
+package daemon
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/testground/testground/pkg/api"
+	"github.com/testground/testground/pkg/auth"
+	"github.com/testground/testground/pkg/daemon/daemonpb"
+	"github.com/testground/testground/pkg/engine"
+	"github.com/testground/testground/pkg/rpc"
+	"github.com/testground/testground/pkg/task"
+)
+
+// dialGRPC serves the gRPC API of an engine without builders nor runners,
+// and returns a client for it.
+func dialGRPC(t *testing.T, authn *authenticator) (daemonpb.DaemonClient, *engine.Engine) {
+	e := newTestEngine(t)
+
+	l := bufconn.Listen(1 << 20)
+	srv := newGRPCServer(e, authn, nil)
+	go func() { _ = srv.Serve(l) }()
+	t.Cleanup(srv.Stop)
+
+	conn, err := grpc.Dial("bufnet",
+		grpc.WithInsecure(),
+		grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) { return l.Dial() }),
+	)
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+
+	return daemonpb.NewDaemonClient(conn), e
+}
+
+func zipPlan(t *testing.T) []byte {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	f, err := zw.Create("manifest.toml")
+	require.NoError(t, err)
+	_, err = f.Write([]byte(`name = "placebo"`))
+	require.NoError(t, err)
+	require.NoError(t, zw.Close())
+	return buf.Bytes()
+}
+
+func TestGRPC(t *testing.T) {
+	client, e := dialGRPC(t, nil)
+	ctx := context.Background()
+
+	req, err := json.Marshal(&api.BuildRequest{
+		Composition: api.Composition{Global: api.Global{Plan: "placebo", Builder: "docker:fake"}},
+		CreatedBy:   api.CreatedBy{User: "alice"},
+	})
+	require.NoError(t, err)
+
+	_, err = client.Build(ctx, &daemonpb.BuildRequest{RequestJson: req})
+	require.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	res, err := client.Build(ctx, &daemonpb.BuildRequest{
+		RequestJson: req,
+		Sources:     &daemonpb.Sources{Plan: zipPlan(t)},
+	})
+	require.NoError(t, err)
+	require.NotEmpty(t, res.TaskId)
+
+	// The build fails, as the manifest of the plan lists no builders.
+	var tsk *daemonpb.Task
+	require.Eventually(t, func() bool {
+		tsk, err = client.Status(ctx, &daemonpb.StatusRequest{TaskId: res.TaskId})
+		require.NoError(t, err)
+		return tsk.State != string(task.StateScheduled) && tsk.State != string(task.StateProcessing)
+	}, 10*time.Second, 50*time.Millisecond)
+	require.Equal(t, "alice", tsk.User)
+	require.Equal(t, string(task.TypeBuild), tsk.Type)
+	require.Contains(t, tsk.Error, "plan supports no builders")
+
+	tasks, err := client.Tasks(ctx, &daemonpb.TasksRequest{After: timestamppb.New(time.Now().Add(time.Minute))})
+	require.NoError(t, err)
+	require.Len(t, tasks.Tasks, 1)
+	require.Equal(t, res.TaskId, tasks.Tasks[0].Id)
+
+	f, err := os.OpenFile(filepath.Join(e.EnvConfig().Dirs().Daemon(), res.TaskId+".out"), os.O_APPEND|os.O_WRONLY, 0644)
+	require.NoError(t, err)
+	rpc.NewFileOutputWriter(f).Infow("hello from the task")
+	require.NoError(t, f.Close())
+
+	stream, err := client.Logs(ctx, &daemonpb.LogsRequest{TaskId: res.TaskId})
+	require.NoError(t, err)
+	var (
+		logs []byte
+		last *daemonpb.Task
+	)
+	for {
+		msg, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		logs = append(logs, msg.GetProgress()...)
+		if msg.GetTask() != nil {
+			last = msg.GetTask()
+		}
+	}
+	require.Contains(t, string(logs), "hello from the task")
+	require.NotNil(t, last)
+	require.Equal(t, res.TaskId, last.Id)
+
+	_, err = client.Status(ctx, &daemonpb.StatusRequest{TaskId: "c3ftkqjpc98qra498sg0"})
+	require.Equal(t, codes.NotFound, status.Code(err))
+}
+
+func TestGRPCAuth(t *testing.T) {
+	store, err := auth.NewMemoryStore()
+	require.NoError(t, err)
+	defer store.Close()
+
+	_, readOnly, err := store.Create("bob", auth.ScopeReadOnly, 0)
+	require.NoError(t, err)
+
+	client, e := dialGRPC(t, newAuthenticator([]string{"secret"}, store, nil))
+	withToken := func(tok string) context.Context {
+		return metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer "+tok)
+	}
+
+	_, err = client.Tasks(context.Background(), &daemonpb.TasksRequest{})
+	require.Equal(t, codes.Unauthenticated, status.Code(err))
+
+	_, err = client.Tasks(withToken(readOnly), &daemonpb.TasksRequest{})
+	require.NoError(t, err)
+
+	_, err = client.Build(withToken(readOnly), &daemonpb.BuildRequest{RequestJson: []byte("{}")})
+	require.Equal(t, codes.PermissionDenied, status.Code(err))
+
+	// Streams are authorized too.
+	stream, err := client.Logs(context.Background(), &daemonpb.LogsRequest{TaskId: "c3ftkqjpc98qra498sg0"})
+	require.NoError(t, err)
+	_, err = stream.Recv()
+	require.Equal(t, codes.Unauthenticated, status.Code(err))
+
+	_, err = client.Build(withToken("secret"), &daemonpb.BuildRequest{RequestJson: []byte("{}")})
+	require.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	// Read-only tokens can't cancel the tasks they follow.
+	tsk := failedBuild(t, e)
+	stream, err = client.Logs(withToken(readOnly), &daemonpb.LogsRequest{TaskId: tsk.ID, Follow: true, CancelOnClose: true})
+	require.NoError(t, err)
+	_, err = stream.Recv()
+	require.Equal(t, codes.PermissionDenied, status.Code(err))
+}
@@ -26,6 +26,15 @@ func (d *Daemon) logsHandler(engine api.Engine) func(w http.ResponseWriter, r *h
 			return
 		}
 
+		// Following with cancel kills the task on disconnect, which only its
+		// owner may do.
+		if req.CancelWithContext {
+			if err := checkOwner(r.Context(), engine, req.TaskID); err != nil {
+				tgw.WriteError("error while getting task", "err", err)
+				return
+			}
+		}
+
 		tsk, err := engine.Logs(r.Context(), req.TaskID, req.Follow, req.CancelWithContext, w)
 		if err != nil {
 			tgw.WriteError("error while getting task", "err", err)
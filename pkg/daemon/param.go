@@ -0,0 +1,42 @@
+package daemon
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/testground/testground/pkg/api"
+	"github.com/testground/testground/pkg/logging"
+	"github.com/testground/testground/pkg/rpc"
+)
+
+func (d *Daemon) paramHandler(engine api.Engine) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		log := logging.S().With("req_id", r.Header.Get("X-Request-ID"))
+
+		log.Debugw("handle request", "command", "param")
+		defer log.Debugw("request handled", "command", "param")
+
+		tgw := rpc.NewOutputWriter(w, r)
+
+		var req api.ParamPushRequest
+		err := json.NewDecoder(r.Body).Decode(&req)
+		if err != nil {
+			tgw.WriteError("param json decode", "err", err.Error())
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		if err := checkOwner(r.Context(), engine, req.TaskID); err != nil {
+			tgw.WriteError("param error", "err", err.Error())
+			return
+		}
+
+		out, err := engine.DoPushParam(r.Context(), &req, tgw)
+		if err != nil {
+			tgw.WriteError("param error", "err", err.Error())
+			return
+		}
+
+		tgw.WriteResult(out)
+	}
+}
@@ -0,0 +1,69 @@
+package daemon
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/otiai10/copy"
+	"github.com/testground/testground/pkg/api"
+	"github.com/testground/testground/pkg/config"
+	"github.com/testground/testground/pkg/logging"
+	"github.com/testground/testground/pkg/plansource"
+)
+
+// fetchPlanSource fetches the plan of a request from its remote source, if
+// it has one and the request carries no plan sources, and copies it under the
+// packing directory of the request.
+//
+// Only the remote sources CheckRemote allows are fetched, so that callers
+// can't read the repositories of the host of the daemon, nor clone with its
+// ssh keys from hosts it doesn't trust.
+//
+// Fetches are cached by commit, so a source pinned to a commit is only
+// cloned once.
+func fetchPlanSource(ctx context.Context, envcfg config.EnvConfig, src *api.PlanSource, sources *api.UnpackedSources, dir string) (*api.UnpackedSources, error) {
+	if src == nil || (sources != nil && sources.PlanDir != "") {
+		return sources, nil
+	}
+
+	ps := plansource.Source(*src)
+	if err := ps.CheckRemote(envcfg.Daemon.PlanSources.SSHHosts); err != nil {
+		return nil, fmt.Errorf("plan source %s is not allowed: %w", ps, err)
+	}
+	var (
+		pinned = &ps
+		cached string
+		err    error
+	)
+	if envcfg.Daemon.Offline.Enabled {
+		// Sources fetched before the daemon went offline are still served.
+		if cached, err = plansource.Cached(envcfg.Dirs().PlanSources(), &ps); err != nil {
+			return nil, fmt.Errorf("%w: plan source %s can't be fetched, send the plan along with the request: %s", config.ErrOffline, ps, err)
+		}
+	} else if pinned, cached, err = plansource.Fetch(ctx, envcfg.Dirs().PlanSources(), &ps, nil); err != nil {
+		return nil, fmt.Errorf("failed to fetch plan from %s: %w", ps, err)
+	}
+	if src.Commit != "" && src.Commit != pinned.Commit {
+		return nil, fmt.Errorf("plan source %s resolved to commit %s, expected %s", ps, pinned.Commit, src.Commit)
+	}
+	*src = api.PlanSource(*pinned)
+
+	if sources == nil {
+		sources = &api.UnpackedSources{BaseDir: dir}
+	}
+	if err := os.MkdirAll(sources.BaseDir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create directory for plan: %w", err)
+	}
+	plan := filepath.Join(sources.BaseDir, "plan")
+	// Symlinks are skipped, as those of the repository may point anywhere on
+	// the host.
+	if err := copy.Copy(cached, plan, copy.Options{OnSymlink: func(string) copy.SymlinkAction { return copy.Skip }}); err != nil {
+		return nil, fmt.Errorf("failed to copy plan from cache: %w", err)
+	}
+	sources.PlanDir = plan
+
+	logging.S().Infow("fetched plan", "source", ps.String(), "commit", pinned.Commit)
+	return sources, nil
+}
@@ -0,0 +1,54 @@
+package daemon
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/testground/testground/pkg/api"
+	"github.com/testground/testground/pkg/logging"
+)
+
+// registryHandler serves the instances of a live run, for external observers
+// to discover them. Instances are returned as JSON, or in hosts file format
+// (`format=hosts`), which can be fed to a DNS server such as dnsmasq.
+func (d *Daemon) registryHandler(engine api.Engine) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		log := logging.S().With("req_id", r.Header.Get("X-Request-ID"))
+
+		log.Debugw("handle request", "command", "registry")
+		defer log.Debugw("request handled", "command", "registry")
+
+		taskId := r.URL.Query().Get("task_id")
+		if taskId == "" {
+			http.Error(w, "url param `task_id` is missing", http.StatusBadRequest)
+			return
+		}
+
+		instances, err := engine.DoListInstances(r.Context(), taskId)
+		if err != nil {
+			log.Warnw("could not list instances", "task_id", taskId, "err", err)
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+
+		switch r.URL.Query().Get("format") {
+		case "hosts":
+			w.Header().Set("Content-Type", "text/plain")
+			for _, inst := range instances {
+				if inst.DataIP == "" {
+					continue
+				}
+				fmt.Fprintf(w, "%s\t%s.%s.%s\n", inst.DataIP, inst.Name, inst.GroupID, taskId)
+			}
+		case "", "json":
+			w.Header().Set("Content-Type", "application/json")
+			err = json.NewEncoder(w).Encode(instances)
+			if err != nil {
+				log.Warnw("could not encode instances", "err", err)
+			}
+		default:
+			http.Error(w, "unsupported format", http.StatusBadRequest)
+		}
+	}
+}
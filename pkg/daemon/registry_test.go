@@ -0,0 +1,84 @@
+package daemon
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/testground/testground/pkg/api"
+	"github.com/testground/testground/pkg/config"
+)
+
+// registryEngine lists the instances of a single run.
+type registryEngine struct {
+	api.Engine
+	runID     string
+	instances []*api.Instance
+}
+
+func (e *registryEngine) DoListInstances(_ context.Context, runID string) ([]*api.Instance, error) {
+	if runID != e.runID {
+		return nil, errors.New("unknown run")
+	}
+	return e.instances, nil
+}
+
+func TestRegistry(t *testing.T) {
+	e := &registryEngine{
+		runID: "run1",
+		instances: []*api.Instance{
+			{Name: "tg-placebo-run1-miners-0", GroupID: "miners", DataIP: "16.0.0.2", Ports: map[string]string{"8080/tcp": "0.0.0.0:32768"}, State: "running"},
+			{Name: "tg-placebo-run1-miners-1", GroupID: "miners", State: "created"},
+		},
+	}
+
+	cfg := &config.EnvConfig{}
+	cfg.Daemon.Registry = true
+	srv := httptest.NewServer((&Daemon{}).newRouter(cfg, e, nil))
+	defer srv.Close()
+
+	get := func(query string) (*http.Response, []byte) {
+		resp, err := http.Get(srv.URL + "/registry" + query)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		body, err := ioutil.ReadAll(resp.Body)
+		require.NoError(t, err)
+		return resp, body
+	}
+
+	resp, body := get("?task_id=run1")
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, "application/json", resp.Header.Get("Content-Type"))
+	var instances []*api.Instance
+	require.NoError(t, json.Unmarshal(body, &instances))
+	require.Equal(t, e.instances, instances)
+
+	// Instances without a data network address are left out of hosts files.
+	resp, body = get("?task_id=run1&format=hosts")
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, "text/plain", resp.Header.Get("Content-Type"))
+	require.Equal(t, "16.0.0.2\ttg-placebo-run1-miners-0.miners.run1\n", string(body))
+
+	resp, _ = get("?task_id=run1&format=yaml")
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	resp, _ = get("")
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	resp, _ = get("?task_id=run2")
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
+
+func TestRegistryDisabled(t *testing.T) {
+	srv := httptest.NewServer((&Daemon{}).newRouter(&config.EnvConfig{}, &registryEngine{runID: "run1"}, nil))
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/registry?task_id=run1")
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
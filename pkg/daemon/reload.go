@@ -0,0 +1,58 @@
+package daemon
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/testground/testground/pkg/api"
+	"github.com/testground/testground/pkg/config"
+	"github.com/testground/testground/pkg/logging"
+	"github.com/testground/testground/pkg/rpc"
+)
+
+// Reload reloads the env config of the daemon from the file at path, or from
+// its .env.toml file if empty, without restarting it.
+func (d *Daemon) Reload(path string) (*api.ReloadOutput, error) {
+	return reloadConfig(d.engine, path)
+}
+
+func reloadConfig(engine api.Engine, path string) (*api.ReloadOutput, error) {
+	cfg := &config.EnvConfig{}
+	var err error
+	if path != "" {
+		err = cfg.LoadFrom(path)
+	} else {
+		err = cfg.Load()
+	}
+	if err != nil {
+		return nil, err
+	}
+	return engine.Reload(cfg)
+}
+
+func (d *Daemon) reloadHandler(engine api.Engine) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		log := logging.S().With("req_id", r.Header.Get("X-Request-ID"))
+
+		log.Debugw("handle request", "command", "reload")
+		defer log.Debugw("request handled", "command", "reload")
+
+		tgw := rpc.NewOutputWriter(w, r)
+
+		var req api.ReloadRequest
+		err := json.NewDecoder(r.Body).Decode(&req)
+		if err != nil {
+			tgw.WriteError("reload json decode", "err", err.Error())
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		out, err := reloadConfig(engine, req.Path)
+		if err != nil {
+			tgw.WriteError("reload error", "err", err.Error())
+			return
+		}
+
+		tgw.WriteResult(out)
+	}
+}
@@ -10,6 +10,7 @@ import (
 	"github.com/testground/testground/pkg/api"
 	"github.com/testground/testground/pkg/logging"
 	"github.com/testground/testground/pkg/rpc"
+	"github.com/testground/testground/pkg/tracing"
 )
 
 func (d *Daemon) runHandler(engine api.Engine) func(w http.ResponseWriter, r *http.Request) {
@@ -36,11 +37,22 @@ func (d *Daemon) runHandler(engine api.Engine) func(w http.ResponseWriter, r *ht
 			return
 		}
 
+		if len(request.BuildGroups) > 0 {
+			sources, err = fetchPlanSource(r.Context(), engine.EnvConfig(), request.PlanSource, sources, dir)
+			if err != nil {
+				tgw.WriteError("failed to fetch plan source", "err", err)
+				return
+			}
+		}
+
 		if len(request.BuildGroups) > 0 && sources == nil {
 			tgw.WriteError("failed to consume request", "err", errors.New("plan dir required for build"))
 			return
 		}
 
+		request.TraceContext = tracing.Inject(r.Context())
+		createdBy(r.Context(), &request.CreatedBy)
+
 		id, err := engine.QueueRun(request, sources)
 		if err != nil {
 			tgw.WriteError(fmt.Sprintf("engine run error: %s", err))
@@ -0,0 +1,42 @@
+package daemon
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/testground/testground/pkg/api"
+	"github.com/testground/testground/pkg/logging"
+	"github.com/testground/testground/pkg/rpc"
+)
+
+func (d *Daemon) scaleHandler(engine api.Engine) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		log := logging.S().With("req_id", r.Header.Get("X-Request-ID"))
+
+		log.Debugw("handle request", "command", "scale")
+		defer log.Debugw("request handled", "command", "scale")
+
+		tgw := rpc.NewOutputWriter(w, r)
+
+		var req api.ScaleRequest
+		err := json.NewDecoder(r.Body).Decode(&req)
+		if err != nil {
+			tgw.WriteError("scale json decode", "err", err.Error())
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		if err := checkOwner(r.Context(), engine, req.TaskID); err != nil {
+			tgw.WriteError("scale error", "err", err.Error())
+			return
+		}
+
+		out, err := engine.DoScale(r.Context(), &req, tgw)
+		if err != nil {
+			tgw.WriteError("scale error", "err", err.Error())
+			return
+		}
+
+		tgw.WriteResult(out)
+	}
+}
@@ -1,9 +1,11 @@
 package daemon
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"html/template"
+	"io"
 	"net/http"
 	"time"
 
@@ -58,7 +60,7 @@ func (d *Daemon) listTasksHandler(engine api.Engine) func(w http.ResponseWriter,
 
 		before := time.Now().Add(-7 * 24 * time.Hour)
 		req := api.TasksRequest{
-			Types:  []task.Type{task.TypeBuild, task.TypeRun},
+			Types:  []task.Type{task.TypeBuild, task.TypeRun, task.TypePipeline},
 			States: []task.State{task.StateScheduled, task.StateProcessing, task.StateComplete},
 			Before: &before,
 		}
@@ -147,19 +149,9 @@ func (d *Daemon) listTasksHandler(engine api.Engine) func(w http.ResponseWriter,
 			tdata.Tasks = append(tdata.Tasks, currentTask)
 		}
 
-		t := template.New("tasks.html").Funcs(template.FuncMap{"unescape": unescape})
-		content, err := tmpl.HtmlTemplates.ReadFile("tasks.html")
-		if err != nil {
-			panic(fmt.Sprintf("cannot find template file: %s", err))
-		}
-		t, err = t.Parse(string(content))
-		if err != nil {
-			panic(fmt.Sprintf("cannot ParseFiles with tmpl/tasks: %s", err))
-		}
-
-		err = t.Execute(w, tdata)
-		if err != nil {
-			panic(fmt.Sprintf("cannot execute template: %s", err))
+		if err := renderTemplate(w, "tasks.html", tdata); err != nil {
+			log.Errorw("cannot render page", "err", err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
 		}
 	}
 }
@@ -173,3 +165,24 @@ func (d *Daemon) redirect() func(w http.ResponseWriter, r *http.Request) {
 func unescape(s string) template.HTML {
 	return template.HTML(s)
 }
+
+// renderTemplate renders one of the HTML templates of the daemon. The page is
+// rendered in full before anything is written, so that a failure leaves the
+// response untouched for the caller to report.
+func renderTemplate(w io.Writer, name string, data interface{}) error {
+	content, err := tmpl.HtmlTemplates.ReadFile(name)
+	if err != nil {
+		return fmt.Errorf("cannot find template %s: %w", name, err)
+	}
+	t, err := template.New(name).Funcs(template.FuncMap{"unescape": unescape}).Parse(string(content))
+	if err != nil {
+		return fmt.Errorf("cannot parse template %s: %w", name, err)
+	}
+
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, data); err != nil {
+		return fmt.Errorf("cannot execute template %s: %w", name, err)
+	}
+	_, err = buf.WriteTo(w)
+	return err
+}
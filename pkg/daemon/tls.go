@@ -0,0 +1,47 @@
+package daemon
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+
+	"github.com/testground/testground/pkg/config"
+)
+
+// serverTLSConfig returns the TLS configuration of the listener of the
+// daemon, or nil if the daemon serves plain HTTP.
+func serverTLSConfig(cfg config.DaemonTLSConfig) (*tls.Config, error) {
+	if cfg.CertFile == "" && cfg.KeyFile == "" {
+		if cfg.ClientCAFile != "" {
+			return nil, errors.New("tls: client_ca_file requires cert_file and key_file")
+		}
+		return nil, nil
+	}
+
+	cert, err := tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("tls: failed to load the certificate of the daemon: %w", err)
+	}
+
+	tlscfg := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+
+	if cfg.ClientCAFile != "" {
+		pem, err := ioutil.ReadFile(cfg.ClientCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("tls: failed to read the client CAs: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("tls: no certificates found in %s", cfg.ClientCAFile)
+		}
+		tlscfg.ClientCAs = pool
+		tlscfg.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+
+	return tlscfg, nil
+}
@@ -0,0 +1,109 @@
+package daemon
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/testground/testground/pkg/client"
+	"github.com/testground/testground/pkg/config"
+	"github.com/testground/testground/pkg/rpc"
+)
+
+// issue writes a certificate and its key signed by parent, or self-signed if
+// parent is nil, and returns them.
+func issue(t *testing.T, dir string, name string, parent *x509.Certificate, parentKey *ecdsa.PrivateKey) (*x509.Certificate, *ecdsa.PrivateKey) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	tpl := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	if parent == nil {
+		tpl.IsCA, tpl.BasicConstraintsValid = true, true
+		parent, parentKey = tpl, key
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tpl, parent, &key.PublicKey, parentKey)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, name+".pem"), pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, name+"-key.pem"), pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0600))
+	return cert, key
+}
+
+func TestMutualTLS(t *testing.T) {
+	dir := t.TempDir()
+	ca, caKey := issue(t, dir, "ca", nil, nil)
+	issue(t, dir, "daemon", ca, caKey)
+	issue(t, dir, "client", ca, caKey)
+
+	path := func(name string) string { return filepath.Join(dir, name) }
+
+	tlscfg, err := serverTLSConfig(config.DaemonTLSConfig{
+		CertFile:     path("daemon.pem"),
+		KeyFile:      path("daemon-key.pem"),
+		ClientCAFile: path("ca.pem"),
+	})
+	require.NoError(t, err)
+
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rpc.NewOutputWriter(w, r).WriteResult([]interface{}{})
+	}))
+	srv.TLS = tlscfg
+	srv.StartTLS()
+	defer srv.Close()
+
+	listTokens := func(tls config.ClientTLSConfig) error {
+		cfg := &config.EnvConfig{Client: config.ClientConfig{Endpoint: srv.URL, TLS: tls}}
+		r, err := client.New(cfg).ListTokens(context.Background())
+		if err != nil {
+			return err
+		}
+		defer r.Close()
+		_, err = client.ParseTokenListResponse(r, ioutil.Discard)
+		return err
+	}
+
+	require.NoError(t, listTokens(config.ClientTLSConfig{
+		CAFile:   path("ca.pem"),
+		CertFile: path("client.pem"),
+		KeyFile:  path("client-key.pem"),
+	}))
+
+	// The daemon requires a client certificate.
+	require.Error(t, listTokens(config.ClientTLSConfig{CAFile: path("ca.pem")}))
+
+	// The client doesn't trust the daemon without its CA.
+	require.Error(t, listTokens(config.ClientTLSConfig{
+		CertFile: path("client.pem"),
+		KeyFile:  path("client-key.pem"),
+	}))
+
+	_, err = serverTLSConfig(config.DaemonTLSConfig{ClientCAFile: path("ca.pem")})
+	require.Error(t, err)
+}
@@ -0,0 +1,104 @@
+package daemon
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/testground/testground/pkg/api"
+	"github.com/testground/testground/pkg/logging"
+	"github.com/testground/testground/pkg/rpc"
+)
+
+func (d *Daemon) tokenCreateHandler() func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		log := logging.S().With("req_id", r.Header.Get("X-Request-ID"))
+
+		log.Debugw("handle request", "command", "token create")
+		defer log.Debugw("request handled", "command", "token create")
+
+		tgw := rpc.NewOutputWriter(w, r)
+
+		var req api.TokenCreateRequest
+		err := json.NewDecoder(r.Body).Decode(&req)
+		if err != nil {
+			tgw.WriteError("token create json decode", "err", err.Error())
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if req.ExpiresSecs < 0 {
+			http.Error(w, "expires_secs must not be negative", http.StatusBadRequest)
+			return
+		}
+
+		tok, secret, err := d.tokens.Create(req.Name, req.Scope, time.Duration(req.ExpiresSecs)*time.Second)
+		if err != nil {
+			tgw.WriteError("token create error", "err", err.Error())
+			return
+		}
+
+		log.Infow("token created", "id", tok.ID, "name", tok.Name, "scope", tok.Scope, "expires", tok.Expires)
+
+		tok.Hash = ""
+		tgw.WriteResult(&api.TokenCreateResponse{Token: tok, Secret: secret})
+	}
+}
+
+func (d *Daemon) tokenListHandler() func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		log := logging.S().With("req_id", r.Header.Get("X-Request-ID"))
+
+		log.Debugw("handle request", "command", "token list")
+		defer log.Debugw("request handled", "command", "token list")
+
+		tgw := rpc.NewOutputWriter(w, r)
+
+		tokens, err := d.tokens.List()
+		if err != nil {
+			tgw.WriteError("token list error", "err", err.Error())
+			return
+		}
+
+		for _, tok := range tokens {
+			tok.Hash = ""
+		}
+		tgw.WriteResult(tokens)
+	}
+}
+
+func (d *Daemon) tokenRevokeHandler() func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		log := logging.S().With("req_id", r.Header.Get("X-Request-ID"))
+
+		log.Debugw("handle request", "command", "token revoke")
+		defer log.Debugw("request handled", "command", "token revoke")
+
+		tgw := rpc.NewOutputWriter(w, r)
+
+		var req api.TokenRevokeRequest
+		err := json.NewDecoder(r.Body).Decode(&req)
+		if err != nil {
+			tgw.WriteError("token revoke json decode", "err", err.Error())
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		if err := d.tokens.Revoke(req.ID); err != nil {
+			tgw.WriteError("token revoke error", "err", err.Error())
+			return
+		}
+
+		log.Infow("token revoked", "id", req.ID)
+
+		tgw.WriteResult("token revoked")
+	}
+}
+
+// tokenDisabledHandler rejects the token requests of a daemon that doesn't
+// authenticate requests.
+func (d *Daemon) tokenDisabledHandler() func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		tgw := rpc.NewOutputWriter(w, r)
+		tgw.WriteError("token management requires authentication; set daemon.tokens or daemon.oidc in the daemon config")
+	}
+}
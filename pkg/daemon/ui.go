@@ -0,0 +1,219 @@
+package daemon
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/docker/docker/pkg/ioutils"
+
+	"github.com/testground/testground/pkg/api"
+	"github.com/testground/testground/pkg/client"
+	"github.com/testground/testground/pkg/data"
+	"github.com/testground/testground/pkg/logging"
+	"github.com/testground/testground/pkg/task"
+)
+
+// uiTask is the view model of a task rendered in the web UI.
+type uiTask struct {
+	ID        string
+	Name      string
+	Type      task.Type
+	Runner    string
+	Priority  int
+	Created   string
+	Updated   string
+	Took      string
+	State     task.State
+	Status    string
+	Outcome   task.Outcome
+	Error     string
+	CreatedBy string
+}
+
+// uiGroup is the view model of the instance states of a single group.
+type uiGroup struct {
+	ID    string
+	Ok    int
+	Total int
+}
+
+func newUITask(t *task.Task) uiTask {
+	tf := "Mon Jan _2 15:04:05"
+
+	outcome, err := data.DecodeTaskOutcome(t)
+	if err != nil {
+		outcome = task.OutcomeUnknown
+	}
+
+	ut := uiTask{
+		ID:        t.ID,
+		Name:      t.Name(),
+		Type:      t.Type,
+		Runner:    t.Runner,
+		Priority:  t.Priority,
+		Created:   t.Created().Format(tf),
+		Updated:   t.State().Created.Format(tf),
+		Took:      t.Took().String(),
+		State:     t.State().State,
+		Outcome:   outcome,
+		Error:     t.Error,
+		CreatedBy: t.RenderCreatedBy(),
+	}
+
+	switch t.State().State {
+	case task.StateComplete:
+		if outcome == task.OutcomeSuccess {
+			ut.Status = EmojiSuccess
+		} else {
+			ut.Status = EmojiFailure
+		}
+	case task.StateCanceled:
+		ut.Status = EmojiCanceled
+	case task.StateProcessing:
+		ut.Status = EmojiInProgress
+		ut.Took = time.Since(t.State().Created).Truncate(time.Second).String()
+	case task.StateScheduled:
+		ut.Status = EmojiScheduled
+		ut.Took = ""
+	}
+
+	return ut
+}
+
+// uiTasksHandler renders the overview page of the web UI: the task queue
+// (scheduled and processing tasks) followed by the recently completed tasks.
+func (d *Daemon) uiTasksHandler(engine api.Engine) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		log := logging.S().With("req_id", r.Header.Get("X-Request-ID"))
+
+		log.Debugw("handle request", "command", "ui tasks")
+		defer log.Debugw("request handled", "command", "ui tasks")
+
+		w.Header().Set("Content-Type", "text/html")
+
+		// Tasks are filtered by the second they were created in; include the
+		// current one, or tasks submitted just now wouldn't show.
+		before := time.Now().Add(-7 * 24 * time.Hour)
+		after := time.Now().Add(time.Second)
+		tasks, err := engine.Tasks(api.TasksRequest{
+			Types:  []task.Type{task.TypeBuild, task.TypeRun, task.TypePipeline},
+			States: []task.State{task.StateProcessing, task.StateScheduled, task.StateComplete},
+			Before: &before,
+			After:  &after,
+		})
+		if err != nil {
+			fmt.Fprintf(w, "cannot get tasks: %s", err)
+			return
+		}
+
+		tdata := struct {
+			RootURL  string
+			Queue    []uiTask
+			Finished []uiTask
+		}{
+			RootURL: engine.EnvConfig().Daemon.RootURL,
+		}
+
+		for i := range tasks {
+			t := newUITask(&tasks[i])
+			switch t.State {
+			case task.StateScheduled, task.StateProcessing:
+				tdata.Queue = append(tdata.Queue, t)
+			default:
+				tdata.Finished = append(tdata.Finished, t)
+			}
+		}
+
+		if err := renderTemplate(w, "ui_tasks.html", tdata); err != nil {
+			log.Errorw("cannot render page", "err", err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	}
+}
+
+// uiTaskHandler renders the detail page of a single task: its state history,
+// the instance states of every group, and the live output of the task.
+func (d *Daemon) uiTaskHandler(engine api.Engine) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		log := logging.S().With("req_id", r.Header.Get("X-Request-ID"))
+
+		log.Debugw("handle request", "command", "ui task")
+		defer log.Debugw("request handled", "command", "ui task")
+
+		w.Header().Set("Content-Type", "text/html")
+
+		taskId := r.URL.Query().Get("task_id")
+		if taskId == "" {
+			fmt.Fprintf(w, "url param `task_id` is missing")
+			return
+		}
+
+		tsk, err := engine.GetTask(taskId)
+		if err != nil {
+			fmt.Fprintf(w, "cannot get task: %s", err)
+			return
+		}
+
+		tdata := struct {
+			RootURL string
+			Task    uiTask
+			States  []task.DatedState
+			Groups  []uiGroup
+		}{
+			RootURL: engine.EnvConfig().Daemon.RootURL,
+			Task:    newUITask(tsk),
+			States:  tsk.States,
+		}
+
+		if tsk.Type == task.TypeRun {
+			result := data.DecodeRunnerResult(tsk.Result)
+			for id, g := range result.Outcomes {
+				tdata.Groups = append(tdata.Groups, uiGroup{ID: id, Ok: g.Ok, Total: g.Total})
+			}
+			sort.Slice(tdata.Groups, func(i, j int) bool {
+				return tdata.Groups[i].ID < tdata.Groups[j].ID
+			})
+		}
+
+		if err := renderTemplate(w, "ui_task.html", tdata); err != nil {
+			log.Errorw("cannot render page", "err", err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	}
+}
+
+// uiLogsHandler streams the output of a task as plain text, following it until
+// the task is done or the client goes away.
+func (d *Daemon) uiLogsHandler(engine api.Engine) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		log := logging.S().With("req_id", r.Header.Get("X-Request-ID"))
+
+		log.Debugw("handle request", "command", "ui logs")
+		defer log.Debugw("request handled", "command", "ui logs")
+
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+
+		taskId := r.URL.Query().Get("task_id")
+		if taskId == "" {
+			fmt.Fprintf(w, "url param `task_id` is missing")
+			return
+		}
+
+		rr, ww := io.Pipe()
+		go func() {
+			_, err := engine.Logs(r.Context(), taskId, true, false, ww)
+			_ = ww.CloseWithError(err)
+		}()
+		// Unblocks the logs of the engine once the client goes away.
+		defer rr.Close()
+
+		_, err := client.CopyLogs(ioutils.NewWriteFlusher(w), rr)
+		if err != nil && err != io.EOF {
+			fmt.Fprintf(w, "error while streaming logs: %s", err.Error())
+		}
+	}
+}
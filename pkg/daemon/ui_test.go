@@ -0,0 +1,62 @@
+package daemon
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/testground/testground/pkg/rpc"
+)
+
+func TestUI(t *testing.T) {
+	e := newTestEngine(t)
+	tsk := failedBuild(t, e)
+	d := &Daemon{}
+
+	get := func(h http.HandlerFunc, url string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		h(w, httptest.NewRequest(http.MethodGet, url, nil))
+		return w
+	}
+
+	w := get(d.uiTasksHandler(e), "/ui")
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, "text/html", w.Header().Get("Content-Type"))
+	require.Contains(t, w.Body.String(), tsk.ID)
+
+	w = get(d.uiTaskHandler(e), "/ui/task?task_id="+tsk.ID)
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Contains(t, w.Body.String(), tsk.ID)
+	require.Contains(t, w.Body.String(), "plan supports no builders")
+
+	w = get(d.uiTaskHandler(e), "/ui/task")
+	require.Contains(t, w.Body.String(), "`task_id` is missing")
+
+	w = get(d.uiTaskHandler(e), "/ui/task?task_id=c3ftkqjpc98qra498sg0")
+	require.Contains(t, w.Body.String(), "cannot get task")
+
+	// The logs of completed tasks are streamed in full, then the response ends.
+	f, err := os.OpenFile(filepath.Join(e.EnvConfig().Dirs().Daemon(), tsk.ID+".out"), os.O_APPEND|os.O_WRONLY, 0644)
+	require.NoError(t, err)
+	rpc.NewFileOutputWriter(f).Infow("hello from the task")
+	require.NoError(t, f.Close())
+
+	w = get(d.uiLogsHandler(e), "/ui/logs?task_id="+tsk.ID)
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, "text/plain; charset=utf-8", w.Header().Get("Content-Type"))
+	require.Contains(t, w.Body.String(), "hello from the task")
+}
+
+func TestRenderTemplate(t *testing.T) {
+	var buf bytes.Buffer
+	require.Error(t, renderTemplate(&buf, "missing.html", nil))
+
+	// Failing to execute leaves the writer untouched.
+	require.Error(t, renderTemplate(&buf, "ui_task.html", struct{}{}))
+	require.Zero(t, buf.Len())
+}
@@ -0,0 +1,32 @@
+package daemon
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/testground/testground/pkg/api"
+	"github.com/testground/testground/pkg/logging"
+	"github.com/testground/testground/pkg/version"
+)
+
+// versionHandler advertises the versions of the daemon, and the versions of
+// the runtime API it supports, for clients and SDKs to check they're
+// compatible before submitting plans.
+func (d *Daemon) versionHandler() func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		log := logging.S().With("req_id", r.Header.Get("X-Request-ID"))
+
+		log.Debugw("handle request", "command", "version")
+		defer log.Debugw("request handled", "command", "version")
+
+		resp := api.VersionResponse{
+			Version:    version.GitCommit,
+			RuntimeAPI: api.SupportedRuntimeAPI(),
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(resp); err != nil {
+			log.Warnw("could not encode version", "err", err)
+		}
+	}
+}
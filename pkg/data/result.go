@@ -28,6 +28,10 @@ func DecodeRunnerResult(result interface{}) *runner.Result {
 func DecodeTaskOutcome(t *task.Task) (task.Outcome, error) {
 	switch t.State().State {
 	case task.StateCanceled:
+		// Runs that timed out are torn down like canceled ones.
+		if t.Type == task.TypeRun && t.Result != nil && DecodeRunnerResult(t.Result).Outcome == task.OutcomeTimedOut {
+			return task.OutcomeTimedOut, nil
+		}
 		return task.OutcomeCanceled, nil
 	case task.StateProcessing:
 		return task.OutcomeUnknown, nil
@@ -43,7 +47,7 @@ func DecodeTaskOutcome(t *task.Task) (task.Outcome, error) {
 	case task.TypeBuild:
 		// As of today a build that completed is successful. No need to check the result.
 		return task.OutcomeSuccess, nil
-	case task.TypeRun:
+	case task.TypeRun, task.TypePipeline:
 		return DecodeRunnerResult(t.Result).Outcome, nil
 	default:
 		return "", fmt.Errorf("unexpected task type: %s", t.Type)
@@ -106,6 +106,26 @@ func TestDecodeTaskOutcomeWithCanceledState(t *testing.T) {
 	assert.Nil(t, e)
 }
 
+func TestDecodeTaskOutcomeWithTimedOutRun(t *testing.T) {
+	// Run canceled after timing out => timed out outcome
+	tested := &task.Task{
+		Type: task.TypeRun,
+		States: []task.DatedState{
+			{
+				State:   task.StateCanceled,
+				Created: time.Now(),
+			},
+		},
+		Result: &runner.Result{
+			Outcome: task.OutcomeTimedOut,
+		},
+	}
+
+	r, e := DecodeTaskOutcome(tested)
+	assert.Equal(t, task.OutcomeTimedOut, r)
+	assert.Nil(t, e)
+}
+
 func TestDecodeTaskOutcomeWithLocalExecRunner(t *testing.T) {
 	// Run with local exec runner => the result is nil, we assume outcome is Success if the task suceeded.
 	tested := &task.Task{
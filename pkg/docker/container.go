@@ -116,11 +116,7 @@ func EnsureContainerStarted(ctx context.Context, ow *rpc.OutputWriter, cli *clie
 		}
 
 	case ImageStrategyPull:
-		out, err := cli.ImagePull(ctx, opts.ContainerConfig.Image, types.ImagePullOptions{})
-		if err != nil {
-			return nil, false, err
-		}
-		if _, err := PipeOutput(out, ow.StdoutWriter()); err != nil {
+		if err := PullImage(ctx, ow, cli, opts.ContainerConfig.Image); err != nil {
 			return nil, false, err
 		}
 
@@ -184,7 +180,7 @@ func DeleteContainers(cli *client.Client, ow *rpc.OutputWriter, ids []string) (e
 	for i := 0; i < len(ids); i++ {
 		if err := <-errs; err != nil {
 			ow.Errorw("failed while deleting container", "error", err)
-			merr = multierror.Append(merr, <-errs)
+			merr = multierror.Append(merr, err)
 		}
 	}
 	close(errs)
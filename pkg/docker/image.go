@@ -11,6 +11,7 @@ import (
 	"github.com/docker/docker/pkg/archive"
 
 	"github.com/testground/testground/pkg/rpc"
+	"github.com/testground/testground/pkg/tracing"
 )
 
 type BuildImageOpts struct {
@@ -35,7 +36,10 @@ func defaultBuildOptsFor(name string) *types.ImageBuildOptions {
 // When BuildImageOpts.BuildOpts has nil value, a default set of options will be constructed using
 // the Name, and the constructed options are sent to the docker client.
 // The build output is directed to stdout via PipeOutput, and also returned from this function.
-func BuildImage(ctx context.Context, ow *rpc.OutputWriter, client *client.Client, opts *BuildImageOpts) (string, error) {
+func BuildImage(ctx context.Context, ow *rpc.OutputWriter, client *client.Client, opts *BuildImageOpts) (_ string, err error) {
+	ctx, span := tracing.Start(ctx, "docker build")
+	defer func() { tracing.End(span, err) }()
+
 	buildCtx, err := archive.TarWithOptions(opts.BuildCtx, &archive.TarOptions{
 		ExcludePatterns: []string{"plan/_*", "plan.zip"},
 	})
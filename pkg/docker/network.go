@@ -2,6 +2,7 @@ package docker
 
 import (
 	"context"
+	"net"
 
 	"github.com/testground/testground/pkg/rpc"
 
@@ -11,11 +12,21 @@ import (
 	"github.com/docker/docker/client"
 )
 
+// NewBridgeNetwork creates a bridge network. IPv6 is enabled if any of the
+// IPAM configs is an IPv6 one.
 func NewBridgeNetwork(ctx context.Context, cli *client.Client, name string, internal bool, labels map[string]string, config ...network.IPAMConfig) (id string, err error) {
+	var ipv6 bool
+	for _, c := range config {
+		if ip, _, err := net.ParseCIDR(c.Subnet); err == nil && ip.To4() == nil {
+			ipv6 = true
+		}
+	}
+
 	res, err := cli.NetworkCreate(ctx, name, types.NetworkCreate{
 		Driver:     "bridge",
 		Attachable: true,
 		Internal:   internal,
+		EnableIPv6: ipv6,
 		Labels:     labels,
 		IPAM: &network.IPAM{
 			Config: config,
@@ -0,0 +1,91 @@
+package docker
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/client"
+
+	"github.com/testground/testground/pkg/rpc"
+)
+
+// offline is the offline mode of the daemon, which it sets when it starts.
+var offline struct {
+	sync.RWMutex
+	enabled  bool
+	registry string
+}
+
+// SetOffline enables or disables the offline mode of the image pulls. In
+// offline mode, images are never pulled from their origin: PullImage uses
+// them if they are present locally, and pulls them from the mirror registry
+// otherwise, if there's one.
+func SetOffline(enabled bool, registry string) {
+	offline.Lock()
+	defer offline.Unlock()
+
+	offline.enabled = enabled
+	offline.registry = strings.TrimSuffix(registry, "/")
+}
+
+// Offline tells if the image pulls are in offline mode.
+func Offline() bool {
+	offline.RLock()
+	defer offline.RUnlock()
+
+	return offline.enabled
+}
+
+// MirrorRef returns the reference of an image in a mirror registry: its
+// repository, without the registry it comes from, under the mirror, e.g.
+// registry.lan:5000/library/redis for redis.
+func MirrorRef(registry, ref string) string {
+	parts := strings.SplitN(ref, "/", 2)
+	switch {
+	case len(parts) == 1:
+		ref = "library/" + ref
+	case strings.ContainsAny(parts[0], ".:") || parts[0] == "localhost":
+		ref = parts[1]
+	}
+	return strings.TrimSuffix(registry, "/") + "/" + ref
+}
+
+// PullImage pulls an image. In offline mode, it uses the local image if
+// there's one, or pulls it from the mirror registry and tags it as ref.
+func PullImage(ctx context.Context, ow *rpc.OutputWriter, cli *client.Client, ref string) error {
+	offline.RLock()
+	enabled, registry := offline.enabled, offline.registry
+	offline.RUnlock()
+
+	if !enabled {
+		return pull(ctx, ow, cli, ref)
+	}
+
+	if _, _, err := cli.ImageInspectWithRaw(ctx, ref); err == nil {
+		return nil
+	} else if !client.IsErrNotFound(err) {
+		return err
+	}
+	if registry == "" {
+		return fmt.Errorf("image %s is not available locally, and no mirror registry is configured in offline mode; load it with `testground bundle load`", ref)
+	}
+
+	mirror := MirrorRef(registry, ref)
+	ow.Infow("offline mode: pulling image from the mirror registry", "image", ref, "mirror", mirror)
+	if err := pull(ctx, ow, cli, mirror); err != nil {
+		return fmt.Errorf("failed to pull image %s from the mirror registry: %w", mirror, err)
+	}
+	return cli.ImageTag(ctx, mirror, ref)
+}
+
+func pull(ctx context.Context, ow *rpc.OutputWriter, cli *client.Client, ref string) error {
+	out, err := cli.ImagePull(ctx, ref, types.ImagePullOptions{})
+	if err != nil {
+		return err
+	}
+	_, err = PipeOutput(out, ow.StdoutWriter())
+	return err
+}
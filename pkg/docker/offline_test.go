@@ -0,0 +1,21 @@
+package docker_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/testground/testground/pkg/docker"
+)
+
+func TestMirrorRef(t *testing.T) {
+	for ref, mirror := range map[string]string{
+		"redis":                          "registry.lan:5000/library/redis",
+		"library/influxdb:1.8":           "registry.lan:5000/library/influxdb:1.8",
+		"iptestground/sync-service:edge": "registry.lan:5000/iptestground/sync-service:edge",
+		"quay.io/org/image@sha256:abc":   "registry.lan:5000/org/image@sha256:abc",
+		"localhost/image":                "registry.lan:5000/image",
+	} {
+		require.Equal(t, mirror, docker.MirrorRef("registry.lan:5000/", ref), ref)
+	}
+}
@@ -0,0 +1,105 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/testground/testground/pkg/api"
+	"github.com/testground/testground/pkg/results"
+	"github.com/testground/testground/pkg/rpc"
+	"github.com/testground/testground/pkg/runner"
+	"github.com/testground/testground/pkg/task"
+)
+
+// checkAssertions evaluates the assertions of a completed run, and records
+// their results in the result of the run. A run whose assertions don't all
+// pass has a failure outcome.
+func (e *Engine) checkAssertions(ctx context.Context, run api.Runner, in *api.RunInput, assertions []*api.Assertion, out *api.RunOutput, ow *rpc.OutputWriter) {
+	result, ok := out.Result.(*runner.Result)
+	if !ok {
+		ow.Warnw("runner does not report a result; assertions not evaluated", "runner", run.ID())
+		return
+	}
+
+	var failed int
+	for _, a := range assertions {
+		var res *api.AssertionResult
+		if a.State != "" {
+			res = checkStateAssertion(ctx, run, in, a)
+		} else {
+			res = checkMetricAssertion(run, in, a)
+		}
+
+		if res.Passed {
+			ow.Infow("assertion passed", "assertion", res.Assertion, "observed", res.Observed)
+		} else {
+			ow.Warnw("assertion failed", "assertion", res.Assertion, "observed", res.Observed, "err", res.Error)
+			failed++
+		}
+		result.Assertions = append(result.Assertions, res)
+	}
+
+	if failed > 0 {
+		ow.Warnw("run failed its assertions", "failed", failed, "total", len(assertions))
+		result.Outcome = task.OutcomeFailure
+	}
+}
+
+func checkStateAssertion(ctx context.Context, run api.Runner, in *api.RunInput, a *api.Assertion) *api.AssertionResult {
+	res := &api.AssertionResult{Assertion: a.String()}
+
+	inspector, ok := run.(api.StateInspector)
+	if !ok {
+		res.Error = fmt.Sprintf("runner %s does not support checking states", run.ID())
+		return res
+	}
+
+	reached, err := inspector.StateReached(ctx, in, a.State)
+	if err != nil {
+		res.Error = err.Error()
+		return res
+	}
+
+	res.Passed = reached
+	return res
+}
+
+func checkMetricAssertion(run api.Runner, in *api.RunInput, a *api.Assertion) *api.AssertionResult {
+	locator, ok := run.(api.OutputsLocator)
+	if !ok {
+		return &api.AssertionResult{
+			Assertion: a.String(),
+			Error:     fmt.Sprintf("runner %s does not support reading metrics", run.ID()),
+		}
+	}
+
+	dir, err := locator.LocateOutputs(in.TestPlan, in.RunID)
+	if err != nil {
+		return &api.AssertionResult{Assertion: a.String(), Error: err.Error()}
+	}
+
+	values, err := readMetricValues(dir, a.Metric, a.MeasureName())
+	if err != nil {
+		return &api.AssertionResult{Assertion: a.String(), Error: err.Error()}
+	}
+
+	return a.EvaluateMetric(values)
+}
+
+// readMetricValues reads the values of a measure of a metric recorded by all
+// the instances of a run, i.e. its samples as defined by results.Samples: all
+// the points, and the last snapshot of every instance of cumulative metrics.
+func readMetricValues(dir string, name string, measure string) ([]float64, error) {
+	rows, err := results.ReadDir(dir, "")
+	if err != nil {
+		return nil, err
+	}
+
+	var values []float64
+	for _, r := range results.Samples(rows) {
+		if r.Source == results.SourceResults && r.Name == name && r.Measure == measure {
+			values = append(values, r.Value)
+		}
+	}
+	return values, nil
+}
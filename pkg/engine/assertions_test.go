@@ -0,0 +1,43 @@
+package engine
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadMetricValues(t *testing.T) {
+	dir, err := ioutil.TempDir("", "assertions")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	write := func(instance string, lines string) {
+		p := filepath.Join(dir, "single", instance)
+		require.NoError(t, os.MkdirAll(p, 0755))
+		require.NoError(t, ioutil.WriteFile(filepath.Join(p, "results.out"), []byte(lines), 0644))
+	}
+
+	write("0", `{"ts":1,"type":"histogram","name":"latency","measures":{"p95":100}}
+{"ts":2,"type":"histogram","name":"latency","measures":{"p95":300}}
+{"ts":2,"type":"point","name":"throughput","measures":{"value":7}}
+{"ts":3,"type":"point","name":"throughput","measures":{"value":9}}
+`)
+	write("1", `{"ts":1,"type":"histogram","name":"latency","measures":{"p95":200}}
+not json
+`)
+
+	values, err := readMetricValues(dir, "latency", "p95")
+	require.NoError(t, err)
+	require.ElementsMatch(t, []float64{300, 200}, values)
+
+	values, err = readMetricValues(dir, "throughput", "value")
+	require.NoError(t, err)
+	require.ElementsMatch(t, []float64{7, 9}, values)
+
+	values, err = readMetricValues(dir, "missing", "value")
+	require.NoError(t, err)
+	require.Empty(t, values)
+}
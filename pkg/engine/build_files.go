@@ -0,0 +1,156 @@
+package engine
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/testground/testground/pkg/api"
+	"github.com/testground/testground/pkg/rpc"
+	"github.com/testground/testground/pkg/task"
+)
+
+// keepBuildFiles copies the files of the builds of a build task into the
+// builds directory, as builders may remove them, e.g. with the sources of the
+// build. The files are named after the first group of their build in the
+// archive of the task, and checksummed.
+func (e *Engine) keepBuildFiles(id string, outs []*api.BuildOutput) ([]task.BuildFile, error) {
+	dir := filepath.Join(e.config().Dirs().Builds(), id)
+
+	var (
+		files []task.BuildFile
+		seen  = make(map[*api.BuildOutput]bool, len(outs))
+	)
+	for _, out := range outs {
+		// Groups with the same build key share their output.
+		if out == nil || seen[out] || len(out.Groups) == 0 {
+			continue
+		}
+		seen[out] = true
+
+		for _, f := range out.Files {
+			name := path.Join(out.Groups[0], filepath.ToSlash(f.Name))
+			if !strings.HasPrefix(name, out.Groups[0]+"/") {
+				return nil, fmt.Errorf("invalid name of build file %q", f.Name)
+			}
+			dst := filepath.Join(dir, filepath.FromSlash(name))
+			size, sum, err := copyBuildFile(f.Path, dst)
+			if err != nil {
+				return nil, fmt.Errorf("failed to keep build file %s: %w", f.Name, err)
+			}
+			files = append(files, task.BuildFile{
+				Name:    name,
+				Path:    dst,
+				Groups:  out.Groups,
+				Builder: out.BuilderID,
+				Size:    size,
+				SHA256:  sum,
+			})
+		}
+	}
+	return files, nil
+}
+
+// copyBuildFile copies a file with its mode, and returns its size and its
+// SHA-256 checksum.
+func copyBuildFile(src, dst string) (int64, string, error) {
+	in, err := os.Open(src)
+	if err != nil {
+		return 0, "", err
+	}
+	defer in.Close()
+
+	fi, err := in.Stat()
+	if err != nil {
+		return 0, "", err
+	}
+	if !fi.Mode().IsRegular() {
+		return 0, "", fmt.Errorf("%s is not a regular file", src)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return 0, "", err
+	}
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, fi.Mode().Perm())
+	if err != nil {
+		return 0, "", err
+	}
+	defer out.Close()
+
+	h := sha256.New()
+	n, err := io.Copy(io.MultiWriter(out, h), in)
+	if err != nil {
+		return 0, "", err
+	}
+	return n, hex.EncodeToString(h.Sum(nil)), out.Close()
+}
+
+// DoCollectBuild writes the files of the builds of a build task to the output
+// writer, as a .tgz archive with a SHA256SUMS file.
+func (e *Engine) DoCollectBuild(ctx context.Context, id string, ow *rpc.OutputWriter) error {
+	tsk, err := e.GetTask(id)
+	if err != nil {
+		return fmt.Errorf("could not get task %s: %s", id, err.Error())
+	}
+	if tsk.Type != task.TypeBuild {
+		return fmt.Errorf("task %s is not a build", id)
+	}
+	if len(tsk.BuildFiles) == 0 {
+		return fmt.Errorf("build %s produced no files", id)
+	}
+
+	gz := gzip.NewWriter(ow.BinaryWriter())
+	defer gz.Close()
+
+	tw := tar.NewWriter(gz)
+	defer tw.Close()
+
+	var sums strings.Builder
+	for _, f := range tsk.BuildFiles {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := addBuildFile(tw, f); err != nil {
+			return fmt.Errorf("failed to collect build file %s: %w", f.Name, err)
+		}
+		fmt.Fprintf(&sums, "%s  %s\n", f.SHA256, f.Name)
+	}
+
+	hdr := &tar.Header{Name: "SHA256SUMS", Mode: 0644, Size: int64(sums.Len())}
+	if err := tw.WriteHeader(hdr); err != nil {
+		return err
+	}
+	_, err = io.WriteString(tw, sums.String())
+	return err
+}
+
+func addBuildFile(tw *tar.Writer, f task.BuildFile) error {
+	file, err := os.Open(f.Path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	fi, err := file.Stat()
+	if err != nil {
+		return err
+	}
+	hdr, err := tar.FileInfoHeader(fi, "")
+	if err != nil {
+		return err
+	}
+	hdr.Name = f.Name
+	if err := tw.WriteHeader(hdr); err != nil {
+		return err
+	}
+	_, err = io.Copy(tw, file)
+	return err
+}
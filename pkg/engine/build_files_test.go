@@ -0,0 +1,92 @@
+package engine
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/testground/testground/pkg/api"
+	"github.com/testground/testground/pkg/rpc"
+	"github.com/testground/testground/pkg/task"
+)
+
+func TestKeepAndCollectBuildFiles(t *testing.T) {
+	e := newTestEngine(t, nil)
+
+	src := t.TempDir()
+	bin := filepath.Join(src, "plan")
+	require.NoError(t, ioutil.WriteFile(bin, []byte("binary"), 0755))
+	mod := filepath.Join(src, "go.mod")
+	require.NoError(t, ioutil.WriteFile(mod, []byte("module plan"), 0644))
+
+	// Both groups share the output of their build.
+	out := &api.BuildOutput{
+		BuilderID: "exec:go",
+		Groups:    []string{"a", "b"},
+		Files:     []api.BuildFile{{Name: "plan", Path: bin}, {Name: "go.mod", Path: mod}},
+	}
+
+	const id = "c3ftkqjpc98qra498sk0"
+	files, err := e.keepBuildFiles(id, []*api.BuildOutput{out, out})
+	require.NoError(t, err)
+	require.Len(t, files, 2)
+	require.Equal(t, "a/plan", files[0].Name)
+	require.Equal(t, int64(6), files[0].Size)
+	require.Equal(t, []string{"a", "b"}, files[0].Groups)
+
+	// The kept files outlive the sources of the build.
+	require.NoError(t, os.RemoveAll(src))
+
+	require.NoError(t, e.store.PersistProcessing(&task.Task{ID: id, Type: task.TypeBuild, BuildFiles: files}))
+
+	var buf bytes.Buffer
+	require.NoError(t, e.DoCollectBuild(context.Background(), id, rpc.NewBinaryOutputWriter(&buf)))
+
+	gz, err := gzip.NewReader(&buf)
+	require.NoError(t, err)
+	tr := tar.NewReader(gz)
+
+	got := make(map[string]string)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		b, err := ioutil.ReadAll(tr)
+		require.NoError(t, err)
+		got[hdr.Name] = string(b)
+	}
+	require.Equal(t, "binary", got["a/plan"])
+	require.Equal(t, "module plan", got["a/go.mod"])
+	require.Equal(t, files[0].SHA256+"  a/plan\n"+files[1].SHA256+"  a/go.mod\n", got["SHA256SUMS"])
+
+	require.NoError(t, e.DeleteTask(id))
+	_, err = os.Stat(files[0].Path)
+	require.True(t, os.IsNotExist(err))
+}
+
+func TestCollectBuildWithoutFiles(t *testing.T) {
+	e := newTestEngine(t, nil)
+
+	require.NoError(t, e.store.PersistProcessing(&task.Task{ID: "c3ftkqjpc98qra498sk1", Type: task.TypeBuild}))
+	require.Error(t, e.DoCollectBuild(context.Background(), "c3ftkqjpc98qra498sk1", rpc.Discard()))
+
+	require.NoError(t, e.store.PersistProcessing(&task.Task{ID: "c3ftkqjpc98qra498sk2", Type: task.TypeRun}))
+	require.Error(t, e.DoCollectBuild(context.Background(), "c3ftkqjpc98qra498sk2", rpc.Discard()))
+
+	// File names can't escape the directory of their group.
+	_, err := e.keepBuildFiles("c3ftkqjpc98qra498sk3", []*api.BuildOutput{{
+		Groups: []string{"a"},
+		Files:  []api.BuildFile{{Name: "../b/plan", Path: "plan"}},
+	}})
+	require.Error(t, err)
+}
@@ -0,0 +1,137 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/testground/testground/pkg/api"
+	"github.com/testground/testground/pkg/logging"
+	"github.com/testground/testground/pkg/metrics"
+	"github.com/testground/testground/pkg/rpc"
+	"github.com/testground/testground/pkg/runner"
+	"github.com/testground/testground/pkg/task"
+)
+
+// ErrDraining is returned when queueing a task while the engine is draining.
+var ErrDraining = errors.New("the daemon is draining and accepts no new tasks")
+
+// Cancel cancels a task on behalf of a user. A queued task is removed from
+// the queue right away. A running task has its context canceled, which stops
+// its builder or runner; the runner tears down the resources of the run
+// before the task completes as canceled.
+func (e *Engine) Cancel(id string, by string) error {
+	reason := "canceled"
+	if by != "" {
+		reason = "canceled by " + by
+	}
+
+	ok, err := e.queue.Cancel(id, reason)
+	if err != nil {
+		return err
+	}
+	if ok {
+		metrics.TasksQueued.Set(float64(e.queue.Len()))
+		logging.S().Infow("canceled queued task", "task_id", id, "by", by)
+		return nil
+	}
+
+	e.signalsLk.RLock()
+	_, running := e.signals[id]
+	e.signalsLk.RUnlock()
+	if !running {
+		return fmt.Errorf("task %s is neither queued nor running", id)
+	}
+
+	e.canceledLk.Lock()
+	e.canceled[id] = reason
+	e.canceledLk.Unlock()
+
+	logging.S().Infow("canceling running task", "task_id", id, "by", by)
+	return e.Kill(id)
+}
+
+// takeCanceled returns the reason a task was canceled, if it was canceled
+// with Cancel, and forgets about it.
+func (e *Engine) takeCanceled(id string) (string, bool) {
+	e.canceledLk.Lock()
+	defer e.canceledLk.Unlock()
+
+	reason, ok := e.canceled[id]
+	delete(e.canceled, id)
+	return reason, ok
+}
+
+// isDraining returns true if the engine accepts no new tasks.
+func (e *Engine) isDraining() bool {
+	e.runningLk.Lock()
+	defer e.runningLk.Unlock()
+
+	return e.draining
+}
+
+// DoDrain stops the engine from accepting and picking tasks, and waits for
+// the tasks being processed to complete, so that the daemon can be shut down
+// without interrupting them. The runs of the pipelines being processed are
+// still picked, for the pipelines to complete. Other queued tasks stay
+// queued, and are picked again once the daemon restarts, or once the engine
+// is resumed.
+func (e *Engine) DoDrain(ctx context.Context, ow *rpc.OutputWriter) error {
+	e.runningLk.Lock()
+	e.draining = true
+	e.runningLk.Unlock()
+
+	ow.Infow("draining, no new tasks are accepted", "queued", e.queue.Len())
+
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	last := [2]int{-1, -1}
+	for {
+		e.runningLk.Lock()
+		inflight, pipelines := e.inflight, len(e.pipelines)
+		e.runningLk.Unlock()
+
+		if inflight == 0 && pipelines == 0 {
+			ow.Infow("drained, the daemon can be shut down", "queued", e.queue.Len())
+			return nil
+		}
+		if now := [2]int{inflight, pipelines}; now != last {
+			ow.Infow("waiting for tasks to complete", "running", inflight, "pipelines", pipelines)
+			last = now
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// Resume accepts and picks tasks again after DoDrain.
+func (e *Engine) Resume() {
+	e.runningLk.Lock()
+	e.draining = false
+	e.runningLk.Unlock()
+
+	logging.S().Infow("resumed, accepting new tasks")
+}
+
+// markCanceled records a task canceled with Cancel as such, whatever its
+// builder or runner returned.
+func markCanceled(tsk *task.Task, state *task.DatedState, result interface{}, reason string) {
+	state.State = task.StateCanceled
+	if tsk.Error == "" {
+		tsk.Error = reason
+	} else {
+		tsk.Error = reason + ": " + tsk.Error
+	}
+	switch res := result.(type) {
+	case *runner.Result:
+		res.Outcome = task.OutcomeCanceled
+	case *api.PipelineResult:
+		res.Outcome = task.OutcomeCanceled
+	}
+}
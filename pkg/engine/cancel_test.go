@@ -0,0 +1,119 @@
+package engine
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/testground/testground/pkg/api"
+	"github.com/testground/testground/pkg/rpc"
+	"github.com/testground/testground/pkg/runner"
+	"github.com/testground/testground/pkg/task"
+)
+
+func TestCancel(t *testing.T) {
+	e := newTestEngine(t, nil)
+
+	queued := &task.Task{
+		ID:     "c3ftkqjpc98qra498sg0",
+		Type:   task.TypeBuild,
+		States: []task.DatedState{{State: task.StateScheduled, Created: time.Now().UTC()}},
+	}
+	require.NoError(t, e.queue.Push(queued))
+
+	require.NoError(t, e.Cancel(queued.ID, "alice"))
+	require.Equal(t, 0, e.queue.Len())
+
+	tsk, err := e.GetTask(queued.ID)
+	require.NoError(t, err)
+	require.Equal(t, task.StateCanceled, tsk.State().State)
+	require.Equal(t, "canceled by alice", tsk.Error)
+
+	// Running tasks have their context canceled.
+	ch := make(chan int)
+	e.addSignal("c3ftkqjpc98qra498sh0", ch)
+	require.NoError(t, e.Cancel("c3ftkqjpc98qra498sh0", "alice"))
+	select {
+	case <-ch:
+	default:
+		t.Fatal("running task not killed")
+	}
+
+	reason, ok := e.takeCanceled("c3ftkqjpc98qra498sh0")
+	require.True(t, ok)
+	require.Equal(t, "canceled by alice", reason)
+
+	require.Error(t, e.Cancel("c3ftkqjpc98qra498si0", "alice"))
+}
+
+func TestMarkCanceled(t *testing.T) {
+	tsk := &task.Task{Error: "context canceled"}
+	state := task.DatedState{State: task.StateComplete}
+	result := &runner.Result{Outcome: task.OutcomeFailure}
+
+	markCanceled(tsk, &state, result, "canceled by alice")
+	require.Equal(t, task.StateCanceled, state.State)
+	require.Equal(t, "canceled by alice: context canceled", tsk.Error)
+	require.Equal(t, task.OutcomeCanceled, result.Outcome)
+}
+
+func TestDrain(t *testing.T) {
+	e := newTestEngine(t, nil)
+
+	for _, id := range []string{"c3ftkqjpc98qra498sg0", "c3ftkqjpc98qra498sh0"} {
+		require.NoError(t, e.queue.Push(&task.Task{
+			ID:     id,
+			Type:   task.TypeBuild,
+			States: []task.DatedState{{State: task.StateScheduled, Created: time.Now().UTC()}},
+		}))
+	}
+
+	running, err := e.popTask()
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	require.Equal(t, context.DeadlineExceeded, e.DoDrain(ctx, rpc.Discard()))
+
+	// No task is accepted or picked while draining.
+	_, err = e.QueueBuild(&api.BuildRequest{}, nil)
+	require.Equal(t, ErrDraining, err)
+	_, err = e.popTask()
+	require.Equal(t, task.ErrQueueEmpty, err)
+
+	e.releaseSlot(running)
+
+	// The runs of a pipeline being processed are still picked, and the
+	// drain waits for the pipeline to complete.
+	pipeline := "c3ftkqjpc98qra498si0"
+	e.runningLk.Lock()
+	e.pipelines[pipeline] = struct{}{}
+	e.runningLk.Unlock()
+	require.NoError(t, e.queue.Push(&task.Task{
+		ID:     "c3ftkqjpc98qra498sj0",
+		Type:   task.TypeRun,
+		Input:  &RunInput{RunRequest: &api.RunRequest{Pipeline: pipeline}},
+		States: []task.DatedState{{State: task.StateScheduled, Created: time.Now().UTC()}},
+	}))
+	running, err = e.popTask()
+	require.NoError(t, err)
+	require.Equal(t, "c3ftkqjpc98qra498sj0", running.ID)
+	e.releaseSlot(running)
+
+	ctx, cancel = context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	require.Equal(t, context.DeadlineExceeded, e.DoDrain(ctx, rpc.Discard()))
+
+	e.runningLk.Lock()
+	delete(e.pipelines, pipeline)
+	e.runningLk.Unlock()
+	require.NoError(t, e.DoDrain(context.Background(), rpc.Discard()))
+	require.Equal(t, 1, e.queue.Len())
+
+	e.Resume()
+	tsk, err := e.popTask()
+	require.NoError(t, err)
+	require.Equal(t, "c3ftkqjpc98qra498sh0", tsk.ID)
+}
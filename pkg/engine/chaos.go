@@ -0,0 +1,262 @@
+package engine
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	client "github.com/influxdata/influxdb1-client/v2"
+
+	"github.com/testground/testground/pkg/api"
+	"github.com/testground/testground/pkg/rpc"
+)
+
+// ChaosTimelineFile is the name of the file, at the root of the run outputs,
+// holding the chaos actions taken during a run.
+const ChaosTimelineFile = "chaos_timeline.json"
+
+// chaosMeasurement is the InfluxDB measurement chaos actions are recorded in,
+// next to the metrics of the run, so that they can be correlated.
+const chaosMeasurement = "testground.chaos"
+
+// chaosController disrupts the instances of a live run, as scheduled by the
+// chaos rules of the run.
+type chaosController struct {
+	runID    string
+	rules    []*api.ChaosRule
+	injector api.ChaosInjector
+	registry api.InstanceRegistry
+	ow       *rpc.OutputWriter
+
+	lk     sync.Mutex
+	rnd    *rand.Rand
+	events []*api.ChaosEvent
+
+	wg sync.WaitGroup
+}
+
+func newChaosController(run api.Runner, runID string, rules []*api.ChaosRule, ow *rpc.OutputWriter) (*chaosController, error) {
+	injector, ok := run.(api.ChaosInjector)
+	if !ok {
+		return nil, fmt.Errorf("runner %s does not support chaos rules", run.ID())
+	}
+	registry, ok := run.(api.InstanceRegistry)
+	if !ok {
+		return nil, fmt.Errorf("runner %s does not support chaos rules: it cannot list instances", run.ID())
+	}
+
+	return &chaosController{
+		runID:    runID,
+		rules:    rules,
+		injector: injector,
+		registry: registry,
+		ow:       ow,
+		rnd:      rand.New(rand.NewSource(time.Now().UnixNano())),
+	}, nil
+}
+
+// start schedules the disruptions, until the context is done.
+func (c *chaosController) start(ctx context.Context) {
+	for i, rule := range c.rules {
+		c.wg.Add(1)
+		go c.schedule(ctx, i, rule)
+	}
+}
+
+// stop waits for the scheduled disruptions to stop, once the context passed
+// to start is done, and returns the events of the run.
+func (c *chaosController) stop() []*api.ChaosEvent {
+	c.wg.Wait()
+
+	c.lk.Lock()
+	defer c.lk.Unlock()
+
+	sort.SliceStable(c.events, func(i, j int) bool { return c.events[i].Time.Before(c.events[j].Time) })
+	return c.events
+}
+
+func (c *chaosController) schedule(ctx context.Context, idx int, rule *api.ChaosRule) {
+	defer c.wg.Done()
+
+	every, startAfter, restoreAfter := rule.Schedule()
+
+	timer := time.NewTimer(startAfter)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			return
+		}
+		c.disrupt(ctx, idx, rule, restoreAfter)
+		timer.Reset(every)
+	}
+}
+
+// disrupt applies the action of a rule to a random selection of the running
+// instances of its group, and schedules their restore.
+func (c *chaosController) disrupt(ctx context.Context, idx int, rule *api.ChaosRule, restoreAfter time.Duration) {
+	instances, err := c.registry.ListInstances(ctx, c.runID)
+	if err != nil {
+		c.ow.Warnw("chaos: failed to list instances", "rule", idx, "err", err)
+		return
+	}
+
+	targets := c.pickTargets(instances, rule)
+	if len(targets) == 0 {
+		c.ow.Infow("chaos: no running instances to disrupt", "rule", idx, "group", rule.Group)
+		return
+	}
+
+	restore := restoreAfter > 0 && rule.Action != api.ChaosRestart
+	for _, inst := range targets {
+		if err := c.apply(ctx, idx, inst, rule.Action, restore); err != nil || !restore {
+			continue
+		}
+
+		inst := inst
+		c.wg.Add(1)
+		go func() {
+			defer c.wg.Done()
+			select {
+			case <-time.After(restoreAfter):
+			case <-ctx.Done():
+				return
+			}
+			action := api.ChaosStart
+			if rule.Action == api.ChaosPause {
+				action = api.ChaosResume
+			}
+			_ = c.apply(ctx, idx, inst, action, false)
+		}()
+	}
+}
+
+// pickTargets picks the instances a rule disrupts among the running instances
+// of its group.
+func (c *chaosController) pickTargets(instances []*api.Instance, rule *api.ChaosRule) []*api.Instance {
+	var running []*api.Instance
+	for _, inst := range instances {
+		if inst.GroupID == rule.Group && inst.State == "running" {
+			running = append(running, inst)
+		}
+	}
+
+	c.lk.Lock()
+	c.rnd.Shuffle(len(running), func(i, j int) { running[i], running[j] = running[j], running[i] })
+	c.lk.Unlock()
+
+	return running[:rule.Targets(len(running))]
+}
+
+// apply applies an action to an instance, and records it.
+func (c *chaosController) apply(ctx context.Context, idx int, inst *api.Instance, action api.ChaosAction, restore bool) error {
+	err := c.injector.InjectChaos(ctx, &api.ChaosInput{
+		RunID:    c.runID,
+		Instance: inst,
+		Action:   action,
+		Restore:  restore,
+	})
+
+	ev := &api.ChaosEvent{
+		Time:     time.Now().UTC(),
+		Rule:     idx,
+		Action:   action,
+		Group:    inst.GroupID,
+		Instance: inst.Name,
+	}
+	if err != nil {
+		ev.Error = err.Error()
+		c.ow.Warnw("chaos: action failed", "rule", idx, "action", action, "instance", inst.Name, "err", err)
+	} else {
+		c.ow.Infow("chaos: action applied", "rule", idx, "action", action, "instance", inst.Name)
+	}
+
+	c.lk.Lock()
+	c.events = append(c.events, ev)
+	c.lk.Unlock()
+
+	return err
+}
+
+// recordChaos writes the chaos events of a run to its outputs, and to
+// InfluxDB next to its metrics.
+func (e *Engine) recordChaos(run api.Runner, in *api.RunInput, events []*api.ChaosEvent, ow *rpc.OutputWriter) {
+	if locator, ok := run.(api.OutputsLocator); ok {
+		dir, err := locator.LocateOutputs(in.TestPlan, in.RunID)
+		if err == nil {
+			err = writeChaosTimeline(filepath.Join(dir, ChaosTimelineFile), events)
+		}
+		if err != nil {
+			ow.Warnw("failed to write chaos timeline", "err", err)
+		}
+	}
+
+	if len(events) == 0 || e.config().Daemon.InfluxDBEndpoint == "" {
+		return
+	}
+	if err := pushChaosEvents(e.config().Daemon.InfluxDBEndpoint, in.RunID, events); err != nil {
+		ow.Warnw("failed to record chaos events in influxdb", "err", err)
+	}
+}
+
+func writeChaosTimeline(path string, events []*api.ChaosEvent) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if events == nil {
+		events = []*api.ChaosEvent{}
+	}
+	enc := json.NewEncoder(f)
+	enc.SetIndent("", "  ")
+	return enc.Encode(events)
+}
+
+// pushChaosEvents writes the chaos events of a run to the testground
+// database, one point per event, tagged like the metrics of the run.
+func pushChaosEvents(addr string, runID string, events []*api.ChaosEvent) error {
+	cl, err := client.NewHTTPClient(client.HTTPConfig{Addr: addr})
+	if err != nil {
+		return err
+	}
+	defer cl.Close()
+
+	bp, err := client.NewBatchPoints(client.BatchPointsConfig{Database: "testground", Precision: "ns"})
+	if err != nil {
+		return err
+	}
+
+	for _, ev := range events {
+		tags := map[string]string{
+			"run":      runID,
+			"group_id": ev.Group,
+			"instance": ev.Instance,
+			"action":   string(ev.Action),
+		}
+		fields := map[string]interface{}{
+			"rule":   ev.Rule,
+			"failed": ev.Error != "",
+		}
+		p, err := client.NewPoint(chaosMeasurement, tags, fields, ev.Time)
+		if err != nil {
+			return err
+		}
+		bp.AddPoint(p)
+	}
+
+	return cl.Write(bp)
+}
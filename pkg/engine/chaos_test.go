@@ -0,0 +1,56 @@
+package engine
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/testground/testground/pkg/api"
+	"github.com/testground/testground/pkg/rpc"
+)
+
+func TestChaosController(t *testing.T) {
+	run := &fakeRunner{instances: []*api.Instance{
+		{Name: "a-0", GroupID: "a", State: "running"},
+		{Name: "a-1", GroupID: "a", State: "running"},
+		{Name: "a-2", GroupID: "a", State: "paused"},
+		{Name: "b-0", GroupID: "b", State: "running"},
+	}}
+	rules := []*api.ChaosRule{{
+		Group:        "a",
+		Action:       api.ChaosKill,
+		Count:        1,
+		Every:        "1h",
+		StartAfter:   "0s",
+		RestoreAfter: "10ms",
+	}}
+
+	c, err := newChaosController(run, "run", rules, rpc.Discard())
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	c.start(ctx)
+	require.Eventually(t, func() bool {
+		run.lk.Lock()
+		defer run.lk.Unlock()
+		return len(run.inputs) == 2
+	}, time.Second, 5*time.Millisecond)
+	cancel()
+
+	events := c.stop()
+	require.Len(t, events, 2)
+	require.Equal(t, api.ChaosKill, events[0].Action)
+	require.Equal(t, api.ChaosStart, events[1].Action)
+	require.Equal(t, events[0].Instance, events[1].Instance)
+	require.Contains(t, []string{"a-0", "a-1"}, events[0].Instance)
+
+	require.True(t, run.inputs[0].Restore)
+	require.False(t, run.inputs[1].Restore)
+}
+
+func TestChaosControllerUnsupported(t *testing.T) {
+	_, err := newChaosController(plainRunner(&fakeRunner{}), "run", []*api.ChaosRule{{Group: "a"}}, rpc.Discard())
+	require.Error(t, err)
+}
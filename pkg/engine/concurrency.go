@@ -0,0 +1,77 @@
+package engine
+
+import (
+	"github.com/testground/testground/pkg/task"
+)
+
+// popTask pops the next task that can be processed right away, i.e. whose
+// runner isn't at its concurrency limit, and takes a slot of its runner. The
+// slot must be released with releaseSlot once the task is processed. While
+// the engine is draining, only the runs of the pipelines being processed are
+// popped, for the pipelines to complete.
+func (e *Engine) popTask() (*task.Task, error) {
+	e.runningLk.Lock()
+	defer e.runningLk.Unlock()
+
+	tsk, err := e.queue.PopFunc(func(tsk *task.Task) bool {
+		return (!e.draining || e.inPipelineLocked(tsk)) && e.hasSlotLocked(tsk)
+	})
+	if err != nil {
+		return nil, err
+	}
+	e.inflight++
+	if tsk.Type == task.TypeRun {
+		e.running[tsk.Runner]++
+	}
+	return tsk, nil
+}
+
+func (e *Engine) releaseSlot(tsk *task.Task) {
+	e.runningLk.Lock()
+	defer e.runningLk.Unlock()
+
+	e.inflight--
+	if tsk.Type != task.TypeRun {
+		return
+	}
+	if e.running[tsk.Runner]--; e.running[tsk.Runner] <= 0 {
+		delete(e.running, tsk.Runner)
+	}
+}
+
+// inPipelineLocked returns true if a task is a run of a pipeline being
+// processed.
+func (e *Engine) inPipelineLocked(tsk *task.Task) bool {
+	input, ok := tsk.Input.(*RunInput)
+	if !ok || input.RunRequest == nil || input.Pipeline == "" {
+		return false
+	}
+	_, ok = e.pipelines[input.Pipeline]
+	return ok
+}
+
+// hasSlot returns true if a task can be processed as far as the concurrency
+// limit of its runner is concerned. Only runs are limited.
+func (e *Engine) hasSlot(tsk *task.Task) bool {
+	e.runningLk.Lock()
+	defer e.runningLk.Unlock()
+
+	return e.hasSlotLocked(tsk)
+}
+
+func (e *Engine) hasSlotLocked(tsk *task.Task) bool {
+	if tsk.Type != task.TypeRun {
+		return true
+	}
+	limit := e.config().Daemon.Scheduler.RunnerConcurrency[tsk.Runner]
+	return limit <= 0 || e.running[tsk.Runner] < limit
+}
+
+// runnerUsage returns the runs a runner is processing, and its limit, or
+// zero if it has none.
+func (e *Engine) runnerUsage(runner string) (int, int) {
+	e.runningLk.Lock()
+	defer e.runningLk.Unlock()
+
+	return e.running[runner], e.config().Daemon.Scheduler.RunnerConcurrency[runner]
+}
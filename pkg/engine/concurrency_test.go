@@ -0,0 +1,56 @@
+package engine
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/testground/testground/pkg/config"
+	"github.com/testground/testground/pkg/task"
+)
+
+func TestRunnerConcurrency(t *testing.T) {
+	e := newTestEngine(t, func(envcfg *config.EnvConfig) {
+		envcfg.Daemon.Scheduler.RunnerConcurrency = map[string]int{"local:docker": 1}
+	})
+
+	now := time.Now().UTC()
+	for i, tsk := range []*task.Task{
+		{ID: "c3ftkqjpc98qra498sg0", Type: task.TypeRun, Runner: "local:docker"},
+		{ID: "c3ftkqjpc98qra498sh0", Type: task.TypeRun, Runner: "local:docker"},
+		{ID: "c3ftkqjpc98qra498si0", Type: task.TypeRun, Runner: "cluster:k8s"},
+		{ID: "c3ftkqjpc98qra498sj0", Type: task.TypeBuild},
+	} {
+		tsk.States = []task.DatedState{{State: task.StateScheduled, Created: now.Add(time.Duration(i) * time.Second)}}
+		require.NoError(t, e.queue.Push(tsk))
+	}
+
+	first, err := e.popTask()
+	require.NoError(t, err)
+	require.Equal(t, "c3ftkqjpc98qra498sg0", first.ID)
+
+	// The second local:docker run waits for the first one; the others don't.
+	tsk, err := e.popTask()
+	require.NoError(t, err)
+	require.Equal(t, "c3ftkqjpc98qra498si0", tsk.ID)
+
+	tsk, err = e.popTask()
+	require.NoError(t, err)
+	require.Equal(t, "c3ftkqjpc98qra498sj0", tsk.ID)
+
+	_, err = e.popTask()
+	require.Equal(t, task.ErrQueueEmpty, err)
+
+	ex, err := e.DoExplain(context.Background(), "c3ftkqjpc98qra498sh0")
+	require.NoError(t, err)
+	require.Equal(t, "local:docker", ex.Queue.Runner)
+	require.Equal(t, 1, ex.Queue.RunnerRunning)
+	require.Equal(t, 1, ex.Queue.RunnerLimit)
+
+	e.releaseSlot(first)
+	tsk, err = e.popTask()
+	require.NoError(t, err)
+	require.Equal(t, "c3ftkqjpc98qra498sh0", tsk.ID)
+}
@@ -15,9 +15,14 @@ import (
 	"github.com/testground/testground/pkg/api"
 	"github.com/testground/testground/pkg/build"
 	"github.com/testground/testground/pkg/config"
+	"github.com/testground/testground/pkg/docker"
+	"github.com/testground/testground/pkg/grafana"
 	"github.com/testground/testground/pkg/logging"
+	"github.com/testground/testground/pkg/metrics"
+	"github.com/testground/testground/pkg/notify"
 	"github.com/testground/testground/pkg/rpc"
 	"github.com/testground/testground/pkg/runner"
+	"github.com/testground/testground/pkg/storage"
 	"github.com/testground/testground/pkg/task"
 )
 
@@ -52,14 +57,45 @@ type Engine struct {
 	builders map[string]api.Builder
 	// runners binds runners to their identifying key.
 	runners map[string]api.Runner
-	envcfg  *config.EnvConfig
-	ctx     context.Context
-	store   *task.Storage
-	queue   *task.Queue
+	// envcfg is the env config of the daemon, replaced as a whole when it's
+	// reloaded, and the notifier with it; both are guarded by cfglk.
+	envcfg *config.EnvConfig
+	cfglk  sync.RWMutex
+	ctx    context.Context
+	store  *task.Storage
+	queue  *task.Queue
 	// signals contains a channel for each running task
 	// by closing a channel, the task is canceled
 	signals   map[string]chan int
 	signalsLk sync.RWMutex
+	// decisions contains the decision log of each running task.
+	decisions   map[string]*api.DecisionLog
+	decisionsLk sync.RWMutex
+	// outputs is the backend the outputs of runs are uploaded to, if any.
+	outputs storage.Backend
+	// preempted maps the runs being preempted to the tasks preempting them.
+	preempted   map[string]string
+	preemptedLk sync.Mutex
+	// running counts the runs being processed by each runner, to enforce
+	// the concurrency limits of runners. inflight counts all the tasks being
+	// processed by the workers, and pipelines holds the pipelines being
+	// processed, which take no worker. While draining, only the runs of those
+	// pipelines are picked; all are guarded by runningLk.
+	running   map[string]int
+	inflight  int
+	pipelines map[string]struct{}
+	draining  bool
+	runningLk sync.Mutex
+	// canceled maps the tasks being canceled to the users canceling them.
+	canceled   map[string]string
+	canceledLk sync.Mutex
+	// notifier posts the outcome of completed tasks, if configured.
+	notifier *notify.Notifier
+	// grafana provisions the dashboards of runs, if an observability stack
+	// is configured.
+	grafana *grafana.Client
+	// events streams the lifecycle events of tasks to their subscribers.
+	events *eventBus
 }
 
 var _ api.Engine = (*Engine)(nil)
@@ -99,6 +135,13 @@ func NewEngine(cfg *EngineConfig) (*Engine, error) {
 		return nil, err
 	}
 
+	outputs, err := storage.New(cfg.EnvConfig.Daemon.Outputs, cfg.EnvConfig.AWS)
+	if err != nil {
+		return nil, err
+	}
+
+	notifications := applyOffline(cfg.EnvConfig)
+
 	e := &Engine{
 		builders: make(map[string]api.Builder, len(cfg.Builders)),
 		runners:  make(map[string]api.Runner, len(cfg.Runners)),
@@ -107,8 +150,20 @@ func NewEngine(cfg *EngineConfig) (*Engine, error) {
 		store:    store,
 		queue:    queue,
 		signals:  make(map[string]chan int),
+
+		decisions: make(map[string]*api.DecisionLog),
+		outputs:   outputs,
+		preempted: make(map[string]string),
+		running:   make(map[string]int),
+		pipelines: make(map[string]struct{}),
+		canceled:  make(map[string]string),
+		notifier:  notify.New(notifications),
+		grafana:   grafana.New(cfg.EnvConfig.Daemon.Observability),
+		events:    newEventBus(),
 	}
 
+	metrics.TasksQueued.Set(float64(queue.Len()))
+
 	for _, b := range cfg.Builders {
 		e.builders[b.ID()] = b
 	}
@@ -117,10 +172,22 @@ func NewEngine(cfg *EngineConfig) (*Engine, error) {
 		e.runners[r.ID()] = r
 	}
 
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	e.recoverInterrupted(ctx)
+	cancel()
+
 	for i := 0; i < cfg.EnvConfig.Daemon.Scheduler.Workers; i++ {
 		go e.worker(i)
 	}
 
+	if m := cfg.EnvConfig.Daemon.Retention.IntervalMin; m > 0 {
+		go e.gcLoop(time.Duration(m) * time.Minute)
+	}
+
+	if e.grafana != nil {
+		go e.provisionObservability()
+	}
+
 	return e, nil
 }
 
@@ -178,9 +245,17 @@ func (e *Engine) ListRunners() map[string]api.Runner {
 }
 
 func (e *Engine) QueueBuild(request *api.BuildRequest, sources *api.UnpackedSources) (string, error) {
+	if e.isDraining() {
+		return "", ErrDraining
+	}
+
+	if err := e.checkEnabled(request.Composition.ListBuilders(), ""); err != nil {
+		return "", err
+	}
+
 	id := xid.New().String()
-	err := e.queue.Push(&task.Task{
-		Version:  0,
+	tsk := &task.Task{
+		Version:  task.CurrentVersion,
 		Priority: request.Priority,
 		ID:       id,
 		Type:     task.TypeBuild,
@@ -194,13 +269,23 @@ func (e *Engine) QueueBuild(request *api.BuildRequest, sources *api.UnpackedSour
 				Created: time.Now().UTC(),
 			},
 		},
-		CreatedBy: task.CreatedBy(request.CreatedBy),
-	})
+		CreatedBy:  task.CreatedBy(request.CreatedBy),
+		PlanSource: (*task.PlanSource)(request.PlanSource),
+	}
+	err := e.queue.Push(tsk)
+	metrics.TasksQueued.Set(float64(e.queue.Len()))
+	if err == nil {
+		e.publishQueued(tsk)
+	}
 
 	return id, err
 }
 
 func (e *Engine) QueueRun(request *api.RunRequest, sources *api.UnpackedSources) (string, error) {
+	if e.isDraining() {
+		return "", ErrDraining
+	}
+
 	var (
 		builders = request.Composition.ListBuilders()
 		runner   = request.Composition.Global.Runner
@@ -219,15 +304,49 @@ func (e *Engine) QueueRun(request *api.RunRequest, sources *api.UnpackedSources)
 		}
 	}
 
+	if err := request.Composition.ValidateForRunner(run); err != nil {
+		return "", err
+	}
+
+	if err := e.checkEnabled(builders, runner); err != nil {
+		return "", err
+	}
+
+	// Fail before building if the plan targets a runtime API this daemon
+	// doesn't support.
+	if _, err := request.Manifest.RuntimeAPI(); err != nil {
+		return "", err
+	}
+
+	// Inject the env vars into the groups, so that the task records the
+	// composition they run with.
+	if err := request.Composition.InjectEnv(request.Env); err != nil {
+		return "", err
+	}
+
+	// Requests for several runs, e.g. the runs of a sweep, are processed as
+	// pipelines.
+	if err := planRuns(request); err != nil {
+		return "", err
+	}
+	if len(request.RunIds) > 1 {
+		return e.queuePipeline(request, sources)
+	}
+
+	return e.queueRun(request, sources)
+}
+
+// queueRun queues the task of a single run.
+func (e *Engine) queueRun(request *api.RunRequest, sources *api.UnpackedSources) (string, error) {
 	id := xid.New().String()
 	cby := task.CreatedBy(request.CreatedBy)
 	newTask := &task.Task{
-		Version:     0,
+		Version:     task.CurrentVersion,
 		Priority:    request.Priority,
 		Plan:        request.Composition.Global.Plan,
 		Case:        request.Composition.Global.Case,
 		ID:          id,
-		Runner:      runner,
+		Runner:      request.Composition.Global.Runner,
 		Type:        task.TypeRun,
 		Composition: request.Composition,
 		Input: &RunInput{
@@ -240,10 +359,16 @@ func (e *Engine) QueueRun(request *api.RunRequest, sources *api.UnpackedSources)
 				Created: time.Now().UTC(),
 			},
 		},
-		CreatedBy: cby,
+		CreatedBy:  cby,
+		PlanSource: (*task.PlanSource)(request.PlanSource),
 	}
 
 	err := e.queue.PushUniqueByBranch(newTask)
+	metrics.TasksQueued.Set(float64(e.queue.Len()))
+	if err == nil {
+		e.publishQueued(newTask)
+		e.preemptFor(newTask)
+	}
 
 	return id, err
 }
@@ -254,6 +379,12 @@ func (e *Engine) DoCollectOutputs(ctx context.Context, runID string, ow *rpc.Out
 		return fmt.Errorf("could not get task %s: %s", runID, err.Error())
 	}
 
+	if e.outputs != nil {
+		if ok, err := e.collectStoredOutputs(ctx, runID, ow); ok || err != nil {
+			return err
+		}
+	}
+
 	runner := t.Runner
 	run, ok := e.runners[runner]
 	if !ok {
@@ -263,7 +394,7 @@ func (e *Engine) DoCollectOutputs(ctx context.Context, runID string, ow *rpc.Out
 	var cfg config.CoalescedConfig
 
 	// Get the env config for the runner.
-	cfg = cfg.Append(e.envcfg.Runners[runner])
+	cfg = cfg.Append(e.config().Runners[runner])
 
 	// Coalesce all configurations and deserialize into the config type
 	// mandated by the builder.
@@ -275,13 +406,37 @@ func (e *Engine) DoCollectOutputs(ctx context.Context, runID string, ow *rpc.Out
 	input := &api.CollectionInput{
 		RunnerID:     runner,
 		RunID:        runID,
-		EnvConfig:    *e.envcfg,
+		EnvConfig:    *e.config(),
 		RunnerConfig: obj,
 	}
 
 	return run.CollectOutputs(ctx, input, ow)
 }
 
+// DoListInstances lists the live instances of a run, if its runner supports it.
+func (e *Engine) DoListInstances(ctx context.Context, runID string) ([]*api.Instance, error) {
+	t, err := e.GetTask(runID)
+	if err != nil {
+		return nil, fmt.Errorf("could not get task %s: %s", runID, err.Error())
+	}
+
+	if t.Type != task.TypeRun {
+		return nil, fmt.Errorf("task %s is not a run", runID)
+	}
+
+	run, ok := e.runners[t.Runner]
+	if !ok {
+		return nil, fmt.Errorf("unknown runner: %s", t.Runner)
+	}
+
+	registry, ok := run.(api.InstanceRegistry)
+	if !ok {
+		return nil, fmt.Errorf("runner %s does not support listing instances", t.Runner)
+	}
+
+	return registry.ListInstances(ctx, runID)
+}
+
 func (e *Engine) DoTerminate(ctx context.Context, ctype api.ComponentType, ref string, ow *rpc.OutputWriter) error {
 	var component interface{}
 	var ok bool
@@ -336,9 +491,41 @@ func (e *Engine) DoBuildPurge(ctx context.Context, builder, plan string, ow *rpc
 	return bm.Purge(ctx, plan, ow)
 }
 
+// applyOffline sets the offline mode of the daemon, as configured, and returns
+// the notifications config, without the webhooks that are never on the LAN
+// in offline mode.
+func applyOffline(cfg *config.EnvConfig) config.NotificationsConfig {
+	notifications := cfg.Daemon.Notifications
+	if notifications.Slack == "" {
+		notifications.Slack = cfg.Daemon.SlackWebhookURL
+	}
+
+	// In offline mode, images are never pulled from their origin, and
+	// nothing is posted to Slack or Discord, which are never on the LAN.
+	offline := cfg.Daemon.Offline
+	docker.SetOffline(offline.Enabled, offline.Registry)
+	if offline.Enabled {
+		logging.S().Infow("offline mode enabled", "registry", offline.Registry)
+		if notifications.Slack != "" || notifications.Discord != "" {
+			logging.S().Warnw("offline mode: slack and discord notifications are disabled")
+		}
+		notifications.Slack, notifications.Discord = "", ""
+	}
+	return notifications
+}
+
+// config returns the current env config of the daemon. It must not be
+// modified; reloads replace it.
+func (e *Engine) config() *config.EnvConfig {
+	e.cfglk.RLock()
+	defer e.cfglk.RUnlock()
+
+	return e.envcfg
+}
+
 // EnvConfig returns the EnvConfig for this Engine.
 func (e *Engine) EnvConfig() config.EnvConfig {
-	return *e.envcfg
+	return *e.config()
 }
 
 func (e *Engine) Context() context.Context {
@@ -354,6 +541,15 @@ func stringInSlice(a string, list []string) bool {
 	return false
 }
 
+func intInSlice(a int, list []int) bool {
+	for _, b := range list {
+		if b == a {
+			return true
+		}
+	}
+	return false
+}
+
 // Tasks returns a list of tasks that match the filters argument
 func (e *Engine) Tasks(filters api.TasksFilters) ([]task.Task, error) {
 	var (
@@ -406,9 +602,13 @@ func (e *Engine) Tasks(filters api.TasksFilters) ([]task.Task, error) {
 	return res, nil
 }
 
-// DeleteTask removes a task from the Testground daemon database
+// DeleteTask removes a task from the Testground daemon database, along with
+// the files of its builds, if any.
 func (e *Engine) DeleteTask(id string) error {
-	return e.store.Delete(id)
+	if err := e.store.Delete(id); err != nil {
+		return err
+	}
+	return os.RemoveAll(filepath.Join(e.config().Dirs().Builds(), id))
 }
 
 func (e *Engine) GetTask(id string) (*task.Task, error) {
@@ -417,11 +617,12 @@ func (e *Engine) GetTask(id string) (*task.Task, error) {
 
 // Kill closes the signal channel for a given task, which signals to the runner to stop it
 func (e *Engine) Kill(id string) error {
-	e.signalsLk.RLock()
+	e.signalsLk.Lock()
 	if ch, ok := e.signals[id]; ok {
 		close(ch)
+		delete(e.signals, id)
 	}
-	e.signalsLk.RUnlock()
+	e.signalsLk.Unlock()
 
 	return nil
 }
@@ -440,7 +641,7 @@ func UnmarshalTask(taskData []byte) (*task.Task, error) {
 
 	// unmarshal task again, based on its type
 	switch unmarshaledValue.Type {
-	case task.TypeRun:
+	case task.TypeRun, task.TypePipeline:
 		finalTask.Input = &RunInput{}
 		err = json.Unmarshal(taskData, finalTask)
 	case task.TypeBuild:
@@ -525,11 +726,7 @@ Outer:
 		select {
 		case <-ctx.Done():
 			if cancel {
-				e.signalsLk.RLock()
-				if ch, ok := e.signals[id]; ok {
-					close(ch)
-				}
-				e.signalsLk.RUnlock()
+				_ = e.Kill(id)
 			}
 			break Outer
 		default:
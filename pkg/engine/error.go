@@ -1,6 +1,12 @@
 package engine
 
-import "fmt"
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/testground/testground/pkg/api"
+)
 
 type TaskExecutionError struct {
 	TaskType   string
@@ -10,3 +16,43 @@ type TaskExecutionError struct {
 func (e *TaskExecutionError) Error() string {
 	return fmt.Sprintf("task of type %s cancelled: %v", e.TaskType, e.WrappedErr.Error())
 }
+
+func (e *TaskExecutionError) Unwrap() error {
+	return e.WrappedErr
+}
+
+// TimeoutError is returned when a stage of a task runs out of time, as bound
+// by the timeouts of its composition.
+type TimeoutError struct {
+	// Stage is the stage that timed out: run, build or start.
+	Stage   string
+	Timeout time.Duration
+	// WrappedErr is the error the stage returned once interrupted, if any.
+	WrappedErr error
+}
+
+func (e *TimeoutError) Error() string {
+	if e.WrappedErr == nil {
+		return fmt.Sprintf("%s timed out after %s", e.Stage, e.Timeout)
+	}
+	return fmt.Sprintf("%s timed out after %s: %v", e.Stage, e.Timeout, e.WrappedErr)
+}
+
+func (e *TimeoutError) Unwrap() error {
+	return e.WrappedErr
+}
+
+// ScanError is returned when the scans of build artifacts found
+// vulnerabilities at or above the severity threshold of the daemon config.
+type ScanError struct {
+	Reports []*api.ScanReport
+}
+
+func (e *ScanError) Error() string {
+	parts := make([]string, 0, len(e.Reports))
+	for _, r := range e.Reports {
+		parts = append(parts, fmt.Sprintf("%s (groups %s): %d at or above %s; found %s",
+			r.Artifact, strings.Join(r.Groups, ", "), r.Blocking, strings.ToLower(r.FailOn), r.Summary()))
+	}
+	return "vulnerability scan failed: " + strings.Join(parts, "; ")
+}
@@ -0,0 +1,126 @@
+package engine
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/testground/testground/pkg/api"
+	"github.com/testground/testground/pkg/data"
+	"github.com/testground/testground/pkg/logging"
+	"github.com/testground/testground/pkg/task"
+)
+
+// eventBuffer is the number of events buffered for every subscriber.
+// Subscribers that fall further behind are dropped, so that they don't hold
+// up the tasks; they can subscribe again, and catch up with GET /tasks.
+const eventBuffer = 256
+
+// eventBus fans the lifecycle events of tasks out to their subscribers.
+type eventBus struct {
+	lk   sync.Mutex
+	next int
+	subs map[int]*eventSub
+}
+
+type eventSub struct {
+	filter api.EventFilter
+	ch     chan *api.Event
+}
+
+func newEventBus() *eventBus {
+	return &eventBus{subs: make(map[int]*eventSub)}
+}
+
+// SubscribeEvents returns the channel of the lifecycle events selected by the
+// filter, and the function to unsubscribe. The channel is closed once
+// unsubscribed, or if the subscriber falls behind.
+func (e *Engine) SubscribeEvents(filter api.EventFilter) (<-chan *api.Event, func()) {
+	b := e.events
+	sub := &eventSub{filter: filter, ch: make(chan *api.Event, eventBuffer)}
+
+	b.lk.Lock()
+	id := b.next
+	b.next++
+	b.subs[id] = sub
+	b.lk.Unlock()
+
+	var once sync.Once
+	return sub.ch, func() {
+		once.Do(func() {
+			b.lk.Lock()
+			defer b.lk.Unlock()
+			if _, ok := b.subs[id]; ok {
+				delete(b.subs, id)
+				close(sub.ch)
+			}
+		})
+	}
+}
+
+// publish sends the event to the subscribers it matches.
+func (e *Engine) publish(ev *api.Event) {
+	if ev.Time.IsZero() {
+		ev.Time = time.Now().UTC()
+	}
+
+	b := e.events
+	if b == nil {
+		return
+	}
+	b.lk.Lock()
+	defer b.lk.Unlock()
+	for id, sub := range b.subs {
+		if !sub.filter.Match(ev) {
+			continue
+		}
+		select {
+		case sub.ch <- ev:
+		default:
+			logging.S().Warnw("event subscriber fell behind; dropping it", "subscriber", id)
+			delete(b.subs, id)
+			close(sub.ch)
+		}
+	}
+}
+
+// taskEvent returns an event of the task.
+func taskEvent(tsk *task.Task, typ api.EventType) *api.Event {
+	ev := &api.Event{Type: typ}
+	stampEvent(ev, tsk)
+	return ev
+}
+
+// stampEvent sets the task of the event. Build tasks don't record their plan,
+// which is taken from their composition.
+func stampEvent(ev *api.Event, tsk *task.Task) {
+	ev.TaskID, ev.TaskType = tsk.ID, tsk.Type
+	ev.Plan, ev.Case = tsk.Plan, tsk.Case
+	if in, ok := tsk.Input.(*BuildInput); ok && ev.Plan == "" {
+		ev.Plan = in.Composition.Global.Plan
+	}
+}
+
+// publishQueued publishes the queueing of the task.
+func (e *Engine) publishQueued(tsk *task.Task) {
+	e.publish(taskEvent(tsk, api.EventTaskQueued))
+}
+
+// publishCompleted publishes the completion of the task, with its outcome.
+func (e *Engine) publishCompleted(tsk *task.Task) {
+	ev := taskEvent(tsk, api.EventTaskCompleted)
+	ev.Error = tsk.Error
+	if outcome, err := data.DecodeTaskOutcome(tsk); err == nil {
+		ev.Outcome = outcome
+	}
+	e.publish(ev)
+}
+
+// withEventSink returns a context that publishes the events of the builders
+// and runners processing the task.
+func (e *Engine) withEventSink(ctx context.Context, tsk *task.Task) context.Context {
+	return api.WithEventSink(ctx, func(ev api.Event) {
+		stampEvent(&ev, tsk)
+		e.publish(&ev)
+	})
+}
@@ -0,0 +1,43 @@
+package engine
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/testground/testground/pkg/api"
+	"github.com/testground/testground/pkg/task"
+)
+
+func TestSubscribeEvents(t *testing.T) {
+	e := newTestEngine(t, nil)
+
+	all, unsubscribeAll := e.SubscribeEvents(api.EventFilter{})
+	defer unsubscribeAll()
+	completed, unsubscribe := e.SubscribeEvents(api.EventFilter{TaskID: "a", Types: []api.EventType{api.EventTaskCompleted}})
+
+	e.publishQueued(&task.Task{ID: "a", Type: task.TypeRun, Plan: "placebo"})
+	done := []task.DatedState{{State: task.StateComplete, Created: time.Now().UTC()}}
+	e.publishCompleted(&task.Task{ID: "b", Type: task.TypeRun, Plan: "placebo", States: done})
+	e.publishCompleted(&task.Task{ID: "a", Type: task.TypeRun, Plan: "placebo", States: done})
+
+	require.Len(t, all, 3)
+	require.Len(t, completed, 1)
+	ev := <-completed
+	require.Equal(t, api.EventTaskCompleted, ev.Type)
+	require.Equal(t, "a", ev.TaskID)
+	require.False(t, ev.Time.IsZero())
+
+	unsubscribe()
+	unsubscribe()
+	_, ok := <-completed
+	require.False(t, ok)
+
+	// Subscribers that fall behind are dropped.
+	for i := 0; i < eventBuffer; i++ {
+		e.publishQueued(&task.Task{ID: "c", Type: task.TypeBuild})
+	}
+	for range all {
+	}
+}
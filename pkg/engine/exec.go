@@ -0,0 +1,58 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/testground/testground/pkg/api"
+	"github.com/testground/testground/pkg/task"
+)
+
+// DoExec runs a command in an instance of a running task, streaming its
+// input and output.
+func (e *Engine) DoExec(ctx context.Context, req *api.ExecRequest, stdin io.Reader, stdout, stderr io.Writer) (*api.ExecOutput, error) {
+	if req.Group == "" {
+		return nil, fmt.Errorf("group is required")
+	}
+	if req.Instance < 0 {
+		return nil, fmt.Errorf("invalid instance index %d", req.Instance)
+	}
+	if len(req.Cmd) == 0 {
+		return nil, fmt.Errorf("a command to run is required")
+	}
+
+	t, err := e.GetTask(req.TaskID)
+	if err != nil {
+		return nil, fmt.Errorf("could not get task %s: %s", req.TaskID, err.Error())
+	}
+
+	if t.Type != task.TypeRun {
+		return nil, fmt.Errorf("task %s is not a run", req.TaskID)
+	}
+
+	if st := t.State().State; st != task.StateProcessing {
+		return nil, fmt.Errorf("task %s is not running (state: %s)", req.TaskID, st)
+	}
+
+	run, ok := e.runners[t.Runner]
+	if !ok {
+		return nil, fmt.Errorf("unknown runner: %s", t.Runner)
+	}
+
+	executor, ok := run.(api.Executor)
+	if !ok {
+		return nil, fmt.Errorf("runner %s does not support exec", t.Runner)
+	}
+
+	return executor.Exec(ctx, &api.ExecInput{
+		RunID:    t.ID,
+		Group:    req.Group,
+		Instance: req.Instance,
+		Cmd:      req.Cmd,
+		Tty:      req.Tty,
+		Stdin:    stdin,
+		Stdout:   stdout,
+		Stderr:   stderr,
+	})
+}
@@ -0,0 +1,63 @@
+package engine
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/testground/testground/pkg/api"
+	"github.com/testground/testground/pkg/task"
+)
+
+func persistRun(t *testing.T, e *Engine, id string, state task.State) {
+	tsk := &task.Task{
+		ID:     id,
+		Type:   task.TypeRun,
+		Runner: "local:fake",
+		States: []task.DatedState{{State: state, Created: time.Now().UTC()}},
+	}
+	require.NoError(t, e.store.PersistProcessing(tsk))
+}
+
+func TestDoExec(t *testing.T) {
+	e := newTestEngine(t, nil, &fakeRunner{})
+
+	id := "c3ftkqjpc98qra498sg0"
+	persistRun(t, e, id, task.StateProcessing)
+
+	var stdout, stderr bytes.Buffer
+	req := &api.ExecRequest{TaskID: id, Group: "nodes", Instance: 2, Cmd: []string{"cat", "/data/HEAD"}}
+	out, err := e.DoExec(context.Background(), req, strings.NewReader("input"), &stdout, &stderr)
+	require.NoError(t, err)
+	require.Equal(t, &api.ExecOutput{Instance: "nodes-2", ExitCode: 3}, out)
+	require.Equal(t, "input", stdout.String())
+	require.Equal(t, "cat /data/HEAD", stderr.String())
+
+	for _, req := range []*api.ExecRequest{
+		{TaskID: id, Cmd: []string{"sh"}},
+		{TaskID: id, Group: "nodes"},
+		{TaskID: id, Group: "nodes", Instance: -1, Cmd: []string{"sh"}},
+	} {
+		_, err := e.DoExec(context.Background(), req, nil, nil, nil)
+		require.Error(t, err, "%+v", req)
+	}
+
+	// Only in running tasks.
+	done := "c3ftkqjpc98qra498sg1"
+	persistRun(t, e, done, task.StateComplete)
+	_, err = e.DoExec(context.Background(), &api.ExecRequest{TaskID: done, Group: "nodes", Cmd: []string{"sh"}}, nil, nil, nil)
+	require.Error(t, err)
+}
+
+func TestDoExecUnsupported(t *testing.T) {
+	e := newTestEngine(t, nil, plainRunner(&fakeRunner{}))
+
+	id := "c3ftkqjpc98qra498sg0"
+	persistRun(t, e, id, task.StateProcessing)
+	_, err := e.DoExec(context.Background(), &api.ExecRequest{TaskID: id, Group: "nodes", Cmd: []string{"sh"}}, nil, nil, nil)
+	require.EqualError(t, err, "runner local:fake does not support exec")
+}
@@ -0,0 +1,83 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/testground/testground/pkg/api"
+	"github.com/testground/testground/pkg/task"
+)
+
+func (e *Engine) addDecisionLog(id string) *api.DecisionLog {
+	l := new(api.DecisionLog)
+	e.decisionsLk.Lock()
+	e.decisions[id] = l
+	e.decisionsLk.Unlock()
+	return l
+}
+
+func (e *Engine) deleteDecisionLog(id string) {
+	e.decisionsLk.Lock()
+	delete(e.decisions, id)
+	e.decisionsLk.Unlock()
+}
+
+// DoExplain explains the scheduling of a task: where it stands in the queue
+// while it's queued, and the decisions taken so far, or in total once it's
+// complete, while building and running it.
+func (e *Engine) DoExplain(_ context.Context, id string) (*api.Explanation, error) {
+	tsk, err := e.store.Get(id)
+	if err != nil {
+		return nil, fmt.Errorf("could not get task %s: %w", id, err)
+	}
+
+	ex := &api.Explanation{
+		TaskID:    id,
+		State:     tsk.State().State,
+		Decisions: tsk.Decisions,
+	}
+
+	switch ex.State {
+	case task.StateScheduled:
+		ahead, n, ok := e.queue.Ahead(id)
+		if !ok {
+			break
+		}
+
+		e.signalsLk.RLock()
+		busy := make([]string, 0, len(e.signals))
+		for tid := range e.signals {
+			busy = append(busy, tid)
+		}
+		e.signalsLk.RUnlock()
+		sort.Strings(busy)
+
+		ex.Queue = &api.QueueExplanation{
+			Position: len(ahead) + 1,
+			Length:   n,
+			Ahead:    ahead,
+			Workers:  e.config().Daemon.Scheduler.Workers,
+			Busy:     busy,
+		}
+
+		if tsk.Type == task.TypeRun {
+			if running, limit := e.runnerUsage(tsk.Runner); limit > 0 {
+				ex.Queue.Runner, ex.Queue.RunnerRunning, ex.Queue.RunnerLimit = tsk.Runner, running, limit
+			}
+		}
+
+	case task.StateProcessing:
+		e.decisionsLk.RLock()
+		l, ok := e.decisions[id]
+		e.decisionsLk.RUnlock()
+		if ok {
+			ex.Decisions = l.Decisions()
+		}
+	}
+
+	if ex.Decisions == nil {
+		ex.Decisions = []task.Decision{}
+	}
+	return ex, nil
+}
@@ -0,0 +1,46 @@
+package engine
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/testground/testground/pkg/api"
+	"github.com/testground/testground/pkg/task"
+)
+
+func TestDoExplain(t *testing.T) {
+	e := newTestEngine(t, nil)
+
+	now := time.Now().UTC()
+	for i, id := range []string{"bt4brhjpc98qra498sg0", "bt4brhjpc98qra498sg1"} {
+		require.NoError(t, e.queue.Push(&task.Task{
+			ID:     id,
+			Type:   task.TypeBuild,
+			Input:  &BuildInput{},
+			States: []task.DatedState{{State: task.StateScheduled, Created: now.Add(time.Duration(i) * time.Second)}},
+		}))
+	}
+
+	ex, err := e.DoExplain(context.Background(), "bt4brhjpc98qra498sg1")
+	require.NoError(t, err)
+	require.Equal(t, task.StateScheduled, ex.State)
+	require.Equal(t, &api.QueueExplanation{Position: 2, Length: 2, Ahead: []string{"bt4brhjpc98qra498sg0"}, Busy: []string{}}, ex.Queue)
+
+	// Decisions of running tasks are reported as they are taken.
+	l := e.addDecisionLog("bt4brhjpc98qra498sg0")
+	api.RecordDecision(api.WithDecisionLog(context.Background(), l), api.DecisionStageBuild, "group %s built by %s", "single", "docker:go")
+
+	tsk, err := e.queue.Pop()
+	require.NoError(t, err)
+	tsk.States = append(tsk.States, task.DatedState{State: task.StateProcessing, Created: now})
+	require.NoError(t, e.store.PersistProcessing(tsk))
+
+	ex, err = e.DoExplain(context.Background(), "bt4brhjpc98qra498sg0")
+	require.NoError(t, err)
+	require.Nil(t, ex.Queue)
+	require.Len(t, ex.Decisions, 1)
+	require.Equal(t, "group single built by docker:go", ex.Decisions[0].Message)
+}
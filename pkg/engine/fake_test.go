@@ -0,0 +1,154 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/testground/testground/pkg/api"
+	"github.com/testground/testground/pkg/config"
+	"github.com/testground/testground/pkg/rpc"
+)
+
+// newTestEngine returns an engine with in-memory task storage, no workers and
+// its home in a temporary directory, with the given runners. configure, if
+// not nil, adjusts the configuration before the engine is created.
+func newTestEngine(t *testing.T, configure func(*config.EnvConfig), runners ...api.Runner) *Engine {
+	home, err := ioutil.TempDir("", "testground")
+	require.NoError(t, err)
+	t.Cleanup(func() { os.RemoveAll(home) })
+
+	prev, had := os.LookupEnv(config.EnvTestgroundHomeDir)
+	require.NoError(t, os.Setenv(config.EnvTestgroundHomeDir, home))
+	t.Cleanup(func() {
+		if had {
+			os.Setenv(config.EnvTestgroundHomeDir, prev)
+		} else {
+			os.Unsetenv(config.EnvTestgroundHomeDir)
+		}
+	})
+
+	envcfg := &config.EnvConfig{}
+	require.NoError(t, envcfg.Load())
+	envcfg.Daemon.Scheduler = config.SchedulerConfig{TaskRepoType: "memory", QueueSize: 10}
+	if configure != nil {
+		configure(envcfg)
+	}
+
+	e, err := NewEngine(&EngineConfig{EnvConfig: envcfg, Runners: runners})
+	require.NoError(t, err)
+	return e
+}
+
+// fakeRunner is the runner of the tests of the engine. It pretends to run a
+// fixed set of instances, to keep the outputs of runs in dir, and to have
+// resources for a fixed set of runs, and records what it's asked to do.
+type fakeRunner struct {
+	// instances are the instances of every run.
+	instances []*api.Instance
+	// runs are the runs it has resources for.
+	runs []string
+	// dir is the directory it keeps the outputs of runs in.
+	dir string
+	// step is the number of instances that become ready at every probe.
+	step int
+	// run runs a run; runs succeed right away if nil.
+	run func(context.Context, *api.RunInput) (*api.RunOutput, error)
+
+	lk      sync.Mutex
+	inputs  []*api.ChaosInput
+	removed []string
+	probes  int
+}
+
+var (
+	_ api.Runner           = (*fakeRunner)(nil)
+	_ api.ChaosInjector    = (*fakeRunner)(nil)
+	_ api.Executor         = (*fakeRunner)(nil)
+	_ api.InstanceRegistry = (*fakeRunner)(nil)
+	_ api.OutputsLocator   = (*fakeRunner)(nil)
+	_ api.ReadinessProber  = (*fakeRunner)(nil)
+	_ api.RunReaper        = (*fakeRunner)(nil)
+)
+
+// plainRunner hides all the optional interfaces a runner implements.
+func plainRunner(r api.Runner) api.Runner {
+	return struct{ api.Runner }{r}
+}
+
+func (r *fakeRunner) ID() string                   { return "local:fake" }
+func (r *fakeRunner) ConfigType() reflect.Type     { return reflect.TypeOf(struct{}{}) }
+func (r *fakeRunner) CompatibleBuilders() []string { return []string{"docker:fake"} }
+
+func (r *fakeRunner) Run(ctx context.Context, input *api.RunInput, _ *rpc.OutputWriter) (*api.RunOutput, error) {
+	if r.run != nil {
+		return r.run(ctx, input)
+	}
+	return &api.RunOutput{RunID: input.RunID}, nil
+}
+
+func (r *fakeRunner) CollectOutputs(_ context.Context, input *api.CollectionInput, ow *rpc.OutputWriter) error {
+	_, err := ow.BinaryWriter().Write([]byte("archive of " + input.RunID))
+	return err
+}
+
+func (r *fakeRunner) LocateOutputs(plan string, runID string) (string, error) {
+	return filepath.Join(r.dir, plan, runID), nil
+}
+
+func (r *fakeRunner) ListInstances(context.Context, string) ([]*api.Instance, error) {
+	return r.instances, nil
+}
+
+// Exec echoes its input to the output of the command, and the command to
+// its error output.
+func (r *fakeRunner) Exec(_ context.Context, input *api.ExecInput) (*api.ExecOutput, error) {
+	if _, err := io.Copy(input.Stdout, input.Stdin); err != nil {
+		return nil, err
+	}
+	if _, err := fmt.Fprint(input.Stderr, strings.Join(input.Cmd, " ")); err != nil {
+		return nil, err
+	}
+	return &api.ExecOutput{Instance: fmt.Sprintf("%s-%d", input.Group, input.Instance), ExitCode: 3}, nil
+}
+
+func (r *fakeRunner) InjectChaos(_ context.Context, input *api.ChaosInput) error {
+	r.lk.Lock()
+	defer r.lk.Unlock()
+	r.inputs = append(r.inputs, input)
+	return nil
+}
+
+// ProbeReadiness reports step more ready instances at every probe, up to 4.
+func (r *fakeRunner) ProbeReadiness(context.Context, *api.ReadinessInput) (*api.ReadinessReport, error) {
+	r.lk.Lock()
+	defer r.lk.Unlock()
+
+	r.probes++
+	rep := &api.ReadinessReport{}
+	for i := 0; i < r.probes*r.step && i < 4; i++ {
+		rep.Instances = append(rep.Instances, &api.InstanceReadiness{Name: string(rune('a' + i)), Ready: true})
+		rep.Ready++
+	}
+	return rep, nil
+}
+
+func (r *fakeRunner) ListRuns(context.Context) ([]string, error) {
+	return r.runs, nil
+}
+
+func (r *fakeRunner) RemoveRun(_ context.Context, runID string, _ *rpc.OutputWriter) error {
+	r.lk.Lock()
+	defer r.lk.Unlock()
+	r.removed = append(r.removed, runID)
+	return nil
+}
@@ -0,0 +1,324 @@
+package engine
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/rs/xid"
+
+	"github.com/testground/testground/pkg/api"
+	"github.com/testground/testground/pkg/config"
+	"github.com/testground/testground/pkg/logging"
+	"github.com/testground/testground/pkg/rpc"
+	"github.com/testground/testground/pkg/task"
+)
+
+// sourcesGracePeriod protects the sources of requests that are still being
+// unpacked, and aren't referred to by a task yet.
+const sourcesGracePeriod = time.Hour
+
+// gcEntry is an item the garbage collector knows about. Protected entries,
+// i.e. those in use by scheduled or running tasks, are never removed.
+type gcEntry struct {
+	*api.GCItem
+	protected bool
+}
+
+// DoGC applies the retention policies of the daemon to the outputs of the
+// local runners, the sources unpacked from requests and the artifacts of the
+// builders, and removes what they select, unless dryRun is set.
+func (e *Engine) DoGC(ctx context.Context, dryRun bool, ow *rpc.OutputWriter) (*api.GCReport, error) {
+	inUse, err := e.gcInUse()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list the tasks in progress: %w", err)
+	}
+
+	var entries []*gcEntry
+
+	outputs, err := listOutputs(e.config().Dirs().Outputs())
+	if err != nil {
+		return nil, err
+	}
+	entries = append(entries, outputs...)
+
+	sources, err := listSources(filepath.Join(e.config().Dirs().Work(), "requests"))
+	if err != nil {
+		return nil, err
+	}
+	entries = append(entries, sources...)
+
+	for id, b := range e.ListBuilders() {
+		collector, ok := b.(api.ArtifactCollector)
+		if !ok {
+			continue
+		}
+		artifacts, err := collector.ListArtifacts(ctx, e.config())
+		if err != nil {
+			ow.Warnw("failed to list build artifacts", "builder", id, "err", err)
+			continue
+		}
+		for _, a := range artifacts {
+			entries = append(entries, &gcEntry{GCItem: &api.GCItem{
+				Kind:    api.GCKindArtifact,
+				Builder: id,
+				Plan:    a.Plan,
+				ID:      a.ID,
+				Created: a.Created,
+				Size:    a.Size,
+			}})
+		}
+	}
+
+	for _, en := range entries {
+		switch en.Kind {
+		case api.GCKindOutputs:
+			_, en.protected = inUse.runs[en.ID]
+		case api.GCKindSources:
+			_, en.protected = inUse.sources[en.Path]
+			en.protected = en.protected || time.Since(en.Created) < sourcesGracePeriod
+		case api.GCKindArtifact:
+			_, en.protected = inUse.artifacts[en.ID]
+		}
+	}
+
+	report := &api.GCReport{DryRun: dryRun, Items: []*api.GCItem{}}
+	for _, item := range selectGarbage(entries, e.config().Daemon.Retention, time.Now()) {
+		if !dryRun {
+			if err := e.removeGarbage(ctx, item); err != nil {
+				ow.Warnw("failed to remove", "kind", item.Kind, "id", item.ID, "err", err)
+				continue
+			}
+		}
+		ow.Infow("garbage collected", "kind", item.Kind, "plan", item.Plan, "id", item.ID, "size", item.Size, "reason", item.Reason, "dry_run", dryRun)
+		report.Items = append(report.Items, item)
+		report.Freed += item.Size
+	}
+
+	return report, nil
+}
+
+func (e *Engine) removeGarbage(ctx context.Context, item *api.GCItem) error {
+	if item.Kind != api.GCKindArtifact {
+		return os.RemoveAll(item.Path)
+	}
+	b, ok := e.BuilderByName(item.Builder)
+	if !ok {
+		return fmt.Errorf("unknown builder: %s", item.Builder)
+	}
+	return b.(api.ArtifactCollector).RemoveArtifact(ctx, &api.Artifact{ID: item.ID, Plan: item.Plan, Created: item.Created, Size: item.Size})
+}
+
+// gcLoop garbage collects every interval, until the engine context is done.
+func (e *Engine) gcLoop(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-e.ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		report, err := e.DoGC(e.ctx, false, rpc.NewStdoutWriter())
+		if err != nil {
+			logging.S().Warnw("garbage collection failed", "err", err)
+			continue
+		}
+		logging.S().Infow("garbage collection done", "removed", len(report.Items), "freed", report.Freed)
+	}
+}
+
+type gcReferences struct {
+	runs      map[string]struct{}
+	sources   map[string]struct{}
+	artifacts map[string]struct{}
+}
+
+// gcInUse returns what the scheduled and running tasks refer to.
+func (e *Engine) gcInUse() (*gcReferences, error) {
+	refs := &gcReferences{
+		runs:      make(map[string]struct{}),
+		sources:   make(map[string]struct{}),
+		artifacts: make(map[string]struct{}),
+	}
+
+	for _, state := range []task.State{task.StateScheduled, task.StateProcessing} {
+		tsks, err := e.store.Filter(state, time.Time{}, time.Now().UTC())
+		if err != nil {
+			return nil, err
+		}
+		for _, tsk := range tsks {
+			refs.runs[tsk.ID] = struct{}{}
+
+			// The inputs of stored tasks are untyped; decode them again
+			// according to the type of the task.
+			data, err := json.Marshal(tsk)
+			if err != nil {
+				return nil, err
+			}
+			typed, err := UnmarshalTask(data)
+			if err != nil {
+				return nil, err
+			}
+
+			switch input := typed.Input.(type) {
+			case *BuildInput:
+				if input.Sources != nil {
+					refs.sources[input.Sources.BaseDir] = struct{}{}
+				}
+			case *RunInput:
+				if input.Sources != nil {
+					refs.sources[input.Sources.BaseDir] = struct{}{}
+				}
+				if input.RunRequest == nil {
+					continue
+				}
+				comp := input.RunRequest.Composition
+				if comp.Global.Run != nil && comp.Global.Run.Artifact != "" {
+					refs.artifacts[comp.Global.Run.Artifact] = struct{}{}
+				}
+				for _, g := range comp.Groups {
+					if g.Run.Artifact != "" {
+						refs.artifacts[g.Run.Artifact] = struct{}{}
+					}
+				}
+			}
+		}
+	}
+
+	return refs, nil
+}
+
+// listOutputs lists the outputs of the runs of the local runners, laid out
+// as <runner>/<plan>/<run id>.
+func listOutputs(dir string) ([]*gcEntry, error) {
+	runs, err := filepath.Glob(filepath.Join(dir, "*", "*", "*"))
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []*gcEntry
+	for _, run := range runs {
+		fi, err := os.Stat(run)
+		if err != nil || !fi.IsDir() {
+			continue
+		}
+		id := fi.Name()
+		created := fi.ModTime()
+		if x, err := xid.FromString(id); err == nil {
+			created = x.Time()
+		}
+		entries = append(entries, &gcEntry{GCItem: &api.GCItem{
+			Kind:    api.GCKindOutputs,
+			Plan:    filepath.Base(filepath.Dir(run)),
+			ID:      id,
+			Path:    run,
+			Created: created,
+			Size:    dirSize(run),
+		}})
+	}
+	return entries, nil
+}
+
+// listSources lists the directories the sources of requests were unpacked
+// to, named after the request ID.
+func listSources(dir string) ([]*gcEntry, error) {
+	fis, err := ioutil.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []*gcEntry
+	for _, fi := range fis {
+		if !fi.IsDir() {
+			continue
+		}
+		path := filepath.Join(dir, fi.Name())
+		entries = append(entries, &gcEntry{GCItem: &api.GCItem{
+			Kind:    api.GCKindSources,
+			ID:      fi.Name(),
+			Path:    path,
+			Created: fi.ModTime(),
+			Size:    dirSize(path),
+		}})
+	}
+	return entries, nil
+}
+
+func dirSize(dir string) int64 {
+	var size int64
+	_ = filepath.Walk(dir, func(_ string, fi os.FileInfo, err error) error {
+		if err == nil && !fi.IsDir() {
+			size += fi.Size()
+		}
+		return nil
+	})
+	return size
+}
+
+// selectGarbage returns the entries selected by the retention policies,
+// setting the reason each of them was selected. Protected entries are never
+// selected, but they count towards the last N entries of a plan and the total
+// size.
+func selectGarbage(entries []*gcEntry, policy config.RetentionConfig, now time.Time) []*api.GCItem {
+	// Newest first.
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].Created.After(entries[j].Created)
+	})
+
+	var (
+		maxAge  = time.Duration(policy.MaxAgeDays) * 24 * time.Hour
+		maxSize = int64(policy.MaxTotalSizeMB) << 20
+		perPlan = make(map[string]int)
+		total   int64
+	)
+
+	for _, en := range entries {
+		en.Reason = ""
+
+		// Sources aren't tied to a plan.
+		if en.Plan != "" && en.Kind != api.GCKindSources {
+			k := en.Kind + "/" + en.Builder + "/" + en.Plan
+			perPlan[k]++
+			if policy.KeepLastPerPlan > 0 && perPlan[k] > policy.KeepLastPerPlan {
+				en.Reason = fmt.Sprintf("not among the last %d of plan %s", policy.KeepLastPerPlan, en.Plan)
+			}
+		}
+		if en.Reason == "" && maxAge > 0 && now.Sub(en.Created) > maxAge {
+			en.Reason = fmt.Sprintf("older than %d days", policy.MaxAgeDays)
+		}
+		if (en.Reason == "" || en.protected) && en.Kind != api.GCKindArtifact {
+			total += en.Size
+		}
+	}
+
+	// Oldest first, until the outputs and sources that are left fit.
+	if maxSize > 0 {
+		for i := len(entries) - 1; i >= 0 && total > maxSize; i-- {
+			en := entries[i]
+			if en.Reason != "" || en.protected || en.Kind == api.GCKindArtifact {
+				continue
+			}
+			en.Reason = fmt.Sprintf("over the total size of %d MB", policy.MaxTotalSizeMB)
+			total -= en.Size
+		}
+	}
+
+	var selected []*api.GCItem
+	for _, en := range entries {
+		if en.Reason != "" && !en.protected {
+			selected = append(selected, en.GCItem)
+		}
+	}
+	return selected
+}
@@ -0,0 +1,49 @@
+package engine
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/testground/testground/pkg/api"
+	"github.com/testground/testground/pkg/config"
+)
+
+func TestSelectGarbage(t *testing.T) {
+	now := time.Now()
+	day := 24 * time.Hour
+
+	entry := func(kind, plan, id string, age time.Duration, size int64) *gcEntry {
+		return &gcEntry{GCItem: &api.GCItem{Kind: kind, Plan: plan, ID: id, Created: now.Add(-age), Size: size}}
+	}
+
+	var (
+		newest   = entry(api.GCKindOutputs, "ping", "r3", 1*day, 1<<20)
+		middle   = entry(api.GCKindOutputs, "ping", "r2", 2*day, 1<<20)
+		oldest   = entry(api.GCKindOutputs, "ping", "r1", 3*day, 1<<20)
+		other    = entry(api.GCKindOutputs, "pong", "r4", 10*day, 1<<20)
+		running  = entry(api.GCKindOutputs, "pong", "r5", 20*day, 1<<20)
+		sources  = entry(api.GCKindSources, "", "req1", 4*day, 3<<20)
+		artifact = entry(api.GCKindArtifact, "ping", "sha256:abc", 40*day, 100<<20)
+	)
+	running.protected = true
+
+	policy := config.RetentionConfig{
+		MaxAgeDays:      7,
+		MaxTotalSizeMB:  3,
+		KeepLastPerPlan: 2,
+	}
+
+	selected := selectGarbage([]*gcEntry{oldest, newest, sources, other, running, middle, artifact}, policy, now)
+
+	require.ElementsMatch(t, []*api.GCItem{oldest.GCItem, other.GCItem, sources.GCItem, artifact.GCItem}, selected)
+	require.Contains(t, oldest.Reason, "last 2")
+	require.Contains(t, other.Reason, "older than 7 days")
+	require.Contains(t, artifact.Reason, "older than 7 days")
+	// Only newest, middle and the protected run are left: 3 MB.
+	require.Contains(t, sources.Reason, "total size")
+
+	// No policy, no garbage.
+	require.Empty(t, selectGarbage([]*gcEntry{oldest, other}, config.RetentionConfig{}, now))
+}
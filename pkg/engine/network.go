@@ -0,0 +1,107 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/rs/xid"
+
+	"github.com/testground/testground/pkg/api"
+	"github.com/testground/testground/pkg/rpc"
+	"github.com/testground/testground/pkg/task"
+)
+
+// defaultNetworkFaultTimeout is how long to wait for the instances to apply
+// a network fault, unless the request says otherwise.
+const defaultNetworkFaultTimeout = 30 * time.Second
+
+// DoInjectNetworkFault injects a network fault in a running task, and
+// records the fault, and the network events of the instances applying it,
+// in the task log.
+func (e *Engine) DoInjectNetworkFault(ctx context.Context, req *api.NetworkFaultRequest, ow *rpc.OutputWriter) (*api.NetworkFaultOutput, error) {
+	f := req.Fault
+	if f == nil || (f.Shape == nil && len(f.Partition) == 0 && len(f.Blackhole) == 0 && !f.Heal) {
+		return nil, fmt.Errorf("a shape, a partition, a blackhole or heal is required")
+	}
+
+	t, err := e.GetTask(req.TaskID)
+	if err != nil {
+		return nil, fmt.Errorf("could not get task %s: %s", req.TaskID, err.Error())
+	}
+
+	if t.Type != task.TypeRun {
+		return nil, fmt.Errorf("task %s is not a run", req.TaskID)
+	}
+
+	if st := t.State().State; st != task.StateProcessing {
+		return nil, fmt.Errorf("task %s is not running (state: %s)", req.TaskID, st)
+	}
+
+	run, ok := e.runners[t.Runner]
+	if !ok {
+		return nil, fmt.Errorf("unknown runner: %s", t.Runner)
+	}
+
+	faulter, ok := run.(api.NetworkFaulter)
+	if !ok {
+		return nil, fmt.Errorf("runner %s does not support network faults", t.Runner)
+	}
+
+	timeout := time.Duration(req.TimeoutSecs) * time.Second
+	if timeout <= 0 {
+		timeout = defaultNetworkFaultTimeout
+	}
+
+	fault := *f
+	fault.ID = xid.New().String()
+	fault.Time = time.Now().UTC()
+
+	in := &api.NetworkFaultInput{
+		RunID:    t.ID,
+		TestPlan: t.Plan,
+		TestCase: t.Case,
+		Fault:    &fault,
+		Timeout:  timeout,
+	}
+
+	out, err := faulter.InjectNetworkFault(ctx, in, ow)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := e.logNetworkFault(t.ID, out); err != nil {
+		ow.Warnw("failed to record network fault in the task log", "err", err)
+	}
+
+	return out, nil
+}
+
+// logNetworkFault appends a network fault, and the network events of the
+// instances applying it, to the log of the task.
+func (e *Engine) logNetworkFault(taskID string, out *api.NetworkFaultOutput) error {
+	path := filepath.Join(e.EnvConfig().Dirs().Daemon(), taskID+".out")
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	tow := rpc.NewFileOutputWriter(f)
+
+	fault := out.Fault
+	tow.Infow("network fault injected", "id", fault.ID, "group", fault.Group, "shape", fault.Shape, "partition", fault.Partition, "blackhole", fault.Blackhole, "heal", fault.Heal, "expected", out.Expected)
+	for _, ev := range out.Events {
+		if ev.Error != "" {
+			tow.Warnw("network fault failed", "id", fault.ID, "instance", ev.Instance, "err", ev.Error, "after", ev.Time.Sub(fault.Time))
+			continue
+		}
+		tow.Infow("network fault applied", "id", fault.ID, "instance", ev.Instance, "blocked", len(ev.Blocked), "after", ev.Time.Sub(fault.Time))
+	}
+	if len(out.Events) < out.Expected {
+		tow.Warnw("network fault not applied by all instances", "id", fault.ID, "applied", len(out.Events), "expected", out.Expected)
+	}
+	return nil
+}
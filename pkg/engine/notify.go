@@ -0,0 +1,43 @@
+package engine
+
+import (
+	"context"
+	"errors"
+
+	"github.com/testground/testground/pkg/data"
+	"github.com/testground/testground/pkg/logging"
+	"github.com/testground/testground/pkg/notify"
+	"github.com/testground/testground/pkg/task"
+)
+
+// notify posts the outcome of a completed task to the configured webhooks,
+// in the background.
+func (e *Engine) notify(tsk *task.Task, errTask error, canceled bool) {
+	e.cfglk.RLock()
+	notifier := e.notifier
+	e.cfglk.RUnlock()
+
+	if notifier == nil {
+		return
+	}
+
+	outcome, err := data.DecodeTaskOutcome(tsk)
+	if err != nil {
+		return
+	}
+	// Tasks that returned an error are archived as canceled; only those
+	// canceled by a user, or along with their client, are reported as such.
+	if outcome == task.OutcomeCanceled && errTask != nil && !canceled && !errors.Is(errTask, context.Canceled) {
+		outcome = task.OutcomeFailure
+	}
+	if !notifier.Wants(outcome) {
+		return
+	}
+
+	ev := notify.NewEvent(tsk, outcome, e.config().Daemon.RootURL)
+	go func() {
+		if err := notifier.Notify(context.Background(), ev); err != nil {
+			logging.S().Warnw("could not post task notification", "task_id", ev.TaskID, "err", err)
+		}
+	}()
+}
@@ -0,0 +1,73 @@
+package engine
+
+import (
+	"context"
+	"time"
+
+	"github.com/testground/testground/pkg/config"
+	"github.com/testground/testground/pkg/grafana"
+	"github.com/testground/testground/pkg/logging"
+	"github.com/testground/testground/pkg/rpc"
+	"github.com/testground/testground/pkg/runner"
+	"github.com/testground/testground/pkg/task"
+)
+
+// provisionTimeout bounds the provisioning of the observability stack, which
+// takes a while to be up when the daemon starts it.
+const provisionTimeout = 3 * time.Minute
+
+// provisionObservability starts the local observability stack if the daemon
+// manages it, and provisions the datasource of its Grafana.
+func (e *Engine) provisionObservability() {
+	ctx, cancel := context.WithTimeout(e.ctx, provisionTimeout)
+	defer cancel()
+
+	if e.config().Daemon.Observability.Stack == config.ObservabilityStackLocal {
+		if err := runner.EnsureObservabilityStack(ctx, rpc.NewStdoutWriter()); err != nil {
+			logging.S().Warnw("failed to start the observability stack", "err", err)
+			return
+		}
+	}
+
+	// Grafana takes a few seconds to serve its API once started.
+	var err error
+	for {
+		if err = e.grafana.EnsureDatasource(ctx); err == nil {
+			logging.S().Infow("observability stack provisioned", "grafana", e.config().Daemon.Observability.GrafanaURL)
+			return
+		}
+		select {
+		case <-ctx.Done():
+			logging.S().Warnw("failed to provision the datasource of grafana", "err", err)
+			return
+		case <-time.After(5 * time.Second):
+		}
+	}
+}
+
+// provisionDashboard provisions the dashboard of a run, and links it from its
+// task. Runs go on without a dashboard if it fails.
+func (e *Engine) provisionDashboard(tsk *task.Task, input *RunInput) {
+	if e.grafana == nil {
+		return
+	}
+
+	run := &grafana.Run{
+		ID:   tsk.ID,
+		Plan: clean(input.Composition.Global.Plan),
+		Case: clean(input.Composition.Global.Case),
+	}
+	for _, g := range input.Composition.Groups {
+		run.Groups = append(run.Groups, g.ID)
+	}
+
+	ctx, cancel := context.WithTimeout(e.ctx, 10*time.Second)
+	defer cancel()
+
+	url, err := e.grafana.ProvisionDashboard(ctx, run)
+	if err != nil {
+		logging.S().Warnw("could not provision the dashboard of the run", "task_id", tsk.ID, "err", err)
+		return
+	}
+	tsk.Dashboard = url
+}
@@ -0,0 +1,73 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/testground/testground/pkg/api"
+	"github.com/testground/testground/pkg/rpc"
+	"github.com/testground/testground/pkg/storage"
+)
+
+// storeOutputs uploads the outputs of a run to the outputs backend, as the
+// archive its runner collects, and then removes them from the local disk,
+// unless configured to keep them.
+func (e *Engine) storeOutputs(ctx context.Context, run api.Runner, in *api.RunInput, ow *rpc.OutputWriter) error {
+	cfg := e.config().Daemon.Outputs
+	key := storage.OutputsKey(cfg.Prefix, in.RunID)
+
+	input := &api.CollectionInput{
+		RunnerID:     run.ID(),
+		RunID:        in.RunID,
+		EnvConfig:    *e.config(),
+		RunnerConfig: in.RunnerConfig,
+	}
+
+	// Stream the archive straight to the backend; it's never written to disk.
+	pr, pw := io.Pipe()
+	go func() {
+		pw.CloseWithError(run.CollectOutputs(ctx, input, rpc.NewBinaryOutputWriter(pw)))
+	}()
+
+	if err := e.outputs.Put(ctx, key, pr); err != nil {
+		_ = pr.CloseWithError(err)
+		return fmt.Errorf("failed to upload outputs: %w", err)
+	}
+	ow.Infow("outputs uploaded", "run_id", in.RunID, "backend", cfg.Backend, "key", key)
+
+	if cfg.KeepLocal {
+		return nil
+	}
+
+	locator, ok := run.(api.OutputsLocator)
+	if !ok {
+		return nil
+	}
+	dir, err := locator.LocateOutputs(in.TestPlan, in.RunID)
+	if err != nil {
+		return err
+	}
+	return os.RemoveAll(dir)
+}
+
+// collectStoredOutputs writes the outputs archive of a run from the outputs
+// backend. It returns false if the backend doesn't have it.
+func (e *Engine) collectStoredOutputs(ctx context.Context, runID string, ow *rpc.OutputWriter) (bool, error) {
+	key := storage.OutputsKey(e.config().Daemon.Outputs.Prefix, runID)
+
+	r, err := e.outputs.Get(ctx, key)
+	if err == storage.ErrNotFound {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to fetch outputs from %s storage: %w", e.config().Daemon.Outputs.Backend, err)
+	}
+	defer r.Close()
+
+	ow.Infow("collecting outputs from storage", "run_id", runID, "backend", e.config().Daemon.Outputs.Backend, "key", key)
+
+	_, err = io.Copy(ow.BinaryWriter(), r)
+	return true, err
+}
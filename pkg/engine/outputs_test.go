@@ -0,0 +1,66 @@
+package engine
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/testground/testground/pkg/api"
+	"github.com/testground/testground/pkg/config"
+	"github.com/testground/testground/pkg/rpc"
+	"github.com/testground/testground/pkg/task"
+)
+
+func TestStoreAndCollectOutputs(t *testing.T) {
+	outputs := t.TempDir()
+	run := &fakeRunner{dir: t.TempDir()}
+	e := newTestEngine(t, func(envcfg *config.EnvConfig) {
+		envcfg.Daemon.Outputs = config.OutputsConfig{Backend: "dir", Path: outputs, Prefix: "runs"}
+	}, run)
+
+	const id = "c3ftkqjpc98qra498sg0"
+	local := filepath.Join(run.dir, "plan", id)
+	require.NoError(t, os.MkdirAll(local, 0755))
+
+	require.NoError(t, e.storeOutputs(context.Background(), run, &api.RunInput{RunID: id, TestPlan: "plan"}, rpc.Discard()))
+
+	data, err := ioutil.ReadFile(filepath.Join(outputs, "runs", id, "outputs.tgz"))
+	require.NoError(t, err)
+	require.Equal(t, "archive of "+id, string(data))
+
+	// The local copy is gone, so the outputs must come from the backend.
+	_, err = os.Stat(local)
+	require.True(t, os.IsNotExist(err))
+
+	require.NoError(t, e.store.PersistProcessing(&task.Task{ID: id, Type: task.TypeRun, Runner: run.ID()}))
+
+	var buf bytes.Buffer
+	require.NoError(t, e.DoCollectOutputs(context.Background(), id, rpc.NewBinaryOutputWriter(&buf)))
+	require.Equal(t, "archive of "+id, buf.String())
+}
+
+func TestStoreOutputsKeepLocal(t *testing.T) {
+	outputs := t.TempDir()
+	run := &fakeRunner{dir: t.TempDir()}
+	e := newTestEngine(t, func(envcfg *config.EnvConfig) {
+		envcfg.Daemon.Outputs = config.OutputsConfig{Backend: "dir", Path: outputs, KeepLocal: true}
+	}, run)
+
+	const id = "c3ftkqjpc98qra498sg1"
+	local := filepath.Join(run.dir, "plan", id)
+	require.NoError(t, os.MkdirAll(local, 0755))
+
+	require.NoError(t, e.storeOutputs(context.Background(), run, &api.RunInput{RunID: id, TestPlan: "plan"}, rpc.Discard()))
+
+	_, err := os.Stat(local)
+	require.NoError(t, err)
+
+	// Unknown runs are still an error.
+	err = e.DoCollectOutputs(context.Background(), "unknown", rpc.Discard())
+	require.Error(t, err)
+}
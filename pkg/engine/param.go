@@ -0,0 +1,108 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/rs/xid"
+
+	"github.com/testground/testground/pkg/api"
+	"github.com/testground/testground/pkg/rpc"
+	"github.com/testground/testground/pkg/task"
+)
+
+// defaultParamPushTimeout is how long to wait for the instances to
+// acknowledge a parameter update, unless the request says otherwise.
+const defaultParamPushTimeout = 30 * time.Second
+
+// DoPushParam pushes a parameter update to the instances of a running task,
+// and records the delivery, and its acknowledgments, in the task log.
+func (e *Engine) DoPushParam(ctx context.Context, req *api.ParamPushRequest, ow *rpc.OutputWriter) (*api.ParamPushOutput, error) {
+	if req.Key == "" {
+		return nil, fmt.Errorf("param key is required")
+	}
+
+	t, err := e.GetTask(req.TaskID)
+	if err != nil {
+		return nil, fmt.Errorf("could not get task %s: %s", req.TaskID, err.Error())
+	}
+
+	if t.Type != task.TypeRun {
+		return nil, fmt.Errorf("task %s is not a run", req.TaskID)
+	}
+
+	if st := t.State().State; st != task.StateProcessing {
+		return nil, fmt.Errorf("task %s is not running (state: %s)", req.TaskID, st)
+	}
+
+	run, ok := e.runners[t.Runner]
+	if !ok {
+		return nil, fmt.Errorf("unknown runner: %s", t.Runner)
+	}
+
+	pusher, ok := run.(api.ParamPusher)
+	if !ok {
+		return nil, fmt.Errorf("runner %s does not support pushing params", t.Runner)
+	}
+
+	timeout := time.Duration(req.TimeoutSecs) * time.Second
+	if timeout <= 0 {
+		timeout = defaultParamPushTimeout
+	}
+
+	in := &api.ParamPushInput{
+		RunID:    t.ID,
+		TestPlan: t.Plan,
+		TestCase: t.Case,
+		Update: &api.ParamUpdate{
+			ID:    xid.New().String(),
+			Group: req.Group,
+			Key:   req.Key,
+			Value: req.Value,
+			Time:  time.Now().UTC(),
+		},
+		Timeout: timeout,
+	}
+
+	out, err := pusher.PushParam(ctx, in, ow)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := e.logParamPush(t.ID, out); err != nil {
+		ow.Warnw("failed to record param update in the task log", "err", err)
+	}
+
+	return out, nil
+}
+
+// logParamPush appends the delivery of a parameter update, and its
+// acknowledgments, to the log of the task, so that they show in the timeline
+// of the run next to the output of the instances.
+func (e *Engine) logParamPush(taskID string, out *api.ParamPushOutput) error {
+	path := filepath.Join(e.EnvConfig().Dirs().Daemon(), taskID+".out")
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	tow := rpc.NewFileOutputWriter(f)
+
+	u := out.Update
+	tow.Infow("param update pushed", "id", u.ID, "group", u.Group, "key", u.Key, "value", u.Value, "expected", out.Expected)
+	for _, ack := range out.Acks {
+		if ack.Error != "" {
+			tow.Warnw("param update rejected", "id", u.ID, "instance", ack.Instance, "err", ack.Error, "after", ack.Time.Sub(u.Time))
+			continue
+		}
+		tow.Infow("param update acknowledged", "id", u.ID, "instance", ack.Instance, "after", ack.Time.Sub(u.Time))
+	}
+	if len(out.Acks) < out.Expected {
+		tow.Warnw("param update not acknowledged by all instances", "id", u.ID, "acked", len(out.Acks), "expected", out.Expected)
+	}
+	return nil
+}
@@ -0,0 +1,499 @@
+package engine
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/rs/xid"
+	"go.opentelemetry.io/otel/attribute"
+
+	"github.com/testground/testground/pkg/api"
+	"github.com/testground/testground/pkg/data"
+	"github.com/testground/testground/pkg/logging"
+	"github.com/testground/testground/pkg/metrics"
+	"github.com/testground/testground/pkg/rpc"
+	"github.com/testground/testground/pkg/task"
+	"github.com/testground/testground/pkg/tracing"
+)
+
+// upstreamOutputs locates the outputs of the tasks that ran the dependencies
+// of a run in a pipeline, so that the runner can hand them to the instances.
+func (e *Engine) upstreamOutputs(run api.Runner, compRun *api.Run, upstream map[string]string) (map[string]string, error) {
+	locator, ok := run.(api.OutputsLocator)
+	if !ok {
+		return nil, fmt.Errorf("runner %s does not support consuming the outputs of upstream runs", run.ID())
+	}
+
+	inputs := make(map[string]string, len(compRun.DependsOn))
+	for _, dep := range compRun.DependsOn {
+		id, ok := upstream[dep]
+		if !ok {
+			return nil, fmt.Errorf("missing upstream task for run %s", dep)
+		}
+
+		tsk, err := e.GetTask(id)
+		if err != nil {
+			return nil, fmt.Errorf("could not get upstream task %s: %w", id, err)
+		}
+
+		if tsk.Type != task.TypeRun || tsk.Runner != run.ID() {
+			return nil, fmt.Errorf("upstream task %s is not a %s run", id, run.ID())
+		}
+
+		if outcome, err := data.DecodeTaskOutcome(tsk); err != nil || outcome != task.OutcomeSuccess {
+			return nil, fmt.Errorf("upstream task %s of run %s did not succeed", id, dep)
+		}
+
+		if inputs[dep], err = locator.LocateOutputs(clean(tsk.Plan), id); err != nil {
+			return nil, fmt.Errorf("could not locate the outputs of upstream task %s: %w", id, err)
+		}
+	}
+
+	return inputs, nil
+}
+
+// pipelinePollInterval is how often the runs of a pipeline are checked for
+// completion.
+var pipelinePollInterval = time.Second
+
+// planRuns expands the sweeps of the composition of a run request, and
+// resolves the runs it requests: all the runs of the composition if it names
+// none, all the runs of a sweep for the id of a swept run, and the runs they
+// depend on, ordered before them.
+func planRuns(request *api.RunRequest) error {
+	comp, err := request.Composition.ExpandSweeps()
+	if err != nil {
+		return fmt.Errorf("failed to expand sweeps: %w", err)
+	}
+
+	runIds := request.RunIds
+	if len(runIds) == 0 {
+		runIds = comp.ListRunIds()
+	} else {
+		runIds = comp.ResolveRunIds(runIds)
+	}
+	if runIds, err = comp.PipelineOrder(runIds); err != nil {
+		return err
+	}
+	if len(runIds) == 0 {
+		return fmt.Errorf("composition has no runs")
+	}
+
+	request.Composition = *comp
+	request.RunIds = runIds
+	return nil
+}
+
+// queuePipeline creates the task of a pipeline, and processes it right away.
+// A pipeline takes no worker; it queues its runs, which do.
+func (e *Engine) queuePipeline(request *api.RunRequest, sources *api.UnpackedSources) (string, error) {
+	now := time.Now().UTC()
+	tsk := &task.Task{
+		Version:     task.CurrentVersion,
+		Priority:    request.Priority,
+		Plan:        request.Composition.Global.Plan,
+		Case:        request.Composition.Global.Case,
+		ID:          xid.New().String(),
+		Runner:      request.Composition.Global.Runner,
+		Type:        task.TypePipeline,
+		Composition: request.Composition,
+		Input: &RunInput{
+			RunRequest: request,
+			Sources:    sources,
+		},
+		States: []task.DatedState{
+			{State: task.StateScheduled, Created: now},
+			{State: task.StateProcessing, Created: now},
+		},
+		CreatedBy:  task.CreatedBy(request.CreatedBy),
+		PlanSource: (*task.PlanSource)(request.PlanSource),
+	}
+
+	// The output is created before returning, for clients to follow it.
+	file := filepath.Join(e.EnvConfig().Dirs().Daemon(), tsk.ID+".out")
+	f, err := os.OpenFile(file, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return "", fmt.Errorf("could not create the output of the pipeline: %w", err)
+	}
+
+	if err := e.store.PersistProcessing(tsk); err != nil {
+		f.Close()
+		return "", err
+	}
+
+	ch := make(chan int)
+	e.addSignal(tsk.ID, ch)
+	e.runningLk.Lock()
+	e.pipelines[tsk.ID] = struct{}{}
+	e.runningLk.Unlock()
+	e.publishQueued(tsk)
+	go e.processPipeline(tsk, f, ch)
+
+	return tsk.ID, nil
+}
+
+// processPipeline runs the runs of a pipeline, and archives it with their
+// results once they're all done.
+func (e *Engine) processPipeline(tsk *task.Task, f *os.File, ch chan int) {
+	defer f.Close()
+	defer e.deleteSignal(tsk.ID)
+	defer func() {
+		e.runningLk.Lock()
+		delete(e.pipelines, tsk.ID)
+		e.runningLk.Unlock()
+	}()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	ctx = tracing.Extract(ctx, traceContext(tsk))
+	ctx, span := tracing.Start(ctx, "task "+string(tsk.Type), attribute.String("task_id", tsk.ID), attribute.String("name", tsk.Name()))
+	defer span.End()
+
+	go func() {
+		select {
+		case <-ch:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	running := metrics.TasksRunning.WithLabelValues(string(tsk.Type))
+	running.Inc()
+	defer running.Dec()
+
+	ow := rpc.NewFileOutputWriter(f)
+	res, errTask := e.doPipeline(ctx, tsk, ow)
+
+	newState := task.DatedState{
+		Created: time.Now().UTC(),
+		State:   task.StateComplete,
+	}
+	if errTask != nil {
+		span.RecordError(errTask)
+		tsk.Error = errTask.Error()
+		newState.State = task.StateCanceled
+	}
+	reason, canceled := e.takeCanceled(tsk.ID)
+	if canceled {
+		markCanceled(tsk, &newState, res, reason)
+		ow.Infow("task canceled", "task_id", tsk.ID, "reason", reason)
+	}
+	ow.Infow("pipeline finished", "task_id", tsk.ID, "outcome", res.Outcome)
+
+	tsk.States = append(tsk.States, newState)
+	tsk.Result = res
+	metrics.TasksFinished.WithLabelValues(string(tsk.Type), string(res.Outcome)).Inc()
+
+	if err := e.store.PersistProcessing(tsk); err != nil {
+		logging.S().Errorw("could not persist task", "task_id", tsk.ID, "err", err)
+		return
+	}
+	if err := e.store.ArchiveTask(tsk); err != nil {
+		logging.S().Errorw("could not archive task", "task_id", tsk.ID, "err", err)
+		return
+	}
+
+	e.publishCompleted(tsk)
+	e.notify(tsk, errTask, canceled)
+}
+
+// pipelineRunDone is sent once a run of a pipeline is done.
+type pipelineRunDone struct {
+	runId string
+	tsk   *task.Task
+	err   error
+}
+
+// doPipeline queues the runs of a pipeline after the runs they depend on, up
+// to the number of concurrent runs of the composition, and aggregates their
+// results. If there are artifacts to build, the first run builds them alone;
+// the others reuse them. Runs whose upstream runs didn't succeed are skipped, and
+// the runs that would likely complete after the deadline of the pipeline are
+// shed. Canceling the context cancels the runs.
+func (e *Engine) doPipeline(ctx context.Context, tsk *task.Task, ow *rpc.OutputWriter) (*api.PipelineResult, error) {
+	input := tsk.Input.(*RunInput)
+	comp := &input.Composition
+
+	limit := comp.Global.ConcurrentRuns
+	if limit < 1 {
+		limit = 1
+	}
+
+	var (
+		res     = &api.PipelineResult{Outcome: task.OutcomeUnknown}
+		results = make(map[string]*api.PipelineRunResult, len(input.RunIds))
+		pending = append([]string(nil), input.RunIds...)
+		running = make(map[string]time.Time)
+		done    = make(chan pipelineRunDone, len(input.RunIds))
+		took    []time.Duration
+		// built is the composition with the artifacts built by the first
+		// run, once it completed.
+		built *api.Composition
+	)
+	for _, id := range input.RunIds {
+		r := &api.PipelineRunResult{RunID: id, Outcome: task.OutcomeUnknown}
+		if run, err := comp.GetRun(id); err == nil {
+			r.SweptFrom, r.SweptParams = run.SweptFrom, run.SweptParams
+		}
+		res.Runs = append(res.Runs, r)
+		results[id] = r
+	}
+	tsk.Result = res
+
+	// With nothing to build, runs needn't wait for a first run to build it.
+	if len(input.BuildGroups) == 0 {
+		built = comp
+	}
+
+	ow.Infow("running pipeline", "task_id", tsk.ID, "runs", len(pending), "concurrent_runs", limit)
+
+	for len(pending) > 0 || len(running) > 0 {
+		for i := 0; i < len(pending) && len(running) < limit && (built != nil || len(running) == 0); {
+			r := results[pending[i]]
+			upstream, reason, ready := pipelineUpstream(comp, r.RunID, results)
+			if !ready {
+				i++
+				continue
+			}
+			pending = append(pending[:i], pending[i+1:]...)
+
+			switch {
+			case reason != "":
+				r.Outcome, r.Error = task.OutcomeCanceled, "skipped: "+reason
+				ow.Warnw("skipping run", "run_id", r.RunID, "reason", reason)
+				continue
+			case atRisk(input.Deadline, took, time.Now()):
+				r.Outcome, r.Error, r.Shed = task.OutcomeCanceled, "shed: deadline at risk", true
+				ow.Warnw("shedding run to meet the deadline", "run_id", r.RunID, "deadline", input.Deadline)
+				continue
+			}
+
+			id, err := e.queueRun(pipelineRunRequest(input.RunRequest, tsk.ID, built, r.RunID, upstream), input.Sources)
+			if err != nil {
+				r.Outcome, r.Error = task.OutcomeFailure, fmt.Sprintf("failed to queue run: %s", err)
+				ow.Warnw("failed to queue run", "run_id", r.RunID, "err", err)
+				continue
+			}
+			r.TaskID = id
+			running[r.RunID] = time.Now()
+			ow.Infow("queued run", "run_id", r.RunID, "task_id", id)
+
+			go func(runId string, id string) {
+				tsk, err := e.waitForRun(ctx, id)
+				done <- pipelineRunDone{runId: runId, tsk: tsk, err: err}
+			}(r.RunID, id)
+		}
+		e.persistPipeline(tsk)
+
+		if len(running) == 0 {
+			break
+		}
+
+		select {
+		case d := <-done:
+			r := results[d.runId]
+			r.Took = time.Since(running[d.runId])
+			delete(running, d.runId)
+			took = append(took, r.Took)
+
+			if d.err != nil {
+				r.Outcome, r.Error = task.OutcomeFailure, d.err.Error()
+				ow.Warnw("failed to follow run", "run_id", r.RunID, "task_id", r.TaskID, "err", d.err)
+				continue
+			}
+
+			r.TaskID, r.Error, r.Result = d.tsk.ID, d.tsk.Error, d.tsk.Result
+			if r.Outcome, _ = data.DecodeTaskOutcome(d.tsk); r.Outcome == "" {
+				r.Outcome = task.OutcomeFailure
+			}
+			ow.Infow("run finished", "run_id", r.RunID, "task_id", r.TaskID, "outcome", r.Outcome)
+
+			if built == nil {
+				if built = builtComposition(d.tsk, input.BuildGroups); built != nil {
+					tsk.Composition = *built
+				}
+			}
+		case <-ctx.Done():
+			for runId := range running {
+				r := results[runId]
+				if err := e.Cancel(e.lastAttempt(r.TaskID), "pipeline "+tsk.ID); err != nil {
+					ow.Warnw("failed to cancel run", "run_id", runId, "task_id", r.TaskID, "err", err)
+				}
+			}
+			for _, r := range res.Runs {
+				if r.Outcome == task.OutcomeUnknown {
+					r.Outcome, r.Error = task.OutcomeCanceled, "canceled"
+				}
+			}
+			res.Outcome = task.OutcomeCanceled
+			return res, ctx.Err()
+		}
+	}
+
+	res.Outcome = task.OutcomeSuccess
+	for _, r := range res.Runs {
+		if r.Outcome == task.OutcomeUnknown {
+			r.Outcome, r.Error = task.OutcomeCanceled, "skipped: upstream runs not done"
+		}
+		if !r.Shed && r.Outcome != task.OutcomeSuccess {
+			res.Outcome = task.OutcomeFailure
+		}
+	}
+	return res, nil
+}
+
+// pipelineUpstream returns the ids of the tasks that ran the dependencies of
+// a run of a pipeline, once they're all done. If any of them didn't succeed,
+// it returns the reason why the run can't be executed instead.
+func pipelineUpstream(comp *api.Composition, runId string, results map[string]*api.PipelineRunResult) (map[string]string, string, bool) {
+	run, err := comp.GetRun(runId)
+	if err != nil || len(run.DependsOn) == 0 {
+		return nil, "", true
+	}
+
+	upstream := make(map[string]string, len(run.DependsOn))
+	for _, dep := range run.DependsOn {
+		r, ok := results[dep]
+		switch {
+		case !ok:
+			return nil, fmt.Sprintf("upstream run %s is not part of the pipeline", dep), true
+		case r.Outcome == task.OutcomeUnknown:
+			return nil, "", false
+		case r.Outcome != task.OutcomeSuccess:
+			return nil, fmt.Sprintf("upstream run %s did not succeed", dep), true
+		}
+		upstream[dep] = r.TaskID
+	}
+	return upstream, "", true
+}
+
+// pipelineRunRequest returns the request of a run of a pipeline. Once the
+// artifacts are built, runs use them rather than building them again.
+func pipelineRunRequest(base *api.RunRequest, pipeline string, built *api.Composition, runId string, upstream map[string]string) *api.RunRequest {
+	req := *base
+	req.Pipeline = pipeline
+	req.RunIds = []string{runId}
+	req.Upstream = upstream
+	req.Deadline = nil
+	if built != nil {
+		req.Composition = *built
+		req.BuildGroups = nil
+	}
+	return &req
+}
+
+// builtComposition returns the composition a run ran, if it holds the
+// artifacts of all the groups the run was to build.
+func builtComposition(tsk *task.Task, buildGroups []int) *api.Composition {
+	var comp api.Composition
+	if b, err := json.Marshal(tsk.Composition); err != nil || json.Unmarshal(b, &comp) != nil {
+		return nil
+	}
+	for _, idx := range buildGroups {
+		if idx >= len(comp.Groups) || comp.Groups[idx].Run.Artifact == "" {
+			return nil
+		}
+	}
+	return &comp
+}
+
+// atRisk returns true if starting one more run would likely complete after
+// the deadline, estimating its duration from the runs done so far.
+func atRisk(deadline *time.Time, took []time.Duration, now time.Time) bool {
+	if deadline == nil {
+		return false
+	}
+
+	var estimate time.Duration
+	if len(took) > 0 {
+		var total time.Duration
+		for _, d := range took {
+			total += d
+		}
+		estimate = total / time.Duration(len(took))
+	}
+
+	return now.Add(estimate).After(*deadline)
+}
+
+// waitForRun waits for a run task to complete, following its retries, and
+// returns its last attempt.
+func (e *Engine) waitForRun(ctx context.Context, id string) (*task.Task, error) {
+	ticker := time.NewTicker(pipelinePollInterval)
+	defer ticker.Stop()
+
+	for {
+		tsk, err := e.store.Get(id)
+		if err != nil {
+			return nil, err
+		}
+
+		switch tsk.State().State {
+		case task.StateComplete, task.StateCanceled:
+			if tsk.RetriedBy == "" {
+				return tsk, nil
+			}
+			id = tsk.RetriedBy
+			continue
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// lastAttempt returns the id of the last attempt at a run task.
+func (e *Engine) lastAttempt(id string) string {
+	for {
+		tsk, err := e.store.Get(id)
+		if err != nil || tsk.RetriedBy == "" {
+			return id
+		}
+		id = tsk.RetriedBy
+	}
+}
+
+// persistPipeline persists the progress of a pipeline.
+func (e *Engine) persistPipeline(tsk *task.Task) {
+	if err := e.store.PersistProcessing(tsk); err != nil {
+		logging.S().Errorw("could not persist task", "task_id", tsk.ID, "err", err)
+	}
+}
+
+// interruptPipeline cancels the queued runs of a pipeline a restart of the
+// daemon interrupted, and returns its result, failed.
+func (e *Engine) interruptPipeline(tsk *task.Task, ow *rpc.OutputWriter) *api.PipelineResult {
+	res := decodePipelineResult(tsk.Result)
+	for _, r := range res.Runs {
+		if r.Outcome != task.OutcomeUnknown {
+			continue
+		}
+		r.Outcome, r.Error = task.OutcomeCanceled, errInterrupted.Error()
+		if r.TaskID == "" {
+			continue
+		}
+		if _, err := e.queue.Cancel(e.lastAttempt(r.TaskID), "pipeline "+errInterrupted.Error()); err != nil {
+			ow.Warnw("could not cancel the run of the pipeline", "run_id", r.RunID, "task_id", r.TaskID, "err", err)
+		}
+	}
+	res.Outcome = task.OutcomeFailure
+	return res
+}
+
+// decodePipelineResult decodes the result of a pipeline task, which is
+// generic once the task is read back from storage.
+func decodePipelineResult(result interface{}) *api.PipelineResult {
+	res := &api.PipelineResult{}
+	if b, err := json.Marshal(result); err == nil {
+		_ = json.Unmarshal(b, res)
+	}
+	return res
+}
@@ -0,0 +1,259 @@
+package engine
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/testground/testground/pkg/api"
+	"github.com/testground/testground/pkg/config"
+	"github.com/testground/testground/pkg/data"
+	"github.com/testground/testground/pkg/rpc"
+	"github.com/testground/testground/pkg/runner"
+	"github.com/testground/testground/pkg/task"
+)
+
+// pipelineComposition has a sweep of two runs, a run that depends on it, and
+// a run that depends on a run that fails.
+func pipelineComposition() api.Composition {
+	run := func(id string, deps ...string) *api.Run {
+		return &api.Run{
+			ID:        id,
+			DependsOn: deps,
+			Groups:    api.CompositionRunGroups{{ID: "nodes", Instances: api.Instances{Count: 1}}},
+		}
+	}
+
+	sweep := run("sweep")
+	sweep.Sweep = &api.Sweep{TestParams: map[string][]string{"latency": {"50ms", "100ms"}}}
+
+	return api.Composition{
+		Global: api.Global{Plan: "network", Case: "ping-pong", Builder: "docker:fake", Runner: "local:fake", ConcurrentRuns: 2},
+		Groups: api.Groups{{ID: "nodes", Run: api.RunParams{Artifact: "image"}}},
+		Runs:   api.Runs{sweep, run("analyse", "sweep"), run("broken"), run("after-broken", "broken")},
+	}
+}
+
+func newPipelineEngine(t *testing.T, run *fakeRunner) *Engine {
+	prev := pipelinePollInterval
+	pipelinePollInterval = 10 * time.Millisecond
+	t.Cleanup(func() { pipelinePollInterval = prev })
+
+	return newTestEngine(t, func(envcfg *config.EnvConfig) {
+		envcfg.Daemon.Scheduler.Workers = 2
+	}, run)
+}
+
+func pipelineRequest() *api.RunRequest {
+	return &api.RunRequest{
+		Composition: pipelineComposition(),
+		Manifest: api.TestPlanManifest{
+			Name:      "network",
+			Runners:   map[string]config.ConfigMap{"local:fake": {}},
+			TestCases: []*api.TestCase{{Name: "ping-pong", Instances: api.InstanceConstraints{Minimum: 1, Maximum: 10}}},
+		},
+	}
+}
+
+func waitForTask(t *testing.T, e *Engine, id string) *task.Task {
+	var tsk *task.Task
+	require.Eventually(t, func() bool {
+		var err error
+		tsk, err = e.GetTask(id)
+		require.NoError(t, err)
+		st := tsk.State().State
+		return st == task.StateComplete || st == task.StateCanceled
+	}, 10*time.Second, 10*time.Millisecond)
+	return tsk
+}
+
+func TestPlanRuns(t *testing.T) {
+	req := &api.RunRequest{Composition: pipelineComposition(), RunIds: []string{"analyse"}}
+	require.NoError(t, planRuns(req))
+	require.Equal(t, []string{"sweep-1", "sweep-2", "analyse"}, req.RunIds)
+	require.Len(t, req.Composition.Runs, 5)
+
+	// All the runs by default, every run after the runs it depends on.
+	req = &api.RunRequest{Composition: pipelineComposition()}
+	require.NoError(t, planRuns(req))
+	require.ElementsMatch(t, []string{"sweep-1", "sweep-2", "analyse", "broken", "after-broken"}, req.RunIds)
+	pos := make(map[string]int)
+	for i, id := range req.RunIds {
+		pos[id] = i
+	}
+	require.Less(t, pos["sweep-2"], pos["analyse"])
+	require.Less(t, pos["broken"], pos["after-broken"])
+
+	req = &api.RunRequest{Composition: pipelineComposition(), RunIds: []string{"unknown"}}
+	require.Error(t, planRuns(req))
+}
+
+func TestPipeline(t *testing.T) {
+	var (
+		lk      sync.Mutex
+		inputs  = make(map[string]map[string]string)
+		running int
+		peak    int
+	)
+	run := &fakeRunner{dir: t.TempDir()}
+	run.run = func(ctx context.Context, input *api.RunInput) (*api.RunOutput, error) {
+		lk.Lock()
+		inputs[input.RunID] = input.Inputs
+		running++
+		if running > peak {
+			peak = running
+		}
+		lk.Unlock()
+
+		// The second run of the sweep waits for the run it runs alongside,
+		// which workers may pick up to a second later.
+		wait := time.Now().Add(5 * time.Second)
+		for input.Groups[0].Parameters["latency"] == "100ms" && time.Now().Before(wait) {
+			lk.Lock()
+			both := running == 2
+			lk.Unlock()
+			if both {
+				break
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		time.Sleep(50 * time.Millisecond)
+
+		lk.Lock()
+		running--
+		lk.Unlock()
+
+		outcome := task.OutcomeSuccess
+		if input.Groups[0].Parameters["broken"] == "true" {
+			outcome = task.OutcomeFailure
+		}
+		return &api.RunOutput{RunID: input.RunID, Result: &runner.Result{Outcome: outcome}}, nil
+	}
+	e := newPipelineEngine(t, run)
+
+	req := pipelineRequest()
+	req.Composition.Runs[2].Groups[0].TestParams = map[string]string{"broken": "true"}
+	id, err := e.QueueRun(req, nil)
+	require.NoError(t, err)
+
+	tsk := waitForTask(t, e, id)
+	require.Equal(t, task.TypePipeline, tsk.Type)
+	require.Equal(t, task.StateComplete, tsk.State().State)
+	outcome, err := data.DecodeTaskOutcome(tsk)
+	require.NoError(t, err)
+	require.Equal(t, task.OutcomeFailure, outcome)
+
+	res := decodePipelineResult(tsk.Result)
+	require.Len(t, res.Runs, 5)
+
+	byRun := make(map[string]*api.PipelineRunResult)
+	for _, r := range res.Runs {
+		byRun[r.RunID] = r
+	}
+
+	// The runs of the sweep ran concurrently, and handed their outputs to
+	// the run that depends on them.
+	for _, id := range []string{"sweep-1", "sweep-2", "analyse"} {
+		require.Equal(t, task.OutcomeSuccess, byRun[id].Outcome, id)
+		require.NotEmpty(t, byRun[id].TaskID)
+	}
+	require.Equal(t, "sweep", byRun["sweep-2"].SweptFrom)
+	require.Equal(t, map[string]string{"latency": "100ms"}, byRun["sweep-2"].SweptParams)
+	require.Equal(t, 2, peak)
+
+	lk.Lock()
+	analysed := inputs[byRun["analyse"].TaskID]
+	lk.Unlock()
+	require.Len(t, analysed, 2)
+	require.Contains(t, analysed, "sweep-1")
+
+	// The run that depends on a failed run was skipped.
+	require.Equal(t, task.OutcomeFailure, byRun["broken"].Outcome)
+	require.Equal(t, task.OutcomeCanceled, byRun["after-broken"].Outcome)
+	require.Empty(t, byRun["after-broken"].TaskID)
+	require.Contains(t, byRun["after-broken"].Error, "upstream run broken did not succeed")
+
+	// Every run is a task of its own.
+	child, err := e.GetTask(byRun["sweep-1"].TaskID)
+	require.NoError(t, err)
+	require.Equal(t, task.TypeRun, child.Type)
+}
+
+func TestPipelineCancel(t *testing.T) {
+	run := &fakeRunner{}
+	run.run = func(ctx context.Context, input *api.RunInput) (*api.RunOutput, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+	e := newPipelineEngine(t, run)
+
+	id, err := e.QueueRun(pipelineRequest(), nil)
+	require.NoError(t, err)
+
+	// Wait for a run to start alongside the pipeline.
+	require.Eventually(t, func() bool {
+		tsks, err := e.store.Filter(task.StateProcessing, time.Time{}, time.Now().Add(time.Second))
+		require.NoError(t, err)
+		return len(tsks) >= 2
+	}, 5*time.Second, 10*time.Millisecond)
+
+	require.NoError(t, e.Cancel(id, "alice"))
+
+	tsk := waitForTask(t, e, id)
+	require.Equal(t, task.StateCanceled, tsk.State().State)
+	require.Contains(t, tsk.Error, "canceled by alice")
+
+	res := decodePipelineResult(tsk.Result)
+	for _, r := range res.Runs {
+		require.Equal(t, task.OutcomeCanceled, r.Outcome, r.RunID)
+	}
+
+	// The runs that were queued or running are canceled too.
+	child := waitForTask(t, e, res.Runs[0].TaskID)
+	require.Equal(t, task.StateCanceled, child.State().State)
+}
+
+func TestAtRisk(t *testing.T) {
+	now := time.Now()
+	deadline := now.Add(30 * time.Minute)
+
+	require.False(t, atRisk(nil, []time.Duration{time.Hour}, now))
+	require.False(t, atRisk(&deadline, nil, now))
+	require.True(t, atRisk(&deadline, nil, now.Add(31*time.Minute)))
+
+	took := []time.Duration{10 * time.Minute, 20 * time.Minute}
+	require.False(t, atRisk(&deadline, took, now.Add(15*time.Minute)))
+	require.True(t, atRisk(&deadline, took, now.Add(16*time.Minute)))
+}
+
+func TestInterruptPipeline(t *testing.T) {
+	e := newTestEngine(t, nil)
+
+	queued := &task.Task{
+		ID:     "c3ftkqjpc98qra498sh0",
+		Type:   task.TypeRun,
+		States: []task.DatedState{{State: task.StateScheduled, Created: time.Now().UTC()}},
+	}
+	require.NoError(t, e.queue.Push(queued))
+
+	tsk := &task.Task{Result: &api.PipelineResult{Runs: []*api.PipelineRunResult{
+		{RunID: "done", TaskID: "c3ftkqjpc98qra498sg0", Outcome: task.OutcomeSuccess},
+		{RunID: "queued", TaskID: queued.ID, Outcome: task.OutcomeUnknown},
+		{RunID: "pending", Outcome: task.OutcomeUnknown},
+	}}}
+
+	res := e.interruptPipeline(tsk, rpc.Discard())
+	require.Equal(t, task.OutcomeFailure, res.Outcome)
+	require.Equal(t, task.OutcomeSuccess, res.Runs[0].Outcome)
+	require.Equal(t, task.OutcomeCanceled, res.Runs[1].Outcome)
+	require.Equal(t, errInterrupted.Error(), res.Runs[2].Error)
+
+	// The queued run is canceled.
+	require.Equal(t, 0, e.queue.Len())
+	canceled, err := e.GetTask(queued.ID)
+	require.NoError(t, err)
+	require.True(t, canceled.IsCanceled())
+}
@@ -0,0 +1,135 @@
+package engine
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/testground/testground/pkg/api"
+	"github.com/testground/testground/pkg/logging"
+	"github.com/testground/testground/pkg/metrics"
+	"github.com/testground/testground/pkg/rpc"
+	"github.com/testground/testground/pkg/task"
+)
+
+// preemptFor preempts a low priority run when a high priority task is queued
+// while all workers are busy, or its runner is at its concurrency limit, so
+// that the task is picked as soon as the run is canceled. At most one run is
+// preempted per queued task.
+func (e *Engine) preemptFor(tsk *task.Task) {
+	if !e.config().Daemon.Scheduler.Preemption || tsk.Priority < task.PriorityHigh {
+		return
+	}
+
+	// Pipelines take no worker, so only the tasks popped by the workers
+	// count towards them.
+	e.runningLk.Lock()
+	inflight := e.inflight
+	e.runningLk.Unlock()
+
+	// When the runner of the task is at its limit, only its own runs free a
+	// slot for the task.
+	runnerFull := !e.hasSlot(tsk)
+	if inflight < e.config().Daemon.Scheduler.Workers && !runnerFull {
+		return
+	}
+
+	e.signalsLk.RLock()
+	busy := make(map[string]struct{}, len(e.signals))
+	for id := range e.signals {
+		busy[id] = struct{}{}
+	}
+	e.signalsLk.RUnlock()
+
+	processing, err := e.store.Filter(task.StateProcessing, time.Time{}, time.Now().UTC())
+	if err != nil {
+		logging.S().Warnw("could not list the running tasks to preempt", "err", err)
+		return
+	}
+	var running []*task.Task
+	for _, t := range processing {
+		if _, ok := busy[t.ID]; ok && (!runnerFull || t.Runner == tsk.Runner) {
+			running = append(running, t)
+		}
+	}
+
+	e.preemptedLk.Lock()
+	victim := selectVictim(running, e.preempted)
+	if victim != nil {
+		e.preempted[victim.ID] = tsk.ID
+	}
+	e.preemptedLk.Unlock()
+
+	if victim == nil {
+		logging.S().Infow("no low priority run to preempt", "task_id", tsk.ID)
+		return
+	}
+
+	e.decisionsLk.RLock()
+	if l, ok := e.decisions[victim.ID]; ok {
+		l.Record(api.DecisionStageQueue, "preempted by high priority task %s", tsk.ID)
+	}
+	e.decisionsLk.RUnlock()
+
+	logging.S().Infow("preempting run", "task_id", victim.ID, "priority", victim.Priority, "preempted_by", tsk.ID)
+	_ = e.Kill(victim.ID)
+}
+
+// selectVictim returns the run to preempt among the running tasks: the one
+// of lowest priority, among the low priority ones, that was started last, as
+// it has the least work to lose. Runs already preempted are skipped.
+func selectVictim(running []*task.Task, preempted map[string]string) *task.Task {
+	var victim *task.Task
+	for _, t := range running {
+		if t.Type != task.TypeRun || task.PriorityClass(t.Priority) != "low" {
+			continue
+		}
+		if _, ok := preempted[t.ID]; ok {
+			continue
+		}
+		switch {
+		case victim == nil, t.Priority < victim.Priority:
+			victim = t
+		case t.Priority == victim.Priority && t.State().Created.After(victim.State().Created):
+			victim = t
+		}
+	}
+	return victim
+}
+
+// takePreempted returns the task that preempted a run, if it was preempted,
+// and forgets about it.
+func (e *Engine) takePreempted(id string) (string, bool) {
+	e.preemptedLk.Lock()
+	defer e.preemptedLk.Unlock()
+
+	by, ok := e.preempted[id]
+	delete(e.preempted, id)
+	return by, ok
+}
+
+// requeuePreempted queues a preempted run again. The new task is not a new
+// attempt, as far as the retry policy of the run is concerned.
+func (e *Engine) requeuePreempted(tsk *task.Task, by string, ow *rpc.OutputWriter) {
+	tsk.Error = fmt.Sprintf("preempted by task %s", by)
+
+	input, ok := tsk.Input.(*RunInput)
+	if !ok {
+		return
+	}
+
+	attempt := tsk.Attempt
+	if attempt == 0 {
+		attempt = 1
+	}
+	next := nextAttempt(tsk, input, attempt)
+
+	if err := e.queue.Push(next); err != nil {
+		logging.S().Errorw("could not queue preempted run", "task_id", tsk.ID, "err", err)
+		return
+	}
+	metrics.TasksQueued.Set(float64(e.queue.Len()))
+	e.publishQueued(next)
+	tsk.RetriedBy = next.ID
+
+	ow.Infow("run preempted, queued again", "task_id", tsk.ID, "preempted_by", by, "requeued_as", next.ID)
+}
@@ -0,0 +1,91 @@
+package engine
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/testground/testground/pkg/api"
+	"github.com/testground/testground/pkg/config"
+	"github.com/testground/testground/pkg/rpc"
+	"github.com/testground/testground/pkg/task"
+)
+
+func runningTask(id string, priority int, started time.Time) *task.Task {
+	return &task.Task{
+		ID:       id,
+		Type:     task.TypeRun,
+		Priority: priority,
+		Input:    &RunInput{RunRequest: &api.RunRequest{}},
+		States: []task.DatedState{
+			{State: task.StateScheduled, Created: started.Add(-time.Minute)},
+			{State: task.StateProcessing, Created: started},
+		},
+	}
+}
+
+func TestSelectVictim(t *testing.T) {
+	now := time.Now()
+	running := []*task.Task{
+		runningTask("normal", task.PriorityNormal, now),
+		runningTask("low-old", task.PriorityLow, now.Add(-time.Hour)),
+		runningTask("low-new", task.PriorityLow, now.Add(-time.Minute)),
+		runningTask("low-waited", task.PriorityLow+1, now),
+	}
+
+	require.Equal(t, "low-new", selectVictim(running, nil).ID)
+	require.Equal(t, "low-old", selectVictim(running, map[string]string{"low-new": "x"}).ID)
+	require.Nil(t, selectVictim(running[:1], nil))
+}
+
+func TestPreemption(t *testing.T) {
+	e := newTestEngine(t, func(envcfg *config.EnvConfig) {
+		envcfg.Daemon.Scheduler.Preemption = true
+	})
+
+	// Pretend a single worker is busy with a low priority run.
+	e.envcfg.Daemon.Scheduler.Workers = 1
+	soak := runningTask("c3ftkqjpc98qra498sg0", task.PriorityLow, time.Now().UTC())
+	require.NoError(t, e.store.PersistProcessing(soak))
+	ch := make(chan int)
+	e.addSignal(soak.ID, ch)
+
+	e.inflight = 1
+
+	// Normal priority tasks wait.
+	e.preemptFor(&task.Task{ID: "normal", Priority: task.PriorityNormal + 1})
+	_, ok := e.takePreempted(soak.ID)
+	require.False(t, ok)
+
+	// Pipelines take no worker; with one of two workers idle, nothing is
+	// preempted.
+	e.envcfg.Daemon.Scheduler.Workers = 2
+	e.addSignal("c3ftkqjpc98qra498si0", make(chan int))
+	e.preemptFor(&task.Task{ID: "ci", Priority: task.PriorityHigh + 1})
+	_, ok = e.takePreempted(soak.ID)
+	require.False(t, ok)
+	e.envcfg.Daemon.Scheduler.Workers = 1
+
+	e.preemptFor(&task.Task{ID: "ci", Priority: task.PriorityHigh + 1})
+	select {
+	case <-ch:
+	case <-time.After(time.Second):
+		t.Fatal("low priority run not killed")
+	}
+
+	by, ok := e.takePreempted(soak.ID)
+	require.True(t, ok)
+	require.Equal(t, "ci", by)
+
+	e.requeuePreempted(soak, by, rpc.Discard())
+	require.Equal(t, "preempted by task ci", soak.Error)
+	require.Equal(t, 1, e.queue.Len())
+
+	next, err := e.queue.Pop()
+	require.NoError(t, err)
+	require.Equal(t, soak.RetriedBy, next.ID)
+	require.Equal(t, soak.ID, next.RetryOf)
+	require.Equal(t, task.PriorityLow, next.Priority)
+	require.Equal(t, 1, next.Attempt)
+}
@@ -0,0 +1,51 @@
+package engine
+
+import (
+	"github.com/testground/testground/pkg/api"
+	"github.com/testground/testground/pkg/plansource"
+	"github.com/testground/testground/pkg/version"
+)
+
+// runProvenance records what a run is executed from: the composition as
+// prepared for the run, the resolved configuration of its runner, and the
+// builds of its groups.
+func runProvenance(input *RunInput, comp *api.Composition, runID, planHash string, runnerCfg map[string]interface{}, built map[string]*api.BuildOutput) *api.Provenance {
+	prov := &api.Provenance{
+		Version:      version.GitCommit,
+		RunID:        runID,
+		PlanSource:   input.PlanSource,
+		PlanHash:     planHash,
+		Manifest:     input.Manifest,
+		Composition:  *comp,
+		Runner:       comp.Global.Runner,
+		RunnerConfig: runnerCfg,
+		Upstream:     input.Upstream,
+		Env:          input.Env,
+		Groups:       make([]*api.GroupProvenance, 0, len(comp.Groups)),
+	}
+
+	for _, g := range comp.Groups {
+		gp := &api.GroupProvenance{
+			ID:       g.ID,
+			Builder:  g.Builder,
+			Artifact: g.Run.Artifact,
+		}
+		if out, ok := built[g.ID]; ok {
+			gp.Built = true
+			gp.BuildConfig = out.Config
+			gp.Dependencies = out.Dependencies
+		}
+		prov.Groups = append(prov.Groups, gp)
+	}
+	return prov
+}
+
+// planHash returns the hash of the plan sources of a run, if it builds any
+// groups. It's computed before the builds, as builders may change the
+// sources.
+func planHash(input *RunInput) (string, error) {
+	if len(input.BuildGroups) == 0 || input.Sources == nil || input.Sources.PlanDir == "" {
+		return "", nil
+	}
+	return plansource.Hash(input.Sources.PlanDir)
+}
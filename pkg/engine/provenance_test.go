@@ -0,0 +1,69 @@
+package engine
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/testground/testground/pkg/api"
+)
+
+func TestRunProvenance(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "main.go"), []byte("package main"), 0644))
+
+	input := &RunInput{
+		RunRequest: &api.RunRequest{
+			BuildGroups: []int{0},
+			RunIds:      []string{"run"},
+			PlanSource:  &api.PlanSource{URL: "https://github.com/org/plans", Commit: "0123"},
+			Upstream:    map[string]string{"setup": "task1"},
+		},
+		Sources: &api.UnpackedSources{PlanDir: dir},
+	}
+	comp := &api.Composition{
+		Global: api.Global{Plan: "network", Runner: "local:docker"},
+		Groups: api.Groups{
+			{ID: "built", Builder: "docker:go", Run: api.RunParams{Artifact: "sha256:built"}},
+			{ID: "given", Builder: "docker:go", Run: api.RunParams{Artifact: "sha256:given"}},
+		},
+	}
+	built := map[string]*api.BuildOutput{
+		"built": {
+			ArtifactPath: "sha256:built",
+			Dependencies: map[string]string{"github.com/testground/sdk-go": "v0.3.0"},
+			Config:       map[string]interface{}{"go_version": "1.16"},
+		},
+	}
+
+	hash, err := planHash(input)
+	require.NoError(t, err)
+	require.NotEmpty(t, hash)
+
+	prov := runProvenance(input, comp, "run", hash, map[string]interface{}{"keep_service": true}, built)
+	require.Equal(t, "run", prov.RunID)
+	require.Equal(t, "local:docker", prov.Runner)
+	require.Equal(t, hash, prov.PlanHash)
+	require.Equal(t, input.PlanSource, prov.PlanSource)
+	require.Equal(t, input.Upstream, prov.Upstream)
+	require.Equal(t, map[string]interface{}{"keep_service": true}, prov.RunnerConfig)
+
+	require.Len(t, prov.Groups, 2)
+	require.Equal(t, &api.GroupProvenance{
+		ID:           "built",
+		Builder:      "docker:go",
+		Artifact:     "sha256:built",
+		Built:        true,
+		BuildConfig:  map[string]interface{}{"go_version": "1.16"},
+		Dependencies: map[string]string{"github.com/testground/sdk-go": "v0.3.0"},
+	}, prov.Group("built"))
+	require.Equal(t, &api.GroupProvenance{ID: "given", Builder: "docker:go", Artifact: "sha256:given"}, prov.Group("given"))
+
+	// Runs that build nothing have no plan hash.
+	input.BuildGroups = nil
+	hash, err = planHash(input)
+	require.NoError(t, err)
+	require.Empty(t, hash)
+}
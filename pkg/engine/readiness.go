@@ -0,0 +1,141 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/testground/testground/pkg/api"
+	"github.com/testground/testground/pkg/rpc"
+)
+
+// readinessWatch probes the instances of a live run until enough of them are
+// ready, and aborts the run if they aren't by the deadline of its readiness
+// gate.
+type readinessWatch struct {
+	input  *api.ReadinessInput
+	gate   *api.ReadinessGate
+	prober api.ReadinessProber
+	ow     *rpc.OutputWriter
+	abort  context.CancelFunc
+
+	// ready are the instances reported ready so far.
+	ready map[string]bool
+
+	done chan struct{}
+	err  error
+}
+
+// newReadinessWatch returns a watch of the instances of a run, or nil if
+// there is nothing to watch. Without a gate, the readiness of the instances is
+// reported, and the run is never aborted.
+func newReadinessWatch(run api.Runner, in *api.RunInput, probe *api.ReadinessProbe, gate *api.ReadinessGate, ow *rpc.OutputWriter, abort context.CancelFunc) (*readinessWatch, error) {
+	if probe == nil {
+		if gate != nil {
+			return nil, fmt.Errorf("the run has a readiness gate, but its test case declares no readiness probe")
+		}
+		return nil, nil
+	}
+	if err := probe.Validate(); err != nil {
+		return nil, err
+	}
+
+	prober, ok := run.(api.ReadinessProber)
+	if !ok {
+		if gate != nil {
+			return nil, fmt.Errorf("runner %s does not support readiness gates", run.ID())
+		}
+		ow.Infow("runner does not support readiness probes; not probing instances", "runner", run.ID())
+		return nil, nil
+	}
+
+	target := in.TotalInstances
+	if gate != nil {
+		target = gate.Target(in.TotalInstances)
+	}
+
+	return &readinessWatch{
+		input: &api.ReadinessInput{
+			RunID:    in.RunID,
+			TestPlan: in.TestPlan,
+			TestCase: in.TestCase,
+			Probe:    probe,
+			Target:   target,
+		},
+		gate:   gate,
+		prober: prober,
+		ow:     ow,
+		abort:  abort,
+		ready:  make(map[string]bool),
+		done:   make(chan struct{}),
+	}, nil
+}
+
+// start probes the instances until enough of them are ready, the deadline of
+// the gate passes, or the context is done.
+func (w *readinessWatch) start(ctx context.Context) {
+	go w.watch(ctx)
+}
+
+// stop waits for the watch to end, once the context passed to start is done,
+// and returns an error if the run was aborted.
+func (w *readinessWatch) stop() error {
+	<-w.done
+	return w.err
+}
+
+func (w *readinessWatch) watch(ctx context.Context) {
+	defer close(w.done)
+
+	var deadline <-chan time.Time
+	if w.gate != nil {
+		d, _ := time.ParseDuration(w.gate.Deadline)
+		timer := time.NewTimer(d)
+		defer timer.Stop()
+		deadline = timer.C
+	}
+
+	ticker := time.NewTicker(w.input.Probe.ProbeInterval())
+	defer ticker.Stop()
+
+	ready := 0
+	for {
+		select {
+		case <-ticker.C:
+		case <-deadline:
+			w.err = fmt.Errorf("readiness gate failed: %d of the %d required instances ready after %s", ready, w.input.Target, w.gate.Deadline)
+			w.ow.Warnw("readiness gate failed; aborting run", "ready", ready, "target", w.input.Target, "deadline", w.gate.Deadline)
+			w.abort()
+			return
+		case <-ctx.Done():
+			return
+		}
+
+		rep, err := w.prober.ProbeReadiness(ctx, w.input)
+		if err != nil {
+			if ctx.Err() == nil {
+				w.ow.Warnw("failed to probe the readiness of instances", "err", err)
+			}
+			continue
+		}
+
+		ready = w.record(ctx, rep)
+		if ready >= w.input.Target {
+			w.ow.Infow("readiness: instances ready", "ready", ready, "target", w.input.Target)
+			return
+		}
+	}
+}
+
+// record reports the instances that became ready, and returns the number of
+// ready instances.
+func (w *readinessWatch) record(ctx context.Context, rep *api.ReadinessReport) int {
+	for _, inst := range rep.Instances {
+		if inst.Ready && !w.ready[inst.Name] {
+			w.ready[inst.Name] = true
+			w.ow.Infow("readiness: instance ready", "instance", inst.Name, "group", inst.Group)
+			api.EmitEvent(ctx, api.Event{Type: api.EventInstanceHealthy, Group: inst.Group, Instance: inst.Name})
+		}
+	}
+	return rep.Ready
+}
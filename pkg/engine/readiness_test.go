@@ -0,0 +1,83 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/testground/testground/pkg/api"
+	"github.com/testground/testground/pkg/rpc"
+)
+
+func TestReadinessWatchPasses(t *testing.T) {
+	run := &fakeRunner{step: 1}
+	probe := &api.ReadinessProbe{Type: api.ProbeTCP, Port: 80, Interval: "1ms"}
+	gate := &api.ReadinessGate{MinReady: 3, Deadline: "1m"}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	w, err := newReadinessWatch(run, &api.RunInput{RunID: "run", TotalInstances: 4}, probe, gate, rpc.Discard(), cancel)
+	require.NoError(t, err)
+	w.start(ctx)
+
+	// The watch ends once enough instances are ready, without aborting the
+	// run.
+	require.NoError(t, w.stop())
+	require.NoError(t, ctx.Err())
+	require.Len(t, w.ready, 3)
+}
+
+func TestReadinessWatchAborts(t *testing.T) {
+	run := &fakeRunner{step: 0}
+	probe := &api.ReadinessProbe{Type: api.ProbeTCP, Port: 80, Interval: "1ms"}
+	gate := &api.ReadinessGate{MinReadyPercentage: 0.5, Deadline: "20ms"}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	w, err := newReadinessWatch(run, &api.RunInput{RunID: "run", TotalInstances: 4}, probe, gate, rpc.Discard(), cancel)
+	require.NoError(t, err)
+	w.start(ctx)
+
+	select {
+	case <-ctx.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("the run was not aborted")
+	}
+	err = w.stop()
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "0 of the 2 required instances")
+	require.False(t, errors.Is(err, context.Canceled))
+}
+
+func TestNewReadinessWatch(t *testing.T) {
+	in := &api.RunInput{RunID: "run", TotalInstances: 4}
+	gate := &api.ReadinessGate{MinReady: 3, Deadline: "1m"}
+	probe := &api.ReadinessProbe{Type: api.ProbeSync, State: "ready"}
+
+	// Nothing to watch.
+	w, err := newReadinessWatch(&fakeRunner{}, in, nil, nil, rpc.Discard(), func() {})
+	require.NoError(t, err)
+	require.Nil(t, w)
+
+	// A gate requires a probe.
+	_, err = newReadinessWatch(&fakeRunner{}, in, nil, gate, rpc.Discard(), func() {})
+	require.Error(t, err)
+
+	// A gate requires a runner that probes instances.
+	_, err = newReadinessWatch(plainRunner(&fakeRunner{}), in, probe, gate, rpc.Discard(), func() {})
+	require.Error(t, err)
+
+	w, err = newReadinessWatch(plainRunner(&fakeRunner{}), in, probe, nil, rpc.Discard(), func() {})
+	require.NoError(t, err)
+	require.Nil(t, w)
+
+	// Without a gate, the watch waits for all the instances.
+	w, err = newReadinessWatch(&fakeRunner{}, in, probe, nil, rpc.Discard(), func() {})
+	require.NoError(t, err)
+	require.Equal(t, 4, w.input.Target)
+}
@@ -0,0 +1,108 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/testground/testground/pkg/api"
+	"github.com/testground/testground/pkg/logging"
+	"github.com/testground/testground/pkg/rpc"
+	"github.com/testground/testground/pkg/runner"
+	"github.com/testground/testground/pkg/task"
+)
+
+// errInterrupted is the error of the tasks a restart of the daemon
+// interrupted.
+var errInterrupted = errors.New("interrupted by a restart of the daemon")
+
+// recoverInterrupted fails the tasks that were being processed when the
+// daemon stopped, rather than processing them again from scratch, and
+// removes the resources their runs left behind. Runs with a retry policy get
+// another attempt. Resources of runs the daemon knows nothing about are only
+// reported.
+func (e *Engine) recoverInterrupted(ctx context.Context) {
+	interrupted := e.queue.RemoveFunc(func(tsk *task.Task) bool {
+		return tsk.State().State == task.StateProcessing
+	})
+	if len(interrupted) == 0 {
+		return
+	}
+
+	// List the runs with resources of the runners involved.
+	resources := make(map[string]map[string]struct{})
+	for _, tsk := range interrupted {
+		if _, ok := resources[tsk.Runner]; ok || tsk.Type != task.TypeRun {
+			continue
+		}
+		reaper, ok := e.runners[tsk.Runner].(api.RunReaper)
+		if !ok {
+			continue
+		}
+		runs, err := reaper.ListRuns(ctx)
+		if err != nil {
+			logging.S().Warnw("could not list the runs with resources", "runner", tsk.Runner, "err", err)
+			continue
+		}
+		resources[tsk.Runner] = make(map[string]struct{}, len(runs))
+		for _, id := range runs {
+			resources[tsk.Runner][id] = struct{}{}
+		}
+	}
+
+	for _, tsk := range interrupted {
+		logging.S().Infow("recovering interrupted task", "task_id", tsk.ID, "type", tsk.Type)
+		e.recoverTask(ctx, tsk, resources[tsk.Runner])
+	}
+
+	for r, runs := range resources {
+		for id := range runs {
+			if _, err := e.store.Get(id); errors.Is(err, task.ErrNotFound) {
+				logging.S().Warnw("resources of an unknown run left behind; remove them with `testground terminate`", "runner", r, "run_id", id)
+			}
+		}
+	}
+}
+
+// recoverTask removes the resources of an interrupted task, if it has any,
+// and archives it as failed. The queued runs of interrupted pipelines are
+// canceled.
+func (e *Engine) recoverTask(ctx context.Context, tsk *task.Task, resources map[string]struct{}) {
+	ow := rpc.Discard()
+	file := filepath.Join(e.EnvConfig().Dirs().Daemon(), tsk.ID+".out")
+	if f, err := os.OpenFile(file, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644); err == nil {
+		defer f.Close()
+		ow = rpc.NewFileOutputWriter(f)
+	}
+	ow.Warnw("task interrupted by a restart of the daemon", "task_id", tsk.ID)
+
+	if _, ok := resources[tsk.ID]; ok {
+		if err := e.runners[tsk.Runner].(api.RunReaper).RemoveRun(ctx, tsk.ID, ow); err != nil {
+			ow.Errorw("could not remove the resources of the run", "runner", tsk.Runner, "err", err)
+		}
+		delete(resources, tsk.ID)
+	}
+
+	tsk.Error = errInterrupted.Error()
+	tsk.States = append(tsk.States, task.DatedState{
+		State:   task.StateComplete,
+		Created: time.Now().UTC(),
+	})
+	switch tsk.Type {
+	case task.TypeRun:
+		tsk.Result = &runner.Result{Outcome: task.OutcomeFailure}
+		e.retryRun(tsk, errInterrupted, ow)
+	case task.TypePipeline:
+		tsk.Result = e.interruptPipeline(tsk, ow)
+	}
+
+	if err := e.store.PersistProcessing(tsk); err != nil {
+		logging.S().Errorw("could not persist task", "task_id", tsk.ID, "err", err)
+		return
+	}
+	if err := e.store.ArchiveTask(tsk); err != nil {
+		logging.S().Errorw("could not archive task", "task_id", tsk.ID, "err", err)
+	}
+}
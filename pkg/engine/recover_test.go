@@ -0,0 +1,52 @@
+package engine
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/testground/testground/pkg/api"
+	"github.com/testground/testground/pkg/data"
+	"github.com/testground/testground/pkg/task"
+)
+
+func TestRecoverInterrupted(t *testing.T) {
+	// The run had started; the other one was left behind by an unknown run.
+	const interrupted, unknown = "c3ftkqjpc98qra498sg0", "c3ftkqjpc98qra498si0"
+	run := &fakeRunner{runs: []string{interrupted, unknown}}
+	e := newTestEngine(t, nil, run)
+
+	now := time.Now().UTC()
+	for _, tsk := range []*task.Task{
+		{ID: interrupted, Type: task.TypeRun, Runner: run.ID(), Input: &RunInput{RunRequest: &api.RunRequest{}}},
+		{ID: "c3ftkqjpc98qra498sh0", Type: task.TypeRun, Runner: run.ID()},
+	} {
+		tsk.States = []task.DatedState{{State: task.StateScheduled, Created: now}}
+		require.NoError(t, e.queue.Push(tsk))
+		if tsk.ID == interrupted {
+			require.NoError(t, e.store.ProcessTask(tsk))
+			tsk.States = append(tsk.States, task.DatedState{State: task.StateProcessing, Created: now})
+			require.NoError(t, e.store.PersistProcessing(tsk))
+		}
+	}
+
+	e.recoverInterrupted(context.Background())
+
+	require.Equal(t, []string{interrupted}, run.removed)
+
+	tsk, err := e.GetTask(interrupted)
+	require.NoError(t, err)
+	require.Equal(t, task.StateComplete, tsk.State().State)
+	require.Equal(t, errInterrupted.Error(), tsk.Error)
+	outcome, err := data.DecodeTaskOutcome(tsk)
+	require.NoError(t, err)
+	require.Equal(t, task.OutcomeFailure, outcome)
+
+	// Tasks that hadn't started stay queued.
+	require.Equal(t, 1, e.queue.Len())
+	next, err := e.queue.Pop()
+	require.NoError(t, err)
+	require.Equal(t, "c3ftkqjpc98qra498sh0", next.ID)
+}
@@ -0,0 +1,116 @@
+package engine
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/testground/testground/pkg/api"
+	"github.com/testground/testground/pkg/config"
+	"github.com/testground/testground/pkg/logging"
+	"github.com/testground/testground/pkg/notify"
+	"github.com/testground/testground/pkg/runner"
+)
+
+// reloadKeys are the settings of the env config compared when it's reloaded.
+// Those with restart set configure what the daemon sets up when it starts,
+// e.g. its listeners, workers and task storage, and only change when it
+// restarts.
+var reloadKeys = []struct {
+	key     string
+	restart bool
+	field   func(c *config.EnvConfig) interface{}
+}{
+	{"aws", false, func(c *config.EnvConfig) interface{} { return &c.AWS }},
+	{"dockerhub", false, func(c *config.EnvConfig) interface{} { return &c.DockerHub }},
+	{"builders", false, func(c *config.EnvConfig) interface{} { return &c.Builders }},
+	{"runners", false, func(c *config.EnvConfig) interface{} { return &c.Runners }},
+	{"daemon.listen", true, func(c *config.EnvConfig) interface{} { return &c.Daemon.Listen }},
+	{"daemon.grpc_listen", true, func(c *config.EnvConfig) interface{} { return &c.Daemon.GRPCListen }},
+	{"daemon.tls", true, func(c *config.EnvConfig) interface{} { return &c.Daemon.TLS }},
+	{"daemon.tokens", true, func(c *config.EnvConfig) interface{} { return &c.Daemon.Tokens }},
+	{"daemon.oidc", true, func(c *config.EnvConfig) interface{} { return &c.Daemon.OIDC }},
+	{"daemon.ui", true, func(c *config.EnvConfig) interface{} { return &c.Daemon.UI }},
+	{"daemon.registry", true, func(c *config.EnvConfig) interface{} { return &c.Daemon.Registry }},
+	{"daemon.scheduler.workers", true, func(c *config.EnvConfig) interface{} { return &c.Daemon.Scheduler.Workers }},
+	{"daemon.scheduler.queue_size", true, func(c *config.EnvConfig) interface{} { return &c.Daemon.Scheduler.QueueSize }},
+	{"daemon.scheduler.task_repo_type", true, func(c *config.EnvConfig) interface{} { return &c.Daemon.Scheduler.TaskRepoType }},
+	{"daemon.scheduler.task_timeout_min", false, func(c *config.EnvConfig) interface{} { return &c.Daemon.Scheduler.TaskTimeoutMin }},
+	{"daemon.scheduler.preemption", false, func(c *config.EnvConfig) interface{} { return &c.Daemon.Scheduler.Preemption }},
+	{"daemon.scheduler.runner_concurrency", false, func(c *config.EnvConfig) interface{} { return &c.Daemon.Scheduler.RunnerConcurrency }},
+	{"daemon.slack_webhook_url", false, func(c *config.EnvConfig) interface{} { return &c.Daemon.SlackWebhookURL }},
+	{"daemon.github_repo_status_token", false, func(c *config.EnvConfig) interface{} { return &c.Daemon.GithubRepoStatusToken }},
+	{"daemon.root_url", false, func(c *config.EnvConfig) interface{} { return &c.Daemon.RootURL }},
+	{"daemon.influxdb_endpoint", false, func(c *config.EnvConfig) interface{} { return &c.Daemon.InfluxDBEndpoint }},
+	{"daemon.retention.max_age_days", false, func(c *config.EnvConfig) interface{} { return &c.Daemon.Retention.MaxAgeDays }},
+	{"daemon.retention.max_total_size_mb", false, func(c *config.EnvConfig) interface{} { return &c.Daemon.Retention.MaxTotalSizeMB }},
+	{"daemon.retention.keep_last_per_plan", false, func(c *config.EnvConfig) interface{} { return &c.Daemon.Retention.KeepLastPerPlan }},
+	{"daemon.retention.interval_min", true, func(c *config.EnvConfig) interface{} { return &c.Daemon.Retention.IntervalMin }},
+	{"daemon.outputs", true, func(c *config.EnvConfig) interface{} { return &c.Daemon.Outputs }},
+	{"daemon.notifications", false, func(c *config.EnvConfig) interface{} { return &c.Daemon.Notifications }},
+	{"daemon.observability", true, func(c *config.EnvConfig) interface{} { return &c.Daemon.Observability }},
+	{"daemon.offline", false, func(c *config.EnvConfig) interface{} { return &c.Daemon.Offline }},
+	{"daemon.scan", false, func(c *config.EnvConfig) interface{} { return &c.Daemon.Scan }},
+	{"daemon.plan_sources", false, func(c *config.EnvConfig) interface{} { return &c.Daemon.PlanSources }},
+	{"tracing", true, func(c *config.EnvConfig) interface{} { return &c.Tracing }},
+}
+
+// Reload replaces the env config of the daemon, e.g. to enable or disable
+// builders and runners, or to update registry credentials, without losing the
+// tasks queued or being processed. Tasks that are being processed keep the
+// config they started with. The settings that only change when the daemon
+// restarts keep their current values in cfg, which is modified.
+func (e *Engine) Reload(cfg *config.EnvConfig) (*api.ReloadOutput, error) {
+	if s := cfg.Daemon.Scan; s.Scanner != "" && s.FailOn != "" {
+		if _, err := api.ParseSeverityThreshold(s.FailOn); err != nil {
+			return nil, fmt.Errorf("invalid daemon.scan.fail_on: %w", err)
+		}
+	}
+
+	e.cfglk.Lock()
+	defer e.cfglk.Unlock()
+
+	// The OIDC verifier set up when the daemon started keeps fetching the
+	// keys of the provider, which the daemon can't reach once offline.
+	if cfg.Daemon.Offline.Enabled && e.envcfg.Daemon.OIDC.Issuer != "" {
+		return nil, fmt.Errorf("%w: can't be enabled while the keys of the OIDC provider %s are fetched; restart the daemon without daemon.oidc", config.ErrOffline, e.envcfg.Daemon.OIDC.Issuer)
+	}
+
+	out := &api.ReloadOutput{Changed: []string{}, Restart: []string{}}
+	for _, k := range reloadKeys {
+		prev := reflect.ValueOf(k.field(e.envcfg)).Elem()
+		next := reflect.ValueOf(k.field(cfg)).Elem()
+		if reflect.DeepEqual(prev.Interface(), next.Interface()) {
+			continue
+		}
+		if k.restart {
+			next.Set(prev)
+			out.Restart = append(out.Restart, k.key)
+			continue
+		}
+		out.Changed = append(out.Changed, k.key)
+	}
+
+	e.envcfg = cfg
+	e.notifier = notify.New(applyOffline(cfg))
+
+	logging.S().Infow("env config reloaded", "changed", out.Changed)
+	if len(out.Restart) > 0 {
+		logging.S().Warnw("env config settings only applied on restart", "keys", out.Restart)
+	}
+	return out, nil
+}
+
+// checkEnabled returns an error if the config disables any of the builders,
+// or the runner, if set.
+func (e *Engine) checkEnabled(builders []string, rn string) error {
+	cfg := e.config()
+	for _, b := range builders {
+		if cfg.Builders[b][config.BuilderDisabledFlag] == true {
+			return fmt.Errorf("builder %s is disabled by config", b)
+		}
+	}
+	if rn != "" && cfg.Runners[rn][config.RunnerDisabledFlag] == true {
+		return fmt.Errorf("%s: %w", rn, runner.ErrRunnerDisabled)
+	}
+	return nil
+}
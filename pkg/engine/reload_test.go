@@ -0,0 +1,94 @@
+package engine
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/testground/testground/pkg/config"
+	"github.com/testground/testground/pkg/runner"
+	"github.com/testground/testground/pkg/task"
+)
+
+func TestReload(t *testing.T) {
+	e := newTestEngine(t, nil, &fakeRunner{})
+	require.NoError(t, e.queue.Push(&task.Task{
+		ID:     "c3ftkqjpc98qra498sg0",
+		Type:   task.TypeRun,
+		Runner: "local:fake",
+		States: []task.DatedState{{State: task.StateScheduled, Created: time.Now().UTC()}},
+	}))
+	require.NoError(t, e.checkEnabled([]string{"docker:fake"}, "local:fake"))
+
+	cfg := e.EnvConfig()
+	cfg.Runners = map[string]config.ConfigMap{"local:fake": {config.RunnerDisabledFlag: true}}
+	cfg.Builders = map[string]config.ConfigMap{"docker:fake": {config.BuilderDisabledFlag: true}}
+	cfg.DockerHub.AccessToken = "rotated"
+	cfg.Daemon.Listen = "localhost:9999"
+
+	out, err := e.Reload(&cfg)
+	require.NoError(t, err)
+	require.Equal(t, []string{"dockerhub", "builders", "runners"}, out.Changed)
+	require.Equal(t, []string{"daemon.listen"}, out.Restart)
+
+	// The queue is kept, the new settings are applied, and those that are
+	// only applied on restart keep their values.
+	require.Equal(t, 1, e.queue.Len())
+	require.Equal(t, "rotated", e.EnvConfig().DockerHub.AccessToken)
+	require.Equal(t, config.DefaultListenAddr, e.EnvConfig().Daemon.Listen)
+
+	err = e.checkEnabled(nil, "local:fake")
+	require.True(t, errors.Is(err, runner.ErrRunnerDisabled))
+	require.Error(t, e.checkEnabled([]string{"docker:fake"}, ""))
+
+	cfg.Daemon.Scan = config.ScanConfig{Scanner: "trivy", FailOn: "severe"}
+	_, err = e.Reload(&cfg)
+	require.Error(t, err)
+
+	// Offline mode can't be switched on while OIDC tokens are verified.
+	e.envcfg.Daemon.OIDC.Issuer = "https://accounts.example.com"
+	offline := e.EnvConfig()
+	offline.Daemon.Scan = config.ScanConfig{}
+	offline.Daemon.Offline.Enabled = true
+	_, err = e.Reload(&offline)
+	require.True(t, errors.Is(err, config.ErrOffline))
+	require.False(t, e.EnvConfig().Daemon.Offline.Enabled)
+}
+
+// TestReloadKeys checks every setting of the env config is compared when
+// it's reloaded.
+func TestReloadKeys(t *testing.T) {
+	keys := make(map[string]bool, len(reloadKeys))
+	for _, k := range reloadKeys {
+		keys[k.key] = true
+	}
+
+	// The scheduler and the retention settings are compared one by one.
+	split := map[string]bool{"daemon.scheduler": true, "daemon.retention": true}
+
+	var missing []string
+	var walk func(prefix string, typ reflect.Type)
+	walk = func(prefix string, typ reflect.Type) {
+		for i := 0; i < typ.NumField(); i++ {
+			f := typ.Field(i)
+			tag := strings.Split(f.Tag.Get("toml"), ",")[0]
+			if tag == "" {
+				continue
+			}
+			key := strings.TrimPrefix(prefix+"."+tag, ".")
+			switch {
+			case key == "daemon" || split[key]:
+				walk(key, f.Type)
+			case key == "client" || keys[key]:
+			default:
+				missing = append(missing, key)
+			}
+		}
+	}
+	walk("", reflect.TypeOf(config.EnvConfig{}))
+	require.Empty(t, missing, "settings not compared on reload")
+}
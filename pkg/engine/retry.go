@@ -0,0 +1,107 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/rs/xid"
+	"github.com/testground/testground/pkg/logging"
+	"github.com/testground/testground/pkg/metrics"
+	"github.com/testground/testground/pkg/rpc"
+	"github.com/testground/testground/pkg/runner"
+	"github.com/testground/testground/pkg/task"
+)
+
+// retryRun schedules another attempt at a completed run task if the retry
+// policy of the run asks for it, and links the task to the new attempt.
+//
+// The new attempt is persisted right away, so that clients can follow it, and
+// is queued once the backoff has elapsed.
+func (e *Engine) retryRun(tsk *task.Task, errTask error, ow *rpc.OutputWriter) {
+	input, ok := tsk.Input.(*RunInput)
+	if !ok || len(input.RunIds) == 0 {
+		return
+	}
+
+	run, err := input.Composition.GetRun(input.RunIds[0])
+	if err != nil || run.Retry == nil {
+		return
+	}
+
+	// Runs killed by the user are never retried.
+	if errors.Is(errTask, context.Canceled) {
+		return
+	}
+
+	var outcome task.Outcome
+	if res, ok := tsk.Result.(*runner.Result); ok {
+		outcome = res.Outcome
+	}
+
+	attempt := tsk.Attempt
+	if attempt == 0 {
+		attempt = 1
+	}
+	if !run.Retry.ShouldRetry(attempt, outcome, errTask != nil) {
+		return
+	}
+
+	next := nextAttempt(tsk, input, attempt+1)
+
+	if err := e.store.PersistScheduled(next); err != nil {
+		logging.S().Errorw("could not persist retry", "task_id", tsk.ID, "err", err)
+		return
+	}
+	tsk.RetriedBy = next.ID
+
+	backoff := run.Retry.Backoff(attempt)
+	ow.Infow("retrying run", "task_id", tsk.ID, "retry_id", next.ID, "attempt", next.Attempt, "max_attempts", run.Retry.MaxAttempts, "backoff", backoff)
+
+	time.AfterFunc(backoff, func() {
+		if err := e.queue.Push(next); err != nil {
+			logging.S().Errorw("could not queue retry", "task_id", tsk.ID, "retry_id", next.ID, "err", err)
+			return
+		}
+		metrics.TasksQueued.Set(float64(e.queue.Len()))
+		e.publishQueued(next)
+	})
+}
+
+// nextAttempt returns a new task for another attempt at a run task. It reuses
+// the artifacts built by the previous attempt, and only builds the groups that
+// have none, e.g. if the build failed.
+func nextAttempt(tsk *task.Task, input *RunInput, attempt int) *task.Task {
+	req := *input.RunRequest
+	req.BuildGroups = nil
+	for _, idx := range input.BuildGroups {
+		if input.Composition.Groups[idx].Run.Artifact == "" {
+			req.BuildGroups = append(req.BuildGroups, idx)
+		}
+	}
+
+	return &task.Task{
+		Version:     tsk.Version,
+		Priority:    tsk.Priority,
+		Plan:        tsk.Plan,
+		Case:        tsk.Case,
+		ID:          xid.New().String(),
+		Runner:      tsk.Runner,
+		Type:        task.TypeRun,
+		Composition: req.Composition,
+		Input: &RunInput{
+			RunRequest: &req,
+			Sources:    input.Sources,
+		},
+		States: []task.DatedState{
+			{
+				State:   task.StateScheduled,
+				Created: time.Now().UTC(),
+			},
+		},
+		CreatedBy:  tsk.CreatedBy,
+		PlanSource: tsk.PlanSource,
+		Attempt:    attempt,
+		RetryOf:    tsk.ID,
+	}
+}
@@ -0,0 +1,72 @@
+package engine
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/testground/testground/pkg/api"
+	"github.com/testground/testground/pkg/rpc"
+	"github.com/testground/testground/pkg/task"
+)
+
+func TestRetryRun(t *testing.T) {
+	store, err := task.NewMemoryTaskStorage()
+	require.NoError(t, err)
+	queue, err := task.NewQueue(store, 10, UnmarshalTask)
+	require.NoError(t, err)
+	e := &Engine{store: store, queue: queue}
+
+	newTask := func(attempt int) *task.Task {
+		return &task.Task{
+			ID:      "task",
+			Type:    task.TypeRun,
+			Attempt: attempt,
+			Input: &RunInput{
+				RunRequest: &api.RunRequest{
+					BuildGroups: []int{0, 1},
+					RunIds:      []string{"run"},
+					Composition: api.Composition{
+						Groups: api.Groups{
+							{ID: "built", Run: api.RunParams{Artifact: "image"}},
+							{ID: "unbuilt"},
+						},
+						Runs: api.Runs{{ID: "run", Retry: &api.RetryPolicy{MaxAttempts: 2}}},
+					},
+				},
+			},
+			States: []task.DatedState{{State: task.StateScheduled, Created: time.Now().UTC()}},
+		}
+	}
+	ow := rpc.NewFileOutputWriter(&bytes.Buffer{})
+
+	// the first attempt errored: it's retried.
+	tsk := newTask(0)
+	e.retryRun(tsk, errors.New("failed to start containers"), ow)
+	require.NotEmpty(t, tsk.RetriedBy)
+
+	next, err := store.Get(tsk.RetriedBy)
+	require.NoError(t, err)
+	require.Equal(t, 2, next.Attempt)
+	require.Equal(t, "task", next.RetryOf)
+
+	// the retry is queued, and only builds the groups without artifacts.
+	require.Eventually(t, func() bool { return queue.Len() == 1 }, time.Second, 10*time.Millisecond)
+	queued, err := queue.Pop()
+	require.NoError(t, err)
+	require.Equal(t, next.ID, queued.ID)
+	require.Equal(t, []int{1}, queued.Input.(*RunInput).BuildGroups)
+
+	// the last attempt isn't retried.
+	tsk = newTask(2)
+	e.retryRun(tsk, errors.New("failed to start containers"), ow)
+	require.Empty(t, tsk.RetriedBy)
+
+	// successful runs aren't retried.
+	tsk = newTask(1)
+	e.retryRun(tsk, nil, ow)
+	require.Empty(t, tsk.RetriedBy)
+}
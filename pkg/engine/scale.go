@@ -0,0 +1,71 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/testground/testground/pkg/api"
+	"github.com/testground/testground/pkg/rpc"
+	"github.com/testground/testground/pkg/task"
+)
+
+// DoScale adds instances to, or removes instances from, a group of a running
+// task, and records the change in the task log.
+func (e *Engine) DoScale(ctx context.Context, req *api.ScaleRequest, ow *rpc.OutputWriter) (*api.ScaleOutput, error) {
+	if req.Group == "" {
+		return nil, fmt.Errorf("group is required")
+	}
+	if req.Delta == 0 {
+		return nil, fmt.Errorf("a non-zero number of instances to add or remove is required")
+	}
+
+	t, err := e.GetTask(req.TaskID)
+	if err != nil {
+		return nil, fmt.Errorf("could not get task %s: %s", req.TaskID, err.Error())
+	}
+
+	if t.Type != task.TypeRun {
+		return nil, fmt.Errorf("task %s is not a run", req.TaskID)
+	}
+
+	if st := t.State().State; st != task.StateProcessing {
+		return nil, fmt.Errorf("task %s is not running (state: %s)", req.TaskID, st)
+	}
+
+	run, ok := e.runners[t.Runner]
+	if !ok {
+		return nil, fmt.Errorf("unknown runner: %s", t.Runner)
+	}
+
+	scaler, ok := run.(api.Scaler)
+	if !ok {
+		return nil, fmt.Errorf("runner %s does not support scaling", t.Runner)
+	}
+
+	out, err := scaler.Scale(ctx, &api.ScaleInput{RunID: t.ID, Group: req.Group, Delta: req.Delta}, ow)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := e.logScale(t.ID, out); err != nil {
+		ow.Warnw("failed to record scaling in the task log", "err", err)
+	}
+
+	return out, nil
+}
+
+// logScale appends a change of the instances of a group to the log of the
+// task.
+func (e *Engine) logScale(taskID string, out *api.ScaleOutput) error {
+	path := filepath.Join(e.EnvConfig().Dirs().Daemon(), taskID+".out")
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	rpc.NewFileOutputWriter(f).Infow("group scaled", "group", out.Group, "added", out.Added, "removed", out.Removed, "instances", out.Instances)
+	return nil
+}
@@ -0,0 +1,260 @@
+package engine
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/testground/testground/pkg/api"
+	"github.com/testground/testground/pkg/rpc"
+)
+
+// ScanReportsFile is the name of the file, at the root of the run outputs,
+// holding the reports of the vulnerability scans of the artifacts of a run.
+const ScanReportsFile = "scan_reports.json"
+
+const defaultScanTimeout = 10 * time.Minute
+
+// scanArtifact scans the artifact of a build for vulnerabilities, with the
+// scanner of the daemon config. Images are scanned from the Docker daemon,
+// and executables as files.
+func (e *Engine) scanArtifact(ctx context.Context, out *api.BuildOutput, groups []string, ow *rpc.OutputWriter) (*api.ScanReport, error) {
+	cfg := e.config().Daemon.Scan
+
+	var failOn string
+	if cfg.FailOn != "" {
+		var err error
+		if failOn, err = api.ParseSeverityThreshold(cfg.FailOn); err != nil {
+			return nil, err
+		}
+	}
+
+	image := strings.HasPrefix(out.BuilderID, "docker:")
+	offline := e.config().Daemon.Offline.Enabled
+
+	var (
+		args  []string
+		env   []string
+		parse func([]byte) ([]*api.Vulnerability, error)
+	)
+	switch cfg.Scanner {
+	case "trivy":
+		if image {
+			args = []string{"image", "--image-src", "docker"}
+		} else {
+			args = []string{"rootfs"}
+		}
+		args = append(args, "--quiet", "--format", "json")
+		if offline {
+			args = append(args, "--skip-db-update", "--offline-scan")
+		}
+		args = append(args, out.ArtifactPath)
+		parse = parseTrivyReport
+	case "grype":
+		src := "file:" + out.ArtifactPath
+		if image {
+			src = "docker:" + out.ArtifactPath
+		}
+		args = []string{src, "-o", "json"}
+		if offline {
+			env = append(env, "GRYPE_DB_AUTO_UPDATE=false", "GRYPE_CHECK_FOR_APP_UPDATE=false")
+		}
+		parse = parseGrypeReport
+	default:
+		return nil, fmt.Errorf("unsupported scanner %q; expected trivy or grype", cfg.Scanner)
+	}
+
+	bin := cfg.Path
+	if bin == "" {
+		bin = cfg.Scanner
+	}
+
+	timeout := defaultScanTimeout
+	if cfg.TimeoutMin > 0 {
+		timeout = time.Duration(cfg.TimeoutMin) * time.Minute
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	ow.Infow("scanning artifact for vulnerabilities", "scanner", cfg.Scanner, "artifact", out.ArtifactPath, "groups", groups)
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, bin, args...)
+	cmd.Env = append(os.Environ(), env...)
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("%s failed: %w; stderr: %s", cfg.Scanner, err, strings.TrimSpace(stderr.String()))
+	}
+
+	vulns, err := parse(stdout.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse the report of %s: %w", cfg.Scanner, err)
+	}
+
+	report := &api.ScanReport{
+		Scanner:         cfg.Scanner,
+		Artifact:        out.ArtifactPath,
+		Groups:          groups,
+		Vulnerabilities: vulns,
+	}
+	report.Evaluate(failOn, cfg.IgnoreUnfixed)
+
+	if report.Blocking > 0 {
+		ow.Warnw("artifact has vulnerabilities at or above the severity threshold", "artifact", out.ArtifactPath, "groups", groups, "fail_on", failOn, "blocking", report.Blocking, "found", report.Summary())
+	} else {
+		ow.Infow("scanned artifact", "artifact", out.ArtifactPath, "groups", groups, "found", report.Summary())
+	}
+	return report, nil
+}
+
+// scanGate returns an error if any of the scans of the builds found
+// vulnerabilities at or above the severity threshold.
+func scanGate(outs []*api.BuildOutput) error {
+	var (
+		blocking []*api.ScanReport
+		seen     = make(map[*api.ScanReport]bool)
+	)
+	for _, out := range outs {
+		if out == nil || out.Scan == nil || seen[out.Scan] {
+			continue
+		}
+		seen[out.Scan] = true
+		if out.Scan.Blocking > 0 {
+			blocking = append(blocking, out.Scan)
+		}
+	}
+	if len(blocking) == 0 {
+		return nil
+	}
+	return &ScanError{Reports: blocking}
+}
+
+// recordScans writes the reports of the scans of the artifacts of a run to
+// its outputs.
+func (e *Engine) recordScans(run api.Runner, plan, runID string, outs map[string]*api.BuildOutput, ow *rpc.OutputWriter) {
+	locator, ok := run.(api.OutputsLocator)
+	if !ok {
+		return
+	}
+
+	var (
+		reports []*api.ScanReport
+		seen    = make(map[*api.ScanReport]bool)
+	)
+	for _, out := range outs {
+		if out.Scan != nil && !seen[out.Scan] {
+			seen[out.Scan] = true
+			reports = append(reports, out.Scan)
+		}
+	}
+	if len(reports) == 0 {
+		return
+	}
+
+	dir, err := locator.LocateOutputs(plan, runID)
+	if err == nil {
+		err = writeScanReports(filepath.Join(dir, ScanReportsFile), reports)
+	}
+	if err != nil {
+		ow.Warnw("failed to write scan reports", "err", err)
+	}
+}
+
+func writeScanReports(path string, reports []*api.ScanReport) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	enc := json.NewEncoder(f)
+	enc.SetIndent("", "  ")
+	return enc.Encode(reports)
+}
+
+// parseTrivyReport parses the JSON report of Trivy.
+func parseTrivyReport(b []byte) ([]*api.Vulnerability, error) {
+	type result struct {
+		Vulnerabilities []struct {
+			VulnerabilityID  string
+			PkgName          string
+			InstalledVersion string
+			FixedVersion     string
+			Severity         string
+			Title            string
+		}
+	}
+
+	var report struct {
+		Results []result
+	}
+	// Trivy reported a list of results before the schema version 2.
+	if trimmed := bytes.TrimSpace(b); len(trimmed) > 0 && trimmed[0] == '[' {
+		if err := json.Unmarshal(trimmed, &report.Results); err != nil {
+			return nil, err
+		}
+	} else if err := json.Unmarshal(b, &report); err != nil {
+		return nil, err
+	}
+
+	vulns := []*api.Vulnerability{}
+	for _, r := range report.Results {
+		for _, v := range r.Vulnerabilities {
+			vulns = append(vulns, &api.Vulnerability{
+				ID:           v.VulnerabilityID,
+				Package:      v.PkgName,
+				Version:      v.InstalledVersion,
+				FixedVersion: v.FixedVersion,
+				Severity:     api.NormalizeSeverity(v.Severity),
+				Title:        v.Title,
+			})
+		}
+	}
+	return vulns, nil
+}
+
+// parseGrypeReport parses the JSON report of Grype.
+func parseGrypeReport(b []byte) ([]*api.Vulnerability, error) {
+	var report struct {
+		Matches []struct {
+			Vulnerability struct {
+				ID          string `json:"id"`
+				Severity    string `json:"severity"`
+				Description string `json:"description"`
+				Fix         struct {
+					Versions []string `json:"versions"`
+				} `json:"fix"`
+			} `json:"vulnerability"`
+			Artifact struct {
+				Name    string `json:"name"`
+				Version string `json:"version"`
+			} `json:"artifact"`
+		} `json:"matches"`
+	}
+	if err := json.Unmarshal(b, &report); err != nil {
+		return nil, err
+	}
+
+	vulns := []*api.Vulnerability{}
+	for _, m := range report.Matches {
+		vulns = append(vulns, &api.Vulnerability{
+			ID:           m.Vulnerability.ID,
+			Package:      m.Artifact.Name,
+			Version:      m.Artifact.Version,
+			FixedVersion: strings.Join(m.Vulnerability.Fix.Versions, ", "),
+			Severity:     api.NormalizeSeverity(m.Vulnerability.Severity),
+			Title:        m.Vulnerability.Description,
+		})
+	}
+	return vulns, nil
+}
@@ -0,0 +1,74 @@
+package engine
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/testground/testground/pkg/api"
+)
+
+func TestParseTrivyReport(t *testing.T) {
+	report := `{
+  "SchemaVersion": 2,
+  "ArtifactName": "sha256:1234",
+  "Results": [
+    {"Target": "alpine", "Vulnerabilities": [
+      {"VulnerabilityID": "CVE-2023-0001", "PkgName": "openssl", "InstalledVersion": "3.0.1", "FixedVersion": "3.0.2", "Severity": "CRITICAL", "Title": "bad"},
+      {"VulnerabilityID": "CVE-2023-0002", "PkgName": "zlib", "InstalledVersion": "1.2", "Severity": "LOW"}
+    ]},
+    {"Target": "app"}
+  ]
+}`
+	vulns, err := parseTrivyReport([]byte(report))
+	require.NoError(t, err)
+	require.Equal(t, []*api.Vulnerability{
+		{ID: "CVE-2023-0001", Package: "openssl", Version: "3.0.1", FixedVersion: "3.0.2", Severity: api.SeverityCritical, Title: "bad"},
+		{ID: "CVE-2023-0002", Package: "zlib", Version: "1.2", Severity: api.SeverityLow},
+	}, vulns)
+
+	// Reports before the schema version 2 are lists of results.
+	vulns, err = parseTrivyReport([]byte(`[{"Target": "alpine", "Vulnerabilities": [{"VulnerabilityID": "CVE-1", "Severity": "HIGH"}]}]`))
+	require.NoError(t, err)
+	require.Len(t, vulns, 1)
+
+	vulns, err = parseTrivyReport([]byte(`{"Results": []}`))
+	require.NoError(t, err)
+	require.Empty(t, vulns)
+}
+
+func TestParseGrypeReport(t *testing.T) {
+	report := `{"matches": [
+  {"vulnerability": {"id": "GHSA-1", "severity": "High", "fix": {"versions": ["1.2.3"], "state": "fixed"}},
+   "artifact": {"name": "golang.org/x/net", "version": "v0.1.0"}},
+  {"vulnerability": {"id": "CVE-2", "severity": "Negligible", "fix": {"versions": [], "state": "not-fixed"}},
+   "artifact": {"name": "libc", "version": "2.31"}}
+]}`
+	vulns, err := parseGrypeReport([]byte(report))
+	require.NoError(t, err)
+	require.Equal(t, []*api.Vulnerability{
+		{ID: "GHSA-1", Package: "golang.org/x/net", Version: "v0.1.0", FixedVersion: "1.2.3", Severity: api.SeverityHigh},
+		{ID: "CVE-2", Package: "libc", Version: "2.31", Severity: api.SeverityNegligible},
+	}, vulns)
+}
+
+func TestScanGate(t *testing.T) {
+	clean := &api.ScanReport{Artifact: "a", Groups: []string{"a"}}
+	clean.Evaluate(api.SeverityHigh, false)
+
+	vulnerable := &api.ScanReport{Artifact: "b", Groups: []string{"b", "c"}, Vulnerabilities: []*api.Vulnerability{
+		{ID: "CVE-1", Severity: api.SeverityCritical, FixedVersion: "1"},
+		{ID: "CVE-2", Severity: api.SeverityMedium},
+	}}
+	vulnerable.Evaluate(api.SeverityHigh, false)
+
+	require.NoError(t, scanGate([]*api.BuildOutput{{Scan: clean}, {}, nil}))
+
+	// Groups sharing a build share its report.
+	err := scanGate([]*api.BuildOutput{{Scan: clean}, {Scan: vulnerable}, {Scan: vulnerable}})
+	var serr *ScanError
+	require.ErrorAs(t, err, &serr)
+	require.Len(t, serr.Reports, 1)
+	require.True(t, strings.Contains(err.Error(), "b (groups b, c): 1 at or above high; found 1 critical, 1 medium"), err.Error())
+}
@@ -15,10 +15,14 @@ import (
 	"github.com/otiai10/copy"
 	"github.com/testground/testground/pkg/api"
 	"github.com/testground/testground/pkg/config"
+	"github.com/testground/testground/pkg/data"
 	"github.com/testground/testground/pkg/logging"
+	"github.com/testground/testground/pkg/metrics"
 	"github.com/testground/testground/pkg/rpc"
 	"github.com/testground/testground/pkg/runner"
 	"github.com/testground/testground/pkg/task"
+	"github.com/testground/testground/pkg/tracing"
+	"go.opentelemetry.io/otel/attribute"
 	"golang.org/x/sync/errgroup"
 )
 
@@ -52,7 +56,7 @@ func (e *Engine) worker(n int) {
 	}
 
 	for {
-		tsk, err := e.queue.Pop()
+		tsk, err := e.popTask()
 		if err == task.ErrQueueEmpty {
 			time.Sleep(time.Second)
 			continue
@@ -62,11 +66,29 @@ func (e *Engine) worker(n int) {
 			logging.S().Errorw("error while popping task from the queue", "err", err)
 			continue
 		}
+		metrics.TasksQueued.Set(float64(e.queue.Len()))
 
 		func() {
+			defer e.releaseSlot(tsk)
+
 			ctx, cancel := context.WithTimeout(context.Background(), taskTimeout)
 			defer cancel()
 
+			// Resume the trace of the request that queued the task, if any.
+			ctx = tracing.Extract(ctx, traceContext(tsk))
+			ctx, span := tracing.StartAt(ctx, "task "+string(tsk.Type), tsk.Created(), attribute.String("task_id", tsk.ID), attribute.String("name", tsk.Name()))
+			defer span.End()
+
+			_, scheduling := tracing.StartAt(ctx, "scheduling", tsk.Created())
+			scheduling.End()
+
+			// Record the decisions taken for the task, for `task explain`.
+			decisions := e.addDecisionLog(tsk.ID)
+			defer e.deleteDecisionLog(tsk.ID)
+			ctx = api.WithDecisionLog(ctx, decisions)
+			ctx = e.withEventSink(ctx, tsk)
+			api.RecordDecision(ctx, api.DecisionStageQueue, "picked by worker %d after waiting %s in the queue, at priority %d", n, time.Since(tsk.Created()).Truncate(time.Second), tsk.Priority)
+
 			ch := make(chan int)
 			e.addSignal(tsk.ID, ch)
 
@@ -84,6 +106,9 @@ func (e *Engine) worker(n int) {
 				State:   task.StateProcessing,
 				Created: time.Now().UTC(),
 			})
+			if tsk.Type == task.TypeRun {
+				e.provisionDashboard(tsk, tsk.Input.(*RunInput))
+			}
 			err = e.store.PersistProcessing(tsk)
 			if err != nil {
 				logging.S().Errorw("could not persist task", "err", err)
@@ -105,13 +130,20 @@ func (e *Engine) worker(n int) {
 
 			ow := rpc.NewFileOutputWriter(f)
 
+			running := metrics.TasksRunning.WithLabelValues(string(tsk.Type))
+			running.Inc()
+			defer running.Dec()
+
 			var result interface{}
 			var errTask error
 
 			switch tsk.Type {
 			case task.TypeRun:
 				var res *api.RunOutput
-				res, errTask = e.doRun(ctx, tsk.ID, tsk.Input.(*RunInput), ow)
+				input := tsk.Input.(*RunInput)
+				rctx, runDone := withTimeout(ctx, "run", input.Composition.Global.Timeouts.RunTimeout())
+				res, errTask = e.doRun(rctx, tsk.ID, input, ow)
+				errTask = runDone(errTask)
 
 				if errTask != nil {
 					errTask = &TaskExecutionError{TaskType: string(tsk.Type), WrappedErr: errTask}
@@ -121,10 +153,16 @@ func (e *Engine) worker(n int) {
 				if res != nil {
 					result = res.Result
 					tsk.Composition = res.Composition
+					if res.Provenance != nil {
+						tsk.Provenance = res.Provenance
+					}
 				}
 			case task.TypeBuild:
 				var res []*api.BuildOutput
-				res, errTask = e.doBuild(ctx, tsk.Input.(*BuildInput), ow)
+				input := tsk.Input.(*BuildInput)
+				bctx, buildDone := withTimeout(ctx, "build", input.Composition.Global.Timeouts.BuildTimeout())
+				res, errTask = e.doBuild(bctx, input, ow)
+				errTask = buildDone(errTask)
 				if errTask != nil {
 					errTask = &TaskExecutionError{TaskType: string(tsk.Type), WrappedErr: errTask}
 					logging.S().Errorw("doBuild returned err", "err", errTask)
@@ -138,6 +176,14 @@ func (e *Engine) worker(n int) {
 					result = artifactPaths
 				}
 
+				if errTask == nil {
+					files, err := e.keepBuildFiles(tsk.ID, res)
+					if err != nil {
+						ow.Warnw("failed to keep build files", "err", err)
+					}
+					tsk.BuildFiles = files
+				}
+
 			default:
 				logging.S().Errorw("unknown task type", "type", tsk.Type)
 				return
@@ -148,6 +194,7 @@ func (e *Engine) worker(n int) {
 				State:   task.StateComplete,
 			}
 			if errTask != nil {
+				span.RecordError(errTask)
 				tsk.Error = errTask.Error()
 
 				var e *TaskExecutionError
@@ -159,8 +206,39 @@ func (e *Engine) worker(n int) {
 				}
 			}
 
+			reason, canceled := e.takeCanceled(tsk.ID)
+			var timeout *TimeoutError
+			switch {
+			case canceled:
+				markCanceled(tsk, &newState, result, reason)
+				decisions.Record(api.DecisionStageQueue, "%s", reason)
+				ow.Infow("task canceled", "task_id", tsk.ID, "reason", reason)
+			case errors.As(errTask, &timeout):
+				if tsk.Type == task.TypeRun {
+					result = markTimedOut(&newState, result)
+				}
+				ow.Warnw("task timed out", "task_id", tsk.ID, "stage", timeout.Stage, "timeout", timeout.Timeout)
+			}
+
 			tsk.States = append(tsk.States, newState)
 			tsk.Result = result
+			tsk.Decisions = decisions.Decisions()
+
+			if tsk.Type == task.TypeRun {
+				by, preempted := e.takePreempted(tsk.ID)
+				switch {
+				case canceled:
+					// Runs canceled by a user are neither retried nor queued again.
+				case preempted && errTask != nil:
+					e.requeuePreempted(tsk, by, ow)
+				default:
+					e.retryRun(tsk, errTask, ow)
+				}
+			}
+
+			if outcome, err := data.DecodeTaskOutcome(tsk); err == nil {
+				metrics.TasksFinished.WithLabelValues(string(tsk.Type), string(outcome)).Inc()
+			}
 
 			err = e.store.PersistProcessing(tsk)
 			if err != nil {
@@ -174,10 +252,8 @@ func (e *Engine) worker(n int) {
 				return
 			}
 
-			err = e.postStatusToSlack(tsk)
-			if err != nil {
-				logging.S().Errorw("could not send status to slack", "err", err)
-			}
+			e.publishCompleted(tsk)
+			e.notify(tsk, errTask, canceled)
 			err = e.postStatusToGithub(tsk)
 			if err != nil {
 				logging.S().Errorw("could not post status to github", "err", err)
@@ -189,8 +265,19 @@ func (e *Engine) worker(n int) {
 	}
 }
 
+// traceContext returns the trace context of the request that queued the task.
+func traceContext(tsk *task.Task) map[string]string {
+	switch in := tsk.Input.(type) {
+	case *RunInput:
+		return in.TraceContext
+	case *BuildInput:
+		return in.TraceContext
+	}
+	return nil
+}
+
 func (e *Engine) postStatusToGithub(tsk *task.Task) error {
-	if e.envcfg.Daemon.GithubRepoStatusToken == "" {
+	if e.config().Daemon.GithubRepoStatusToken == "" || e.config().Daemon.Offline.Enabled {
 		return nil
 	}
 
@@ -222,6 +309,9 @@ func (e *Engine) postStatusToGithub(tsk *task.Task) error {
 		case task.OutcomeCanceled:
 			msg = "Testplan run was canceled!"
 			state = "failure"
+		case task.OutcomeTimedOut:
+			msg = "Testplan run timed out!"
+			state = "failure"
 		case task.OutcomeFailure:
 			msg = "Testplan run failed!"
 			state = "failure"
@@ -245,7 +335,7 @@ func (e *Engine) postStatusToGithub(tsk *task.Task) error {
 	if err != nil {
 		return err
 	}
-	req.Header.Add("Authorization", "Basic "+e.envcfg.Daemon.GithubRepoStatusToken)
+	req.Header.Add("Authorization", "Basic "+e.config().Daemon.GithubRepoStatusToken)
 	req.Header.Set("Accept", "application/vnd.github.v3+json")
 
 	res, err := cl.Do(req)
@@ -258,43 +348,6 @@ func (e *Engine) postStatusToGithub(tsk *task.Task) error {
 	return nil
 }
 
-func (e *Engine) postStatusToSlack(tsk *task.Task) error {
-	if e.envcfg.Daemon.SlackWebhookURL == "" {
-		return nil
-	}
-
-	result, ok := tsk.Result.(*runner.Result)
-	if !ok {
-		return nil
-	}
-
-	payload := fmt.Sprintf(`{"text":"<https://ci.testground.ipfs.team/tasks#taskID_%s|%s> *%s* run completed"}`, tsk.ID, tsk.ID, tsk.Name())
-
-	switch result.Outcome {
-	case task.OutcomeSuccess:
-		payload = fmt.Sprintf(`{"text":"✅ <https://ci.testground.ipfs.team/tasks#taskID_%s|%s> *%s* run succeeded (%s) %s"}`, tsk.ID, tsk.ID, tsk.Name(), result, tsk.Took())
-	case task.OutcomeCanceled:
-		payload = fmt.Sprintf(`{"text":"⚪ <https://ci.testground.ipfs.team/tasks#taskID_%s|%s> *%s* run canceled %s ; %s"}`, tsk.ID, tsk.ID, tsk.Name(), tsk.Took(), tsk.Error)
-	case task.OutcomeFailure:
-		payload = fmt.Sprintf(`{"text":"❌ <https://ci.testground.ipfs.team/tasks#taskID_%s|%s> *%s* run failed (%s) %s ; %s"}`, tsk.ID, tsk.ID, tsk.Name(), result, tsk.Took(), tsk.Error)
-	}
-
-	cl := &http.Client{Timeout: time.Second * 10}
-	body := strings.NewReader(payload)
-	res, err := cl.Post(
-		e.envcfg.Daemon.SlackWebhookURL,
-		"application/json; charset=UTF-8",
-		body,
-	)
-	if err != nil {
-		return err
-	}
-
-	res.Body.Close()
-
-	return nil
-}
-
 func (e *Engine) doBuild(ctx context.Context, input *BuildInput, ow *rpc.OutputWriter) ([]*api.BuildOutput, error) {
 	sources := input.Sources
 	comp, err := input.Composition.PrepareForBuild(&input.Manifest)
@@ -322,7 +375,13 @@ func (e *Engine) doBuild(ctx context.Context, input *BuildInput, ow *rpc.OutputW
 		}
 	}
 
+	// The builders may have been disabled since the task was queued.
+	if err := e.checkEnabled(usedBuilders, ""); err != nil {
+		return nil, err
+	}
+
 	// Call healthcheck on the builders
+	hctx, hspan := tracing.Start(ctx, "healthcheck builders")
 	for _, b := range usedBuilders {
 		bm := e.builders[b]
 
@@ -330,9 +389,11 @@ func (e *Engine) doBuild(ctx context.Context, input *BuildInput, ow *rpc.OutputW
 		if hc, ok := bm.(api.Healthchecker); ok {
 			ow.Info("performing healthcheck on builder")
 
-			if rep, err := hc.Healthcheck(ctx, e, ow, true); err != nil {
+			if rep, err := hc.Healthcheck(hctx, e, ow, true); err != nil {
+				tracing.End(hspan, err)
 				return nil, fmt.Errorf("healthcheck and fix errored: %w", err)
 			} else if !rep.FixesSucceeded() {
+				hspan.End()
 				return nil, fmt.Errorf("healthcheck fixes failed; aborting:\n%s", rep)
 			} else if !rep.ChecksSucceeded() {
 				ow.Warnf(aurora.Bold(aurora.Yellow("some healthchecks failed, but continuing")).String())
@@ -341,6 +402,7 @@ func (e *Engine) doBuild(ctx context.Context, input *BuildInput, ow *rpc.OutputW
 			}
 		}
 	}
+	hspan.End()
 
 	// Builders are ready, let's go.
 	var (
@@ -397,6 +459,8 @@ func (e *Engine) doBuild(ctx context.Context, input *BuildInput, ow *rpc.OutputW
 	concurrentBuilds := comp.Global.ConcurrentBuilds
 	if concurrentBuilds == 0 {
 		concurrentBuilds = -1
+	} else if concurrentBuilds < len(uniq) {
+		api.RecordDecision(ctx, api.DecisionStageLimits, "%d builds limited to %d at a time by concurrent_builds", len(uniq), concurrentBuilds)
 	}
 	errGroup.SetLimit(concurrentBuilds)
 
@@ -424,6 +488,11 @@ func (e *Engine) doBuild(ctx context.Context, input *BuildInput, ow *rpc.OutputW
 			bm := e.builders[builder]
 
 			ow.Infow("performing build for groups", "plan", plan, "groups", grpids, "builder", builder)
+			if len(grpids) > 1 {
+				api.RecordDecision(ctx, api.DecisionStageBuild, "groups %v have the same build key; built once by %s", grpids, builder)
+			} else {
+				api.RecordDecision(ctx, api.DecisionStageBuild, "group %s built by %s", grpids[0], builder)
+			}
 
 			deps := make(map[string]api.DependencyTarget, len(grp.Build.Dependencies))
 
@@ -443,8 +512,8 @@ func (e *Engine) doBuild(ctx context.Context, input *BuildInput, ow *rpc.OutputW
 			//  3. Builder defaults (applied by the builder itself, nothing to do here).
 			//
 			var cfg config.CoalescedConfig
-			cfg = cfg.Append(e.envcfg.Builders[builder]) // env config for the builder
-			groupCfg := cfg.Append(grp.BuildConfig)      // add the group config
+			cfg = cfg.Append(e.config().Builders[builder]) // env config for the builder
+			groupCfg := cfg.Append(grp.BuildConfig)        // add the group config
 
 			// Coalesce all configurations and deserialize into the config type
 			// mandated by the builder.
@@ -456,7 +525,7 @@ func (e *Engine) doBuild(ctx context.Context, input *BuildInput, ow *rpc.OutputW
 
 			in := &api.BuildInput{
 				BuildID:         uuid.New().String()[24:],
-				EnvConfig:       *e.envcfg,
+				EnvConfig:       *e.config(),
 				TestPlan:        plan,
 				Selectors:       grp.Build.Selectors,
 				Dependencies:    deps,
@@ -464,13 +533,30 @@ func (e *Engine) doBuild(ctx context.Context, input *BuildInput, ow *rpc.OutputW
 				UnpackedSources: src,
 			}
 
-			res, err := bm.Build(errGroupCtx, in, ow)
+			api.EmitEvent(ctx, api.Event{Type: api.EventBuildStarted, Groups: grpids, Builder: builder})
+			bctx, bspan := tracing.Start(errGroupCtx, "build", attribute.String("builder", builder), attribute.StringSlice("groups", grpids))
+			start := time.Now()
+			res, err := bm.Build(bctx, in, ow)
+			tracing.End(bspan, err)
+			metrics.BuildDuration.WithLabelValues(builder, metrics.Outcome(err)).Observe(time.Since(start).Seconds())
 			if err != nil {
+				api.EmitEvent(ctx, api.Event{Type: api.EventBuildFinished, Groups: grpids, Builder: builder, Error: err.Error()})
 				ow.Infow("build failed", "plan", plan, "groups", grpids, "builder", builder, "error", err)
 				return err
 			}
 
 			res.BuilderID = bm.ID()
+			res.Config = groupCfg.Coalesce()
+			res.Groups = grpids
+
+			if e.config().Daemon.Scan.Scanner != "" {
+				sctx, sspan := tracing.Start(errGroupCtx, "scan", attribute.String("builder", builder), attribute.StringSlice("groups", grpids))
+				res.Scan, err = e.scanArtifact(sctx, res, grpids, ow)
+				tracing.End(sspan, err)
+				if err != nil {
+					return fmt.Errorf("failed to scan the artifact of groups %v: %w", grpids, err)
+				}
+			}
 
 			// no need for a mutex as the indices we access do not intersect
 			// across goroutines.
@@ -478,6 +564,7 @@ func (e *Engine) doBuild(ctx context.Context, input *BuildInput, ow *rpc.OutputW
 				ress[idx] = res
 			}
 
+			api.EmitEvent(ctx, api.Event{Type: api.EventBuildFinished, Groups: grpids, Builder: builder, Artifact: res.ArtifactPath})
 			ow.Infow("build succeeded", "plan", plan, "groups", grpids, "builder", builder, "artifact", res.ArtifactPath)
 			return nil
 		})
@@ -488,24 +575,47 @@ func (e *Engine) doBuild(ctx context.Context, input *BuildInput, ow *rpc.OutputW
 		return nil, err
 	}
 
+	// The outputs are returned along with the error of the scan gate, for
+	// their reports to be recorded.
+	if err := scanGate(ress); err != nil {
+		return ress, err
+	}
+
 	return ress, nil
 }
 
 func (e *Engine) doRun(ctx context.Context, id string, input *RunInput, ow *rpc.OutputWriter) (*api.RunOutput, error) {
+	// The outputs of the builds of this run, by group.
+	built := make(map[string]*api.BuildOutput, len(input.BuildGroups))
+
+	phash, err := planHash(input)
+	if err != nil {
+		return nil, fmt.Errorf("failed to hash the plan sources: %w", err)
+	}
+
 	if len(input.BuildGroups) > 0 {
 		bcomp, err := input.Composition.PickGroups(input.BuildGroups...)
 		if err != nil {
 			return nil, err
 		}
 
-		bout, err := e.doBuild(ctx, &BuildInput{
+		bctx, buildDone := withTimeout(ctx, "build", input.Composition.Global.Timeouts.BuildTimeout())
+		bout, err := e.doBuild(bctx, &BuildInput{
 			BuildRequest: &api.BuildRequest{
 				Composition: bcomp,
 				Manifest:    input.Manifest,
 			},
 			Sources: input.Sources,
 		}, ow)
-		if err != nil {
+		if err = buildDone(err); err != nil {
+			var serr *ScanError
+			if errors.As(err, &serr) {
+				for i, groupIdx := range input.BuildGroups {
+					built[input.Composition.Groups[groupIdx].ID] = bout[i]
+				}
+				run := e.runners[input.Composition.Global.Runner]
+				e.recordScans(run, clean(input.Composition.Global.Plan), id, built, ow)
+			}
 			return nil, err
 		}
 
@@ -514,6 +624,13 @@ func (e *Engine) doRun(ctx context.Context, id string, input *RunInput, ow *rpc.
 		for i, groupIdx := range input.BuildGroups {
 			g := input.Composition.Groups[groupIdx]
 			g.Run.Artifact = bout[i].ArtifactPath
+			built[g.ID] = bout[i]
+		}
+	}
+
+	for i, g := range input.Composition.Groups {
+		if !intInSlice(i, input.BuildGroups) && g.Run.Artifact != "" {
+			api.RecordDecision(ctx, api.DecisionStageBuild, "group %s uses the provided artifact %s; not built", g.ID, g.Run.Artifact)
 		}
 	}
 
@@ -541,7 +658,11 @@ func (e *Engine) doRun(ctx context.Context, id string, input *RunInput, ow *rpc.
 	if hc, ok := run.(api.Healthchecker); ok {
 		ow.Info("performing healthcheck on runner")
 
-		if rep, err := hc.Healthcheck(ctx, e, ow, true); err != nil {
+		hctx, hspan := tracing.Start(ctx, "healthcheck runner", attribute.String("runner", trunner))
+		rep, err := hc.Healthcheck(hctx, e, ow, true)
+		tracing.End(hspan, err)
+
+		if err != nil {
 			return nil, fmt.Errorf("healthcheck and fix errored: %w", err)
 		} else if !rep.FixesSucceeded() {
 			return nil, fmt.Errorf("healthcheck fixes failed; aborting:\n%s", rep)
@@ -563,9 +684,9 @@ func (e *Engine) doRun(ctx context.Context, id string, input *RunInput, ow *rpc.
 	var cfg config.CoalescedConfig
 
 	// 2. Get the env config for the runner.
-	cfg = cfg.Append(e.envcfg.Runners[trunner])
+	cfg = cfg.Append(e.config().Runners[trunner])
 
-	var flag = e.envcfg.Runners[trunner][config.RunnerDisabledFlag]
+	var flag = e.config().Runners[trunner][config.RunnerDisabledFlag]
 	if flag == true {
 		return nil, runner.ErrRunnerDisabled
 	}
@@ -580,13 +701,13 @@ func (e *Engine) doRun(ctx context.Context, id string, input *RunInput, ow *rpc.
 		return nil, fmt.Errorf("error while coalescing configuration values: %w", err)
 	}
 
-	if (len(input.RunIds) > 1) {
+	if len(input.RunIds) > 1 {
 		// TODO: remove when we can build multiple runs
 		return nil, fmt.Errorf("cannot specify multiple run ids for now")
 	}
 
 	runId := input.RunIds[0]
-	framedComp, err := comp.FrameForRuns(runId);
+	framedComp, err := comp.FrameForRuns(runId)
 
 	if err != nil {
 		return nil, fmt.Errorf("error while framing composition for run: %s: %w", runId, err)
@@ -594,15 +715,36 @@ func (e *Engine) doRun(ctx context.Context, id string, input *RunInput, ow *rpc.
 
 	compRun := framedComp.Runs[0]
 
+	// PrepareForRun already checked the runtime API the plan targets.
+	runtimeAPI, err := input.Manifest.RuntimeAPI()
+	if err != nil {
+		return nil, err
+	}
+
 	in := api.RunInput{
 		RunID:          id,
-		EnvConfig:      *e.envcfg,
+		EnvConfig:      *e.config(),
 		RunnerConfig:   obj,
 		TestPlan:       clean(plan),
 		TestCase:       clean(tcase),
 		TotalInstances: int(compRun.TotalInstances),
 		Groups:         make([]*api.RunGroup, 0, len(compRun.Groups)),
 		DisableMetrics: comp.Global.DisableMetrics,
+		RuntimeAPI:     runtimeAPI,
+		IPFamily:       api.IPv4,
+		Fixtures:       comp.Global.Fixtures,
+		PauseOnFailure: time.Duration(input.PauseOnFailureSecs) * time.Second,
+		Diagnostics:    comp.Global.Diagnostics,
+	}
+
+	if comp.Global.Network != nil && comp.Global.Network.IPFamily != "" {
+		in.IPFamily = comp.Global.Network.IPFamily
+	}
+
+	if len(compRun.DependsOn) > 0 {
+		if in.Inputs, err = e.upstreamOutputs(run, compRun, input.Upstream); err != nil {
+			return nil, err
+		}
 	}
 
 	for _, grp := range compRun.Groups {
@@ -618,13 +760,82 @@ func (e *Engine) doRun(ctx context.Context, id string, input *RunInput, ow *rpc.
 			Parameters:   grp.TestParams,
 			Resources:    grp.Resources,
 			Profiles:     grp.Profiles,
+			Env:          grp.Env,
+			Volume:       grp.Volume,
+			Publish:      grp.Publish,
 		}
 
 		in.Groups = append(in.Groups, g)
 	}
 
+	prov := runProvenance(input, comp, runId, phash, cfg.Coalesce(), built)
+
+	var chaos *chaosController
+	if len(compRun.Chaos) > 0 {
+		if chaos, err = newChaosController(run, id, compRun.Chaos, ow); err != nil {
+			return nil, err
+		}
+	}
+
+	// The readiness gate of the run aborts it, if not enough instances
+	// become ready in time.
+	var probe *api.ReadinessProbe
+	if _, tc, ok := input.Manifest.TestCaseByName(tcase); ok {
+		probe = tc.Readiness
+	}
+
 	ow.Infow("starting run", "run_id", id, "plan", in.TestPlan, "case", in.TestCase, "runner", trunner, "instances", in.TotalInstances)
-	out, err := run.Run(ctx, &in, ow)
+	rctx, rspan := tracing.Start(ctx, "run", attribute.String("runner", trunner), attribute.Int("instances", in.TotalInstances))
+	rctx, abortRun := context.WithCancel(rctx)
+	defer abortRun()
+
+	readiness, err := newReadinessWatch(run, &in, probe, compRun.Readiness, ow, abortRun)
+	if err != nil {
+		tracing.End(rspan, err)
+		return nil, err
+	}
+
+	// The start timeout of the run aborts it, if its instances don't all
+	// start in time.
+	starting, err := newStartWatch(run, &in, comp.Global.Timeouts.StartTimeout(), ow, abortRun)
+	if err != nil {
+		tracing.End(rspan, err)
+		return nil, err
+	}
+
+	start := time.Now()
+	cctx, cancelChaos := context.WithCancel(rctx)
+	if chaos != nil {
+		chaos.start(cctx)
+	}
+	if readiness != nil {
+		readiness.start(cctx)
+	}
+	if starting != nil {
+		starting.start(cctx)
+	}
+	out, err := run.Run(rctx, &in, ow)
+	cancelChaos()
+	if chaos != nil {
+		e.recordChaos(run, &in, chaos.stop(), ow)
+	}
+	e.recordScans(run, in.TestPlan, in.RunID, built, ow)
+	if readiness != nil {
+		if rerr := readiness.stop(); rerr != nil {
+			err = rerr
+		}
+	}
+	if starting != nil {
+		if serr := starting.stop(); serr != nil {
+			err = serr
+		}
+	}
+	tracing.End(rspan, err)
+	metrics.RunDuration.WithLabelValues(trunner, metrics.Outcome(err)).Observe(time.Since(start).Seconds())
+
+	if err == nil && out != nil && len(compRun.Assertions) > 0 {
+		e.checkAssertions(ctx, run, &in, compRun.Assertions, out, ow)
+	}
 
 	if err == nil {
 		message := "run finished with outcome unknown"
@@ -639,8 +850,21 @@ func (e *Engine) doRun(ctx context.Context, id string, input *RunInput, ow *rpc.
 		ow.Warnw("run finished in error", "run_id", id, "plan", plan, "case", tcase, "runner", trunner, "instances", in.TotalInstances, "error", err)
 	}
 
+	if e.outputs != nil {
+		if serr := e.storeOutputs(ctx, run, &in, ow); serr != nil {
+			ow.Warnw("failed to store outputs", "run_id", id, "backend", e.config().Daemon.Outputs.Backend, "err", serr)
+		}
+	}
+
+	// Runs failing without an output are recorded too, to reproduce the
+	// failure.
+	if out == nil && err != nil {
+		out = &api.RunOutput{RunID: id}
+	}
+
 	if out != nil { // TODO: Make sure all runners return a value, and get rid of nil check
 		out.Composition = *compositionUsedForRun
+		out.Provenance = prov
 	}
 
 	return out, err
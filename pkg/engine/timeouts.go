@@ -0,0 +1,151 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/testground/testground/pkg/api"
+	"github.com/testground/testground/pkg/rpc"
+	"github.com/testground/testground/pkg/runner"
+	"github.com/testground/testground/pkg/task"
+)
+
+// startPollInterval is the interval between two listings of the instances of
+// a run, while waiting for them to start.
+var startPollInterval = 2 * time.Second
+
+// withTimeout bounds a stage of a task to d, unless d is 0. The returned
+// function must be called with the error of the stage once it returns; it
+// returns a TimeoutError instead if the stage ran out of time.
+func withTimeout(ctx context.Context, stage string, d time.Duration) (context.Context, func(error) error) {
+	if d <= 0 {
+		return ctx, func(err error) error { return err }
+	}
+
+	tctx, cancel := context.WithTimeout(ctx, d)
+	return tctx, func(err error) error {
+		defer cancel()
+
+		// The parent context being done first isn't a timeout of this stage.
+		if tctx.Err() == context.DeadlineExceeded && ctx.Err() == nil {
+			return &TimeoutError{Stage: stage, Timeout: d, WrappedErr: err}
+		}
+		return err
+	}
+}
+
+// markTimedOut records a task that ran out of time as such. Timed out runs
+// are torn down like canceled ones, but have a distinct outcome.
+func markTimedOut(state *task.DatedState, result interface{}) interface{} {
+	state.State = task.StateCanceled
+
+	res, ok := result.(*runner.Result)
+	if !ok || res == nil {
+		res = &runner.Result{}
+	}
+	res.Outcome = task.OutcomeTimedOut
+	return res
+}
+
+// startWatch lists the instances of a live run until all of them have
+// started, and aborts the run if they haven't by its start timeout.
+type startWatch struct {
+	registry api.InstanceRegistry
+	runID    string
+	total    int
+	timeout  time.Duration
+	ow       *rpc.OutputWriter
+	abort    context.CancelFunc
+
+	// started are the instances seen started so far; instances that already
+	// exited count as started.
+	started map[string]bool
+
+	done chan struct{}
+	err  error
+}
+
+// newStartWatch returns a watch of the start of the instances of a run, or
+// nil if the run has no start timeout.
+func newStartWatch(run api.Runner, in *api.RunInput, timeout time.Duration, ow *rpc.OutputWriter, abort context.CancelFunc) (*startWatch, error) {
+	if timeout <= 0 {
+		return nil, nil
+	}
+
+	registry, ok := run.(api.InstanceRegistry)
+	if !ok {
+		return nil, fmt.Errorf("runner %s does not support start timeouts", run.ID())
+	}
+
+	return &startWatch{
+		registry: registry,
+		runID:    in.RunID,
+		total:    in.TotalInstances,
+		timeout:  timeout,
+		ow:       ow,
+		abort:    abort,
+		started:  make(map[string]bool),
+		done:     make(chan struct{}),
+	}, nil
+}
+
+// start lists the instances until all of them have started, the start
+// timeout passes, or the context is done.
+func (w *startWatch) start(ctx context.Context) {
+	go w.watch(ctx)
+}
+
+// stop waits for the watch to end, once the context passed to start is done,
+// and returns a TimeoutError if the run was aborted.
+func (w *startWatch) stop() error {
+	<-w.done
+	return w.err
+}
+
+func (w *startWatch) watch(ctx context.Context) {
+	defer close(w.done)
+
+	timer := time.NewTimer(w.timeout)
+	defer timer.Stop()
+
+	ticker := time.NewTicker(startPollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-timer.C:
+			w.err = &TimeoutError{Stage: "start", Timeout: w.timeout}
+			w.ow.Warnw("instances did not start in time; aborting run", "started", len(w.started), "total", w.total, "timeout", w.timeout)
+			w.abort()
+			return
+		case <-ctx.Done():
+			return
+		}
+
+		instances, err := w.registry.ListInstances(ctx, w.runID)
+		if err != nil {
+			if ctx.Err() == nil {
+				w.ow.Warnw("failed to list the instances of the run", "err", err)
+			}
+			continue
+		}
+
+		if w.record(instances) >= w.total {
+			w.ow.Infow("all instances started", "total", w.total)
+			return
+		}
+	}
+}
+
+// record counts the instances that started, and returns the number of
+// instances started so far.
+func (w *startWatch) record(instances []*api.Instance) int {
+	for _, inst := range instances {
+		if inst.State != "" && inst.State != "created" {
+			w.started[inst.Name] = true
+		}
+	}
+	return len(w.started)
+}
@@ -0,0 +1,98 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/testground/testground/pkg/api"
+	"github.com/testground/testground/pkg/rpc"
+	"github.com/testground/testground/pkg/runner"
+	"github.com/testground/testground/pkg/task"
+)
+
+func TestWithTimeout(t *testing.T) {
+	// Stages returning in time keep their error.
+	ctx, done := withTimeout(context.Background(), "build", time.Minute)
+	require.NoError(t, ctx.Err())
+	require.NoError(t, done(nil))
+
+	// Stages running out of time return a TimeoutError.
+	ctx, done = withTimeout(context.Background(), "build", time.Millisecond)
+	<-ctx.Done()
+	err := done(ctx.Err())
+	var te *TimeoutError
+	require.True(t, errors.As(err, &te))
+	require.Equal(t, "build", te.Stage)
+	require.True(t, errors.Is(err, context.DeadlineExceeded))
+
+	// The parent context being canceled isn't a timeout.
+	parent, cancel := context.WithCancel(context.Background())
+	ctx, done = withTimeout(parent, "run", time.Millisecond)
+	cancel()
+	<-ctx.Done()
+	time.Sleep(5 * time.Millisecond)
+	require.False(t, errors.As(done(ctx.Err()), &te))
+
+	// No timeout applies without a duration.
+	ctx, done = withTimeout(context.Background(), "run", 0)
+	_, ok := ctx.Deadline()
+	require.False(t, ok)
+	require.Equal(t, context.Canceled, done(context.Canceled))
+}
+
+func TestMarkTimedOut(t *testing.T) {
+	state := &task.DatedState{State: task.StateComplete}
+	res := markTimedOut(state, &runner.Result{Outcome: task.OutcomeFailure})
+	require.Equal(t, task.StateCanceled, state.State)
+	require.Equal(t, task.OutcomeTimedOut, res.(*runner.Result).Outcome)
+
+	// Runs timing out before returning a result get one.
+	res = markTimedOut(state, nil)
+	require.Equal(t, task.OutcomeTimedOut, res.(*runner.Result).Outcome)
+}
+
+func TestStartWatch(t *testing.T) {
+	defer func(d time.Duration) { startPollInterval = d }(startPollInterval)
+	startPollInterval = time.Millisecond
+
+	run := &fakeRunner{instances: []*api.Instance{
+		{Name: "a-0", State: "running"},
+		{Name: "a-1", State: "exited"},
+	}}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	w, err := newStartWatch(run, &api.RunInput{RunID: "run", TotalInstances: 2}, time.Minute, rpc.Discard(), cancel)
+	require.NoError(t, err)
+	w.start(ctx)
+
+	// The watch ends once all instances started, without aborting the run.
+	require.NoError(t, w.stop())
+	require.NoError(t, ctx.Err())
+
+	// Instances not started by the start timeout abort the run.
+	run.instances = append(run.instances, &api.Instance{Name: "a-2", State: "created"})
+	ctx, cancel = context.WithCancel(context.Background())
+	defer cancel()
+
+	w, err = newStartWatch(run, &api.RunInput{RunID: "run", TotalInstances: 3}, 20*time.Millisecond, rpc.Discard(), cancel)
+	require.NoError(t, err)
+	w.start(ctx)
+
+	var te *TimeoutError
+	require.True(t, errors.As(w.stop(), &te))
+	require.Equal(t, "start", te.Stage)
+	require.Error(t, ctx.Err())
+	require.Len(t, w.started, 2)
+}
+
+func TestStartWatchWithoutTimeout(t *testing.T) {
+	w, err := newStartWatch(&fakeRunner{}, &api.RunInput{}, 0, rpc.Discard(), func() {})
+	require.NoError(t, err)
+	require.Nil(t, w)
+}
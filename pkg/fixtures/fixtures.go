@@ -0,0 +1,275 @@
+// Package fixtures fetches the fixtures of runs, the data sets mounted
+// read-only into their instances, and caches them on the host of the runner,
+// so that every fixture is fetched once rather than by every instance of every
+// run.
+package fixtures
+
+import (
+	"archive/tar"
+	"bufio"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/client"
+
+	"github.com/testground/testground/pkg/api"
+	"github.com/testground/testground/pkg/config"
+	"github.com/testground/testground/pkg/docker"
+	"github.com/testground/testground/pkg/rpc"
+)
+
+// locks serializes the fetches of a fixture into a cache directory, by the
+// path it is cached at.
+var (
+	locksLk sync.Mutex
+	locks   = make(map[string]*sync.Mutex)
+)
+
+func lock(path string) func() {
+	locksLk.Lock()
+	l, ok := locks[path]
+	if !ok {
+		l = new(sync.Mutex)
+		locks[path] = l
+	}
+	locksLk.Unlock()
+
+	l.Lock()
+	return l.Unlock
+}
+
+// Cached returns the directory of the host holding a fixture, if it's local
+// or cached, without fetching it. It's what the runners use in offline mode.
+func Cached(cacheDir string, f *api.Fixture) (string, error) {
+	if dir, ok := f.LocalDir(); ok {
+		if fi, err := os.Stat(dir); err != nil {
+			return "", fmt.Errorf("fixture %s: %w", f.Name, err)
+		} else if !fi.IsDir() {
+			return "", fmt.Errorf("fixture %s: %s is not a directory", f.Name, dir)
+		}
+		return dir, nil
+	}
+
+	dir := filepath.Join(cacheDir, CacheKey(f))
+	defer lock(dir)()
+
+	if _, err := os.Stat(dir); err != nil {
+		return "", fmt.Errorf("%w: fixture %s from %s is not cached, and can't be fetched", config.ErrOffline, f.Name, f.Source)
+	}
+	return dir, nil
+}
+
+// Fetch returns the directory of the host holding a fixture, and fetches it
+// into the cache directory if it isn't cached yet. Local directories are used
+// in place.
+func Fetch(ctx context.Context, cacheDir string, f *api.Fixture, ow *rpc.OutputWriter) (string, error) {
+	if dir, ok := f.LocalDir(); ok {
+		if fi, err := os.Stat(dir); err != nil {
+			return "", fmt.Errorf("fixture %s: %w", f.Name, err)
+		} else if !fi.IsDir() {
+			return "", fmt.Errorf("fixture %s: %s is not a directory", f.Name, dir)
+		}
+		return dir, nil
+	}
+
+	dir := filepath.Join(cacheDir, CacheKey(f))
+	defer lock(dir)()
+
+	if _, err := os.Stat(dir); err == nil {
+		ow.Infow("using cached fixture", "fixture", f.Name, "source", f.Source)
+		return dir, nil
+	}
+
+	if err := os.MkdirAll(cacheDir, 0755); err != nil {
+		return "", err
+	}
+	tmp, err := ioutil.TempDir(cacheDir, ".fetch-")
+	if err != nil {
+		return "", err
+	}
+	defer os.RemoveAll(tmp)
+
+	ow.Infow("fetching fixture", "fixture", f.Name, "source", f.Source)
+	if f.IsImage() {
+		err = fetchImage(ctx, tmp, strings.TrimPrefix(f.Source, "oci://"), f.ImageSourcePath())
+	} else {
+		err = fetchTarball(ctx, tmp, f.Source, f.SHA256)
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to fetch fixture %s: %w", f.Name, err)
+	}
+
+	if err := os.Rename(tmp, dir); err != nil {
+		return "", err
+	}
+	return dir, nil
+}
+
+// CacheKey names the cache entry of a fixture after what is fetched.
+func CacheKey(f *api.Fixture) string {
+	h := sha256.New()
+	_, _ = io.WriteString(h, f.Source)
+	switch {
+	case f.IsImage():
+		_, _ = io.WriteString(h, "\x00"+f.ImageSourcePath())
+	case f.SHA256 != "":
+		_, _ = io.WriteString(h, "\x00"+f.SHA256)
+	}
+	return hex.EncodeToString(h.Sum(nil))[:32]
+}
+
+// fetchTarball downloads a tarball, optionally gzipped, and extracts it into
+// dir. If digest is set, the tarball must match it.
+func fetchTarball(ctx context.Context, dir string, url string, digest string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s returned %s", url, resp.Status)
+	}
+
+	h := sha256.New()
+	r := bufio.NewReader(io.TeeReader(resp.Body, h))
+	var src io.Reader = r
+	if magic, err := r.Peek(2); err == nil && magic[0] == 0x1f && magic[1] == 0x8b {
+		gz, err := gzip.NewReader(r)
+		if err != nil {
+			return err
+		}
+		defer gz.Close()
+		src = gz
+	}
+	if err := extract(dir, src, false); err != nil {
+		return err
+	}
+
+	if digest == "" {
+		return nil
+	}
+	// Hash whatever trails the archive too.
+	if _, err := io.Copy(ioutil.Discard, r); err != nil {
+		return err
+	}
+	if sum := hex.EncodeToString(h.Sum(nil)); sum != digest {
+		return fmt.Errorf("sha256 mismatch: expected %s, got %s", digest, sum)
+	}
+	return nil
+}
+
+// fetchImage pulls an OCI image, and copies the directory holding the fixture
+// out of it into dir.
+func fetchImage(ctx context.Context, dir string, ref string, srcPath string) error {
+	cli, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
+	if err != nil {
+		return err
+	}
+	defer cli.Close()
+
+	if err := docker.PullImage(ctx, rpc.Discard(), cli, ref); err != nil {
+		return err
+	}
+
+	// The container is never started; images carrying data may have no
+	// command.
+	res, err := cli.ContainerCreate(ctx, &container.Config{Image: ref, Cmd: []string{"true"}}, nil, nil, "")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = cli.ContainerRemove(context.Background(), res.ID, types.ContainerRemoveOptions{Force: true})
+	}()
+
+	rc, _, err := cli.CopyFromContainer(ctx, res.ID, srcPath)
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+
+	// Docker archives the directory with the directory as the root entry.
+	return extract(dir, rc, true)
+}
+
+// extract extracts a tar archive into dir, optionally stripping the first
+// component of the entries. Entries escaping dir are rejected.
+func extract(dir string, r io.Reader, stripRoot bool) error {
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		name := strings.TrimPrefix(hdr.Name, "./")
+		if stripRoot {
+			i := strings.Index(name, "/")
+			if i < 0 {
+				continue
+			}
+			name = name[i+1:]
+		}
+		if name == "" {
+			continue
+		}
+
+		target := filepath.Join(dir, filepath.FromSlash(name))
+		if !strings.HasPrefix(target, filepath.Clean(dir)+string(filepath.Separator)) {
+			return fmt.Errorf("archive entry %q escapes the fixture", hdr.Name)
+		}
+
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(target, 0755); err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+				return err
+			}
+			f, err := os.OpenFile(target, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, os.FileMode(hdr.Mode)&0755|0444)
+			if err != nil {
+				return err
+			}
+			_, err = io.Copy(f, tr)
+			if cerr := f.Close(); err == nil {
+				err = cerr
+			}
+			if err != nil {
+				return err
+			}
+		case tar.TypeSymlink:
+			if filepath.IsAbs(hdr.Linkname) || strings.HasPrefix(filepath.Clean(filepath.Join(filepath.Dir(name), hdr.Linkname)), "..") {
+				return fmt.Errorf("archive entry %q links outside the fixture", hdr.Name)
+			}
+			if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+				return err
+			}
+			if err := os.Symlink(hdr.Linkname, target); err != nil {
+				return err
+			}
+		default:
+			// Devices, hard links and the like have no place in a fixture.
+		}
+	}
+}
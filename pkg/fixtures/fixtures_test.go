@@ -0,0 +1,198 @@
+package fixtures
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/testground/testground/pkg/api"
+	"github.com/testground/testground/pkg/config"
+	"github.com/testground/testground/pkg/rpc"
+)
+
+type entry struct {
+	name, body string
+	typ        byte
+	link       string
+}
+
+func tarball(t *testing.T, gz bool, entries ...entry) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	var tw *tar.Writer
+	var zw *gzip.Writer
+	if gz {
+		zw = gzip.NewWriter(&buf)
+		tw = tar.NewWriter(zw)
+	} else {
+		tw = tar.NewWriter(&buf)
+	}
+	for _, e := range entries {
+		typ := e.typ
+		if typ == 0 {
+			typ = tar.TypeReg
+		}
+		hdr := &tar.Header{Name: e.name, Mode: 0644, Size: int64(len(e.body)), Typeflag: typ, Linkname: e.link}
+		if typ != tar.TypeReg {
+			hdr.Size = 0
+		}
+		require.NoError(t, tw.WriteHeader(hdr))
+		if typ == tar.TypeReg {
+			_, err := tw.Write([]byte(e.body))
+			require.NoError(t, err)
+		}
+	}
+	require.NoError(t, tw.Close())
+	if zw != nil {
+		require.NoError(t, zw.Close())
+	}
+	return buf.Bytes()
+}
+
+// serve serves body, and counts the requests.
+func serve(t *testing.T, body []byte) (string, *int32) {
+	var hits int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		_, _ = w.Write(body)
+	}))
+	t.Cleanup(srv.Close)
+	return srv.URL + "/chain.tar", &hits
+}
+
+func TestFetchTarballCaches(t *testing.T) {
+	for _, gz := range []bool{false, true} {
+		body := tarball(t, gz,
+			entry{name: "blocks/", typ: tar.TypeDir},
+			entry{name: "blocks/0001", body: "genesis"},
+			entry{name: "./HEAD", body: "0001"},
+			entry{name: "LATEST", typ: tar.TypeSymlink, link: "blocks/0001"},
+		)
+		url, hits := serve(t, body)
+		cache := t.TempDir()
+		f := &api.Fixture{Name: "chain", Source: url}
+
+		dir, err := Fetch(context.Background(), cache, f, rpc.Discard())
+		require.NoError(t, err)
+		require.Equal(t, filepath.Join(cache, CacheKey(f)), dir)
+
+		b, err := ioutil.ReadFile(filepath.Join(dir, "LATEST"))
+		require.NoError(t, err)
+		require.Equal(t, "genesis", string(b))
+		b, err = ioutil.ReadFile(filepath.Join(dir, "HEAD"))
+		require.NoError(t, err)
+		require.Equal(t, "0001", string(b))
+
+		// The second fetch is served from the cache.
+		again, err := Fetch(context.Background(), cache, f, rpc.Discard())
+		require.NoError(t, err)
+		require.Equal(t, dir, again)
+		require.EqualValues(t, 1, atomic.LoadInt32(hits))
+
+		// Nothing but the cache entry is left behind.
+		ls, err := ioutil.ReadDir(cache)
+		require.NoError(t, err)
+		require.Len(t, ls, 1)
+	}
+}
+
+func TestFetchTarballChecksum(t *testing.T) {
+	body := tarball(t, true, entry{name: "HEAD", body: "0001"})
+	sum := sha256.Sum256(body)
+	url, _ := serve(t, body)
+	cache := t.TempDir()
+
+	good := &api.Fixture{Name: "chain", Source: url, SHA256: hex.EncodeToString(sum[:])}
+	dir, err := Fetch(context.Background(), cache, good, rpc.Discard())
+	require.NoError(t, err)
+	require.DirExists(t, dir)
+
+	bad := &api.Fixture{Name: "chain", Source: url, SHA256: hex.EncodeToString(make([]byte, 32))}
+	require.NotEqual(t, CacheKey(good), CacheKey(bad))
+	_, err = Fetch(context.Background(), cache, bad, rpc.Discard())
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "sha256 mismatch")
+
+	// Mismatching tarballs are never cached.
+	_, err = os.Stat(filepath.Join(cache, CacheKey(bad)))
+	require.True(t, os.IsNotExist(err))
+}
+
+func TestFetchTarballRejectsEscapes(t *testing.T) {
+	for i, body := range [][]byte{
+		tarball(t, false, entry{name: "../evil", body: "x"}),
+		tarball(t, false, entry{name: "evil", typ: tar.TypeSymlink, link: "/etc/passwd"}),
+		tarball(t, false, entry{name: "a/evil", typ: tar.TypeSymlink, link: "../../etc"}),
+	} {
+		url, _ := serve(t, body)
+		cache := t.TempDir()
+		f := &api.Fixture{Name: "chain", Source: url}
+		_, err := Fetch(context.Background(), cache, f, rpc.Discard())
+		require.Error(t, err, "entry %d", i)
+		_, err = os.Stat(filepath.Join(cache, CacheKey(f)))
+		require.True(t, os.IsNotExist(err))
+	}
+}
+
+func TestFetchTarballHTTPError(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	defer srv.Close()
+
+	_, err := Fetch(context.Background(), t.TempDir(), &api.Fixture{Name: "chain", Source: srv.URL}, rpc.Discard())
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "404")
+}
+
+func TestFetchLocalDir(t *testing.T) {
+	src := t.TempDir()
+	cache := t.TempDir()
+
+	for _, source := range []string{src, "file://" + src} {
+		dir, err := Fetch(context.Background(), cache, &api.Fixture{Name: "chain", Source: source}, rpc.Discard())
+		require.NoError(t, err)
+		require.Equal(t, src, dir)
+	}
+
+	file := filepath.Join(src, "file")
+	require.NoError(t, ioutil.WriteFile(file, nil, 0644))
+	_, err := Fetch(context.Background(), cache, &api.Fixture{Name: "chain", Source: file}, rpc.Discard())
+	require.Error(t, err)
+
+	_, err = Fetch(context.Background(), cache, &api.Fixture{Name: "chain", Source: filepath.Join(src, "missing")}, rpc.Discard())
+	require.Error(t, err)
+}
+
+func TestCached(t *testing.T) {
+	url, hits := serve(t, tarball(t, true, entry{name: "HEAD", body: "0001"}))
+	cache := t.TempDir()
+	f := &api.Fixture{Name: "chain", Source: url}
+
+	_, err := Cached(cache, f)
+	require.True(t, errors.Is(err, config.ErrOffline))
+
+	fetched, err := Fetch(context.Background(), cache, f, rpc.Discard())
+	require.NoError(t, err)
+	dir, err := Cached(cache, f)
+	require.NoError(t, err)
+	require.Equal(t, fetched, dir)
+	require.EqualValues(t, 1, atomic.LoadInt32(hits))
+
+	src := t.TempDir()
+	dir, err = Cached(cache, &api.Fixture{Name: "chain", Source: src})
+	require.NoError(t, err)
+	require.Equal(t, src, dir)
+}
@@ -0,0 +1,99 @@
+package grafana
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// Run is a run a dashboard is provisioned for.
+type Run struct {
+	ID string
+	// Plan and Case name the measurements of the metrics of the run, as the
+	// instances record them: <source>.<plan>-<case>.<metric>.<type>.
+	Plan   string
+	Case   string
+	Groups []string
+}
+
+// DashboardUID returns the uid of the dashboard of a run.
+func DashboardUID(runID string) string {
+	return "tg-" + runID
+}
+
+// RunDashboard returns the model of the dashboard of a run: a row per group
+// of the run, with a panel of the diagnostics and one of the results its
+// instances recorded, and the chaos actions of the run as annotations.
+func RunDashboard(run *Run) map[string]interface{} {
+	var (
+		panels []interface{}
+		id     int
+		y      int
+	)
+	for _, g := range run.Groups {
+		id++
+		panels = append(panels, map[string]interface{}{
+			"id":        id,
+			"type":      "row",
+			"title":     "group " + g,
+			"collapsed": false,
+			"gridPos":   gridPos(24, 1, 0, y),
+		})
+		y++
+
+		for i, source := range []string{"diagnostics", "results"} {
+			id++
+			panels = append(panels, map[string]interface{}{
+				"id":         id,
+				"type":       "timeseries",
+				"title":      fmt.Sprintf("%s (%s)", source, g),
+				"datasource": Datasource,
+				"gridPos":    gridPos(12, 9, 12*i, y),
+				"targets": []interface{}{
+					map[string]interface{}{
+						"refId":        "A",
+						"rawQuery":     true,
+						"resultFormat": "time_series",
+						"query":        MetricsQuery(run, source, g),
+					},
+				},
+			})
+		}
+		y += 9
+	}
+
+	return map[string]interface{}{
+		"uid":           DashboardUID(run.ID),
+		"title":         fmt.Sprintf("%s:%s (%s)", run.Plan, run.Case, run.ID),
+		"tags":          []string{"testground", run.Plan},
+		"timezone":      "browser",
+		"refresh":       "10s",
+		"schemaVersion": 27,
+		"time":          map[string]string{"from": "now-1h", "to": "now"},
+		"panels":        panels,
+		"annotations": map[string]interface{}{
+			"list": []interface{}{
+				map[string]interface{}{
+					"name":       "chaos",
+					"datasource": Datasource,
+					"enable":     true,
+					"iconColor":  "red",
+					"query":      fmt.Sprintf(`SELECT "rule" FROM "testground.chaos" WHERE "run" = '%s' AND $timeFilter`, run.ID),
+					"tagsColumn": "action",
+					"textColumn": "rule",
+				},
+			},
+		},
+	}
+}
+
+// MetricsQuery returns the InfluxQL query of the metrics of a source, e.g.
+// results, recorded by the instances of a group of a run.
+func MetricsQuery(run *Run, source, group string) string {
+	prefix := regexp.QuoteMeta(fmt.Sprintf("%s.%s-%s.", source, run.Plan, run.Case))
+	return fmt.Sprintf(`SELECT mean(*) FROM /^%s/ WHERE "run" = '%s' AND "group_id" = '%s' AND $timeFilter GROUP BY time($__interval) fill(none)`,
+		prefix, run.ID, group)
+}
+
+func gridPos(w, h, x, y int) map[string]int {
+	return map[string]int{"w": w, "h": h, "x": x, "y": y}
+}
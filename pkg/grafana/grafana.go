@@ -0,0 +1,130 @@
+// Package grafana provisions the InfluxDB datasource of Grafana, and the
+// dashboards of the metrics of runs, through its HTTP API.
+package grafana
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/testground/testground/pkg/config"
+)
+
+// Datasource is the name of the InfluxDB datasource the dashboards query.
+const Datasource = "testground"
+
+// Client provisions a Grafana.
+type Client struct {
+	cfg    config.ObservabilityConfig
+	client *http.Client
+}
+
+// New returns a client of the Grafana of the observability stack, or nil if
+// there is none.
+func New(cfg config.ObservabilityConfig) *Client {
+	if cfg.Stack == "" || cfg.GrafanaURL == "" {
+		return nil
+	}
+	cfg.GrafanaURL = strings.TrimSuffix(cfg.GrafanaURL, "/")
+	if cfg.GrafanaPublicURL == "" {
+		cfg.GrafanaPublicURL = cfg.GrafanaURL
+	}
+	cfg.GrafanaPublicURL = strings.TrimSuffix(cfg.GrafanaPublicURL, "/")
+	return &Client{cfg: cfg, client: &http.Client{Timeout: 10 * time.Second}}
+}
+
+// EnsureDatasource creates the InfluxDB datasource of the testground
+// database, unless it exists, or no InfluxDB URL is configured.
+func (c *Client) EnsureDatasource(ctx context.Context) error {
+	if c.cfg.InfluxDBURL == "" {
+		return nil
+	}
+
+	err := c.do(ctx, http.MethodGet, "/api/datasources/name/"+Datasource, nil, nil)
+	if err == nil {
+		return nil
+	}
+	if e, ok := err.(*StatusError); !ok || e.Code != http.StatusNotFound {
+		return err
+	}
+
+	return c.do(ctx, http.MethodPost, "/api/datasources", map[string]interface{}{
+		"name":     Datasource,
+		"type":     "influxdb",
+		"access":   "proxy",
+		"url":      c.cfg.InfluxDBURL,
+		"database": "testground",
+	}, nil)
+}
+
+// ProvisionDashboard creates or replaces the dashboard of a run, and returns
+// its URL.
+func (c *Client) ProvisionDashboard(ctx context.Context, run *Run) (string, error) {
+	var resp struct {
+		URL string `json:"url"`
+	}
+	err := c.do(ctx, http.MethodPost, "/api/dashboards/db", map[string]interface{}{
+		"dashboard": RunDashboard(run),
+		"overwrite": true,
+		"message":   "testground run " + run.ID,
+	}, &resp)
+	if err != nil {
+		return "", fmt.Errorf("failed to provision the dashboard of run %s: %w", run.ID, err)
+	}
+	return c.cfg.GrafanaPublicURL + resp.URL, nil
+}
+
+// StatusError is the error of a request Grafana responded to with an
+// unexpected status.
+type StatusError struct {
+	Code int
+	Body string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("grafana responded with status %d: %s", e.Code, e.Body)
+}
+
+func (c *Client) do(ctx context.Context, method, path string, in, out interface{}) error {
+	var body io.Reader
+	if in != nil {
+		b, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(b)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.cfg.GrafanaURL+path, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+	if c.cfg.GrafanaToken != "" {
+		req.Header.Set("Authorization", "Bearer "+c.cfg.GrafanaToken)
+	} else if c.cfg.GrafanaUser != "" {
+		req.SetBasicAuth(c.cfg.GrafanaUser, c.cfg.GrafanaPassword)
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		b, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+		return &StatusError{Code: resp.StatusCode, Body: strings.TrimSpace(string(b))}
+	}
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
@@ -0,0 +1,93 @@
+package grafana
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/testground/testground/pkg/config"
+)
+
+func TestProvision(t *testing.T) {
+	var (
+		datasources map[string]interface{}
+		dashboard   map[string]interface{}
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, pass, ok := r.BasicAuth()
+		require.True(t, ok)
+		require.Equal(t, "admin", user)
+		require.Equal(t, "secret", pass)
+
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/api/datasources/name/testground":
+			if datasources == nil {
+				w.WriteHeader(http.StatusNotFound)
+			}
+		case r.Method == http.MethodPost && r.URL.Path == "/api/datasources":
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&datasources))
+		case r.Method == http.MethodPost && r.URL.Path == "/api/dashboards/db":
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&dashboard))
+			_, _ = w.Write([]byte(`{"status":"success","url":"/d/tg-c3ftkqjpc98qra498sg0/network"}`))
+		default:
+			w.WriteHeader(http.StatusForbidden)
+		}
+	}))
+	defer srv.Close()
+
+	require.Nil(t, New(config.ObservabilityConfig{GrafanaURL: srv.URL}))
+
+	c := New(config.ObservabilityConfig{
+		Stack:            config.ObservabilityStackExternal,
+		GrafanaURL:       srv.URL + "/",
+		GrafanaPublicURL: "https://grafana.example.com/",
+		GrafanaUser:      "admin",
+		GrafanaPassword:  "secret",
+		InfluxDBURL:      "http://influxdb:8086",
+	})
+	ctx := context.Background()
+
+	// The datasource is created once.
+	require.NoError(t, c.EnsureDatasource(ctx))
+	require.Equal(t, "http://influxdb:8086", datasources["url"])
+	require.Equal(t, "influxdb", datasources["type"])
+	datasources["url"] = "unchanged"
+	require.NoError(t, c.EnsureDatasource(ctx))
+	require.Equal(t, "unchanged", datasources["url"])
+
+	url, err := c.ProvisionDashboard(ctx, &Run{ID: "c3ftkqjpc98qra498sg0", Plan: "network", Case: "ping-pong", Groups: []string{"a", "b"}})
+	require.NoError(t, err)
+	require.Equal(t, "https://grafana.example.com/d/tg-c3ftkqjpc98qra498sg0/network", url)
+	require.Equal(t, true, dashboard["overwrite"])
+
+	model := dashboard["dashboard"].(map[string]interface{})
+	require.Equal(t, "tg-c3ftkqjpc98qra498sg0", model["uid"])
+	// A row and two panels per group.
+	require.Len(t, model["panels"], 6)
+}
+
+func TestProvisionError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "Bearer key", r.Header.Get("Authorization"))
+		w.WriteHeader(http.StatusUnauthorized)
+		_, _ = w.Write([]byte(`{"message":"invalid API key"}`))
+	}))
+	defer srv.Close()
+
+	c := New(config.ObservabilityConfig{Stack: config.ObservabilityStackExternal, GrafanaURL: srv.URL, GrafanaToken: "key"})
+	_, err := c.ProvisionDashboard(context.Background(), &Run{ID: "run"})
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "invalid API key")
+
+	// Without an InfluxDB, there is no datasource to provision.
+	require.NoError(t, c.EnsureDatasource(context.Background()))
+}
+
+func TestMetricsQuery(t *testing.T) {
+	q := MetricsQuery(&Run{ID: "c3ft", Plan: "network", Case: "ping-pong"}, "results", "a")
+	require.Equal(t, `SELECT mean(*) FROM /^results\.network-ping-pong\./ WHERE "run" = 'c3ft' AND "group_id" = 'a' AND $timeFilter GROUP BY time($__interval) fill(none)`, q)
+}
@@ -6,11 +6,14 @@ import (
 	"net"
 	"os"
 	"os/exec"
+	"sort"
+	"strings"
 
 	"github.com/testground/testground/pkg/docker"
 	"github.com/testground/testground/pkg/rpc"
 
 	"github.com/docker/docker/client"
+	v1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes"
 )
@@ -118,6 +121,32 @@ func CheckK8sPods(ctx context.Context, client *kubernetes.Clientset, label strin
 	}
 }
 
+// CheckK8sNodesArchitecture returns a Checker that succeeds if all the nodes
+// have the same CPU architecture, and that architecture is arch, if set.
+func CheckK8sNodesArchitecture(nodes []v1.Node, arch string) Checker {
+	return func() (bool, string, error) {
+		found := make(map[string]int)
+		for _, n := range nodes {
+			found[n.Status.NodeInfo.Architecture]++
+		}
+
+		archs := make([]string, 0, len(found))
+		for a, count := range found {
+			archs = append(archs, fmt.Sprintf("%s (%d nodes)", a, count))
+		}
+		sort.Strings(archs)
+		msg := fmt.Sprintf("found architectures: %s", strings.Join(archs, ", "))
+
+		switch {
+		case len(found) > 1:
+			return false, msg + "; nodes must all have the same architecture", nil
+		case arch != "" && len(nodes) > 0 && found[arch] == 0:
+			return false, msg + fmt.Sprintf("; expected %s", arch), nil
+		}
+		return true, msg, nil
+	}
+}
+
 // CheckRedisPort returns a checker which verifies if the default port of redis (6379) is already binded
 // on localhost. If it is, it fails. If not, it succeeds.
 func CheckRedisPort(ctx context.Context, ow *rpc.OutputWriter, cli *client.Client) Checker {
@@ -0,0 +1,31 @@
+package healthcheck
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+)
+
+func node(arch string) v1.Node {
+	var n v1.Node
+	n.Status.NodeInfo.Architecture = arch
+	return n
+}
+
+func TestCheckK8sNodesArchitecture(t *testing.T) {
+	ok, _, err := CheckK8sNodesArchitecture([]v1.Node{node("arm64"), node("arm64")}, "")()
+	require.NoError(t, err)
+	require.True(t, ok)
+
+	ok, _, _ = CheckK8sNodesArchitecture([]v1.Node{node("arm64"), node("arm64")}, "arm64")()
+	require.True(t, ok)
+
+	ok, msg, _ := CheckK8sNodesArchitecture([]v1.Node{node("arm64")}, "amd64")()
+	require.False(t, ok)
+	require.Contains(t, msg, "expected amd64")
+
+	ok, msg, _ = CheckK8sNodesArchitecture([]v1.Node{node("arm64"), node("amd64")}, "")()
+	require.False(t, ok)
+	require.Contains(t, msg, "amd64 (1 nodes), arm64 (1 nodes)")
+}
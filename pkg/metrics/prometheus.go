@@ -0,0 +1,97 @@
+package metrics
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "testground"
+
+// Registry holds the Prometheus collectors of the daemon itself (as opposed to
+// the metrics emitted by test plans), served by the daemon under /metrics.
+var Registry = prometheus.NewRegistry()
+
+var (
+	// TasksQueued is the number of tasks waiting in the queue.
+	TasksQueued = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "tasks_queued",
+		Help:      "Number of tasks waiting in the queue.",
+	})
+
+	// TasksRunning is the number of tasks being processed, per task type.
+	TasksRunning = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "tasks_running",
+		Help:      "Number of tasks currently being processed.",
+	}, []string{"type"})
+
+	// TasksFinished counts the tasks that finished processing, per task type
+	// and outcome (success, failure, canceled).
+	TasksFinished = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "tasks_finished_total",
+		Help:      "Number of tasks that finished processing, by outcome.",
+	}, []string{"type", "outcome"})
+
+	// BuildDuration observes the duration of build jobs, per builder.
+	BuildDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "build_duration_seconds",
+		Help:      "Duration of build jobs.",
+		Buckets:   prometheus.ExponentialBuckets(5, 2, 10), // 5s to ~43m
+	}, []string{"builder", "outcome"})
+
+	// RunDuration observes the duration of runs, per runner.
+	RunDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "run_duration_seconds",
+		Help:      "Duration of test runs.",
+		Buckets:   prometheus.ExponentialBuckets(5, 2, 10), // 5s to ~43m
+	}, []string{"runner", "outcome"})
+
+	// ImageCache counts the lookups of cached build images, per builder and
+	// result (hit or miss).
+	ImageCache = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "image_cache_lookups_total",
+		Help:      "Number of build cache image lookups, by result.",
+	}, []string{"builder", "result"})
+
+	// SyncSessions is the number of open sync service sessions the runners
+	// hold to collect the outcomes of runs, per runner.
+	SyncSessions = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "sync_sessions",
+		Help:      "Number of open sync service sessions.",
+	}, []string{"runner"})
+)
+
+func init() {
+	Registry.MustRegister(
+		prometheus.NewGoCollector(),
+		prometheus.NewProcessCollector(prometheus.ProcessCollectorOpts{}),
+		TasksQueued,
+		TasksRunning,
+		TasksFinished,
+		BuildDuration,
+		RunDuration,
+		ImageCache,
+		SyncSessions,
+	)
+}
+
+// Outcome returns the outcome label value for an error.
+func Outcome(err error) string {
+	if err != nil {
+		return "failure"
+	}
+	return "success"
+}
+
+// Handler returns the HTTP handler exposing the registered metrics.
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{})
+}
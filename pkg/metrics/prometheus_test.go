@@ -0,0 +1,21 @@
+package metrics
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandler(t *testing.T) {
+	TasksQueued.Set(3)
+	BuildDuration.WithLabelValues("docker:go", Outcome(nil)).Observe(42)
+
+	rec := httptest.NewRecorder()
+	Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+
+	body := rec.Body.String()
+	require.Contains(t, body, "testground_tasks_queued 3")
+	require.Contains(t, body, `testground_build_duration_seconds_count{builder="docker:go",outcome="success"} 1`)
+	require.Contains(t, body, "go_goroutines")
+}
@@ -0,0 +1,188 @@
+// Package notify posts the outcome of completed tasks to webhooks, and to
+// Slack and Discord incoming webhooks.
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/hashicorp/go-multierror"
+
+	"github.com/testground/testground/pkg/config"
+	"github.com/testground/testground/pkg/task"
+)
+
+// Event is the payload POSTed to webhooks when a task completes.
+type Event struct {
+	TaskID  string       `json:"task_id"`
+	Type    task.Type    `json:"type"`
+	Name    string       `json:"name"`
+	Plan    string       `json:"plan,omitempty"`
+	Case    string       `json:"case,omitempty"`
+	State   task.State   `json:"state"`
+	Outcome task.Outcome `json:"outcome"`
+	Error   string       `json:"error,omitempty"`
+	User    string       `json:"user,omitempty"`
+	// DurationSecs is how long the task took from being queued to
+	// completing, in seconds.
+	DurationSecs float64 `json:"duration_secs"`
+	// TaskURL and OutputsURL link to the task, and to the outputs of runs,
+	// when the root URL of the daemon is configured.
+	TaskURL    string `json:"task_url,omitempty"`
+	OutputsURL string `json:"outputs_url,omitempty"`
+	// DashboardURL links to the Grafana dashboard of runs, if provisioned.
+	DashboardURL string `json:"dashboard_url,omitempty"`
+}
+
+// NewEvent describes a completed task. rootURL is the URL the daemon is
+// reachable at, for links; there are none if it's empty.
+func NewEvent(tsk *task.Task, outcome task.Outcome, rootURL string) *Event {
+	ev := &Event{
+		TaskID:       tsk.ID,
+		Type:         tsk.Type,
+		Name:         tsk.Name(),
+		Plan:         tsk.Plan,
+		Case:         tsk.Case,
+		State:        tsk.State().State,
+		Outcome:      outcome,
+		Error:        tsk.Error,
+		User:         tsk.CreatedBy.User,
+		DurationSecs: tsk.Took().Seconds(),
+		DashboardURL: tsk.Dashboard,
+	}
+	if rootURL != "" {
+		ev.TaskURL = fmt.Sprintf("%s/tasks#taskID_%s", rootURL, tsk.ID)
+		if tsk.Type == task.TypeRun {
+			ev.OutputsURL = fmt.Sprintf("%s/outputs?run_id=%s", rootURL, tsk.ID)
+		}
+	}
+	return ev
+}
+
+// Notifier posts events to the configured webhooks.
+type Notifier struct {
+	cfg    config.NotificationsConfig
+	client *http.Client
+}
+
+// New returns a notifier, or nil if no webhook is configured.
+func New(cfg config.NotificationsConfig) *Notifier {
+	if len(cfg.Webhooks) == 0 && cfg.Slack == "" && cfg.Discord == "" {
+		return nil
+	}
+	return &Notifier{cfg: cfg, client: &http.Client{Timeout: 10 * time.Second}}
+}
+
+// Wants returns true if the tasks of the given outcome are notified.
+func (n *Notifier) Wants(outcome task.Outcome) bool {
+	if len(n.cfg.Outcomes) == 0 {
+		return true
+	}
+	for _, o := range n.cfg.Outcomes {
+		if task.Outcome(o) == outcome {
+			return true
+		}
+	}
+	return false
+}
+
+// Notify posts an event to all the webhooks. A failing webhook doesn't
+// prevent the others from being posted to.
+func (n *Notifier) Notify(ctx context.Context, ev *Event) error {
+	var merr *multierror.Error
+	for _, url := range n.cfg.Webhooks {
+		if err := n.post(ctx, url, ev); err != nil {
+			merr = multierror.Append(merr, err)
+		}
+	}
+	if n.cfg.Slack != "" {
+		if err := n.post(ctx, n.cfg.Slack, map[string]string{"text": message(ev, true)}); err != nil {
+			merr = multierror.Append(merr, err)
+		}
+	}
+	if n.cfg.Discord != "" {
+		if err := n.post(ctx, n.cfg.Discord, map[string]string{"content": message(ev, false)}); err != nil {
+			merr = multierror.Append(merr, err)
+		}
+	}
+	return merr.ErrorOrNil()
+}
+
+func (n *Notifier) post(ctx context.Context, url string, payload interface{}) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json; charset=UTF-8")
+
+	res, err := n.client.Do(req)
+	if err != nil {
+		return err
+	}
+	res.Body.Close()
+
+	if res.StatusCode >= 300 {
+		return fmt.Errorf("webhook %s returned %s", req.URL.Host, res.Status)
+	}
+	return nil
+}
+
+// message formats an event for a chat; Slack has its own syntax for links,
+// while Discord uses markdown.
+func message(ev *Event, slack bool) string {
+	var icon string
+	switch ev.Outcome {
+	case task.OutcomeSuccess:
+		icon = "✅"
+	case task.OutcomeFailure:
+		icon = "❌"
+	case task.OutcomeCanceled:
+		icon = "⚪"
+	case task.OutcomeTimedOut:
+		icon = "⏱"
+	default:
+		icon = "❔"
+	}
+
+	id, name := ev.TaskID, fmt.Sprintf("**%s**", ev.Name)
+	if slack {
+		name = fmt.Sprintf("*%s*", ev.Name)
+	}
+	switch {
+	case ev.TaskURL == "":
+	case slack:
+		id = fmt.Sprintf("<%s|%s>", ev.TaskURL, ev.TaskID)
+	default:
+		id = fmt.Sprintf("[%s](%s)", ev.TaskID, ev.TaskURL)
+	}
+
+	took := time.Duration(ev.DurationSecs * float64(time.Second))
+	msg := fmt.Sprintf("%s %s %s %s in %s", icon, id, name, ev.Outcome, took)
+	if ev.Error != "" {
+		msg += "; " + ev.Error
+	}
+	if ev.OutputsURL != "" {
+		if slack {
+			msg += fmt.Sprintf(" (<%s|outputs>)", ev.OutputsURL)
+		} else {
+			msg += fmt.Sprintf(" ([outputs](%s))", ev.OutputsURL)
+		}
+	}
+	if ev.DashboardURL != "" {
+		if slack {
+			msg += fmt.Sprintf(" (<%s|dashboard>)", ev.DashboardURL)
+		} else {
+			msg += fmt.Sprintf(" ([dashboard](%s))", ev.DashboardURL)
+		}
+	}
+	return msg
+}
@@ -0,0 +1,78 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/testground/testground/pkg/config"
+	"github.com/testground/testground/pkg/task"
+)
+
+func TestNotify(t *testing.T) {
+	posted := make(map[string]map[string]interface{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload map[string]interface{}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&payload))
+		posted[r.URL.Path] = payload
+		if r.URL.Path == "/broken" {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer srv.Close()
+
+	n := New(config.NotificationsConfig{
+		Webhooks: []string{srv.URL + "/hook", srv.URL + "/broken"},
+		Slack:    srv.URL + "/slack",
+		Discord:  srv.URL + "/discord",
+	})
+
+	now := time.Now()
+	tsk := &task.Task{
+		ID:        "c3ftkqjpc98qra498sg0",
+		Type:      task.TypeRun,
+		Plan:      "network",
+		Case:      "ping-pong",
+		Error:     "instance 3 crashed",
+		CreatedBy: task.CreatedBy{User: "alice"},
+		States: []task.DatedState{
+			{State: task.StateScheduled, Created: now.Add(-90 * time.Second)},
+			{State: task.StateComplete, Created: now},
+		},
+	}
+
+	err := n.Notify(context.Background(), NewEvent(tsk, task.OutcomeFailure, "https://tg.example.com"))
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "500")
+
+	// The other webhooks are posted to regardless.
+	hook := posted["/hook"]
+	require.Equal(t, "c3ftkqjpc98qra498sg0", hook["task_id"])
+	require.Equal(t, "failure", hook["outcome"])
+	require.Equal(t, "alice", hook["user"])
+	require.Equal(t, float64(90), hook["duration_secs"])
+	require.Equal(t, "https://tg.example.com/outputs?run_id=c3ftkqjpc98qra498sg0", hook["outputs_url"])
+
+	slack := posted["/slack"]["text"].(string)
+	require.True(t, strings.HasPrefix(slack, "❌ <https://tg.example.com/tasks#taskID_c3ftkqjpc98qra498sg0|c3ftkqjpc98qra498sg0> *network:ping-pong* failure in 1m30s; instance 3 crashed"), slack)
+
+	discord := posted["/discord"]["content"].(string)
+	require.Contains(t, discord, "[c3ftkqjpc98qra498sg0](https://tg.example.com/tasks#taskID_c3ftkqjpc98qra498sg0) **network:ping-pong**")
+}
+
+func TestWants(t *testing.T) {
+	require.Nil(t, New(config.NotificationsConfig{}))
+
+	n := New(config.NotificationsConfig{Slack: "http://localhost", Outcomes: []string{"failure"}})
+	require.True(t, n.Wants(task.OutcomeFailure))
+	require.False(t, n.Wants(task.OutcomeSuccess))
+
+	n = New(config.NotificationsConfig{Slack: "http://localhost"})
+	require.True(t, n.Wants(task.OutcomeSuccess))
+}
@@ -0,0 +1,165 @@
+package plansource
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+)
+
+// Fetch fetches a source into a cache directory, and returns the source
+// pinned to the commit it resolved to, along with the directory of the plan.
+//
+// The cache holds a checkout per repository and commit, without its git
+// metadata, so sources pinned to a commit that was fetched already are
+// served from the cache, without contacting the remote.
+func Fetch(ctx context.Context, cacheDir string, src *Source, progress io.Writer) (*Source, string, error) {
+	repoDir := filepath.Join(cacheDir, cacheKey(src.URL))
+
+	if plumbing.IsHash(src.Commit) {
+		if dir, err := pinnedDir(repoDir, src); err == nil {
+			return src, dir, nil
+		}
+	}
+
+	if err := os.MkdirAll(repoDir, 0755); err != nil {
+		return nil, "", err
+	}
+	tmp, err := ioutil.TempDir(repoDir, ".fetch-")
+	if err != nil {
+		return nil, "", err
+	}
+	defer os.RemoveAll(tmp)
+
+	repo, err := git.PlainCloneContext(ctx, tmp, false, &git.CloneOptions{
+		URL:        src.URL,
+		Progress:   progress,
+		NoCheckout: true,
+		Tags:       git.AllTags,
+	})
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to clone %s: %w", src.URL, err)
+	}
+
+	hash, err := resolve(repo, src)
+	if err != nil {
+		return nil, "", err
+	}
+
+	pinned := *src
+	pinned.Commit = hash.String()
+
+	if dir, err := pinnedDir(repoDir, &pinned); err == nil {
+		return &pinned, dir, nil
+	}
+
+	wt, err := repo.Worktree()
+	if err != nil {
+		return nil, "", err
+	}
+	if err := wt.Checkout(&git.CheckoutOptions{Hash: hash, Force: true}); err != nil {
+		return nil, "", fmt.Errorf("failed to check out %s of %s: %w", pinned.Commit, src.URL, err)
+	}
+	if err := os.RemoveAll(filepath.Join(tmp, git.GitDirName)); err != nil {
+		return nil, "", err
+	}
+
+	// Another fetch of the same commit may have won the race; its checkout
+	// is just as good.
+	if err := os.Rename(tmp, filepath.Join(repoDir, pinned.Commit)); err != nil && !os.IsExist(err) {
+		if _, serr := os.Stat(filepath.Join(repoDir, pinned.Commit)); serr != nil {
+			return nil, "", err
+		}
+	}
+
+	dir, err := pinnedDir(repoDir, &pinned)
+	if err != nil {
+		return nil, "", err
+	}
+	return &pinned, dir, nil
+}
+
+// resolve resolves the ref of a source to a commit: a commit if it's pinned
+// to one, the default branch if it has no ref, and a tag, branch or commit
+// otherwise.
+func resolve(repo *git.Repository, src *Source) (plumbing.Hash, error) {
+	if src.Commit != "" {
+		h, err := repo.ResolveRevision(plumbing.Revision(src.Commit))
+		if err != nil {
+			return plumbing.ZeroHash, fmt.Errorf("commit %s not found in %s: %w", src.Commit, src.URL, err)
+		}
+		return *h, nil
+	}
+
+	if src.Ref == "" {
+		head, err := repo.Head()
+		if err != nil {
+			return plumbing.ZeroHash, fmt.Errorf("failed to resolve the default branch of %s: %w", src.URL, err)
+		}
+		return head.Hash(), nil
+	}
+
+	// Branches of a clone are remote branches.
+	for _, rev := range []string{src.Ref, git.DefaultRemoteName + "/" + src.Ref} {
+		if h, err := repo.ResolveRevision(plumbing.Revision(rev)); err == nil {
+			return *h, nil
+		}
+	}
+	return plumbing.ZeroHash, fmt.Errorf("ref %s not found in %s", src.Ref, src.URL)
+}
+
+// Cached returns the directory of the plan of a source pinned to a commit it
+// was fetched at already, without contacting the remote.
+func Cached(cacheDir string, src *Source) (string, error) {
+	if !plumbing.IsHash(src.Commit) {
+		return "", fmt.Errorf("%s is not pinned to a commit", src)
+	}
+	return pinnedDir(filepath.Join(cacheDir, cacheKey(src.URL)), src)
+}
+
+// pinnedDir returns the directory of the plan of a pinned source in the
+// cache, if it's there. The directory must be within the checkout of the
+// commit once its symlinks are resolved, so that a repository can't point
+// the daemon at the files of its host.
+func pinnedDir(repoDir string, src *Source) (string, error) {
+	root := filepath.Join(repoDir, src.Commit)
+	dir := filepath.Join(root, filepath.FromSlash(src.Dir))
+	fi, err := os.Stat(dir)
+	switch {
+	case os.IsNotExist(err) && src.Dir != "":
+		if _, cerr := os.Stat(root); cerr == nil {
+			return "", fmt.Errorf("directory %s not found in %s at %s", src.Dir, src.URL, src.Commit)
+		}
+		return "", err
+	case err != nil:
+		return "", err
+	case !fi.IsDir():
+		return "", fmt.Errorf("%s is not a directory in %s at %s", src.Dir, src.URL, src.Commit)
+	}
+
+	root, err = filepath.EvalSymlinks(root)
+	if err != nil {
+		return "", err
+	}
+	if dir, err = filepath.EvalSymlinks(dir); err != nil {
+		return "", err
+	}
+	if rel, err := filepath.Rel(root, dir); err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("directory %s is outside of %s at %s", src.Dir, src.URL, src.Commit)
+	}
+	return dir, nil
+}
+
+// cacheKey returns the name of the cache directory of a repository.
+func cacheKey(url string) string {
+	sum := sha256.Sum256([]byte(url))
+	return hex.EncodeToString(sum[:8])
+}
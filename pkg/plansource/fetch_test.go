@@ -0,0 +1,131 @@
+package plansource
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/stretchr/testify/require"
+)
+
+// commitFile writes a file in a repository and commits it.
+func commitFile(t *testing.T, repo *git.Repository, dir, name, content string) plumbing.Hash {
+	t.Helper()
+
+	path := filepath.Join(dir, filepath.FromSlash(name))
+	require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+	require.NoError(t, ioutil.WriteFile(path, []byte(content), 0644))
+
+	wt, err := repo.Worktree()
+	require.NoError(t, err)
+	_, err = wt.Add(name)
+	require.NoError(t, err)
+	h, err := wt.Commit("update "+name, &git.CommitOptions{
+		Author: &object.Signature{Name: "test", Email: "test@example.com", When: time.Now()},
+	})
+	require.NoError(t, err)
+	return h
+}
+
+func TestFetch(t *testing.T) {
+	var (
+		remote = t.TempDir()
+		cache  = t.TempDir()
+		ctx    = context.Background()
+	)
+
+	repo, err := git.PlainInit(remote, false)
+	require.NoError(t, err)
+
+	v1 := commitFile(t, repo, remote, "plans/ping/manifest.toml", `name = "ping"`)
+	_, err = repo.CreateTag("v1", v1, nil)
+	require.NoError(t, err)
+	head := commitFile(t, repo, remote, "plans/ping/manifest.toml", `name = "ping-v2"`)
+
+	// A tag.
+	pinned, dir, err := Fetch(ctx, cache, &Source{URL: remote, Dir: "plans/ping", Ref: "v1"}, nil)
+	require.NoError(t, err)
+	require.Equal(t, v1.String(), pinned.Commit)
+	require.Equal(t, "v1", pinned.Ref)
+	b, err := ioutil.ReadFile(filepath.Join(dir, "manifest.toml"))
+	require.NoError(t, err)
+	require.Equal(t, `name = "ping"`, string(b))
+	require.NoDirExists(t, filepath.Join(dir, "..", "..", git.GitDirName))
+
+	// The default branch, and a branch.
+	for _, ref := range []string{"", "master"} {
+		pinned, dir, err = Fetch(ctx, cache, &Source{URL: remote, Dir: "plans/ping", Ref: ref}, nil)
+		require.NoError(t, err, ref)
+		require.Equal(t, head.String(), pinned.Commit, ref)
+		b, err = ioutil.ReadFile(filepath.Join(dir, "manifest.toml"))
+		require.NoError(t, err)
+		require.Equal(t, `name = "ping-v2"`, string(b))
+	}
+
+	// A missing ref, and a missing directory.
+	_, _, err = Fetch(ctx, cache, &Source{URL: remote, Ref: "v3"}, nil)
+	require.Error(t, err)
+	_, _, err = Fetch(ctx, cache, &Source{URL: remote, Dir: "plans/pong", Ref: "v1"}, nil)
+	require.Error(t, err)
+
+	// Pinned sources are served from the cache, even if the remote is gone.
+	require.NoError(t, os.RemoveAll(remote))
+	again, cached, err := Fetch(ctx, cache, &Source{URL: remote, Dir: "plans/ping", Commit: v1.String()}, nil)
+	require.NoError(t, err)
+	require.Equal(t, v1.String(), again.Commit)
+	b, err = ioutil.ReadFile(filepath.Join(cached, "manifest.toml"))
+	require.NoError(t, err)
+	require.Equal(t, `name = "ping"`, string(b))
+
+	// Which is all offline daemons serve.
+	dir, err = Cached(cache, &Source{URL: remote, Dir: "plans/ping", Commit: v1.String()})
+	require.NoError(t, err)
+	require.Equal(t, cached, dir)
+	_, err = Cached(cache, &Source{URL: remote, Dir: "plans/ping", Ref: "v1"})
+	require.Error(t, err)
+}
+
+func TestFetchOutsideCheckout(t *testing.T) {
+	var (
+		remote = t.TempDir()
+		cache  = t.TempDir()
+		host   = t.TempDir()
+		ctx    = context.Background()
+	)
+
+	repo, err := git.PlainInit(remote, false)
+	require.NoError(t, err)
+	commitFile(t, repo, remote, "plans/ping/manifest.toml", `name = "ping"`)
+
+	// A plan directory linking to a directory of the host, relative to where
+	// it's checked out in the cache.
+	require.NoError(t, ioutil.WriteFile(filepath.Join(host, "manifest.toml"), []byte(`name = "host"`), 0644))
+	target, err := filepath.Rel(filepath.Join(cache, cacheKey(remote), "commit", "plans"), host)
+	require.NoError(t, err)
+	require.NoError(t, os.Symlink(target, filepath.Join(remote, "plans", "evil")))
+	wt, err := repo.Worktree()
+	require.NoError(t, err)
+	_, err = wt.Add("plans/evil")
+	require.NoError(t, err)
+	_, err = wt.Commit("link", &git.CommitOptions{
+		Author: &object.Signature{Name: "test", Email: "test@example.com", When: time.Now()},
+	})
+	require.NoError(t, err)
+
+	_, _, err = Fetch(ctx, cache, &Source{URL: remote, Dir: "plans/evil"}, nil)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "is outside of")
+
+	_, _, err = Fetch(ctx, cache, &Source{URL: remote, Dir: "../.."}, nil)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "is outside of")
+
+	_, _, err = Fetch(ctx, cache, &Source{URL: remote, Dir: "plans/ping"}, nil)
+	require.NoError(t, err)
+}
@@ -0,0 +1,33 @@
+package plansource
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestHash(t *testing.T) {
+	write := func(dir, name, content string) {
+		require.NoError(t, ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644))
+	}
+
+	a, b := t.TempDir(), t.TempDir()
+	for _, dir := range []string{a, b} {
+		write(dir, "manifest.toml", `name = "ping"`)
+		write(dir, "main.go", "package main")
+	}
+	require.NoError(t, WriteRecord(b, &Source{URL: "https://github.com/org/plans", Commit: "0123"}))
+
+	ha, err := Hash(a)
+	require.NoError(t, err)
+	hb, err := Hash(b)
+	require.NoError(t, err)
+	require.Equal(t, ha, hb)
+
+	write(b, "main.go", "package main // changed")
+	hb, err = Hash(b)
+	require.NoError(t, err)
+	require.NotEqual(t, ha, hb)
+}
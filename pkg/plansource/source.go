@@ -0,0 +1,258 @@
+// Package plansource fetches test plans from remote git repositories, and
+// pins them to the commit they were fetched at.
+package plansource
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+)
+
+// RecordFile is the file, in the directory of an imported plan, recording
+// the source it was imported from.
+const RecordFile = ".testground-source.toml"
+
+// Source is a test plan in a git repository.
+type Source struct {
+	// URL is the URL of the repository, in any form git understands.
+	URL string `toml:"url" json:"url"`
+	// Dir is the directory of the plan in the repository; the root if empty.
+	Dir string `toml:"dir,omitempty" json:"dir,omitempty"`
+	// Ref is the branch, tag or commit requested; the default branch if
+	// empty.
+	Ref string `toml:"ref,omitempty" json:"ref,omitempty"`
+	// Commit is the commit the source is pinned to, once fetched.
+	Commit string `toml:"commit,omitempty" json:"commit,omitempty"`
+}
+
+// String returns the source in the form Parse accepts.
+func (s Source) String() string {
+	str := s.URL
+	if s.Dir != "" {
+		str += "//" + s.Dir
+	}
+	switch {
+	case s.Commit != "":
+		str += "?ref=" + s.Commit
+	case s.Ref != "":
+		str += "?ref=" + s.Ref
+	}
+	return str
+}
+
+// Name returns the default name of the plan: the base name of its directory,
+// or of the repository if it's at the root.
+func (s Source) Name() string {
+	if s.Dir != "" {
+		return path.Base(s.Dir)
+	}
+	u := strings.TrimSuffix(strings.TrimRight(s.URL, "/"), ".git")
+	if i := strings.LastIndexAny(u, "/:"); i >= 0 {
+		u = u[i+1:]
+	}
+	return u
+}
+
+// Parse parses a source, in one of these forms:
+//
+//	https://github.com/org/plans//path/to/plan?ref=v1.2
+//	git@github.com:org/plans.git//path/to/plan?ref=main
+//	github:org/plans/v1.2?dir=path/to/plan
+//	git+https://github.com/org/plans?ref=main&rev=<commit>&dir=path/to/plan
+//
+// The first two are go-getter style URLs, where a double slash separates the
+// repository from the directory of the plan. The last two are nix flake
+// references; github:, gitlab: and git+ references are supported.
+func Parse(s string) (*Source, error) {
+	if s == "" {
+		return nil, fmt.Errorf("empty plan source")
+	}
+
+	if src, ok, err := parseFlakeRef(s); ok {
+		return src, err
+	}
+
+	src := new(Source)
+	rest := s
+	if i := strings.LastIndex(rest, "?"); i >= 0 {
+		q, err := url.ParseQuery(rest[i+1:])
+		if err != nil {
+			return nil, fmt.Errorf("invalid query in plan source %s: %w", s, err)
+		}
+		if err := src.setQuery(q); err != nil {
+			return nil, fmt.Errorf("invalid plan source %s: %w", s, err)
+		}
+		rest = rest[:i]
+	}
+
+	// Skip the scheme, if any, before looking for the directory separator.
+	start := 0
+	if i := strings.Index(rest, "://"); i >= 0 {
+		start = i + len("://")
+	}
+	if i := strings.Index(rest[start:], "//"); i >= 0 {
+		src.Dir = rest[start+i+2:]
+		rest = rest[:start+i]
+	}
+	src.URL = strings.TrimPrefix(rest, "git::")
+
+	if err := src.clean(); err != nil {
+		return nil, fmt.Errorf("invalid plan source %s: %w", s, err)
+	}
+	return src, nil
+}
+
+// parseFlakeRef parses a nix flake reference. It returns false if s isn't
+// one.
+func parseFlakeRef(s string) (*Source, bool, error) {
+	var (
+		src    = new(Source)
+		scheme string
+	)
+	switch {
+	case strings.HasPrefix(s, "github:"), strings.HasPrefix(s, "gitlab:"):
+		scheme = s[:strings.Index(s, ":")]
+	case strings.HasPrefix(s, "git+"):
+		scheme = "git+"
+	default:
+		return nil, false, nil
+	}
+
+	rest := s
+	if i := strings.Index(rest, "?"); i >= 0 {
+		q, err := url.ParseQuery(rest[i+1:])
+		if err != nil {
+			return nil, true, fmt.Errorf("invalid query in flake reference %s: %w", s, err)
+		}
+		if err := src.setQuery(q); err != nil {
+			return nil, true, fmt.Errorf("invalid flake reference %s: %w", s, err)
+		}
+		rest = rest[:i]
+	}
+
+	if scheme == "git+" {
+		src.URL = strings.TrimPrefix(rest, "git+")
+	} else {
+		// github:owner/repo[/ref]
+		parts := strings.Split(strings.TrimPrefix(rest, scheme+":"), "/")
+		if len(parts) < 2 || len(parts) > 3 || parts[0] == "" || parts[1] == "" {
+			return nil, true, fmt.Errorf("invalid flake reference %s: expected %s:owner/repo[/ref]", s, scheme)
+		}
+		if len(parts) == 3 {
+			if src.Ref != "" || src.Commit != "" {
+				return nil, true, fmt.Errorf("invalid flake reference %s: ref set twice", s)
+			}
+			src.Ref = parts[2]
+		}
+		src.URL = fmt.Sprintf("https://%s.com/%s/%s", scheme, parts[0], parts[1])
+	}
+
+	if err := src.clean(); err != nil {
+		return nil, true, fmt.Errorf("invalid flake reference %s: %w", s, err)
+	}
+	return src, true, nil
+}
+
+// setQuery sets the fields of the source a query sets: ref, and the rev and
+// dir of flake references.
+func (s *Source) setQuery(q url.Values) error {
+	for k := range q {
+		switch k {
+		case "ref", "rev", "dir":
+		default:
+			return fmt.Errorf("unknown parameter %q", k)
+		}
+	}
+	s.Ref = q.Get("ref")
+	s.Commit = q.Get("rev")
+	if d := q.Get("dir"); d != "" {
+		s.Dir = d
+	}
+	return nil
+}
+
+// scpLike matches the scp-like syntax of ssh repositories,
+// [user@]host:path.
+var scpLike = regexp.MustCompile(`^(?:[^@/]+@)?([^:/]+):[^/]`)
+
+// CheckRemote checks that the source is one a daemon may clone on behalf of
+// its callers: a repository over https, or over ssh from one of sshHosts.
+// Local paths and file URLs, which would read the repositories of the host,
+// are rejected, and so are the other transports.
+func (s Source) CheckRemote(sshHosts []string) error {
+	var scheme, host string
+	if u, err := url.Parse(s.URL); err == nil && len(u.Scheme) > 1 {
+		scheme, host = strings.ToLower(u.Scheme), u.Hostname()
+	} else if m := scpLike.FindStringSubmatch(s.URL); m != nil && len(m[1]) > 1 {
+		scheme, host = "ssh", m[1]
+	}
+
+	switch scheme {
+	case "https":
+		if host == "" {
+			return fmt.Errorf("no host in %s", s.URL)
+		}
+		return nil
+	case "ssh":
+		for _, h := range sshHosts {
+			if strings.EqualFold(h, host) {
+				return nil
+			}
+		}
+		return fmt.Errorf("cloning from %s over ssh is not allowed", host)
+	case "":
+		return fmt.Errorf("%s is a local repository", s.URL)
+	default:
+		return fmt.Errorf("the %s transport is not allowed; use https", scheme)
+	}
+}
+
+func (s *Source) clean() error {
+	if s.URL == "" {
+		return fmt.Errorf("no repository")
+	}
+	if s.Dir == "" {
+		return nil
+	}
+	d := path.Clean(strings.Trim(s.Dir, "/"))
+	if d == ".." || strings.HasPrefix(d, "../") {
+		return fmt.Errorf("directory %s is outside of the repository", s.Dir)
+	}
+	if d == "." {
+		d = ""
+	}
+	s.Dir = d
+	return nil
+}
+
+// WriteRecord records the source a plan was imported from in its directory.
+func WriteRecord(dir string, src *Source) error {
+	f, err := os.Create(filepath.Join(dir, RecordFile))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return toml.NewEncoder(f).Encode(src)
+}
+
+// ReadRecord reads the source a plan was imported from. It returns nil if
+// the plan was not imported from a remote source.
+func ReadRecord(dir string) (*Source, error) {
+	var src Source
+	switch _, err := toml.DecodeFile(filepath.Join(dir, RecordFile), &src); {
+	case os.IsNotExist(err):
+		return nil, nil
+	case err != nil:
+		return nil, fmt.Errorf("failed to read the source of plan %s: %w", dir, err)
+	}
+	if src.URL == "" || src.Commit == "" {
+		return nil, fmt.Errorf("invalid source of plan %s: no url or commit", dir)
+	}
+	return &src, nil
+}
@@ -0,0 +1,89 @@
+package plansource
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	cases := []struct {
+		in   string
+		want Source
+	}{
+		{"https://github.com/org/plans//net/ping?ref=v1.2", Source{URL: "https://github.com/org/plans", Dir: "net/ping", Ref: "v1.2"}},
+		{"https://github.com/org/plans", Source{URL: "https://github.com/org/plans"}},
+		{"git::https://github.com/org/plans?ref=main", Source{URL: "https://github.com/org/plans", Ref: "main"}},
+		{"git@github.com:org/plans.git//ping", Source{URL: "git@github.com:org/plans.git", Dir: "ping"}},
+		{"file:///tmp/plans//ping/", Source{URL: "file:///tmp/plans", Dir: "ping"}},
+		{"/tmp/plans//ping", Source{URL: "/tmp/plans", Dir: "ping"}},
+		{"github:org/plans", Source{URL: "https://github.com/org/plans"}},
+		{"github:org/plans/v1.2?dir=ping", Source{URL: "https://github.com/org/plans", Dir: "ping", Ref: "v1.2"}},
+		{"gitlab:org/plans?rev=0123", Source{URL: "https://gitlab.com/org/plans", Commit: "0123"}},
+		{"git+ssh://git@github.com/org/plans?ref=main&dir=ping", Source{URL: "ssh://git@github.com/org/plans", Dir: "ping", Ref: "main"}},
+	}
+	for _, c := range cases {
+		src, err := Parse(c.in)
+		require.NoError(t, err, c.in)
+		require.Equal(t, c.want, *src, c.in)
+	}
+
+	for _, in := range []string{
+		"",
+		"https://github.com/org/plans//../etc",
+		"https://github.com/org/plans?tag=v1",
+		"github:org",
+		"github:org/plans/v1?ref=v2",
+	} {
+		_, err := Parse(in)
+		require.Error(t, err, in)
+	}
+}
+
+func TestSourceName(t *testing.T) {
+	require.Equal(t, "ping", Source{URL: "https://github.com/org/plans", Dir: "net/ping"}.Name())
+	require.Equal(t, "plans", Source{URL: "https://github.com/org/plans.git"}.Name())
+	require.Equal(t, "plans", Source{URL: "git@github.com:plans"}.Name())
+}
+
+func TestRecord(t *testing.T) {
+	dir := t.TempDir()
+
+	src, err := ReadRecord(dir)
+	require.NoError(t, err)
+	require.Nil(t, src)
+
+	want := &Source{URL: "https://github.com/org/plans", Dir: "ping", Ref: "v1.2", Commit: "0123456789012345678901234567890123456789"}
+	require.NoError(t, WriteRecord(dir, want))
+
+	src, err = ReadRecord(dir)
+	require.NoError(t, err)
+	require.Equal(t, want, src)
+}
+
+func TestCheckRemote(t *testing.T) {
+	hosts := []string{"github.com"}
+	for _, u := range []string{
+		"https://github.com/org/plans",
+		"ssh://git@github.com/org/plans",
+		"git@GitHub.com:org/plans.git",
+	} {
+		require.NoError(t, Source{URL: u}.CheckRemote(hosts), u)
+	}
+
+	for _, u := range []string{
+		"/srv/private",
+		"./plans",
+		"file:///srv/private",
+		"FILE:///srv/private",
+		"C:/plans",
+		"http://github.com/org/plans",
+		"git://github.com/org/plans",
+		"ssh://git@gitlab.com/org/plans",
+		"git@gitlab.com:org/plans.git",
+		"https:///srv/private",
+	} {
+		require.Error(t, Source{URL: u}.CheckRemote(hosts), u)
+	}
+	require.Error(t, Source{URL: "git@github.com:org/plans.git"}.CheckRemote(nil))
+}
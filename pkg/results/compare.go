@@ -0,0 +1,139 @@
+package results
+
+import (
+	"math"
+	"sort"
+)
+
+// Delta is the change of a measure of a metric between a baseline run and a
+// candidate run, averaged over the samples of the instances of a group.
+type Delta struct {
+	Group   string
+	Source  string
+	Name    string
+	Measure string
+	// Base and Head are NaN when the measure wasn't recorded by the
+	// baseline or the candidate run, respectively.
+	Base float64
+	Head float64
+	// Change is relative to the baseline, e.g. 0.1 for a 10% increase.
+	Change float64
+	// Regression is true if the change is beyond the threshold, in the
+	// direction that is worse for the metric.
+	Regression bool
+}
+
+// CompareOptions configures how deltas are judged.
+type CompareOptions struct {
+	// Threshold is the relative change beyond which a measure is considered
+	// a regression, e.g. 0.1 for 10%.
+	Threshold float64
+	// Thresholds override the threshold of specific metrics, by name.
+	Thresholds map[string]float64
+	// HigherIsBetter lists the metrics, by name, for which a decrease is a
+	// regression, e.g. throughput. For all other metrics, e.g. latencies, an
+	// increase is a regression.
+	HigherIsBetter map[string]bool
+}
+
+type compareKey struct {
+	group, source, name, measure string
+}
+
+// Compare aligns the metrics of two runs by group, source, name and measure,
+// and returns the sorted deltas between them. Events are ignored.
+func Compare(base, head []*Row, opts CompareOptions) []*Delta {
+	b, h := averages(base), averages(head)
+
+	keys := make([]compareKey, 0, len(b))
+	for k := range b {
+		keys = append(keys, k)
+	}
+	for k := range h {
+		if _, ok := b[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		ki, kj := keys[i], keys[j]
+		if ki.group != kj.group {
+			return ki.group < kj.group
+		}
+		if ki.source != kj.source {
+			return ki.source < kj.source
+		}
+		if ki.name != kj.name {
+			return ki.name < kj.name
+		}
+		return ki.measure < kj.measure
+	})
+
+	deltas := make([]*Delta, 0, len(keys))
+	for _, k := range keys {
+		d := &Delta{Group: k.group, Source: k.source, Name: k.name, Measure: k.measure, Base: math.NaN(), Head: math.NaN(), Change: math.NaN()}
+		bv, bok := b[k]
+		hv, hok := h[k]
+		if bok {
+			d.Base = bv
+		}
+		if hok {
+			d.Head = hv
+		}
+		if bok && hok {
+			d.Change = relativeChange(bv, hv)
+
+			threshold := opts.Threshold
+			if t, ok := opts.Thresholds[k.name]; ok {
+				threshold = t
+			}
+			change := d.Change
+			if opts.HigherIsBetter[k.name] {
+				change = -change
+			}
+			d.Regression = change > threshold
+		}
+		deltas = append(deltas, d)
+	}
+	return deltas
+}
+
+// Regressions returns the deltas that are regressions.
+func Regressions(deltas []*Delta) []*Delta {
+	var res []*Delta
+	for _, d := range deltas {
+		if d.Regression {
+			res = append(res, d)
+		}
+	}
+	return res
+}
+
+// averages returns the mean of every measure of every metric over its
+// samples, per group: the mean of all the points, and of the last snapshot of
+// every instance of cumulative metrics.
+func averages(rows []*Row) map[compareKey]float64 {
+	var (
+		sums   = make(map[compareKey]float64)
+		counts = make(map[compareKey]int)
+	)
+	for _, r := range Samples(rows) {
+		k := compareKey{r.Group, r.Source, r.Name, r.Measure}
+		sums[k] += r.Value
+		counts[k]++
+	}
+	for k, n := range counts {
+		sums[k] /= float64(n)
+	}
+	return sums
+}
+
+func relativeChange(base, head float64) float64 {
+	switch {
+	case base == head:
+		return 0
+	case base == 0:
+		return math.Copysign(math.Inf(1), head)
+	default:
+		return (head - base) / math.Abs(base)
+	}
+}
@@ -0,0 +1,82 @@
+package results
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompare(t *testing.T) {
+	metric := func(instance, name string, v float64) *Row {
+		return &Row{Group: "single", Instance: instance, Source: SourceResults, Type: "point", Name: name, Measure: "value", Value: v}
+	}
+
+	base := []*Row{
+		metric("0", "latency", 100),
+		metric("1", "latency", 200),
+		metric("0", "throughput", 50),
+		metric("0", "removed", 1),
+		{Group: "single", Instance: "0", Source: SourceEvents, Type: "message_event"},
+	}
+	head := []*Row{
+		metric("0", "latency", 160),
+		metric("1", "latency", 200),
+		metric("0", "throughput", 40),
+		metric("0", "added", 1),
+	}
+
+	deltas := Compare(base, head, CompareOptions{
+		Threshold:      0.1,
+		Thresholds:     map[string]float64{"throughput": 0.5},
+		HigherIsBetter: map[string]bool{"throughput": true},
+	})
+	require.Len(t, deltas, 4)
+
+	require.Equal(t, "added", deltas[0].Name)
+	require.True(t, math.IsNaN(deltas[0].Base))
+	require.False(t, deltas[0].Regression)
+
+	require.Equal(t, "latency", deltas[1].Name)
+	require.Equal(t, 150.0, deltas[1].Base)
+	require.Equal(t, 180.0, deltas[1].Head)
+	require.InDelta(t, 0.2, deltas[1].Change, 1e-9)
+	require.True(t, deltas[1].Regression)
+
+	require.Equal(t, "removed", deltas[2].Name)
+	require.True(t, math.IsNaN(deltas[2].Head))
+
+	// A 20% drop of throughput is within its own threshold.
+	require.Equal(t, "throughput", deltas[3].Name)
+	require.InDelta(t, -0.2, deltas[3].Change, 1e-9)
+	require.False(t, deltas[3].Regression)
+
+	require.Equal(t, []*Delta{deltas[1]}, Regressions(deltas))
+}
+
+func TestCompareSnapshots(t *testing.T) {
+	snapshot := func(run, instance string, ts int64, v float64) *Row {
+		return &Row{Run: run, Group: "single", Instance: instance, Source: SourceResults, Timestamp: ts, Type: "histogram", Name: "latency", Measure: "p95", Value: v}
+	}
+
+	// Only the last snapshot of every instance counts, however many
+	// snapshots instances recorded.
+	base := []*Row{
+		snapshot("run1", "0", 1, 1000),
+		snapshot("run1", "1", 1, 50),
+		snapshot("run1", "0", 2, 100),
+		snapshot("run1", "0", 3, 200),
+	}
+	head := []*Row{
+		snapshot("run2", "0", 1, 10),
+		snapshot("run2", "0", 2, 20),
+		snapshot("run3", "0", 1, 60),
+		snapshot("run2", "1", 1, 100),
+	}
+
+	deltas := Compare(base, head, CompareOptions{Threshold: 0.1})
+	require.Len(t, deltas, 1)
+	require.Equal(t, 125.0, deltas[0].Base)
+	require.Equal(t, 60.0, deltas[0].Head)
+	require.False(t, deltas[0].Regression)
+}
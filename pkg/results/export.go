@@ -0,0 +1,128 @@
+package results
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	client "github.com/influxdata/influxdb1-client/v2"
+)
+
+const (
+	FormatCSV     = "csv"
+	FormatJSON    = "json"
+	FormatParquet = "parquet"
+)
+
+// Formats are the formats rows can be written in.
+var Formats = []string{FormatCSV, FormatJSON, FormatParquet}
+
+// Write writes the rows in the given format: CSV with a header line, JSON
+// with one object per line, or a parquet file with the columns of Columns.
+func Write(w io.Writer, format string, rows []*Row) error {
+	switch format {
+	case FormatCSV:
+		return writeCSV(w, rows)
+	case FormatJSON:
+		return writeJSON(w, rows)
+	case FormatParquet:
+		return writeParquet(w, rows)
+	default:
+		return fmt.Errorf("unsupported format %q; supported: %s", format, strings.Join(Formats, ", "))
+	}
+}
+
+func writeCSV(w io.Writer, rows []*Row) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(Columns); err != nil {
+		return err
+	}
+	for _, r := range rows {
+		record := []string{
+			r.Run,
+			r.Group,
+			r.Instance,
+			r.Source,
+			strconv.FormatInt(r.Timestamp, 10),
+			r.Type,
+			r.Name,
+			r.Measure,
+			strconv.FormatFloat(r.Value, 'f', -1, 64),
+			r.Message,
+		}
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func writeJSON(w io.Writer, rows []*Row) error {
+	enc := json.NewEncoder(w)
+	for _, r := range rows {
+		if err := enc.Encode(r); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// PushInfluxDB writes the metrics among the rows to an InfluxDB database,
+// one point per metric snapshot, with the measures as fields. Events are not
+// pushed.
+func PushInfluxDB(addr string, database string, rows []*Row) (int, error) {
+	cl, err := client.NewHTTPClient(client.HTTPConfig{Addr: addr})
+	if err != nil {
+		return 0, err
+	}
+	defer cl.Close()
+
+	bp, err := client.NewBatchPoints(client.BatchPointsConfig{Database: database, Precision: "ns"})
+	if err != nil {
+		return 0, err
+	}
+
+	type key struct {
+		source, run, group, instance, name string
+		ts                                 int64
+	}
+
+	var (
+		order  []key
+		fields = make(map[key]map[string]interface{})
+	)
+	for _, r := range rows {
+		if r.Source == SourceEvents {
+			continue
+		}
+		k := key{r.Source, r.Run, r.Group, r.Instance, r.Name, r.Timestamp}
+		if _, ok := fields[k]; !ok {
+			order = append(order, k)
+			fields[k] = make(map[string]interface{})
+		}
+		fields[k][r.Measure] = r.Value
+	}
+
+	for _, k := range order {
+		tags := map[string]string{
+			"run":      k.run,
+			"group_id": k.group,
+			"instance": k.instance,
+		}
+		p, err := client.NewPoint(k.source+"."+k.name, tags, fields[k], time.Unix(0, k.ts))
+		if err != nil {
+			return 0, err
+		}
+		bp.AddPoint(p)
+	}
+
+	if err := cl.Write(bp); err != nil {
+		return 0, fmt.Errorf("failed to write to influxdb: %w", err)
+	}
+	return len(order), nil
+}
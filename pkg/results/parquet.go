@@ -0,0 +1,273 @@
+package results
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"math"
+)
+
+// The parquet writer below writes the rows as a single row group, with one
+// uncompressed, PLAIN-encoded data page per column. All the columns are
+// required, so pages carry no repetition nor definition levels. The metadata
+// is encoded with the thrift compact protocol, as the format mandates; see
+// https://github.com/apache/parquet-format.
+
+const parquetMagic = "PAR1"
+
+// Physical, converted and other enum values of the parquet format.
+const (
+	parquetInt64     = 2
+	parquetDouble    = 5
+	parquetByteArray = 6
+
+	parquetUTF8 = 0
+
+	parquetRequired     = 0
+	parquetPlain        = 0
+	parquetRLE          = 3
+	parquetUncompressed = 0
+	parquetDataPage     = 0
+)
+
+// parquetColumn is a column of the dataset; exactly one of str, i64 and f64
+// is set.
+type parquetColumn struct {
+	name string
+	str  func(*Row) string
+	i64  func(*Row) int64
+	f64  func(*Row) float64
+}
+
+// parquetColumns are the columns in the order of Columns.
+var parquetColumns = []parquetColumn{
+	{name: "run", str: func(r *Row) string { return r.Run }},
+	{name: "group", str: func(r *Row) string { return r.Group }},
+	{name: "instance", str: func(r *Row) string { return r.Instance }},
+	{name: "source", str: func(r *Row) string { return r.Source }},
+	{name: "ts", i64: func(r *Row) int64 { return r.Timestamp }},
+	{name: "type", str: func(r *Row) string { return r.Type }},
+	{name: "name", str: func(r *Row) string { return r.Name }},
+	{name: "measure", str: func(r *Row) string { return r.Measure }},
+	{name: "value", f64: func(r *Row) float64 { return r.Value }},
+	{name: "message", str: func(r *Row) string { return r.Message }},
+}
+
+func (c *parquetColumn) physicalType() int32 {
+	switch {
+	case c.i64 != nil:
+		return parquetInt64
+	case c.f64 != nil:
+		return parquetDouble
+	default:
+		return parquetByteArray
+	}
+}
+
+// encode returns the PLAIN encoding of the values of the column.
+func (c *parquetColumn) encode(rows []*Row) []byte {
+	var (
+		buf bytes.Buffer
+		b   [8]byte
+	)
+	for _, r := range rows {
+		switch {
+		case c.i64 != nil:
+			binary.LittleEndian.PutUint64(b[:], uint64(c.i64(r)))
+			buf.Write(b[:8])
+		case c.f64 != nil:
+			binary.LittleEndian.PutUint64(b[:], math.Float64bits(c.f64(r)))
+			buf.Write(b[:8])
+		default:
+			s := c.str(r)
+			binary.LittleEndian.PutUint32(b[:], uint32(len(s)))
+			buf.Write(b[:4])
+			buf.WriteString(s)
+		}
+	}
+	return buf.Bytes()
+}
+
+func writeParquet(w io.Writer, rows []*Row) error {
+	type chunk struct {
+		offset, size int64
+	}
+
+	var (
+		file   bytes.Buffer
+		chunks []chunk
+		total  int64
+	)
+	file.WriteString(parquetMagic)
+
+	for i := range parquetColumns {
+		data := parquetColumns[i].encode(rows)
+
+		var header thriftWriter
+		header.i32(1, parquetDataPage)
+		header.i32(2, int32(len(data)))
+		header.i32(3, int32(len(data)))
+		header.structField(5)
+		header.i32(1, int32(len(rows)))
+		header.i32(2, parquetPlain)
+		header.i32(3, parquetRLE)
+		header.i32(4, parquetRLE)
+		header.endStruct()
+		header.stop()
+
+		c := chunk{offset: int64(file.Len()), size: int64(header.buf.Len() + len(data))}
+		file.Write(header.buf.Bytes())
+		file.Write(data)
+		chunks = append(chunks, c)
+		total += c.size
+	}
+
+	var meta thriftWriter
+	meta.i32(1, 1)
+
+	meta.listBegin(2, thriftStruct, 1+len(parquetColumns))
+	meta.beginStruct()
+	meta.string(4, "schema")
+	meta.i32(5, int32(len(parquetColumns)))
+	meta.endStruct()
+	for i := range parquetColumns {
+		col := &parquetColumns[i]
+		meta.beginStruct()
+		meta.i32(1, col.physicalType())
+		meta.i32(3, parquetRequired)
+		meta.string(4, col.name)
+		if col.str != nil {
+			meta.i32(6, parquetUTF8)
+		}
+		meta.endStruct()
+	}
+
+	meta.i64(3, int64(len(rows)))
+
+	meta.listBegin(4, thriftStruct, 1)
+	meta.beginStruct()
+	meta.listBegin(1, thriftStruct, len(chunks))
+	for i, c := range chunks {
+		col := &parquetColumns[i]
+		meta.beginStruct()
+		meta.i64(2, c.offset)
+		meta.structField(3)
+		meta.i32(1, col.physicalType())
+		meta.listBegin(2, thriftI32, 1)
+		meta.zigzag(parquetPlain)
+		meta.listBegin(3, thriftBinary, 1)
+		meta.binary(col.name)
+		meta.i32(4, parquetUncompressed)
+		meta.i64(5, int64(len(rows)))
+		meta.i64(6, c.size)
+		meta.i64(7, c.size)
+		meta.i64(9, c.offset)
+		meta.endStruct()
+		meta.endStruct()
+	}
+	meta.i64(2, total)
+	meta.i64(3, int64(len(rows)))
+	meta.endStruct()
+
+	meta.string(6, "testground")
+	meta.stop()
+
+	file.Write(meta.buf.Bytes())
+	var n [4]byte
+	binary.LittleEndian.PutUint32(n[:], uint32(meta.buf.Len()))
+	file.Write(n[:])
+	file.WriteString(parquetMagic)
+
+	_, err := w.Write(file.Bytes())
+	return err
+}
+
+// Types of the thrift compact protocol.
+const (
+	thriftI32    = 5
+	thriftI64    = 6
+	thriftBinary = 8
+	thriftList   = 9
+	thriftStruct = 12
+)
+
+// thriftWriter encodes a struct with the thrift compact protocol. Field ids
+// are encoded as deltas from the previous field of the same struct, so nested
+// structs, and the structs in lists, begin with beginStruct and end with
+// endStruct. The outermost struct ends with stop.
+type thriftWriter struct {
+	buf  bytes.Buffer
+	last int16
+	// outer are the last field ids of the enclosing structs.
+	outer []int16
+}
+
+func (t *thriftWriter) varint(v uint64) {
+	var b [binary.MaxVarintLen64]byte
+	t.buf.Write(b[:binary.PutUvarint(b[:], v)])
+}
+
+func (t *thriftWriter) zigzag(v int64) {
+	t.varint(uint64((v << 1) ^ (v >> 63)))
+}
+
+func (t *thriftWriter) field(id int16, typ byte) {
+	if d := id - t.last; d > 0 && d <= 15 {
+		t.buf.WriteByte(byte(d)<<4 | typ)
+	} else {
+		t.buf.WriteByte(typ)
+		t.zigzag(int64(id))
+	}
+	t.last = id
+}
+
+func (t *thriftWriter) i32(id int16, v int32) {
+	t.field(id, thriftI32)
+	t.zigzag(int64(v))
+}
+
+func (t *thriftWriter) i64(id int16, v int64) {
+	t.field(id, thriftI64)
+	t.zigzag(v)
+}
+
+func (t *thriftWriter) binary(s string) {
+	t.varint(uint64(len(s)))
+	t.buf.WriteString(s)
+}
+
+func (t *thriftWriter) string(id int16, s string) {
+	t.field(id, thriftBinary)
+	t.binary(s)
+}
+
+// listBegin begins a list field of n elements of the given type, which must
+// follow.
+func (t *thriftWriter) listBegin(id int16, typ byte, n int) {
+	t.field(id, thriftList)
+	if n < 15 {
+		t.buf.WriteByte(byte(n)<<4 | typ)
+	} else {
+		t.buf.WriteByte(0xf0 | typ)
+		t.varint(uint64(n))
+	}
+}
+
+func (t *thriftWriter) structField(id int16) {
+	t.field(id, thriftStruct)
+	t.beginStruct()
+}
+
+func (t *thriftWriter) beginStruct() {
+	t.outer = append(t.outer, t.last)
+	t.last = 0
+}
+
+func (t *thriftWriter) endStruct() {
+	t.stop()
+	t.last, t.outer = t.outer[len(t.outer)-1], t.outer[:len(t.outer)-1]
+}
+
+func (t *thriftWriter) stop() {
+	t.buf.WriteByte(0)
+}
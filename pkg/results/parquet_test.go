@@ -0,0 +1,140 @@
+package results
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// thriftReader decodes structs encoded with the thrift compact protocol into
+// maps of field ids to values, to check the metadata of parquet files.
+type thriftReader struct {
+	t   *testing.T
+	buf *bytes.Reader
+}
+
+func (r *thriftReader) varint() uint64 {
+	v, err := binary.ReadUvarint(r.buf)
+	require.NoError(r.t, err)
+	return v
+}
+
+func (r *thriftReader) zigzag() int64 {
+	v := r.varint()
+	return int64(v>>1) ^ -int64(v&1)
+}
+
+func (r *thriftReader) byte() byte {
+	b, err := r.buf.ReadByte()
+	require.NoError(r.t, err)
+	return b
+}
+
+func (r *thriftReader) value(typ byte) interface{} {
+	switch typ {
+	case thriftI32, thriftI64:
+		return r.zigzag()
+	case thriftBinary:
+		b := make([]byte, r.varint())
+		_, err := r.buf.Read(b)
+		require.NoError(r.t, err)
+		return string(b)
+	case thriftList:
+		h := r.byte()
+		n := int(h >> 4)
+		if n == 15 {
+			n = int(r.varint())
+		}
+		l := make([]interface{}, n)
+		for i := range l {
+			l[i] = r.value(h & 0x0f)
+		}
+		return l
+	case thriftStruct:
+		return r.structure()
+	default:
+		r.t.Fatalf("unexpected thrift type %d", typ)
+		return nil
+	}
+}
+
+func (r *thriftReader) structure() map[int16]interface{} {
+	var (
+		fields = make(map[int16]interface{})
+		last   int16
+	)
+	for {
+		h := r.byte()
+		if h == 0 {
+			return fields
+		}
+		id := last + int16(h>>4)
+		if h>>4 == 0 {
+			id = int16(r.zigzag())
+		}
+		fields[id] = r.value(h & 0x0f)
+		last = id
+	}
+}
+
+func TestWriteParquet(t *testing.T) {
+	rows := []*Row{
+		{Run: "run1", Group: "single", Instance: "0", Source: SourceResults, Timestamp: 2, Type: "point", Name: "rtt", Measure: "value", Value: 1.5},
+		{Run: "run1", Group: "single", Instance: "1", Source: SourceEvents, Timestamp: 3, Type: "message_event", Message: "hello"},
+	}
+
+	var buf bytes.Buffer
+	require.NoError(t, Write(&buf, FormatParquet, rows))
+
+	file := buf.Bytes()
+	require.Equal(t, parquetMagic, string(file[:4]))
+	require.Equal(t, parquetMagic, string(file[len(file)-4:]))
+
+	n := int(binary.LittleEndian.Uint32(file[len(file)-8:]))
+	footer := &thriftReader{t, bytes.NewReader(file[len(file)-8-n : len(file)-8])}
+	meta := footer.structure()
+	require.Zero(t, footer.buf.Len())
+	require.EqualValues(t, 2, meta[3])
+
+	schema := meta[2].([]interface{})
+	require.Len(t, schema, 1+len(Columns))
+	require.EqualValues(t, len(Columns), schema[0].(map[int16]interface{})[5])
+	for i, c := range Columns {
+		require.Equal(t, c, schema[1+i].(map[int16]interface{})[4])
+	}
+
+	groups := meta[4].([]interface{})
+	require.Len(t, groups, 1)
+	chunks := groups[0].(map[int16]interface{})[1].([]interface{})
+	require.Len(t, chunks, len(Columns))
+
+	// Read the values of a column from its data page.
+	column := func(name string) []byte {
+		for i, c := range Columns {
+			if c != name {
+				continue
+			}
+			md := chunks[i].(map[int16]interface{})[3].(map[int16]interface{})
+			require.Equal(t, []interface{}{name}, md[3])
+			require.EqualValues(t, 2, md[5])
+
+			page := &thriftReader{t, bytes.NewReader(file[md[9].(int64):])}
+			header := page.structure()
+			size := int(header[3].(int64))
+			require.EqualValues(t, 2, header[5].(map[int16]interface{})[1])
+
+			start := int(md[9].(int64)) + int(md[7].(int64)) - size
+			return file[start : start+size]
+		}
+		t.Fatalf("no column %s", name)
+		return nil
+	}
+
+	require.Equal(t, 1.5, math.Float64frombits(binary.LittleEndian.Uint64(column("value"))))
+	require.EqualValues(t, 3, binary.LittleEndian.Uint64(column("ts")[8:]))
+	require.Equal(t, "\x00\x00\x00\x00\x05\x00\x00\x00hello", string(column("message")))
+	require.Equal(t, "\x01\x00\x00\x000\x01\x00\x00\x001", string(column("instance")))
+}
@@ -0,0 +1,138 @@
+package results
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/golang/snappy"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// remoteWriteTimeout bounds a push to a Prometheus remote-write endpoint.
+const remoteWriteTimeout = time.Minute
+
+// remoteWriteSeries is a time series of the Prometheus remote-write protocol.
+type remoteWriteSeries struct {
+	labels  [][2]string
+	samples []remoteWriteSample
+}
+
+type remoteWriteSample struct {
+	value float64
+	// ts is in milliseconds since the epoch.
+	ts int64
+}
+
+// PushRemoteWrite writes the metrics among the rows to a Prometheus
+// remote-write endpoint, one series per measure of a metric of an instance,
+// named <source>_<metric>_<measure>, with one sample per snapshot. Events are
+// not pushed. It returns the number of samples pushed.
+func PushRemoteWrite(url string, rows []*Row) (int, error) {
+	type key struct {
+		name, run, group, instance string
+	}
+
+	var (
+		order   []key
+		series  = make(map[key]*remoteWriteSeries)
+		samples int
+	)
+	for _, r := range rows {
+		if r.Source == SourceEvents {
+			continue
+		}
+		k := key{metricName(r.Source, r.Name, r.Measure), r.Run, r.Group, r.Instance}
+		s, ok := series[k]
+		if !ok {
+			// Labels are sorted by name, as the protocol requires.
+			s = &remoteWriteSeries{labels: [][2]string{
+				{"__name__", k.name},
+				{"group_id", k.group},
+				{"instance", k.instance},
+				{"run", k.run},
+			}}
+			series[k] = s
+			order = append(order, k)
+		}
+		s.samples = append(s.samples, remoteWriteSample{value: r.Value, ts: r.Timestamp / int64(time.Millisecond)})
+		samples++
+	}
+	if samples == 0 {
+		return 0, nil
+	}
+
+	var req []byte
+	for _, k := range order {
+		s := series[k]
+		sort.SliceStable(s.samples, func(i, j int) bool { return s.samples[i].ts < s.samples[j].ts })
+		req = protowire.AppendTag(req, 1, protowire.BytesType)
+		req = protowire.AppendBytes(req, s.marshal())
+	}
+
+	hreq, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(snappy.Encode(nil, req)))
+	if err != nil {
+		return 0, err
+	}
+	hreq.Header.Set("Content-Encoding", "snappy")
+	hreq.Header.Set("Content-Type", "application/x-protobuf")
+	hreq.Header.Set("X-Prometheus-Remote-Write-Version", "0.1.0")
+
+	resp, err := (&http.Client{Timeout: remoteWriteTimeout}).Do(hreq)
+	if err != nil {
+		return 0, fmt.Errorf("failed to write to prometheus: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		msg, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+		return 0, fmt.Errorf("failed to write to prometheus: %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+	return samples, nil
+}
+
+// marshal encodes the series as a prometheus.TimeSeries message.
+func (s *remoteWriteSeries) marshal() []byte {
+	var b []byte
+	for _, l := range s.labels {
+		var label []byte
+		label = protowire.AppendTag(label, 1, protowire.BytesType)
+		label = protowire.AppendString(label, l[0])
+		label = protowire.AppendTag(label, 2, protowire.BytesType)
+		label = protowire.AppendString(label, l[1])
+
+		b = protowire.AppendTag(b, 1, protowire.BytesType)
+		b = protowire.AppendBytes(b, label)
+	}
+	for _, smp := range s.samples {
+		var sample []byte
+		sample = protowire.AppendTag(sample, 1, protowire.Fixed64Type)
+		sample = protowire.AppendFixed64(sample, math.Float64bits(smp.value))
+		sample = protowire.AppendTag(sample, 2, protowire.VarintType)
+		sample = protowire.AppendVarint(sample, uint64(smp.ts))
+
+		b = protowire.AppendTag(b, 2, protowire.BytesType)
+		b = protowire.AppendBytes(b, sample)
+	}
+	return b
+}
+
+// metricName returns a valid Prometheus metric name for a measure of a metric.
+func metricName(parts ...string) string {
+	name := []byte(strings.Join(parts, "_"))
+	for i, c := range name {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c == '_', c == ':':
+		case c >= '0' && c <= '9' && i > 0:
+		default:
+			name[i] = '_'
+		}
+	}
+	return string(name)
+}
@@ -0,0 +1,99 @@
+package results
+
+import (
+	"io/ioutil"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/golang/snappy"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// fields decodes the length-delimited and scalar fields of a protobuf message.
+func fields(t *testing.T, b []byte) map[protowire.Number][]interface{} {
+	res := make(map[protowire.Number][]interface{})
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		require.GreaterOrEqual(t, n, 0)
+		b = b[n:]
+
+		var v interface{}
+		switch typ {
+		case protowire.BytesType:
+			v, n = protowire.ConsumeBytes(b)
+		case protowire.Fixed64Type:
+			var u uint64
+			u, n = protowire.ConsumeFixed64(b)
+			v = math.Float64frombits(u)
+		case protowire.VarintType:
+			var u uint64
+			u, n = protowire.ConsumeVarint(b)
+			v = int64(u)
+		default:
+			t.Fatalf("unexpected wire type %d", typ)
+		}
+		require.GreaterOrEqual(t, n, 0)
+		b = b[n:]
+		res[num] = append(res[num], v)
+	}
+	return res
+}
+
+func TestPushRemoteWrite(t *testing.T) {
+	var body []byte
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "snappy", r.Header.Get("Content-Encoding"))
+		compressed, err := ioutil.ReadAll(r.Body)
+		require.NoError(t, err)
+		body, err = snappy.Decode(nil, compressed)
+		require.NoError(t, err)
+	}))
+	defer srv.Close()
+
+	rows := []*Row{
+		{Run: "run1", Group: "single", Instance: "0", Source: SourceResults, Timestamp: 3e6, Type: "histogram", Name: "rtt.ms", Measure: "p95", Value: 7},
+		{Run: "run1", Group: "single", Instance: "0", Source: SourceResults, Timestamp: 1e6, Type: "histogram", Name: "rtt.ms", Measure: "p95", Value: 5},
+		{Run: "run1", Group: "single", Instance: "1", Source: SourceDiagnostics, Timestamp: 2e6, Type: "counter", Name: "dials", Measure: "count", Value: 2},
+		{Run: "run1", Group: "single", Instance: "0", Source: SourceEvents, Timestamp: 1e6, Type: "message_event", Message: "hello"},
+	}
+
+	n, err := PushRemoteWrite(srv.URL, rows)
+	require.NoError(t, err)
+	require.Equal(t, 3, n)
+
+	series := fields(t, body)[1]
+	require.Len(t, series, 2)
+
+	ts := fields(t, series[0].([]byte))
+	var labels []string
+	for _, l := range ts[1] {
+		f := fields(t, l.([]byte))
+		labels = append(labels, string(f[1][0].([]byte))+"="+string(f[2][0].([]byte)))
+	}
+	require.Equal(t, []string{"__name__=results_rtt_ms_p95", "group_id=single", "instance=0", "run=run1"}, labels)
+
+	// Samples are in time order, with timestamps in milliseconds.
+	require.Len(t, ts[2], 2)
+	first := fields(t, ts[2][0].([]byte))
+	require.Equal(t, 5.0, first[1][0])
+	require.Equal(t, int64(1), first[2][0])
+
+	// Nothing to push.
+	n, err = PushRemoteWrite(srv.URL, rows[3:])
+	require.NoError(t, err)
+	require.Zero(t, n)
+}
+
+func TestPushRemoteWriteError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "out of order sample", http.StatusBadRequest)
+	}))
+	defer srv.Close()
+
+	_, err := PushRemoteWrite(srv.URL, []*Row{{Source: SourceResults, Name: "rtt", Measure: "value"}})
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "out of order sample")
+}
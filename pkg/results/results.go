@@ -0,0 +1,257 @@
+// Package results turns the outputs collected from a run into a tidy dataset:
+// one row per measure of a metric, or per event, recorded by an instance.
+package results
+
+import (
+	"archive/tar"
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const (
+	// SourceResults are the metrics recorded by the instances in results.out.
+	SourceResults = "results"
+	// SourceDiagnostics are the metrics recorded by the instances in
+	// diagnostics.out.
+	SourceDiagnostics = "diagnostics"
+	// SourceEvents are the events recorded by the instances in run.out.
+	SourceEvents = "events"
+)
+
+// sourceFiles maps the files of the outputs of an instance to their source.
+var sourceFiles = map[string]string{
+	"results.out":     SourceResults,
+	"diagnostics.out": SourceDiagnostics,
+	"run.out":         SourceEvents,
+}
+
+// Row is a measure of a metric, or an event, recorded by an instance.
+type Row struct {
+	Run      string `json:"run"`
+	Group    string `json:"group"`
+	Instance string `json:"instance"`
+	Source   string `json:"source"`
+	// Timestamp is in nanoseconds since the epoch.
+	Timestamp int64 `json:"ts"`
+	// Type is the type of the metric, e.g. "histogram", or of the event,
+	// e.g. "message_event".
+	Type string `json:"type"`
+	// Name and Measure are set for metrics, e.g. "latency" and "p95".
+	Name    string  `json:"name,omitempty"`
+	Measure string  `json:"measure,omitempty"`
+	Value   float64 `json:"value"`
+	// Message is set for events.
+	Message string `json:"message,omitempty"`
+}
+
+// Columns are the columns of the tabular form of the rows.
+var Columns = []string{"run", "group", "instance", "source", "ts", "type", "name", "measure", "value", "message"}
+
+// ReadArchive parses the outputs of a run, as a .tgz archive in the format of
+// `testground collect`, i.e. <run id>/<group id>/<instance>/<file>. Outputs of
+// a newer schema version than the current one are rejected.
+func ReadArchive(r io.Reader) ([]*Row, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open the outputs archive: %w", err)
+	}
+	defer gz.Close()
+
+	var rows []*Row
+
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read the outputs archive: %w", err)
+		}
+
+		// Refuse outputs of a schema this version doesn't know how to parse.
+		if isSchemaEntry(hdr.Name) {
+			if _, err := parseSchema(tr); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		parts := strings.Split(path.Clean(hdr.Name), "/")
+		if hdr.Typeflag != tar.TypeReg || len(parts) != 4 {
+			continue
+		}
+
+		source, ok := sourceFiles[parts[3]]
+		if !ok {
+			continue
+		}
+
+		parsed, err := parseFile(tr, source, parts[0], parts[1], parts[2])
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", hdr.Name, err)
+		}
+		rows = append(rows, parsed...)
+	}
+
+	sort.SliceStable(rows, func(i, j int) bool {
+		return rows[i].Timestamp < rows[j].Timestamp
+	})
+
+	return rows, nil
+}
+
+// ReadDir parses the outputs of a run kept in a directory, i.e.
+// <group id>/<instance>/<file>, as runners keep them before they're collected.
+func ReadDir(dir string, run string) ([]*Row, error) {
+	var rows []*Row
+
+	err := filepath.Walk(dir, func(p string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		source, ok := sourceFiles[fi.Name()]
+		if fi.IsDir() || !ok {
+			return nil
+		}
+
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		parts := strings.Split(filepath.ToSlash(rel), "/")
+		if len(parts) != 3 {
+			return nil
+		}
+
+		f, err := os.Open(p)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		parsed, err := parseFile(f, source, run, parts[0], parts[1])
+		if err != nil {
+			return fmt.Errorf("failed to parse %s: %w", p, err)
+		}
+		rows = append(rows, parsed...)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(rows, func(i, j int) bool {
+		return rows[i].Timestamp < rows[j].Timestamp
+	})
+
+	return rows, nil
+}
+
+// Samples returns the rows that sample the metrics of a run. Points are
+// samples of their own, so all of them are returned. Every other metric is
+// cumulative: each snapshot supersedes the previous ones of the same instance,
+// so only the last snapshot of every run and instance is returned. Events are
+// dropped. Rows must be in timestamp order, as returned by ReadArchive and
+// ReadDir.
+func Samples(rows []*Row) []*Row {
+	type key struct {
+		run, group, instance, source, name, measure string
+	}
+
+	last := make(map[key]int)
+	for i, r := range rows {
+		if r.Source != SourceEvents && r.Type != "point" {
+			last[key{r.Run, r.Group, r.Instance, r.Source, r.Name, r.Measure}] = i
+		}
+	}
+
+	var res []*Row
+	for i, r := range rows {
+		switch {
+		case r.Source == SourceEvents:
+		case r.Type == "point":
+			res = append(res, r)
+		case last[key{r.Run, r.Group, r.Instance, r.Source, r.Name, r.Measure}] == i:
+			res = append(res, r)
+		}
+	}
+	return res
+}
+
+// parseFile parses a file of the outputs of an instance. Lines that aren't
+// metrics or events, e.g. regular log messages, are skipped.
+func parseFile(r io.Reader, source, run, group, instance string) ([]*Row, error) {
+	var rows []*Row
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var line struct {
+			Ts       int64                             `json:"ts"`
+			Type     string                            `json:"type"`
+			Name     string                            `json:"name"`
+			Measures map[string]interface{}            `json:"measures"`
+			Event    map[string]map[string]interface{} `json:"event"`
+		}
+		if err := json.Unmarshal(scanner.Bytes(), &line); err != nil {
+			continue
+		}
+
+		base := Row{
+			Run:       run,
+			Group:     group,
+			Instance:  instance,
+			Source:    source,
+			Timestamp: line.Ts,
+		}
+
+		if source == SourceEvents {
+			for typ, fields := range line.Event {
+				row := base
+				row.Type = typ
+				row.Message = eventMessage(fields)
+				rows = append(rows, &row)
+			}
+			continue
+		}
+
+		measures := make([]string, 0, len(line.Measures))
+		for m := range line.Measures {
+			measures = append(measures, m)
+		}
+		sort.Strings(measures)
+
+		for _, m := range measures {
+			v, ok := line.Measures[m].(float64)
+			if !ok {
+				continue
+			}
+			row := base
+			row.Type = line.Type
+			row.Name = line.Name
+			row.Measure = m
+			row.Value = v
+			rows = append(rows, &row)
+		}
+	}
+
+	return rows, scanner.Err()
+}
+
+func eventMessage(fields map[string]interface{}) string {
+	for _, k := range []string{"message", "error", "name"} {
+		if s, ok := fields[k].(string); ok && s != "" {
+			return s
+		}
+	}
+	return ""
+}
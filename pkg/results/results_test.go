@@ -0,0 +1,65 @@
+package results
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func archive(t *testing.T, files map[string]string) *bytes.Buffer {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for name, content := range files {
+		require.NoError(t, tw.WriteHeader(&tar.Header{
+			Name:     name,
+			Mode:     0644,
+			Size:     int64(len(content)),
+			Typeflag: tar.TypeReg,
+		}))
+		_, err := tw.Write([]byte(content))
+		require.NoError(t, err)
+	}
+	require.NoError(t, tw.Close())
+	require.NoError(t, gz.Close())
+	return &buf
+}
+
+func TestReadArchive(t *testing.T) {
+	buf := archive(t, map[string]string{
+		"run1/single/0/results.out":     `{"ts":2,"type":"histogram","name":"latency","measures":{"p95":300,"count":4}}` + "\n",
+		"run1/single/0/run.out":         `{"ts":1,"msg":"","event":{"message_event":{"message":"hello"}}}` + "\n" + `{"ts":3,"msg":"plain log line"}` + "\n",
+		"run1/single/1/diagnostics.out": `{"ts":4,"type":"counter","name":"dials","measures":{"count":2}}` + "\n",
+		"run1/single/1/other.out":       "ignored\n",
+	})
+
+	rows, err := ReadArchive(buf)
+	require.NoError(t, err)
+	require.Len(t, rows, 4)
+
+	require.Equal(t, &Row{Run: "run1", Group: "single", Instance: "0", Source: SourceEvents, Timestamp: 1, Type: "message_event", Message: "hello"}, rows[0])
+	require.Equal(t, &Row{Run: "run1", Group: "single", Instance: "0", Source: SourceResults, Timestamp: 2, Type: "histogram", Name: "latency", Measure: "count", Value: 4}, rows[1])
+	require.Equal(t, "p95", rows[2].Measure)
+	require.Equal(t, SourceDiagnostics, rows[3].Source)
+	require.Equal(t, "1", rows[3].Instance)
+}
+
+func TestWrite(t *testing.T) {
+	rows := []*Row{
+		{Run: "run1", Group: "single", Instance: "0", Source: SourceResults, Timestamp: 2, Type: "point", Name: "rtt", Measure: "value", Value: 1.5},
+	}
+
+	var csv bytes.Buffer
+	require.NoError(t, Write(&csv, FormatCSV, rows))
+	require.Equal(t, strings.Join(Columns, ",")+"\nrun1,single,0,results,2,point,rtt,value,1.5,\n", csv.String())
+
+	var js bytes.Buffer
+	require.NoError(t, Write(&js, FormatJSON, rows))
+	require.JSONEq(t, `{"run":"run1","group":"single","instance":"0","source":"results","ts":2,"type":"point","name":"rtt","measure":"value","value":1.5}`, js.String())
+
+	require.Error(t, Write(&js, "xml", rows))
+}
@@ -0,0 +1,154 @@
+package results
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/testground/testground/pkg/api"
+)
+
+// outputsMigrations[v] migrates the path of an entry of an outputs archive
+// from version v of the schema to version v+1. Entries mapped to an empty
+// path are dropped.
+var outputsMigrations = []func(name string) string{
+	// Version 0 outputs predate schema versioning; their layout is that of
+	// version 1.
+	0: func(name string) string { return name },
+}
+
+// parseSchema decodes the outputs schema stamp of a run, and rejects the
+// versions this version of testground doesn't support.
+func parseSchema(r io.Reader) (*api.OutputsSchema, error) {
+	var schema api.OutputsSchema
+	if err := json.NewDecoder(r).Decode(&schema); err != nil {
+		return nil, fmt.Errorf("malformed %s: %w", api.OutputsSchemaFile, err)
+	}
+	if schema.Version > api.OutputsSchemaVersion || schema.Version < 0 {
+		return nil, fmt.Errorf("unsupported outputs schema version %d; this version of testground supports up to %d", schema.Version, api.OutputsSchemaVersion)
+	}
+	return &schema, nil
+}
+
+// isSchemaEntry returns true if the entry of an outputs archive is the schema
+// stamp of the run, i.e. <run id>/schema.json.
+func isSchemaEntry(name string) bool {
+	parts := strings.Split(path.Clean(name), "/")
+	return len(parts) == 2 && parts[1] == api.OutputsSchemaFile
+}
+
+// ReadSchema returns the schema of the outputs of a run, as a .tgz archive.
+// Outputs that aren't stamped have version 0.
+func ReadSchema(r io.Reader) (*api.OutputsSchema, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open the outputs archive: %w", err)
+	}
+	defer gz.Close()
+
+	schema := &api.OutputsSchema{}
+
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return schema, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read the outputs archive: %w", err)
+		}
+
+		if schema.RunID == "" {
+			schema.RunID = strings.Split(path.Clean(hdr.Name), "/")[0]
+		}
+
+		if isSchemaEntry(hdr.Name) {
+			return parseSchema(tr)
+		}
+	}
+}
+
+// MigrateArchive rewrites the outputs of a run, as a .tgz archive, to the
+// current version of the schema, and stamps them with it. It returns the
+// schema the outputs were migrated from.
+func MigrateArchive(in io.ReadSeeker, out io.Writer) (*api.OutputsSchema, error) {
+	// The stamp of a run can be anywhere in the archive, so the archive is
+	// read twice: once to find the stamp, and once to rewrite it.
+	from, err := ReadSchema(in)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := in.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	gzr, err := gzip.NewReader(in)
+	if err != nil {
+		return nil, err
+	}
+	defer gzr.Close()
+
+	gzw := gzip.NewWriter(out)
+	tw := tar.NewWriter(gzw)
+
+	tr := tar.NewReader(gzr)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read the outputs archive: %w", err)
+		}
+
+		if isSchemaEntry(hdr.Name) {
+			continue
+		}
+
+		name := hdr.Name
+		for v := from.Version; v < api.OutputsSchemaVersion && name != ""; v++ {
+			name = outputsMigrations[v](name)
+		}
+		if name == "" {
+			continue
+		}
+
+		hdr.Name = name
+		if err := tw.WriteHeader(hdr); err != nil {
+			return nil, err
+		}
+		if _, err := io.Copy(tw, tr); err != nil {
+			return nil, err
+		}
+	}
+
+	to := *from
+	to.Version = api.OutputsSchemaVersion
+
+	stamp, err := json.MarshalIndent(&to, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err := tw.WriteHeader(&tar.Header{
+		Name:     to.RunID + "/" + api.OutputsSchemaFile,
+		Mode:     0644,
+		Size:     int64(len(stamp)),
+		ModTime:  time.Now(),
+		Typeflag: tar.TypeReg,
+	}); err != nil {
+		return nil, err
+	}
+	if _, err := tw.Write(stamp); err != nil {
+		return nil, err
+	}
+
+	if err := tw.Close(); err != nil {
+		return nil, err
+	}
+	return from, gzw.Close()
+}
@@ -0,0 +1,44 @@
+package results
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/testground/testground/pkg/api"
+)
+
+func TestMigrateArchive(t *testing.T) {
+	buf := archive(t, map[string]string{
+		"run1/single/0/results.out": `{"ts":2,"type":"point","name":"rtt","measures":{"value":1.5}}` + "\n",
+	})
+
+	schema, err := ReadSchema(bytes.NewReader(buf.Bytes()))
+	require.NoError(t, err)
+	require.Equal(t, &api.OutputsSchema{RunID: "run1"}, schema)
+
+	var out bytes.Buffer
+	from, err := MigrateArchive(bytes.NewReader(buf.Bytes()), &out)
+	require.NoError(t, err)
+	require.Equal(t, 0, from.Version)
+
+	schema, err = ReadSchema(bytes.NewReader(out.Bytes()))
+	require.NoError(t, err)
+	require.Equal(t, api.OutputsSchemaVersion, schema.Version)
+	require.Equal(t, "run1", schema.RunID)
+
+	rows, err := ReadArchive(&out)
+	require.NoError(t, err)
+	require.Len(t, rows, 1)
+}
+
+func TestReadArchiveRejectsNewerSchema(t *testing.T) {
+	buf := archive(t, map[string]string{
+		"run1/schema.json":          `{"version":99,"run_id":"run1"}`,
+		"run1/single/0/results.out": "",
+	})
+
+	_, err := ReadArchive(buf)
+	require.Error(t, err)
+}
@@ -10,6 +10,11 @@ const (
 	ChunkTypeError    ChunkType = 'e'
 )
 
+// StreamProtocol is the protocol requests upgrade their connection to, to
+// stream input to the daemon while it responds, e.g. to commands run in
+// instances. Both ends then write chunks.
+const StreamProtocol = "testground-stream"
+
 // Chunk is a response chunk sent from the Testground daemon to the Testground
 // client. For a given request, clients should expect between 0 to `n`
 // `progress` chunks, and exactly 1 `result` or `error` chunk before EOF.
@@ -0,0 +1,49 @@
+package rpc
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+)
+
+// chunkWriter implements io.Writer, and decodes the stream of chunks written
+// to it, passing each one to a function. Writes don't need to be aligned to
+// chunks; incomplete chunks are kept until the rest is written.
+type chunkWriter struct {
+	fn  func(*Chunk) error
+	buf []byte
+}
+
+var _ io.Writer = (*chunkWriter)(nil)
+
+// NewChunkWriter returns an io.Writer that decodes the chunks an OutputWriter
+// writes to it, and calls fn for each of them, in order. Progress and binary
+// payloads are passed as the base64 strings they are encoded to.
+func NewChunkWriter(fn func(*Chunk) error) io.Writer {
+	return &chunkWriter{fn: fn}
+}
+
+func (cw *chunkWriter) Write(p []byte) (n int, err error) {
+	cw.buf = append(cw.buf, p...)
+
+	dec := json.NewDecoder(bytes.NewReader(cw.buf))
+	var offset int64
+	for {
+		var chunk Chunk
+		err := dec.Decode(&chunk)
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			break
+		}
+		if err != nil {
+			return 0, err
+		}
+		offset = dec.InputOffset()
+		if err := cw.fn(&chunk); err != nil {
+			return 0, err
+		}
+	}
+
+	cw.buf = append(cw.buf[:0], cw.buf[offset:]...)
+	return len(p), nil
+}
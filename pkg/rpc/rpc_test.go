@@ -124,3 +124,35 @@ func TestWriters(t *testing.T) {
 		testBody(t, &test, res.Body)
 	}
 }
+
+// test decoding chunks written in arbitrary pieces.
+func TestChunkWriter(t *testing.T) {
+	var chunks []*rpc.Chunk
+	w := rpc.NewChunkWriter(func(c *rpc.Chunk) error {
+		chunks = append(chunks, c)
+		return nil
+	})
+
+	stream := `{"t":112,"p":"dGVzdA=="}` + "\n" + `{"t":98,"p":"dGVzdA=="}{"t":114,"p":true}`
+	for i := 0; i < len(stream); i += 7 {
+		end := i + 7
+		if end > len(stream) {
+			end = len(stream)
+		}
+		if _, err := w.Write([]byte(stream[i:end])); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if len(chunks) != 3 {
+		t.Fatalf("expected 3 chunks, got %d", len(chunks))
+	}
+	for i, ct := range []rpc.ChunkType{rpc.ChunkTypeProgress, rpc.ChunkTypeBinary, rpc.ChunkTypeResult} {
+		if chunks[i].Type != ct {
+			t.Errorf("chunk %d: expected type %c, got %c", i, ct, chunks[i].Type)
+		}
+	}
+	if chunks[0].Payload != "dGVzdA==" {
+		t.Errorf("unexpected payload: %v", chunks[0].Payload)
+	}
+}
@@ -41,6 +41,22 @@ func NewStdoutWriter() *OutputWriter {
 	return ow
 }
 
+// NewBinaryOutputWriter returns an OutputWriter that writes binary output to w
+// as is, instead of framing it into chunks, and only logs everything else.
+func NewBinaryOutputWriter(w io.Writer) *OutputWriter {
+	pw := &progressWriter{out: ioutil.Discard}
+	bw := &binaryWriter{raw: w}
+	ow := &OutputWriter{
+		SugaredLogger: logging.S(),
+		out:           ioutil.Discard,
+		pw:            pw,
+		bw:            bw,
+	}
+	pw.ow = ow
+	bw.ow = ow
+	return ow
+}
+
 func NewFileOutputWriter(w io.Writer) *OutputWriter {
 	writer := ioutils.NewWriteFlusher(w)
 
@@ -182,12 +198,18 @@ func (ow *OutputWriter) StdoutWriter() io.Writer {
 }
 
 // binaryWriter implements io.Writer, and passes all writes to the OutputWriter.WriteBinary()
-// to marshal into chunk.Binary JSON messages.
-type binaryWriter struct{ ow *OutputWriter }
+// to marshal into chunk.Binary JSON messages, unless it has a raw writer.
+type binaryWriter struct {
+	ow  *OutputWriter
+	raw io.Writer
+}
 
 var _ io.Writer = (*binaryWriter)(nil)
 
 func (bw *binaryWriter) Write(p []byte) (n int, err error) {
+	if bw.raw != nil {
+		return bw.raw.Write(p)
+	}
 	return bw.ow.WriteBinary(p)
 }
 
@@ -14,6 +14,7 @@ import (
 	"os"
 	"path/filepath"
 	"reflect"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
@@ -28,11 +29,14 @@ import (
 	ss "github.com/testground/sdk-go/sync"
 	"github.com/testground/testground/pkg/api"
 	"github.com/testground/testground/pkg/aws"
+	"github.com/testground/testground/pkg/config"
 	"github.com/testground/testground/pkg/conv"
 	"github.com/testground/testground/pkg/healthcheck"
 	"github.com/testground/testground/pkg/logging"
+	"github.com/testground/testground/pkg/metrics"
 	"github.com/testground/testground/pkg/rpc"
 	"github.com/testground/testground/pkg/task"
+	"github.com/testground/testground/pkg/tracing"
 	"golang.org/x/sync/errgroup"
 
 	v1 "k8s.io/api/core/v1"
@@ -40,6 +44,7 @@ import (
 
 	lru "github.com/hashicorp/golang-lru"
 	"github.com/msoap/byline"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes/scheme"
@@ -51,6 +56,7 @@ var (
 	_             api.Runner        = (*ClusterK8sRunner)(nil)
 	_             api.Terminatable  = (*ClusterK8sRunner)(nil)
 	_             api.Healthchecker = (*ClusterK8sRunner)(nil)
+	_             api.RunReaper     = (*ClusterK8sRunner)(nil)
 	mu                              = sync.Mutex{}
 	errSyncClient                   = errors.New("failed to start sync client")
 )
@@ -124,6 +130,38 @@ type ClusterK8sRunnerConfig struct {
 	RunTimeoutMin int `toml:"run_timeout_min"`
 
 	Sysctls []string `toml:"sysctls"`
+
+	// Architecture is the CPU architecture of the plan nodes, e.g. "arm64".
+	// Testplan pods are pinned to nodes of this architecture, and runs whose
+	// images were built for another architecture are rejected (default: not
+	// set, i.e. the architecture of the plan nodes, if they all share one).
+	Architecture string `toml:"architecture"`
+
+	// NamespacePerRun runs the pods of every run in a namespace of their own,
+	// deleted with everything in it when the run ends, so that concurrent runs
+	// don't interfere (default: false, i.e. all runs share the namespace of
+	// the daemon).
+	NamespacePerRun bool `toml:"namespace_per_run"`
+
+	// ResourceQuotaTemplate, LimitRangeTemplate and NetworkPolicyTemplate are
+	// paths, relative to $TESTGROUND_HOME unless absolute, to text/template
+	// YAML manifests of the objects to create in the namespace of a run, with
+	// NamespacePerRun. Templates may hold several objects, and are rendered
+	// with the RunID, Namespace, InfraNamespace, TestPlan, TestCase and
+	// TotalInstances of the run.
+	ResourceQuotaTemplate string `toml:"resource_quota_template"`
+	LimitRangeTemplate    string `toml:"limit_range_template"`
+	NetworkPolicyTemplate string `toml:"network_policy_template"`
+
+	// Tolerations are the taints testplan pods tolerate, as
+	// KEY[=VALUE][:EFFECT], e.g. those of spot or preemptible nodes.
+	Tolerations []string `toml:"tolerations"`
+
+	// RescheduleEvicted reschedules the instances disrupted by their node,
+	// e.g. evicted by the preemption of a spot node, instead of failing them,
+	// up to MaxReschedules times per instance (default: 3).
+	RescheduleEvicted bool `toml:"reschedule_evicted"`
+	MaxReschedules    int  `toml:"max_reschedules"`
 }
 
 // ClusterK8sRunner is a runner that creates a Docker service to launch as
@@ -200,6 +238,14 @@ func (c *ClusterK8sRunner) Run(ctx context.Context, input *api.RunInput, ow *rpc
 
 	cfg := *input.RunnerConfig.(*ClusterK8sRunnerConfig)
 
+	if err := c.checkArchitecture(ctx, ow, input, cfg.Architecture); err != nil {
+		runerr = err
+		return
+	}
+	if cfg.Architecture != "" {
+		api.RecordDecision(ctx, api.DecisionStagePlacement, "instances restricted to %s plan nodes by the architecture setting", cfg.Architecture)
+	}
+
 	// if `provider` is set, we have to push to a docker registry
 	if cfg.Provider != "" {
 		err := c.pushImagesToDockerRegistry(ctx, ow, input)
@@ -245,7 +291,17 @@ func (c *ClusterK8sRunner) Run(ctx context.Context, input *api.RunInput, ow *rpc
 
 	template.TestSubnet = &ptypes.IPNet{IPNet: *subnet}
 
-	enoughResources, err := c.checkClusterResources(ow, input.Groups, defaultMemory, defaultCPU)
+	// Weave only assigns IPv4 addresses; the sidecar assigns the IPv6 ones,
+	// embedding the IPv4 address of the instance in this subnet.
+	var subnet6 *net.IPNet
+	if input.IPFamily.HasIPv6() {
+		if subnet6, _, err = dataNetworkV6(subnet); err != nil {
+			runerr = err
+			return
+		}
+	}
+
+	enoughResources, err := c.checkClusterResources(ctx, ow, input.Groups, defaultMemory, defaultCPU)
 	if err != nil {
 		runerr = fmt.Errorf("couldn't check cluster resources: %v", err)
 		return
@@ -254,16 +310,42 @@ func (c *ClusterK8sRunner) Run(ctx context.Context, input *api.RunInput, ow *rpc
 	if !enoughResources {
 		if cfg.AutoscalerEnabled {
 			ow.Warnw("too many test instances requested, will have to wait for cluster autoscaler to kick in")
+			api.RecordDecision(ctx, api.DecisionStageLimits, "not enough capacity on the cluster; waiting for the cluster autoscaler")
 		} else {
 			runerr = errors.New("too many test instances requested, resize cluster if you need more capacity")
 			return
 		}
 	}
 
+	if len(input.Fixtures) > 0 {
+		if input.EnvConfig.Daemon.Offline.Enabled {
+			runerr = fmt.Errorf("%w: the fixtures of runs on cluster:k8s are fetched from their sources", config.ErrOffline)
+			return
+		}
+		if err := c.fetchFixtures(ctx, input, ow); err != nil {
+			runerr = err
+			return
+		}
+	}
+
+	ns := c.config.Namespace
+	if cfg.NamespacePerRun {
+		if ns, err = c.createRunNamespace(ctx, ow, input, &cfg); err != nil {
+			runerr = err
+			return
+		}
+		api.RecordDecision(ctx, api.DecisionStagePlacement, "instances isolated in namespace %s", ns)
+		if !cfg.KeepService {
+			defer c.deleteRunNamespace(ow, input.RunID)
+		}
+	}
+
 	jobName := fmt.Sprintf("tg-%s", input.TestPlan)
 
 	ow.Infow("deploying testground testplan run on k8s", "job-name", jobName)
 
+	evictions := newK8sEvictions(c, ns, &cfg)
+
 	var eg errgroup.Group
 
 	eg.Go(func() error {
@@ -275,7 +357,7 @@ func (c *ClusterK8sRunner) Run(ctx context.Context, input *api.RunInput, ow *rpc
 			ow.Errorw("could not start collecting outcomes", "err", err)
 		}
 
-		err = c.watchRunPods(ctx, ow, input, result, &template)
+		err = c.watchRunPods(ctx, ow, input, ns, evictions, result, &template)
 		if err != nil {
 			return err
 		}
@@ -285,9 +367,16 @@ func (c *ClusterK8sRunner) Run(ctx context.Context, input *api.RunInput, ow *rpc
 		return nil
 	})
 
+	usage := newK8sUsage(ctx, c, input, ns, ow)
+	defer usage.cancel()
+
 	sem := make(chan struct{}, 30) // limit the number of concurrent k8s api calls
 
 	for _, g := range input.Groups {
+		if len(g.Publish) > 0 {
+			ow.Warnw("cluster:k8s does not publish ports; ignoring the published ports of the group", "group", g.ID)
+		}
+
 		runenv := template
 		runenv.TestGroupID = g.ID
 		runenv.TestGroupInstanceCount = g.Instances
@@ -299,9 +388,9 @@ func (c *ClusterK8sRunner) Run(ctx context.Context, input *api.RunInput, ow *rpc
 		}
 
 		env := conv.ToEnvVar(runenv.ToEnvVars())
-		env = append(env, v1.EnvVar{Name: "REDIS_HOST", Value: "testground-infra-redis"})
-		env = append(env, v1.EnvVar{Name: "SYNC_SERVICE_HOST", Value: "testground-sync-service"})
-		env = append(env, v1.EnvVar{Name: "INFLUXDB_URL", Value: "http://influxdb:8086"})
+		env = append(env, v1.EnvVar{Name: "REDIS_HOST", Value: c.infraHost(ns, "testground-infra-redis")})
+		env = append(env, v1.EnvVar{Name: "SYNC_SERVICE_HOST", Value: c.infraHost(ns, "testground-sync-service")})
+		env = append(env, v1.EnvVar{Name: "INFLUXDB_URL", Value: "http://" + c.infraHost(ns, "influxdb") + ":8086"})
 		// This subnet should correspond to the secondary CNI's IP range (usually Weave)
 		env = append(env, v1.EnvVar{Name: "TEST_SUBNET", Value: "10.32.0.0/12"})
 
@@ -318,6 +407,15 @@ func (c *ClusterK8sRunner) Run(ctx context.Context, input *api.RunInput, ow *rpc
 			env = append(env, v1.EnvVar{Name: name, Value: value})
 		}
 
+		// Tell the instances and the sidecar the IP family of the data network.
+		env = append(env, conv.ToEnvVar(ipFamilyEnv(input.IPFamily, subnet6))...)
+		env = append(env, conv.ToEnvVar(runtimeAPIEnv(input))...)
+		env = append(env, conv.ToEnvVar(g.Env)...)
+
+		for _, f := range input.Fixtures {
+			env = append(env, v1.EnvVar{Name: fixtureEnvName(f), Value: f.MountPath()})
+		}
+
 		podCPU := defaultCPU
 		if g.Resources.CPU != "" {
 			var err error
@@ -351,8 +449,12 @@ func (c *ClusterK8sRunner) Run(ctx context.Context, input *api.RunInput, ow *rpc
 				}
 				client := c.pool.Acquire()
 				defer c.pool.Release(client)
+				// The run context is done when the run is canceled; the pods
+				// must be deleted nonetheless.
+				ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+				defer cancel()
 				ow.Debugw("deleting pod", "pod", podName)
-				err = client.CoreV1().Pods(c.config.Namespace).Delete(ctx, podName, metav1.DeleteOptions{})
+				err = client.CoreV1().Pods(ns).Delete(ctx, podName, metav1.DeleteOptions{})
 				if err != nil {
 					ow.Errorw("couldn't remove pod", "pod", podName, "err", err)
 				}
@@ -369,11 +471,22 @@ func (c *ClusterK8sRunner) Run(ctx context.Context, input *api.RunInput, ow *rpc
 					Value: fmt.Sprintf("/outputs/%s/%s/%d", input.RunID, g.ID, i),
 				})
 
-				return c.createTestplanPod(ctx, podName, input, runenv, currentEnv, g, i, podMemory, podCPU)
+				create := func(ctx context.Context, env []v1.EnvVar) error {
+					return c.createTestplanPod(ctx, ns, podName, input, runenv, env, g, i, podMemory, podCPU)
+				}
+				evictions.register(podName, currentEnv, create)
+				return create(ctx, currentEnv)
 			})
 		}
 	}
 
+	// Account for the usage of the pods before they're deleted.
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		result.Usage = usage.collect(ctx)
+	}()
+
 	// we want to fetch logs even in an event of error
 	defer func() {
 		if input.TotalInstances <= 200 {
@@ -391,7 +504,7 @@ func (c *ClusterK8sRunner) Run(ctx context.Context, input *api.RunInput, ow *rpc
 						podName := fmt.Sprintf("%s-%s-%s-%d", jobName, input.RunID, g.ID, i)
 
 						ow.Debugw("fetching logs", "pod", podName)
-						logs, err := c.getPodLogs(ow, podName)
+						logs, err := c.getPodLogs(ow, ns, podName)
 						if err != nil {
 							return err
 						}
@@ -412,6 +525,25 @@ func (c *ClusterK8sRunner) Run(ctx context.Context, input *api.RunInput, ow *rpc
 		}
 	}()
 
+	// Keep the pods of a failed run for inspection, before they're deleted.
+	if input.PauseOnFailure > 0 {
+		defer func() {
+			if failedRun(ctx, result, runerr) {
+				c.pauseOnFailure(ctx, input, ow)
+			}
+		}()
+	}
+
+	// Capture the diagnostics of the instances of a failed run, before it's
+	// paused or torn down.
+	if input.Diagnostics != nil {
+		defer func() {
+			if diagnosedRun(ctx, result, runerr) {
+				c.diagnoseRun(input, ow)
+			}
+		}()
+	}
+
 	err = eg.Wait()
 	if err != nil {
 		runerr = err
@@ -475,6 +607,16 @@ func (c *ClusterK8sRunner) Healthcheck(ctx context.Context, engine api.Engine, o
 		healthcheck.NotImplemented(),
 	)
 
+	var arch string
+	if rcfg, ok := engine.EnvConfig().Runners[c.ID()]; ok {
+		arch, _ = rcfg["architecture"].(string)
+	}
+
+	hh.Enlist("plan nodes architecture",
+		healthcheck.CheckK8sNodesArchitecture(planNodes, arch),
+		healthcheck.RequiresManualFixing(),
+	)
+
 	hh.Enlist("sidecar pods",
 		healthcheck.CheckK8sPods(ctx, client, "name=testground-sidecar", c.config.Namespace, len(planNodes)),
 		healthcheck.NotImplemented(),
@@ -658,7 +800,7 @@ func (c *ClusterK8sRunner) ensureCollectOutputsPod(ctx context.Context, input *a
 	return nil
 }
 
-func (c *ClusterK8sRunner) getPodLogs(ow *rpc.OutputWriter, podName string) (string, error) {
+func (c *ClusterK8sRunner) getPodLogs(ow *rpc.OutputWriter, namespace, podName string) (string, error) {
 	client := c.pool.Acquire()
 	defer c.pool.Release(client)
 
@@ -669,7 +811,7 @@ func (c *ClusterK8sRunner) getPodLogs(ow *rpc.OutputWriter, podName string) (str
 	var podLogs io.ReadCloser
 	var err error
 	err = retry(5, 5*time.Second, func() error {
-		req := client.CoreV1().Pods(c.config.Namespace).GetLogs(podName, &podLogOpts)
+		req := client.CoreV1().Pods(namespace).GetLogs(podName, &podLogOpts)
 		podLogs, err = req.Stream(context.TODO())
 		if err != nil {
 			ow.Warnw("got error when trying to fetch pod logs", "err", err.Error())
@@ -693,7 +835,7 @@ func (c *ClusterK8sRunner) getPodLogs(ow *rpc.OutputWriter, podName string) (str
 	return buf.String(), nil
 }
 
-func (c *ClusterK8sRunner) watchRunPods(ctx context.Context, ow *rpc.OutputWriter, input *api.RunInput, result *Result, rp *runtime.RunParams) error {
+func (c *ClusterK8sRunner) watchRunPods(ctx context.Context, ow *rpc.OutputWriter, input *api.RunInput, namespace string, evictions *k8sEvictions, result *Result, rp *runtime.RunParams) error {
 	client := c.pool.Acquire()
 	defer c.pool.Release(client)
 
@@ -708,7 +850,7 @@ func (c *ClusterK8sRunner) watchRunPods(ctx context.Context, ow *rpc.OutputWrite
 	opts := metav1.ListOptions{
 		FieldSelector: fieldSelector,
 	}
-	eventsWatcher, err := client.CoreV1().Events(c.config.Namespace).Watch(ctx, opts)
+	eventsWatcher, err := client.CoreV1().Events(namespace).Watch(ctx, opts)
 	if err != nil {
 		ow.Errorw("k8s client pods list error", "err", err.Error())
 		return err
@@ -755,7 +897,7 @@ func (c *ClusterK8sRunner) watchRunPods(ctx context.Context, ow *rpc.OutputWrite
 				LabelSelector: fmt.Sprintf("testground.run_id=%s", input.RunID),
 				FieldSelector: fieldSelector,
 			}
-			res, err := client.CoreV1().Pods(c.config.Namespace).List(ctx, opts)
+			res, err := client.CoreV1().Pods(namespace).List(ctx, opts)
 			if err != nil {
 				ow.Warnw("k8s client pods list error", "err", err.Error())
 				return -1
@@ -785,6 +927,25 @@ func (c *ClusterK8sRunner) watchRunPods(ctx context.Context, ow *rpc.OutputWrite
 		}
 		wg.Wait()
 
+		// Instances disrupted by their node don't count as failed while they
+		// are rescheduled; those gone with their node do. Listing errors would
+		// make pods seem gone.
+		failed := counters["Failed"]
+		if counters["Pending"] >= 0 && counters["Running"] >= 0 && counters["Succeeded"] >= 0 && failed >= 0 && counters["Unknown"] >= 0 {
+			var all []v1.Pod
+			for _, res := range podsByState {
+				all = append(all, res.Items...)
+			}
+			evictions.handle(ctx, ow, all, result)
+
+			failed = evictions.gone()
+			for _, p := range podsByState["Failed"].Items {
+				if !evictions.isPending(p.Name) {
+					failed++
+				}
+			}
+		}
+
 		ow.Debugw("testplan pods state", "running_for", time.Since(start).Truncate(time.Second), "succeeded", counters["Succeeded"], "running", counters["Running"], "pending", counters["Pending"], "failed", counters["Failed"], "unknown", counters["Unknown"])
 
 		if counters["Failed"] > 0 {
@@ -792,8 +953,14 @@ func (c *ClusterK8sRunner) watchRunPods(ctx context.Context, ow *rpc.OutputWrite
 				if !strings.Contains(p.ObjectMeta.Name, input.RunID) {
 					continue
 				}
+				if _, _, disrupted := podDisruption(&p); disrupted {
+					continue
+				}
 
 				for _, st := range p.Status.ContainerStatuses {
+					if st.State.Terminated == nil {
+						continue
+					}
 					event := fmt.Sprintf("pod status <failed> obj<%s> reason<%s> started_at<%s> finished_at<%s> exitcode<%d>", st.Name, st.State.Terminated.Reason, st.State.Terminated.StartedAt, st.State.Terminated.FinishedAt, st.State.Terminated.ExitCode)
 					ow.Warnw("testplan received status", "status", event)
 					result.Journal.PodsStatuses[event] = struct{}{}
@@ -804,6 +971,7 @@ func (c *ClusterK8sRunner) watchRunPods(ctx context.Context, ow *rpc.OutputWrite
 		if counters["Running"] == input.TotalInstances && !allRunningStage {
 			allRunningStage = true
 			ow.Infow("all testplan instances in `Running` state", "took", time.Since(start).Truncate(time.Second))
+			recordPodPlacement(ctx, podsByState["Running"])
 		}
 
 		if counters["Succeeded"] == input.TotalInstances {
@@ -811,25 +979,39 @@ func (c *ClusterK8sRunner) watchRunPods(ctx context.Context, ow *rpc.OutputWrite
 			return nil
 		}
 
-		if (counters["Succeeded"] + counters["Failed"]) == input.TotalInstances {
+		if (counters["Succeeded"] + failed) == input.TotalInstances {
 			ow.Warnw("all testplan instances in `Succeeded` or `Failed` state", "took", time.Since(start).Truncate(time.Second))
 			return nil
 		}
 	}
 }
 
-func (c *ClusterK8sRunner) createTestplanPod(ctx context.Context, podName string, input *api.RunInput, runenv runtime.RunParams, env []v1.EnvVar, g *api.RunGroup, i int, podResourceMemory resource.Quantity, podResourceCPU resource.Quantity) error {
+func (c *ClusterK8sRunner) createTestplanPod(ctx context.Context, namespace, podName string, input *api.RunInput, runenv runtime.RunParams, env []v1.EnvVar, g *api.RunGroup, i int, podResourceMemory resource.Quantity, podResourceCPU resource.Quantity) error {
 	client := c.pool.Acquire()
 	defer c.pool.Release(client)
 
 	cfg := *input.RunnerConfig.(*ClusterK8sRunnerConfig)
 
+	nodeSelector := map[string]string{"testground.node.role.plan": "true"}
+	if cfg.Architecture != "" {
+		nodeSelector[v1.LabelArchStable] = cfg.Architecture
+	}
+
 	var sysctls []v1.Sysctl
 	for _, v := range cfg.Sysctls {
 		sysctl := strings.Split(v, "=")
 		sysctls = append(sysctls, v1.Sysctl{Name: sysctl[0], Value: sysctl[1]})
 	}
 
+	var tolerations []v1.Toleration
+	for _, v := range cfg.Tolerations {
+		t, err := parseToleration(v)
+		if err != nil {
+			return fmt.Errorf("invalid toleration %q: %w", v, err)
+		}
+		tolerations = append(tolerations, t)
+	}
+
 	var ports []v1.ContainerPort
 	cnt := 0
 	for _, p := range cfg.ExposedPorts {
@@ -935,11 +1117,31 @@ func (c *ClusterK8sRunner) createTestplanPod(ctx context.Context, podName string
 					},
 				},
 			},
-			NodeSelector: map[string]string{"testground.node.role.plan": "true"},
+			NodeSelector: nodeSelector,
+			Tolerations:  tolerations,
 		},
 	}
 
-	_, err := client.CoreV1().Pods(c.config.Namespace).Create(ctx, podRequest, metav1.CreateOptions{})
+	// Mount the persistent volume of the instance, if its group has one.
+	if g.Volume != nil {
+		vol, mount, restore, err := instanceVolume(input.RunID, g, i, sharedVolumeName)
+		if err != nil {
+			return err
+		}
+		spec := &podRequest.Spec
+		if vol != nil {
+			spec.Volumes = append(spec.Volumes, *vol)
+		}
+		if restore != nil {
+			spec.InitContainers = append(spec.InitContainers, *restore)
+		}
+		spec.Containers[0].VolumeMounts = append(spec.Containers[0].VolumeMounts, mount)
+	}
+
+	// Mount the fixtures of the run, read-only.
+	podRequest.Spec.Containers[0].VolumeMounts = append(podRequest.Spec.Containers[0].VolumeMounts, fixtureVolumeMounts(input.Fixtures, sharedVolumeName)...)
+
+	_, err := client.CoreV1().Pods(namespace).Create(ctx, podRequest, metav1.CreateOptions{})
 	return err
 }
 
@@ -954,8 +1156,25 @@ func (fw FakeWriterAt) WriteAt(p []byte, offset int64) (n int, err error) {
 	return fw.w.Write(p)
 }
 
+// recordPodPlacement records the nodes the pods of a run were scheduled on.
+func recordPodPlacement(ctx context.Context, pods *v1.PodList) {
+	if pods == nil {
+		return
+	}
+	perNode := make(map[string]int)
+	for _, p := range pods.Items {
+		perNode[p.Spec.NodeName]++
+	}
+	nodes := make([]string, 0, len(perNode))
+	for n, cnt := range perNode {
+		nodes = append(nodes, fmt.Sprintf("%s (%d)", n, cnt))
+	}
+	sort.Strings(nodes)
+	api.RecordDecision(ctx, api.DecisionStagePlacement, "instances scheduled on nodes: %s", strings.Join(nodes, ", "))
+}
+
 // checkClusterResources returns whether we can fit the input groups in the current cluster
-func (c *ClusterK8sRunner) checkClusterResources(ow *rpc.OutputWriter, groups []*api.RunGroup, fallbackMemory resource.Quantity, fallbackCPU resource.Quantity) (bool, error) {
+func (c *ClusterK8sRunner) checkClusterResources(ctx context.Context, ow *rpc.OutputWriter, groups []*api.RunGroup, fallbackMemory resource.Quantity, fallbackCPU resource.Quantity) (bool, error) {
 	neededCPUs := 0.0
 
 	defaultPodCPU, err := strconv.ParseFloat(fallbackCPU.AsDec().String(), 64)
@@ -966,7 +1185,7 @@ func (c *ClusterK8sRunner) checkClusterResources(ow *rpc.OutputWriter, groups []
 	client := c.pool.Acquire()
 	defer c.pool.Release(client)
 
-	res, err := client.CoreV1().Nodes().List(context.TODO(), metav1.ListOptions{
+	res, err := client.CoreV1().Nodes().List(ctx, metav1.ListOptions{
 		LabelSelector: "testground.node.role.plan=true",
 	})
 	if err != nil {
@@ -1001,6 +1220,8 @@ func (c *ClusterK8sRunner) checkClusterResources(ow *rpc.OutputWriter, groups []
 		neededCPUs += podCPU * float64(g.Instances)
 	}
 
+	api.RecordDecision(ctx, api.DecisionStageLimits, "instances need %.2f CPUs; %d plan nodes offer %.2f CPUs, %.0f%% of which can be used", neededCPUs, nodes, availableCPUs, utilisation*100)
+
 	if (availableCPUs * utilisation) > neededCPUs {
 		return true, nil
 	}
@@ -1027,10 +1248,119 @@ func (c *ClusterK8sRunner) TerminateAll(ctx context.Context, ow *rpc.OutputWrite
 		ow.Errorw("could not terminate all pods", "err", err)
 		return err
 	}
+	if err := c.deleteRunNamespaces(ctx, ow, "testground.purpose=run"); err != nil {
+		ow.Errorw("could not delete the namespaces of runs", "err", err)
+		return err
+	}
 	return nil
 }
 
-func (c *ClusterK8sRunner) pushImagesToDockerRegistry(ctx context.Context, ow *rpc.OutputWriter, in *api.RunInput) error {
+// ListRuns returns the IDs of the runs that have plan pods.
+func (c *ClusterK8sRunner) ListRuns(ctx context.Context) ([]string, error) {
+	if err := c.initPool(); err != nil {
+		return nil, fmt.Errorf("could not init pool: %w", err)
+	}
+
+	client := c.pool.Acquire()
+	defer c.pool.Release(client)
+
+	pods, err := client.CoreV1().Pods(c.config.Namespace).List(ctx, metav1.ListOptions{
+		LabelSelector: "testground.purpose=plan",
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Runs with namespaces of their own may have left them behind, with or
+	// without pods.
+	namespaces, err := client.CoreV1().Namespaces().List(ctx, metav1.ListOptions{
+		LabelSelector: "testground.purpose=run",
+	})
+	if err != nil && !apierrors.IsForbidden(err) {
+		return nil, err
+	}
+
+	seen := make(map[string]struct{})
+	var runs []string
+	add := func(labels map[string]string) {
+		id := labels["testground.run_id"]
+		if _, ok := seen[id]; ok || id == "" {
+			return
+		}
+		seen[id] = struct{}{}
+		runs = append(runs, id)
+	}
+	for _, pod := range pods.Items {
+		add(pod.Labels)
+	}
+	if namespaces != nil {
+		for _, ns := range namespaces.Items {
+			add(ns.Labels)
+		}
+	}
+	sort.Strings(runs)
+	return runs, nil
+}
+
+// RemoveRun deletes the plan pods of a run, and its namespace if it has one.
+func (c *ClusterK8sRunner) RemoveRun(ctx context.Context, runID string, ow *rpc.OutputWriter) error {
+	if err := c.initPool(); err != nil {
+		return fmt.Errorf("could not init pool: %w", err)
+	}
+
+	client := c.pool.Acquire()
+	defer c.pool.Release(client)
+
+	ow.Infow("deleting pods", "run_id", runID)
+	err := client.CoreV1().Pods(c.config.Namespace).DeleteCollection(ctx, metav1.DeleteOptions{}, metav1.ListOptions{
+		LabelSelector: "testground.purpose=plan,testground.run_id=" + runID,
+	})
+	if err != nil {
+		return err
+	}
+	return c.deleteRunNamespaces(ctx, ow, "testground.purpose=run,testground.run_id="+runID)
+}
+
+// checkArchitecture verifies that the images of a run match the architecture
+// of the nodes it targets: the configured one, or else the one shared by all
+// the plan nodes.
+func (c *ClusterK8sRunner) checkArchitecture(ctx context.Context, ow *rpc.OutputWriter, input *api.RunInput, arch string) error {
+	if arch == "" {
+		k8s := c.pool.Acquire()
+		res, err := k8s.CoreV1().Nodes().List(ctx, metav1.ListOptions{
+			LabelSelector: "testground.node.role.plan=true",
+		})
+		c.pool.Release(k8s)
+		if err != nil {
+			return fmt.Errorf("failed to list plan nodes: %w", err)
+		}
+
+		archs := make(map[string]struct{})
+		for _, n := range res.Items {
+			archs[n.Status.NodeInfo.Architecture] = struct{}{}
+		}
+		if len(archs) != 1 {
+			ow.Warnw("plan nodes do not share a single architecture; not checking the architecture of images; set `architecture` in the runner configuration to pin pods to nodes", "architectures", len(archs))
+			return nil
+		}
+		for a := range archs {
+			arch = a
+		}
+	}
+
+	cli, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
+	if err != nil {
+		return fmt.Errorf("failed to create docker client: %w", err)
+	}
+	defer cli.Close()
+
+	return checkImageArchitectures(ctx, cli, input.Groups, arch)
+}
+
+func (c *ClusterK8sRunner) pushImagesToDockerRegistry(ctx context.Context, ow *rpc.OutputWriter, in *api.RunInput) (err error) {
+	ctx, span := tracing.Start(ctx, "push images")
+	defer func() { tracing.End(span, err) }()
+
 	cfg := *in.RunnerConfig.(*ClusterK8sRunnerConfig)
 
 	// Create a docker client.
@@ -1213,6 +1543,9 @@ func (c *ClusterK8sRunner) collectOutcomes(ctx context.Context, result *Result,
 		return nil, err
 	}
 
+	sessions := metrics.SyncSessions.WithLabelValues(c.ID())
+	sessions.Inc()
+
 	done := make(chan bool)
 
 	go func() {
@@ -1243,6 +1576,7 @@ func (c *ClusterK8sRunner) collectOutcomes(ctx context.Context, result *Result,
 			}
 		}
 
+		sessions.Dec()
 		done <- true
 	}()
 
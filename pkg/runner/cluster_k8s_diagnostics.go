@@ -0,0 +1,146 @@
+package runner
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/url"
+	"path"
+	"strconv"
+	"sync"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/client-go/tools/remotecommand"
+
+	"github.com/testground/testground/pkg/api"
+	"github.com/testground/testground/pkg/rpc"
+)
+
+// diagnosticsConcurrency bounds the instances of a cluster:k8s run diagnosed
+// at once.
+const diagnosticsConcurrency = 16
+
+// diagnoseRun captures the diagnostics of the pods of a failed run to the
+// outputs of their instances, through the collect-outputs pod, which mounts
+// the outputs volume. Profiles are fetched through the API server proxy, so
+// that the daemon doesn't need to reach the pods.
+func (c *ClusterK8sRunner) diagnoseRun(input *api.RunInput, ow *rpc.OutputWriter) {
+	ctx, cancel := context.WithTimeout(context.Background(), diagnosticsTimeout)
+	defer cancel()
+
+	err := c.ensureCollectOutputsPod(ctx, &api.CollectionInput{
+		EnvConfig:    input.EnvConfig,
+		RunID:        input.RunID,
+		RunnerID:     c.ID(),
+		RunnerConfig: input.RunnerConfig,
+	})
+	if err != nil {
+		ow.Warnw("failed to capture diagnostics; the outputs pod is not running", "err", err)
+		return
+	}
+
+	var (
+		wg  sync.WaitGroup
+		sem = make(chan struct{}, diagnosticsConcurrency)
+	)
+	for _, g := range input.Groups {
+		for i := 0; i < g.Instances; i++ {
+			g, i := g, i
+			wg.Add(1)
+			sem <- struct{}{}
+			go func() {
+				defer func() { <-sem; wg.Done() }()
+				c.diagnosePod(input, g.ID, i, ow)
+			}()
+		}
+	}
+	wg.Wait()
+}
+
+// diagnosePod captures the diagnostics of an instance.
+func (c *ClusterK8sRunner) diagnosePod(input *api.RunInput, group string, idx int, ow *rpc.OutputWriter) {
+	ctx, cancel := context.WithTimeout(context.Background(), diagnosticsTimeout)
+	defer cancel()
+
+	pod, err := c.instancePod(ctx, input.RunID, group, idx)
+	if err != nil {
+		ow.Warnw("failed to capture diagnostics of instance", "group", group, "group_index", idx, "err", err)
+		return
+	}
+
+	dir := fmt.Sprintf("/outputs/%s/%s/%d/%s", input.RunID, group, idx, diagnosticsDir)
+	in := instanceDiagnostics{
+		running: pod.Status.Phase == v1.PodRunning,
+		profile: func(ctx context.Context, port int, urlPath string) ([]byte, error) {
+			u, err := url.Parse(urlPath)
+			if err != nil {
+				return nil, err
+			}
+			params := make(map[string]string)
+			for k := range u.Query() {
+				params[k] = u.Query().Get(k)
+			}
+
+			client := c.pool.Acquire()
+			defer c.pool.Release(client)
+			return client.CoreV1().Pods(pod.Namespace).ProxyGet("http", pod.Name, strconv.Itoa(port), u.Path, params).DoRaw(ctx)
+		},
+		logs: func(ctx context.Context, lines int) ([]byte, error) {
+			tail := int64(lines)
+
+			client := c.pool.Acquire()
+			defer c.pool.Release(client)
+			return client.CoreV1().Pods(pod.Namespace).GetLogs(pod.Name, &v1.PodLogOptions{TailLines: &tail}).DoRaw(ctx)
+		},
+		write: func(name string, data []byte) error {
+			return c.writeOutputsFile(path.Join(dir, name), data)
+		},
+	}
+
+	if err := captureDiagnostics(input.Diagnostics, in); err != nil {
+		ow.Warnw("failed to capture some diagnostics of instance", "instance", pod.Name, "dir", dir, "err", err)
+		return
+	}
+	ow.Infow("captured diagnostics of instance", "instance", pod.Name, "dir", dir)
+}
+
+// writeOutputsFile writes a file to the outputs volume, through the
+// collect-outputs pod.
+func (c *ClusterK8sRunner) writeOutputsFile(file string, data []byte) error {
+	k8sCfg, err := clientcmd.BuildConfigFromFlags("", c.config.KubeConfigPath)
+	if err != nil {
+		return err
+	}
+
+	client := c.pool.Acquire()
+	defer c.pool.Release(client)
+
+	req := client.
+		CoreV1().
+		RESTClient().
+		Post().
+		Resource("pods").
+		Name(collectOutputsPodName).
+		Namespace(c.config.Namespace).
+		SubResource("exec").
+		VersionedParams(&v1.PodExecOptions{
+			Container: collectOutputsPodName,
+			Command:   []string{"sh", "-c", `mkdir -p "$(dirname "$0")" && cat > "$0"`, file},
+			Stdin:     true,
+			Stderr:    true,
+		}, scheme.ParameterCodec)
+
+	exec, err := remotecommand.NewSPDYExecutor(k8sCfg, "POST", req.URL())
+	if err != nil {
+		return err
+	}
+
+	var stderr bytes.Buffer
+	err = exec.Stream(remotecommand.StreamOptions{Stdin: bytes.NewReader(data), Stderr: &stderr})
+	if err != nil {
+		return fmt.Errorf("failed to write %s: %w: %s", file, err, stderr.String())
+	}
+	return nil
+}
@@ -0,0 +1,261 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/testground/testground/pkg/rpc"
+)
+
+// Instances on spot or preemptible nodes are disrupted when their node is
+// reclaimed: their pods are evicted, fail with the shutdown of their node, or
+// are deleted with it. Disrupted instances are recorded in the result of the
+// run, and, with reschedule_evicted, their pods are recreated, under the same
+// name, and with the number of times the instance was rescheduled in
+// TEST_INSTANCE_RESCHEDULES. The run ID and the sync service don't change, so
+// a rescheduled instance rejoins the sync session of the run; plans read that
+// variable to resume rather than start over, e.g. not to enter the barriers
+// they passed again.
+
+const (
+	// k8sDefaultMaxReschedules is how many times an instance is rescheduled,
+	// by default.
+	k8sDefaultMaxReschedules = 3
+
+	// k8sReschedulesEnv tells an instance how many times it was rescheduled.
+	k8sReschedulesEnv = "TEST_INSTANCE_RESCHEDULES"
+
+	// k8sReasonDeleted is the reason of the disruption of the instances whose
+	// pods are gone, e.g. with their node.
+	k8sReasonDeleted = "Deleted"
+)
+
+// k8sDisruptionReasons are the reasons of the pods that failed because they
+// were disrupted, rather than because their instance failed.
+var k8sDisruptionReasons = map[string]bool{
+	"Evicted":      true,
+	"Preempting":   true,
+	"NodeLost":     true,
+	"NodeShutdown": true,
+	"Shutdown":     true,
+	"Terminated":   true,
+}
+
+// podDisruption returns why a pod was disrupted, if it was.
+func podDisruption(p *v1.Pod) (reason, message string, disrupted bool) {
+	for _, c := range p.Status.Conditions {
+		// The DisruptionTarget condition of Kubernetes 1.26+.
+		if c.Type == "DisruptionTarget" && c.Status == v1.ConditionTrue {
+			return c.Reason, c.Message, true
+		}
+	}
+	if p.Status.Phase != v1.PodFailed && p.Status.Phase != v1.PodUnknown {
+		return "", "", false
+	}
+	if k8sDisruptionReasons[p.Status.Reason] {
+		return p.Status.Reason, p.Status.Message, true
+	}
+	return "", "", false
+}
+
+// parseToleration parses a toleration, as KEY[=VALUE][:EFFECT].
+func parseToleration(s string) (v1.Toleration, error) {
+	t := v1.Toleration{Operator: v1.TolerationOpExists}
+	if i := strings.LastIndex(s, ":"); i >= 0 {
+		t.Effect = v1.TaintEffect(s[i+1:])
+		s = s[:i]
+		switch t.Effect {
+		case v1.TaintEffectNoSchedule, v1.TaintEffectPreferNoSchedule, v1.TaintEffectNoExecute:
+		default:
+			return t, fmt.Errorf("invalid toleration effect %q", t.Effect)
+		}
+	}
+	if i := strings.Index(s, "="); i >= 0 {
+		t.Operator = v1.TolerationOpEqual
+		t.Value = s[i+1:]
+		s = s[:i]
+	}
+	if s == "" {
+		return t, fmt.Errorf("toleration without a key")
+	}
+	t.Key = s
+	return t, nil
+}
+
+// k8sInstance is how to create the pod of an instance again.
+type k8sInstance struct {
+	env    []v1.EnvVar
+	create func(ctx context.Context, env []v1.EnvVar) error
+}
+
+// k8sEvictions handles the disruption of the instances of a run.
+type k8sEvictions struct {
+	runner     *ClusterK8sRunner
+	namespace  string
+	reschedule bool
+	max        int
+
+	lk          sync.Mutex
+	instances   map[string]*k8sInstance
+	seen        map[string]string // the group of the pods, by name
+	handled     map[string]bool   // by pod UID
+	rescheduled map[string]int
+	pending     map[string]bool
+	lost        int
+}
+
+func newK8sEvictions(c *ClusterK8sRunner, namespace string, cfg *ClusterK8sRunnerConfig) *k8sEvictions {
+	max := cfg.MaxReschedules
+	if max == 0 {
+		max = k8sDefaultMaxReschedules
+	}
+	return &k8sEvictions{
+		runner:      c,
+		namespace:   namespace,
+		reschedule:  cfg.RescheduleEvicted,
+		max:         max,
+		instances:   make(map[string]*k8sInstance),
+		seen:        make(map[string]string),
+		handled:     make(map[string]bool),
+		rescheduled: make(map[string]int),
+		pending:     make(map[string]bool),
+	}
+}
+
+// register records how to create the pod of an instance again.
+func (e *k8sEvictions) register(pod string, env []v1.EnvVar, create func(ctx context.Context, env []v1.EnvVar) error) {
+	e.lk.Lock()
+	defer e.lk.Unlock()
+
+	e.instances[pod] = &k8sInstance{env: env, create: create}
+}
+
+// isPending returns whether the pod of an instance is being recreated, so that
+// it doesn't count as failed.
+func (e *k8sEvictions) isPending(pod string) bool {
+	e.lk.Lock()
+	defer e.lk.Unlock()
+
+	return e.pending[pod]
+}
+
+// handle records the disruptions of the pods of the run, and reschedules the
+// disrupted instances, if enabled. pods are all the pods of the run.
+func (e *k8sEvictions) handle(ctx context.Context, ow *rpc.OutputWriter, pods []v1.Pod, result *Result) {
+	e.lk.Lock()
+	defer e.lk.Unlock()
+
+	listed := make(map[string]bool, len(pods))
+	for i := range pods {
+		p := &pods[i]
+		listed[p.Name] = true
+		e.seen[p.Name] = p.Labels["testground.groupid"]
+
+		reason, message, disrupted := podDisruption(p)
+		if !disrupted || e.handled[string(p.UID)] || e.pending[p.Name] {
+			continue
+		}
+		e.handled[string(p.UID)] = true
+		e.disrupted(ctx, ow, p.Name, p.Labels["testground.groupid"], p.Spec.NodeName, reason, message, true, result)
+	}
+
+	// Pods deleted along with their node don't fail; they are gone.
+	for name, group := range e.seen {
+		if listed[name] || e.pending[name] {
+			continue
+		}
+		delete(e.seen, name)
+		if !e.disrupted(ctx, ow, name, group, "", k8sReasonDeleted, "the pod of the instance is gone", false, result) {
+			e.lost++
+		}
+	}
+}
+
+// gone returns how many instances are gone, with their pods, and won't be
+// rescheduled, so that they count as failed.
+func (e *k8sEvictions) gone() int {
+	e.lk.Lock()
+	defer e.lk.Unlock()
+
+	return e.lost
+}
+
+// disrupted records the disruption of an instance, and reschedules it if
+// enabled, returning whether it did. exists is whether its pod is still
+// there.
+func (e *k8sEvictions) disrupted(ctx context.Context, ow *rpc.OutputWriter, pod, group, node, reason, message string, exists bool, result *Result) bool {
+	d := &Disruption{
+		Time:     time.Now(),
+		Group:    group,
+		Instance: pod,
+		Node:     node,
+		Reason:   reason,
+		Message:  message,
+	}
+
+	inst, ok := e.instances[pod]
+	if e.reschedule && ok && e.rescheduled[pod] < e.max {
+		e.rescheduled[pod]++
+		e.pending[pod] = true
+		d.Rescheduled = true
+		go e.recreate(ctx, ow, pod, inst, e.rescheduled[pod], exists)
+	}
+
+	result.Disruptions = append(result.Disruptions, d)
+	result.Journal.Events["disruption-"+pod+"-"+strconv.Itoa(len(result.Disruptions))] = fmt.Sprintf(
+		"instance disrupted obj<%s> group<%s> node<%s> reason<%s> message<%s> rescheduled<%t>", pod, group, node, reason, message, d.Rescheduled)
+	ow.Warnw("instance disrupted", "instance", pod, "group", group, "node", node, "reason", reason, "message", message, "rescheduled", d.Rescheduled)
+	return d.Rescheduled
+}
+
+// recreate deletes the pod of a disrupted instance, if it exists, waits for
+// it to be gone, and creates it again.
+func (e *k8sEvictions) recreate(ctx context.Context, ow *rpc.OutputWriter, pod string, inst *k8sInstance, attempt int, exists bool) {
+	defer func() {
+		e.lk.Lock()
+		delete(e.pending, pod)
+		e.lk.Unlock()
+	}()
+
+	client := e.runner.pool.Acquire()
+	pods := client.CoreV1().Pods(e.namespace)
+	err := func() error {
+		defer e.runner.pool.Release(client)
+
+		if exists {
+			if err := pods.Delete(ctx, pod, metav1.DeleteOptions{}); err != nil && !apierrors.IsNotFound(err) {
+				return err
+			}
+		}
+		for {
+			if _, err := pods.Get(ctx, pod, metav1.GetOptions{}); apierrors.IsNotFound(err) {
+				return nil
+			}
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(time.Second):
+			}
+		}
+	}()
+
+	if err == nil {
+		env := make([]v1.EnvVar, len(inst.env), len(inst.env)+1)
+		copy(env, inst.env)
+		env = append(env, v1.EnvVar{Name: k8sReschedulesEnv, Value: strconv.Itoa(attempt)})
+		err = inst.create(ctx, env)
+	}
+	if err != nil {
+		ow.Errorw("failed to reschedule instance", "instance", pod, "err", err)
+		return
+	}
+	ow.Infow("rescheduled instance", "instance", pod, "reschedules", attempt)
+}
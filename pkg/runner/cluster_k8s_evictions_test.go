@@ -0,0 +1,88 @@
+package runner
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	"github.com/testground/testground/pkg/api"
+	"github.com/testground/testground/pkg/rpc"
+)
+
+func TestPodDisruption(t *testing.T) {
+	evicted := v1.Pod{Status: v1.PodStatus{Phase: v1.PodFailed, Reason: "Evicted", Message: "The node was low on resource: memory."}}
+	reason, message, ok := podDisruption(&evicted)
+	require.True(t, ok)
+	require.Equal(t, "Evicted", reason)
+	require.Equal(t, "The node was low on resource: memory.", message)
+
+	target := v1.Pod{Status: v1.PodStatus{Phase: v1.PodRunning, Conditions: []v1.PodCondition{
+		{Type: "DisruptionTarget", Status: v1.ConditionTrue, Reason: "TerminationByKubelet"},
+	}}}
+	reason, _, ok = podDisruption(&target)
+	require.True(t, ok)
+	require.Equal(t, "TerminationByKubelet", reason)
+
+	failed := v1.Pod{Status: v1.PodStatus{Phase: v1.PodFailed, Reason: "Error"}}
+	_, _, ok = podDisruption(&failed)
+	require.False(t, ok)
+
+	// Running pods aren't disrupted, whatever their reason.
+	running := v1.Pod{Status: v1.PodStatus{Phase: v1.PodRunning, Reason: "Evicted"}}
+	_, _, ok = podDisruption(&running)
+	require.False(t, ok)
+}
+
+func TestParseToleration(t *testing.T) {
+	tt, err := parseToleration("cloud.google.com/gke-preemptible=true:NoSchedule")
+	require.NoError(t, err)
+	require.Equal(t, v1.Toleration{Key: "cloud.google.com/gke-preemptible", Operator: v1.TolerationOpEqual, Value: "true", Effect: v1.TaintEffectNoSchedule}, tt)
+
+	tt, err = parseToleration("spot")
+	require.NoError(t, err)
+	require.Equal(t, v1.Toleration{Key: "spot", Operator: v1.TolerationOpExists}, tt)
+
+	_, err = parseToleration("spot:Sometimes")
+	require.Error(t, err)
+	_, err = parseToleration("=true")
+	require.Error(t, err)
+}
+
+func TestEvictionsWithoutRescheduling(t *testing.T) {
+	e := newK8sEvictions(nil, "default", &ClusterK8sRunnerConfig{})
+	result := newResult(&api.RunInput{Groups: []*api.RunGroup{{ID: "a", Instances: 2}}})
+	ctx, ow := context.Background(), rpc.Discard()
+
+	pod := func(name, uid string, phase v1.PodPhase, reason string) v1.Pod {
+		return v1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: name, UID: types.UID(uid), Labels: map[string]string{"testground.groupid": "a"}},
+			Spec:       v1.PodSpec{NodeName: "spot-1"},
+			Status:     v1.PodStatus{Phase: phase, Reason: reason},
+		}
+	}
+
+	e.handle(ctx, ow, []v1.Pod{pod("a-0", "0", v1.PodRunning, ""), pod("a-1", "1", v1.PodRunning, "")}, result)
+	require.Empty(t, result.Disruptions)
+
+	// An evicted pod is recorded once.
+	for i := 0; i < 2; i++ {
+		e.handle(ctx, ow, []v1.Pod{pod("a-0", "0", v1.PodFailed, "Evicted"), pod("a-1", "1", v1.PodRunning, "")}, result)
+	}
+	require.Len(t, result.Disruptions, 1)
+	require.Equal(t, "a-0", result.Disruptions[0].Instance)
+	require.Equal(t, "spot-1", result.Disruptions[0].Node)
+	require.False(t, result.Disruptions[0].Rescheduled)
+	require.Zero(t, e.gone())
+
+	// A pod gone with its node counts as failed.
+	e.handle(ctx, ow, []v1.Pod{pod("a-0", "0", v1.PodFailed, "Evicted")}, result)
+	require.Len(t, result.Disruptions, 2)
+	require.Equal(t, k8sReasonDeleted, result.Disruptions[1].Reason)
+	require.Equal(t, "a", result.Disruptions[1].Group)
+	require.Equal(t, 1, e.gone())
+	require.Len(t, result.Journal.Events, 2)
+}
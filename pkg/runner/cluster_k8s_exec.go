@@ -0,0 +1,123 @@
+package runner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/client-go/tools/remotecommand"
+	"k8s.io/client-go/util/exec"
+
+	"github.com/testground/testground/pkg/api"
+	"github.com/testground/testground/pkg/rpc"
+)
+
+// instancePod returns the pod of an instance of a run.
+func (c *ClusterK8sRunner) instancePod(ctx context.Context, runID, group string, idx int) (*v1.Pod, error) {
+	client := c.pool.Acquire()
+	defer c.pool.Release(client)
+
+	pods, err := client.CoreV1().Pods(c.runNamespace(ctx, runID)).List(ctx, metav1.ListOptions{
+		LabelSelector: fmt.Sprintf("testground.purpose=plan,testground.run_id=%s,testground.groupid=%s", runID, group),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list pods: %w", err)
+	}
+
+	suffix := fmt.Sprintf("-%s-%s-%d", runID, group, idx)
+	for i := range pods.Items {
+		if pod := &pods.Items[i]; strings.HasSuffix(pod.Name, suffix) {
+			return pod, nil
+		}
+	}
+	return nil, fmt.Errorf("run %s has no instance %d in group %s", runID, idx, group)
+}
+
+// Exec runs a command in the pod of an instance of a live run.
+func (c *ClusterK8sRunner) Exec(ctx context.Context, input *api.ExecInput) (*api.ExecOutput, error) {
+	if err := c.initPool(); err != nil {
+		return nil, fmt.Errorf("could not init pool: %w", err)
+	}
+
+	pod, err := c.instancePod(ctx, input.RunID, input.Group, input.Instance)
+	if err != nil {
+		return nil, err
+	}
+	if pod.Status.Phase != v1.PodRunning {
+		return nil, fmt.Errorf("instance %d of group %s is not running (phase: %s)", input.Instance, input.Group, pod.Status.Phase)
+	}
+
+	k8sCfg, err := clientcmd.BuildConfigFromFlags("", c.config.KubeConfigPath)
+	if err != nil {
+		return nil, err
+	}
+
+	client := c.pool.Acquire()
+	defer c.pool.Release(client)
+
+	req := client.
+		CoreV1().
+		RESTClient().
+		Post().
+		Resource("pods").
+		Name(pod.Name).
+		Namespace(pod.Namespace).
+		SubResource("exec").
+		VersionedParams(&v1.PodExecOptions{
+			Container: pod.Name,
+			Command:   input.Cmd,
+			Stdin:     input.Stdin != nil,
+			Stdout:    true,
+			Stderr:    !input.Tty,
+			TTY:       input.Tty,
+		}, scheme.ParameterCodec)
+
+	executor, err := remotecommand.NewSPDYExecutor(k8sCfg, "POST", req.URL())
+	if err != nil {
+		return nil, fmt.Errorf("failed to exec in %s: %w", pod.Name, err)
+	}
+
+	opts := remotecommand.StreamOptions{Stdin: input.Stdin, Stdout: input.Stdout, Tty: input.Tty}
+	if !input.Tty {
+		opts.Stderr = input.Stderr
+	}
+
+	out := &api.ExecOutput{Instance: pod.Name}
+	err = executor.Stream(opts)
+	var exit exec.CodeExitError
+	switch {
+	case errors.As(err, &exit):
+		out.ExitCode = exit.ExitStatus()
+	case err != nil:
+		return nil, fmt.Errorf("failed to exec in %s: %w", pod.Name, err)
+	}
+	return out, nil
+}
+
+// pauseOnFailure keeps the pods of a failed run until the pause is over or
+// the run is canceled. Only the instances that are still running can be
+// exec'd into; the others keep their logs and outputs.
+func (c *ClusterK8sRunner) pauseOnFailure(ctx context.Context, input *api.RunInput, ow *rpc.OutputWriter) {
+	for _, g := range input.Groups {
+		for i := 0; i < g.Instances; i++ {
+			pod, err := c.instancePod(ctx, input.RunID, g.ID, i)
+			if err != nil || pod.Status.Phase != v1.PodRunning {
+				continue
+			}
+			ow.Infow("instance kept alive for inspection", "instance", pod.Name,
+				"exec", fmt.Sprintf("testground exec --task %s --group %s --instance %d -- /bin/sh", input.RunID, g.ID, i))
+		}
+	}
+
+	ow.Infow("run failed; pausing before tearing it down; cancel the task to tear it down earlier", "pause", input.PauseOnFailure)
+	select {
+	case <-time.After(input.PauseOnFailure):
+	case <-ctx.Done():
+	}
+}
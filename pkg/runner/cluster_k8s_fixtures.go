@@ -0,0 +1,203 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"strings"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/testground/testground/pkg/api"
+	"github.com/testground/testground/pkg/fixtures"
+	"github.com/testground/testground/pkg/rpc"
+)
+
+// The fixtures of cluster:k8s runs are cached on the shared filesystem, as
+// fixtures/<cache key>. Before the instances are scheduled, a pod fetches the
+// fixtures that aren't cached yet, one init container per fixture; the
+// instances then mount them read-only from there.
+
+const fixturesToolsVolumeName = "fixtures-tools"
+
+// fetchFixtureScript fetches a fixture into $FIXTURE_DIR, unless it is there
+// already. Every command goes through $BB, the busybox binary, so that the
+// script also runs in OCI images holding nothing but data.
+const fetchFixtureScript = `set -e
+d="$FIXTURE_DIR"
+if [ -d "$d" ]; then echo "fixture cached at $d"; exit 0; fi
+t="$d.$FIXTURE_RUN"
+$BB rm -rf "$t" "$t.tar"
+$BB mkdir -p "$t"
+if [ -n "$FIXTURE_URL" ]; then
+  $BB wget -q -O "$t.tar" "$FIXTURE_URL"
+  if [ -n "$FIXTURE_SHA256" ]; then echo "$FIXTURE_SHA256  $t.tar" | $BB sha256sum -c -; fi
+  if $BB gzip -t "$t.tar" 2>/dev/null; then $BB tar -xzf "$t.tar" -C "$t"; else $BB tar -xf "$t.tar" -C "$t"; fi
+  $BB rm -f "$t.tar"
+else
+  $BB cp -a "$FIXTURE_SRC/." "$t/"
+fi
+if [ -d "$d" ]; then $BB rm -rf "$t"; else $BB mv "$t" "$d"; fi
+`
+
+func k8sFixturePath(f *api.Fixture) string {
+	return path.Join("fixtures", fixtures.CacheKey(f))
+}
+
+func k8sFixturesPodName(runID string) string {
+	return "tg-fixtures-" + runID
+}
+
+// fixturesPod returns the pod fetching the fixtures of a run onto the shared
+// filesystem.
+func fixturesPod(runID string, fs []*api.Fixture, sharedVolumeName string) (*v1.Pod, error) {
+	limits := v1.ResourceRequirements{
+		Limits: v1.ResourceList{
+			v1.ResourceMemory: resource.MustParse("256Mi"),
+			v1.ResourceCPU:    resource.MustParse("500m"),
+		},
+	}
+	efs := v1.VolumeMount{Name: sharedVolumeName, MountPath: "/efs"}
+	tools := v1.VolumeMount{Name: fixturesToolsVolumeName, MountPath: "/tools", ReadOnly: true}
+
+	pod := &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: k8sFixturesPodName(runID),
+			Labels: map[string]string{
+				"testground.run_id":  runID,
+				"testground.purpose": "fixtures",
+			},
+		},
+		Spec: v1.PodSpec{
+			Volumes: []v1.Volume{
+				{
+					Name: sharedVolumeName,
+					VolumeSource: v1.VolumeSource{
+						PersistentVolumeClaim: &v1.PersistentVolumeClaimVolumeSource{ClaimName: "efs"},
+					},
+				},
+				{
+					Name:         fixturesToolsVolumeName,
+					VolumeSource: v1.VolumeSource{EmptyDir: &v1.EmptyDirVolumeSource{}},
+				},
+			},
+			RestartPolicy: v1.RestartPolicyNever,
+			NodeSelector:  map[string]string{"testground.node.role.infra": "true"},
+			InitContainers: []v1.Container{
+				{
+					Name:         "tools",
+					Image:        "busybox",
+					Args:         []string{"-c", "cp /bin/busybox /tools/busybox"},
+					Command:      []string{"sh"},
+					VolumeMounts: []v1.VolumeMount{{Name: fixturesToolsVolumeName, MountPath: "/tools"}},
+					Resources:    limits,
+				},
+			},
+			Containers: []v1.Container{
+				{
+					Name:      "done",
+					Image:     "busybox",
+					Command:   []string{"true"},
+					Resources: limits,
+				},
+			},
+		},
+	}
+
+	for _, f := range fs {
+		if _, ok := f.LocalDir(); ok {
+			return nil, fmt.Errorf("fixture %s: cluster:k8s can't mount local directories of the daemon host; use a tarball URL or an OCI image", f.Name)
+		}
+
+		env := []v1.EnvVar{
+			{Name: "FIXTURE_DIR", Value: path.Join("/efs", k8sFixturePath(f))},
+			{Name: "FIXTURE_RUN", Value: runID},
+		}
+		c := v1.Container{
+			Name:         "fetch-" + strings.ReplaceAll(f.Name, "_", "-"),
+			Image:        "busybox",
+			Command:      []string{"/tools/busybox", "sh", "-c", fetchFixtureScript},
+			VolumeMounts: []v1.VolumeMount{efs, tools},
+			Resources:    limits,
+		}
+		if f.IsImage() {
+			c.Image = strings.TrimPrefix(f.Source, "oci://")
+			env = append(env, v1.EnvVar{Name: "FIXTURE_SRC", Value: f.ImageSourcePath()})
+		} else {
+			env = append(env,
+				v1.EnvVar{Name: "FIXTURE_URL", Value: f.Source},
+				v1.EnvVar{Name: "FIXTURE_SHA256", Value: f.SHA256},
+			)
+		}
+		c.Env = append(env, v1.EnvVar{Name: "BB", Value: "/tools/busybox"})
+		pod.Spec.InitContainers = append(pod.Spec.InitContainers, c)
+	}
+	return pod, nil
+}
+
+// fixtureVolumeMounts returns the read-only mounts of the fixtures of a run in
+// its instances.
+func fixtureVolumeMounts(fs []*api.Fixture, sharedVolumeName string) []v1.VolumeMount {
+	mounts := make([]v1.VolumeMount, 0, len(fs))
+	for _, f := range fs {
+		mounts = append(mounts, v1.VolumeMount{
+			Name:      sharedVolumeName,
+			MountPath: f.MountPath(),
+			SubPath:   k8sFixturePath(f),
+			ReadOnly:  true,
+		})
+	}
+	return mounts
+}
+
+// fetchFixtures fetches the fixtures of a run onto the shared filesystem,
+// unless they are cached there, and waits for them.
+func (c *ClusterK8sRunner) fetchFixtures(ctx context.Context, input *api.RunInput, ow *rpc.OutputWriter) error {
+	pod, err := fixturesPod(input.RunID, input.Fixtures, "efs-shared")
+	if err != nil {
+		return err
+	}
+
+	client := c.pool.Acquire()
+	defer c.pool.Release(client)
+
+	pods := client.CoreV1().Pods(c.config.Namespace)
+	if _, err := pods.Create(ctx, pod, metav1.CreateOptions{}); err != nil {
+		return fmt.Errorf("failed to create the fixtures pod: %w", err)
+	}
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		if err := pods.Delete(ctx, pod.Name, metav1.DeleteOptions{}); err != nil {
+			ow.Warnw("couldn't remove the fixtures pod", "pod", pod.Name, "err", err)
+		}
+	}()
+
+	ow.Infow("fetching fixtures", "pod", pod.Name, "count", len(input.Fixtures))
+	for {
+		p, err := pods.Get(ctx, pod.Name, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		switch p.Status.Phase {
+		case v1.PodSucceeded:
+			return nil
+		case v1.PodFailed:
+			for _, cs := range p.Status.InitContainerStatuses {
+				if t := cs.State.Terminated; t != nil && t.ExitCode != 0 {
+					return fmt.Errorf("failed to fetch fixtures: %s exited with code %d: %s", cs.Name, t.ExitCode, t.Message)
+				}
+			}
+			return fmt.Errorf("failed to fetch fixtures: pod %s failed: %s", pod.Name, p.Status.Message)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(2 * time.Second):
+		}
+	}
+}
@@ -0,0 +1,268 @@
+package runner
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"text/template"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/yaml"
+	"k8s.io/client-go/kubernetes"
+
+	"github.com/testground/testground/pkg/api"
+	"github.com/testground/testground/pkg/rpc"
+)
+
+// With namespace_per_run, the pods of a run run in a namespace of their own,
+// tg-run-<run id>, which holds the objects rendered from the ResourceQuota,
+// LimitRange and NetworkPolicy templates of the runner config, and a claim of
+// the outputs volume. A volume binds a single claim, so the volume of the
+// claim of the daemon namespace is cloned into one bound to the claim of the
+// run; the clone retains the data when it's deleted. Deleting the namespace
+// deletes everything in it, the services and claims the instances created
+// included; the cloned volume is deleted along with it.
+
+const (
+	k8sRunNamespacePrefix = "tg-run-"
+	k8sOutputsClaimName   = "efs"
+)
+
+// k8sRunNamespaceName returns the name of the namespace of a run.
+func k8sRunNamespaceName(runID string) string {
+	return k8sRunNamespacePrefix + runID
+}
+
+// k8sNamespaceTemplateData is what the templates of the objects of the
+// namespace of a run are rendered with.
+type k8sNamespaceTemplateData struct {
+	RunID          string
+	Namespace      string
+	InfraNamespace string
+	TestPlan       string
+	TestCase       string
+	TotalInstances int
+}
+
+// runNamespace returns the namespace the pods of a live run run in.
+func (c *ClusterK8sRunner) runNamespace(ctx context.Context, runID string) string {
+	client := c.pool.Acquire()
+	defer c.pool.Release(client)
+
+	ns := k8sRunNamespaceName(runID)
+	if _, err := client.CoreV1().Namespaces().Get(ctx, ns, metav1.GetOptions{}); err == nil {
+		return ns
+	}
+	return c.config.Namespace
+}
+
+// infraHost returns the name the pods of a namespace resolve a service of the
+// infrastructure by.
+func (c *ClusterK8sRunner) infraHost(namespace, service string) string {
+	if namespace == c.config.Namespace {
+		return service
+	}
+	return fmt.Sprintf("%s.%s.svc.cluster.local", service, c.config.Namespace)
+}
+
+// createRunNamespace creates the namespace of a run, and the objects in it.
+// The namespace is deleted if any of them fails to be created.
+func (c *ClusterK8sRunner) createRunNamespace(ctx context.Context, ow *rpc.OutputWriter, input *api.RunInput, cfg *ClusterK8sRunnerConfig) (ns string, err error) {
+	client := c.pool.Acquire()
+	defer c.pool.Release(client)
+
+	ns = k8sRunNamespaceName(input.RunID)
+	labels := map[string]string{
+		"testground.run_id":  input.RunID,
+		"testground.plan":    input.TestPlan,
+		"testground.purpose": "run",
+	}
+
+	ow.Infow("creating the namespace of the run", "namespace", ns)
+	_, err = client.CoreV1().Namespaces().Create(ctx, &v1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{Name: ns, Labels: labels},
+	}, metav1.CreateOptions{})
+	if err != nil {
+		return "", fmt.Errorf("failed to create namespace %s: %w", ns, err)
+	}
+	defer func() {
+		if err != nil {
+			c.deleteRunNamespace(ow, input.RunID)
+		}
+	}()
+
+	data := k8sNamespaceTemplateData{
+		RunID:          input.RunID,
+		Namespace:      ns,
+		InfraNamespace: c.config.Namespace,
+		TestPlan:       input.TestPlan,
+		TestCase:       input.TestCase,
+		TotalInstances: input.TotalInstances,
+	}
+	home := input.EnvConfig.Dirs().Home()
+
+	if err = createFromTemplate(home, cfg.ResourceQuotaTemplate, data, func() interface{} { return &v1.ResourceQuota{} }, func(obj interface{}) error {
+		o := obj.(*v1.ResourceQuota)
+		o.Namespace = ns
+		_, err := client.CoreV1().ResourceQuotas(ns).Create(ctx, o, metav1.CreateOptions{})
+		return err
+	}); err != nil {
+		return "", fmt.Errorf("failed to create the resource quotas of the run: %w", err)
+	}
+
+	if err = createFromTemplate(home, cfg.LimitRangeTemplate, data, func() interface{} { return &v1.LimitRange{} }, func(obj interface{}) error {
+		o := obj.(*v1.LimitRange)
+		o.Namespace = ns
+		_, err := client.CoreV1().LimitRanges(ns).Create(ctx, o, metav1.CreateOptions{})
+		return err
+	}); err != nil {
+		return "", fmt.Errorf("failed to create the limit ranges of the run: %w", err)
+	}
+
+	if err = createFromTemplate(home, cfg.NetworkPolicyTemplate, data, func() interface{} { return &networkingv1.NetworkPolicy{} }, func(obj interface{}) error {
+		o := obj.(*networkingv1.NetworkPolicy)
+		o.Namespace = ns
+		_, err := client.NetworkingV1().NetworkPolicies(ns).Create(ctx, o, metav1.CreateOptions{})
+		return err
+	}); err != nil {
+		return "", fmt.Errorf("failed to create the network policies of the run: %w", err)
+	}
+
+	if err = c.cloneOutputsClaim(ctx, client, ns, labels); err != nil {
+		return "", fmt.Errorf("failed to claim the outputs volume in the namespace of the run: %w", err)
+	}
+	return ns, nil
+}
+
+// createFromTemplate renders the template at path, relative to the home
+// directory, and creates every object of the YAML documents it renders.
+func createFromTemplate(home, path string, data k8sNamespaceTemplateData, newObj func() interface{}, create func(obj interface{}) error) error {
+	if path == "" {
+		return nil
+	}
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(home, path)
+	}
+
+	src, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	tpl, err := template.New(filepath.Base(path)).Option("missingkey=error").Parse(string(src))
+	if err != nil {
+		return fmt.Errorf("failed to parse template %s: %w", path, err)
+	}
+	var buf bytes.Buffer
+	if err := tpl.Execute(&buf, data); err != nil {
+		return fmt.Errorf("failed to render template %s: %w", path, err)
+	}
+
+	dec := yaml.NewYAMLOrJSONDecoder(&buf, 4096)
+	for {
+		obj := newObj()
+		if err := dec.Decode(obj); errors.Is(err, io.EOF) {
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("failed to decode template %s: %w", path, err)
+		}
+		if err := create(obj); err != nil {
+			return err
+		}
+	}
+}
+
+// cloneOutputsClaim claims the outputs volume in the namespace of a run, by
+// cloning the volume bound to the claim of the daemon namespace.
+func (c *ClusterK8sRunner) cloneOutputsClaim(ctx context.Context, client *kubernetes.Clientset, ns string, labels map[string]string) error {
+	claim, err := client.CoreV1().PersistentVolumeClaims(c.config.Namespace).Get(ctx, k8sOutputsClaimName, metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+	if claim.Spec.VolumeName == "" {
+		return fmt.Errorf("claim %s/%s is not bound", c.config.Namespace, k8sOutputsClaimName)
+	}
+	pv, err := client.CoreV1().PersistentVolumes().Get(ctx, claim.Spec.VolumeName, metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+
+	name := ns + "-" + k8sOutputsClaimName
+	storageClass := ""
+	clone := &v1.PersistentVolume{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Labels: labels},
+		Spec: v1.PersistentVolumeSpec{
+			Capacity:                      pv.Spec.Capacity,
+			PersistentVolumeSource:        pv.Spec.PersistentVolumeSource,
+			AccessModes:                   pv.Spec.AccessModes,
+			MountOptions:                  pv.Spec.MountOptions,
+			VolumeMode:                    pv.Spec.VolumeMode,
+			PersistentVolumeReclaimPolicy: v1.PersistentVolumeReclaimRetain,
+			ClaimRef:                      &v1.ObjectReference{Namespace: ns, Name: k8sOutputsClaimName},
+		},
+	}
+	if _, err := client.CoreV1().PersistentVolumes().Create(ctx, clone, metav1.CreateOptions{}); err != nil {
+		return err
+	}
+
+	_, err = client.CoreV1().PersistentVolumeClaims(ns).Create(ctx, &v1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{Name: k8sOutputsClaimName, Labels: labels},
+		Spec: v1.PersistentVolumeClaimSpec{
+			AccessModes:      claim.Spec.AccessModes,
+			Resources:        claim.Spec.Resources,
+			VolumeName:       name,
+			StorageClassName: &storageClass,
+			VolumeMode:       claim.Spec.VolumeMode,
+		},
+	}, metav1.CreateOptions{})
+	return err
+}
+
+// deleteRunNamespace deletes the namespace of a run, with everything in it,
+// and its clone of the outputs volume. The run context may be done, so it
+// deletes them with a context of its own.
+func (c *ClusterK8sRunner) deleteRunNamespace(ow *rpc.OutputWriter, runID string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	if err := c.deleteRunNamespaces(ctx, ow, "testground.purpose=run,testground.run_id="+runID); err != nil {
+		ow.Errorw("couldn't delete the namespace of the run", "namespace", k8sRunNamespaceName(runID), "err", err)
+	}
+}
+
+// deleteRunNamespaces deletes the namespaces of runs, and their clones of
+// the outputs volume, by label selector.
+func (c *ClusterK8sRunner) deleteRunNamespaces(ctx context.Context, ow *rpc.OutputWriter, selector string) error {
+	client := c.pool.Acquire()
+	defer c.pool.Release(client)
+
+	opts := metav1.ListOptions{LabelSelector: selector}
+	namespaces, err := client.CoreV1().Namespaces().List(ctx, opts)
+	switch {
+	case apierrors.IsForbidden(err):
+		// Runs don't have namespaces of their own without the permission.
+		return nil
+	case err != nil:
+		return err
+	}
+
+	propagation := metav1.DeletePropagationForeground
+	for _, ns := range namespaces.Items {
+		ow.Infow("deleting namespace", "namespace", ns.Name)
+		err := client.CoreV1().Namespaces().Delete(ctx, ns.Name, metav1.DeleteOptions{PropagationPolicy: &propagation})
+		if err != nil && !apierrors.IsNotFound(err) {
+			return err
+		}
+	}
+
+	// The volumes are protected until their claims are deleted with the
+	// namespaces.
+	return client.CoreV1().PersistentVolumes().DeleteCollection(ctx, metav1.DeleteOptions{}, opts)
+}
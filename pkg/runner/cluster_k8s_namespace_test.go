@@ -0,0 +1,55 @@
+package runner
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	networkingv1 "k8s.io/api/networking/v1"
+)
+
+func TestCreateFromTemplate(t *testing.T) {
+	home := t.TempDir()
+	tpl := `apiVersion: networking.k8s.io/v1
+kind: NetworkPolicy
+metadata:
+  name: isolate-{{ .RunID }}
+spec:
+  podSelector: {}
+  ingress:
+    - from:
+        - namespaceSelector:
+            matchLabels:
+              kubernetes.io/metadata.name: {{ .InfraNamespace }}
+---
+apiVersion: networking.k8s.io/v1
+kind: NetworkPolicy
+metadata:
+  name: deny-egress
+spec:
+  podSelector: {}
+  policyTypes: ["Egress"]
+`
+	require.NoError(t, ioutil.WriteFile(filepath.Join(home, "policy.yaml"), []byte(tpl), 0644))
+
+	data := k8sNamespaceTemplateData{RunID: "abc", Namespace: k8sRunNamespaceName("abc"), InfraNamespace: "default"}
+
+	var created []*networkingv1.NetworkPolicy
+	err := createFromTemplate(home, "policy.yaml", data, func() interface{} { return &networkingv1.NetworkPolicy{} }, func(obj interface{}) error {
+		created = append(created, obj.(*networkingv1.NetworkPolicy))
+		return nil
+	})
+	require.NoError(t, err)
+	require.Len(t, created, 2)
+	require.Equal(t, "isolate-abc", created[0].Name)
+	require.Equal(t, "default", created[0].Spec.Ingress[0].From[0].NamespaceSelector.MatchLabels["kubernetes.io/metadata.name"])
+	require.Equal(t, "deny-egress", created[1].Name)
+
+	// No template, no objects.
+	require.NoError(t, createFromTemplate(home, "", data, nil, nil))
+
+	// Templates referring to unknown fields fail.
+	require.NoError(t, ioutil.WriteFile(filepath.Join(home, "bad.yaml"), []byte("name: {{ .Nope }}"), 0644))
+	require.Error(t, createFromTemplate(home, "bad.yaml", data, func() interface{} { return &networkingv1.NetworkPolicy{} }, func(interface{}) error { return nil }))
+}
@@ -0,0 +1,228 @@
+package runner
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/testground/testground/pkg/api"
+	"github.com/testground/testground/pkg/rpc"
+)
+
+// k8sUsageInterval is how often the usage of the pods of a run is sampled.
+const k8sUsageInterval = 15 * time.Second
+
+// kubeletSummary is the part of the summary of the statistics of a node, as
+// served by its kubelet, that accounts for the usage of its pods.
+type kubeletSummary struct {
+	Pods []struct {
+		PodRef struct {
+			Name      string `json:"name"`
+			Namespace string `json:"namespace"`
+		} `json:"podRef"`
+		CPU *struct {
+			UsageCoreNanoSeconds uint64 `json:"usageCoreNanoSeconds"`
+		} `json:"cpu"`
+		Memory *struct {
+			WorkingSetBytes uint64 `json:"workingSetBytes"`
+		} `json:"memory"`
+		Network *struct {
+			RxBytes uint64 `json:"rxBytes"`
+			TxBytes uint64 `json:"txBytes"`
+		} `json:"network"`
+	} `json:"pods"`
+}
+
+// k8sUsage accounts for the resources the pods of a run use, by sampling the
+// statistics the kubelets of their nodes serve.
+type k8sUsage struct {
+	runner    *ClusterK8sRunner
+	input     *api.RunInput
+	namespace string
+	log       *rpc.OutputWriter
+	recorder  *usageRecorder
+
+	cancel context.CancelFunc
+	done   chan struct{}
+
+	lk            sync.Mutex
+	instanceTypes map[string]string
+}
+
+// newK8sUsage starts sampling the usage of the pods of a run.
+func newK8sUsage(ctx context.Context, c *ClusterK8sRunner, input *api.RunInput, namespace string, log *rpc.OutputWriter) *k8sUsage {
+	ctx, cancel := context.WithCancel(ctx)
+	u := &k8sUsage{
+		runner:        c,
+		input:         input,
+		namespace:     namespace,
+		log:           log,
+		recorder:      newUsageRecorder(),
+		cancel:        cancel,
+		done:          make(chan struct{}),
+		instanceTypes: make(map[string]string),
+	}
+
+	go func() {
+		defer close(u.done)
+
+		ticker := time.NewTicker(k8sUsageInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+			if err := u.sample(ctx); err != nil && ctx.Err() == nil {
+				u.log.Debugw("failed to sample the usage of the pods", "err", err)
+			}
+		}
+	}()
+	return u
+}
+
+func (u *k8sUsage) pods(ctx context.Context) ([]v1.Pod, error) {
+	client := u.runner.pool.Acquire()
+	defer u.runner.pool.Release(client)
+
+	pods, err := client.CoreV1().Pods(u.namespace).List(ctx, metav1.ListOptions{
+		LabelSelector: fmt.Sprintf("testground.purpose=plan,testground.run_id=%s", u.input.RunID),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return pods.Items, nil
+}
+
+// sample records the statistics of the pods of the run, from the kubelets of
+// the nodes they're scheduled on.
+func (u *k8sUsage) sample(ctx context.Context) error {
+	pods, err := u.pods(ctx)
+	if err != nil {
+		return err
+	}
+
+	groups := make(map[string]string, len(pods))
+	nodes := make(map[string]bool)
+	for _, p := range pods {
+		groups[p.Name] = p.Labels["testground.groupid"]
+		if p.Spec.NodeName != "" {
+			nodes[p.Spec.NodeName] = true
+		}
+	}
+
+	client := u.runner.pool.Acquire()
+	defer u.runner.pool.Release(client)
+
+	for node := range nodes {
+		b, err := client.CoreV1().RESTClient().Get().
+			AbsPath("/api/v1/nodes", node, "proxy", "stats", "summary").
+			DoRaw(ctx)
+		if err != nil {
+			u.log.Debugw("failed to get the statistics of the node", "node", node, "err", err)
+			continue
+		}
+
+		var summary kubeletSummary
+		if err := json.Unmarshal(b, &summary); err != nil {
+			u.log.Debugw("failed to decode the statistics of the node", "node", node, "err", err)
+			continue
+		}
+
+		for _, p := range summary.Pods {
+			group, ok := groups[p.PodRef.Name]
+			if !ok || p.PodRef.Namespace != u.namespace {
+				continue
+			}
+			var (
+				cpu            float64
+				memory, rx, tx uint64
+			)
+			if p.CPU != nil {
+				cpu = float64(p.CPU.UsageCoreNanoSeconds) / float64(time.Second)
+			}
+			if p.Memory != nil {
+				memory = p.Memory.WorkingSetBytes
+			}
+			if p.Network != nil {
+				rx, tx = p.Network.RxBytes, p.Network.TxBytes
+			}
+			u.recorder.sample(p.PodRef.Name, group, cpu, memory, rx, tx)
+		}
+	}
+	return nil
+}
+
+// instanceType returns the instance type of a node, from its well-known
+// label.
+func (u *k8sUsage) instanceType(ctx context.Context, node string) string {
+	u.lk.Lock()
+	defer u.lk.Unlock()
+
+	if typ, ok := u.instanceTypes[node]; ok {
+		return typ
+	}
+
+	client := u.runner.pool.Acquire()
+	defer u.runner.pool.Release(client)
+
+	n, err := client.CoreV1().Nodes().Get(ctx, node, metav1.GetOptions{})
+	if err != nil {
+		return ""
+	}
+	typ := n.Labels[v1.LabelInstanceTypeStable]
+	if typ == "" {
+		typ = n.Labels[v1.LabelInstanceType]
+	}
+	u.instanceTypes[node] = typ
+	return typ
+}
+
+// collect stops sampling, takes a last sample, and returns the usage of the
+// pods. The node and instance type of every pod, and how long it ran, come
+// from the pods, so collect must be called before they are deleted.
+func (u *k8sUsage) collect(ctx context.Context) *Usage {
+	u.cancel()
+	<-u.done
+
+	if err := u.sample(ctx); err != nil {
+		u.log.Debugw("failed to sample the usage of the pods", "err", err)
+	}
+
+	pods, err := u.pods(ctx)
+	if err != nil {
+		u.log.Debugw("failed to list the pods", "err", err)
+		return u.recorder.usage()
+	}
+
+	for _, p := range pods {
+		var seconds float64
+		for _, st := range p.Status.ContainerStatuses {
+			switch {
+			case st.State.Terminated != nil:
+				seconds = maxFloat(seconds, st.State.Terminated.FinishedAt.Sub(st.State.Terminated.StartedAt.Time).Seconds())
+			case st.State.Running != nil:
+				seconds = maxFloat(seconds, time.Since(st.State.Running.StartedAt.Time).Seconds())
+			}
+		}
+
+		var typ string
+		if p.Spec.NodeName != "" {
+			typ = u.instanceType(ctx, p.Spec.NodeName)
+		}
+
+		u.recorder.update(p.Name, p.Labels["testground.groupid"], func(i *InstanceUsage) {
+			i.Seconds = seconds
+			i.Node = p.Spec.NodeName
+			i.InstanceType = typ
+		})
+	}
+	return u.recorder.usage()
+}
@@ -0,0 +1,74 @@
+package runner
+
+import (
+	"fmt"
+	"path"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+
+	"github.com/testground/testground/pkg/api"
+)
+
+// The volume snapshots of cluster:k8s runs live on the shared filesystem, as
+// snapshots/<run id>/<group id>/<group index>. The volumes of groups that are
+// snapshotted are mounted from there directly; the others are ephemeral, and
+// disappear with their pod.
+
+const instanceVolumeName = "instance-volume"
+
+func k8sSnapshotPath(runID string, group string, idx int) string {
+	return path.Join("snapshots", runID, group, fmt.Sprint(idx))
+}
+
+// instanceVolume returns the volume of the i-th instance of a group, its
+// mount in the instance, and the init container restoring it from a
+// snapshot, if any.
+func instanceVolume(runID string, g *api.RunGroup, i int, sharedVolumeName string) (*v1.Volume, v1.VolumeMount, *v1.Container, error) {
+	v := g.Volume
+
+	var (
+		vol   *v1.Volume
+		mount v1.VolumeMount
+	)
+	if v.Snapshot {
+		mount = v1.VolumeMount{Name: sharedVolumeName, MountPath: v.Path, SubPath: k8sSnapshotPath(runID, g.ID, i)}
+	} else {
+		vol = &v1.Volume{Name: instanceVolumeName, VolumeSource: v1.VolumeSource{EmptyDir: &v1.EmptyDirVolumeSource{}}}
+		mount = v1.VolumeMount{Name: instanceVolumeName, MountPath: v.Path}
+	}
+
+	if v.FromSnapshot == "" {
+		return vol, mount, nil, nil
+	}
+
+	ref, err := api.ParseSnapshotRef(v.FromSnapshot)
+	if err != nil {
+		return nil, mount, nil, err
+	}
+
+	// The init container sees the volume at /volume, and the snapshots on
+	// the shared filesystem at /efs.
+	restoreMount := mount
+	restoreMount.MountPath = "/volume"
+	src := path.Join("/efs", k8sSnapshotPath(ref.Task, ref.Group, i))
+
+	restore := &v1.Container{
+		Name:            "restore-volume",
+		Image:           "busybox",
+		ImagePullPolicy: v1.PullIfNotPresent,
+		Args:            []string{"-c", fmt.Sprintf("if [ -d %[1]s ]; then cp -a %[1]s/. /volume/; else echo \"no snapshot %[1]s; starting with an empty volume\"; fi", src)},
+		Command:         []string{"sh"},
+		VolumeMounts: []v1.VolumeMount{
+			restoreMount,
+			{Name: sharedVolumeName, MountPath: "/efs", ReadOnly: true},
+		},
+		Resources: v1.ResourceRequirements{
+			Limits: v1.ResourceList{
+				v1.ResourceMemory: resource.MustParse("100Mi"),
+				v1.ResourceCPU:    resource.MustParse("100m"),
+			},
+		},
+	}
+	return vol, mount, restore, nil
+}
@@ -76,6 +76,10 @@ func (*ClusterSwarmRunner) Run(ctx context.Context, input *api.RunInput, ow *rpc
 		cfg = *input.RunnerConfig.(*ClusterSwarmRunnerConfig)
 	)
 
+	if err := checkIPv4Only("cluster:swarm", input.IPFamily); err != nil {
+		return nil, err
+	}
+
 	// global timeout of 1 minute for the scheduling.
 	ctx, cancelFn := context.WithTimeout(ctx, 1*time.Minute)
 	defer cancelFn()
@@ -201,6 +205,8 @@ func (*ClusterSwarmRunner) Run(ctx context.Context, input *api.RunInput, ow *rpc
 
 		// Serialize the runenv into env variables to pass to docker.
 		env := conv.ToOptionsSlice(runenv.ToEnvVars())
+		env = append(env, conv.ToOptionsSlice(runtimeAPIEnv(input))...)
+		env = append(env, conv.ToOptionsSlice(g.Env)...)
 
 		// Set the log level if provided in cfg.
 		if cfg.LogLevel != "" {
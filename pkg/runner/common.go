@@ -10,6 +10,7 @@ import (
 	"net"
 	"os"
 	"path/filepath"
+	"strconv"
 
 	"github.com/testground/testground/pkg/api"
 	"github.com/testground/testground/pkg/rpc"
@@ -115,9 +116,19 @@ func gzipRunOutputs(ctx context.Context, basedir string, input *api.CollectionIn
 	return nil
 }
 
-func reviewResources(group *api.RunGroup, ow *rpc.OutputWriter) {
+func reviewResources(ctx context.Context, group *api.RunGroup, ow *rpc.OutputWriter) {
 	log := ow.With("group_id", group.ID)
 	if group.Resources.CPU != "" || group.Resources.Memory != "" {
 		log.Warnw("group has resources set. Note that resources requirement and limits are ignored by this runner.")
+		api.RecordDecision(ctx, api.DecisionStageLimits, "resources of group %s (cpu: %q, memory: %q) ignored by this runner", group.ID, group.Resources.CPU, group.Resources.Memory)
 	}
 }
+
+// runtimeAPIEnv returns the environment variable telling instances the version
+// of the runtime API of their environment, for their SDK to check it.
+func runtimeAPIEnv(input *api.RunInput) map[string]string {
+	if input.RuntimeAPI == 0 {
+		return nil
+	}
+	return map[string]string{api.EnvTestRuntimeAPI: strconv.Itoa(input.RuntimeAPI)}
+}
@@ -0,0 +1,37 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/docker/docker/client"
+
+	"github.com/testground/testground/pkg/api"
+)
+
+// checkImageArchitectures verifies that the images of the groups of a run were
+// built for the given CPU architecture, so that a run targeting nodes of
+// another architecture is rejected before any instance is scheduled. Images
+// that are not known to the local docker daemon are not checked.
+func checkImageArchitectures(ctx context.Context, cli *client.Client, groups []*api.RunGroup, arch string) error {
+	checked := make(map[string]struct{}, len(groups))
+	for _, g := range groups {
+		if _, ok := checked[g.ArtifactPath]; ok {
+			continue
+		}
+		checked[g.ArtifactPath] = struct{}{}
+
+		img, _, err := cli.ImageInspectWithRaw(ctx, g.ArtifactPath)
+		if client.IsErrNotFound(err) {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("failed to inspect image %s of group %s: %w", g.ArtifactPath, g.ID, err)
+		}
+
+		if img.Architecture != arch {
+			return fmt.Errorf("image %s of group %s was built for %s, but the run targets %s; set the `platform` build configuration to linux/%s", g.ArtifactPath, g.ID, img.Architecture, arch, arch)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,78 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/hashicorp/go-multierror"
+
+	"github.com/testground/testground/pkg/api"
+	"github.com/testground/testground/pkg/task"
+)
+
+// diagnosticsTimeout bounds the capture of the diagnostics of an instance.
+// Runs that time out are diagnosed too, so it doesn't derive from the run
+// context.
+const diagnosticsTimeout = 30 * time.Second
+
+// diagnosticsDir is the directory of the outputs of an instance the
+// diagnostics are written to.
+const diagnosticsDir = "diagnostics"
+
+// diagnosticsLogFile is the file of the diagnostics directory of an instance
+// the last lines of its logs are written to.
+const diagnosticsLogFile = "logs.txt"
+
+// instanceDiagnostics is how the diagnostics of an instance are captured by
+// a runner.
+type instanceDiagnostics struct {
+	// running tells if the instance is still running, and serves profiles.
+	running bool
+
+	// profile fetches the response of the instance to a request of
+	// net/http/pprof.
+	profile func(ctx context.Context, port int, urlPath string) ([]byte, error)
+
+	// logs fetches the last lines of the logs of the instance.
+	logs func(ctx context.Context, lines int) ([]byte, error)
+
+	// write writes a file to the diagnostics directory of the instance.
+	write func(name string, data []byte) error
+}
+
+// captureDiagnostics captures the profiles of an instance if it's still
+// running, and the last lines of its logs. It captures as much as it can,
+// and returns all the errors it ran into.
+func captureDiagnostics(d *api.Diagnostics, in instanceDiagnostics) error {
+	ctx, cancel := context.WithTimeout(context.Background(), diagnosticsTimeout)
+	defer cancel()
+
+	var merr *multierror.Error
+	if in.running {
+		for _, p := range d.ProfileNames() {
+			data, err := in.profile(ctx, d.Port(), api.ProfileURLPath(p))
+			if err == nil {
+				err = in.write(api.ProfileFile(p), data)
+			}
+			if err != nil {
+				merr = multierror.Append(merr, fmt.Errorf("failed to capture the %s profile: %w", p, err))
+			}
+		}
+	}
+
+	data, err := in.logs(ctx, d.LogTail())
+	if err == nil {
+		err = in.write(diagnosticsLogFile, data)
+	}
+	if err != nil {
+		merr = multierror.Append(merr, fmt.Errorf("failed to capture the logs: %w", err))
+	}
+	return merr.ErrorOrNil()
+}
+
+// diagnosedRun tells if the instances of a run are diagnosed: if the run
+// failed or timed out, rather than succeeded or was canceled.
+func diagnosedRun(ctx context.Context, result *Result, err error) bool {
+	return ctx.Err() != context.Canceled && (err != nil || result.Outcome != task.OutcomeSuccess)
+}
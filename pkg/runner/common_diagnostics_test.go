@@ -0,0 +1,70 @@
+package runner
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/testground/testground/pkg/api"
+	"github.com/testground/testground/pkg/task"
+)
+
+func TestCaptureDiagnostics(t *testing.T) {
+	var paths []string
+	files := make(map[string]string)
+	in := instanceDiagnostics{
+		running: true,
+		profile: func(_ context.Context, port int, urlPath string) ([]byte, error) {
+			require.Equal(t, 7070, port)
+			paths = append(paths, urlPath)
+			if urlPath == "/debug/pprof/mutex" {
+				return nil, errors.New("unexpected status: 404 Not Found")
+			}
+			return []byte(urlPath), nil
+		},
+		logs: func(_ context.Context, lines int) ([]byte, error) {
+			require.Equal(t, api.DefaultDiagnosticsLogLines, lines)
+			return []byte("panic: boom"), nil
+		},
+		write: func(name string, data []byte) error {
+			files[name] = string(data)
+			return nil
+		},
+	}
+
+	// The diagnostics captured despite a failed profile are kept.
+	d := &api.Diagnostics{Profiles: []string{"goroutine", "mutex", "heap"}, PprofPort: 7070}
+	err := captureDiagnostics(d, in)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "mutex")
+	require.Equal(t, []string{"/debug/pprof/goroutine?debug=2", "/debug/pprof/mutex", "/debug/pprof/heap"}, paths)
+	require.Equal(t, map[string]string{
+		"goroutine.txt":    "/debug/pprof/goroutine?debug=2",
+		"heap.pprof":       "/debug/pprof/heap",
+		diagnosticsLogFile: "panic: boom",
+	}, files)
+
+	// Only the logs of instances that exited are captured.
+	paths, files = nil, make(map[string]string)
+	in.running = false
+	require.NoError(t, captureDiagnostics(d, in))
+	require.Empty(t, paths)
+	require.Equal(t, map[string]string{diagnosticsLogFile: "panic: boom"}, files)
+}
+
+func TestDiagnosedRun(t *testing.T) {
+	ctx := context.Background()
+	require.False(t, diagnosedRun(ctx, &Result{Outcome: task.OutcomeSuccess}, nil))
+	require.True(t, diagnosedRun(ctx, &Result{Outcome: task.OutcomeFailure}, nil))
+	require.True(t, diagnosedRun(ctx, &Result{Outcome: task.OutcomeSuccess}, errors.New("failed")))
+
+	timedOut, cancel := context.WithTimeout(ctx, 0)
+	defer cancel()
+	require.True(t, diagnosedRun(timedOut, &Result{}, timedOut.Err()))
+
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	require.False(t, diagnosedRun(canceled, &Result{}, canceled.Err()))
+}
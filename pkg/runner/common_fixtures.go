@@ -0,0 +1,69 @@
+package runner
+
+import (
+	"context"
+	"strings"
+
+	"github.com/docker/docker/api/types/mount"
+
+	"github.com/testground/testground/pkg/api"
+	"github.com/testground/testground/pkg/fixtures"
+	"github.com/testground/testground/pkg/rpc"
+)
+
+// EnvTestFixturePrefix prefixes the environment variables set on the
+// instances of a run to the paths of its fixtures, e.g. TEST_FIXTURE_CHAIN
+// for the fixture named chain.
+const EnvTestFixturePrefix = "TEST_FIXTURE_"
+
+func fixtureEnvName(f *api.Fixture) string {
+	return EnvTestFixturePrefix + strings.ToUpper(f.Name)
+}
+
+func fixtureEnvVar(f *api.Fixture, path string) string {
+	return fixtureEnvName(f) + "=" + path
+}
+
+// fetchFixtures fetches the fixtures of a run, unless they are cached, and
+// returns the directories of the host holding them, by fixture name. In
+// offline mode, fixtures are only taken from the cache.
+func fetchFixtures(ctx context.Context, input *api.RunInput, ow *rpc.OutputWriter) (map[string]string, error) {
+	var (
+		dirs    = make(map[string]string, len(input.Fixtures))
+		cache   = input.EnvConfig.Dirs().Fixtures()
+		offline = input.EnvConfig.Daemon.Offline.Enabled
+	)
+	for _, f := range input.Fixtures {
+		var (
+			dir string
+			err error
+		)
+		if offline {
+			dir, err = fixtures.Cached(cache, f)
+		} else {
+			dir, err = fixtures.Fetch(ctx, cache, f, ow)
+		}
+		if err != nil {
+			return nil, err
+		}
+		dirs[f.Name] = dir
+	}
+	return dirs, nil
+}
+
+// fixtureMounts returns the environment variables and the read-only bind
+// mounts exposing fetched fixtures to containers.
+func fixtureMounts(fs []*api.Fixture, dirs map[string]string) ([]string, []mount.Mount) {
+	env := make([]string, 0, len(fs))
+	mounts := make([]mount.Mount, 0, len(fs))
+	for _, f := range fs {
+		env = append(env, fixtureEnvVar(f, f.MountPath()))
+		mounts = append(mounts, mount.Mount{
+			Type:     mount.TypeBind,
+			Source:   dirs[f.Name],
+			Target:   f.MountPath(),
+			ReadOnly: true,
+		})
+	}
+	return env, mounts
+}
@@ -0,0 +1,73 @@
+package runner
+
+import (
+	"testing"
+
+	"github.com/docker/docker/api/types/mount"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+
+	"github.com/testground/testground/pkg/api"
+	"github.com/testground/testground/pkg/fixtures"
+)
+
+func TestFixtureMounts(t *testing.T) {
+	fs := []*api.Fixture{
+		{Name: "chain", Source: "https://example.com/chain.tar.gz"},
+		{Name: "keys", Source: "/data/keys", Path: "/etc/keys"},
+	}
+	env, mounts := fixtureMounts(fs, map[string]string{"chain": "/cache/abc", "keys": "/data/keys"})
+
+	require.Equal(t, []string{"TEST_FIXTURE_CHAIN=/fixtures/chain", "TEST_FIXTURE_KEYS=/etc/keys"}, env)
+	require.Equal(t, []mount.Mount{
+		{Type: mount.TypeBind, Source: "/cache/abc", Target: "/fixtures/chain", ReadOnly: true},
+		{Type: mount.TypeBind, Source: "/data/keys", Target: "/etc/keys", ReadOnly: true},
+	}, mounts)
+}
+
+func TestK8sFixtures(t *testing.T) {
+	tarball := &api.Fixture{Name: "chain_db", Source: "https://example.com/chain.tar.gz", SHA256: "ab"}
+	image := &api.Fixture{Name: "keys", Source: "oci://ghcr.io/org/keys:v1"}
+
+	pod, err := fixturesPod("run1", []*api.Fixture{tarball, image}, "efs-shared")
+	require.NoError(t, err)
+	require.Equal(t, "tg-fixtures-run1", pod.Name)
+
+	// The tools are copied first, then every fixture is fetched.
+	inits := pod.Spec.InitContainers
+	require.Len(t, inits, 3)
+	require.Equal(t, "tools", inits[0].Name)
+
+	envOf := func(c v1.Container) map[string]string {
+		m := make(map[string]string)
+		for _, e := range c.Env {
+			m[e.Name] = e.Value
+		}
+		return m
+	}
+
+	require.Equal(t, "fetch-chain-db", inits[1].Name)
+	require.Equal(t, "busybox", inits[1].Image)
+	require.Equal(t, map[string]string{
+		"FIXTURE_DIR":    "/efs/fixtures/" + fixtures.CacheKey(tarball),
+		"FIXTURE_RUN":    "run1",
+		"FIXTURE_URL":    tarball.Source,
+		"FIXTURE_SHA256": "ab",
+		"BB":             "/tools/busybox",
+	}, envOf(inits[1]))
+
+	// Images holding nothing but data fetch with the copied busybox.
+	require.Equal(t, "ghcr.io/org/keys:v1", inits[2].Image)
+	require.Equal(t, "/tools/busybox", inits[2].Command[0])
+	require.Equal(t, api.DefaultFixtureSourcePath, envOf(inits[2])["FIXTURE_SRC"])
+
+	mounts := fixtureVolumeMounts([]*api.Fixture{tarball, image}, "efs-shared")
+	require.Equal(t, []v1.VolumeMount{
+		{Name: "efs-shared", MountPath: "/fixtures/chain_db", SubPath: "fixtures/" + fixtures.CacheKey(tarball), ReadOnly: true},
+		{Name: "efs-shared", MountPath: "/fixtures/keys", SubPath: "fixtures/" + fixtures.CacheKey(image), ReadOnly: true},
+	}, mounts)
+
+	// Local directories of the daemon host aren't reachable from the cluster.
+	_, err = fixturesPod("run1", []*api.Fixture{{Name: "local", Source: "/data/local"}}, "efs-shared")
+	require.Error(t, err)
+}
@@ -0,0 +1,141 @@
+package runner
+
+import (
+	"encoding/json"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/testground/testground/pkg/sidecar"
+)
+
+// LatencyHeatmapFile is the name of the file, at the root of the run outputs,
+// holding the latency heatmap of a run.
+const LatencyHeatmapFile = "latency_heatmap.json"
+
+// LatencyHeatmapConfig configures the pairwise latency measurements the sidecar
+// performs between instances during a run.
+type LatencyHeatmapConfig struct {
+	// Enabled turns on the latency measurements (default: false).
+	Enabled bool `toml:"enabled"`
+	// IntervalSecs is the interval between two rounds of probes, in seconds
+	// (default: 10).
+	IntervalSecs int `toml:"interval_secs"`
+	// SampleSize is the number of instances probing each other (default: 10).
+	SampleSize int `toml:"sample_size"`
+}
+
+// EnvVars returns the environment variables to pass to test instances in
+// order for the sidecar to measure latencies on their behalf.
+func (c LatencyHeatmapConfig) EnvVars() []string {
+	interval, size := time.Duration(c.IntervalSecs)*time.Second, c.SampleSize
+	if interval <= 0 {
+		interval = sidecar.DefaultLatencyHeatmapInterval
+	}
+	if size <= 0 {
+		size = sidecar.DefaultLatencyHeatmapSampleSize
+	}
+	return []string{
+		sidecar.EnvLatencyHeatmapInterval + "=" + interval.String(),
+		sidecar.EnvLatencyHeatmapSampleSize + "=" + strconv.Itoa(size),
+	}
+}
+
+// LatencyHeatmap is the pairwise latency matrix of the sampled instances of a
+// run. Cells are indexed [from][to], in the order of Instances. Latencies are
+// in milliseconds, and are -1 for pairs without a successful measurement.
+type LatencyHeatmap struct {
+	Start     time.Time   `json:"start"`
+	End       time.Time   `json:"end"`
+	Instances []string    `json:"instances"`
+	Samples   int         `json:"samples"`
+	MeanRTT   [][]float64 `json:"mean_rtt_ms"`
+	MaxRTT    [][]float64 `json:"max_rtt_ms"`
+	Loss      [][]float64 `json:"loss"`
+}
+
+// newLatencyHeatmap aggregates the latency samples of a run into a heatmap.
+func newLatencyHeatmap(samples []*sidecar.LatencySample) *LatencyHeatmap {
+	hm := &LatencyHeatmap{Samples: len(samples)}
+
+	index := make(map[string]int)
+	for _, s := range samples {
+		index[s.From] = 0
+		index[s.To] = 0
+	}
+	for name := range index {
+		hm.Instances = append(hm.Instances, name)
+	}
+	sort.Strings(hm.Instances)
+	for i, name := range hm.Instances {
+		index[name] = i
+	}
+
+	n := len(hm.Instances)
+	var (
+		sum   = matrix(n, 0)
+		ok    = matrix(n, 0)
+		total = matrix(n, 0)
+	)
+	hm.MeanRTT, hm.MaxRTT, hm.Loss = matrix(n, -1), matrix(n, -1), matrix(n, 0)
+
+	for _, s := range samples {
+		if hm.Start.IsZero() || s.Time.Before(hm.Start) {
+			hm.Start = s.Time
+		}
+		if s.Time.After(hm.End) {
+			hm.End = s.Time
+		}
+
+		from, to := index[s.From], index[s.To]
+		total[from][to]++
+		if s.Lost {
+			continue
+		}
+
+		rtt := float64(s.RTT) / float64(time.Millisecond)
+		ok[from][to]++
+		sum[from][to] += rtt
+		if rtt > hm.MaxRTT[from][to] {
+			hm.MaxRTT[from][to] = rtt
+		}
+	}
+
+	for i := 0; i < n; i++ {
+		for j := 0; j < n; j++ {
+			if ok[i][j] > 0 {
+				hm.MeanRTT[i][j] = sum[i][j] / ok[i][j]
+			}
+			if total[i][j] > 0 {
+				hm.Loss[i][j] = (total[i][j] - ok[i][j]) / total[i][j]
+			}
+		}
+	}
+
+	return hm
+}
+
+// writeLatencyHeatmap aggregates the samples and writes the heatmap to path.
+func writeLatencyHeatmap(path string, samples []*sidecar.LatencySample) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	enc := json.NewEncoder(f)
+	enc.SetIndent("", "  ")
+	return enc.Encode(newLatencyHeatmap(samples))
+}
+
+func matrix(n int, v float64) [][]float64 {
+	m := make([][]float64, n)
+	for i := range m {
+		m[i] = make([]float64, n)
+		for j := range m[i] {
+			m[i][j] = v
+		}
+	}
+	return m
+}
@@ -0,0 +1,46 @@
+package runner
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/testground/testground/pkg/sidecar"
+)
+
+func TestNewLatencyHeatmap(t *testing.T) {
+	now := time.Now()
+	samples := []*sidecar.LatencySample{
+		{Time: now, From: "b/2", To: "a/1", RTT: 10 * time.Millisecond},
+		{Time: now.Add(time.Second), From: "b/2", To: "a/1", RTT: 20 * time.Millisecond},
+		{Time: now.Add(2 * time.Second), From: "b/2", To: "a/1", Lost: true},
+		{Time: now.Add(-time.Second), From: "a/1", To: "b/2", Lost: true},
+	}
+
+	hm := newLatencyHeatmap(samples)
+
+	require.Equal(t, []string{"a/1", "b/2"}, hm.Instances)
+	require.Equal(t, 4, hm.Samples)
+	require.Equal(t, now.Add(-time.Second), hm.Start)
+	require.Equal(t, now.Add(2*time.Second), hm.End)
+
+	require.Equal(t, [][]float64{{-1, -1}, {15, -1}}, hm.MeanRTT)
+	require.Equal(t, [][]float64{{-1, -1}, {20, -1}}, hm.MaxRTT)
+	require.InDelta(t, 1.0/3, hm.Loss[1][0], 1e-9)
+	require.Equal(t, 1.0, hm.Loss[0][1])
+	require.Equal(t, 0.0, hm.Loss[0][0])
+}
+
+func TestLatencyHeatmapConfigEnvVars(t *testing.T) {
+	var cfg LatencyHeatmapConfig
+	require.Equal(t, []string{"LATENCY_HEATMAP_INTERVAL=10s", "LATENCY_HEATMAP_SAMPLE_SIZE=10"}, cfg.EnvVars())
+
+	parsed, err := sidecar.ParseLatencyHeatmapConfig(LatencyHeatmapConfig{IntervalSecs: 1, SampleSize: 3}.EnvVars())
+	require.NoError(t, err)
+	require.Equal(t, &sidecar.LatencyHeatmapConfig{Interval: time.Second, SampleSize: 3}, parsed)
+
+	parsed, err = sidecar.ParseLatencyHeatmapConfig([]string{"FOO=bar"})
+	require.NoError(t, err)
+	require.Nil(t, parsed)
+}
@@ -0,0 +1,44 @@
+package runner
+
+import (
+	"fmt"
+	"net"
+
+	"github.com/testground/testground/pkg/api"
+	"github.com/testground/testground/pkg/sidecar"
+)
+
+// dataNetworkV6 returns the IPv6 subnet, and gateway, paired with an IPv4
+// data subnet: a /64 of the unique local fd74:6700::/32 block, numbered
+// after the first two bytes of the IPv4 subnet.
+func dataNetworkV6(subnet *net.IPNet) (*net.IPNet, string, error) {
+	ip := subnet.IP.To4()
+	if ip == nil {
+		return nil, "", fmt.Errorf("%s is not an IPv4 subnet", subnet)
+	}
+
+	sn := fmt.Sprintf("fd74:6700:%x:%x::/64", ip[0], ip[1])
+	gw := fmt.Sprintf("fd74:6700:%x:%x::1", ip[0], ip[1])
+
+	_, subnet6, err := net.ParseCIDR(sn)
+	return subnet6, gw, err
+}
+
+// ipFamilyEnv returns the environment variables telling test instances, and
+// the sidecar, the IP family of the data network, and its IPv6 subnet.
+func ipFamilyEnv(family api.IPFamily, subnet6 *net.IPNet) map[string]string {
+	env := map[string]string{sidecar.EnvTestIPFamily: string(family)}
+	if family.HasIPv6() {
+		env[sidecar.EnvTestSubnetV6] = subnet6.String()
+	}
+	return env
+}
+
+// checkIPv4Only fails runs with an IPv6 data network, for runners that only
+// support IPv4 ones.
+func checkIPv4Only(runner string, family api.IPFamily) error {
+	if family.HasIPv6() {
+		return fmt.Errorf("runner %s does not support %s data networks", runner, family)
+	}
+	return nil
+}
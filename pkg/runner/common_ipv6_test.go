@@ -0,0 +1,53 @@
+package runner
+
+import (
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/testground/testground/pkg/api"
+	"github.com/testground/testground/pkg/conv"
+	"github.com/testground/testground/pkg/sidecar"
+)
+
+func TestDataNetworkV6(t *testing.T) {
+	subnet, _, err := nextDataNetwork(300)
+	require.NoError(t, err)
+
+	subnet6, gw, err := dataNetworkV6(subnet)
+	require.NoError(t, err)
+	require.Equal(t, "fd74:6700:11:2c::/64", subnet6.String())
+	require.Equal(t, "fd74:6700:11:2c::1", gw)
+	require.True(t, subnet6.Contains(net.ParseIP(gw)))
+
+	// Distinct IPv4 subnets get distinct IPv6 ones.
+	other, _, err := nextDataNetwork(301)
+	require.NoError(t, err)
+	other6, _, err := dataNetworkV6(other)
+	require.NoError(t, err)
+	require.NotEqual(t, subnet6.String(), other6.String())
+
+	_, err = sidecar.ParseIPv6Config(conv.ToOptionsSlice(ipFamilyEnv(api.IPv6, subnet6)))
+	require.NoError(t, err)
+}
+
+func TestIPFamilyEnv(t *testing.T) {
+	_, subnet6, _ := net.ParseCIDR("fd74:6700:10:0::/64")
+
+	cfg, err := sidecar.ParseIPv6Config(conv.ToOptionsSlice(ipFamilyEnv(api.IPv4, subnet6)))
+	require.NoError(t, err)
+	require.Nil(t, cfg)
+
+	cfg, err = sidecar.ParseIPv6Config(conv.ToOptionsSlice(ipFamilyEnv(api.DualStack, subnet6)))
+	require.NoError(t, err)
+	require.Equal(t, subnet6, cfg.Subnet)
+	require.False(t, cfg.Only)
+
+	cfg, err = sidecar.ParseIPv6Config(conv.ToOptionsSlice(ipFamilyEnv(api.IPv6, subnet6)))
+	require.NoError(t, err)
+	require.True(t, cfg.Only)
+
+	require.Error(t, checkIPv4Only("local:exec", api.DualStack))
+	require.NoError(t, checkIPv4Only("local:exec", api.IPv4))
+}
@@ -0,0 +1,93 @@
+package runner
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"sort"
+
+	"github.com/testground/testground/pkg/api"
+)
+
+// NetworkTimelineFile is the name of the file, at the root of the run outputs,
+// holding the changes the sidecar applied to the data network of the
+// instances during the run, whether requested by the test plan or injected
+// as faults.
+const NetworkTimelineFile = "network_timeline.json"
+
+// awaitNetworkEvents collects the network events recording the given fault
+// from ch, until expected instances applied it or the context is done.
+func awaitNetworkEvents(ctx context.Context, ch <-chan *api.NetworkEvent, faultID string, expected int) []*api.NetworkEvent {
+	var (
+		events = make([]*api.NetworkEvent, 0, expected)
+		seen   = make(map[string]struct{}, expected)
+	)
+	for len(events) < expected {
+		select {
+		case ev := <-ch:
+			if ev.FaultID != faultID {
+				continue
+			}
+			if _, ok := seen[ev.Instance]; ok {
+				continue
+			}
+			seen[ev.Instance] = struct{}{}
+			events = append(events, ev)
+		case <-ctx.Done():
+			return events
+		}
+	}
+	return events
+}
+
+// resolveBlackholes replaces the names of instances among the blackholed
+// peers of a fault with their data network address. Other peers must be
+// addresses or subnets.
+func resolveBlackholes(peers []string, instances []*api.Instance) ([]string, error) {
+	if len(peers) == 0 {
+		return nil, nil
+	}
+
+	byName := make(map[string]*api.Instance, len(instances))
+	for _, inst := range instances {
+		byName[inst.Name] = inst
+	}
+
+	resolved := make([]string, 0, len(peers))
+	for _, p := range peers {
+		if inst, ok := byName[p]; ok {
+			if inst.DataIP == "" {
+				return nil, fmt.Errorf("instance %s has no data network address", p)
+			}
+			resolved = append(resolved, inst.DataIP)
+			continue
+		}
+		if _, _, err := net.ParseCIDR(p); err == nil {
+			resolved = append(resolved, p)
+			continue
+		}
+		if net.ParseIP(p) == nil {
+			return nil, fmt.Errorf("invalid blackhole %q: not an instance, an IP address or a subnet", p)
+		}
+		resolved = append(resolved, p)
+	}
+	return resolved, nil
+}
+
+// writeNetworkTimeline writes the network events of a run to path, in
+// chronological order.
+func writeNetworkTimeline(path string, events []*api.NetworkEvent) error {
+	sort.SliceStable(events, func(i, j int) bool { return events[i].Time.Before(events[j].Time) })
+
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	enc := json.NewEncoder(f)
+	enc.SetIndent("", "  ")
+	return enc.Encode(events)
+}
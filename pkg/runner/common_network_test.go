@@ -0,0 +1,41 @@
+package runner
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/testground/testground/pkg/api"
+)
+
+func TestAwaitNetworkEvents(t *testing.T) {
+	ch := make(chan *api.NetworkEvent, 4)
+	ch <- &api.NetworkEvent{Source: api.NetworkEventPlan, Instance: "a/1"}
+	ch <- &api.NetworkEvent{FaultID: "fault", Instance: "a/1"}
+	ch <- &api.NetworkEvent{FaultID: "fault", Instance: "a/1"}
+	ch <- &api.NetworkEvent{FaultID: "fault", Instance: "a/2", Error: "no such link"}
+
+	events := awaitNetworkEvents(context.Background(), ch, "fault", 2)
+	require.Len(t, events, 2)
+	require.Equal(t, "a/1", events[0].Instance)
+	require.Equal(t, "a/2", events[1].Instance)
+	require.Equal(t, "no such link", events[1].Error)
+}
+
+func TestResolveBlackholes(t *testing.T) {
+	instances := []*api.Instance{
+		{Name: "tg-plan-case-run-a-0", DataIP: "16.0.0.2"},
+		{Name: "tg-plan-case-run-a-1"},
+	}
+
+	resolved, err := resolveBlackholes([]string{"tg-plan-case-run-a-0", "16.1.0.0/16", "fd74:6700::2"}, instances)
+	require.NoError(t, err)
+	require.Equal(t, []string{"16.0.0.2", "16.1.0.0/16", "fd74:6700::2"}, resolved)
+
+	_, err = resolveBlackholes([]string{"tg-plan-case-run-a-1"}, instances)
+	require.Error(t, err)
+
+	_, err = resolveBlackholes([]string{"unknown"}, instances)
+	require.Error(t, err)
+}
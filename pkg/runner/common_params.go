@@ -0,0 +1,42 @@
+package runner
+
+import (
+	"context"
+
+	"github.com/testground/sdk-go/sync"
+
+	"github.com/testground/testground/pkg/api"
+)
+
+// ParamUpdatesTopic is the topic on which runners publish the parameter
+// updates pushed to the instances of a run.
+var ParamUpdatesTopic = sync.NewTopic("param-updates", &api.ParamUpdate{})
+
+// ParamAcksTopic is the topic on which instances acknowledge the parameter
+// updates they applied.
+var ParamAcksTopic = sync.NewTopic("param-acks", &api.ParamAck{})
+
+// awaitParamAcks collects the acknowledgments of the given update from ch,
+// until expected instances acknowledged it or the context is done.
+func awaitParamAcks(ctx context.Context, ch <-chan *api.ParamAck, updateID string, expected int) []*api.ParamAck {
+	var (
+		acks = make([]*api.ParamAck, 0, expected)
+		seen = make(map[string]struct{}, expected)
+	)
+	for len(acks) < expected {
+		select {
+		case ack := <-ch:
+			if ack.UpdateID != updateID {
+				continue
+			}
+			if _, ok := seen[ack.Instance]; ok {
+				continue
+			}
+			seen[ack.Instance] = struct{}{}
+			acks = append(acks, ack)
+		case <-ctx.Done():
+			return acks
+		}
+	}
+	return acks
+}
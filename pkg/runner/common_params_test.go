@@ -0,0 +1,36 @@
+package runner
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/testground/testground/pkg/api"
+)
+
+func TestAwaitParamAcks(t *testing.T) {
+	ch := make(chan *api.ParamAck, 4)
+	ch <- &api.ParamAck{UpdateID: "previous", Instance: "a/1"}
+	ch <- &api.ParamAck{UpdateID: "update", Instance: "a/1"}
+	ch <- &api.ParamAck{UpdateID: "update", Instance: "a/1"}
+	ch <- &api.ParamAck{UpdateID: "update", Instance: "a/2", Error: "unknown param"}
+
+	acks := awaitParamAcks(context.Background(), ch, "update", 2)
+	require.Len(t, acks, 2)
+	require.Equal(t, "a/1", acks[0].Instance)
+	require.Equal(t, "a/2", acks[1].Instance)
+	require.Equal(t, "unknown param", acks[1].Error)
+}
+
+func TestAwaitParamAcksTimeout(t *testing.T) {
+	ch := make(chan *api.ParamAck, 1)
+	ch <- &api.ParamAck{UpdateID: "update", Instance: "a/1"}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	acks := awaitParamAcks(ctx, ch, "update", 2)
+	require.Len(t, acks, 1)
+}
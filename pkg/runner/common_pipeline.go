@@ -0,0 +1,44 @@
+package runner
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// EnvTestInputsPath is set on the instances of a run that depends on other
+// runs, to the directory holding the outputs of the upstream runs, one
+// sub-directory per upstream run id.
+const EnvTestInputsPath = "TEST_INPUTS_PATH"
+
+// locateOutputs returns the directory holding the outputs of a run, under the
+// outputs directory of a local runner.
+func locateOutputs(outputsDir string, plan string, runID string) (string, error) {
+	if outputsDir == "" {
+		return "", fmt.Errorf("runner not initialized")
+	}
+
+	dir := filepath.Join(outputsDir, plan, runID)
+	if _, err := os.Stat(dir); err != nil {
+		return "", err
+	}
+	return dir, nil
+}
+
+// linkInputs creates a directory holding one symlink per upstream run,
+// pointing to its outputs.
+func linkInputs(inputs map[string]string) (string, error) {
+	dir, err := ioutil.TempDir("", "testground-inputs")
+	if err != nil {
+		return "", err
+	}
+
+	for id, src := range inputs {
+		if err := os.Symlink(src, filepath.Join(dir, id)); err != nil {
+			_ = os.RemoveAll(dir)
+			return "", fmt.Errorf("failed to link the outputs of run %s: %w", id, err)
+		}
+	}
+	return dir, nil
+}
@@ -1,6 +1,8 @@
 package runner
 
 import (
+	"time"
+
 	"github.com/testground/testground/pkg/api"
 	"github.com/testground/testground/pkg/task"
 )
@@ -9,6 +11,30 @@ type Result struct {
 	Outcome  task.Outcome             `json:"outcome"`
 	Outcomes map[string]*GroupOutcome `json:"outcomes"`
 	Journal  *Journal                 `json:"journal"`
+	// Assertions are the results of the assertions of the run, evaluated by
+	// the daemon once the run completed.
+	Assertions []*api.AssertionResult `json:"assertions,omitempty"`
+	// Ports are the host addresses the published ports of the instances are
+	// reachable at, by instance and port (e.g. "8545/tcp").
+	Ports map[string]map[string]string `json:"ports,omitempty"`
+	// Usage is what the instances used of the infrastructure, to attribute
+	// its spend to runs.
+	Usage *Usage `json:"usage,omitempty"`
+	// Disruptions are the instances disrupted during the run by their
+	// infrastructure, e.g. evicted by the preemption of their spot node.
+	Disruptions []*Disruption `json:"disruptions,omitempty"`
+}
+
+// Disruption is the disruption of an instance by its infrastructure.
+type Disruption struct {
+	Time     time.Time `json:"time" mapstructure:"time"`
+	Group    string    `json:"group" mapstructure:"group"`
+	Instance string    `json:"instance" mapstructure:"instance"`
+	Node     string    `json:"node,omitempty" mapstructure:"node"`
+	Reason   string    `json:"reason" mapstructure:"reason"`
+	Message  string    `json:"message,omitempty" mapstructure:"message"`
+	// Rescheduled is whether the instance was rescheduled.
+	Rescheduled bool `json:"rescheduled" mapstructure:"rescheduled"`
 }
 
 func newResult(input *api.RunInput) *Result {
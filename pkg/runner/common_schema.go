@@ -0,0 +1,37 @@
+package runner
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+
+	"github.com/testground/testground/pkg/api"
+	"github.com/testground/testground/pkg/version"
+)
+
+// writeOutputsSchema stamps the outputs of a run, in dir, with the version of
+// their schema.
+func writeOutputsSchema(dir string, runner string, input *api.RunInput) error {
+	if err := os.MkdirAll(dir, 0777); err != nil {
+		return err
+	}
+
+	schema := &api.OutputsSchema{
+		Version:    api.OutputsSchemaVersion,
+		RunID:      input.RunID,
+		Plan:       input.TestPlan,
+		Case:       input.TestCase,
+		Runner:     runner,
+		Testground: version.GitCommit,
+	}
+
+	f, err := os.Create(filepath.Join(dir, api.OutputsSchemaFile))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	enc := json.NewEncoder(f)
+	enc.SetIndent("", "  ")
+	return enc.Encode(schema)
+}
@@ -0,0 +1,244 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/client"
+	"github.com/docker/go-connections/nat"
+	"github.com/docker/go-units"
+	tgsync "github.com/testground/sync-service"
+
+	"github.com/testground/testground/pkg/docker"
+	"github.com/testground/testground/pkg/healthcheck"
+	"github.com/testground/testground/pkg/logging"
+	"github.com/testground/testground/pkg/rpc"
+)
+
+// SyncBackend is the implementation of the sync service the instances of a
+// run coordinate through.
+type SyncBackend string
+
+const (
+	// SyncBackendService is the standalone sync service, run in the
+	// testground-sync-service container next to redis (default).
+	SyncBackendService SyncBackend = "service"
+	// SyncBackendEmbedded is an in-memory sync service embedded in the
+	// daemon. It requires no infrastructure container; each run gets its
+	// own, whose state is dropped once the run is done.
+	SyncBackendEmbedded SyncBackend = "embedded"
+)
+
+// syncBackend provides the sync service of runs.
+type syncBackend interface {
+	// enlist enlists the checks and fixes of the infrastructure the backend
+	// requires.
+	enlist(ctx context.Context, hh *healthcheck.Helper, cli *client.Client, ow *rpc.OutputWriter, controlNetworkID string)
+
+	// env returns the environment variables the instances of a run reach
+	// the sync service with, at the given host. It starts the backend if
+	// needed.
+	env(runID, host string) ([]string, error)
+
+	// release drops the state the backend holds for a run, once it's done.
+	release(runID string)
+}
+
+// newSyncBackend returns the sync backend of a runner. An empty name selects
+// the default backend.
+func newSyncBackend(name SyncBackend) (syncBackend, error) {
+	switch name {
+	case "", SyncBackendService:
+		return serviceSyncBackend{}, nil
+	case SyncBackendEmbedded:
+		return embeddedSync, nil
+	default:
+		return nil, fmt.Errorf("unknown sync backend %q; supported: %s, %s", name, SyncBackendService, SyncBackendEmbedded)
+	}
+}
+
+// serviceSyncBackend is the standalone sync service.
+type serviceSyncBackend struct{}
+
+func (serviceSyncBackend) enlist(ctx context.Context, hh *healthcheck.Helper, cli *client.Client, ow *rpc.OutputWriter, controlNetworkID string) {
+	// redis, using a downloaded image and no additional configuration.
+	_, exposed, _ := nat.ParsePortSpecs([]string{"6379:6379"})
+	hh.Enlist("local-redis",
+		healthcheck.CheckContainerStarted(ctx, ow, cli, "testground-redis"),
+		healthcheck.StartContainer(ctx, ow, cli, &docker.EnsureContainerOpts{
+			ContainerName: "testground-redis",
+			ContainerConfig: &container.Config{
+				Image: "library/redis",
+				Cmd:   []string{"--save", "", "--appendonly", "no", "--maxclients", "120000", "--stop-writes-on-bgsave-error", "no"},
+			},
+			HostConfig: &container.HostConfig{
+				// NOTE: we expose this port for compatibility with older sdk versions.
+				PortBindings: exposed,
+				NetworkMode:  container.NetworkMode(controlNetworkID),
+				Resources: container.Resources{
+					Ulimits: []*units.Ulimit{
+						{Name: "nofile", Hard: InfraMaxFilesUlimit, Soft: InfraMaxFilesUlimit},
+					},
+				},
+				Sysctls: map[string]string{
+					"net.core.somaxconn": "150000",
+				},
+				RestartPolicy: container.RestartPolicy{
+					Name: "unless-stopped",
+				},
+			},
+			ImageStrategy: docker.ImageStrategyPull,
+		}),
+	)
+
+	// sync service, which uses redis.
+	_, exposed, _ = nat.ParsePortSpecs([]string{"5050:5050"})
+	hh.Enlist("local-sync-service",
+		healthcheck.CheckContainerStarted(ctx, ow, cli, "testground-sync-service"),
+		healthcheck.StartContainer(ctx, ow, cli, &docker.EnsureContainerOpts{
+			ContainerName: "testground-sync-service",
+			ContainerConfig: &container.Config{
+				Image:      "iptestground/sync-service:edge",
+				Entrypoint: []string{"/service"},
+				Env:        []string{"REDIS_HOST=testground-redis"},
+			},
+			HostConfig: &container.HostConfig{
+				PortBindings: exposed,
+				NetworkMode:  container.NetworkMode(controlNetworkID),
+				Resources: container.Resources{
+					Ulimits: []*units.Ulimit{
+						{Name: "nofile", Hard: InfraMaxFilesUlimit, Soft: InfraMaxFilesUlimit},
+					},
+				},
+				Sysctls: map[string]string{
+					"net.core.somaxconn": "150000",
+				},
+				RestartPolicy: container.RestartPolicy{
+					Name: "unless-stopped",
+				},
+			},
+		}),
+	)
+}
+
+func (serviceSyncBackend) env(_, host string) ([]string, error) {
+	return []string{
+		// NOTE: we export REDIS_HOST for compatibility with older sdk versions.
+		"REDIS_HOST=" + host,
+		"SYNC_SERVICE_HOST=" + host,
+	}, nil
+}
+
+// release is a no-op, as the standalone sync service collects the topics and
+// barriers of runs once they are idle.
+func (serviceSyncBackend) release(string) {}
+
+// embeddedSync is the sync service embedded in the daemon, shared by the runs
+// of every runner selecting it.
+var embeddedSync = &embeddedSyncBackend{}
+
+// embeddedSyncBackend serves in-memory sync services from the daemon. Each
+// run gets its own, on a port picked when the run starts, so that its topics
+// and barriers are dropped with it once the run is done.
+type embeddedSyncBackend struct {
+	lk   sync.Mutex
+	srvs map[string]*tgsync.Server
+}
+
+func (*embeddedSyncBackend) enlist(_ context.Context, hh *healthcheck.Helper, _ *client.Client, _ *rpc.OutputWriter, _ string) {
+	hh.Enlist("embedded-sync-service",
+		func() (bool, string, error) {
+			l, err := net.Listen("tcp", ":0")
+			if err != nil {
+				return false, fmt.Sprintf("the embedded sync service can't listen: %s", err), nil
+			}
+			_ = l.Close()
+			return true, "the embedded sync service can listen.", nil
+		},
+		healthcheck.RequiresManualFixing(),
+	)
+}
+
+func (b *embeddedSyncBackend) env(runID, host string) ([]string, error) {
+	port, err := b.start(runID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start the embedded sync service: %w", err)
+	}
+	return []string{
+		"SYNC_SERVICE_HOST=" + host,
+		"SYNC_SERVICE_PORT=" + strconv.Itoa(port),
+	}, nil
+}
+
+// start starts the sync service of a run, if it's not started yet, and
+// returns the port it listens on.
+func (b *embeddedSyncBackend) start(runID string) (int, error) {
+	b.lk.Lock()
+	defer b.lk.Unlock()
+
+	if srv, ok := b.srvs[runID]; ok {
+		return srv.Port(), nil
+	}
+
+	service, err := tgsync.NewDefaultService(context.Background(), logging.S())
+	if err != nil {
+		return 0, err
+	}
+	srv, err := tgsync.NewServer(service, 0)
+	if err != nil {
+		_ = service.Close()
+		return 0, err
+	}
+
+	go func() {
+		if err := srv.Serve(); err != nil && err != http.ErrServerClosed {
+			logging.S().Warnw("embedded sync service stopped", "run_id", runID, "err", err)
+		}
+	}()
+
+	if b.srvs == nil {
+		b.srvs = make(map[string]*tgsync.Server)
+	}
+	b.srvs[runID] = srv
+	logging.S().Infow("embedded sync service started", "run_id", runID, "port", srv.Port())
+	return srv.Port(), nil
+}
+
+// release stops the sync service of a run, which drops its topics and
+// barriers.
+func (b *embeddedSyncBackend) release(runID string) {
+	b.lk.Lock()
+	srv, ok := b.srvs[runID]
+	delete(b.srvs, runID)
+	b.lk.Unlock()
+
+	if ok {
+		b.stop(runID, srv)
+	}
+}
+
+// close stops the sync services of all runs.
+func (b *embeddedSyncBackend) close() {
+	b.lk.Lock()
+	srvs := b.srvs
+	b.srvs = nil
+	b.lk.Unlock()
+
+	for runID, srv := range srvs {
+		b.stop(runID, srv)
+	}
+}
+
+func (b *embeddedSyncBackend) stop(runID string, srv *tgsync.Server) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := srv.Shutdown(ctx); err != nil {
+		logging.S().Warnw("failed to stop the embedded sync service", "run_id", runID, "err", err)
+	}
+}
@@ -0,0 +1,56 @@
+package runner
+
+import (
+	"net"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewSyncBackend(t *testing.T) {
+	for _, name := range []SyncBackend{"", SyncBackendService} {
+		backend, err := newSyncBackend(name)
+		require.NoError(t, err)
+		require.IsType(t, serviceSyncBackend{}, backend)
+	}
+
+	backend, err := newSyncBackend(SyncBackendEmbedded)
+	require.NoError(t, err)
+	require.Equal(t, embeddedSync, backend)
+
+	_, err = newSyncBackend("nats")
+	require.Error(t, err)
+}
+
+func TestEmbeddedSyncBackend(t *testing.T) {
+	b := &embeddedSyncBackend{}
+	defer b.close()
+
+	env, err := b.env("c3ftkqjpc98qra498sg0", "127.0.0.1")
+	require.NoError(t, err)
+	require.Contains(t, env, "SYNC_SERVICE_HOST=127.0.0.1")
+
+	// The service of a run is started once, and each run gets its own.
+	port, err := b.start("c3ftkqjpc98qra498sg0")
+	require.NoError(t, err)
+	require.Contains(t, env, "SYNC_SERVICE_PORT="+strconv.Itoa(port))
+	other, err := b.start("c3ftkqjpc98qra498si0")
+	require.NoError(t, err)
+	require.NotEqual(t, port, other)
+
+	conn, err := net.Dial("tcp", net.JoinHostPort("127.0.0.1", strconv.Itoa(port)))
+	require.NoError(t, err)
+	_ = conn.Close()
+
+	// Released runs drop their service, and the others keep theirs.
+	b.release("c3ftkqjpc98qra498sg0")
+	_, err = net.Dial("tcp", net.JoinHostPort("127.0.0.1", strconv.Itoa(port)))
+	require.Error(t, err)
+	require.Len(t, b.srvs, 1)
+
+	b.close()
+	_, err = net.Dial("tcp", net.JoinHostPort("127.0.0.1", strconv.Itoa(other)))
+	require.Error(t, err)
+	require.Empty(t, b.srvs)
+}
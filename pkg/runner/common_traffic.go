@@ -0,0 +1,72 @@
+package runner
+
+import (
+	"encoding/json"
+	"os"
+	"time"
+
+	"github.com/testground/testground/pkg/sidecar"
+)
+
+// TrafficSummaryFile is the name of the file, at the root of the run outputs,
+// holding the per-protocol traffic summary of a run.
+const TrafficSummaryFile = "traffic_summary.json"
+
+// defaultTrafficSummaryInterval is the default interval between two snapshots
+// of the socket statistics of an instance.
+const defaultTrafficSummaryInterval = 5 * time.Second
+
+// TrafficSummaryConfig configures the collection of the socket statistics of
+// the instances by the sidecar during a run.
+type TrafficSummaryConfig struct {
+	// Enabled turns on the traffic summary (default: false).
+	Enabled bool `toml:"enabled"`
+	// IntervalSecs is the interval between two snapshots of the socket
+	// statistics, in seconds (default: 5). The summary reflects the last
+	// snapshot of every instance, so traffic in the last interval before an
+	// instance exits is not accounted for.
+	IntervalSecs int `toml:"interval_secs"`
+}
+
+// EnvVars returns the environment variables to pass to test instances in
+// order for the sidecar to collect their socket statistics.
+func (c TrafficSummaryConfig) EnvVars() []string {
+	interval := time.Duration(c.IntervalSecs) * time.Second
+	if interval <= 0 {
+		interval = defaultTrafficSummaryInterval
+	}
+	return []string{sidecar.EnvTrafficStatsInterval + "=" + interval.String()}
+}
+
+// TrafficSummary is the per-protocol summary of the traffic of a run: the
+// connections opened, the failed handshakes, the retransmits, etc. of every
+// instance, and their total.
+type TrafficSummary struct {
+	Instances map[string]*sidecar.ProtocolStats `json:"instances"`
+	Total     sidecar.ProtocolStats             `json:"total"`
+}
+
+// newTrafficSummary builds the summary of a run from the last snapshot of the
+// socket statistics of every instance.
+func newTrafficSummary(latest map[string]*sidecar.TrafficStatsSample) *TrafficSummary {
+	summary := &TrafficSummary{Instances: make(map[string]*sidecar.ProtocolStats, len(latest))}
+	for name, s := range latest {
+		stats := s.Stats
+		summary.Instances[name] = &stats
+		summary.Total.Add(&stats)
+	}
+	return summary
+}
+
+// writeTrafficSummary summarises the snapshots and writes the summary to path.
+func writeTrafficSummary(path string, latest map[string]*sidecar.TrafficStatsSample) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	enc := json.NewEncoder(f)
+	enc.SetIndent("", "  ")
+	return enc.Encode(newTrafficSummary(latest))
+}
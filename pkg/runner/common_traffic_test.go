@@ -0,0 +1,28 @@
+package runner
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/testground/testground/pkg/sidecar"
+)
+
+func TestNewTrafficSummary(t *testing.T) {
+	latest := map[string]*sidecar.TrafficStatsSample{
+		"a/1": {Instance: "a/1", Stats: sidecar.ProtocolStats{TCP: sidecar.TCPStats{ActiveOpens: 2, RetransSegs: 5}}},
+		"b/2": {Instance: "b/2", Stats: sidecar.ProtocolStats{TCP: sidecar.TCPStats{PassiveOpens: 2, AttemptFails: 1}, UDP: sidecar.UDPStats{InDatagrams: 3}}},
+	}
+
+	summary := newTrafficSummary(latest)
+
+	require.Len(t, summary.Instances, 2)
+	require.EqualValues(t, 5, summary.Instances["a/1"].TCP.RetransSegs)
+	require.Equal(t, sidecar.TCPStats{ActiveOpens: 2, PassiveOpens: 2, AttemptFails: 1, RetransSegs: 5}, summary.Total.TCP)
+	require.EqualValues(t, 3, summary.Total.UDP.InDatagrams)
+}
+
+func TestTrafficSummaryConfigEnvVars(t *testing.T) {
+	require.Equal(t, []string{"TRAFFIC_STATS_INTERVAL=5s"}, TrafficSummaryConfig{}.EnvVars())
+	require.Equal(t, []string{"TRAFFIC_STATS_INTERVAL=30s"}, TrafficSummaryConfig{IntervalSecs: 30}.EnvVars())
+}
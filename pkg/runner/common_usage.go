@@ -0,0 +1,164 @@
+package runner
+
+import (
+	"sort"
+	"sync"
+)
+
+// InstanceUsage is what an instance used of its host during a run.
+type InstanceUsage struct {
+	Group string `json:"group" mapstructure:"group"`
+	// Seconds is how long the instance ran.
+	Seconds float64 `json:"seconds" mapstructure:"seconds"`
+	// CPUSeconds is the CPU time the instance used, in user and kernel mode.
+	CPUSeconds      float64 `json:"cpu_seconds" mapstructure:"cpu_seconds"`
+	PeakMemoryBytes uint64  `json:"peak_memory_bytes" mapstructure:"peak_memory_bytes"`
+	NetworkRxBytes  uint64  `json:"network_rx_bytes" mapstructure:"network_rx_bytes"`
+	NetworkTxBytes  uint64  `json:"network_tx_bytes" mapstructure:"network_tx_bytes"`
+	// Node and InstanceType are the node the instance ran on, and the
+	// instance type of the node, with cluster:k8s.
+	Node         string `json:"node,omitempty" mapstructure:"node"`
+	InstanceType string `json:"instance_type,omitempty" mapstructure:"instance_type"`
+}
+
+// UsageTotals sums the usage of instances. PeakMemoryBytes is the sum of the
+// peaks of the instances, i.e. the memory they may have used at once.
+type UsageTotals struct {
+	Instances       int     `json:"instances" mapstructure:"instances"`
+	Seconds         float64 `json:"seconds" mapstructure:"seconds"`
+	CPUSeconds      float64 `json:"cpu_seconds" mapstructure:"cpu_seconds"`
+	PeakMemoryBytes uint64  `json:"peak_memory_bytes" mapstructure:"peak_memory_bytes"`
+	NetworkRxBytes  uint64  `json:"network_rx_bytes" mapstructure:"network_rx_bytes"`
+	NetworkTxBytes  uint64  `json:"network_tx_bytes" mapstructure:"network_tx_bytes"`
+}
+
+func (t *UsageTotals) add(u *InstanceUsage) {
+	t.Instances++
+	t.Seconds += u.Seconds
+	t.CPUSeconds += u.CPUSeconds
+	t.PeakMemoryBytes += u.PeakMemoryBytes
+	t.NetworkRxBytes += u.NetworkRxBytes
+	t.NetworkTxBytes += u.NetworkTxBytes
+}
+
+// Usage is what the instances of a run used, to attribute the spend of the
+// infrastructure to runs.
+type Usage struct {
+	// Instances is the usage of every instance, by name.
+	Instances map[string]*InstanceUsage `json:"instances" mapstructure:"instances"`
+	// Groups and Total sum the usage of the instances by group, and of all.
+	Groups map[string]*UsageTotals `json:"groups" mapstructure:"groups"`
+	Total  *UsageTotals            `json:"total" mapstructure:"total"`
+	// InstanceTypes sums the usage of the instances by the instance type of
+	// their node, and Nodes lists the nodes of each, with cluster:k8s.
+	InstanceTypes map[string]*UsageTotals `json:"instance_types,omitempty" mapstructure:"instance_types"`
+	Nodes         map[string][]string     `json:"nodes,omitempty" mapstructure:"nodes"`
+}
+
+// newUsage aggregates the usage of the instances of a run.
+func newUsage(instances map[string]*InstanceUsage) *Usage {
+	u := &Usage{
+		Instances: instances,
+		Groups:    make(map[string]*UsageTotals),
+		Total:     &UsageTotals{},
+	}
+
+	nodes := make(map[string]map[string]bool)
+	for _, i := range instances {
+		g, ok := u.Groups[i.Group]
+		if !ok {
+			g = &UsageTotals{}
+			u.Groups[i.Group] = g
+		}
+		g.add(i)
+		u.Total.add(i)
+
+		if i.InstanceType == "" {
+			continue
+		}
+		if u.InstanceTypes == nil {
+			u.InstanceTypes = make(map[string]*UsageTotals)
+		}
+		t, ok := u.InstanceTypes[i.InstanceType]
+		if !ok {
+			t = &UsageTotals{}
+			u.InstanceTypes[i.InstanceType] = t
+			nodes[i.InstanceType] = make(map[string]bool)
+		}
+		t.add(i)
+		nodes[i.InstanceType][i.Node] = true
+	}
+
+	for typ, set := range nodes {
+		if u.Nodes == nil {
+			u.Nodes = make(map[string][]string)
+		}
+		for n := range set {
+			u.Nodes[typ] = append(u.Nodes[typ], n)
+		}
+		sort.Strings(u.Nodes[typ])
+	}
+	return u
+}
+
+// usageRecorder records the usage of the instances of a run, as their
+// samplers observe it. Counters only ever grow, as samplers may observe
+// instances once they've stopped, with zeroed statistics.
+type usageRecorder struct {
+	lk        sync.Mutex
+	instances map[string]*InstanceUsage
+}
+
+func newUsageRecorder() *usageRecorder {
+	return &usageRecorder{instances: make(map[string]*InstanceUsage)}
+}
+
+// update applies fn to the usage of an instance.
+func (r *usageRecorder) update(name, group string, fn func(u *InstanceUsage)) {
+	r.lk.Lock()
+	defer r.lk.Unlock()
+
+	u, ok := r.instances[name]
+	if !ok {
+		u = &InstanceUsage{Group: group}
+		r.instances[name] = u
+	}
+	fn(u)
+}
+
+// sample records a sample of the cumulative counters of an instance.
+func (r *usageRecorder) sample(name, group string, cpuSeconds float64, memory, rx, tx uint64) {
+	r.update(name, group, func(u *InstanceUsage) {
+		u.CPUSeconds = maxFloat(u.CPUSeconds, cpuSeconds)
+		u.PeakMemoryBytes = maxUint(u.PeakMemoryBytes, memory)
+		u.NetworkRxBytes = maxUint(u.NetworkRxBytes, rx)
+		u.NetworkTxBytes = maxUint(u.NetworkTxBytes, tx)
+	})
+}
+
+// usage aggregates the usage recorded.
+func (r *usageRecorder) usage() *Usage {
+	r.lk.Lock()
+	defer r.lk.Unlock()
+
+	instances := make(map[string]*InstanceUsage, len(r.instances))
+	for name, u := range r.instances {
+		cp := *u
+		instances[name] = &cp
+	}
+	return newUsage(instances)
+}
+
+func maxFloat(a, b float64) float64 {
+	if b > a {
+		return b
+	}
+	return a
+}
+
+func maxUint(a, b uint64) uint64 {
+	if b > a {
+		return b
+	}
+	return a
+}
@@ -0,0 +1,50 @@
+package runner
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestUsageRecorder(t *testing.T) {
+	r := newUsageRecorder()
+	r.sample("a-0", "a", 1.5, 100, 10, 20)
+	r.sample("a-1", "a", 2, 300, 30, 40)
+	r.sample("b-0", "b", 4, 500, 50, 60)
+
+	// Samples of stopped instances are zeroed; counters don't go back.
+	r.sample("a-0", "a", 0, 0, 0, 0)
+	r.update("a-0", "a", func(u *InstanceUsage) {
+		u.Seconds = 10
+		u.Node = "node-1"
+		u.InstanceType = "c5.large"
+	})
+	r.update("b-0", "b", func(u *InstanceUsage) {
+		u.Node = "node-2"
+		u.InstanceType = "c5.large"
+	})
+
+	u := r.usage()
+	require.Len(t, u.Instances, 3)
+	require.Equal(t, &InstanceUsage{
+		Group:           "a",
+		Seconds:         10,
+		CPUSeconds:      1.5,
+		PeakMemoryBytes: 100,
+		NetworkRxBytes:  10,
+		NetworkTxBytes:  20,
+		Node:            "node-1",
+		InstanceType:    "c5.large",
+	}, u.Instances["a-0"])
+
+	require.Equal(t, &UsageTotals{Instances: 2, Seconds: 10, CPUSeconds: 3.5, PeakMemoryBytes: 400, NetworkRxBytes: 40, NetworkTxBytes: 60}, u.Groups["a"])
+	require.Equal(t, &UsageTotals{Instances: 3, Seconds: 10, CPUSeconds: 7.5, PeakMemoryBytes: 900, NetworkRxBytes: 90, NetworkTxBytes: 120}, u.Total)
+
+	require.Len(t, u.InstanceTypes, 1)
+	require.Equal(t, 2, u.InstanceTypes["c5.large"].Instances)
+	require.Equal(t, []string{"node-1", "node-2"}, u.Nodes["c5.large"])
+
+	// The usage returned is a copy.
+	r.sample("a-0", "a", 5, 0, 0, 0)
+	require.Equal(t, 1.5, u.Instances["a-0"].CPUSeconds)
+}
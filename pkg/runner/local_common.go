@@ -3,8 +3,7 @@ package runner
 import (
 	"context"
 
-	"github.com/docker/go-units"
-
+	"github.com/testground/testground/pkg/config"
 	"github.com/testground/testground/pkg/docker"
 	"github.com/testground/testground/pkg/healthcheck"
 	"github.com/testground/testground/pkg/rpc"
@@ -15,7 +14,7 @@ import (
 	"github.com/docker/go-connections/nat"
 )
 
-func localCommonHealthcheck(ctx context.Context, hh *healthcheck.Helper, cli *client.Client, ow *rpc.OutputWriter, controlNetworkID string, workdir string) {
+func localCommonHealthcheck(ctx context.Context, hh *healthcheck.Helper, cli *client.Client, ow *rpc.OutputWriter, controlNetworkID string, workdir string, backend syncBackend) {
 	hh.Enlist("local-outputs-dir",
 		healthcheck.CheckDirectoryExists(workdir),
 		healthcheck.CreateDirectory(workdir),
@@ -27,96 +26,58 @@ func localCommonHealthcheck(ctx context.Context, hh *healthcheck.Helper, cli *cl
 		healthcheck.CreateNetwork(ctx, ow, cli, controlNetworkID, network.IPAMConfig{Subnet: controlSubnet, Gateway: controlGateway}),
 	)
 
-	// grafana from downloaded image, with no additional configuration.
-	_, exposed, _ := nat.ParsePortSpecs([]string{"3000:3000"})
+	// grafana from downloaded image.
 	hh.Enlist("local-grafana",
 		healthcheck.CheckContainerStarted(ctx, ow, cli, "testground-grafana"),
-		healthcheck.StartContainer(ctx, ow, cli, &docker.EnsureContainerOpts{
-			ContainerName: "testground-grafana",
-			ContainerConfig: &container.Config{
-				Image: "bitnami/grafana",
-			},
-			HostConfig: &container.HostConfig{
-				PortBindings: exposed,
-				NetworkMode:  container.NetworkMode(controlNetworkID),
-			},
-			ImageStrategy: docker.ImageStrategyPull,
-		}),
-	)
-
-	// redis, using a downloaded image and no additional configuration.
-	_, exposed, _ = nat.ParsePortSpecs([]string{"6379:6379"})
-	hh.Enlist("local-redis",
-		healthcheck.CheckContainerStarted(ctx, ow, cli, "testground-redis"),
-		healthcheck.StartContainer(ctx, ow, cli, &docker.EnsureContainerOpts{
-			ContainerName: "testground-redis",
-			ContainerConfig: &container.Config{
-				Image: "library/redis",
-				Cmd:   []string{"--save", "", "--appendonly", "no", "--maxclients", "120000", "--stop-writes-on-bgsave-error", "no"},
-			},
-			HostConfig: &container.HostConfig{
-				// NOTE: we expose this port for compatibility with older sdk versions.
-				PortBindings: exposed,
-				NetworkMode:  container.NetworkMode(controlNetworkID),
-				Resources: container.Resources{
-					Ulimits: []*units.Ulimit{
-						{Name: "nofile", Hard: InfraMaxFilesUlimit, Soft: InfraMaxFilesUlimit},
-					},
-				},
-				Sysctls: map[string]string{
-					"net.core.somaxconn": "150000",
-				},
-				RestartPolicy: container.RestartPolicy{
-					Name: "unless-stopped",
-				},
-			},
-			ImageStrategy: docker.ImageStrategyPull,
-		}),
+		healthcheck.StartContainer(ctx, ow, cli, grafanaContainerOpts(controlNetworkID)),
 	)
 
-	// sync service, which uses redis.
-	_, exposed, _ = nat.ParsePortSpecs([]string{"5050:5050"})
-	hh.Enlist("local-sync-service",
-		healthcheck.CheckContainerStarted(ctx, ow, cli, "testground-sync-service"),
-		healthcheck.StartContainer(ctx, ow, cli, &docker.EnsureContainerOpts{
-			ContainerName: "testground-sync-service",
-			ContainerConfig: &container.Config{
-				Image:      "iptestground/sync-service:edge",
-				Entrypoint: []string{"/service"},
-				Env:        []string{"REDIS_HOST=testground-redis"},
-			},
-			HostConfig: &container.HostConfig{
-				PortBindings: exposed,
-				NetworkMode:  container.NetworkMode(controlNetworkID),
-				Resources: container.Resources{
-					Ulimits: []*units.Ulimit{
-						{Name: "nofile", Hard: InfraMaxFilesUlimit, Soft: InfraMaxFilesUlimit},
-					},
-				},
-				Sysctls: map[string]string{
-					"net.core.somaxconn": "150000",
-				},
-				RestartPolicy: container.RestartPolicy{
-					Name: "unless-stopped",
-				},
-			},
-		}),
-	)
+	// the sync service, and the infrastructure it relies on.
+	backend.enlist(ctx, hh, cli, ow, controlNetworkID)
 
-	_, exposed, _ = nat.ParsePortSpecs([]string{"8086:8086", "8088:8088"})
 	hh.Enlist("local-influxdb",
 		healthcheck.CheckContainerStarted(ctx, ow, cli, "testground-influxdb"),
-		healthcheck.StartContainer(ctx, ow, cli, &docker.EnsureContainerOpts{
-			ContainerName: "testground-influxdb",
-			ContainerConfig: &container.Config{
-				Image: "library/influxdb:1.8",
-				Env:   []string{"INFLUXDB_HTTP_AUTH_ENABLED=false", "INFLUXDB_DB=testground", "INFLUXDB_HTTP_FLUX_ENABLED=true"},
-			},
-			HostConfig: &container.HostConfig{
-				PortBindings: exposed,
-				NetworkMode:  container.NetworkMode(controlNetworkID),
-			},
-			ImageStrategy: docker.ImageStrategyPull,
-		}),
+		healthcheck.StartContainer(ctx, ow, cli, influxdbContainerOpts(controlNetworkID)),
 	)
 }
+
+// DefaultGrafanaImage is the image of the Grafana of the local runners. It's
+// pinned, so that the one bundled for offline daemons is the one they run.
+const DefaultGrafanaImage = "bitnami/grafana:7.5.11"
+
+// grafanaContainerOpts are the options of the container of the Grafana of the
+// local runners, whose admin password is the default one of the local
+// observability stack.
+func grafanaContainerOpts(controlNetworkID string) *docker.EnsureContainerOpts {
+	_, exposed, _ := nat.ParsePortSpecs([]string{"3000:3000"})
+	return &docker.EnsureContainerOpts{
+		ContainerName: "testground-grafana",
+		ContainerConfig: &container.Config{
+			Image: DefaultGrafanaImage,
+			Env:   []string{"GF_SECURITY_ADMIN_PASSWORD=" + config.DefaultGrafanaPassword},
+		},
+		HostConfig: &container.HostConfig{
+			PortBindings: exposed,
+			NetworkMode:  container.NetworkMode(controlNetworkID),
+		},
+		ImageStrategy: docker.ImageStrategyPull,
+	}
+}
+
+// influxdbContainerOpts are the options of the container of the InfluxDB of
+// the local runners.
+func influxdbContainerOpts(controlNetworkID string) *docker.EnsureContainerOpts {
+	_, exposed, _ := nat.ParsePortSpecs([]string{"8086:8086", "8088:8088"})
+	return &docker.EnsureContainerOpts{
+		ContainerName: "testground-influxdb",
+		ContainerConfig: &container.Config{
+			Image: "library/influxdb:1.8",
+			Env:   []string{"INFLUXDB_HTTP_AUTH_ENABLED=false", "INFLUXDB_DB=testground", "INFLUXDB_HTTP_FLUX_ENABLED=true"},
+		},
+		HostConfig: &container.HostConfig{
+			PortBindings: exposed,
+			NetworkMode:  container.NetworkMode(controlNetworkID),
+		},
+		ImageStrategy: docker.ImageStrategyPull,
+	}
+}
@@ -9,6 +9,7 @@ import (
 	"os"
 	"path/filepath"
 	"reflect"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
@@ -26,8 +27,11 @@ import (
 	"github.com/testground/testground/pkg/conv"
 	"github.com/testground/testground/pkg/docker"
 	"github.com/testground/testground/pkg/healthcheck"
+	"github.com/testground/testground/pkg/metrics"
 	"github.com/testground/testground/pkg/rpc"
+	"github.com/testground/testground/pkg/sidecar"
 	"github.com/testground/testground/pkg/task"
+	"github.com/testground/testground/pkg/tracing"
 
 	"github.com/docker/docker/api/types"
 	"github.com/docker/docker/api/types/container"
@@ -38,6 +42,7 @@ import (
 	"github.com/docker/docker/pkg/stdcopy"
 
 	"github.com/imdario/mergo"
+	"go.opentelemetry.io/otel/attribute"
 	"golang.org/x/sync/errgroup"
 
 	ss "github.com/testground/sdk-go/sync"
@@ -46,9 +51,18 @@ import (
 const InfraMaxFilesUlimit int64 = 1048576
 
 var (
-	_ api.Runner        = (*LocalDockerRunner)(nil)
-	_ api.Healthchecker = (*LocalDockerRunner)(nil)
-	_ api.Terminatable  = (*LocalDockerRunner)(nil)
+	_ api.Runner           = (*LocalDockerRunner)(nil)
+	_ api.Healthchecker    = (*LocalDockerRunner)(nil)
+	_ api.Terminatable     = (*LocalDockerRunner)(nil)
+	_ api.InstanceRegistry = (*LocalDockerRunner)(nil)
+	_ api.OutputsLocator   = (*LocalDockerRunner)(nil)
+	_ api.ParamPusher      = (*LocalDockerRunner)(nil)
+	_ api.NetworkFaulter   = (*LocalDockerRunner)(nil)
+	_ api.ChaosInjector    = (*LocalDockerRunner)(nil)
+	_ api.ReadinessProber  = (*LocalDockerRunner)(nil)
+	_ api.Scaler           = (*LocalDockerRunner)(nil)
+	_ api.StateInspector   = (*LocalDockerRunner)(nil)
+	_ api.RunReaper        = (*LocalDockerRunner)(nil)
 )
 
 // LocalDockerRunnerConfig is the configuration object of this runner. Boolean
@@ -76,10 +90,19 @@ type LocalDockerRunnerConfig struct {
 	OutcomesCollectionTimeout time.Duration `toml:"outcomes_collection_timeout"`
 
 	AdditionalHosts []string `toml:"additional_hosts"`
+
+	// LatencyHeatmap makes the sidecar measure pairwise latencies between a
+	// sample of instances, and writes the resulting heatmap to the run outputs.
+	LatencyHeatmap LatencyHeatmapConfig `toml:"latency_heatmap"`
+
+	// TrafficSummary makes the sidecar collect the socket statistics of the
+	// instances, and writes a per-protocol summary to the run outputs.
+	TrafficSummary TrafficSummaryConfig `toml:"traffic_summary"`
 }
 
 type testContainerInstance struct {
 	containerID string
+	name        string
 	groupID     string
 	groupIdx    int
 }
@@ -111,6 +134,15 @@ type LocalDockerRunner struct {
 	outputsDir       string
 
 	syncClient *ss.DefaultClient
+
+	// chaosHeld are the containers killed by the chaos controller, which the
+	// run waits for until they are started again.
+	chaosLk   sync.Mutex
+	chaosHeld map[string]chan struct{}
+
+	// scaling are the runs in progress, which can be scaled.
+	scalingLk sync.Mutex
+	scaling   map[string]*dockerScaling
 }
 
 func (r *LocalDockerRunner) Healthcheck(ctx context.Context, engine api.Engine, ow *rpc.OutputWriter, fix bool) (*api.HealthcheckReport, error) {
@@ -129,7 +161,7 @@ func (r *LocalDockerRunner) Healthcheck(ctx context.Context, engine api.Engine,
 	hh := &healthcheck.Helper{}
 
 	// enlist healthchecks which are common between local:docker and local:exec
-	localCommonHealthcheck(ctx, hh, cli, ow, r.controlNetworkID, r.outputsDir)
+	localCommonHealthcheck(ctx, hh, cli, ow, r.controlNetworkID, r.outputsDir, serviceSyncBackend{})
 
 	dockerSock := "/var/run/docker.sock"
 	if host := cli.DaemonHost(); strings.HasPrefix(host, "unix://") {
@@ -214,12 +246,16 @@ func (r *LocalDockerRunner) setupSyncClient() error {
 
 // collectOutcomes listens to the sync service and collects the outcome for every test instance.
 // It stops when all instances have submitted a result or the context was canceled.
-func (r *LocalDockerRunner) collectOutcomes(ctx context.Context, result *Result, tpl *runtime.RunParams) (chan bool, error) {
+// The instances expected follow the scaling of the run.
+func (r *LocalDockerRunner) collectOutcomes(ctx context.Context, result *Result, tpl *runtime.RunParams, scaling *dockerScaling) (chan bool, error) {
 	eventsCh, err := r.syncClient.SubscribeEvents(ctx, tpl)
 	if err != nil {
 		return nil, err
 	}
 
+	sessions := metrics.SyncSessions.WithLabelValues(r.ID())
+	sessions.Inc()
+
 	// TODO: eventually we'll keep a trace of each test instance status.
 	// Right now, if a container sends multiple events, it will mess up the outcomes.
 	// We have to pass its group id to the container, so that it can send us back messages
@@ -227,12 +263,19 @@ func (r *LocalDockerRunner) collectOutcomes(ctx context.Context, result *Result,
 	expectingOutcomes := result.countTotalInstances()
 	done := make(chan bool)
 
+	adjust := func(a outcomeAdjustment) {
+		result.Outcomes[a.group].Total += a.delta
+		expectingOutcomes += a.delta
+	}
+
 	go func() {
 		running := true
 		for running && expectingOutcomes > 0 {
 			select {
 			case <-ctx.Done():
 				running = false
+			case a := <-scaling.adjust:
+				adjust(a)
 			case e := <-eventsCh:
 				if e.SuccessEvent != nil {
 					result.addOutcome(e.SuccessEvent.TestGroupID, task.OutcomeSuccess)
@@ -248,13 +291,241 @@ func (r *LocalDockerRunner) collectOutcomes(ctx context.Context, result *Result,
 			}
 		}
 
+		// No more instances can be added or removed once the outcomes are
+		// collected.
+		scaling.close(adjust)
+
 		result.updateOutcome()
+		sessions.Dec()
 		done <- true
 	}()
 
 	return done, nil
 }
 
+// collectLatencySamples listens to the sync service and accumulates the latency
+// samples published by the sidecar. The samples are delivered on the returned
+// channel once the context is canceled.
+func (r *LocalDockerRunner) collectLatencySamples(ctx context.Context, tpl *runtime.RunParams) (chan []*sidecar.LatencySample, error) {
+	ch := make(chan *sidecar.LatencySample, 128)
+	if _, err := r.syncClient.Subscribe(ss.WithRunParams(ctx, tpl), sidecar.LatencyHeatmapTopic, ch); err != nil {
+		return nil, fmt.Errorf("failed to subscribe to latency samples: %w", err)
+	}
+
+	done := make(chan []*sidecar.LatencySample, 1)
+	go func() {
+		var samples []*sidecar.LatencySample
+		for {
+			select {
+			case s := <-ch:
+				samples = append(samples, s)
+			case <-ctx.Done():
+				done <- samples
+				return
+			}
+		}
+	}()
+
+	return done, nil
+}
+
+// collectNetworkEvents listens to the sync service and accumulates the
+// network events published by the sidecar. The events are delivered on the
+// returned channel once the context is canceled.
+func (r *LocalDockerRunner) collectNetworkEvents(ctx context.Context, tpl *runtime.RunParams) (chan []*api.NetworkEvent, error) {
+	ch := make(chan *api.NetworkEvent, 128)
+	if _, err := r.syncClient.Subscribe(ss.WithRunParams(ctx, tpl), sidecar.NetworkEventsTopic, ch); err != nil {
+		return nil, fmt.Errorf("failed to subscribe to network events: %w", err)
+	}
+
+	done := make(chan []*api.NetworkEvent, 1)
+	go func() {
+		var events []*api.NetworkEvent
+		for {
+			select {
+			case ev := <-ch:
+				events = append(events, ev)
+			case <-ctx.Done():
+				done <- events
+				return
+			}
+		}
+	}()
+
+	return done, nil
+}
+
+// collectTrafficStats listens to the sync service and keeps the last snapshot
+// of the socket statistics of every instance published by the sidecar. The
+// snapshots are delivered on the returned channel once the context is
+// canceled.
+func (r *LocalDockerRunner) collectTrafficStats(ctx context.Context, tpl *runtime.RunParams) (chan map[string]*sidecar.TrafficStatsSample, error) {
+	ch := make(chan *sidecar.TrafficStatsSample, 128)
+	if _, err := r.syncClient.Subscribe(ss.WithRunParams(ctx, tpl), sidecar.TrafficStatsTopic, ch); err != nil {
+		return nil, fmt.Errorf("failed to subscribe to traffic stats: %w", err)
+	}
+
+	done := make(chan map[string]*sidecar.TrafficStatsSample, 1)
+	go func() {
+		latest := make(map[string]*sidecar.TrafficStatsSample)
+		for {
+			select {
+			case s := <-ch:
+				if prev, ok := latest[s.Instance]; !ok || s.Time.After(prev.Time) {
+					latest[s.Instance] = s
+				}
+			case <-ctx.Done():
+				done <- latest
+				return
+			}
+		}
+	}()
+
+	return done, nil
+}
+
+// LocateOutputs returns the directory holding the outputs of a run.
+func (r *LocalDockerRunner) LocateOutputs(plan string, runID string) (string, error) {
+	r.lk.RLock()
+	defer r.lk.RUnlock()
+
+	return locateOutputs(r.outputsDir, plan, runID)
+}
+
+// PushParam publishes a parameter update to the instances of a live run, and
+// waits for the running instances it is addressed to to acknowledge it.
+func (r *LocalDockerRunner) PushParam(ctx context.Context, input *api.ParamPushInput, ow *rpc.OutputWriter) (*api.ParamPushOutput, error) {
+	instances, err := r.ListInstances(ctx, input.RunID)
+	if err != nil {
+		return nil, err
+	}
+
+	out := &api.ParamPushOutput{Update: input.Update}
+	for _, inst := range instances {
+		if inst.State == "running" && (input.Update.Group == "" || inst.GroupID == input.Update.Group) {
+			out.Expected++
+		}
+	}
+	if out.Expected == 0 {
+		return nil, fmt.Errorf("no running instances in run %s to push the update to", input.RunID)
+	}
+
+	if err := r.setupSyncClient(); err != nil {
+		return nil, fmt.Errorf("failed to set up sync client: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, input.Timeout)
+	defer cancel()
+
+	tpl := &runtime.RunParams{
+		TestPlan: input.TestPlan,
+		TestCase: input.TestCase,
+		TestRun:  input.RunID,
+	}
+	ctx = ss.WithRunParams(ctx, tpl)
+
+	// Subscribe before publishing, so that no acknowledgment is missed.
+	ch := make(chan *api.ParamAck, out.Expected)
+	if _, err := r.syncClient.Subscribe(ctx, ParamAcksTopic, ch); err != nil {
+		return nil, fmt.Errorf("failed to subscribe to param acks: %w", err)
+	}
+
+	if _, err := r.syncClient.Publish(ctx, ParamUpdatesTopic, input.Update); err != nil {
+		return nil, fmt.Errorf("failed to publish param update: %w", err)
+	}
+
+	ow.Infow("param update published; waiting for acknowledgments", "id", input.Update.ID, "expected", out.Expected)
+
+	out.Acks = awaitParamAcks(ctx, ch, input.Update.ID, out.Expected)
+	return out, nil
+}
+
+// InjectNetworkFault publishes a network fault to the sidecars of a running
+// run, and waits for the instances it is addressed to to apply it.
+func (r *LocalDockerRunner) InjectNetworkFault(ctx context.Context, input *api.NetworkFaultInput, ow *rpc.OutputWriter) (*api.NetworkFaultOutput, error) {
+	instances, err := r.ListInstances(ctx, input.RunID)
+	if err != nil {
+		return nil, err
+	}
+
+	out := &api.NetworkFaultOutput{Fault: input.Fault}
+	for _, inst := range instances {
+		if inst.State == "running" && (input.Fault.Group == "" || inst.GroupID == input.Fault.Group) {
+			out.Expected++
+		}
+	}
+	if out.Expected == 0 {
+		return nil, fmt.Errorf("no running instances in run %s to inject the fault in", input.RunID)
+	}
+
+	if input.Fault.Blackhole, err = resolveBlackholes(input.Fault.Blackhole, instances); err != nil {
+		return nil, err
+	}
+
+	if err := r.setupSyncClient(); err != nil {
+		return nil, fmt.Errorf("failed to set up sync client: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, input.Timeout)
+	defer cancel()
+
+	ctx = ss.WithRunParams(ctx, &runtime.RunParams{
+		TestPlan: input.TestPlan,
+		TestCase: input.TestCase,
+		TestRun:  input.RunID,
+	})
+
+	// Subscribe before publishing, so that no event is missed.
+	ch := make(chan *api.NetworkEvent, out.Expected)
+	if _, err := r.syncClient.Subscribe(ctx, sidecar.NetworkEventsTopic, ch); err != nil {
+		return nil, fmt.Errorf("failed to subscribe to network events: %w", err)
+	}
+
+	if _, err := r.syncClient.Publish(ctx, sidecar.NetworkFaultsTopic, input.Fault); err != nil {
+		return nil, fmt.Errorf("failed to publish network fault: %w", err)
+	}
+
+	ow.Infow("network fault published; waiting for the instances to apply it", "id", input.Fault.ID, "expected", out.Expected)
+
+	out.Events = awaitNetworkEvents(ctx, ch, input.Fault.ID, out.Expected)
+	return out, nil
+}
+
+// stateReachedTimeout bounds the wait for the sync service to confirm that
+// all the instances of a completed run signalled a state.
+const stateReachedTimeout = 5 * time.Second
+
+// StateReached checks with the sync service whether all the instances of a
+// run signalled a state.
+func (r *LocalDockerRunner) StateReached(ctx context.Context, input *api.RunInput, state string) (bool, error) {
+	if err := r.setupSyncClient(); err != nil {
+		return false, fmt.Errorf("failed to set up sync client: %w", err)
+	}
+
+	// The run is over, so the counter of the state no longer moves: the
+	// barrier is either satisfied right away, or never.
+	ctx, cancel := context.WithTimeout(ctx, stateReachedTimeout)
+	defer cancel()
+
+	ctx = ss.WithRunParams(ctx, &runtime.RunParams{
+		TestPlan: input.TestPlan,
+		TestCase: input.TestCase,
+		TestRun:  input.RunID,
+	})
+
+	b, err := r.syncClient.Barrier(ctx, ss.State(state), input.TotalInstances)
+	if err != nil {
+		return false, err
+	}
+
+	select {
+	case err := <-b.C:
+		return err == nil, nil
+	case <-ctx.Done():
+		return false, nil
+	}
+}
+
 func (r *LocalDockerRunner) prepareOutputDirectory(instance_id int, runenv *runtime.RunParams) (string, error) {
 	// <outputs_dir>/<plan>/<run_id>/<group_id>/<instance_number>
 	odir := filepath.Join(r.outputsDir, runenv.TestPlan, runenv.TestRun, runenv.TestGroupID, strconv.Itoa(instance_id))
@@ -324,7 +595,7 @@ func (r *LocalDockerRunner) Run(ctx context.Context, input *api.RunInput, ow *rp
 	}
 
 	// Create a data network.
-	dataNetworkID, subnet, err := newDataNetwork(ctx, cli, ow, input, "default")
+	dataNetworkID, subnet, subnet6, err := newDataNetwork(ctx, cli, ow, input, "default")
 	if err != nil {
 		return
 	}
@@ -343,6 +614,13 @@ func (r *LocalDockerRunner) Run(ctx context.Context, input *api.RunInput, ow *rp
 		TestSubnet:         &ptypes.IPNet{IPNet: *subnet},
 	}
 
+	snapshotsDir := filepath.Join(input.EnvConfig.Dirs().Snapshots(), "local_docker")
+
+	if err = writeOutputsSchema(filepath.Join(r.outputsDir, input.TestPlan, input.RunID), r.ID(), input); err != nil {
+		err = fmt.Errorf("failed to stamp the outputs schema: %w", err)
+		return
+	}
+
 	// Prepare the Runner Configuration.
 	cfg := defaultConfig
 	if err = mergo.Merge(&cfg, input.RunnerConfig, mergo.WithOverride); err != nil {
@@ -362,10 +640,46 @@ func (r *LocalDockerRunner) Run(ctx context.Context, input *api.RunInput, ow *rp
 	sharedEnv = append(sharedEnv, "REDIS_HOST=testground-redis")
 	// Inject exposed ports.
 	sharedEnv = append(sharedEnv, conv.ToOptionsSlice(cfg.ExposedPorts.ToEnvVars())...)
+	// Tell the instances and the sidecar the IP family of the data network.
+	sharedEnv = append(sharedEnv, conv.ToOptionsSlice(ipFamilyEnv(input.IPFamily, subnet6))...)
+	sharedEnv = append(sharedEnv, conv.ToOptionsSlice(runtimeAPIEnv(input))...)
 	// Set the log level if provided in cfg.
 	if cfg.LogLevel != "" {
 		sharedEnv = append(sharedEnv, "LOG_LEVEL="+cfg.LogLevel)
 	}
+	// Ask the sidecar to measure latencies if requested.
+	if cfg.LatencyHeatmap.Enabled && template.TestSidecar {
+		sharedEnv = append(sharedEnv, cfg.LatencyHeatmap.EnvVars()...)
+	}
+	// Ask the sidecar to collect socket statistics if requested.
+	if cfg.TrafficSummary.Enabled && template.TestSidecar {
+		sharedEnv = append(sharedEnv, cfg.TrafficSummary.EnvVars()...)
+	}
+
+	// Mount the outputs of the upstream runs, read-only.
+	var inputMounts []mount.Mount
+	if len(input.Inputs) > 0 {
+		sharedEnv = append(sharedEnv, EnvTestInputsPath+"=/inputs")
+		for id, dir := range input.Inputs {
+			inputMounts = append(inputMounts, mount.Mount{
+				Type:     mount.TypeBind,
+				Source:   dir,
+				Target:   "/inputs/" + id,
+				ReadOnly: true,
+			})
+		}
+	}
+
+	// Mount the fixtures, read-only.
+	if len(input.Fixtures) > 0 {
+		var dirs map[string]string
+		if dirs, err = fetchFixtures(ctx, input, ow); err != nil {
+			return
+		}
+		env, mounts := fixtureMounts(input.Fixtures, dirs)
+		sharedEnv = append(sharedEnv, env...)
+		inputMounts = append(inputMounts, mounts...)
+	}
 
 	// ## Create the containers
 	var (
@@ -380,106 +694,154 @@ func (r *LocalDockerRunner) Run(ctx context.Context, input *api.RunInput, ow *rp
 		}
 	}()
 
-	for _, g := range input.Groups {
-		reviewResources(g, ow)
-
-		runenv := template
-		runenv.TestGroupInstanceCount = g.Instances
-		runenv.TestGroupID = g.ID
-		runenv.TestInstanceParams = g.Parameters
-		runenv.TestCaptureProfiles = g.Profiles
-		// Prepare the group's environment variables.
-		env := make([]string, 0, len(sharedEnv)+len(runenv.ToEnvVars()))
+	_, createSpan := tracing.Start(ctx, "create containers", attribute.Int("count", input.TotalInstances))
+	defer createSpan.End()
+
+	// createContainer creates the container of the i-th instance of a group.
+	// It returns the container even if it failed to attach it to the data
+	// network, so that it is torn down with the others.
+	createContainer := func(g *api.RunGroup, runenv runtime.RunParams, i int) (testContainerInstance, error) {
+		// Prepare the instance's environment variables.
+		env := make([]string, 0, len(sharedEnv)+len(runenv.ToEnvVars())+1)
 		env = append(env, sharedEnv...)
 		env = append(env, conv.ToOptionsSlice(runenv.ToEnvVars())...)
-		logging.S().Infow("additional hosts", "hosts", strings.Join(cfg.AdditionalHosts, ","))
+		env = append(env, conv.ToOptionsSlice(g.Env)...)
 		env = append(env, fmt.Sprintf("ADDITIONAL_HOSTS=%s", strings.Join(cfg.AdditionalHosts, ",")))
 
-		// Start as many containers as group instances.
-		for i := 0; i < g.Instances; i++ {
-			// TODO: We should set the instance id in runenv and make this whole operation self contained around a local runenv.
-			tmpdir, err := r.prepareTemporaryDirectory(i, &runenv)
-			if err != nil {
-				return nil, fmt.Errorf("failed to prepare temporary directory: %w", err)
-			}
-			tmpdirs = append(tmpdirs, tmpdir)
+		// TODO: We should set the instance id in runenv and make this whole operation self contained around a local runenv.
+		tmpdir, err := r.prepareTemporaryDirectory(i, &runenv)
+		if err != nil {
+			return testContainerInstance{}, fmt.Errorf("failed to prepare temporary directory: %w", err)
+		}
+		tmpdirs = append(tmpdirs, tmpdir)
 
-			odir, err := r.prepareOutputDirectory(i, &runenv)
-			if err != nil {
-				return nil, fmt.Errorf("failed to prepare output directory: %w", err)
-			}
+		odir, err := r.prepareOutputDirectory(i, &runenv)
+		if err != nil {
+			return testContainerInstance{}, fmt.Errorf("failed to prepare output directory: %w", err)
+		}
 
-			// TODO: runenv.TestRun == input.RunID. Refactor into a single name.
-			name := fmt.Sprintf("tg-%s-%s-%s-%s-%d", runenv.TestPlan, runenv.TestCase, runenv.TestRun, runenv.TestGroupID, i)
-			log.Infow("creating container", "name", name)
-
-			ccfg := &container.Config{
-				Image:        g.ArtifactPath,
-				ExposedPorts: ports,
-				Env:          env,
-				Labels: map[string]string{
-					"testground.purpose":  "plan",
-					"testground.plan":     runenv.TestPlan,
-					"testground.testcase": runenv.TestCase,
-					"testground.run_id":   runenv.TestRun,
-					"testground.group_id": runenv.TestGroupID,
-				},
-			}
+		// TODO: runenv.TestRun == input.RunID. Refactor into a single name.
+		name := fmt.Sprintf("tg-%s-%s-%s-%s-%d", runenv.TestPlan, runenv.TestCase, runenv.TestRun, runenv.TestGroupID, i)
+		log.Infow("creating container", "name", name)
 
-			hcfg := &container.HostConfig{
-				NetworkMode:     container.NetworkMode("testground-control"),
-				PublishAllPorts: true,
-				Mounts: []mount.Mount{{
-					Type:   mount.TypeBind,
-					Source: odir,
-					Target: runenv.TestOutputsPath,
-				}, {
-					Type:   mount.TypeBind,
-					Source: tmpdir,
-					Target: runenv.TestTempPath,
-				}},
-			}
+		exposed, bindings, err := publishedPorts(ports, g.Publish, i)
+		if err != nil {
+			return testContainerInstance{}, fmt.Errorf("failed to publish the ports of group %s: %w", g.ID, err)
+		}
 
-			if len(cfg.Ulimits) > 0 {
-				ulimits, err := conv.ToUlimits(cfg.Ulimits)
-				if err == nil {
-					hcfg.Resources = container.Resources{Ulimits: ulimits}
-				} else {
-					ow.Warnf("invalid ulimit will be ignored %v", err)
-				}
+		ccfg := &container.Config{
+			Image:        g.ArtifactPath,
+			ExposedPorts: exposed,
+			Env:          env,
+			Labels: map[string]string{
+				"testground.purpose":     "plan",
+				"testground.plan":        runenv.TestPlan,
+				"testground.testcase":    runenv.TestCase,
+				"testground.run_id":      runenv.TestRun,
+				"testground.group_id":    runenv.TestGroupID,
+				"testground.group_index": strconv.Itoa(i),
+			},
+		}
+
+		hcfg := &container.HostConfig{
+			NetworkMode:     container.NetworkMode("testground-control"),
+			PublishAllPorts: true,
+			PortBindings:    bindings,
+			Mounts: []mount.Mount{{
+				Type:   mount.TypeBind,
+				Source: odir,
+				Target: runenv.TestOutputsPath,
+			}, {
+				Type:   mount.TypeBind,
+				Source: tmpdir,
+				Target: runenv.TestTempPath,
+			}},
+		}
+		hcfg.Mounts = append(hcfg.Mounts, inputMounts...)
+		if g.Volume != nil {
+			hcfg.Mounts = append(hcfg.Mounts, volumeMount(name, g.Volume))
+		}
+
+		if len(cfg.Ulimits) > 0 {
+			ulimits, err := conv.ToUlimits(cfg.Ulimits)
+			if err == nil {
+				hcfg.Resources = container.Resources{Ulimits: ulimits}
+			} else {
+				ow.Warnf("invalid ulimit will be ignored %v", err)
 			}
+		}
 
-			// Create the container.
-			var res container.ContainerCreateCreatedBody
-			res, err = cli.ContainerCreate(ctx, ccfg, hcfg, nil, name)
-			if err != nil {
-				return nil, fmt.Errorf("failed to create container: %w", err)
+		// Create the container.
+		res, err := cli.ContainerCreate(ctx, ccfg, hcfg, nil, name)
+		if err != nil {
+			return testContainerInstance{}, fmt.Errorf("failed to create container: %w", err)
+		}
+
+		c := testContainerInstance{
+			containerID: res.ID,
+			name:        name,
+			groupID:     g.ID,
+			groupIdx:    i,
+		}
+
+		if g.Volume != nil && g.Volume.FromSnapshot != "" {
+			if err := restoreVolume(ctx, cli, snapshotsDir, c, g.Volume, ow); err != nil {
+				return c, err
 			}
+		}
+
+		// TODO: Remove this when we get the sidecar working. It'll do this for us.
+		if err := attachContainerToNetwork(ctx, cli, res.ID, dataNetworkID); err != nil {
+			return c, fmt.Errorf("failed to attach container to network: %w", err)
+		}
+		return c, nil
+	}
+
+	for _, g := range input.Groups {
+		reviewResources(ctx, g, ow)
+		api.RecordDecision(ctx, api.DecisionStagePlacement, "%d instances of group %s placed on the local docker host", g.Instances, g.ID)
+
+		runenv := groupRunEnv(template, g)
+		logging.S().Infow("additional hosts", "hosts", strings.Join(cfg.AdditionalHosts, ","))
 
-			container := testContainerInstance{
-				containerID: res.ID,
-				groupID:     g.ID,
-				groupIdx:    i,
+		if g.Volume != nil && g.Volume.FromSnapshot != "" {
+			if err = checkSnapshot(snapshotsDir, g.Volume); err != nil {
+				return nil, err
 			}
-			containers = append(containers, container)
+		}
 
-			// TODO: Remove this when we get the sidecar working. It'll do this for us.
-			err = attachContainerToNetwork(ctx, cli, res.ID, dataNetworkID)
+		// Start as many containers as group instances.
+		for i := 0; i < g.Instances; i++ {
+			c, err := createContainer(g, runenv, i)
+			if c.containerID != "" {
+				containers = append(containers, c)
+			}
 			if err != nil {
-				return nil, fmt.Errorf("failed to attach container to network: %w", err)
+				return nil, err
 			}
 		}
 	}
 
+	createSpan.End()
+
+	scaling := newDockerScaling(input, template, containers)
+	scaling.create = createContainer
+
 	if !cfg.KeepContainers {
 		defer func() {
-			ids := make([]string, 0, len(containers))
-			for _, c := range containers {
+			_, span := tracing.Start(ctx, "teardown")
+			defer span.End()
+
+			// Tear down the instances added while the run was in progress too.
+			all := scaling.all()
+			ids := make([]string, 0, len(all))
+			for _, c := range all {
 				ids = append(ids, c.containerID)
 			}
 			if err := docker.DeleteContainers(cli, log, ids); err != nil {
 				log.Errorw("failed to delete containers", "err", err)
 			}
+			removeVolumes(cli, input.Groups, all, log)
 			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 			defer cancel()
 			if err := cli.NetworkRemove(ctx, dataNetworkID); err != nil {
@@ -488,6 +850,11 @@ func (r *LocalDockerRunner) Run(ctx context.Context, input *api.RunInput, ow *rp
 		}()
 	}
 
+	// Snapshot the volumes of the instances before they're torn down.
+	defer func() {
+		snapshotVolumes(cli, snapshotsDir, input.RunID, input.Groups, scaling.all(), log)
+	}()
+
 	// If an error occurred interim, abort.
 	if err != nil {
 		log.Error(err)
@@ -506,13 +873,78 @@ func (r *LocalDockerRunner) Run(ctx context.Context, input *api.RunInput, ow *rp
 	}()
 
 	// First we collect every container outcomes.
-	outcomesCollectIsCompleteCh, err := r.collectOutcomes(runCtx, result, &template)
+	outcomesCollectIsCompleteCh, err := r.collectOutcomes(runCtx, result, &template, scaling)
 	if err != nil {
 		log.Error(err)
 		return
 	}
 
+	// Collect the latency samples measured by the sidecar, if any.
+	if cfg.LatencyHeatmap.Enabled && template.TestSidecar {
+		var samplesCh chan []*sidecar.LatencySample
+		samplesCh, err = r.collectLatencySamples(runCtx, &template)
+		if err != nil {
+			log.Error(err)
+			return
+		}
+
+		defer func() {
+			cancelRun()
+			path := filepath.Join(r.outputsDir, template.TestPlan, template.TestRun, LatencyHeatmapFile)
+			if err := writeLatencyHeatmap(path, <-samplesCh); err != nil {
+				log.Warnw("failed to write latency heatmap", "err", err)
+			}
+		}()
+	}
+
+	// Collect the socket statistics snapshots taken by the sidecar, if any.
+	if cfg.TrafficSummary.Enabled && template.TestSidecar {
+		var statsCh chan map[string]*sidecar.TrafficStatsSample
+		statsCh, err = r.collectTrafficStats(runCtx, &template)
+		if err != nil {
+			log.Error(err)
+			return
+		}
+
+		defer func() {
+			cancelRun()
+			path := filepath.Join(r.outputsDir, template.TestPlan, template.TestRun, TrafficSummaryFile)
+			if err := writeTrafficSummary(path, <-statsCh); err != nil {
+				log.Warnw("failed to write traffic summary", "err", err)
+			}
+		}()
+	}
+
+	// Collect the changes the sidecar applied to the data network, if any.
+	if template.TestSidecar {
+		var eventsCh chan []*api.NetworkEvent
+		eventsCh, err = r.collectNetworkEvents(runCtx, &template)
+		if err != nil {
+			log.Error(err)
+			return
+		}
+
+		defer func() {
+			cancelRun()
+			path := filepath.Join(r.outputsDir, template.TestPlan, template.TestRun, NetworkTimelineFile)
+			if err := writeNetworkTimeline(path, <-eventsCh); err != nil {
+				log.Warnw("failed to write network timeline", "err", err)
+			}
+		}()
+	}
+
+	// Account for the resources the instances use, before they're torn down.
+	usage := newDockerUsage(runCtx, cli, log)
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		result.Usage = usage.collect(ctx)
+	}()
+
 	// Second we start the containers
+	_, startSpan := tracing.Start(runCtx, "start containers", attribute.Int("count", len(containers)))
+	defer startSpan.End()
+
 	log.Infow("starting containers", "count", len(containers))
 	var (
 		startGroup, startGroupCtx = errgroup.WithContext(runCtx)
@@ -532,6 +964,8 @@ func (r *LocalDockerRunner) Run(ctx context.Context, input *api.RunInput, ow *rp
 			err := cli.ContainerStart(startGroupCtx, c.containerID, types.ContainerStartOptions{})
 			if err == nil {
 				log.Debugw("started container", "id", c.containerID, "group", c.groupID, "group_index", c.groupIdx)
+				api.EmitEvent(ctx, api.Event{Type: api.EventInstanceStarted, Group: c.groupID, Instance: c.name})
+				usage.track(c)
 				select {
 				case <-startGroupCtx.Done():
 				default:
@@ -616,36 +1050,131 @@ func (r *LocalDockerRunner) Run(ctx context.Context, input *api.RunInput, ow *rp
 
 	// Wait for all container to have started
 	err = startGroup.Wait()
+	tracing.End(startSpan, err)
 	if err != nil {
 		log.Error(err)
 		return
 	}
 
+	// Report where the published ports of the instances are reachable.
+	publish := make(map[string][]api.PublishedPort)
+	for _, g := range input.Groups {
+		if len(g.Publish) > 0 {
+			publish[g.ID] = g.Publish
+		}
+	}
+	if len(publish) > 0 {
+		if result.Ports, err = publishedAddrs(runCtx, cli, containers, publish); err != nil {
+			log.Error(err)
+			return
+		}
+		for _, c := range containers {
+			for port, addr := range result.Ports[c.name] {
+				ow.Infow("published port", "instance", c.name, "group", c.groupID, "group_index", c.groupIdx, "port", port, "addr", addr)
+			}
+		}
+	}
+
+	// Instances that crash are diagnosed right away.
+	var diagnostics *dockerDiagnostics
+	if input.Diagnostics != nil {
+		diagnostics = newDockerDiagnostics(cli, input, r.outputsDir, r.controlNetworkID, log)
+	}
+
+	_, waitSpan := tracing.Start(runCtx, "wait for containers")
+	defer waitSpan.End()
+
 	// Finally, we're going to follow our containers until they are done
+	waitContainer := func(c testContainerInstance) func() error {
+		return func() error {
+			defer scaling.done()
 
-	for _, c := range containers {
-		c := c
-		f := func() error {
-			log.Infow("waiting for container", "id", c.containerID, "group", c.groupID, "group_index", c.groupIdx)
+			for {
+				log.Infow("waiting for container", "id", c.containerID, "group", c.groupID, "group_index", c.groupIdx)
 
-			statusCh, errCh := cli.ContainerWait(runCtx, c.containerID, container.WaitConditionNotRunning)
+				statusCh, errCh := cli.ContainerWait(runCtx, c.containerID, container.WaitConditionNotRunning)
 
-			select {
-			case err := <-errCh:
-				log.Infow("container failed", "id", c.containerID, "group", c.groupID, "group_index", c.groupIdx, "error", err)
-				if err != nil {
-					return err
+				select {
+				case err := <-errCh:
+					log.Infow("container failed", "id", c.containerID, "group", c.groupID, "group_index", c.groupIdx, "error", err)
+					if err != nil {
+						return err
+					}
+					return nil
+				case status := <-statusCh:
+					log.Infow("container exited", "id", c.containerID, "group", c.groupID, "group_index", c.groupIdx, "status", status.StatusCode)
+					// Containers stopped by the chaos controller are waited
+					// for again once restored.
+					if r.awaitChaosRestore(runGroupCtx, cli, c.containerID) {
+						continue
+					}
+					if status.StatusCode != 0 {
+						api.EmitEvent(ctx, api.Event{Type: api.EventInstanceCrashed, Group: c.groupID, Instance: c.name, ExitCode: int(status.StatusCode)})
+					}
+					if status.StatusCode != 0 && diagnostics != nil {
+						diagnostics.diagnose(c)
+					}
+					return nil
+				case <-runGroupCtx.Done(): // race with the group
+					log.Infow("container group exited", "err", runGroupCtx.Err())
+					return nil
 				}
-				return nil
-			case status := <-statusCh:
-				log.Infow("container exited", "id", c.containerID, "group", c.groupID, "group_index", c.groupIdx, "status", status.StatusCode)
-				return nil
-			case <-runGroupCtx.Done(): // race with the group
-				log.Infow("container group exited", "err", runGroupCtx.Err())
-				return nil
 			}
 		}
-		runGroup.Go(f)
+	}
+
+	// Instances added while the run is in progress are started and waited
+	// for like the others.
+	scaling.launch = func(ctx context.Context, c testContainerInstance) error {
+		if err := cli.ContainerStart(ctx, c.containerID, types.ContainerStartOptions{}); err != nil {
+			return err
+		}
+		api.EmitEvent(runCtx, api.Event{Type: api.EventInstanceStarted, Group: c.groupID, Instance: c.name})
+		usage.track(c)
+		if !cfg.Background {
+			go func() {
+				select {
+				case started <- c:
+				case <-runCtx.Done():
+				}
+			}()
+		}
+		runGroup.Go(waitContainer(c))
+		return nil
+	}
+
+	scaling.lk.Lock()
+	for _, c := range containers {
+		scaling.wait()
+		runGroup.Go(waitContainer(c))
+	}
+	scaling.closed = scaling.active == 0
+	scaling.lk.Unlock()
+
+	r.registerScaling(input.RunID, scaling)
+	defer func() {
+		r.unregisterScaling(input.RunID)
+		scaling.close(nil)
+	}()
+
+	// Keep the instances of a failed run alive for inspection, before it's
+	// torn down.
+	if input.PauseOnFailure > 0 {
+		defer func() {
+			if failedRun(ctx, result, err) {
+				pauseOnFailure(ctx, cli, input, result, scaling.all(), log)
+			}
+		}()
+	}
+
+	// Capture the diagnostics of the instances of a failed run, before it's
+	// paused or torn down.
+	if diagnostics != nil {
+		defer func() {
+			if diagnosedRun(ctx, result, err) {
+				diagnostics.diagnoseAll(scaling.all())
+			}
+		}()
 	}
 
 	// When we're here, our containers are started, the outcomes are being collected.
@@ -692,7 +1221,9 @@ func (r *LocalDockerRunner) Run(ctx context.Context, input *api.RunInput, ow *rp
 	return
 }
 
-func newDataNetwork(ctx context.Context, cli *client.Client, rw *rpc.OutputWriter, env *api.RunInput, name string) (id string, subnet *net.IPNet, err error) {
+// newDataNetwork creates a data network, with an IPv6 subnet too if the IP
+// family of the run requests one.
+func newDataNetwork(ctx context.Context, cli *client.Client, rw *rpc.OutputWriter, env *api.RunInput, name string) (id string, subnet, subnet6 *net.IPNet, err error) {
 	// Find a free network.
 	networks, err := cli.NetworkList(ctx, types.NetworkListOptions{
 		Filters: filters.NewArgs(
@@ -703,12 +1234,27 @@ func newDataNetwork(ctx context.Context, cli *client.Client, rw *rpc.OutputWrite
 		),
 	})
 	if err != nil {
-		return "", nil, err
+		return "", nil, nil, err
 	}
 
 	subnet, gateway, err := nextDataNetwork(len(networks))
 	if err != nil {
-		return "", nil, err
+		return "", nil, nil, err
+	}
+
+	ipam := []network.IPAMConfig{{
+		Subnet:  subnet.String(),
+		Gateway: gateway,
+	}}
+	if env.IPFamily.HasIPv6() {
+		var gateway6 string
+		if subnet6, gateway6, err = dataNetworkV6(subnet); err != nil {
+			return "", nil, nil, err
+		}
+		ipam = append(ipam, network.IPAMConfig{
+			Subnet:  subnet6.String(),
+			Gateway: gateway6,
+		})
 	}
 
 	id, err = docker.NewBridgeNetwork(
@@ -722,13 +1268,10 @@ func newDataNetwork(ctx context.Context, cli *client.Client, rw *rpc.OutputWrite
 			"testground.run_id":   env.RunID,
 			"testground.name":     name,
 		},
-		network.IPAMConfig{
-			Subnet:  subnet.String(),
-			Gateway: gateway,
-		},
+		ipam...,
 	)
 
-	return id, subnet, err
+	return id, subnet, subnet6, err
 }
 
 func (r *LocalDockerRunner) CollectOutputs(ctx context.Context, input *api.CollectionInput, ow *rpc.OutputWriter) error {
@@ -766,6 +1309,142 @@ func (*LocalDockerRunner) CompatibleBuilders() []string {
 	return []string{"docker:go", "docker:node", "docker:generic"}
 }
 
+// ListInstances lists the containers of a run, with their address on the data
+// network and their published ports.
+func (*LocalDockerRunner) ListInstances(ctx context.Context, runID string) ([]*api.Instance, error) {
+	cli, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
+	if err != nil {
+		return nil, err
+	}
+	defer cli.Close()
+
+	containers, err := cli.ContainerList(ctx, types.ContainerListOptions{
+		Filters: filters.NewArgs(
+			filters.Arg("label", "testground.purpose=plan"),
+			filters.Arg("label", "testground.run_id="+runID),
+		),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list containers: %w", err)
+	}
+
+	instances := make([]*api.Instance, 0, len(containers))
+	for _, c := range containers {
+		inst := &api.Instance{
+			Name:    strings.TrimPrefix(c.Names[0], "/"),
+			GroupID: c.Labels["testground.group_id"],
+			State:   c.State,
+		}
+
+		if c.NetworkSettings != nil {
+			for name, n := range c.NetworkSettings.Networks {
+				if name != "testground-control" {
+					inst.DataIP = n.IPAddress
+				}
+			}
+		}
+
+		for _, p := range c.Ports {
+			if inst.Ports == nil {
+				inst.Ports = make(map[string]string, len(c.Ports))
+			}
+			var published string
+			if p.PublicPort != 0 {
+				published = net.JoinHostPort(p.IP, strconv.Itoa(int(p.PublicPort)))
+			}
+			inst.Ports[fmt.Sprintf("%d/%s", p.PrivatePort, p.Type)] = published
+		}
+
+		instances = append(instances, inst)
+	}
+
+	sort.Slice(instances, func(i, j int) bool {
+		return instances[i].Name < instances[j].Name
+	})
+
+	return instances, nil
+}
+
+// ListRuns returns the IDs of the runs that have plan containers, running or
+// not, or data networks.
+func (*LocalDockerRunner) ListRuns(ctx context.Context) ([]string, error) {
+	cli, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
+	if err != nil {
+		return nil, err
+	}
+
+	containers, err := cli.ContainerList(ctx, types.ContainerListOptions{
+		All:     true,
+		Filters: filters.NewArgs(filters.Arg("label", "testground.purpose=plan")),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list containers: %w", err)
+	}
+	networks, err := cli.NetworkList(ctx, types.NetworkListOptions{
+		Filters: filters.NewArgs(filters.Arg("label", "testground.run_id")),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list networks: %w", err)
+	}
+
+	seen := make(map[string]struct{})
+	for _, c := range containers {
+		seen[c.Labels["testground.run_id"]] = struct{}{}
+	}
+	for _, n := range networks {
+		seen[n.Labels["testground.run_id"]] = struct{}{}
+	}
+	delete(seen, "")
+
+	runs := make([]string, 0, len(seen))
+	for id := range seen {
+		runs = append(runs, id)
+	}
+	sort.Strings(runs)
+	return runs, nil
+}
+
+// RemoveRun deletes the plan containers and the data networks of a run.
+func (*LocalDockerRunner) RemoveRun(ctx context.Context, runID string, ow *rpc.OutputWriter) error {
+	cli, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
+	if err != nil {
+		return err
+	}
+
+	containers, err := cli.ContainerList(ctx, types.ContainerListOptions{
+		All: true,
+		Filters: filters.NewArgs(
+			filters.Arg("label", "testground.purpose=plan"),
+			filters.Arg("label", "testground.run_id="+runID),
+		),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to list containers: %w", err)
+	}
+
+	ids := make([]string, 0, len(containers))
+	for _, c := range containers {
+		ids = append(ids, c.ID)
+	}
+	if err := docker.DeleteContainers(cli, ow, ids); err != nil {
+		return err
+	}
+
+	networks, err := cli.NetworkList(ctx, types.NetworkListOptions{
+		Filters: filters.NewArgs(filters.Arg("label", "testground.run_id="+runID)),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to list networks: %w", err)
+	}
+	for _, n := range networks {
+		ow.Infow("removing network", "network", n.Name)
+		if err := cli.NetworkRemove(ctx, n.ID); err != nil {
+			return fmt.Errorf("failed to remove network %s: %w", n.Name, err)
+		}
+	}
+	return nil
+}
+
 // This method deletes the testground containers.
 // It does *not* delete any downloaded images or networks.
 // I'll leave a friendly message for how to do a more complete cleanup.
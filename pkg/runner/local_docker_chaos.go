@@ -0,0 +1,105 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/client"
+
+	"github.com/testground/testground/pkg/api"
+)
+
+// InjectChaos kills, pauses or restarts a container of a live run, or starts
+// or resumes it again.
+func (r *LocalDockerRunner) InjectChaos(ctx context.Context, input *api.ChaosInput) error {
+	cli, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
+	if err != nil {
+		return err
+	}
+
+	info, err := cli.ContainerInspect(ctx, input.Instance.Name)
+	if err != nil {
+		return fmt.Errorf("failed to inspect container %s: %w", input.Instance.Name, err)
+	}
+	id := info.ID
+
+	switch input.Action {
+	case api.ChaosKill:
+		// Hold the container before killing it, so that the run waits for it
+		// to be started again.
+		if input.Restore {
+			r.holdContainer(id)
+		}
+		if err := cli.ContainerKill(ctx, id, "SIGKILL"); err != nil {
+			r.releaseContainer(id)
+			return err
+		}
+		return nil
+
+	case api.ChaosStart:
+		defer r.releaseContainer(id)
+		return cli.ContainerStart(ctx, id, types.ContainerStartOptions{})
+
+	case api.ChaosRestart:
+		r.holdContainer(id)
+		defer r.releaseContainer(id)
+		var timeout time.Duration
+		return cli.ContainerRestart(ctx, id, &timeout)
+
+	case api.ChaosPause:
+		return cli.ContainerPause(ctx, id)
+
+	case api.ChaosResume:
+		return cli.ContainerUnpause(ctx, id)
+
+	default:
+		return fmt.Errorf("unsupported chaos action %s", input.Action)
+	}
+}
+
+// holdContainer marks a container as stopped by the chaos controller, until
+// it is released.
+func (r *LocalDockerRunner) holdContainer(id string) {
+	r.chaosLk.Lock()
+	defer r.chaosLk.Unlock()
+
+	if r.chaosHeld == nil {
+		r.chaosHeld = make(map[string]chan struct{})
+	}
+	if _, ok := r.chaosHeld[id]; !ok {
+		r.chaosHeld[id] = make(chan struct{})
+	}
+}
+
+// releaseContainer releases a container held by the chaos controller.
+func (r *LocalDockerRunner) releaseContainer(id string) {
+	r.chaosLk.Lock()
+	defer r.chaosLk.Unlock()
+
+	if ch, ok := r.chaosHeld[id]; ok {
+		close(ch)
+		delete(r.chaosHeld, id)
+	}
+}
+
+// awaitChaosRestore is called when a container of a run exited. It waits for
+// the container to be released if the chaos controller holds it, and
+// returns whether it is running again.
+func (r *LocalDockerRunner) awaitChaosRestore(ctx context.Context, cli *client.Client, id string) bool {
+	r.chaosLk.Lock()
+	ch, held := r.chaosHeld[id]
+	r.chaosLk.Unlock()
+
+	if held {
+		select {
+		case <-ch:
+		case <-ctx.Done():
+			return false
+		}
+	}
+
+	info, err := cli.ContainerInspect(ctx, id)
+	return err == nil && info.State != nil && info.State.Running
+}
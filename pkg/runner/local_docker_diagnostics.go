@@ -0,0 +1,134 @@
+package runner
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/client"
+	"github.com/docker/docker/pkg/stdcopy"
+
+	"github.com/testground/testground/pkg/api"
+	"github.com/testground/testground/pkg/rpc"
+)
+
+// dockerDiagnostics captures the diagnostics of the instances of a
+// local:docker run, once per instance: when it crashes, or when the run
+// fails, before the run is torn down.
+type dockerDiagnostics struct {
+	cli         *client.Client
+	diagnostics *api.Diagnostics
+	// outputsDir is the directory of the outputs of the run.
+	outputsDir       string
+	controlNetworkID string
+	log              *rpc.OutputWriter
+
+	lk        sync.Mutex
+	diagnosed map[string]bool
+}
+
+func newDockerDiagnostics(cli *client.Client, input *api.RunInput, outputsDir, controlNetworkID string, log *rpc.OutputWriter) *dockerDiagnostics {
+	return &dockerDiagnostics{
+		cli:              cli,
+		diagnostics:      input.Diagnostics,
+		outputsDir:       filepath.Join(outputsDir, input.TestPlan, input.RunID),
+		controlNetworkID: controlNetworkID,
+		log:              log,
+		diagnosed:        make(map[string]bool),
+	}
+}
+
+// diagnoseAll captures the diagnostics of the instances not diagnosed yet.
+func (d *dockerDiagnostics) diagnoseAll(containers []testContainerInstance) {
+	for _, c := range containers {
+		d.diagnose(c)
+	}
+}
+
+// diagnose captures the diagnostics of an instance, unless it already was.
+func (d *dockerDiagnostics) diagnose(c testContainerInstance) {
+	d.lk.Lock()
+	if d.diagnosed[c.containerID] {
+		d.lk.Unlock()
+		return
+	}
+	d.diagnosed[c.containerID] = true
+	d.lk.Unlock()
+
+	dir := filepath.Join(d.outputsDir, c.groupID, strconv.Itoa(c.groupIdx), diagnosticsDir)
+	in := instanceDiagnostics{
+		logs: func(ctx context.Context, lines int) ([]byte, error) {
+			return containerLogTail(ctx, d.cli, c.containerID, lines)
+		},
+		write: func(name string, data []byte) error {
+			if err := os.MkdirAll(dir, 0777); err != nil {
+				return err
+			}
+			return ioutil.WriteFile(filepath.Join(dir, name), data, 0666)
+		},
+	}
+
+	// Profiles are fetched on the control network, which the daemon reaches.
+	ctx, cancel := context.WithTimeout(context.Background(), probeTimeout)
+	defer cancel()
+	if info, err := d.cli.ContainerInspect(ctx, c.containerID); err == nil && info.State.Running {
+		if n, ok := info.NetworkSettings.Networks[d.controlNetworkID]; ok && n.IPAddress != "" {
+			in.running = true
+			in.profile = func(ctx context.Context, port int, urlPath string) ([]byte, error) {
+				return httpGet(ctx, "http://"+net.JoinHostPort(n.IPAddress, strconv.Itoa(port))+urlPath)
+			}
+		}
+	}
+
+	if err := captureDiagnostics(d.diagnostics, in); err != nil {
+		d.log.Warnw("failed to capture some diagnostics of instance", "instance", c.name, "dir", dir, "err", err)
+		return
+	}
+	d.log.Infow("captured diagnostics of instance", "instance", c.name, "dir", dir)
+}
+
+// containerLogTail returns the last lines of the logs of a container, stdout
+// and stderr interleaved.
+func containerLogTail(ctx context.Context, cli *client.Client, id string, lines int) ([]byte, error) {
+	stream, err := cli.ContainerLogs(ctx, id, types.ContainerLogsOptions{
+		ShowStdout: true,
+		ShowStderr: true,
+		Tail:       strconv.Itoa(lines),
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer stream.Close()
+
+	var buf bytes.Buffer
+	if _, err := stdcopy.StdCopy(&buf, &buf, stream); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// httpGet returns the body of the response to a GET request of the url.
+func httpGet(ctx context.Context, url string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status: %s", resp.Status)
+	}
+	return ioutil.ReadAll(resp.Body)
+}
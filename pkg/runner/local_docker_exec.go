@@ -0,0 +1,218 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/client"
+	"github.com/docker/docker/pkg/stdcopy"
+
+	"github.com/testground/testground/pkg/api"
+	"github.com/testground/testground/pkg/docker"
+	"github.com/testground/testground/pkg/rpc"
+	"github.com/testground/testground/pkg/task"
+)
+
+// Failed local:docker runs that pause keep the containers of the instances
+// still running, and restart the failed instances that exited from a commit
+// of their container, idling, so that they can be inspected. These copies
+// are labelled testground.paused.
+
+// instanceContainer returns the running container of an instance.
+func instanceContainer(ctx context.Context, cli *client.Client, runID, group string, idx int) (*types.Container, error) {
+	list := func(all bool) ([]types.Container, error) {
+		return cli.ContainerList(ctx, types.ContainerListOptions{
+			All: all,
+			Filters: filters.NewArgs(
+				filters.Arg("label", "testground.purpose=plan"),
+				filters.Arg("label", "testground.run_id="+runID),
+				filters.Arg("label", "testground.group_id="+group),
+				filters.Arg("label", "testground.group_index="+strconv.Itoa(idx)),
+			),
+		})
+	}
+
+	containers, err := list(false)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list containers: %w", err)
+	}
+	if len(containers) > 0 {
+		return &containers[0], nil
+	}
+
+	// Tell instances that exited from those that don't exist.
+	if containers, err = list(true); err == nil && len(containers) > 0 {
+		return nil, fmt.Errorf("instance %d of group %s is not running (state: %s); run with --pause-on-failure to inspect failed instances", idx, group, containers[0].State)
+	}
+	return nil, fmt.Errorf("run %s has no instance %d in group %s", runID, idx, group)
+}
+
+// Exec runs a command in the container of an instance of a live run.
+func (*LocalDockerRunner) Exec(ctx context.Context, input *api.ExecInput) (*api.ExecOutput, error) {
+	cli, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
+	if err != nil {
+		return nil, err
+	}
+	defer cli.Close()
+
+	c, err := instanceContainer(ctx, cli, input.RunID, input.Group, input.Instance)
+	if err != nil {
+		return nil, err
+	}
+	name := strings.TrimPrefix(c.Names[0], "/")
+
+	exec, err := cli.ContainerExecCreate(ctx, c.ID, types.ExecConfig{
+		Cmd:          input.Cmd,
+		Tty:          input.Tty,
+		AttachStdin:  input.Stdin != nil,
+		AttachStdout: true,
+		AttachStderr: true,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to exec in %s: %w", name, err)
+	}
+
+	resp, err := cli.ContainerExecAttach(ctx, exec.ID, types.ExecStartCheck{Tty: input.Tty})
+	if err != nil {
+		return nil, fmt.Errorf("failed to attach to exec in %s: %w", name, err)
+	}
+	defer resp.Close()
+
+	if input.Stdin != nil {
+		go func() {
+			_, _ = io.Copy(resp.Conn, input.Stdin)
+			_ = resp.CloseWrite()
+		}()
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		var err error
+		if input.Tty {
+			_, err = io.Copy(input.Stdout, resp.Reader)
+		} else {
+			_, err = stdcopy.StdCopy(input.Stdout, input.Stderr, resp.Reader)
+		}
+		done <- err
+	}()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			return nil, err
+		}
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	info, err := cli.ContainerExecInspect(ctx, exec.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to inspect exec in %s: %w", name, err)
+	}
+	return &api.ExecOutput{Instance: name, ExitCode: info.ExitCode}, nil
+}
+
+// pauseOnFailure keeps the instances of a failed run alive for inspection,
+// until the pause is over or the run is canceled.
+func pauseOnFailure(ctx context.Context, cli *client.Client, input *api.RunInput, result *Result, containers []testContainerInstance, ow *rpc.OutputWriter) {
+	var (
+		paused []string
+		images []string
+	)
+	defer func() {
+		if len(paused) > 0 {
+			if err := docker.DeleteContainers(cli, ow, paused); err != nil {
+				ow.Errorw("failed to delete the paused instances", "err", err)
+			}
+		}
+		for _, img := range images {
+			if _, err := cli.ImageRemove(context.Background(), img, types.ImageRemoveOptions{Force: true}); err != nil {
+				ow.Warnw("failed to remove the image of a paused instance", "image", img, "err", err)
+			}
+		}
+	}()
+
+	for _, c := range containers {
+		info, err := cli.ContainerInspect(ctx, c.containerID)
+		if err != nil {
+			ow.Warnw("failed to inspect instance", "instance", c.name, "err", err)
+			continue
+		}
+
+		failed := info.State.ExitCode != 0
+		if o := result.Outcomes[c.groupID]; o != nil && o.Ok < o.Total {
+			failed = true
+		}
+		switch {
+		case info.State.Running:
+		case failed:
+			id, img, err := restartIdle(ctx, cli, c, info, input.PauseOnFailure)
+			if img != "" {
+				images = append(images, img)
+			}
+			if id != "" {
+				paused = append(paused, id)
+			}
+			if err != nil {
+				ow.Warnw("failed to keep instance alive", "instance", c.name, "err", err)
+				continue
+			}
+		default:
+			continue
+		}
+		ow.Infow("instance kept alive for inspection", "instance", c.name,
+			"exec", fmt.Sprintf("testground exec --task %s --group %s --instance %d -- /bin/sh", input.RunID, c.groupID, c.groupIdx))
+	}
+
+	ow.Infow("run failed; pausing before tearing it down; cancel the task to tear it down earlier", "pause", input.PauseOnFailure)
+	select {
+	case <-time.After(input.PauseOnFailure):
+	case <-ctx.Done():
+	}
+}
+
+// restartIdle starts a copy of the exited container of an instance, from a
+// commit of the container, that idles for d. It returns the ids of the copy
+// and of the commit.
+func restartIdle(ctx context.Context, cli *client.Client, c testContainerInstance, info types.ContainerJSON, d time.Duration) (string, string, error) {
+	commit, err := cli.ContainerCommit(ctx, c.containerID, types.ContainerCommitOptions{})
+	if err != nil {
+		return "", "", fmt.Errorf("failed to commit container: %w", err)
+	}
+
+	labels := make(map[string]string, len(info.Config.Labels)+1)
+	for k, v := range info.Config.Labels {
+		labels[k] = v
+	}
+	labels["testground.paused"] = "true"
+
+	res, err := cli.ContainerCreate(ctx, &container.Config{
+		Image:      commit.ID,
+		Entrypoint: []string{"sleep", strconv.Itoa(int(d.Seconds()) + 1)},
+		Cmd:        []string{},
+		Env:        info.Config.Env,
+		Labels:     labels,
+	}, &container.HostConfig{
+		NetworkMode: container.NetworkMode("testground-control"),
+		Mounts:      info.HostConfig.Mounts,
+	}, nil, c.name+"-paused")
+	if err != nil {
+		return "", commit.ID, fmt.Errorf("failed to create container: %w", err)
+	}
+	if err := cli.ContainerStart(ctx, res.ID, types.ContainerStartOptions{}); err != nil {
+		return res.ID, commit.ID, fmt.Errorf("failed to start container: %w", err)
+	}
+	return res.ID, commit.ID, nil
+}
+
+// failedRun tells if a run failed, rather than succeeded or was canceled.
+func failedRun(ctx context.Context, result *Result, err error) bool {
+	return ctx.Err() == nil && (err != nil || result.Outcome != task.OutcomeSuccess)
+}
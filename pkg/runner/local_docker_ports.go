@@ -0,0 +1,80 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+	"net"
+
+	"github.com/docker/docker/client"
+	"github.com/docker/go-connections/nat"
+
+	"github.com/testground/testground/pkg/api"
+)
+
+// publishedPorts returns the ports the container of the i-th instance of a
+// group exposes, the exposed ports of the runner config included, and the
+// bindings of the ports the group publishes.
+func publishedPorts(exposed nat.PortSet, publish []api.PublishedPort, i int) (nat.PortSet, nat.PortMap, error) {
+	if len(publish) == 0 {
+		return exposed, nil, nil
+	}
+
+	ports := make(nat.PortSet, len(exposed)+len(publish))
+	for p := range exposed {
+		ports[p] = struct{}{}
+	}
+
+	bindings := make(nat.PortMap, len(publish))
+	for _, p := range publish {
+		n, proto, err := p.PortProto()
+		if err != nil {
+			return nil, nil, err
+		}
+		hostPort, err := p.HostPortOf(i)
+		if err != nil {
+			return nil, nil, err
+		}
+		hostIP := p.HostIP
+		if hostIP == "" {
+			hostIP = api.DefaultPublishHostIP
+		}
+
+		port, err := nat.NewPort(proto, fmt.Sprint(n))
+		if err != nil {
+			return nil, nil, err
+		}
+		ports[port] = struct{}{}
+		bindings[port] = append(bindings[port], nat.PortBinding{HostIP: hostIP, HostPort: hostPort})
+	}
+	return ports, bindings, nil
+}
+
+// publishedAddrs returns the host addresses the published ports of the
+// containers of a group are reachable at, by container name and port (e.g.
+// "8545/tcp").
+func publishedAddrs(ctx context.Context, cli *client.Client, containers []testContainerInstance, publish map[string][]api.PublishedPort) (map[string]map[string]string, error) {
+	addrs := make(map[string]map[string]string)
+	for _, c := range containers {
+		if len(publish[c.groupID]) == 0 {
+			continue
+		}
+		info, err := cli.ContainerInspect(ctx, c.containerID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to inspect container %s: %w", c.name, err)
+		}
+		if info.NetworkSettings == nil {
+			continue
+		}
+		for _, p := range publish[c.groupID] {
+			n, proto, _ := p.PortProto()
+			port, _ := nat.NewPort(proto, fmt.Sprint(n))
+			for _, b := range info.NetworkSettings.Ports[port] {
+				if addrs[c.name] == nil {
+					addrs[c.name] = make(map[string]string)
+				}
+				addrs[c.name][string(port)] = net.JoinHostPort(b.HostIP, b.HostPort)
+			}
+		}
+	}
+	return addrs, nil
+}
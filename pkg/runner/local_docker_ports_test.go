@@ -0,0 +1,39 @@
+package runner
+
+import (
+	"testing"
+
+	"github.com/docker/go-connections/nat"
+	"github.com/stretchr/testify/require"
+
+	"github.com/testground/testground/pkg/api"
+)
+
+func TestPublishedPorts(t *testing.T) {
+	exposed := nat.PortSet{"6060/tcp": {}}
+
+	// Nothing to publish.
+	ports, bindings, err := publishedPorts(exposed, nil, 0)
+	require.NoError(t, err)
+	require.Equal(t, exposed, ports)
+	require.Nil(t, bindings)
+
+	publish := []api.PublishedPort{
+		{Port: "8545", HostPort: "30000-30001"},
+		{Port: "30303/udp", HostIP: "0.0.0.0"},
+	}
+	ports, bindings, err = publishedPorts(exposed, publish, 1)
+	require.NoError(t, err)
+	require.Equal(t, nat.PortSet{"6060/tcp": {}, "8545/tcp": {}, "30303/udp": {}}, ports)
+	require.Equal(t, nat.PortMap{
+		"8545/tcp":  {{HostIP: "127.0.0.1", HostPort: "30001"}},
+		"30303/udp": {{HostIP: "0.0.0.0"}},
+	}, bindings)
+
+	// The exposed ports of the runner config are left alone.
+	require.Len(t, exposed, 1)
+
+	// The range of host ports is too small for a third instance.
+	_, _, err = publishedPorts(exposed, publish, 2)
+	require.Error(t, err)
+}
@@ -0,0 +1,183 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/client"
+	"github.com/testground/sdk-go/runtime"
+	ss "github.com/testground/sdk-go/sync"
+
+	"github.com/testground/testground/pkg/api"
+)
+
+// probeTimeout bounds every probe of an instance.
+const probeTimeout = 5 * time.Second
+
+// ProbeReadiness probes the running containers of a run. Sync probes check
+// with the sync service whether the target number of instances signalled the
+// state of the probe.
+func (r *LocalDockerRunner) ProbeReadiness(ctx context.Context, input *api.ReadinessInput) (*api.ReadinessReport, error) {
+	if input.Probe.Type == api.ProbeSync {
+		return r.probeSyncReadiness(ctx, input)
+	}
+
+	cli, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
+	if err != nil {
+		return nil, err
+	}
+
+	instances, err := r.ListInstances(ctx, input.RunID)
+	if err != nil {
+		return nil, err
+	}
+
+	rep := &api.ReadinessReport{}
+	for _, inst := range instances {
+		res := &api.InstanceReadiness{Name: inst.Name, Group: inst.GroupID}
+		if err := r.probeContainer(ctx, cli, inst, input.Probe); err != nil {
+			res.Error = err.Error()
+		} else {
+			res.Ready = true
+			rep.Ready++
+		}
+		rep.Instances = append(rep.Instances, res)
+	}
+	return rep, nil
+}
+
+func (r *LocalDockerRunner) probeContainer(ctx context.Context, cli *client.Client, inst *api.Instance, probe *api.ReadinessProbe) error {
+	if inst.State != "running" {
+		return fmt.Errorf("instance is %s", inst.State)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, probeTimeout)
+	defer cancel()
+
+	info, err := cli.ContainerInspect(ctx, inst.Name)
+	if err != nil {
+		return err
+	}
+
+	if probe.Type == api.ProbeExec {
+		return probeExec(ctx, cli, info.ID, probe.Command)
+	}
+
+	// Probe the instance on the control network, which the daemon reaches.
+	var host string
+	if info.NetworkSettings != nil {
+		if n, ok := info.NetworkSettings.Networks[r.controlNetworkID]; ok {
+			host = n.IPAddress
+		}
+	}
+	if host == "" {
+		return fmt.Errorf("instance has no address")
+	}
+
+	addr := net.JoinHostPort(host, strconv.Itoa(probe.Port))
+	if probe.Type == api.ProbeHTTP {
+		return probeHTTP(ctx, addr, probe.Path)
+	}
+	return probeTCP(ctx, addr)
+}
+
+func (r *LocalDockerRunner) probeSyncReadiness(ctx context.Context, input *api.ReadinessInput) (*api.ReadinessReport, error) {
+	if err := r.setupSyncClient(); err != nil {
+		return nil, fmt.Errorf("failed to set up sync client: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, input.Probe.ProbeInterval())
+	defer cancel()
+
+	ctx = ss.WithRunParams(ctx, &runtime.RunParams{
+		TestPlan: input.TestPlan,
+		TestCase: input.TestCase,
+		TestRun:  input.RunID,
+	})
+
+	b, err := r.syncClient.Barrier(ctx, ss.State(input.Probe.State), input.Target)
+	if err != nil {
+		return nil, err
+	}
+
+	select {
+	case err := <-b.C:
+		if err != nil {
+			return nil, err
+		}
+		return &api.ReadinessReport{Ready: input.Target}, nil
+	case <-ctx.Done():
+		return &api.ReadinessReport{}, nil
+	}
+}
+
+// probeHTTP expects a 2xx or 3xx response to a GET request on path.
+func probeHTTP(ctx context.Context, addr string, path string) error {
+	if path == "" || path[0] != '/' {
+		path = "/" + path
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://"+addr+path, nil)
+	if err != nil {
+		return err
+	}
+
+	// Redirects count as ready; don't follow them.
+	cl := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+	resp, err := cl.Do(req)
+	if err != nil {
+		return err
+	}
+	_ = resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 400 {
+		return fmt.Errorf("http probe returned %s", resp.Status)
+	}
+	return nil
+}
+
+// probeTCP expects addr to accept connections.
+func probeTCP(ctx context.Context, addr string) error {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return err
+	}
+	return conn.Close()
+}
+
+// probeExec expects a command run in a container to exit with 0.
+func probeExec(ctx context.Context, cli *client.Client, id string, cmd []string) error {
+	exec, err := cli.ContainerExecCreate(ctx, id, types.ExecConfig{Cmd: cmd})
+	if err != nil {
+		return err
+	}
+
+	// The exec runs detached; wait for it to exit.
+	if err := cli.ContainerExecStart(ctx, exec.ID, types.ExecStartCheck{Detach: true}); err != nil {
+		return err
+	}
+	for {
+		res, err := cli.ContainerExecInspect(ctx, exec.ID)
+		if err != nil {
+			return err
+		}
+		if !res.Running {
+			if res.ExitCode != 0 {
+				return fmt.Errorf("exec probe exited with %d", res.ExitCode)
+			}
+			return nil
+		}
+
+		select {
+		case <-time.After(50 * time.Millisecond):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
@@ -0,0 +1,280 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/docker/docker/client"
+	"github.com/testground/sdk-go/runtime"
+
+	"github.com/testground/testground/pkg/api"
+	"github.com/testground/testground/pkg/rpc"
+)
+
+// scaleStopTimeout is how long removed instances are given to exit before they
+// are killed.
+const scaleStopTimeout = 10 * time.Second
+
+// outcomeAdjustment changes the number of instances of a group the outcomes
+// collector expects an outcome from.
+type outcomeAdjustment struct {
+	group string
+	delta int
+}
+
+// groupRunEnv returns the run environment of the instances of a group.
+func groupRunEnv(template runtime.RunParams, g *api.RunGroup) runtime.RunParams {
+	runenv := template
+	runenv.TestGroupInstanceCount = g.Instances
+	runenv.TestGroupID = g.ID
+	runenv.TestInstanceParams = g.Parameters
+	runenv.TestCaptureProfiles = g.Profiles
+	return runenv
+}
+
+// dockerScaling is the state of a run in progress that scaling it needs: how
+// to create and start instances, and the instances the run waits for.
+//
+// New instances join the sync session of the run. They see the number of
+// instances started so far in the run, and in their group, as their
+// TEST_INSTANCE_COUNT and TEST_GROUP_INSTANCE_COUNT.
+type dockerScaling struct {
+	lk sync.Mutex
+	// closed is set once the run no longer waits for its instances or their
+	// outcomes; it can't be scaled anymore then.
+	closed bool
+	// active is the number of instances the run waits for.
+	active int
+	// started is the number of instances started so far in the run.
+	started int
+
+	template runtime.RunParams
+	groups   map[string]*api.RunGroup
+	// next is the index of the next instance of every group.
+	next map[string]int
+	// containers are the containers of the run, including removed ones.
+	containers []testContainerInstance
+	removed    map[string]bool
+
+	// adjust is drained by the outcomes collector.
+	adjust chan outcomeAdjustment
+
+	// create creates the container of an instance; launch starts it and has
+	// the run wait for it.
+	create func(g *api.RunGroup, runenv runtime.RunParams, i int) (testContainerInstance, error)
+	launch func(ctx context.Context, c testContainerInstance) error
+}
+
+func newDockerScaling(input *api.RunInput, template runtime.RunParams, containers []testContainerInstance) *dockerScaling {
+	s := &dockerScaling{
+		started:    len(containers),
+		template:   template,
+		groups:     make(map[string]*api.RunGroup, len(input.Groups)),
+		next:       make(map[string]int, len(input.Groups)),
+		containers: append([]testContainerInstance(nil), containers...),
+		removed:    make(map[string]bool),
+		adjust:     make(chan outcomeAdjustment, 1024),
+	}
+	for _, g := range input.Groups {
+		s.groups[g.ID] = g
+		s.next[g.ID] = g.Instances
+	}
+	return s
+}
+
+// wait registers an instance the run waits for. It must be called before
+// the instance is waited for, with the lock held.
+func (s *dockerScaling) wait() {
+	s.active++
+}
+
+// done records that the run stopped waiting for an instance. The run can't
+// be scaled anymore once it waits for no instances.
+func (s *dockerScaling) done() {
+	s.lk.Lock()
+	defer s.lk.Unlock()
+
+	s.active--
+	if s.active <= 0 {
+		s.closed = true
+	}
+}
+
+// close stops the scaling of the run, and applies the pending adjustments of
+// the expected outcomes with fn.
+func (s *dockerScaling) close(fn func(outcomeAdjustment)) {
+	s.lk.Lock()
+	defer s.lk.Unlock()
+
+	s.closed = true
+	for {
+		select {
+		case a := <-s.adjust:
+			if fn != nil {
+				fn(a)
+			}
+		default:
+			return
+		}
+	}
+}
+
+// all returns the containers of the run, to tear them down.
+func (s *dockerScaling) all() []testContainerInstance {
+	s.lk.Lock()
+	defer s.lk.Unlock()
+
+	return append([]testContainerInstance(nil), s.containers...)
+}
+
+func (s *dockerScaling) adjustOutcomes(group string, delta int) error {
+	select {
+	case s.adjust <- outcomeAdjustment{group: group, delta: delta}:
+		return nil
+	default:
+		return fmt.Errorf("too many pending scaling operations")
+	}
+}
+
+// scale adds instances to, or removes instances from, a group.
+func (s *dockerScaling) scale(ctx context.Context, cli *client.Client, input *api.ScaleInput, ow *rpc.OutputWriter) (*api.ScaleOutput, error) {
+	s.lk.Lock()
+	defer s.lk.Unlock()
+
+	if s.closed {
+		return nil, fmt.Errorf("run %s is completing; it can't be scaled anymore", input.RunID)
+	}
+
+	g, ok := s.groups[input.Group]
+	if !ok {
+		return nil, fmt.Errorf("run %s has no group %s", input.RunID, input.Group)
+	}
+
+	out := &api.ScaleOutput{Group: g.ID}
+	var err error
+	if input.Delta > 0 {
+		err = s.add(ctx, g, input.Delta, out, ow)
+	} else {
+		err = s.remove(ctx, cli, g, -input.Delta, out, ow)
+	}
+
+	for _, c := range s.containers {
+		if c.groupID == g.ID && !s.removed[c.containerID] {
+			out.Instances++
+		}
+	}
+	return out, err
+}
+
+func (s *dockerScaling) add(ctx context.Context, g *api.RunGroup, n int, out *api.ScaleOutput, ow *rpc.OutputWriter) error {
+	runenv := groupRunEnv(s.template, g)
+	runenv.TestInstanceCount = s.started + n
+	runenv.TestGroupInstanceCount = s.next[g.ID] + n
+
+	for k := 0; k < n; k++ {
+		i := s.next[g.ID]
+		s.next[g.ID]++
+
+		c, err := s.create(g, runenv, i)
+		if c.containerID != "" {
+			s.containers = append(s.containers, c)
+		}
+		if err != nil {
+			return err
+		}
+
+		if err := s.adjustOutcomes(g.ID, 1); err != nil {
+			return err
+		}
+		s.started++
+
+		s.wait()
+		if err := s.launch(ctx, c); err != nil {
+			s.active--
+			return fmt.Errorf("failed to start container %s: %w", c.name, err)
+		}
+
+		ow.Infow("instance added", "group", g.ID, "name", c.name)
+		out.Added = append(out.Added, c.name)
+	}
+	return nil
+}
+
+func (s *dockerScaling) remove(ctx context.Context, cli *client.Client, g *api.RunGroup, n int, out *api.ScaleOutput, ow *rpc.OutputWriter) error {
+	// Remove the most recent instances first.
+	var candidates []testContainerInstance
+	for _, c := range s.containers {
+		if c.groupID == g.ID && !s.removed[c.containerID] {
+			candidates = append(candidates, c)
+		}
+	}
+	sort.Slice(candidates, func(i, j int) bool { return candidates[i].groupIdx > candidates[j].groupIdx })
+
+	for _, c := range candidates {
+		if len(out.Removed) == n {
+			break
+		}
+		info, err := cli.ContainerInspect(ctx, c.containerID)
+		if err != nil || info.State == nil || !info.State.Running {
+			continue
+		}
+
+		// Expect no outcome from the instance before stopping it.
+		if err := s.adjustOutcomes(g.ID, -1); err != nil {
+			return err
+		}
+		s.removed[c.containerID] = true
+
+		timeout := scaleStopTimeout
+		if err := cli.ContainerStop(ctx, c.containerID, &timeout); err != nil {
+			return fmt.Errorf("failed to stop container %s: %w", c.name, err)
+		}
+
+		ow.Infow("instance removed", "group", g.ID, "name", c.name)
+		out.Removed = append(out.Removed, c.name)
+	}
+
+	if len(out.Removed) < n {
+		ow.Warnw("fewer running instances than requested to remove", "group", g.ID, "requested", n, "removed", len(out.Removed))
+	}
+	return nil
+}
+
+// registerScaling makes a run in progress scalable.
+func (r *LocalDockerRunner) registerScaling(runID string, s *dockerScaling) {
+	r.scalingLk.Lock()
+	defer r.scalingLk.Unlock()
+
+	if r.scaling == nil {
+		r.scaling = make(map[string]*dockerScaling)
+	}
+	r.scaling[runID] = s
+}
+
+func (r *LocalDockerRunner) unregisterScaling(runID string) {
+	r.scalingLk.Lock()
+	defer r.scalingLk.Unlock()
+
+	delete(r.scaling, runID)
+}
+
+// Scale adds instances to, or removes instances from, a group of a run in
+// progress.
+func (r *LocalDockerRunner) Scale(ctx context.Context, input *api.ScaleInput, ow *rpc.OutputWriter) (*api.ScaleOutput, error) {
+	r.scalingLk.Lock()
+	s, ok := r.scaling[input.RunID]
+	r.scalingLk.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("run %s is not in progress, or its instances are not started yet", input.RunID)
+	}
+
+	cli, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
+	if err != nil {
+		return nil, err
+	}
+
+	return s.scale(ctx, cli, input, ow)
+}
@@ -0,0 +1,74 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/testground/sdk-go/runtime"
+
+	"github.com/testground/testground/pkg/api"
+	"github.com/testground/testground/pkg/rpc"
+)
+
+func TestDockerScalingAdd(t *testing.T) {
+	input := &api.RunInput{
+		RunID: "run",
+		Groups: []*api.RunGroup{
+			{ID: "miners", Instances: 2},
+			{ID: "clients", Instances: 1},
+		},
+	}
+	containers := []testContainerInstance{
+		{containerID: "a", groupID: "miners", groupIdx: 0},
+		{containerID: "b", groupID: "miners", groupIdx: 1},
+		{containerID: "c", groupID: "clients", groupIdx: 0},
+	}
+
+	s := newDockerScaling(input, runtime.RunParams{TestInstanceCount: 3}, containers)
+	s.active = len(containers)
+
+	var envs []runtime.RunParams
+	s.create = func(g *api.RunGroup, runenv runtime.RunParams, i int) (testContainerInstance, error) {
+		envs = append(envs, runenv)
+		return testContainerInstance{containerID: fmt.Sprintf("%s-%d", g.ID, i), name: fmt.Sprintf("tg-%s-%d", g.ID, i), groupID: g.ID, groupIdx: i}, nil
+	}
+	var launched []string
+	s.launch = func(_ context.Context, c testContainerInstance) error {
+		launched = append(launched, c.containerID)
+		return nil
+	}
+
+	out, err := s.scale(context.Background(), nil, &api.ScaleInput{RunID: "run", Group: "miners", Delta: 2}, rpc.Discard())
+	require.NoError(t, err)
+	require.Equal(t, []string{"tg-miners-2", "tg-miners-3"}, out.Added)
+	require.Equal(t, 4, out.Instances)
+	require.Equal(t, []string{"miners-2", "miners-3"}, launched)
+	require.Equal(t, 5, s.active)
+
+	// New instances see the instances started so far.
+	for _, env := range envs {
+		require.Equal(t, 5, env.TestInstanceCount)
+		require.Equal(t, 4, env.TestGroupInstanceCount)
+		require.Equal(t, "miners", env.TestGroupID)
+	}
+
+	// The outcomes collector expects an outcome from the new instances.
+	result := newResult(input)
+	s.close(func(a outcomeAdjustment) { result.Outcomes[a.group].Total += a.delta })
+	require.Equal(t, 4, result.Outcomes["miners"].Total)
+	require.Equal(t, 1, result.Outcomes["clients"].Total)
+	require.Len(t, s.all(), 5)
+
+	_, err = s.scale(context.Background(), nil, &api.ScaleInput{RunID: "run", Group: "miners", Delta: 1}, rpc.Discard())
+	require.Error(t, err)
+}
+
+func TestDockerScalingUnknownGroup(t *testing.T) {
+	input := &api.RunInput{RunID: "run", Groups: []*api.RunGroup{{ID: "miners", Instances: 1}}}
+	s := newDockerScaling(input, runtime.RunParams{}, nil)
+
+	_, err := s.scale(context.Background(), nil, &api.ScaleInput{RunID: "run", Group: "clients", Delta: 1}, rpc.Discard())
+	require.Error(t, err)
+}
@@ -0,0 +1,111 @@
+package runner
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/client"
+
+	"github.com/testground/testground/pkg/rpc"
+)
+
+// dockerUsage accounts for the resources the containers of a run use, by
+// following the statistics the Docker daemon streams for each of them.
+type dockerUsage struct {
+	cli      *client.Client
+	log      *rpc.OutputWriter
+	recorder *usageRecorder
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	lk      sync.Mutex
+	tracked map[string]testContainerInstance
+}
+
+func newDockerUsage(ctx context.Context, cli *client.Client, log *rpc.OutputWriter) *dockerUsage {
+	ctx, cancel := context.WithCancel(ctx)
+	return &dockerUsage{
+		cli:      cli,
+		log:      log,
+		recorder: newUsageRecorder(),
+		ctx:      ctx,
+		cancel:   cancel,
+		tracked:  make(map[string]testContainerInstance),
+	}
+}
+
+// track follows the statistics of a started container, until it stops or
+// the usage is collected.
+func (u *dockerUsage) track(c testContainerInstance) {
+	u.lk.Lock()
+	if _, ok := u.tracked[c.containerID]; ok {
+		u.lk.Unlock()
+		return
+	}
+	u.tracked[c.containerID] = c
+	u.lk.Unlock()
+
+	u.recorder.update(c.name, c.groupID, func(*InstanceUsage) {})
+
+	u.wg.Add(1)
+	go func() {
+		defer u.wg.Done()
+
+		resp, err := u.cli.ContainerStats(u.ctx, c.containerID, true)
+		if err != nil {
+			u.log.Debugw("failed to follow the statistics of the container", "container", c.name, "err", err)
+			return
+		}
+		defer resp.Body.Close()
+
+		for dec := json.NewDecoder(resp.Body); ; {
+			var s types.StatsJSON
+			if err := dec.Decode(&s); err != nil {
+				return
+			}
+			var rx, tx uint64
+			for _, n := range s.Networks {
+				rx += n.RxBytes
+				tx += n.TxBytes
+			}
+			memory := maxUint(s.MemoryStats.Usage, s.MemoryStats.MaxUsage)
+			cpu := float64(s.CPUStats.CPUUsage.TotalUsage) / float64(time.Second)
+			u.recorder.sample(c.name, c.groupID, cpu, memory, rx, tx)
+		}
+	}()
+}
+
+// collect stops following the containers, and returns their usage. How long
+// they ran comes from their state.
+func (u *dockerUsage) collect(ctx context.Context) *Usage {
+	u.cancel()
+	u.wg.Wait()
+
+	u.lk.Lock()
+	defer u.lk.Unlock()
+
+	for _, c := range u.tracked {
+		ci, err := u.cli.ContainerInspect(ctx, c.containerID)
+		if err != nil || ci.State == nil {
+			continue
+		}
+		started, err := time.Parse(time.RFC3339Nano, ci.State.StartedAt)
+		if err != nil || started.IsZero() {
+			continue
+		}
+		finished, err := time.Parse(time.RFC3339Nano, ci.State.FinishedAt)
+		if err != nil || finished.Before(started) || ci.State.Running {
+			finished = time.Now()
+		}
+		u.recorder.update(c.name, c.groupID, func(i *InstanceUsage) {
+			// Containers restored by the chaos controller run several times.
+			i.Seconds = maxFloat(i.Seconds, finished.Sub(started).Seconds())
+		})
+	}
+	return u.recorder.usage()
+}
@@ -0,0 +1,201 @@
+package runner
+
+import (
+	"archive/tar"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/mount"
+	"github.com/docker/docker/client"
+
+	"github.com/testground/testground/pkg/api"
+	"github.com/testground/testground/pkg/rpc"
+)
+
+// The volume snapshots of local:docker runs are tarballs of the volumes of
+// their instances, stored under the snapshots directory, as
+// <run id>/<group id>/<group index>.tar.
+
+// volumeName returns the name of the docker volume of the instance running in
+// a container.
+func volumeName(container string) string {
+	return container + "-volume"
+}
+
+func volumeMount(container string, v *api.Volume) mount.Mount {
+	return mount.Mount{
+		Type:   mount.TypeVolume,
+		Source: volumeName(container),
+		Target: v.Path,
+	}
+}
+
+func snapshotFile(dir string, runID string, group string, idx int) string {
+	return filepath.Join(dir, runID, group, strconv.Itoa(idx)+".tar")
+}
+
+// checkSnapshot fails if the snapshot a group restores its volumes from
+// doesn't exist.
+func checkSnapshot(dir string, v *api.Volume) error {
+	ref, err := api.ParseSnapshotRef(v.FromSnapshot)
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(filepath.Join(dir, ref.Task, ref.Group)); err != nil {
+		return fmt.Errorf("snapshot %s not found: %w", ref, err)
+	}
+	return nil
+}
+
+// restoreVolume copies the snapshot of the volume of an instance into its
+// container, before it starts. Instances that have no snapshot start with an
+// empty volume.
+func restoreVolume(ctx context.Context, cli *client.Client, dir string, c testContainerInstance, v *api.Volume, ow *rpc.OutputWriter) error {
+	ref, err := api.ParseSnapshotRef(v.FromSnapshot)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Open(snapshotFile(dir, ref.Task, ref.Group, c.groupIdx))
+	if os.IsNotExist(err) {
+		ow.Warnw("no snapshot for instance; starting with an empty volume", "snapshot", ref, "group", c.groupID, "group_index", c.groupIdx)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if err := cli.CopyToContainer(ctx, c.containerID, v.Path, f, types.CopyToContainerOptions{}); err != nil {
+		return fmt.Errorf("failed to restore snapshot %s: %w", ref, err)
+	}
+	return nil
+}
+
+// snapshotVolumes snapshots the volumes of the instances of the groups that
+// require it. It runs at the end of the run, whatever its outcome, before the
+// containers are torn down.
+func snapshotVolumes(cli *client.Client, dir string, runID string, groups []*api.RunGroup, containers []testContainerInstance, ow *rpc.OutputWriter) {
+	volumes := make(map[string]*api.Volume, len(groups))
+	for _, g := range groups {
+		if g.Volume != nil && g.Volume.Snapshot {
+			volumes[g.ID] = g.Volume
+		}
+	}
+	if len(volumes) == 0 {
+		return
+	}
+
+	// The run context may be done already.
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+	defer cancel()
+
+	n := make(map[string]int, len(volumes))
+	for _, c := range containers {
+		v, ok := volumes[c.groupID]
+		if !ok {
+			continue
+		}
+		if err := snapshotVolume(ctx, cli, snapshotFile(dir, runID, c.groupID, c.groupIdx), c, v); err != nil {
+			ow.Warnw("failed to snapshot volume", "group", c.groupID, "group_index", c.groupIdx, "err", err)
+			continue
+		}
+		n[c.groupID]++
+	}
+
+	for id := range volumes {
+		ref := &api.SnapshotRef{Task: runID, Group: id}
+		ow.Infow("snapshotted volumes", "snapshot", ref.String(), "instances", n[id])
+	}
+}
+
+func snapshotVolume(ctx context.Context, cli *client.Client, file string, c testContainerInstance, v *api.Volume) error {
+	rc, _, err := cli.CopyFromContainer(ctx, c.containerID, v.Path)
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+
+	if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(file), ".snapshot-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if err := stripTarRoot(tmp, rc); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), file)
+}
+
+// stripTarRoot rewrites an archive of a directory, as returned by docker, so
+// that its entries are relative to the directory: the snapshot of a volume can
+// then be restored at another path.
+func stripTarRoot(dst io.Writer, src io.Reader) error {
+	tr := tar.NewReader(src)
+	tw := tar.NewWriter(dst)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		name := strings.TrimPrefix(hdr.Name, "./")
+		i := strings.Index(name, "/")
+		if i < 0 || i == len(name)-1 {
+			// The directory itself.
+			continue
+		}
+		hdr.Name = name[i+1:]
+		if hdr.Typeflag == tar.TypeLink {
+			hdr.Linkname = strings.TrimPrefix(strings.TrimPrefix(hdr.Linkname, "./"), name[:i+1])
+		}
+
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if _, err := io.Copy(tw, tr); err != nil {
+			return err
+		}
+	}
+	return tw.Close()
+}
+
+// removeVolumes removes the volumes of the instances of a run, once their
+// containers are removed.
+func removeVolumes(cli *client.Client, groups []*api.RunGroup, containers []testContainerInstance, ow *rpc.OutputWriter) {
+	withVolume := make(map[string]bool, len(groups))
+	for _, g := range groups {
+		withVolume[g.ID] = g.Volume != nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	for _, c := range containers {
+		if !withVolume[c.groupID] {
+			continue
+		}
+		if err := cli.VolumeRemove(ctx, volumeName(c.name), true); err != nil {
+			ow.Warnw("failed to remove volume", "volume", volumeName(c.name), "err", err)
+		}
+	}
+}
@@ -0,0 +1,49 @@
+package runner
+
+import (
+	"archive/tar"
+	"bytes"
+	"io"
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestStripTarRoot(t *testing.T) {
+	// Docker archives a directory with the directory as the root entry.
+	var src bytes.Buffer
+	tw := tar.NewWriter(&src)
+	for _, e := range []struct{ name, body string }{
+		{"chain/", ""},
+		{"chain/blocks/", ""},
+		{"chain/blocks/0001", "genesis"},
+		{"chain/HEAD", "0001"},
+	} {
+		hdr := &tar.Header{Name: e.name, Mode: 0644, Size: int64(len(e.body)), Typeflag: tar.TypeReg}
+		if e.body == "" {
+			hdr.Typeflag = tar.TypeDir
+		}
+		require.NoError(t, tw.WriteHeader(hdr))
+		_, err := tw.Write([]byte(e.body))
+		require.NoError(t, err)
+	}
+	require.NoError(t, tw.Close())
+
+	var dst bytes.Buffer
+	require.NoError(t, stripTarRoot(&dst, &src))
+
+	files := make(map[string]string)
+	tr := tar.NewReader(&dst)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		b, err := ioutil.ReadAll(tr)
+		require.NoError(t, err)
+		files[hdr.Name] = string(b)
+	}
+	require.Equal(t, map[string]string{"blocks/": "", "blocks/0001": "genesis", "HEAD": "0001"}, files)
+}
@@ -9,6 +9,7 @@ import (
 	"os/exec"
 	"path/filepath"
 	"reflect"
+	goruntime "runtime"
 	"strconv"
 	"sync"
 	"time"
@@ -33,8 +34,9 @@ var (
 )
 
 var (
-	_ api.Runner        = (*LocalExecutableRunner)(nil)
-	_ api.Healthchecker = (*LocalExecutableRunner)(nil)
+	_ api.Runner         = (*LocalExecutableRunner)(nil)
+	_ api.Healthchecker  = (*LocalExecutableRunner)(nil)
+	_ api.OutputsLocator = (*LocalExecutableRunner)(nil)
 )
 
 type LocalExecutableRunner struct {
@@ -44,7 +46,22 @@ type LocalExecutableRunner struct {
 }
 
 // LocalExecutableRunnerCfg is the configuration struct for this runner.
-type LocalExecutableRunnerCfg struct{}
+type LocalExecutableRunnerCfg struct {
+	// Shaping runs every instance in network namespaces of its own, linked
+	// to a bridge per run, and manages their network on behalf of the
+	// sidecar, so that instances can configure latency, bandwidth, jitter
+	// and packet loss through the network API, as with container runners.
+	// Instances reach the sync service through a control bridge, which is
+	// not shaped. It requires Linux, and the daemon to run as root
+	// (default: false).
+	Shaping bool `toml:"shaping"`
+
+	// SyncBackend selects the sync service of the runs: "service", the
+	// testground-sync-service container next to redis, or "embedded", an
+	// in-memory sync service embedded in the daemon, which requires neither
+	// container. Shaping requires the "service" backend (default: service).
+	SyncBackend SyncBackend `toml:"sync_backend"`
+}
 
 func (r *LocalExecutableRunner) Healthcheck(ctx context.Context, engine api.Engine, ow *rpc.OutputWriter, fix bool) (*api.HealthcheckReport, error) {
 	r.lk.Lock()
@@ -59,19 +76,45 @@ func (r *LocalExecutableRunner) Healthcheck(ctx context.Context, engine api.Engi
 	r.outputsDir = filepath.Join(engine.EnvConfig().Dirs().Outputs(), "local_exec")
 	hh := &healthcheck.Helper{}
 
-	hh.Enlist("redis-port",
-		healthcheck.CheckRedisPort(ctx, ow, cli),
-		healthcheck.RequiresManualFixing(),
-	)
+	var name string
+	if rcfg, ok := engine.EnvConfig().Runners[r.ID()]; ok {
+		name, _ = rcfg["sync_backend"].(string)
+	}
+	backend, err := newSyncBackend(SyncBackend(name))
+	if err != nil {
+		return nil, err
+	}
+
+	// With the embedded sync service, runs don't need Docker, which hosts
+	// such as macOS or Windows may not have; only the metrics of the
+	// instances, which go to InfluxDB, are lost.
+	if _, ok := backend.(*embeddedSyncBackend); ok && !dockerReachable(ctx, cli) {
+		ow.Warnw("docker is not reachable; local:exec runs without InfluxDB and Grafana")
+		hh.Enlist("local-outputs-dir",
+			healthcheck.CheckDirectoryExists(r.outputsDir),
+			healthcheck.CreateDirectory(r.outputsDir),
+		)
+		backend.enlist(ctx, hh, cli, ow, "testground-control")
+		return hh.RunChecks(ctx, fix)
+	}
+
+	if _, ok := backend.(serviceSyncBackend); ok {
+		hh.Enlist("redis-port",
+			healthcheck.CheckRedisPort(ctx, ow, cli),
+			healthcheck.RequiresManualFixing(),
+		)
+	}
 
 	// setup infra which is common between local:docker and local:exec
-	localCommonHealthcheck(ctx, hh, cli, ow, "testground-control", r.outputsDir)
+	localCommonHealthcheck(ctx, hh, cli, ow, "testground-control", r.outputsDir, backend)
 
 	// RunChecks will fill the report and return any errors.
 	return hh.RunChecks(ctx, fix)
 }
 
+// Close stops the embedded sync services of the runs.
 func (r *LocalExecutableRunner) Close() error {
+	embeddedSync.close()
 	return nil
 }
 
@@ -79,6 +122,23 @@ func (r *LocalExecutableRunner) Run(ctx context.Context, input *api.RunInput, ow
 	r.lk.RLock()
 	defer r.lk.RUnlock()
 
+	if err := checkIPv4Only(r.ID(), input.IPFamily); err != nil {
+		return nil, err
+	}
+
+	var cfg LocalExecutableRunnerCfg
+	if c, ok := input.RunnerConfig.(*LocalExecutableRunnerCfg); ok && c != nil {
+		cfg = *c
+	}
+
+	backend, err := newSyncBackend(cfg.SyncBackend)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Shaping && cfg.SyncBackend == SyncBackendEmbedded {
+		return nil, fmt.Errorf("traffic shaping with local:exec requires the %s sync backend", SyncBackendService)
+	}
+
 	// Build a template runenv.
 	template := runtime.RunParams{
 		TestPlan:           input.TestPlan,
@@ -90,12 +150,37 @@ func (r *LocalExecutableRunner) Run(ctx context.Context, input *api.RunInput, ow
 		TestSubnet:         &ptypes.IPNet{IPNet: *localSubnet},
 	}
 
+	var (
+		servicesHost = "localhost"
+		shaping      *execShaping
+	)
+	if cfg.Shaping {
+		if shaping, err = newExecShaping(ctx, input.RunID); err != nil {
+			return nil, err
+		}
+		defer func() {
+			if err := shaping.close(); err != nil {
+				ow.Warnw("failed to delete the networks of the run", "err", err)
+			}
+		}()
+
+		template.TestSidecar = true
+		template.TestSubnet = shaping.dataSubnet()
+		servicesHost = shaping.controlHost()
+	}
+
+	syncEnv, err := backend.env(input.RunID, servicesHost)
+	if err != nil {
+		return nil, err
+	}
+	defer backend.release(input.RunID)
+
 	// Spawn as many instances as the input parameters require.
 	pretty := NewPrettyPrinter(ow)
 	commands := make([]*exec.Cmd, 0, input.TotalInstances)
 	defer func() {
 		for _, cmd := range commands {
-			_ = cmd.Process.Kill()
+			_ = killProcess(cmd.Process)
 		}
 		for _, cmd := range commands {
 			_ = cmd.Wait()
@@ -106,9 +191,52 @@ func (r *LocalExecutableRunner) Run(ctx context.Context, input *api.RunInput, ow
 	var (
 		total   int
 		tmpdirs []string
+		inputs  string
 	)
+
+	if err := writeOutputsSchema(filepath.Join(r.outputsDir, input.TestPlan, input.RunID), r.ID(), input); err != nil {
+		return nil, fmt.Errorf("failed to stamp the outputs schema: %w", err)
+	}
+
+	// Link the outputs of the upstream runs into a single directory.
+	if len(input.Inputs) > 0 {
+		dir, err := linkInputs(input.Inputs)
+		if err != nil {
+			return nil, err
+		}
+		defer os.RemoveAll(dir)
+		inputs = dir
+	}
+
+	// Instances find the fixtures in the cache, in place.
+	var fixtureEnv []string
+	if len(input.Fixtures) > 0 {
+		dirs, err := fetchFixtures(ctx, input, ow)
+		if err != nil {
+			return nil, err
+		}
+		for _, f := range input.Fixtures {
+			fixtureEnv = append(fixtureEnv, fixtureEnvVar(f, dirs[f.Name]))
+		}
+	}
+
+	if input.PauseOnFailure > 0 {
+		ow.Warnw("local:exec does not pause on failure; the outputs of the instances are kept in the outputs directory")
+	}
+
+	if input.Diagnostics != nil {
+		ow.Warnw("local:exec does not capture diagnostics; the logs of the instances are in their outputs")
+	}
+
 	for _, g := range input.Groups {
-		reviewResources(g, ow)
+		reviewResources(ctx, g, ow)
+		api.RecordDecision(ctx, api.DecisionStagePlacement, "%d instances of group %s placed as local processes", g.Instances, g.ID)
+		if g.Volume != nil {
+			ow.Warnw("local:exec does not support volumes; ignoring the volume of the group", "group", g.ID)
+		}
+		if len(g.Publish) > 0 {
+			ow.Warnw("local:exec does not publish ports; the instances listen on the host already", "group", g.ID)
+		}
 
 		for i := 0; i < g.Instances; i++ {
 			total++
@@ -140,31 +268,48 @@ func (r *LocalExecutableRunner) Run(ctx context.Context, input *api.RunInput, ow
 			runenv.TestCaptureProfiles = g.Profiles
 
 			env := conv.ToOptionsSlice(runenv.ToEnvVars())
-			env = append(env, "INFLUXDB_URL=http://localhost:8086")
-			// NOTE: we export REDIS_HOST for compatibility with older sdk versions.
-			env = append(env, "REDIS_HOST=localhost")
-			env = append(env, "SYNC_SERVICE_HOST=localhost")
-			env = append(env, "PATH="+os.Getenv("PATH"))
+			env = append(env, "INFLUXDB_URL=http://"+net.JoinHostPort(servicesHost, "8086"))
+			env = append(env, syncEnv...)
+			env = append(env, hostEnv(goruntime.GOOS, os.Getenv)...)
+			if inputs != "" {
+				env = append(env, EnvTestInputsPath+"="+inputs)
+			}
+			env = append(env, fixtureEnv...)
+			env = append(env, conv.ToOptionsSlice(runtimeAPIEnv(input))...)
+			env = append(env, conv.ToOptionsSlice(g.Env)...)
 
 			ow.Infow("starting test case instance", "plan", input.TestPlan, "group", g.ID, "number", i, "total", total)
 
-			cmd := exec.CommandContext(ctx, g.ArtifactPath)
+			// Instances are stopped with their process group once the run is
+			// done, rather than with the context of the run.
+			cmd := exec.Command(g.ArtifactPath)
 			stdout, _ := cmd.StdoutPipe()
 			stderr, _ := cmd.StderrPipe()
 			cmd.Env = env
+			setProcessGroup(cmd)
 
-			if err := cmd.Start(); err != nil {
+			if shaping != nil {
+				err = shaping.start(cmd, runenv, fmt.Sprintf("%s-%d", g.ID, i))
+			} else {
+				err = cmd.Start()
+			}
+			if err != nil {
 				pretty.FailStart(tag, err)
 				continue
 			}
 
 			commands = append(commands, cmd)
+			api.EmitEvent(ctx, api.Event{Type: api.EventInstanceStarted, Group: g.ID, Instance: tag})
 
 			// instance tag in output: << group[zero_padded_i] >>, e.g. << miner[003] >>
 			pretty.Manage(tag, stdout, stderr)
 		}
 	}
 
+	stopped := make(chan struct{})
+	defer close(stopped)
+	go stopOnDone(ctx, stopped, commands, execStopGrace)
+
 	if err := <-pretty.Wait(); err != nil {
 		return nil, err
 	}
@@ -177,6 +322,14 @@ func (r *LocalExecutableRunner) Run(ctx context.Context, input *api.RunInput, ow
 	return &api.RunOutput{RunID: input.RunID}, nil
 }
 
+// LocateOutputs returns the directory holding the outputs of a run.
+func (r *LocalExecutableRunner) LocateOutputs(plan string, runID string) (string, error) {
+	r.lk.RLock()
+	defer r.lk.RUnlock()
+
+	return locateOutputs(r.outputsDir, plan, runID)
+}
+
 func (r *LocalExecutableRunner) CollectOutputs(ctx context.Context, input *api.CollectionInput, ow *rpc.OutputWriter) error {
 	r.lk.RLock()
 	dir := r.outputsDir
@@ -209,6 +362,9 @@ func (*LocalExecutableRunner) TerminateAll(ctx context.Context, ow *rpc.OutputWr
 	//  children processes of the daemon, and send them a SIGKILL.
 	ow.Info("terminate local:exec requested")
 
+	// The embedded sync services of the runs are stopped with them.
+	embeddedSync.close()
+
 	cli, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
 	if err != nil {
 		return err
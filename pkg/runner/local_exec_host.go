@@ -0,0 +1,64 @@
+package runner
+
+import (
+	"context"
+	"os/exec"
+	"time"
+
+	"github.com/docker/docker/client"
+)
+
+// execStopGrace is how long the instances of local:exec are given to exit
+// once asked to, e.g. when their run is canceled, before they're killed.
+const execStopGrace = 5 * time.Second
+
+// execHostEnv are the variables of the environment of the daemon passed on
+// to the instances of local:exec, besides PATH, per host OS. Programs need
+// them to find their home, temporary directory and, on Windows, the system
+// libraries that networking depends on.
+var execHostEnv = map[string][]string{
+	"darwin":  {"HOME", "TMPDIR"},
+	"windows": {"SystemRoot", "SystemDrive", "ComSpec", "PATHEXT", "TEMP", "TMP", "USERPROFILE", "APPDATA", "LOCALAPPDATA", "ProgramData"},
+}
+
+// hostEnv returns the variables of the environment of the daemon the
+// instances of local:exec inherit on a host OS.
+func hostEnv(goos string, getenv func(string) string) []string {
+	env := []string{"PATH=" + getenv("PATH")}
+	for _, k := range execHostEnv[goos] {
+		if v := getenv(k); v != "" {
+			env = append(env, k+"="+v)
+		}
+	}
+	return env
+}
+
+// stopOnDone asks the instances to exit once ctx is done, and kills them if
+// they haven't within the grace period, unless stopped is closed first.
+func stopOnDone(ctx context.Context, stopped <-chan struct{}, commands []*exec.Cmd, grace time.Duration) {
+	select {
+	case <-ctx.Done():
+	case <-stopped:
+		return
+	}
+
+	for _, cmd := range commands {
+		_ = interruptProcess(cmd.Process)
+	}
+	select {
+	case <-time.After(grace):
+	case <-stopped:
+	}
+	for _, cmd := range commands {
+		_ = killProcess(cmd.Process)
+	}
+}
+
+// dockerReachable tells if the Docker daemon responds.
+func dockerReachable(ctx context.Context, cli *client.Client) bool {
+	ctx, cancel := context.WithTimeout(ctx, probeTimeout)
+	defer cancel()
+
+	_, err := cli.Ping(ctx)
+	return err == nil
+}
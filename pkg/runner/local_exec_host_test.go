@@ -0,0 +1,21 @@
+package runner
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestHostEnv(t *testing.T) {
+	vars := map[string]string{
+		"PATH":       "/usr/bin",
+		"HOME":       "/Users/tg",
+		"SystemRoot": `C:\Windows`,
+		"SECRET":     "s3cr3t",
+	}
+	getenv := func(k string) string { return vars[k] }
+
+	require.Equal(t, []string{"PATH=/usr/bin"}, hostEnv("linux", getenv))
+	require.Equal(t, []string{"PATH=/usr/bin", "HOME=/Users/tg"}, hostEnv("darwin", getenv))
+	require.Equal(t, []string{"PATH=/usr/bin", `SystemRoot=C:\Windows`}, hostEnv("windows", getenv))
+}
@@ -0,0 +1,29 @@
+package runner
+
+import (
+	"os"
+	"os/exec"
+	"strconv"
+	"syscall"
+)
+
+// setProcessGroup starts an instance in a process group of its own, so that
+// the console signals of the daemon don't reach it.
+func setProcessGroup(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{CreationFlags: syscall.CREATE_NEW_PROCESS_GROUP}
+}
+
+// interruptProcess asks the process tree of an instance to terminate. Windows
+// has no equivalent of SIGTERM for console programs without a console, so
+// those only exit when killed.
+func interruptProcess(p *os.Process) error {
+	return exec.Command("taskkill", "/T", "/PID", strconv.Itoa(p.Pid)).Run()
+}
+
+// killProcess kills the process tree of an instance.
+func killProcess(p *os.Process) error {
+	if err := exec.Command("taskkill", "/T", "/F", "/PID", strconv.Itoa(p.Pid)).Run(); err != nil {
+		return p.Kill()
+	}
+	return nil
+}
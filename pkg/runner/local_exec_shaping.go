@@ -0,0 +1,20 @@
+package runner
+
+import (
+	"fmt"
+	"net"
+)
+
+// execSubnets returns the data and control subnets of the idx-th run of
+// local:exec that shapes traffic. Data subnets are taken from the upper half
+// of the space of data networks, so they don't overlap with the networks of
+// local:docker; control subnets are /20 blocks of 198.18.0.0/15, a range set
+// aside for benchmarks.
+func execSubnets(idx int) (data, control *net.IPNet, err error) {
+	if data, _, err = nextDataNetwork(2048 + idx%2048); err != nil {
+		return nil, nil, err
+	}
+	block := idx % 32
+	_, control, err = net.ParseCIDR(fmt.Sprintf("198.%d.%d.0/20", 18+block/16, (block%16)*16))
+	return data, control, err
+}
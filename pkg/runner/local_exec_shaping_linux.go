@@ -0,0 +1,115 @@
+//go:build linux
+// +build linux
+
+package runner
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	gosync "sync"
+	"sync/atomic"
+
+	"github.com/testground/sdk-go/ptypes"
+	"github.com/testground/sdk-go/runtime"
+	"github.com/testground/sdk-go/sync"
+
+	"github.com/testground/testground/pkg/logging"
+	"github.com/testground/testground/pkg/sidecar"
+)
+
+// execShapedRuns counts the runs of local:exec that shaped traffic, to hand
+// out their subnets.
+var execShapedRuns uint32
+
+// execShaping manages the networks of the instances of a run of local:exec
+// that shapes traffic, on behalf of the sidecar.
+type execShaping struct {
+	networks *sidecar.ExecNetworks
+	client   sync.Client
+	subnet   *ptypes.IPNet
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     gosync.WaitGroup
+}
+
+func newExecShaping(ctx context.Context, runID string) (*execShaping, error) {
+	if os.Geteuid() != 0 {
+		return nil, fmt.Errorf("traffic shaping with local:exec requires the daemon to run as root")
+	}
+
+	idx := int(atomic.AddUint32(&execShapedRuns, 1) - 1)
+	data, control, err := execSubnets(idx)
+	if err != nil {
+		return nil, err
+	}
+
+	networks, err := sidecar.NewExecNetworks(runID, data, control)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create the networks of the run: %w", err)
+	}
+
+	client, err := sync.NewGenericClient(ctx, logging.S())
+	if err != nil {
+		_ = networks.Close()
+		return nil, fmt.Errorf("failed to connect to the sync service: %w", err)
+	}
+
+	s := &execShaping{
+		networks: networks,
+		client:   client,
+		subnet:   &ptypes.IPNet{IPNet: *data},
+	}
+	s.ctx, s.cancel = context.WithCancel(ctx)
+	return s, nil
+}
+
+// controlHost returns the address the instances reach the services at.
+func (s *execShaping) controlHost() string {
+	return s.networks.ControlGateway().String()
+}
+
+// dataSubnet returns the subnet of the data network of the run.
+func (s *execShaping) dataSubnet() *ptypes.IPNet {
+	return s.subnet
+}
+
+// start starts an instance in a network namespace of its own, and manages
+// its network until the shaping is closed.
+func (s *execShaping) start(cmd *exec.Cmd, params runtime.RunParams, hostname string) error {
+	network, err := s.networks.NewInstance(hostname)
+	if err != nil {
+		return err
+	}
+	if err := network.Start(cmd); err != nil {
+		_ = network.Close()
+		return err
+	}
+
+	// The sidecar can't store anything.
+	params.TestOutputsPath = ""
+	inst, err := sidecar.NewInstance(s.client, runtime.NewRunEnv(params), hostname, network)
+	if err != nil {
+		_ = network.Close()
+		return err
+	}
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		if err := sidecar.Manage(s.ctx, inst); err != nil {
+			inst.S().Warnw("failed to manage the network of the instance", "instance", hostname, "err", err)
+		}
+	}()
+	return nil
+}
+
+// close stops managing the networks of the instances, and deletes them.
+func (s *execShaping) close() error {
+	s.cancel()
+	s.wg.Wait()
+	_ = s.client.Close()
+	return s.networks.Close()
+}
@@ -0,0 +1,29 @@
+//go:build !linux
+// +build !linux
+
+package runner
+
+import (
+	"context"
+	"errors"
+	"os/exec"
+
+	"github.com/testground/sdk-go/ptypes"
+	"github.com/testground/sdk-go/runtime"
+)
+
+type execShaping struct{}
+
+func newExecShaping(context.Context, string) (*execShaping, error) {
+	return nil, errors.New("traffic shaping with local:exec requires Linux")
+}
+
+func (*execShaping) controlHost() string { return "" }
+
+func (*execShaping) dataSubnet() *ptypes.IPNet { return nil }
+
+func (*execShaping) start(*exec.Cmd, runtime.RunParams, string) error {
+	return errors.New("traffic shaping with local:exec requires Linux")
+}
+
+func (*execShaping) close() error { return nil }
@@ -0,0 +1,25 @@
+package runner
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestExecSubnets(t *testing.T) {
+	data, control, err := execSubnets(0)
+	require.NoError(t, err)
+	require.Equal(t, "24.0.0.0/16", data.String())
+	require.Equal(t, "198.18.0.0/20", control.String())
+
+	data, control, err = execSubnets(17)
+	require.NoError(t, err)
+	require.Equal(t, "24.17.0.0/16", data.String())
+	require.Equal(t, "198.19.16.0/20", control.String())
+
+	// Subnets are reused once all of them were handed out.
+	data, control, err = execSubnets(2048 + 17)
+	require.NoError(t, err)
+	require.Equal(t, "24.17.0.0/16", data.String())
+	require.Equal(t, "198.19.16.0/20", control.String())
+}
@@ -0,0 +1,39 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/docker/docker/api/types/network"
+	"github.com/docker/docker/client"
+
+	"github.com/testground/testground/pkg/docker"
+	"github.com/testground/testground/pkg/rpc"
+)
+
+// EnsureObservabilityStack starts the InfluxDB and Grafana of the local
+// runners, on their control network, unless they're running already. The
+// local runners start them along with the rest of their infrastructure too,
+// when they're healthchecked.
+func EnsureObservabilityStack(ctx context.Context, ow *rpc.OutputWriter) error {
+	cli, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
+	if err != nil {
+		return err
+	}
+	defer cli.Close()
+
+	const controlNetworkID = "testground-control"
+	if _, err := docker.EnsureBridgeNetwork(ctx, ow, cli, controlNetworkID, false, network.IPAMConfig{Subnet: controlSubnet, Gateway: controlGateway}); err != nil {
+		return fmt.Errorf("failed to create the control network: %w", err)
+	}
+
+	for _, opts := range []*docker.EnsureContainerOpts{
+		influxdbContainerOpts(controlNetworkID),
+		grafanaContainerOpts(controlNetworkID),
+	} {
+		if _, _, err := docker.EnsureContainerStarted(ctx, ow, cli, opts); err != nil {
+			return fmt.Errorf("failed to start %s: %w", opts.ContainerName, err)
+		}
+	}
+	return nil
+}
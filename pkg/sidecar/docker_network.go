@@ -4,14 +4,20 @@ package sidecar
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net"
+	"os"
+	goruntime "runtime"
+	"syscall"
+	"time"
 
 	sdknw "github.com/testground/sdk-go/network"
 	"github.com/testground/testground/pkg/docker"
 
 	"github.com/docker/docker/api/types/network"
 	"github.com/vishvananda/netlink"
+	"github.com/vishvananda/netns"
 )
 
 type dockerLink struct {
@@ -25,6 +31,8 @@ type DockerNetwork struct {
 	availableLinks  map[string]string      // name -> id
 	externalRouting map[string]*route      // id -> routes
 	nl              *netlink.Handle
+	pid             int
+	ipv6            *IPv6Config
 }
 
 func (dn *DockerNetwork) Close() error {
@@ -48,12 +56,89 @@ func (dn *DockerNetwork) ListActive() []string {
 	return networks
 }
 
+func (dn *DockerNetwork) Addr(network string) net.IP {
+	ipv4, ipv6 := dn.Addrs(network)
+	if ipv4 == nil {
+		return ipv6
+	}
+	return ipv4
+}
+
+func (dn *DockerNetwork) Addrs(network string) (ipv4, ipv6 net.IP) {
+	link, ok := dn.activeLinks[network]
+	if !ok {
+		return nil, nil
+	}
+	if link.IPv4 != nil {
+		ipv4 = link.IPv4.IP
+	}
+	if link.IPv6 != nil {
+		ipv6 = link.IPv6.IP
+	}
+	return ipv4, ipv6
+}
+
+// Probe measures the time it takes to open a TCP connection to the given
+// address from within the container's network namespace. The port is closed
+// on purpose: a refused connection is a full round trip.
+func (dn *DockerNetwork) Probe(ctx context.Context, ip net.IP, timeout time.Duration) (time.Duration, error) {
+	// Network namespaces are per thread.
+	goruntime.LockOSThread()
+	defer goruntime.UnlockOSThread()
+
+	origin, err := netns.Get()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get current netns: %w", err)
+	}
+	defer origin.Close()
+
+	target, err := netns.GetFromPid(dn.pid)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get container netns: %w", err)
+	}
+	defer target.Close()
+
+	if err := netns.Set(target); err != nil {
+		return 0, fmt.Errorf("failed to enter container netns: %w", err)
+	}
+	defer netns.Set(origin) //nolint:errcheck
+
+	dialer := net.Dialer{Timeout: timeout}
+	start := time.Now()
+	conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(ip.String(), "1"))
+	rtt := time.Since(start)
+	if err == nil {
+		_ = conn.Close()
+		return rtt, nil
+	}
+	if errors.Is(err, syscall.ECONNREFUSED) {
+		return rtt, nil
+	}
+	return 0, err
+}
+
+// SocketStats reads the socket statistics of the container's network
+// namespace through the proc filesystem of its init process.
+func (dn *DockerNetwork) SocketStats() (*ProtocolStats, error) {
+	f, err := os.Open(fmt.Sprintf("/proc/%d/net/snmp", dn.pid))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return ParseSNMP(f)
+}
+
 func (dn *DockerNetwork) ConfigureNetwork(ctx context.Context, cfg *sdknw.Config) error {
 	netId, available := dn.availableLinks[cfg.Network]
 	if !available {
 		return fmt.Errorf("unsupported network: %s", cfg.Network)
 	}
 
+	if err := checkIPFamily(dn.ipv6, cfg); err != nil {
+		return err
+	}
+
 	err := handleRoutingPolicy(dn.externalRouting, cfg.RoutingPolicy, dn.nl)
 	if err != nil {
 		return err
@@ -74,8 +159,7 @@ func (dn *DockerNetwork) ConfigureNetwork(ctx context.Context, cfg *sdknw.Config
 		return nil
 	}
 
-	if online && ((cfg.IPv6 != nil && !link.IPv6.IP.Equal(cfg.IPv6.IP)) ||
-		(cfg.IPv4 != nil && !link.IPv4.IP.Equal(cfg.IPv4.IP))) {
+	if online && addrChanged(link.IPv4, link.IPv6, cfg) {
 		// Disconnect and reconnect to change the IP addresses.
 		//
 		// NOTE: We probably don't need to do this on local docker.
@@ -133,6 +217,14 @@ func (dn *DockerNetwork) ConfigureNetwork(ctx context.Context, cfg *sdknw.Config
 			IPv4:        linkInfo.IPv4,
 			IPv6:        linkInfo.IPv6,
 		}
+		// Docker always assigns an IPv4 address; drop it on IPv6-only
+		// data networks.
+		if dn.ipv6 != nil && dn.ipv6.Only && link.IPv4 != nil {
+			if err := link.AddrDel(link.IPv4); err != nil {
+				return fmt.Errorf("failed to remove the IPv4 address of the data link: %w", err)
+			}
+			link.IPv4 = nil
+		}
 		dn.activeLinks[cfg.Network] = link
 	}
 
@@ -201,12 +201,19 @@ func (d *DockerReactor) handleContainer(ctx context.Context, container *docker.C
 	}
 
 	// Finally, construct the network manager.
+	ipv6, err := ParseIPv6Config(info.Config.Env)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse ipv6 config: %w", err)
+	}
+
 	network := &DockerNetwork{
 		container:       container,
 		activeLinks:     make(map[string]*dockerLink, len(info.NetworkSettings.Networks)),
 		availableLinks:  make(map[string]string, len(networks)),
 		externalRouting: map[string]*route{},
 		nl:              netlinkHandle,
+		pid:             info.State.Pid,
+		ipv6:            ipv6,
 	}
 
 	// Retrieve control routes.
@@ -266,7 +273,24 @@ func (d *DockerReactor) handleContainer(ctx context.Context, container *docker.C
 		}
 	}
 
-	return NewInstance(d.client, runenv, info.Config.Hostname, network)
+	heatmap, err := ParseLatencyHeatmapConfig(info.Config.Env)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse latency heatmap config: %w", err)
+	}
+
+	trafficStatsInterval, err := ParseTrafficStatsInterval(info.Config.Env)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse traffic stats interval: %w", err)
+	}
+
+	inst, err = NewInstance(d.client, runenv, info.Config.Hostname, network)
+	if err != nil {
+		return nil, err
+	}
+	inst.LatencyHeatmap = heatmap
+	inst.TrafficStatsInterval = trafficStatsInterval
+	inst.IPv6 = ipv6
+	return inst, nil
 }
 
 func getNetworkHandlers(pid int) (netns.NsHandle, *netlink.Handle, error) {
@@ -0,0 +1,433 @@
+//go:build linux
+// +build linux
+
+package sidecar
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+	"net"
+	"os"
+	"os/exec"
+	goruntime "runtime"
+	gosync "sync"
+	"syscall"
+	"time"
+
+	sdknw "github.com/testground/sdk-go/network"
+
+	"github.com/vishvananda/netlink"
+	"github.com/vishvananda/netns"
+)
+
+const (
+	// execDataLink and execControlLink are the names of the links of an
+	// instance, in its network namespace.
+	execDataLink    = "eth0"
+	execControlLink = "ctl0"
+)
+
+// ExecNetworks are the networks of a run of the local:exec runner, when it
+// shapes traffic. Every instance runs in a network namespace of its own,
+// with two links: one to the data bridge of the run, which the sidecar
+// shapes, and one to its control bridge, which reaches the services
+// published on the host, and is never shaped.
+type ExecNetworks struct {
+	lk gosync.Mutex
+
+	prefix        string
+	data, control *net.IPNet
+	dataBridge    netlink.Link
+	controlBridge netlink.Link
+	instances     int
+}
+
+// NewExecNetworks creates the bridges of a run on the host. The first
+// address of each subnet is the address of the host on the bridge.
+func NewExecNetworks(runID string, data, control *net.IPNet) (*ExecNetworks, error) {
+	en := &ExecNetworks{
+		prefix:  execLinkPrefix(runID),
+		data:    data,
+		control: control,
+	}
+
+	var err error
+	if en.dataBridge, err = addBridge(en.prefix+"d", data); err != nil {
+		return nil, err
+	}
+	if en.controlBridge, err = addBridge(en.prefix+"c", control); err != nil {
+		_ = netlink.LinkDel(en.dataBridge)
+		return nil, err
+	}
+	return en, nil
+}
+
+// ControlGateway returns the address of the host on the control network,
+// where instances reach the sync service and the other services.
+func (en *ExecNetworks) ControlGateway() net.IP {
+	return nthIP(en.control, 1)
+}
+
+// NewInstance creates the network namespace of an instance, and links it to
+// the bridges of the run.
+func (en *ExecNetworks) NewInstance(hostname string) (*ExecNetwork, error) {
+	en.lk.Lock()
+	idx := en.instances
+	en.instances++
+	en.lk.Unlock()
+
+	dataIP := &net.IPNet{IP: nthIP(en.data, idx+2), Mask: en.data.Mask}
+	controlIP := &net.IPNet{IP: nthIP(en.control, idx+2), Mask: en.control.Mask}
+	if dataIP.IP == nil || controlIP.IP == nil || !en.data.Contains(dataIP.IP) || !en.control.Contains(controlIP.IP) {
+		return nil, fmt.Errorf("no address left for instance %d", idx)
+	}
+
+	ns, err := newNetns()
+	if err != nil {
+		return nil, err
+	}
+
+	nl, err := netlink.NewHandleAt(ns)
+	if err != nil {
+		ns.Close()
+		return nil, fmt.Errorf("failed to get netlink handle: %w", err)
+	}
+
+	n := &ExecNetwork{
+		hostname: hostname,
+		ns:       ns,
+		nl:       nl,
+		data:     en.data,
+	}
+
+	suffix := fmt.Sprintf("%x", idx)
+	n.hostLinks = []string{en.prefix + "d" + suffix, en.prefix + "c" + suffix}
+
+	dataLink, err := n.addVeth(en.dataBridge, n.hostLinks[0], execDataLink, dataIP)
+	if err == nil {
+		_, err = n.addVeth(en.controlBridge, n.hostLinks[1], execControlLink, controlIP)
+	}
+	if err == nil {
+		err = setLoopbackUp(nl)
+	}
+	if err == nil {
+		n.link, err = NewNetlinkLink(nl, dataLink)
+	}
+	if err != nil {
+		_ = n.Close()
+		return nil, err
+	}
+	n.ipv4 = dataIP
+	return n, nil
+}
+
+// Close deletes the bridges of the run.
+func (en *ExecNetworks) Close() error {
+	err1 := netlink.LinkDel(en.dataBridge)
+	err2 := netlink.LinkDel(en.controlBridge)
+	if err1 != nil {
+		return err1
+	}
+	return err2
+}
+
+// ExecNetwork is the network of an instance of the local:exec runner.
+type ExecNetwork struct {
+	hostname  string
+	ns        netns.NsHandle
+	nl        *netlink.Handle
+	data      *net.IPNet
+	hostLinks []string
+
+	link   *NetlinkLink
+	ipv4   *net.IPNet
+	active bool
+	pid    int
+}
+
+var (
+	_ Network       = (*ExecNetwork)(nil)
+	_ Prober        = (*ExecNetwork)(nil)
+	_ SocketStatser = (*ExecNetwork)(nil)
+)
+
+// Start starts a command in the network namespace of the instance, with the
+// hostname of the instance, in a UTS namespace of its own.
+func (n *ExecNetwork) Start(cmd *exec.Cmd) error {
+	errCh := make(chan error, 1)
+
+	// Namespaces are per thread. The thread is never unlocked, so that the
+	// runtime terminates it, rather than reusing it in the namespaces of
+	// the instance.
+	go func() {
+		goruntime.LockOSThread()
+
+		if err := netns.Set(n.ns); err != nil {
+			errCh <- fmt.Errorf("failed to enter the netns of the instance: %w", err)
+			return
+		}
+		if err := syscall.Unshare(syscall.CLONE_NEWUTS); err != nil {
+			errCh <- fmt.Errorf("failed to create the uts namespace of the instance: %w", err)
+			return
+		}
+		if err := syscall.Sethostname([]byte(n.hostname)); err != nil {
+			errCh <- fmt.Errorf("failed to set the hostname of the instance: %w", err)
+			return
+		}
+		errCh <- cmd.Start()
+	}()
+
+	if err := <-errCh; err != nil {
+		return err
+	}
+	n.pid = cmd.Process.Pid
+	return nil
+}
+
+func (n *ExecNetwork) Close() error {
+	for _, name := range n.hostLinks {
+		// Deleting a veth deletes its peer too.
+		if link, err := netlink.LinkByName(name); err == nil {
+			_ = netlink.LinkDel(link)
+		}
+	}
+	n.nl.Delete()
+	return n.ns.Close()
+}
+
+func (n *ExecNetwork) ListActive() []string {
+	if !n.active {
+		return nil
+	}
+	return []string{defaultDataNetwork}
+}
+
+func (n *ExecNetwork) Addr(network string) net.IP {
+	if network != defaultDataNetwork || !n.active {
+		return nil
+	}
+	return n.ipv4.IP
+}
+
+// Addrs returns the IPv4 address of the instance on the given network; the
+// data networks of local:exec instances are IPv4 only.
+func (n *ExecNetwork) Addrs(network string) (ipv4, ipv6 net.IP) {
+	return n.Addr(network), nil
+}
+
+// Probe measures the time it takes to open a TCP connection to the given
+// address from within the network namespace of the instance. The port is
+// closed on purpose: a refused connection is a full round trip.
+func (n *ExecNetwork) Probe(ctx context.Context, ip net.IP, timeout time.Duration) (time.Duration, error) {
+	goruntime.LockOSThread()
+	defer goruntime.UnlockOSThread()
+
+	origin, err := netns.Get()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get current netns: %w", err)
+	}
+	defer origin.Close()
+
+	if err := netns.Set(n.ns); err != nil {
+		return 0, fmt.Errorf("failed to enter the netns of the instance: %w", err)
+	}
+	defer netns.Set(origin) //nolint:errcheck
+
+	dialer := net.Dialer{Timeout: timeout}
+	start := time.Now()
+	conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(ip.String(), "1"))
+	rtt := time.Since(start)
+	if err == nil {
+		_ = conn.Close()
+		return rtt, nil
+	}
+	if errors.Is(err, syscall.ECONNREFUSED) {
+		return rtt, nil
+	}
+	return 0, err
+}
+
+// SocketStats reads the socket statistics of the network namespace of the
+// instance through the proc filesystem of its process.
+func (n *ExecNetwork) SocketStats() (*ProtocolStats, error) {
+	if n.pid == 0 {
+		return nil, errors.New("the instance is not started")
+	}
+	f, err := os.Open(fmt.Sprintf("/proc/%d/net/snmp", n.pid))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return ParseSNMP(f)
+}
+
+// ConfigureNetwork shapes the data link of the instance. Routing policies
+// are not supported: instances have no route out of the networks of the run
+// anyway.
+func (n *ExecNetwork) ConfigureNetwork(ctx context.Context, cfg *sdknw.Config) error {
+	if cfg.Network != defaultDataNetwork {
+		return fmt.Errorf("unsupported network: %s", cfg.Network)
+	}
+
+	if !cfg.Enable {
+		if n.active {
+			if err := n.link.Down(); err != nil {
+				return err
+			}
+			n.active = false
+		}
+		return nil
+	}
+
+	if cfg.IPv4 != nil && !cfg.IPv4.IP.Equal(n.ipv4.IP) {
+		ip := &net.IPNet{IP: cfg.IPv4.IP, Mask: n.data.Mask}
+		if !n.data.Contains(ip.IP) {
+			return fmt.Errorf("address %s is not in the data subnet %s", ip.IP, n.data)
+		}
+		if err := n.link.AddrDel(n.ipv4); err != nil {
+			return err
+		}
+		if err := n.link.AddrAdd(ip); err != nil {
+			return err
+		}
+		n.ipv4 = ip
+	}
+
+	if cfg.IPv6 != nil {
+		if err := n.link.AddrAdd(&cfg.IPv6.IPNet); err != nil && !errors.Is(err, syscall.EEXIST) {
+			return err
+		}
+	}
+
+	if !n.active {
+		if err := n.link.Up(); err != nil {
+			return err
+		}
+		n.active = true
+	}
+
+	if err := n.link.Shape(cfg.Default); err != nil {
+		return err
+	}
+
+	return n.link.AddRules(cfg.Rules)
+}
+
+// addVeth creates a veth pair, attaches its host end to a bridge, and moves
+// its other end into the namespace of the instance, under the given name.
+func (n *ExecNetwork) addVeth(bridge netlink.Link, hostName, name string, ip *net.IPNet) (netlink.Link, error) {
+	// The peer is created on the host, so it needs a name that is unique
+	// there until it's moved.
+	peerName := hostName + "p"
+	veth := &netlink.Veth{
+		LinkAttrs: netlink.LinkAttrs{Name: hostName, MasterIndex: bridge.Attrs().Index},
+		PeerName:  peerName,
+	}
+	if err := netlink.LinkAdd(veth); err != nil {
+		return nil, fmt.Errorf("failed to create link %s: %w", hostName, err)
+	}
+	if err := netlink.LinkSetUp(veth); err != nil {
+		return nil, fmt.Errorf("failed to set link %s up: %w", hostName, err)
+	}
+
+	peer, err := netlink.LinkByName(peerName)
+	if err != nil {
+		return nil, err
+	}
+	if err := netlink.LinkSetNsFd(peer, int(n.ns)); err != nil {
+		return nil, fmt.Errorf("failed to move link %s to the netns of the instance: %w", peerName, err)
+	}
+
+	if peer, err = n.nl.LinkByName(peerName); err != nil {
+		return nil, err
+	}
+	if err := n.nl.LinkSetName(peer, name); err != nil {
+		return nil, fmt.Errorf("failed to rename link %s: %w", peerName, err)
+	}
+	if err := n.nl.AddrAdd(peer, &netlink.Addr{IPNet: ip}); err != nil {
+		return nil, fmt.Errorf("failed to add address to link %s: %w", name, err)
+	}
+	if err := n.nl.LinkSetUp(peer); err != nil {
+		return nil, fmt.Errorf("failed to set link %s up: %w", name, err)
+	}
+	return n.nl.LinkByName(name)
+}
+
+// addBridge creates a bridge with the first address of a subnet.
+func addBridge(name string, subnet *net.IPNet) (netlink.Link, error) {
+	bridge := &netlink.Bridge{LinkAttrs: netlink.LinkAttrs{Name: name}}
+	if err := netlink.LinkAdd(bridge); err != nil {
+		return nil, fmt.Errorf("failed to create bridge %s: %w", name, err)
+	}
+	addr := &netlink.Addr{IPNet: &net.IPNet{IP: nthIP(subnet, 1), Mask: subnet.Mask}}
+	if err := netlink.AddrAdd(bridge, addr); err != nil {
+		_ = netlink.LinkDel(bridge)
+		return nil, fmt.Errorf("failed to add address to bridge %s: %w", name, err)
+	}
+	if err := netlink.LinkSetUp(bridge); err != nil {
+		_ = netlink.LinkDel(bridge)
+		return nil, fmt.Errorf("failed to set bridge %s up: %w", name, err)
+	}
+	return bridge, nil
+}
+
+// newNetns creates a network namespace, without entering it.
+func newNetns() (netns.NsHandle, error) {
+	goruntime.LockOSThread()
+	defer goruntime.UnlockOSThread()
+
+	origin, err := netns.Get()
+	if err != nil {
+		return netns.None(), fmt.Errorf("failed to get current netns: %w", err)
+	}
+	defer origin.Close()
+
+	ns, err := netns.New()
+	if err != nil {
+		return netns.None(), fmt.Errorf("failed to create netns: %w", err)
+	}
+	if err := netns.Set(origin); err != nil {
+		ns.Close()
+		return netns.None(), fmt.Errorf("failed to restore netns: %w", err)
+	}
+	return ns, nil
+}
+
+func setLoopbackUp(nl *netlink.Handle) error {
+	lo, err := nl.LinkByName("lo")
+	if err != nil {
+		return err
+	}
+	return nl.LinkSetUp(lo)
+}
+
+// execLinkPrefix returns the prefix of the names of the links of a run on
+// the host. Link names are limited to 15 characters, which leaves room for
+// a network letter and the index of the instance.
+func execLinkPrefix(runID string) string {
+	sum := sha256.Sum256([]byte(runID))
+	return "tg" + hex.EncodeToString(sum[:])[:5]
+}
+
+// nthIP returns the n-th address of a subnet.
+func nthIP(subnet *net.IPNet, n int) net.IP {
+	ip := subnet.IP.To4()
+	if ip == nil {
+		ip = subnet.IP.To16()
+	}
+	i := new(big.Int).SetBytes(ip)
+	i.Add(i, big.NewInt(int64(n)))
+
+	b := i.Bytes()
+	if len(b) > len(ip) {
+		return nil
+	}
+	res := make(net.IP, len(ip))
+	copy(res[len(res)-len(b):], b)
+	return res
+}
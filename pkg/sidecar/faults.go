@@ -0,0 +1,277 @@
+package sidecar
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sort"
+	"time"
+
+	"github.com/testground/sdk-go/network"
+	"github.com/testground/sdk-go/ptypes"
+	"github.com/testground/sdk-go/sync"
+
+	"github.com/testground/testground/pkg/api"
+)
+
+// NetworkFaultsTopic is the topic on which network faults are published to
+// the sidecars of a run, by the runner or by the test plan itself.
+var NetworkFaultsTopic = sync.NewTopic("network-faults", &api.NetworkFault{})
+
+// NetworkEventsTopic is the topic on which the sidecar publishes the changes
+// it applied to the data network of the instances.
+var NetworkEventsTopic = sync.NewTopic("network-events", &api.NetworkEvent{})
+
+// peerAddressesTimeout bounds the wait for the addresses of the instances of
+// the groups a fault partitions the instance from.
+const peerAddressesTimeout = 10 * time.Second
+
+// networkState is the configuration of the data network of an instance: the
+// last configuration requested by the test plan, and the faults applied on
+// top of it.
+type networkState struct {
+	plan *network.Config
+	// shape overrides the default shape of the plan configuration.
+	shape *network.LinkShape
+	// blocked are the subnets the data link drops the traffic to, keyed by
+	// their string form.
+	blocked map[string]*net.IPNet
+}
+
+func newNetworkState(plan *network.Config) *networkState {
+	return &networkState{plan: plan, blocked: make(map[string]*net.IPNet)}
+}
+
+// withPlan returns the state with the configuration requested by the test
+// plan replaced.
+func (s *networkState) withPlan(plan *network.Config) *networkState {
+	next := *s
+	next.plan = plan
+	return &next
+}
+
+// withFault returns the state with a fault applied, along with the subnets it
+// lifted the blocks on. subnets are the subnets the fault blocks.
+func (s *networkState) withFault(f *api.NetworkFault, subnets []*net.IPNet) (*networkState, []*net.IPNet) {
+	next := &networkState{plan: s.plan, shape: s.shape, blocked: make(map[string]*net.IPNet, len(s.blocked)+len(subnets))}
+
+	var lifted []*net.IPNet
+	if f.Heal {
+		next.shape = nil
+		for _, n := range s.blocked {
+			lifted = append(lifted, n)
+		}
+	} else {
+		for k, n := range s.blocked {
+			next.blocked[k] = n
+		}
+	}
+
+	if f.Shape != nil {
+		shape := *f.Shape
+		next.shape = &shape
+	}
+	for _, n := range subnets {
+		next.blocked[n.String()] = n
+	}
+
+	// Subnets blocked again by the fault are not lifted.
+	kept := lifted[:0]
+	for _, n := range lifted {
+		if _, ok := next.blocked[n.String()]; !ok {
+			kept = append(kept, n)
+		}
+	}
+	sortSubnets(kept)
+	return next, kept
+}
+
+// config returns the network configuration to apply for the state. lifted
+// are the subnets to accept the traffic to again.
+func (s *networkState) config(lifted []*net.IPNet) *network.Config {
+	cfg := *s.plan
+	// The addresses and the callback of the plan configuration were taken
+	// care of when the plan requested them.
+	cfg.IPv4, cfg.IPv6 = nil, nil
+	cfg.CallbackState = ""
+
+	if s.shape != nil {
+		cfg.Default = *s.shape
+	}
+
+	if len(lifted) == 0 && len(s.blocked) == 0 {
+		return &cfg
+	}
+
+	// Accept rules come first, so that the rules of the plan and the blocks
+	// of the faults take precedence over them.
+	cfg.Rules = make([]network.LinkRule, 0, len(lifted)+len(s.plan.Rules)+len(s.blocked))
+	for _, n := range lifted {
+		cfg.Rules = append(cfg.Rules, network.LinkRule{Subnet: ptypes.IPNet{IPNet: *n}})
+	}
+	cfg.Rules = append(cfg.Rules, s.plan.Rules...)
+	for _, n := range s.blockedSubnets() {
+		cfg.Rules = append(cfg.Rules, network.LinkRule{
+			LinkShape: network.LinkShape{Filter: network.Drop},
+			Subnet:    ptypes.IPNet{IPNet: *n},
+		})
+	}
+	return &cfg
+}
+
+func (s *networkState) blockedSubnets() []*net.IPNet {
+	subnets := make([]*net.IPNet, 0, len(s.blocked))
+	for _, n := range s.blocked {
+		subnets = append(subnets, n)
+	}
+	sortSubnets(subnets)
+	return subnets
+}
+
+// event returns the network event recording the state.
+func (s *networkState) event(instance *Instance, source, faultID string, err error) *api.NetworkEvent {
+	ev := &api.NetworkEvent{
+		Time:     time.Now().UTC(),
+		Instance: instance.RunEnv.TestGroupID + "/" + instance.Hostname,
+		Source:   source,
+		FaultID:  faultID,
+		Enabled:  s.plan.Enable,
+		Shape:    s.plan.Default,
+	}
+	if s.shape != nil {
+		ev.Shape = *s.shape
+	}
+	for _, n := range s.blockedSubnets() {
+		ev.Blocked = append(ev.Blocked, n.String())
+	}
+	if err != nil {
+		ev.Error = err.Error()
+	}
+	return ev
+}
+
+func sortSubnets(subnets []*net.IPNet) {
+	sort.Slice(subnets, func(i, j int) bool { return subnets[i].String() < subnets[j].String() })
+}
+
+// parseBlackhole parses a blackholed peer: an IP address, or a subnet in
+// CIDR notation.
+func parseBlackhole(s string) (*net.IPNet, error) {
+	if _, n, err := net.ParseCIDR(s); err == nil {
+		return n, nil
+	}
+	ip := net.ParseIP(s)
+	if ip == nil {
+		return nil, fmt.Errorf("invalid blackhole %q: not an IP address or a subnet", s)
+	}
+	if ip4 := ip.To4(); ip4 != nil {
+		return &net.IPNet{IP: ip4, Mask: net.CIDRMask(32, 32)}, nil
+	}
+	return &net.IPNet{IP: ip, Mask: net.CIDRMask(128, 128)}, nil
+}
+
+// peerBook keeps the data network addresses of the instances of the run, as
+// published by their sidecars.
+type peerBook struct {
+	ch    chan *DataAddresses
+	peers map[string]*DataAddresses
+}
+
+// subscribe subscribes to the addresses of the instances, once.
+func (b *peerBook) subscribe(ctx context.Context, client sync.Client) error {
+	if b.ch != nil {
+		return nil
+	}
+	ch := make(chan *DataAddresses, 64)
+	if _, err := client.Subscribe(ctx, DataAddressesTopic, ch); err != nil {
+		return fmt.Errorf("failed to subscribe to data network addresses: %w", err)
+	}
+	b.ch, b.peers = ch, make(map[string]*DataAddresses)
+	return nil
+}
+
+// await collects addresses until total instances published theirs, or the
+// timeout expires.
+func (b *peerBook) await(ctx context.Context, total int, timeout time.Duration) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	for len(b.peers) < total {
+		select {
+		case addrs := <-b.ch:
+			b.peers[addrs.Hostname] = addrs
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// subnets returns the host subnets of the instances on the other side of a
+// partition of the given groups from the rest of the run, as seen from an
+// instance of group.
+func (b *peerBook) subnets(groups []string, group string) []*net.IPNet {
+	in := make(map[string]bool, len(groups))
+	for _, g := range groups {
+		in[g] = true
+	}
+
+	var subnets []*net.IPNet
+	for _, p := range b.peers {
+		if in[p.Group] == in[group] {
+			continue
+		}
+		if p.IPv4 != nil {
+			subnets = append(subnets, &net.IPNet{IP: p.IPv4.To4(), Mask: net.CIDRMask(32, 32)})
+		}
+		if p.IPv6 != nil {
+			subnets = append(subnets, &net.IPNet{IP: p.IPv6, Mask: net.CIDRMask(128, 128)})
+		}
+	}
+	return subnets
+}
+
+// faultSubnets resolves the subnets a fault blocks.
+func faultSubnets(ctx context.Context, instance *Instance, peers *peerBook, f *api.NetworkFault) ([]*net.IPNet, error) {
+	var subnets []*net.IPNet
+	for _, s := range f.Blackhole {
+		n, err := parseBlackhole(s)
+		if err != nil {
+			return nil, err
+		}
+		subnets = append(subnets, n)
+	}
+
+	if len(f.Partition) == 0 {
+		return subnets, nil
+	}
+	if err := peers.subscribe(ctx, instance.Client); err != nil {
+		return nil, err
+	}
+	peers.await(ctx, instance.RunEnv.TestInstanceCount, peerAddressesTimeout)
+	return append(subnets, peers.subnets(f.Partition, instance.RunEnv.TestGroupID)...), nil
+}
+
+// publishNetworkEvent publishes a network event; failures are only logged,
+// as the timeline is for information only.
+func publishNetworkEvent(ctx context.Context, instance *Instance, ev *api.NetworkEvent) {
+	if _, err := instance.Client.Publish(ctx, NetworkEventsTopic, ev); err != nil {
+		instance.S().Warnw("failed to publish network event", "err", err)
+	}
+}
+
+// applyFault applies a fault to the data network of an instance. It returns
+// the resulting state, which is the current one if the fault could not be
+// applied, and the event recording it.
+func applyFault(ctx context.Context, instance *Instance, peers *peerBook, state *networkState, f *api.NetworkFault) (*networkState, *api.NetworkEvent) {
+	subnets, err := faultSubnets(ctx, instance, peers, f)
+	if err != nil {
+		instance.S().Warnw("failed to resolve network fault", "id", f.ID, "err", err)
+		return state, state.event(instance, api.NetworkEventFault, f.ID, err)
+	}
+
+	next, lifted := state.withFault(f, subnets)
+	if err := instance.Network.ConfigureNetwork(ctx, next.config(lifted)); err != nil {
+		instance.S().Warnw("failed to apply network fault", "id", f.ID, "err", err)
+		return state, state.event(instance, api.NetworkEventFault, f.ID, err)
+	}
+	return next, next.event(instance, api.NetworkEventFault, f.ID, nil)
+}
@@ -0,0 +1,100 @@
+package sidecar
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/testground/sdk-go/network"
+
+	"github.com/testground/testground/pkg/api"
+)
+
+func mustSubnet(t *testing.T, s string) *net.IPNet {
+	n, err := parseBlackhole(s)
+	require.NoError(t, err)
+	return n
+}
+
+func TestParseBlackhole(t *testing.T) {
+	require.Equal(t, "16.0.0.2/32", mustSubnet(t, "16.0.0.2").String())
+	require.Equal(t, "fd74:6700::2/128", mustSubnet(t, "fd74:6700::2").String())
+	require.Equal(t, "16.1.0.0/16", mustSubnet(t, "16.1.2.3/16").String())
+
+	_, err := parseBlackhole("tg-plan-case-run-a-0")
+	require.Error(t, err)
+}
+
+func TestNetworkStateFaults(t *testing.T) {
+	plan := &network.Config{
+		Network:       defaultDataNetwork,
+		Enable:        true,
+		Default:       network.LinkShape{Latency: 10 * time.Millisecond},
+		CallbackState: "configured",
+	}
+	state := newNetworkState(plan)
+
+	// An empty state applies the plan configuration, minus its callback.
+	cfg := state.config(nil)
+	require.Equal(t, plan.Default, cfg.Default)
+	require.Empty(t, cfg.Rules)
+	require.Empty(t, cfg.CallbackState)
+
+	// Faults add up.
+	shape := &network.LinkShape{Latency: time.Second, Loss: 10}
+	state, lifted := state.withFault(&api.NetworkFault{Shape: shape}, nil)
+	require.Empty(t, lifted)
+	state, lifted = state.withFault(&api.NetworkFault{}, []*net.IPNet{mustSubnet(t, "16.0.0.3"), mustSubnet(t, "16.0.0.2")})
+	require.Empty(t, lifted)
+
+	cfg = state.config(lifted)
+	require.Equal(t, *shape, cfg.Default)
+	require.Len(t, cfg.Rules, 2)
+	require.Equal(t, "16.0.0.2/32", cfg.Rules[0].Subnet.String())
+	require.Equal(t, network.Drop, cfg.Rules[0].Filter)
+	require.Equal(t, "16.0.0.3/32", cfg.Rules[1].Subnet.String())
+
+	// A new plan configuration keeps the faults.
+	state = state.withPlan(&network.Config{Network: defaultDataNetwork, Enable: true})
+	require.Equal(t, *shape, state.config(nil).Default)
+	require.Len(t, state.config(nil).Rules, 2)
+
+	// Healing restores the plan shape, and lifts the blocks not requested
+	// again.
+	state, lifted = state.withFault(&api.NetworkFault{Heal: true}, []*net.IPNet{mustSubnet(t, "16.0.0.3")})
+	require.Len(t, lifted, 1)
+	require.Equal(t, "16.0.0.2/32", lifted[0].String())
+
+	cfg = state.config(lifted)
+	require.Equal(t, network.LinkShape{}, cfg.Default)
+	require.Len(t, cfg.Rules, 2)
+	require.Equal(t, "16.0.0.2/32", cfg.Rules[0].Subnet.String())
+	require.Equal(t, network.Accept, cfg.Rules[0].Filter)
+	require.Equal(t, "16.0.0.3/32", cfg.Rules[1].Subnet.String())
+	require.Equal(t, network.Drop, cfg.Rules[1].Filter)
+}
+
+func TestPeerBookPartition(t *testing.T) {
+	b := peerBook{peers: map[string]*DataAddresses{
+		"a-0": {Hostname: "a-0", Group: "a", IPv4: net.ParseIP("16.0.0.2")},
+		"a-1": {Hostname: "a-1", Group: "a", IPv4: net.ParseIP("16.0.0.3")},
+		"b-0": {Hostname: "b-0", Group: "b", IPv6: net.ParseIP("fd74:6700::4")},
+		"c-0": {Hostname: "c-0", Group: "c", IPv4: net.ParseIP("16.0.0.5"), IPv6: net.ParseIP("fd74:6700::5")},
+	}}
+
+	subnets := func(groups []string, group string) []string {
+		var s []string
+		ns := b.subnets(groups, group)
+		sortSubnets(ns)
+		for _, n := range ns {
+			s = append(s, n.String())
+		}
+		return s
+	}
+
+	// Splitting b from the rest of the run: a and c lose b, and b loses a and c.
+	require.Equal(t, []string{"fd74:6700::4/128"}, subnets([]string{"b"}, "a"))
+	require.Equal(t, []string{"fd74:6700::4/128"}, subnets([]string{"b"}, "c"))
+	require.Equal(t, []string{"16.0.0.2/32", "16.0.0.3/32", "16.0.0.5/32", "fd74:6700::5/128"}, subnets([]string{"b"}, "b"))
+}
@@ -0,0 +1,183 @@
+package sidecar
+
+import (
+	"context"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/testground/sdk-go/sync"
+)
+
+const (
+	// EnvLatencyHeatmapInterval is set on test instances by runners that
+	// request a latency heatmap, to the interval between two rounds of probes.
+	EnvLatencyHeatmapInterval = "LATENCY_HEATMAP_INTERVAL"
+	// EnvLatencyHeatmapSampleSize is set on test instances by runners that
+	// request a latency heatmap, to the number of instances probing each other.
+	EnvLatencyHeatmapSampleSize = "LATENCY_HEATMAP_SAMPLE_SIZE"
+
+	DefaultLatencyHeatmapInterval   = 10 * time.Second
+	DefaultLatencyHeatmapSampleSize = 10
+)
+
+// LatencyHeatmapTopic is the topic on which the sidecar publishes the
+// round-trip times it measured between the sampled instances of a run.
+var LatencyHeatmapTopic = sync.NewTopic("latency-heatmap", &LatencySample{})
+
+// latencyPeersTopic is the topic on which the sidecar announces the sampled
+// instances and their data network addresses.
+var latencyPeersTopic = sync.NewTopic("latency-heatmap-peers", &latencyPeer{})
+
+// LatencySample is a single round-trip time measurement between two instances.
+type LatencySample struct {
+	Time time.Time     `json:"time"`
+	From string        `json:"from"`
+	To   string        `json:"to"`
+	RTT  time.Duration `json:"rtt"`
+	// Lost is true when the probe got no answer before timing out.
+	Lost bool `json:"lost"`
+}
+
+type latencyPeer struct {
+	Name string `json:"name"`
+	IP   net.IP `json:"ip"`
+}
+
+// LatencyHeatmapConfig configures the latency probes run by the sidecar on
+// behalf of an instance.
+type LatencyHeatmapConfig struct {
+	Interval   time.Duration
+	SampleSize int
+}
+
+// ParseLatencyHeatmapConfig extracts the latency heatmap configuration from
+// the environment of a test instance. It returns nil if the runner didn't
+// request a heatmap.
+func ParseLatencyHeatmapConfig(env []string) (*LatencyHeatmapConfig, error) {
+	var (
+		cfg     = &LatencyHeatmapConfig{Interval: DefaultLatencyHeatmapInterval, SampleSize: DefaultLatencyHeatmapSampleSize}
+		enabled bool
+		err     error
+	)
+
+	for _, kv := range env {
+		s := strings.SplitN(kv, "=", 2)
+		if len(s) != 2 {
+			continue
+		}
+
+		switch s[0] {
+		case EnvLatencyHeatmapInterval:
+			enabled = true
+			if cfg.Interval, err = time.ParseDuration(s[1]); err != nil {
+				return nil, err
+			}
+		case EnvLatencyHeatmapSampleSize:
+			enabled = true
+			if cfg.SampleSize, err = strconv.Atoi(s[1]); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if !enabled {
+		return nil, nil
+	}
+	return cfg, nil
+}
+
+// Prober is implemented by networks that can measure the round-trip time to
+// other instances from within the network namespace of the instance.
+type Prober interface {
+	// Addr returns the address of the instance on the given network, or nil
+	// if the instance is not attached to it.
+	Addr(network string) net.IP
+
+	// Probe measures the round-trip time to the given address. It returns an
+	// error if the probe got no answer.
+	Probe(ctx context.Context, ip net.IP, timeout time.Duration) (time.Duration, error)
+}
+
+// latencySampler collects the other members of the latency sample: the first
+// size instances to announce themselves, the probing instance included.
+// Announcements must be added in the order they were published.
+type latencySampler struct {
+	self  string
+	size  int
+	seen  int
+	peers []*latencyPeer
+}
+
+func (s *latencySampler) add(p *latencyPeer) {
+	s.seen++
+	if s.seen <= s.size && p.Name != s.self {
+		s.peers = append(s.peers, p)
+	}
+}
+
+// runLatencyProbes announces the instance as a member of the latency sample
+// and, if it made it into the sample, periodically probes all other members
+// until the context is done.
+func runLatencyProbes(ctx context.Context, instance *Instance, prober Prober) {
+	cfg := instance.LatencyHeatmap
+
+	ip := prober.Addr(defaultDataNetwork)
+	if ip == nil {
+		instance.S().Warnw("not probing latencies; instance has no address on the data network")
+		return
+	}
+
+	self := &latencyPeer{
+		Name: instance.RunEnv.TestGroupID + "/" + instance.Hostname,
+		IP:   ip,
+	}
+
+	announced := make(chan *latencyPeer, cfg.SampleSize)
+	seq, _, err := instance.Client.PublishSubscribe(ctx, latencyPeersTopic, self, announced)
+	if err != nil {
+		instance.S().Warnw("failed to join the latency sample", "err", err)
+		return
+	}
+
+	if seq > int64(cfg.SampleSize) {
+		// not sampled.
+		return
+	}
+
+	instance.S().Infow("probing latencies", "interval", cfg.Interval, "sample_size", cfg.SampleSize)
+
+	var (
+		sample = &latencySampler{self: self.Name, size: cfg.SampleSize}
+		ticker = time.NewTicker(cfg.Interval)
+	)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case p := <-announced:
+			sample.add(p)
+		case <-ticker.C:
+			for _, p := range sample.peers {
+				rtt, err := prober.Probe(ctx, p.IP, cfg.Interval)
+				if ctx.Err() != nil {
+					return
+				}
+
+				sample := &LatencySample{
+					Time: time.Now().UTC(),
+					From: self.Name,
+					To:   p.Name,
+					RTT:  rtt,
+					Lost: err != nil,
+				}
+				if _, err := instance.Client.Publish(ctx, LatencyHeatmapTopic, sample); err != nil {
+					instance.S().Warnw("failed to publish latency sample", "err", err)
+				}
+			}
+		}
+	}
+}
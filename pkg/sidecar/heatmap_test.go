@@ -0,0 +1,39 @@
+package sidecar
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLatencySampler(t *testing.T) {
+	names := func(s *latencySampler) []string {
+		var res []string
+		for _, p := range s.peers {
+			res = append(res, p.Name)
+		}
+		return res
+	}
+
+	announce := func(s *latencySampler, n int) {
+		for i := 1; i <= n; i++ {
+			s.add(&latencyPeer{Name: fmt.Sprintf("g/%d", i)})
+		}
+	}
+
+	// The probing instance counts towards the sample.
+	s := &latencySampler{self: "g/2", size: 3}
+	announce(s, 6)
+	require.Equal(t, []string{"g/1", "g/3"}, names(s))
+
+	// The last member of the sample probes the ones before it.
+	s = &latencySampler{self: "g/3", size: 3}
+	announce(s, 6)
+	require.Equal(t, []string{"g/1", "g/2"}, names(s))
+
+	// A sample of one has nobody to probe.
+	s = &latencySampler{self: "g/1", size: 1}
+	announce(s, 3)
+	require.Empty(t, names(s))
+}
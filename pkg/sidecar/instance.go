@@ -3,6 +3,7 @@ package sidecar
 import (
 	"context"
 	"io"
+	"time"
 
 	"github.com/testground/sdk-go/network"
 	"github.com/testground/sdk-go/runtime"
@@ -29,6 +30,17 @@ type Instance struct {
 	Client   sync.Client
 	RunEnv   *runtime.RunEnv
 	Network  Network
+
+	// LatencyHeatmap is set when the runner requested pairwise latency
+	// measurements between instances.
+	LatencyHeatmap *LatencyHeatmapConfig
+
+	// TrafficStatsInterval is set when the runner requested a summary of the
+	// traffic of the instance, to the interval between two snapshots.
+	TrafficStatsInterval time.Duration
+
+	// IPv6 is set when the data network of the instance has IPv6 addresses.
+	IPv6 *IPv6Config
 }
 
 // Network is a test instance's network, as seen by the sidecar.
@@ -0,0 +1,147 @@
+package sidecar
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/testground/sdk-go/network"
+	"github.com/testground/sdk-go/sync"
+)
+
+const (
+	// EnvTestSubnetV6 is set on test instances by runners of runs with an
+	// IPv6 or dual-stack data network, to its IPv6 subnet.
+	EnvTestSubnetV6 = "TEST_SUBNET_V6"
+	// EnvTestIPFamily is set on test instances by runners to the IP family
+	// of the data network: ipv4, ipv6 or dual.
+	EnvTestIPFamily = "TEST_IP_FAMILY"
+)
+
+// DataAddressesTopic is the topic on which the sidecar publishes the data
+// network addresses of the instances, once it assigned them.
+var DataAddressesTopic = sync.NewTopic("data-addresses", &DataAddresses{})
+
+// DataAddresses are the addresses of an instance on the data network.
+type DataAddresses struct {
+	Hostname string `json:"hostname"`
+	Group    string `json:"group"`
+	IPv4     net.IP `json:"ipv4,omitempty"`
+	IPv6     net.IP `json:"ipv6,omitempty"`
+}
+
+// IPv6Config is the IPv6 configuration of the data network of an instance.
+type IPv6Config struct {
+	// Subnet is the IPv6 subnet of the data network.
+	Subnet *net.IPNet
+	// Only is true for IPv6-only data networks: the sidecar removes the
+	// IPv4 address of the data link of the instance.
+	Only bool
+}
+
+// ParseIPv6Config extracts the IPv6 configuration of the data network from
+// the environment of a test instance. It returns nil if the data network is
+// IPv4 only.
+func ParseIPv6Config(env []string) (*IPv6Config, error) {
+	var (
+		cfg    IPv6Config
+		family string
+	)
+
+	for _, kv := range env {
+		s := strings.SplitN(kv, "=", 2)
+		if len(s) != 2 {
+			continue
+		}
+
+		switch s[0] {
+		case EnvTestSubnetV6:
+			_, subnet, err := net.ParseCIDR(s[1])
+			if err != nil {
+				return nil, err
+			}
+			if subnet.IP.To4() != nil {
+				return nil, fmt.Errorf("%s is not an IPv6 subnet", s[1])
+			}
+			cfg.Subnet = subnet
+		case EnvTestIPFamily:
+			family = s[1]
+		}
+	}
+
+	switch family {
+	case "", "ipv4":
+		return nil, nil
+	case "ipv6", "dual":
+		if cfg.Subnet == nil {
+			return nil, fmt.Errorf("no IPv6 subnet for an %s data network", family)
+		}
+		cfg.Only = family == "ipv6"
+		return &cfg, nil
+	default:
+		return nil, fmt.Errorf("unknown IP family %s", family)
+	}
+}
+
+// embedIPv4 returns the address of an IPv6 subnet that embeds an IPv4
+// address in its last 32 bits, for networks that only assign IPv4 addresses.
+func embedIPv4(subnet *net.IPNet, ip net.IP) net.IP {
+	v6 := make(net.IP, net.IPv6len)
+	copy(v6, subnet.IP.To16())
+	copy(v6[12:], ip.To4())
+	return v6
+}
+
+// checkIPFamily checks that the addresses a network configuration requests
+// are of the families of the data network.
+func checkIPFamily(ipv6 *IPv6Config, cfg *network.Config) error {
+	switch {
+	case cfg.IPv6 != nil && ipv6 == nil:
+		return fmt.Errorf("cannot assign %s: the data network has no IPv6 addresses", cfg.IPv6.IP)
+	case cfg.IPv6 != nil && !ipv6.Subnet.Contains(cfg.IPv6.IP):
+		return fmt.Errorf("cannot assign %s: it is not in the data subnet %s", cfg.IPv6.IP, ipv6.Subnet)
+	case cfg.IPv4 != nil && ipv6 != nil && ipv6.Only:
+		return fmt.Errorf("cannot assign %s: the data network is IPv6 only", cfg.IPv4.IP)
+	}
+	return nil
+}
+
+// addrChanged returns whether a network configuration requests addresses
+// other than the current ones of a link.
+func addrChanged(ipv4, ipv6 *net.IPNet, cfg *network.Config) bool {
+	changed := func(cur *net.IPNet, req *net.IPNet) bool {
+		return req != nil && (cur == nil || !cur.IP.Equal(req.IP))
+	}
+	var req4, req6 *net.IPNet
+	if cfg.IPv4 != nil {
+		req4 = &cfg.IPv4.IPNet
+	}
+	if cfg.IPv6 != nil {
+		req6 = &cfg.IPv6.IPNet
+	}
+	return changed(ipv4, req4) || changed(ipv6, req6)
+}
+
+// publishDataAddresses publishes the data network addresses of an instance.
+func publishDataAddresses(ctx context.Context, instance *Instance) error {
+	addresser, ok := instance.Network.(Addresser)
+	if !ok {
+		return nil
+	}
+
+	addrs := &DataAddresses{Hostname: instance.Hostname, Group: instance.RunEnv.TestGroupID}
+	addrs.IPv4, addrs.IPv6 = addresser.Addrs(defaultDataNetwork)
+	if _, err := instance.Client.Publish(ctx, DataAddressesTopic, addrs); err != nil {
+		return fmt.Errorf("failed to publish data network addresses: %w", err)
+	}
+	return nil
+}
+
+// Addresser is implemented by networks that know the addresses of the
+// instance.
+type Addresser interface {
+	// Addrs returns the IPv4 and IPv6 addresses of the instance on the
+	// given network; either is nil if the instance has no such address.
+	Addrs(network string) (ipv4, ipv6 net.IP)
+}
@@ -0,0 +1,69 @@
+package sidecar
+
+import (
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/testground/sdk-go/network"
+	"github.com/testground/sdk-go/ptypes"
+)
+
+func TestParseIPv6Config(t *testing.T) {
+	cfg, err := ParseIPv6Config([]string{"FOO=bar"})
+	require.NoError(t, err)
+	require.Nil(t, cfg)
+
+	cfg, err = ParseIPv6Config([]string{EnvTestIPFamily + "=dual", EnvTestSubnetV6 + "=fd74:6700:10:0::/64"})
+	require.NoError(t, err)
+	require.Equal(t, "fd74:6700:10::/64", cfg.Subnet.String())
+	require.False(t, cfg.Only)
+
+	_, err = ParseIPv6Config([]string{EnvTestIPFamily + "=ipv6"})
+	require.Error(t, err)
+
+	_, err = ParseIPv6Config([]string{EnvTestIPFamily + "=ipv6", EnvTestSubnetV6 + "=16.0.0.0/16"})
+	require.Error(t, err)
+
+	_, err = ParseIPv6Config([]string{EnvTestIPFamily + "=ipx"})
+	require.Error(t, err)
+}
+
+func TestEmbedIPv4(t *testing.T) {
+	_, subnet, _ := net.ParseCIDR("fd74:6700:10::/64")
+	ip := embedIPv4(subnet, net.ParseIP("16.0.3.4"))
+	require.Equal(t, "fd74:6700:10::1000:304", ip.String())
+	require.True(t, subnet.Contains(ip))
+}
+
+func TestCheckIPFamily(t *testing.T) {
+	_, subnet, _ := net.ParseCIDR("fd74:6700:10::/64")
+	v4 := &ptypes.IPNet{IPNet: net.IPNet{IP: net.ParseIP("16.0.0.5"), Mask: net.CIDRMask(16, 32)}}
+	v6 := &ptypes.IPNet{IPNet: net.IPNet{IP: net.ParseIP("fd74:6700:10::5"), Mask: subnet.Mask}}
+	outside := &ptypes.IPNet{IPNet: net.IPNet{IP: net.ParseIP("fd74:6700:11::5"), Mask: subnet.Mask}}
+
+	dual := &IPv6Config{Subnet: subnet}
+	only := &IPv6Config{Subnet: subnet, Only: true}
+
+	require.NoError(t, checkIPFamily(nil, &network.Config{IPv4: v4}))
+	require.Error(t, checkIPFamily(nil, &network.Config{IPv6: v6}))
+	require.NoError(t, checkIPFamily(dual, &network.Config{IPv4: v4, IPv6: v6}))
+	require.Error(t, checkIPFamily(dual, &network.Config{IPv6: outside}))
+	require.Error(t, checkIPFamily(only, &network.Config{IPv4: v4}))
+	require.NoError(t, checkIPFamily(only, &network.Config{IPv6: v6}))
+}
+
+func TestAddrChanged(t *testing.T) {
+	cur := &net.IPNet{IP: net.ParseIP("16.0.0.5"), Mask: net.CIDRMask(16, 32)}
+	same := &ptypes.IPNet{IPNet: *cur}
+	other := &ptypes.IPNet{IPNet: net.IPNet{IP: net.ParseIP("16.0.0.6"), Mask: cur.Mask}}
+	v6 := &ptypes.IPNet{IPNet: net.IPNet{IP: net.ParseIP("fd74:6700:10::5"), Mask: net.CIDRMask(64, 128)}}
+
+	require.False(t, addrChanged(cur, nil, &network.Config{}))
+	require.False(t, addrChanged(cur, nil, &network.Config{IPv4: same}))
+	require.True(t, addrChanged(cur, nil, &network.Config{IPv4: other}))
+	// A link without an IPv6 address needs one.
+	require.True(t, addrChanged(cur, nil, &network.Config{IPv6: v6}))
+	// A link without an IPv4 address, on an IPv6-only network, needs one.
+	require.True(t, addrChanged(nil, &v6.IPNet, &network.Config{IPv4: same}))
+}
@@ -37,6 +37,7 @@ type K8sNetwork struct {
 	subnet          string
 	netnsPath       string
 	initialized     bool
+	ipv6            *IPv6Config
 }
 
 func (n *K8sNetwork) Close() error {
@@ -116,6 +117,10 @@ func (n *K8sNetwork) ConfigureNetwork(ctx context.Context, cfg *network.Config)
 		return fmt.Errorf("configured network is not `%s`", defaultDataNetwork)
 	}
 
+	if err := checkIPFamily(n.ipv6, cfg); err != nil {
+		return err
+	}
+
 	if !n.initialized {
 		err := n.InitializeNetwork(ctx)
 		if err != nil {
@@ -139,8 +144,7 @@ func (n *K8sNetwork) ConfigureNetwork(ctx context.Context, cfg *network.Config)
 		return nil
 	}
 
-	if online && ((cfg.IPv6 != nil && !link.IPv6.IP.Equal(cfg.IPv6.IP)) ||
-		(cfg.IPv4 != nil && !link.IPv4.IP.Equal(cfg.IPv4.IP))) {
+	if online && addrChanged(link.IPv4, link.IPv6, cfg) {
 
 		// Disconnect and reconnect to change the IP addresses.
 		logging.S().Infow("disconnect and reconnect to change the IP addr", "cfg.IPv4", cfg.IPv4, "link.IPv4", link.IPv4.String(), "container", n.container.ID)
@@ -158,10 +162,6 @@ func (n *K8sNetwork) ConfigureNetwork(ctx context.Context, cfg *network.Config)
 	if !online {
 		// No, we're not.
 		// Connect.
-		if cfg.IPv6 != nil {
-			return errors.New("ipv6 not supported")
-		}
-
 		var (
 			netconf *libcni.NetworkConfigList
 			err     error
@@ -240,6 +240,12 @@ func (n *K8sNetwork) ConfigureNetwork(ctx context.Context, cfg *network.Config)
 			netconf:     netconf,
 		}
 
+		if n.ipv6 != nil {
+			if err := n.addIPv6(link, cfg); err != nil {
+				return err
+			}
+		}
+
 		n.activeLinks[cfg.Network] = link
 	}
 
@@ -255,6 +261,42 @@ func (n *K8sNetwork) ConfigureNetwork(ctx context.Context, cfg *network.Config)
 	return nil
 }
 
+// addIPv6 assigns an IPv6 address to the data link, which weave doesn't do:
+// the requested one, or the one embedding the IPv4 address of the link. On
+// IPv6-only data networks, it removes the IPv4 address.
+func (n *K8sNetwork) addIPv6(link *k8sLink, cfg *network.Config) error {
+	ip := &net.IPNet{IP: embedIPv4(n.ipv6.Subnet, link.IPv4.IP), Mask: n.ipv6.Subnet.Mask}
+	if cfg.IPv6 != nil {
+		ip.IP = cfg.IPv6.IP
+	}
+	if err := link.AddrAdd(ip); err != nil {
+		return fmt.Errorf("failed to add the IPv6 address of the data link: %w", err)
+	}
+	link.IPv6 = ip
+
+	if n.ipv6.Only {
+		if err := link.AddrDel(link.IPv4); err != nil {
+			return fmt.Errorf("failed to remove the IPv4 address of the data link: %w", err)
+		}
+		link.IPv4 = nil
+	}
+	return nil
+}
+
+func (n *K8sNetwork) Addrs(network string) (ipv4, ipv6 net.IP) {
+	link, ok := n.activeLinks[network]
+	if !ok {
+		return nil, nil
+	}
+	if link.IPv4 != nil {
+		ipv4 = link.IPv4.IP
+	}
+	if link.IPv6 != nil {
+		ipv6 = link.IPv6.IP
+	}
+	return ipv4, ipv6
+}
+
 func (n *K8sNetwork) ListActive() []string {
 	networks := make([]string, 0, len(n.activeLinks))
 	for name := range n.activeLinks {
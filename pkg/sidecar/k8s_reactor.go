@@ -163,10 +163,16 @@ func (d *K8sReactor) manageContainer(ctx context.Context, container *docker.Cont
 		return nil, fmt.Errorf("couldn't get pod name from container labels for: %s", container.ID)
 	}
 
+	// Runs may have namespaces of their own.
+	podNamespace, ok := info.Config.Labels["io.kubernetes.pod.namespace"]
+	if !ok {
+		podNamespace = "default"
+	}
+
 	// Resolve allowed services, so that we update network routes
 	d.ResolveServices(params.TestRun)
 
-	err = waitForPodRunningPhase(ctx, podName)
+	err = waitForPodRunningPhase(ctx, podNamespace, podName)
 	if err != nil {
 		return nil, err
 	}
@@ -200,6 +206,11 @@ func (d *K8sReactor) manageContainer(ctx context.Context, container *docker.Cont
 		}
 	}()
 
+	ipv6, err := ParseIPv6Config(info.Config.Env)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse ipv6 config: %w", err)
+	}
+
 	// Finally, construct the network manager.
 	network := &K8sNetwork{
 		netnsPath:       fmt.Sprintf("/proc/%d/ns/net", info.State.Pid),
@@ -209,6 +220,7 @@ func (d *K8sReactor) manageContainer(ctx context.Context, container *docker.Cont
 		nl:              netlinkHandle,
 		activeLinks:     make(map[string]*k8sLink),
 		externalRouting: map[string]*route{},
+		ipv6:            ipv6,
 	}
 
 	// Remove all routes but redis and the data subnet
@@ -242,10 +254,15 @@ func (d *K8sReactor) manageContainer(ctx context.Context, container *docker.Cont
 		}
 	}
 
-	return NewInstance(d.client, runenv, info.Config.Hostname, network)
+	inst, err = NewInstance(d.client, runenv, info.Config.Hostname, network)
+	if err != nil {
+		return nil, err
+	}
+	inst.IPv6 = ipv6
+	return inst, nil
 }
 
-func waitForPodRunningPhase(ctx context.Context, podName string) error {
+func waitForPodRunningPhase(ctx context.Context, namespace, podName string) error {
 	k8scfg, err := clientcmd.BuildConfigFromFlags("", "")
 	if err != nil {
 		return fmt.Errorf("error in wait for pod running phase: %v", err)
@@ -266,7 +283,7 @@ func waitForPodRunningPhase(ctx context.Context, podName string) error {
 			if phase == "Running" {
 				return nil
 			}
-			pod, err := k8sClientset.CoreV1().Pods("default").Get(ctx, podName, metav1.GetOptions{})
+			pod, err := k8sClientset.CoreV1().Pods(namespace).Get(ctx, podName, metav1.GetOptions{})
 			if err != nil {
 				return fmt.Errorf("error in wait for pod running phase: %v", err)
 			}
@@ -3,6 +3,7 @@ package sidecar
 import (
 	"context"
 	"errors"
+	"io/ioutil"
 	"math/rand"
 	"os"
 	"strconv"
@@ -26,6 +27,14 @@ func init() {
 // SDK client are handled as though the come from the mocked instance.
 func NewMockReactor() (Reactor, error) {
 	unique := strconv.Itoa(rand.Int())
+
+	// The run environment writes its metrics to the outputs path; keep them
+	// out of the working directory.
+	outputs, err := ioutil.TempDir("", "sidecar-mock-")
+	if err != nil {
+		return nil, err
+	}
+
 	params := runtime.RunParams{
 		TestCase:               "TestCase" + unique,
 		TestGroupID:            "TestGroupID" + unique,
@@ -35,6 +44,7 @@ func NewMockReactor() (Reactor, error) {
 		TestPlan:               "TestPlan" + unique,
 		TestRun:                unique,
 		TestSidecar:            true,
+		TestOutputsPath:        outputs,
 	}
 	runenv := runtime.NewRunEnv(params)
 	network := NewMockNetwork()
@@ -6,12 +6,21 @@ import (
 
 	"github.com/testground/sdk-go/network"
 	"github.com/testground/sdk-go/sync"
+
+	"github.com/testground/testground/pkg/api"
 )
 
 const (
 	defaultDataNetwork = "default"
 )
 
+// Manage manages the network of an instance until the context is done, as
+// the sidecar does for the instances of container runners. It closes the
+// instance when it returns.
+func Manage(ctx context.Context, instance *Instance) error {
+	return handler(ctx, instance)
+}
+
 func handler(ctx context.Context, instance *Instance) error {
 	instance.S().Debugw("managing instance", "instance", instance.Hostname)
 
@@ -23,10 +32,11 @@ func handler(ctx context.Context, instance *Instance) error {
 	}()
 
 	// Network configuration loop.
-	err := instance.Network.ConfigureNetwork(ctx, &network.Config{
+	state := newNetworkState(&network.Config{
 		Network: defaultDataNetwork,
 		Enable:  true,
 	})
+	err := instance.Network.ConfigureNetwork(ctx, state.plan)
 
 	if err != nil {
 		return err
@@ -34,6 +44,10 @@ func handler(ctx context.Context, instance *Instance) error {
 
 	ctx = sync.WithRunParams(ctx, &instance.RunEnv.RunParams)
 
+	if err := publishDataAddresses(ctx, instance); err != nil {
+		return err
+	}
+
 	// Wait for all the sidecars to enter the "network-initialized" state.
 	instance.S().Infof("waiting for all networks to be ready")
 
@@ -45,6 +59,14 @@ func handler(ctx context.Context, instance *Instance) error {
 
 	instance.S().Infof("all networks ready")
 
+	if prober, ok := instance.Network.(Prober); ok && instance.LatencyHeatmap != nil {
+		go runLatencyProbes(ctx, instance, prober)
+	}
+
+	if statser, ok := instance.Network.(SocketStatser); ok && instance.TrafficStatsInterval > 0 {
+		go runTrafficStats(ctx, instance, statser)
+	}
+
 	// Now let the test case tell us how to configure the network.
 	topic := sync.NewTopic("network:"+instance.Hostname, network.Config{})
 	networkChanges := make(chan *network.Config, 16)
@@ -52,6 +74,13 @@ func handler(ctx context.Context, instance *Instance) error {
 		return fmt.Errorf("failed to subscribe to network changes: %s", err)
 	}
 
+	// Faults are applied on top of the configuration of the test case.
+	faults := make(chan *api.NetworkFault, 16)
+	if _, err := instance.Client.Subscribe(ctx, NetworkFaultsTopic, faults); err != nil {
+		return fmt.Errorf("failed to subscribe to network faults: %s", err)
+	}
+	var peers peerBook
+
 	for {
 		select {
 		case <-ctx.Done():
@@ -68,9 +97,25 @@ func handler(ctx context.Context, instance *Instance) error {
 			}
 
 			instance.S().Infow("applying network change", "network", cfg)
+
+			// Faults only apply to the data network.
+			var next *networkState
+			if cfg.Network == defaultDataNetwork {
+				next = state.withPlan(cfg)
+				applied := *next.config(nil)
+				applied.IPv4, applied.IPv6, applied.CallbackState = cfg.IPv4, cfg.IPv6, cfg.CallbackState
+				cfg = &applied
+			}
 			if err := instance.Network.ConfigureNetwork(ctx, cfg); err != nil {
+				if next != nil {
+					publishNetworkEvent(ctx, instance, next.event(instance, api.NetworkEventPlan, "", err))
+				}
 				return fmt.Errorf("failed to update network %s: %w", cfg.Network, err)
 			}
+			if next != nil {
+				state = next
+				publishNetworkEvent(ctx, instance, state.event(instance, api.NetworkEventPlan, "", nil))
+			}
 
 			if cfg.CallbackState != "" {
 				_, err := instance.Client.SignalEntry(ctx, cfg.CallbackState)
@@ -78,6 +123,20 @@ func handler(ctx context.Context, instance *Instance) error {
 					return fmt.Errorf("failed to signal network state change %s: %w", cfg.CallbackState, err)
 				}
 			}
+
+		case f, ok := <-faults:
+			if !ok {
+				instance.S().Debugw("faults channel closed", "instance", instance.Hostname)
+				return nil
+			}
+			if f.Group != "" && f.Group != instance.RunEnv.TestGroupID {
+				continue
+			}
+
+			instance.S().Infow("applying network fault", "fault", f)
+			var ev *api.NetworkEvent
+			state, ev = applyFault(ctx, instance, &peers, state, f)
+			publishNetworkEvent(ctx, instance, ev)
 		}
 	}
 }
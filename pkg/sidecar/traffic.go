@@ -0,0 +1,217 @@
+package sidecar
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/testground/sdk-go/sync"
+)
+
+// EnvTrafficStatsInterval is set on test instances by runners that request a
+// traffic summary, to the interval between two snapshots of the socket
+// statistics.
+const EnvTrafficStatsInterval = "TRAFFIC_STATS_INTERVAL"
+
+// TrafficStatsTopic is the topic on which the sidecar publishes snapshots of
+// the socket statistics of the instances.
+var TrafficStatsTopic = sync.NewTopic("traffic-stats", &TrafficStatsSample{})
+
+// TrafficStatsSample is a snapshot of the socket statistics of an instance.
+// Counters are cumulative since the instance started.
+type TrafficStatsSample struct {
+	Time     time.Time     `json:"time"`
+	Instance string        `json:"instance"`
+	Stats    ProtocolStats `json:"stats"`
+}
+
+// ProtocolStats are the per-protocol socket statistics of the network
+// namespace of an instance, as reported by the kernel in /proc/net/snmp. They
+// cover all the networks of the instance, including the control network.
+type ProtocolStats struct {
+	TCP  TCPStats  `json:"tcp"`
+	UDP  UDPStats  `json:"udp"`
+	ICMP ICMPStats `json:"icmp"`
+}
+
+type TCPStats struct {
+	// ActiveOpens is the number of connections initiated by the instance.
+	ActiveOpens int64 `json:"active_opens"`
+	// PassiveOpens is the number of connections accepted by the instance.
+	PassiveOpens int64 `json:"passive_opens"`
+	// AttemptFails is the number of failed handshakes.
+	AttemptFails int64 `json:"attempt_fails"`
+	// EstabResets is the number of established connections that were reset.
+	EstabResets int64 `json:"estab_resets"`
+	// CurrEstab is the number of connections established at the time of the
+	// snapshot.
+	CurrEstab   int64 `json:"curr_estab"`
+	InSegs      int64 `json:"in_segs"`
+	OutSegs     int64 `json:"out_segs"`
+	RetransSegs int64 `json:"retrans_segs"`
+	InErrs      int64 `json:"in_errs"`
+	OutRsts     int64 `json:"out_rsts"`
+}
+
+type UDPStats struct {
+	InDatagrams  int64 `json:"in_datagrams"`
+	OutDatagrams int64 `json:"out_datagrams"`
+	// NoPorts is the number of datagrams received for a port nobody listens
+	// on.
+	NoPorts      int64 `json:"no_ports"`
+	InErrors     int64 `json:"in_errors"`
+	RcvbufErrors int64 `json:"rcvbuf_errors"`
+	SndbufErrors int64 `json:"sndbuf_errors"`
+}
+
+type ICMPStats struct {
+	InMsgs         int64 `json:"in_msgs"`
+	OutMsgs        int64 `json:"out_msgs"`
+	InErrors       int64 `json:"in_errors"`
+	InDestUnreachs int64 `json:"in_dest_unreachs"`
+}
+
+// Add adds the counters of o to s.
+func (s *ProtocolStats) Add(o *ProtocolStats) {
+	s.TCP.ActiveOpens += o.TCP.ActiveOpens
+	s.TCP.PassiveOpens += o.TCP.PassiveOpens
+	s.TCP.AttemptFails += o.TCP.AttemptFails
+	s.TCP.EstabResets += o.TCP.EstabResets
+	s.TCP.CurrEstab += o.TCP.CurrEstab
+	s.TCP.InSegs += o.TCP.InSegs
+	s.TCP.OutSegs += o.TCP.OutSegs
+	s.TCP.RetransSegs += o.TCP.RetransSegs
+	s.TCP.InErrs += o.TCP.InErrs
+	s.TCP.OutRsts += o.TCP.OutRsts
+
+	s.UDP.InDatagrams += o.UDP.InDatagrams
+	s.UDP.OutDatagrams += o.UDP.OutDatagrams
+	s.UDP.NoPorts += o.UDP.NoPorts
+	s.UDP.InErrors += o.UDP.InErrors
+	s.UDP.RcvbufErrors += o.UDP.RcvbufErrors
+	s.UDP.SndbufErrors += o.UDP.SndbufErrors
+
+	s.ICMP.InMsgs += o.ICMP.InMsgs
+	s.ICMP.OutMsgs += o.ICMP.OutMsgs
+	s.ICMP.InErrors += o.ICMP.InErrors
+	s.ICMP.InDestUnreachs += o.ICMP.InDestUnreachs
+}
+
+// ParseTrafficStatsInterval extracts the interval between two snapshots of
+// the socket statistics from the environment of a test instance. It returns
+// zero if the runner didn't request a traffic summary.
+func ParseTrafficStatsInterval(env []string) (time.Duration, error) {
+	for _, kv := range env {
+		s := strings.SplitN(kv, "=", 2)
+		if len(s) == 2 && s[0] == EnvTrafficStatsInterval {
+			return time.ParseDuration(s[1])
+		}
+	}
+	return 0, nil
+}
+
+// ParseSNMP parses the socket statistics in the format of /proc/net/snmp,
+// where each protocol comes as a line of field names followed by a line of
+// values.
+func ParseSNMP(r io.Reader) (*ProtocolStats, error) {
+	counters := make(map[string]map[string]int64)
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		names := strings.Fields(scanner.Text())
+		if len(names) == 0 {
+			continue
+		}
+		if !scanner.Scan() {
+			return nil, fmt.Errorf("missing values for %s", names[0])
+		}
+		values := strings.Fields(scanner.Text())
+		if len(names) != len(values) || names[0] != values[0] {
+			return nil, fmt.Errorf("malformed snmp statistics")
+		}
+
+		proto := strings.TrimSuffix(names[0], ":")
+		counters[proto] = make(map[string]int64, len(names)-1)
+		for i := 1; i < len(names); i++ {
+			v, err := strconv.ParseInt(values[i], 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("malformed %s counter %s: %w", proto, names[i], err)
+			}
+			counters[proto][names[i]] = v
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	tcp, udp, icmp := counters["Tcp"], counters["Udp"], counters["Icmp"]
+	return &ProtocolStats{
+		TCP: TCPStats{
+			ActiveOpens:  tcp["ActiveOpens"],
+			PassiveOpens: tcp["PassiveOpens"],
+			AttemptFails: tcp["AttemptFails"],
+			EstabResets:  tcp["EstabResets"],
+			CurrEstab:    tcp["CurrEstab"],
+			InSegs:       tcp["InSegs"],
+			OutSegs:      tcp["OutSegs"],
+			RetransSegs:  tcp["RetransSegs"],
+			InErrs:       tcp["InErrs"],
+			OutRsts:      tcp["OutRsts"],
+		},
+		UDP: UDPStats{
+			InDatagrams:  udp["InDatagrams"],
+			OutDatagrams: udp["OutDatagrams"],
+			NoPorts:      udp["NoPorts"],
+			InErrors:     udp["InErrors"],
+			RcvbufErrors: udp["RcvbufErrors"],
+			SndbufErrors: udp["SndbufErrors"],
+		},
+		ICMP: ICMPStats{
+			InMsgs:         icmp["InMsgs"],
+			OutMsgs:        icmp["OutMsgs"],
+			InErrors:       icmp["InErrors"],
+			InDestUnreachs: icmp["InDestUnreachs"],
+		},
+	}, nil
+}
+
+// SocketStatser is implemented by networks that can report the socket
+// statistics of the network namespace of the instance.
+type SocketStatser interface {
+	SocketStats() (*ProtocolStats, error)
+}
+
+// runTrafficStats periodically publishes a snapshot of the socket statistics
+// of the instance until the context is done.
+func runTrafficStats(ctx context.Context, instance *Instance, statser SocketStatser) {
+	name := instance.RunEnv.TestGroupID + "/" + instance.Hostname
+
+	ticker := time.NewTicker(instance.TrafficStatsInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			stats, err := statser.SocketStats()
+			if err != nil {
+				instance.S().Warnw("failed to read socket statistics", "err", err)
+				continue
+			}
+
+			sample := &TrafficStatsSample{
+				Time:     time.Now().UTC(),
+				Instance: name,
+				Stats:    *stats,
+			}
+			if _, err := instance.Client.Publish(ctx, TrafficStatsTopic, sample); err != nil && ctx.Err() == nil {
+				instance.S().Warnw("failed to publish socket statistics", "err", err)
+			}
+		}
+	}
+}
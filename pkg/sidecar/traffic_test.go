@@ -0,0 +1,50 @@
+package sidecar
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const snmp = `Ip: Forwarding DefaultTTL InReceives
+Ip: 1 64 1200
+Icmp: InMsgs InErrors InCsumErrors InDestUnreachs OutMsgs
+Icmp: 4 0 0 3 5
+Tcp: RtoAlgorithm RtoMin RtoMax MaxConn ActiveOpens PassiveOpens AttemptFails EstabResets CurrEstab InSegs OutSegs RetransSegs InErrs OutRsts InCsumErrors
+Tcp: 1 200 120000 -1 12 7 2 1 3 900 950 17 0 4 0
+Udp: InDatagrams NoPorts InErrors OutDatagrams RcvbufErrors SndbufErrors InCsumErrors IgnoredMulti
+Udp: 40 1 0 42 0 0 0 0
+`
+
+func TestParseSNMP(t *testing.T) {
+	stats, err := ParseSNMP(strings.NewReader(snmp))
+	require.NoError(t, err)
+
+	require.Equal(t, TCPStats{
+		ActiveOpens:  12,
+		PassiveOpens: 7,
+		AttemptFails: 2,
+		EstabResets:  1,
+		CurrEstab:    3,
+		InSegs:       900,
+		OutSegs:      950,
+		RetransSegs:  17,
+		OutRsts:      4,
+	}, stats.TCP)
+	require.Equal(t, UDPStats{InDatagrams: 40, OutDatagrams: 42, NoPorts: 1}, stats.UDP)
+	require.Equal(t, ICMPStats{InMsgs: 4, OutMsgs: 5, InDestUnreachs: 3}, stats.ICMP)
+
+	_, err = ParseSNMP(strings.NewReader("Tcp: ActiveOpens PassiveOpens\nTcp: 1\n"))
+	require.Error(t, err)
+}
+
+func TestParseTrafficStatsInterval(t *testing.T) {
+	interval, err := ParseTrafficStatsInterval([]string{"FOO=bar"})
+	require.NoError(t, err)
+	require.Zero(t, interval)
+
+	interval, err = ParseTrafficStatsInterval([]string{EnvTrafficStatsInterval + "=5s"})
+	require.NoError(t, err)
+	require.Equal(t, "5s", interval.String())
+}
@@ -0,0 +1,71 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// dirBackend stores blobs as files under a directory.
+type dirBackend struct {
+	dir string
+}
+
+var _ Backend = (*dirBackend)(nil)
+
+func newDirBackend(dir string) (*dirBackend, error) {
+	if dir == "" {
+		return nil, errors.New("the dir outputs backend requires a path")
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	return &dirBackend{dir: dir}, nil
+}
+
+func (b *dirBackend) path(key string) string {
+	return filepath.Join(b.dir, filepath.FromSlash(key))
+}
+
+func (b *dirBackend) Put(_ context.Context, key string, r io.Reader) error {
+	dst := b.path(key)
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+
+	// Write to a temporary file first, so that readers never see a partial
+	// blob.
+	f, err := ioutil.TempFile(filepath.Dir(dst), ".put-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	if _, err := io.Copy(f, r); err != nil {
+		_ = f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), dst)
+}
+
+func (b *dirBackend) Get(_ context.Context, key string) (io.ReadCloser, error) {
+	f, err := os.Open(b.path(key))
+	if os.IsNotExist(err) {
+		return nil, ErrNotFound
+	}
+	return f, err
+}
+
+func (b *dirBackend) Exists(_ context.Context, key string) (bool, error) {
+	_, err := os.Stat(b.path(key))
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	return err == nil, err
+}
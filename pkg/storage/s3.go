@@ -0,0 +1,112 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3manager"
+
+	"github.com/testground/testground/pkg/config"
+)
+
+// s3Backend stores blobs as objects of an S3 bucket. It also works against
+// S3-compatible stores, such as MinIO, or GCS with HMAC keys, by overriding
+// the endpoint.
+type s3Backend struct {
+	bucket   string
+	svc      *s3.S3
+	uploader *s3manager.Uploader
+}
+
+var _ Backend = (*s3Backend)(nil)
+
+func newS3Backend(cfg config.OutputsConfig, awscfg config.AWSConfig) (*s3Backend, error) {
+	if cfg.Bucket == "" {
+		return nil, errors.New("the s3 outputs backend requires a bucket")
+	}
+
+	region := cfg.Region
+	if region == "" {
+		region = awscfg.Region
+	}
+	if region == "" {
+		// Most S3-compatible stores ignore the region, but the SDK requires one.
+		region = "us-east-1"
+	}
+
+	c := aws.NewConfig().WithRegion(region).WithS3ForcePathStyle(cfg.PathStyle)
+	if cfg.Endpoint != "" {
+		c = c.WithEndpoint(cfg.Endpoint)
+	}
+
+	id, secret := cfg.AccessKeyID, cfg.SecretAccessKey
+	if id == "" && secret == "" {
+		id, secret = awscfg.AccessKeyID, awscfg.SecretAccessKey
+	}
+	if id != "" && secret != "" {
+		c = c.WithCredentials(credentials.NewStaticCredentials(id, secret, ""))
+	}
+
+	sess, err := session.NewSession(c)
+	if err != nil {
+		return nil, err
+	}
+
+	return &s3Backend{
+		bucket: cfg.Bucket,
+		svc:    s3.New(sess),
+		// Uploads are multipart, so that outputs are streamed rather than
+		// buffered whole.
+		uploader: s3manager.NewUploader(sess),
+	}, nil
+}
+
+func (b *s3Backend) Put(ctx context.Context, key string, r io.Reader) error {
+	_, err := b.uploader.UploadWithContext(ctx, &s3manager.UploadInput{
+		Bucket: aws.String(b.bucket),
+		Key:    aws.String(key),
+		Body:   r,
+	})
+	return err
+}
+
+func (b *s3Backend) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	out, err := b.svc.GetObjectWithContext(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(b.bucket),
+		Key:    aws.String(key),
+	})
+	if isNotFound(err) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return out.Body, nil
+}
+
+func (b *s3Backend) Exists(ctx context.Context, key string) (bool, error) {
+	_, err := b.svc.HeadObjectWithContext(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(b.bucket),
+		Key:    aws.String(key),
+	})
+	if isNotFound(err) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func isNotFound(err error) bool {
+	var rerr awserr.RequestFailure
+	if errors.As(err, &rerr) {
+		return rerr.StatusCode() == http.StatusNotFound
+	}
+	var aerr awserr.Error
+	return errors.As(err, &aerr) && aerr.Code() == s3.ErrCodeNoSuchKey
+}
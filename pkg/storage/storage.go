@@ -0,0 +1,57 @@
+// Package storage keeps the outputs of runs in a storage backend other than
+// the local disk of the daemon, such as an S3 bucket.
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+
+	"github.com/testground/testground/pkg/config"
+)
+
+// ErrNotFound is returned when a key doesn't exist in a backend.
+var ErrNotFound = errors.New("not found")
+
+// Backend stores blobs by key.
+type Backend interface {
+	// Put stores the contents of r under key, replacing what's there.
+	Put(ctx context.Context, key string, r io.Reader) error
+	// Get opens the blob stored under key; it's up to the caller to close
+	// it. It returns ErrNotFound if there's no such blob.
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	// Exists returns true if there's a blob stored under key.
+	Exists(ctx context.Context, key string) (bool, error)
+}
+
+// New returns the backend configured for outputs, or nil if outputs are only
+// kept on the local disk.
+func New(cfg config.OutputsConfig, aws config.AWSConfig) (Backend, error) {
+	if cfg.Backend == "" {
+		return nil, nil
+	}
+
+	var (
+		b   Backend
+		err error
+	)
+	switch cfg.Backend {
+	case "s3":
+		b, err = newS3Backend(cfg, aws)
+	case "dir":
+		b, err = newDirBackend(cfg.Path)
+	default:
+		err = fmt.Errorf("unknown outputs backend: %s; supported: s3, dir", cfg.Backend)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// OutputsKey is the key of the outputs archive of a task.
+func OutputsKey(prefix string, taskID string) string {
+	return path.Join(prefix, taskID, "outputs.tgz")
+}
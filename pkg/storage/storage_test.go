@@ -0,0 +1,106 @@
+package storage
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/testground/testground/pkg/config"
+)
+
+// fakeS3 serves the objects of path-style requests from memory.
+type fakeS3 struct {
+	sync.Mutex
+	objects map[string][]byte
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.Lock()
+	defer f.Unlock()
+
+	switch r.Method {
+	case http.MethodPut:
+		b, _ := ioutil.ReadAll(r.Body)
+		f.objects[r.URL.Path] = b
+	case http.MethodGet, http.MethodHead:
+		b, ok := f.objects[r.URL.Path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			if r.Method == http.MethodGet {
+				_, _ = w.Write([]byte(`<Error><Code>NoSuchKey</Code></Error>`))
+			}
+			return
+		}
+		if r.Method == http.MethodGet {
+			_, _ = w.Write(b)
+		}
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func testBackend(t *testing.T, b Backend) {
+	ctx := context.Background()
+	key := OutputsKey("runs", "c3ftkqjpc98qra498sg0")
+	require.Equal(t, "runs/c3ftkqjpc98qra498sg0/outputs.tgz", key)
+
+	ok, err := b.Exists(ctx, key)
+	require.NoError(t, err)
+	require.False(t, ok)
+
+	_, err = b.Get(ctx, key)
+	require.ErrorIs(t, err, ErrNotFound)
+
+	require.NoError(t, b.Put(ctx, key, strings.NewReader("outputs")))
+
+	ok, err = b.Exists(ctx, key)
+	require.NoError(t, err)
+	require.True(t, ok)
+
+	r, err := b.Get(ctx, key)
+	require.NoError(t, err)
+	defer r.Close()
+	data, err := ioutil.ReadAll(r)
+	require.NoError(t, err)
+	require.Equal(t, "outputs", string(data))
+}
+
+func TestDirBackend(t *testing.T) {
+	b, err := New(config.OutputsConfig{Backend: "dir", Path: t.TempDir()}, config.AWSConfig{})
+	require.NoError(t, err)
+	testBackend(t, b)
+}
+
+func TestS3Backend(t *testing.T) {
+	srv := httptest.NewServer(&fakeS3{objects: make(map[string][]byte)})
+	defer srv.Close()
+
+	b, err := New(config.OutputsConfig{
+		Backend:         "s3",
+		Bucket:          "outputs",
+		Endpoint:        srv.URL,
+		PathStyle:       true,
+		AccessKeyID:     "minio",
+		SecretAccessKey: "minio123",
+	}, config.AWSConfig{})
+	require.NoError(t, err)
+	testBackend(t, b)
+}
+
+func TestNew(t *testing.T) {
+	b, err := New(config.OutputsConfig{}, config.AWSConfig{})
+	require.NoError(t, err)
+	require.Nil(t, b)
+
+	_, err = New(config.OutputsConfig{Backend: "s3"}, config.AWSConfig{})
+	require.Error(t, err)
+
+	_, err = New(config.OutputsConfig{Backend: "ftp"}, config.AWSConfig{})
+	require.Error(t, err)
+}
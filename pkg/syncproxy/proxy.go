@@ -0,0 +1,260 @@
+// Package syncproxy implements a sync service endpoint that shards the topics
+// and barriers of runs across several sync service replicas.
+//
+// Instances connect to the proxy as they would to a single sync service. The
+// proxy routes every request to the replica owning its topic or state, picked
+// by hashing its key, so that the pubsub fan-out and the barrier contention of
+// large runs are spread over the replicas. All the requests on a given topic
+// or state reach the same replica, so a single hot topic is still served by a
+// single replica.
+package syncproxy
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"net/http"
+	"strings"
+	"sync"
+
+	tgsync "github.com/testground/sync-service"
+	"go.uber.org/zap"
+	"nhooyr.io/websocket"
+	"nhooyr.io/websocket/wsjson"
+)
+
+// Proxy shards the requests of sync service clients across replicas.
+type Proxy struct {
+	replicas []string
+	log      *zap.SugaredLogger
+}
+
+// New returns a proxy for the given replicas, as host:port addresses or
+// websocket URLs.
+func New(replicas []string, log *zap.SugaredLogger) (*Proxy, error) {
+	if len(replicas) == 0 {
+		return nil, errors.New("at least one sync service replica is required")
+	}
+
+	p := &Proxy{replicas: make([]string, 0, len(replicas)), log: log}
+	for _, r := range replicas {
+		if r == "" {
+			return nil, errors.New("empty sync service replica address")
+		}
+		if !strings.HasPrefix(r, "ws://") && !strings.HasPrefix(r, "wss://") {
+			r = "ws://" + r
+		}
+		p.replicas = append(p.replicas, r)
+	}
+	return p, nil
+}
+
+// Shard returns the index of the replica owning a topic or a state.
+func (p *Proxy) Shard(key string) int {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(key))
+	return int(h.Sum32() % uint32(len(p.replicas)))
+}
+
+// ServeHTTP serves a sync service client, until it disconnects.
+func (p *Proxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	c, err := websocket.Accept(w, r, &websocket.AcceptOptions{
+		InsecureSkipVerify: true, // Accept requests from all domains, like the sync service.
+	})
+	if err != nil {
+		p.log.Warnw("could not upgrade connection", "err", err)
+		return
+	}
+
+	ctx, cancel := context.WithCancel(r.Context())
+	s := &session{
+		proxy:     p,
+		client:    c,
+		ctx:       ctx,
+		cancel:    cancel,
+		upstreams: make([]*websocket.Conn, len(p.replicas)),
+		routes:    make(map[string]int),
+	}
+
+	err = s.serve()
+	s.close()
+
+	switch {
+	case err == nil,
+		errors.Is(err, context.Canceled),
+		websocket.CloseStatus(err) == websocket.StatusNormalClosure,
+		websocket.CloseStatus(err) == websocket.StatusGoingAway:
+		_ = c.Close(websocket.StatusNormalClosure, "")
+	default:
+		p.log.Warnw("sync proxy connection closed unexpectedly", "err", err)
+		_ = c.Close(websocket.StatusInternalError, "")
+	}
+}
+
+// requestKey returns the topic or the state a request operates on.
+func requestKey(req *tgsync.Request) string {
+	switch {
+	case req.PublishRequest != nil:
+		return req.PublishRequest.Topic
+	case req.SubscribeRequest != nil:
+		return req.SubscribeRequest.Topic
+	case req.BarrierRequest != nil:
+		return req.BarrierRequest.State
+	case req.SignalEntryRequest != nil:
+		return req.SignalEntryRequest.State
+	default:
+		return ""
+	}
+}
+
+// session relays the requests of a client to the replicas, over a connection
+// per replica, and their responses back. Request IDs are unique per client,
+// so responses are relayed as they are.
+type session struct {
+	proxy  *Proxy
+	client *websocket.Conn
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	// wlk serializes the responses written to the client.
+	wlk sync.Mutex
+
+	// upstreams are the connections to the replicas, dialed on first use.
+	upstreams []*websocket.Conn
+	// routes are the replicas serving the subscriptions of the client, where
+	// their cancellations go.
+	routes map[string]int
+
+	errLk sync.Mutex
+	err   error
+
+	wg sync.WaitGroup
+}
+
+// serve reads the requests of the client, and relays them.
+func (s *session) serve() error {
+	for {
+		typ, data, err := s.client.Read(s.ctx)
+		if err != nil {
+			// A replica failing ends the session too.
+			if ferr := s.failure(); ferr != nil {
+				return ferr
+			}
+			return err
+		}
+
+		// Requests are relayed as they are; they are only decoded to route
+		// them.
+		var req tgsync.Request
+		if err := json.Unmarshal(data, &req); err != nil {
+			return fmt.Errorf("failed to decode request: %w", err)
+		}
+
+		var shard int
+		if req.IsCancel {
+			var ok bool
+			if shard, ok = s.routes[req.ID]; !ok {
+				s.proxy.log.Warnw("attempt to cancel not cancellable request", "id", req.ID)
+				continue
+			}
+			delete(s.routes, req.ID)
+		} else {
+			shard = s.proxy.Shard(requestKey(&req))
+			if req.SubscribeRequest != nil {
+				s.routes[req.ID] = shard
+			}
+		}
+
+		upstream, err := s.upstream(shard)
+		if err == nil {
+			err = upstream.Write(s.ctx, typ, data)
+		}
+		if err != nil {
+			if req.IsCancel {
+				continue
+			}
+			err = fmt.Errorf("sync service replica %s unavailable: %w", s.proxy.replicas[shard], err)
+			if werr := s.respond(&tgsync.Response{ID: req.ID, Error: err.Error()}); werr != nil {
+				return werr
+			}
+		}
+	}
+}
+
+// upstream returns the connection to a replica, and dials it if needed.
+func (s *session) upstream(shard int) (*websocket.Conn, error) {
+	if c := s.upstreams[shard]; c != nil {
+		return c, nil
+	}
+
+	c, _, err := websocket.Dial(s.ctx, s.proxy.replicas[shard], nil)
+	if err != nil {
+		return nil, err
+	}
+	s.upstreams[shard] = c
+
+	s.wg.Add(1)
+	go s.relay(shard, c)
+	return c, nil
+}
+
+// relay relays the responses of a replica to the client.
+func (s *session) relay(shard int, c *websocket.Conn) {
+	defer s.wg.Done()
+
+	for {
+		typ, data, err := c.Read(s.ctx)
+		if err != nil {
+			if s.ctx.Err() == nil {
+				s.fail(fmt.Errorf("lost sync service replica %s: %w", s.proxy.replicas[shard], err))
+			}
+			return
+		}
+
+		s.wlk.Lock()
+		err = s.client.Write(s.ctx, typ, data)
+		s.wlk.Unlock()
+		if err != nil {
+			s.fail(err)
+			return
+		}
+	}
+}
+
+func (s *session) respond(resp *tgsync.Response) error {
+	s.wlk.Lock()
+	defer s.wlk.Unlock()
+
+	return wsjson.Write(s.ctx, s.client, resp)
+}
+
+// fail ends the session: the client would otherwise wait forever on the
+// requests a failed replica served.
+func (s *session) fail(err error) {
+	s.errLk.Lock()
+	defer s.errLk.Unlock()
+
+	if s.err == nil {
+		s.err = err
+		s.cancel()
+	}
+}
+
+func (s *session) failure() error {
+	s.errLk.Lock()
+	defer s.errLk.Unlock()
+
+	return s.err
+}
+
+func (s *session) close() {
+	s.cancel()
+	for _, c := range s.upstreams {
+		if c != nil {
+			_ = c.Close(websocket.StatusNormalClosure, "")
+		}
+	}
+	s.wg.Wait()
+}
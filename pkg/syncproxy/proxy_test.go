@@ -0,0 +1,249 @@
+package syncproxy
+
+import (
+	"context"
+	"fmt"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	tgsync "github.com/testground/sync-service"
+	"nhooyr.io/websocket"
+	"nhooyr.io/websocket/wsjson"
+
+	"github.com/testground/testground/pkg/logging"
+)
+
+// startReplicas starts n in-memory sync services, and returns their
+// addresses.
+func startReplicas(t testing.TB, n int) []string {
+	addrs := make([]string, 0, n)
+	for i := 0; i < n; i++ {
+		svc, err := tgsync.NewDefaultService(context.Background(), logging.S())
+		require.NoError(t, err)
+		srv, err := tgsync.NewServer(svc, 0)
+		require.NoError(t, err)
+		go func() { _ = srv.Serve() }()
+		t.Cleanup(func() { _ = srv.Shutdown(context.Background()) })
+
+		addrs = append(addrs, "127.0.0.1:"+strconv.Itoa(srv.Port()))
+	}
+	return addrs
+}
+
+func startProxy(t testing.TB, replicas []string) (*Proxy, string) {
+	p, err := New(replicas, logging.S())
+	require.NoError(t, err)
+
+	srv := httptest.NewServer(p)
+	t.Cleanup(srv.Close)
+	return p, "ws://" + strings.TrimPrefix(srv.URL, "http://")
+}
+
+// testClient speaks the sync service protocol.
+type testClient struct {
+	t    testing.TB
+	conn *websocket.Conn
+	next int
+
+	lk        sync.Mutex
+	responses map[string]chan *tgsync.Response
+}
+
+func dial(t testing.TB, addr string) *testClient {
+	if !strings.HasPrefix(addr, "ws://") {
+		addr = "ws://" + addr
+	}
+	conn, _, err := websocket.Dial(context.Background(), addr, nil)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = conn.Close(websocket.StatusNormalClosure, "") })
+
+	c := &testClient{t: t, conn: conn, responses: make(map[string]chan *tgsync.Response)}
+	go func() {
+		for {
+			var resp tgsync.Response
+			if err := wsjson.Read(context.Background(), conn, &resp); err != nil {
+				return
+			}
+			c.lk.Lock()
+			ch := c.responses[resp.ID]
+			c.lk.Unlock()
+			if ch != nil {
+				ch <- &resp
+			}
+		}
+	}()
+	return c
+}
+
+func (c *testClient) send(req *tgsync.Request) chan *tgsync.Response {
+	c.lk.Lock()
+	c.next++
+	req.ID = strconv.Itoa(c.next)
+	ch := make(chan *tgsync.Response, 16)
+	c.responses[req.ID] = ch
+	c.lk.Unlock()
+
+	require.NoError(c.t, wsjson.Write(context.Background(), c.conn, req))
+	return ch
+}
+
+func (c *testClient) await(ch chan *tgsync.Response) *tgsync.Response {
+	select {
+	case resp := <-ch:
+		require.Empty(c.t, resp.Error)
+		return resp
+	case <-time.After(5 * time.Second):
+		c.t.Fatal("timed out waiting for a response")
+		return nil
+	}
+}
+
+// keysOnShards returns a key owned by every replica of a proxy.
+func keysOnShards(p *Proxy, prefix string) []string {
+	keys := make([]string, len(p.replicas))
+	for i, found := 0, 0; found < len(keys); i++ {
+		k := fmt.Sprintf("%s-%d", prefix, i)
+		if s := p.Shard(k); keys[s] == "" {
+			keys[s] = k
+			found++
+		}
+	}
+	return keys
+}
+
+func TestNew(t *testing.T) {
+	_, err := New(nil, logging.S())
+	require.Error(t, err)
+
+	p, err := New([]string{"sync-0:5050", "ws://sync-1:5050"}, logging.S())
+	require.NoError(t, err)
+	require.Equal(t, []string{"ws://sync-0:5050", "ws://sync-1:5050"}, p.replicas)
+
+	// Keys are owned by the same replica every time.
+	require.Equal(t, p.Shard("run:1:topic"), p.Shard("run:1:topic"))
+}
+
+func TestProxyShardsTopics(t *testing.T) {
+	replicas := startReplicas(t, 3)
+	p, addr := startProxy(t, replicas)
+	c := dial(t, addr)
+
+	for shard, topic := range keysOnShards(p, "topic") {
+		sub := c.send(&tgsync.Request{SubscribeRequest: &tgsync.SubscribeRequest{Topic: topic}})
+		pub := c.send(&tgsync.Request{PublishRequest: &tgsync.PublishRequest{Topic: topic, Payload: map[string]int{"n": 12345678901}}})
+		require.Equal(t, 1, c.await(pub).PublishResponse.Seq)
+		require.JSONEq(t, `{"n":12345678901}`, c.await(sub).SubscribeResponse)
+
+		// The item lives on the replica owning the topic.
+		direct := dial(t, replicas[shard])
+		pub = direct.send(&tgsync.Request{PublishRequest: &tgsync.PublishRequest{Topic: topic, Payload: "direct"}})
+		require.Equal(t, 2, direct.await(pub).PublishResponse.Seq)
+		require.JSONEq(t, `"direct"`, c.await(sub).SubscribeResponse)
+	}
+}
+
+func TestProxyBarriers(t *testing.T) {
+	_, addr := startProxy(t, startReplicas(t, 2))
+	a, b := dial(t, addr), dial(t, addr)
+
+	barrier := a.send(&tgsync.Request{BarrierRequest: &tgsync.BarrierRequest{State: "ready", Target: 2}})
+
+	sa := a.await(a.send(&tgsync.Request{SignalEntryRequest: &tgsync.SignalEntryRequest{State: "ready"}}))
+	sb := b.await(b.send(&tgsync.Request{SignalEntryRequest: &tgsync.SignalEntryRequest{State: "ready"}}))
+	require.ElementsMatch(t, []int{1, 2}, []int{sa.SignalEntryResponse.Seq, sb.SignalEntryResponse.Seq})
+
+	a.await(barrier)
+}
+
+func TestProxyUnavailableReplica(t *testing.T) {
+	p, addr := startProxy(t, append(startReplicas(t, 1), "127.0.0.1:1"))
+	c := dial(t, addr)
+
+	keys := keysOnShards(p, "topic")
+	resp := <-c.send(&tgsync.Request{PublishRequest: &tgsync.PublishRequest{Topic: keys[1], Payload: 1}})
+	require.Contains(t, resp.Error, "unavailable")
+
+	// The other replica still serves its topics.
+	pub := c.send(&tgsync.Request{PublishRequest: &tgsync.PublishRequest{Topic: keys[0], Payload: 1}})
+	require.Equal(t, 1, c.await(pub).PublishResponse.Seq)
+}
+
+// benchmarkBarriers has clients, connected to addr, signal and wait on a new
+// barrier per iteration, over 8 states.
+func benchmarkBarriers(b *testing.B, addr string, clients int) {
+	cs := make([]*testClient, clients)
+	for i := range cs {
+		cs[i] = dial(b, addr)
+	}
+
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		var wg sync.WaitGroup
+		for i, c := range cs {
+			wg.Add(1)
+			go func(i int, c *testClient) {
+				defer wg.Done()
+				state := fmt.Sprintf("round-%d-state-%d", n, i%8)
+				barrier := c.send(&tgsync.Request{BarrierRequest: &tgsync.BarrierRequest{State: state, Target: clients / 8}})
+				c.await(c.send(&tgsync.Request{SignalEntryRequest: &tgsync.SignalEntryRequest{State: state}}))
+				c.await(barrier)
+			}(i, c)
+		}
+		wg.Wait()
+	}
+}
+
+func BenchmarkBarriersDirect(b *testing.B) {
+	benchmarkBarriers(b, startReplicas(b, 1)[0], 256)
+}
+
+func BenchmarkBarriersProxy1(b *testing.B) {
+	_, addr := startProxy(b, startReplicas(b, 1))
+	benchmarkBarriers(b, addr, 256)
+}
+
+func BenchmarkBarriersProxy4(b *testing.B) {
+	_, addr := startProxy(b, startReplicas(b, 4))
+	benchmarkBarriers(b, addr, 256)
+}
+
+// benchmarkFanOut has subscribers, connected to addr, receive the items
+// published on 8 topics.
+func benchmarkFanOut(b *testing.B, addr string, subscribers int) {
+	pub := dial(b, addr)
+	topics := make([]string, 8)
+	for i := range topics {
+		topics[i] = fmt.Sprintf("fanout-%d", i)
+	}
+
+	subs := make([]chan *tgsync.Response, subscribers)
+	cs := make([]*testClient, subscribers)
+	for i := range subs {
+		cs[i] = dial(b, addr)
+		subs[i] = cs[i].send(&tgsync.Request{SubscribeRequest: &tgsync.SubscribeRequest{Topic: topics[i%len(topics)]}})
+	}
+
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		for _, topic := range topics {
+			pub.await(pub.send(&tgsync.Request{PublishRequest: &tgsync.PublishRequest{Topic: topic, Payload: n}}))
+		}
+		for i, ch := range subs {
+			cs[i].await(ch)
+		}
+	}
+}
+
+func BenchmarkFanOutDirect(b *testing.B) {
+	benchmarkFanOut(b, startReplicas(b, 1)[0], 256)
+}
+
+func BenchmarkFanOutProxy4(b *testing.B) {
+	_, addr := startProxy(b, startReplicas(b, 4))
+	benchmarkFanOut(b, addr, 256)
+}
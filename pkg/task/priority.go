@@ -0,0 +1,38 @@
+package task
+
+import "fmt"
+
+// Priority classes of tasks. Tasks of a higher class are always popped
+// first; within a class, the tasks a client is waiting for are bumped by one,
+// and then tasks are popped in the order they were queued.
+const (
+	PriorityLow    = -10
+	PriorityNormal = 0
+	PriorityHigh   = 10
+)
+
+// ParsePriority returns the priority of a class: high, normal or low.
+func ParsePriority(class string) (int, error) {
+	switch class {
+	case "high":
+		return PriorityHigh, nil
+	case "normal", "":
+		return PriorityNormal, nil
+	case "low":
+		return PriorityLow, nil
+	default:
+		return 0, fmt.Errorf("unknown priority %q; supported: high, normal, low", class)
+	}
+}
+
+// PriorityClass returns the class of a priority.
+func PriorityClass(priority int) string {
+	switch {
+	case priority >= PriorityHigh:
+		return "high"
+	case priority < PriorityNormal:
+		return "low"
+	default:
+		return "normal"
+	}
+}
@@ -0,0 +1,23 @@
+package task
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParsePriority(t *testing.T) {
+	for class, priority := range map[string]int{"high": PriorityHigh, "normal": PriorityNormal, "": PriorityNormal, "low": PriorityLow} {
+		p, err := ParsePriority(class)
+		require.NoError(t, err)
+		require.Equal(t, priority, p)
+	}
+
+	_, err := ParsePriority("urgent")
+	require.Error(t, err)
+
+	// Waiting clients bump the priority within the class.
+	require.Equal(t, "high", PriorityClass(PriorityHigh+1))
+	require.Equal(t, "normal", PriorityClass(PriorityNormal+1))
+	require.Equal(t, "low", PriorityClass(PriorityLow+1))
+}
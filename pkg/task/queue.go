@@ -3,6 +3,7 @@ package task
 import (
 	"container/heap"
 	"errors"
+	"sort"
 	"sync"
 	"time"
 
@@ -96,6 +97,34 @@ func (q *Queue) PushUniqueByBranch(tsk *Task) error {
 	return err
 }
 
+// Len returns the number of tasks in the queue.
+func (q *Queue) Len() int {
+	q.Lock()
+	defer q.Unlock()
+	return q.tq.Len()
+}
+
+// Ahead returns the IDs of the tasks that will be popped before the task
+// with the given ID, in order, and the total number of queued tasks. It
+// returns false if the task isn't queued.
+func (q *Queue) Ahead(id string) ([]string, int, bool) {
+	q.Lock()
+	sorted := make(taskQueue, len(*q.tq))
+	copy(sorted, *q.tq)
+	q.Unlock()
+
+	sort.Sort(sorted)
+
+	ahead := make([]string, 0, len(sorted))
+	for _, tsk := range sorted {
+		if tsk.ID == id {
+			return ahead, len(sorted), true
+		}
+		ahead = append(ahead, tsk.ID)
+	}
+	return nil, len(sorted), false
+}
+
 // get the next item from the priority queue
 // Pop the task off of the queue
 // The task remains in the database, but is no longer in the heap.
@@ -117,6 +146,70 @@ func (q *Queue) Pop() (*Task, error) {
 	return tsk, nil
 }
 
+// PopFunc pops the task of highest priority that f accepts, like Pop; the
+// tasks f rejects stay queued. It returns ErrQueueEmpty if f accepts none.
+func (q *Queue) PopFunc(f func(*Task) bool) (*Task, error) {
+	q.Lock()
+	defer q.Unlock()
+
+	best := -1
+	for i, tsk := range *q.tq {
+		if f(tsk) && (best == -1 || q.tq.Less(i, best)) {
+			best = i
+		}
+	}
+	if best == -1 {
+		return nil, ErrQueueEmpty
+	}
+
+	tsk := heap.Remove(q.tq, best).(*Task)
+
+	logging.S().Debugw("queue.pop.got-task", "id", tsk.ID, "taskname", tsk.Name())
+	err := q.ts.ProcessTask(tsk)
+	if err != nil {
+		return nil, err
+	}
+	return tsk, nil
+}
+
+// Cancel removes the task with the given ID from the queue and archives it
+// as canceled, with the given reason as its error. It returns false if the
+// task isn't queued.
+func (q *Queue) Cancel(id string, reason string) (bool, error) {
+	q.Lock()
+	defer q.Unlock()
+
+	for i, tsk := range *q.tq {
+		if tsk.ID != id {
+			continue
+		}
+		heap.Remove(q.tq, i)
+		tsk.Error = reason
+		return true, q.cancelTask(tsk)
+	}
+	return false, nil
+}
+
+// RemoveFunc removes the tasks f accepts from the queue, and returns them.
+// The tasks are left as they are in the storage.
+func (q *Queue) RemoveFunc(f func(*Task) bool) []*Task {
+	q.Lock()
+	defer q.Unlock()
+
+	var removed []*Task
+	kept := make(taskQueue, 0, len(*q.tq))
+	for _, tsk := range *q.tq {
+		if f(tsk) {
+			removed = append(removed, tsk)
+		} else {
+			kept = append(kept, tsk)
+		}
+	}
+	*q.tq = kept
+	heap.Init(q.tq)
+	return removed
+}
+
 // Remove all existing tasks from the queue that match the given branch/string
 func (q *Queue) removeExisting(branch string, repo string) error {
 	var err error
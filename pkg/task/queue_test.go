@@ -36,6 +36,7 @@ func TestQueueIsPersistent(t *testing.T) {
 		t.Fatal(err)
 	}
 	assert.Equal(t, (*tsk).ID, (*tsk2).ID)
+	assert.Equal(t, 1, q.Len())
 }
 
 // Simulate persistance between restarts.
@@ -250,3 +251,152 @@ func convertTask(taskData []byte) (*Task, error) {
 	}
 	return tsk, nil
 }
+
+func TestQueueAhead(t *testing.T) {
+	inmem := storage.NewMemStorage()
+	db, err := leveldb.Open(inmem, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	q, err := NewQueue(&Storage{db}, 10, convertTask)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+	for _, tsk := range []*Task{
+		{ID: "bt4brhjpc98qra498sg0", States: []DatedState{{Created: now}}},
+		{ID: "bt4brhjpc98qra498sg1", States: []DatedState{{Created: now.Add(time.Second)}}},
+		{ID: "bt4brhjpc98qra498sg2", Priority: 1, States: []DatedState{{Created: now.Add(2 * time.Second)}}},
+	} {
+		if err := q.Push(tsk); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The task with the higher priority jumps the queue.
+	ahead, n, ok := q.Ahead("bt4brhjpc98qra498sg1")
+	assert.True(t, ok)
+	assert.Equal(t, 3, n)
+	assert.Equal(t, []string{"bt4brhjpc98qra498sg2", "bt4brhjpc98qra498sg0"}, ahead)
+
+	_, _, ok = q.Ahead("bt4brhjpc98qra498sg9")
+	assert.False(t, ok)
+}
+
+func TestQueuePopFunc(t *testing.T) {
+	inmem := storage.NewMemStorage()
+	db, err := leveldb.Open(inmem, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	q, err := NewQueue(&Storage{db}, 10, convertTask)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+	for _, tsk := range []*Task{
+		{ID: "bt4brhjpc98qra498sg0", Runner: "local:docker", Priority: 1, States: []DatedState{{Created: now}}},
+		{ID: "bt4brhjpc98qra498sh0", Runner: "cluster:k8s", States: []DatedState{{Created: now.Add(time.Second)}}},
+		{ID: "bt4brhjpc98qra498si0", Runner: "cluster:k8s", States: []DatedState{{Created: now.Add(2 * time.Second)}}},
+	} {
+		if err := q.Push(tsk); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	notDocker := func(tsk *Task) bool { return tsk.Runner != "local:docker" }
+
+	// The first task that is accepted is popped, in priority order.
+	tsk, err := q.PopFunc(notDocker)
+	assert.NoError(t, err)
+	assert.Equal(t, "bt4brhjpc98qra498sh0", tsk.ID)
+
+	tsk, err = q.PopFunc(notDocker)
+	assert.NoError(t, err)
+	assert.Equal(t, "bt4brhjpc98qra498si0", tsk.ID)
+
+	_, err = q.PopFunc(notDocker)
+	assert.Equal(t, ErrQueueEmpty, err)
+	assert.Equal(t, 1, q.Len())
+
+	tsk, err = q.Pop()
+	assert.NoError(t, err)
+	assert.Equal(t, "bt4brhjpc98qra498sg0", tsk.ID)
+}
+
+func TestQueueCancel(t *testing.T) {
+	inmem := storage.NewMemStorage()
+	db, err := leveldb.Open(inmem, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts := &Storage{db}
+	q, err := NewQueue(ts, 10, convertTask)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+	for _, tsk := range []*Task{
+		{ID: "bt4brhjpc98qra498sg0", States: []DatedState{{State: StateScheduled, Created: now}}},
+		{ID: "bt4brhjpc98qra498sh0", States: []DatedState{{State: StateScheduled, Created: now.Add(time.Second)}}},
+	} {
+		if err := q.Push(tsk); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ok, err := q.Cancel("bt4brhjpc98qra498sg0", "canceled by alice")
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, 1, q.Len())
+
+	tsk, err := ts.Get("bt4brhjpc98qra498sg0")
+	assert.NoError(t, err)
+	assert.Equal(t, StateCanceled, tsk.State().State)
+	assert.Equal(t, "canceled by alice", tsk.Error)
+
+	ok, err = q.Cancel("bt4brhjpc98qra498sg0", "canceled by alice")
+	assert.NoError(t, err)
+	assert.False(t, ok)
+
+	tsk, err = q.Pop()
+	assert.NoError(t, err)
+	assert.Equal(t, "bt4brhjpc98qra498sh0", tsk.ID)
+}
+
+func TestQueueRemoveFunc(t *testing.T) {
+	inmem := storage.NewMemStorage()
+	db, err := leveldb.Open(inmem, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	q, err := NewQueue(&Storage{db}, 10, convertTask)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+	for i, id := range []string{"bt4brhjpc98qra498sg0", "bt4brhjpc98qra498sh0", "bt4brhjpc98qra498si0", "bt4brhjpc98qra498sj0"} {
+		state := StateScheduled
+		if i%2 == 0 {
+			state = StateProcessing
+		}
+		if err := q.Push(&Task{ID: id, States: []DatedState{{State: state, Created: now.Add(time.Duration(i) * time.Second)}}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	removed := q.RemoveFunc(func(tsk *Task) bool { return tsk.State().State == StateProcessing })
+	assert.Len(t, removed, 2)
+	assert.Equal(t, 2, q.Len())
+
+	tsk, err := q.Pop()
+	assert.NoError(t, err)
+	assert.Equal(t, "bt4brhjpc98qra498sh0", tsk.ID)
+	tsk, err = q.Pop()
+	assert.NoError(t, err)
+	assert.Equal(t, "bt4brhjpc98qra498sj0", tsk.ID)
+}
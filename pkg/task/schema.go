@@ -0,0 +1,49 @@
+package task
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// CurrentVersion is the version of the schema of the task records created by
+// this version of testground. It is bumped whenever the format of the records
+// changes, and every bump comes with a migration from the previous version.
+const CurrentVersion = 1
+
+// migrations[v] migrates a raw task record from version v to version v+1.
+var migrations = []func(record map[string]interface{}) error{
+	// Version 0 records predate schema versioning; their format is that of
+	// version 1.
+	0: func(map[string]interface{}) error { return nil },
+}
+
+// MigrateRecord migrates a task record, in JSON, to the current version of
+// the schema. Records of a newer version than the current one are rejected,
+// as they may hold fields this version of testground doesn't know about.
+func MigrateRecord(data []byte) ([]byte, error) {
+	var record map[string]interface{}
+	if err := json.Unmarshal(data, &record); err != nil {
+		return nil, err
+	}
+
+	var version int
+	if v, ok := record["version"].(float64); ok {
+		version = int(v)
+	}
+
+	switch {
+	case version == CurrentVersion:
+		return data, nil
+	case version > CurrentVersion || version < 0:
+		return nil, fmt.Errorf("unsupported task schema version %d; this version of testground supports up to %d", version, CurrentVersion)
+	}
+
+	for ; version < CurrentVersion; version++ {
+		if err := migrations[version](record); err != nil {
+			return nil, fmt.Errorf("failed to migrate task from schema version %d: %w", version, err)
+		}
+		record["version"] = version + 1
+	}
+
+	return json.Marshal(record)
+}
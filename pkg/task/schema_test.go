@@ -0,0 +1,30 @@
+package task
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMigrateRecord(t *testing.T) {
+	// Records that predate schema versioning are stamped with the current
+	// version.
+	data, err := MigrateRecord([]byte(`{"id":"bt4brhjpc98qra498sg0","type":"run"}`))
+	assert.NoError(t, err)
+
+	var tsk Task
+	assert.NoError(t, json.Unmarshal(data, &tsk))
+	assert.Equal(t, CurrentVersion, tsk.Version)
+	assert.Equal(t, "bt4brhjpc98qra498sg0", tsk.ID)
+
+	// Current records are left untouched.
+	current := []byte(`{"version":1,"id":"bt4brhjpc98qra498sg0"}`)
+	data, err = MigrateRecord(current)
+	assert.NoError(t, err)
+	assert.Equal(t, current, data)
+
+	// Newer records are rejected.
+	_, err = MigrateRecord([]byte(`{"version":99}`))
+	assert.Error(t, err)
+}
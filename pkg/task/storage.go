@@ -63,6 +63,10 @@ func (s *Storage) get(prefix string, id string) (tsk *Task, err error) {
 	if err != nil {
 		return nil, err
 	}
+	val, err = MigrateRecord(val)
+	if err != nil {
+		return nil, err
+	}
 	err = json.Unmarshal(val, tsk)
 	if err != nil {
 		return nil, err
@@ -221,7 +225,11 @@ func (s *Storage) rangeIter(prefix string, start time.Time, end time.Time) (task
 	for iter.Next() {
 		tsk := &Task{}
 
-		err := json.Unmarshal(iter.Value(), tsk)
+		val, err := MigrateRecord(iter.Value())
+		if err != nil {
+			return nil, err
+		}
+		err = json.Unmarshal(val, tsk)
 		if err != nil {
 			return nil, err
 		}
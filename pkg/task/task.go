@@ -26,6 +26,7 @@ const (
 	OutcomeSuccess  Outcome = "success"
 	OutcomeFailure  Outcome = "failure"
 	OutcomeCanceled Outcome = "canceled"
+	OutcomeTimedOut Outcome = "timed_out"
 )
 
 // Type (kind: string) represents the kind of activity the daemon asked to perform. In alignment
@@ -33,11 +34,14 @@ const (
 // TypeBuild -- which functions similarly to `testground build`. The result of this task will contain
 // a build ID which can be used in a subsequent run.
 // TypeRun -- which functions similarly to `testground run`
+// TypePipeline -- which runs several runs of a composition, e.g. the runs of a sweep, and aggregates
+// their results.
 type Type string
 
 const (
-	TypeBuild Type = "build"
-	TypeRun   Type = "run"
+	TypeBuild    Type = "build"
+	TypeRun      Type = "run"
+	TypePipeline Type = "pipeline"
 )
 
 // DatedState (kind: struct) is a State with a timestamp.
@@ -46,30 +50,70 @@ type DatedState struct {
 	State   State     `json:"state"`
 }
 
+// Decision (kind: struct) is a scheduling or placement decision taken while
+// processing a task, e.g. why a build was cached or where instances ran.
+type Decision struct {
+	Time    time.Time `json:"time"`
+	Stage   string    `json:"stage"`
+	Message string    `json:"message"`
+}
+
+// BuildFile (kind: struct) is a file a build produced for consumers other
+// than runners, e.g. the binary exec:go builds, kept by the daemon for
+// `testground build collect`.
+type BuildFile struct {
+	Name    string   `json:"name"`    // Path of the file in the collected archive
+	Path    string   `json:"path"`    // Path of the file kept by the daemon
+	Groups  []string `json:"groups"`  // Groups the build was for
+	Builder string   `json:"builder"` // Builder that produced the file
+	Size    int64    `json:"size"`
+	SHA256  string   `json:"sha256"`
+}
+
 type CreatedBy struct {
 	User   string `json:"user,omitempty"`
 	Repo   string `json:"repo,omitempty"`
 	Branch string `json:"branch,omitempty"`
 	Commit string `json:"commit,omitempty"`
+	// Authenticated is whether User is the name of the token the task was
+	// submitted with, rather than the user the client claimed to be.
+	Authenticated bool `json:"authenticated,omitempty"`
+}
+
+// PlanSource (kind: struct) is the git repository and commit the test plan of
+// a task was fetched from, if it was imported from one.
+type PlanSource struct {
+	URL    string `json:"url"`
+	Dir    string `json:"dir,omitempty"`
+	Ref    string `json:"ref,omitempty"`
+	Commit string `json:"commit"`
 }
 
 // Task (kind: struct) contains metadata about a testground task. This schema is used to store
 // metadata in our task storage database as well as the wire format returned when clients get the
 // state of a running or scheduled task.
 type Task struct {
-	Version     int          `json:"version"`     // Schema version
-	Priority    int          `json:"priority"`    // Scheduling priority
-	ID          string       `json:"id"`          // Unique identifier for this task
-	Runner      string       `json:"runner"`      // Runner that ran this task
-	Plan        string       `json:"plan"`        // Test plan
-	Case        string       `json:"case"`        // Test case
-	States      []DatedState `json:"states"`      // State of the task
-	Type        Type         `json:"type"`        // Type of the task
-	Composition interface{}  `json:"composition"` // Composition used for the task
-	Input       interface{}  `json:"input"`       // The input data for this task
-	Result      interface{}  `json:"result"`      // Result of the task, when terminal.
-	Error       string       `json:"error"`       // Error from Testground
-	CreatedBy   CreatedBy    `json:"created_by"`  // Who created the task
+	Version     int          `json:"version"`               // Schema version
+	Priority    int          `json:"priority"`              // Scheduling priority
+	ID          string       `json:"id"`                    // Unique identifier for this task
+	Runner      string       `json:"runner"`                // Runner that ran this task
+	Plan        string       `json:"plan"`                  // Test plan
+	Case        string       `json:"case"`                  // Test case
+	States      []DatedState `json:"states"`                // State of the task
+	Type        Type         `json:"type"`                  // Type of the task
+	Composition interface{}  `json:"composition"`           // Composition used for the task
+	Input       interface{}  `json:"input"`                 // The input data for this task
+	Result      interface{}  `json:"result"`                // Result of the task, when terminal.
+	Error       string       `json:"error"`                 // Error from Testground
+	CreatedBy   CreatedBy    `json:"created_by"`            // Who created the task
+	Attempt     int          `json:"attempt,omitempty"`     // Attempt at a retried run, starting at 1
+	RetryOf     string       `json:"retry_of,omitempty"`    // Task this task retries, if any
+	RetriedBy   string       `json:"retried_by,omitempty"`  // Task retrying this task, if any
+	Decisions   []Decision   `json:"decisions,omitempty"`   // Decisions taken while processing the task
+	PlanSource  *PlanSource  `json:"plan_source,omitempty"` // Remote source of the test plan, if any
+	Provenance  interface{}  `json:"provenance,omitempty"`  // What a run was executed from, to replay it
+	Dashboard   string       `json:"dashboard,omitempty"`   // URL of the Grafana dashboard of a run, if provisioned
+	BuildFiles  []BuildFile  `json:"build_files,omitempty"` // Files the builds of a build task produced
 }
 
 func (t *Task) Created() time.Time {
@@ -88,7 +132,7 @@ func (t *Task) Name() string {
 	switch t.Type {
 	case TypeBuild:
 		return "build"
-	case TypeRun:
+	case TypeRun, TypePipeline:
 		return fmt.Sprintf("%s:%s", t.Plan, t.Case)
 	default:
 		return "not supported"
@@ -0,0 +1,40 @@
+package tracing
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestPropagation(t *testing.T) {
+	otel.SetTracerProvider(sdktrace.NewTracerProvider())
+
+	require.Nil(t, Inject(context.Background()))
+
+	ctx, span := Start(context.Background(), "root")
+	defer span.End()
+
+	// through the task queue.
+	carrier := Inject(ctx)
+	require.NotEmpty(t, carrier)
+	resumed := trace.SpanContextFromContext(Extract(context.Background(), carrier))
+	require.Equal(t, span.SpanContext().TraceID(), resumed.TraceID())
+
+	// through http.
+	var got trace.SpanContext
+	srv := Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = trace.SpanContextFromContext(r.Context())
+	}))
+	req := httptest.NewRequest("GET", "/run", nil)
+	InjectHTTP(ctx, req.Header)
+	srv.ServeHTTP(httptest.NewRecorder(), req)
+
+	require.Equal(t, span.SpanContext().TraceID(), got.TraceID())
+	require.NotEqual(t, span.SpanContext().SpanID(), got.SpanID())
+}